@@ -293,6 +293,24 @@ func startServer(c *cli.Context) error {
 		}
 	}()
 
+	// SIGHUP reloads the WebRTC config used for new participants
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	go func() {
+		for range reloadChan {
+			reloadConf, err := getConfig(c)
+			if err == nil {
+				err = server.RoomManager().ReloadRTCConfig(reloadConf)
+			}
+			if err != nil {
+				logger.Errorw("could not reload config, keeping the current one", err)
+				continue
+			}
+			logger.Infow("reloaded WebRTC config")
+		}
+	}()
+
 	return server.Start()
 }
 
@@ -37,6 +37,28 @@ import (
 type (
 	CongestionControlProbeMode string
 	StreamTrackerType          string
+	KeyFrameRequestMethod      string
+	UnknownRTCPPolicy          string
+	SSRCCollisionPolicy        string
+	MalformedRTPPolicy         string
+	TURNTransport              string
+	AudioConcealmentMode       string
+	LossFallbackAction         string
+	CodecMatchingMode          string
+	SubscriptionMode           string
+	RTXAssociationPolicy       string
+	PriorityChangePolicy       string
+	InitialLayerMode           string
+	LowBandwidthPolicy         string
+	NoRTCPFallback             string
+	ICERestartPolicy           string
+	DTLSFingerprintMismatch    string
+	RIDMismatchPolicy          string
+	PubMutePolicy              string
+	PaddingPolicy              string
+	KeepalivePolicy            string
+	KeyFrameReorderPolicy      string
+	ICECandidateOrder          string
 )
 
 const (
@@ -48,6 +70,82 @@ const (
 	StreamTrackerTypePacket StreamTrackerType = "packet"
 	StreamTrackerTypeFrame  StreamTrackerType = "frame"
 
+	KeyFrameRequestMethodPLI KeyFrameRequestMethod = "pli"
+	KeyFrameRequestMethodFIR KeyFrameRequestMethod = "fir"
+
+	UnknownRTCPPolicyLog    UnknownRTCPPolicy = "log"
+	UnknownRTCPPolicyIgnore UnknownRTCPPolicy = "ignore"
+	UnknownRTCPPolicyCount  UnknownRTCPPolicy = "count"
+
+	SSRCCollisionPolicyRemap  SSRCCollisionPolicy = "remap"
+	SSRCCollisionPolicyReject SSRCCollisionPolicy = "reject"
+
+	MalformedRTPPolicyLog   MalformedRTPPolicy = "log"
+	MalformedRTPPolicyCount MalformedRTPPolicy = "count"
+
+	TURNTransportUDP TURNTransport = "udp"
+	TURNTransportTCP TURNTransport = "tcp"
+	TURNTransportTLS TURNTransport = "tls"
+
+	AudioConcealmentFEC AudioConcealmentMode = "fec"
+	AudioConcealmentPLC AudioConcealmentMode = "plc"
+
+	LossFallbackActionNone       LossFallbackAction = "none"
+	LossFallbackActionKeyFrame   LossFallbackAction = "key_frame"
+	LossFallbackActionLowerLayer LossFallbackAction = "lower_layer"
+
+	CodecMatchingLenient CodecMatchingMode = "lenient"
+	CodecMatchingStrict  CodecMatchingMode = "strict"
+
+	SubscriptionModeImmediate SubscriptionMode = "immediate"
+	SubscriptionModeDeferred  SubscriptionMode = "deferred"
+
+	RTXAssociationPolicyBestEffort RTXAssociationPolicy = "best_effort"
+	RTXAssociationPolicyStrict     RTXAssociationPolicy = "strict"
+
+	PriorityChangePolicyIgnore PriorityChangePolicy = "ignore"
+	PriorityChangePolicyAllow  PriorityChangePolicy = "allow"
+	PriorityChangePolicyDeny   PriorityChangePolicy = "deny"
+
+	InitialLayerHighest InitialLayerMode = "highest"
+	InitialLayerLowest  InitialLayerMode = "lowest"
+
+	LowBandwidthPolicyPause  LowBandwidthPolicy = "pause"
+	LowBandwidthPolicyLowest LowBandwidthPolicy = "lowest"
+	LowBandwidthPolicyFreeze LowBandwidthPolicy = "freeze"
+
+	NoRTCPFallbackConservative NoRTCPFallback = "conservative"
+	NoRTCPFallbackDisconnect   NoRTCPFallback = "disconnect"
+
+	ICERestartPolicyOnNetworkChange ICERestartPolicy = "on_network_change"
+	ICERestartPolicyOnDisconnect    ICERestartPolicy = "on_disconnect"
+	ICERestartPolicyNever           ICERestartPolicy = "never"
+
+	DTLSFingerprintMismatchReject      DTLSFingerprintMismatch = "reject"
+	DTLSFingerprintMismatchRenegotiate DTLSFingerprintMismatch = "renegotiate"
+
+	RIDMismatchPolicyDrop   RIDMismatchPolicy = "drop"
+	RIDMismatchPolicyRetry  RIDMismatchPolicy = "retry"
+	RIDMismatchPolicyAssign RIDMismatchPolicy = "assign"
+
+	PubMutePolicySilence PubMutePolicy = "silence"
+	PubMutePolicyStop    PubMutePolicy = "stop"
+	PubMutePolicyMarker  PubMutePolicy = "marker"
+
+	PaddingPolicyStripContiguous PaddingPolicy = "strip_contiguous"
+	PaddingPolicyStrip           PaddingPolicy = "strip"
+	PaddingPolicyForward         PaddingPolicy = "forward"
+
+	KeepalivePolicyAsPadding KeepalivePolicy = "as_padding"
+	KeepalivePolicyDrop      KeepalivePolicy = "drop"
+	KeepalivePolicyForward   KeepalivePolicy = "forward"
+
+	KeyFrameReorderPolicyDrop  KeyFrameReorderPolicy = "drop"
+	KeyFrameReorderPolicyDefer KeyFrameReorderPolicy = "defer"
+
+	ICECandidateOrderHostFirst  ICECandidateOrder = "host_first"
+	ICECandidateOrderSrflxFirst ICECandidateOrder = "srflx_first"
+
 	StatsUpdateInterval                  = time.Second * 10
 	TelemetryStatsUpdateInterval         = time.Second * 30
 	TelemetryNonMediaStatsUpdateInterval = time.Minute * 5
@@ -91,8 +189,14 @@ type RTCConfig struct {
 	rtcconfig.RTCConfig `yaml:",inline"`
 
 	TURNServers []TURNServer `yaml:"turn_servers,omitempty"`
+	// TURN transport listed first in the ICE servers given to clients, udp, tcp or tls, clients try them in order.
+	// Empty (default) lists the embedded TURN server first, followed by turn_servers in configured order
+	PreferredTURNTransport TURNTransport `yaml:"preferred_turn_transport,omitempty"`
 
 	StrictACKs bool `yaml:"strict_acks,omitempty"`
+	// With strict_acks, time a subscriber is given to acknowledge data channels. Channels count as open once dialed
+	// and the subscriber fails to connect only if the acknowledgement is still missing after it. 0 waits for it
+	StrictACKsGrace time.Duration `yaml:"strict_acks_grace,omitempty"`
 
 	// Deprecated: use PacketBufferSizeVideo and PacketBufferSizeAudio
 	PacketBufferSize int `yaml:"packet_buffer_size,omitempty"`
@@ -100,10 +204,319 @@ type RTCConfig struct {
 	PacketBufferSizeVideo int `yaml:"packet_buffer_size_video,omitempty"`
 	// Number of packets to buffer for NACK - audio
 	PacketBufferSizeAudio int `yaml:"packet_buffer_size_audio,omitempty"`
+	// Number of packets of an RTX stream to buffer until it is paired with its primary stream, defaults to packet_buffer_size_video
+	PacketBufferSizeRTX int `yaml:"packet_buffer_size_rtx,omitempty"`
+	// Maximum age of packets retransmitted on NACK, older packets are not retransmitted and buffers only grow to hold
+	// packets of that age, reducing the memory of high bitrate tracks. Applies alongside the sizes above, which bound
+	// the number of packets. 0 (default) retransmits any buffered packet
+	PacketBufferMaxAge time.Duration `yaml:"packet_buffer_max_age,omitempty"`
+	// Time after which packet buffers of incoming streams that are not used by a track and receive no packets are closed,
+	// releasing their memory, e.g. buffers of streams that were never published. Buffers of tracks are kept until the
+	// track is closed, also while it does not receive packets. 0 (default) keeps all buffers until they are closed
+	BufferIdleTimeout time.Duration `yaml:"buffer_idle_timeout,omitempty"`
+	// Packet buffer sizes of rooms whose name matches a pattern, overriding the sizes above, e.g. larger buffers for
+	// rooms that are recorded. The first matching entry applies
+	RoomPacketBufferSizes []RoomPacketBufferSizeConfig `yaml:"room_packet_buffer_sizes,omitempty"`
+	// Codecs that may be published in rooms whose name matches a pattern, e.g. the codecs of a tenant's plan. Other
+	// codecs are rejected when publishers negotiate. The first matching entry applies, other rooms allow all codecs
+	RoomPublishCodecs []RoomPublishCodecsConfig `yaml:"room_publish_codecs,omitempty"`
+	// Number of times a packet is retransmitted to a subscriber, further NACKs for it are ignored, defaults to 3
+	MaxRetransmits int `yaml:"max_retransmits,omitempty"`
+	// Do not request a key frame from the publisher when a subscriber starts receiving a video track, wait for the
+	// next key frame the publisher sends instead. Avoids bitrate spikes on joins when publishers send key frames regularly
+	DisableKeyFrameRequestOnSubscribe bool `yaml:"disable_key_frame_request_on_subscribe,omitempty"`
+
+	// Number of packets a dependency descriptor can arrive behind the highest received sequence number
+	// and still be associated with its frame, 0 means no limit
+	DDReorderTolerance int `yaml:"dd_reorder_tolerance,omitempty"`
+
+	// Handling of packets that arrive ahead of the key frame packet carrying the dependency descriptor structure they
+	// are parsed with, e.g. AV1 or VP9 SVC key frames whose first packet is reordered. drop (default) drops them,
+	// leaving the key frame incomplete for subscribers, defer holds them until the structure arrives and forwards
+	// them after it
+	KeyFrameReorderPolicy KeyFrameReorderPolicy `yaml:"key_frame_reorder_policy,omitempty"`
+	// Number of packets the structure can arrive behind held packets with defer, packets held longer are dropped,
+	// defaults to 64
+	KeyFrameReorderTolerance int `yaml:"key_frame_reorder_tolerance,omitempty"`
+
+	// Largest jump of published RTP timestamps beyond the time elapsed between packet arrivals that is forwarded as is.
+	// Larger jumps, e.g. from buggy encoders, are handled as a discontinuity and the stream continues from the
+	// expected timestamp. 0 (default) forwards timestamps as received
+	MaxTimestampJump time.Duration `yaml:"max_timestamp_jump,omitempty"`
+
+	// Interval between RTCP receiver reports sent to publishers - video, defaults to 1s
+	ReceiverReportIntervalVideo time.Duration `yaml:"receiver_report_interval_video,omitempty"`
+	// Interval between RTCP receiver reports sent to publishers - audio, defaults to 1s
+	ReceiverReportIntervalAudio time.Duration `yaml:"receiver_report_interval_audio,omitempty"`
+	// Maximum random deviation of the interval between RTCP receiver reports, spreading reports of many tracks
+	// instead of sending them in bursts - video, should be less than the interval, 0 (default) disables jitter
+	ReceiverReportJitterVideo time.Duration `yaml:"receiver_report_jitter_video,omitempty"`
+	// Maximum random deviation of the interval between RTCP receiver reports - audio
+	ReceiverReportJitterAudio time.Duration `yaml:"receiver_report_jitter_audio,omitempty"`
+	// Time without packets after which a published track that is not muted, nor paused by dynacast, is declared dead
+	// and its stream paused for subscribers till packets are received again - video, 0 (default) never declares tracks
+	// dead, otherwise at least 1s
+	DeadTrackTimeoutVideo time.Duration `yaml:"dead_track_timeout_video,omitempty"`
+	// Time without packets after which a published track that is not muted is declared dead and its stream paused for
+	// subscribers till packets are received again - audio, 0 (default) never declares tracks dead, otherwise at least
+	// 1s. Audio using DTX still sends a packet every 400ms
+	DeadTrackTimeoutAudio time.Duration `yaml:"dead_track_timeout_audio,omitempty"`
+	// Interval between RTCP sender reports sent to subscribers, refreshing their RTP/NTP mapping to limit drift
+	// on long lived sessions, defaults to 3s
+	SenderReportInterval time.Duration `yaml:"sender_report_interval,omitempty"`
+
+	// Maximum number of simulcast layers accepted from a publisher per track, 0 means no limit
+	MaxSimulcastLayers int `yaml:"max_simulcast_layers,omitempty"`
+
+	// Largest resolution accepted for each layer of a published video track, as declared by the publisher, in either
+	// orientation. Layers declared larger are not received. 0 does not limit the dimension, when only one is set it
+	// limits the longer side
+	MaxLayerWidth  uint32 `yaml:"max_layer_width,omitempty"`
+	MaxLayerHeight uint32 `yaml:"max_layer_height,omitempty"`
+
+	// negotiate abs-send-time on publisher video, in addition to transport-cc
+	PublisherAbsSendTime bool `yaml:"publisher_abs_send_time,omitempty"`
+
+	// negotiate the RTP stream id (rid) extension on subscriber video, carrying the rid of the forwarded layer.
+	// Meant for debugging client side demuxing, off by default as not all clients expect it
+	SubscriberRTPStreamID bool `yaml:"subscriber_rtp_stream_id,omitempty"`
+
+	// RTP header extension URIs that will not be negotiated, even when offered by the client
+	BlockedHeaderExtensions []string `yaml:"blocked_header_extensions,omitempty"`
+
+	// RTCP feedback that will not be negotiated for a codec, keyed by mime type, e.g. video/vp8: [goog-remb, nack pli].
+	// Entries are the feedback type, optionally followed by its parameter, and only remove an exact match
+	DisabledRTCPFeedback map[string][]string `yaml:"disabled_rtcp_feedback,omitempty"`
+
+	// do not negotiate any RTCP feedback (nack, pli, fir, bandwidth estimation) for video sent to subscribers,
+	// e.g. for one-way broadcasts where subscribers never send feedback
+	DisableSubscriberVideoFeedback bool `yaml:"disable_subscriber_video_feedback,omitempty"`
+
+	// send an RTCP extended report (XR, RFC 3611) statistics summary of loss, duplicate packets and jitter with each
+	// receiver report sent to publishers. Only received streams are summarized, subscribers are not sent summaries
+	RTCPExtendedReports bool `yaml:"rtcp_extended_reports,omitempty"`
+
+	// allow more header extensions than fit in one-byte headers (14). Extensions beyond that use two-byte headers,
+	// which are negotiated with clients signalling extmap-allow-mixed on the publisher connection. Offers made on the
+	// subscriber connection still only carry one-byte ids
+	TwoByteHeaderExtensions bool `yaml:"two_byte_header_extensions,omitempty"`
+
+	// RTCP packet used to request key frames from publishers, keyed by codec mime type (e.g. video/vp9),
+	// codecs not listed use pli
+	KeyFrameRequestMethods map[string]KeyFrameRequestMethod `yaml:"key_frame_request_methods,omitempty"`
+
+	// ICE transport policy (all or relay), keyed by participant kind (e.g. egress), kinds not listed use all
+	ICETransportPolicies map[string]string `yaml:"ice_transport_policies,omitempty"`
+
+	// Maximum number of key frame requests outstanding across all publishers on the node, 0 means no limit.
+	// A request is outstanding till a key frame is received, further requests are queued.
+	MaxOutstandingKeyFrameRequests int `yaml:"max_outstanding_key_frame_requests,omitempty"`
+
+	// Number of redundant encodings carried in audio RED packets, 1 to 8, defaults to a single redundant
+	// encoding in negotiation
+	AudioRedDistance int `yaml:"audio_red_distance,omitempty"`
+
+	// Packet loss concealment of forwarded opus audio, asked of publishers in answers. fec (default) keeps the in-band
+	// FEC publishers offer, so that subscribers recover lost packets from the next one, plc asks publishers not to
+	// encode in-band FEC, leaving its bitrate to audio, subscribers then conceal losses by extrapolation alone. Either
+	// way, subscribers negotiating RED recover losses from its redundant encodings
+	AudioConcealment AudioConcealmentMode `yaml:"audio_concealment,omitempty"`
+
+	// Opus sample rates offered, in order of preference, e.g. [48000, 16000] to also offer 16kHz opus to clients that
+	// only support it. Opus is always opus/48000/2 (RFC 7587), each rate is offered as a separate payload type signalling
+	// it with maxplaybackrate and sprop-maxcapturerate, so forwarded audio is not changed. Must include 48000, which
+	// audio RED is signalled for. Defaults to 48000 only, without signalling a rate
+	OpusSampleRates []uint32 `yaml:"opus_sample_rates,omitempty"`
+
+	// Maximum number of video codecs offered to subscribers for each track, keeping SDP small for constrained clients.
+	// Codecs are offered in order of preference, so the lowest priority ones are left out. Each mime type counts once,
+	// e.g. H264 with all its profiles, and RTX is not counted. The codecs a track is published in are always offered
+	// in addition. 0 offers all enabled codecs
+	MaxVideoCodecs int `yaml:"max_video_codecs,omitempty"`
+
+	// Maximum bitrate of audio in bps, 6000 to 510000, 0 means no limit. Published opus is capped by maxaveragebitrate
+	// in negotiation, redundancy added to forwarded audio RED is limited to stay within it and forwarded audio above
+	// it, e.g. from a publisher ignoring maxaveragebitrate, is dropped
+	MaxAudioBitrate int `yaml:"max_audio_bitrate,omitempty"`
+
+	// SRTP protection profiles allowed in DTLS negotiation, in order of preference, e.g. SRTP_AEAD_AES_128_GCM.
+	// Supported profiles are used when empty
+	SRTPProtectionProfiles []string `yaml:"srtp_protection_profiles,omitempty"`
+
+	// DTLS cipher suites allowed in handshakes, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Connections of clients
+	// that only offer other suites are rejected. Enforced on sockets of the ICE port range, so it cannot be used with
+	// udp_port or tcp_port. Suites supported by pion are allowed when empty
+	DTLSCipherSuites []string `yaml:"dtls_cipher_suites,omitempty"`
+
+	// mDNS candidate handling, one of disabled, query_only (remote .local candidates are resolved)
+	// or query_and_gather, follows use_mdns when not set
+	MDNSMode string `yaml:"mdns_mode,omitempty"`
+
+	// Local ICE candidates clients are steered to, by the priorities signalled for them
+	ICEPreference ICEPreferenceConfig `yaml:"ice_preference,omitempty"`
+
+	// Codecs (mime types, e.g. audio/red) that are always forwarded as published. Subscribers that cannot
+	// receive the published codec are refused instead of being sent a converted stream, e.g. opus extracted from red
+	PassthroughCodecs []string `yaml:"passthrough_codecs,omitempty"`
+
+	// Video codecs (mime types, e.g. video/h264) whose publishers may send frames out of presentation order, e.g.
+	// B-frames. Timestamps of forwarded streams are then allowed to go backwards between frames, and timestamps
+	// synthesized on layer switches, resumes and blank frames follow the highest timestamp forwarded
+	ReorderedFrameCodecs []string `yaml:"reordered_frame_codecs,omitempty"`
+
+	// Handling of a client DTLS certificate that does not match the fingerprint it signalled, e.g. after a
+	// reconnect. reject (default) fails the connection, renegotiate asks the client to reconnect with a new session
+	DTLSFingerprintMismatch DTLSFingerprintMismatch `yaml:"dtls_fingerprint_mismatch,omitempty"`
+
+	// When the server restarts ICE on the subscriber connection. on_network_change (default) restarts when a client
+	// resumes, on_disconnect also restarts once ICE is disconnected, i.e. no traffic for the ICE disconnected timeout,
+	// before the connection fails, at most once per ICE failed timeout. never does not restart from the server, a
+	// resuming client whose subscriber connection failed then does a full reconnect
+	ICERestartPolicy ICERestartPolicy `yaml:"ice_restart_policy,omitempty"`
+
+	// Handling of a simulcast stream whose first packets carry a rid (RTP stream id header extension) that was not
+	// negotiated for its media section in the publisher's offer. drop (default) does not receive the stream, retry
+	// buffers the packets of the stream till a renegotiation adds the rid or the stream switches to a negotiated one,
+	// assign receives the stream as the first negotiated rid of the media section that has no stream yet
+	RIDMismatchPolicy RIDMismatchPolicy `yaml:"rid_mismatch_policy,omitempty"`
+
+	// What subscribers are sent when a publisher mutes a track, forwarding stops in any case. silence (default) sends
+	// silence frames on audio tracks for a second so that decoders settle, stop sends nothing further, marker sends a
+	// single blank frame with the marker bit set on audio and video tracks
+	PubMutePolicy PubMutePolicy `yaml:"pub_mute_policy,omitempty"`
+
+	// Forwarding of padding only packets sent by publishers, e.g. to probe for bandwidth. strip_contiguous (default)
+	// drops ones following the last forwarded packet and forwards ones after a gap, strip drops all of them, forward
+	// forwards all of them. forward cannot be used with padding probes under send side bandwidth estimation, as the
+	// forwarded padding would be mistaken for the probe
+	PaddingPolicy PaddingPolicy `yaml:"padding_policy,omitempty"`
+
+	// Forwarding of keepalive packets sent by publishers, i.e. ones without payload and padding. as_padding (default)
+	// handles them like padding only packets per padding_policy, drop drops all of them to save bandwidth, forward
+	// forwards all of them
+	KeepalivePolicy KeepalivePolicy `yaml:"keepalive_policy,omitempty"`
+
+	// Per track source (e.g. camera, microphone) shift of forwarded RTP timestamps relative to the RTCP sender
+	// report mapping, to compensate for a known pipeline delay of that source when lip syncing. Negative values advance the track
+	SyncOffsets map[string]time.Duration `yaml:"sync_offsets,omitempty"`
+
+	// Maximum frame rate forwarded per video track source (e.g. screen_share), applied by dropping temporal layers
+	// whose frame rate exceeds the cap. Subscribers requesting a lower frame rate get the lower one. Needs
+	// temporal layers, e.g. signalled by the dependency descriptor extension, sources not listed are not capped
+	MaxFps map[string]uint32 `yaml:"max_fps,omitempty"`
+
+	// Upper bound on ICE candidate gathering, e.g. when a TURN allocation is slow to respond. Once reached,
+	// gathering is treated as complete with the candidates found so far, late candidates are not signalled. 0 waits indefinitely
+	ICEGatheringTimeout time.Duration `yaml:"ice_gathering_timeout,omitempty"`
+
+	// MTU of the path to subscribers, caps the size of datagrams sent to them to avoid IP fragmentation.
+	// Forwarded packets are not re-fragmented, ones that do not fit are dropped. 0 means no limit
+	MTU int `yaml:"mtu,omitempty"`
+
+	// Maximum packets queued for sending to a subscriber. Packets are then sent from a queue of this depth instead
+	// of being written as they are forwarded, the oldest ones are dropped when a slow subscriber lets the queue fill
+	// up. 0 (default) writes packets as they are forwarded
+	SubscriberSendQueueSize int `yaml:"subscriber_send_queue_size,omitempty"`
+
+	// Range of SSRCs of streams sent to subscribers, for interop with systems expecting SSRCs in a range. SSRCs are
+	// unique within a room and do not collide with published streams. Not set keeps the random SSRCs of pion
+	SSRCRangeStart uint32 `yaml:"ssrc_range_start,omitempty"`
+	SSRCRangeEnd   uint32 `yaml:"ssrc_range_end,omitempty"`
+
+	// IDs of connections (connID in logs) whose peer connections log every RTP packet, with the header extensions
+	// negotiated for its direction, every RTCP packet, and the decisions taken on feedback from the participant,
+	// e.g. NACKs ignored or key frame requests forwarded. Very verbose, meant for debugging a single connection.
+	// Tracing can also be turned on and off at runtime through /debug/packet_trace
+	PacketTraceConnections []string `yaml:"packet_trace_connections,omitempty"`
+
+	// Log levels of connections matching the given attributes, the first matching rule applies. Allows logging a
+	// subset of connections, e.g. of a client version under investigation, at a higher verbosity than the node
+	ConnectionLogLevels []ConnectionLogLevelConfig `yaml:"connection_log_levels,omitempty"`
+
+	// Spatial layer, 0 (lowest) to 2, that subscribers with the given identities receive of every video track,
+	// forwarded regardless of the estimated bandwidth and of the layers the subscriber requests
+	PinnedSpatialLayers map[string]int32 `yaml:"pinned_spatial_layers,omitempty"`
+
+	// Highest spatial and temporal layers forwarded to subscribers per SVC codec (mime type, e.g. video/av1), to
+	// bound the bandwidth of the codec. Applies to tracks forwarded using the dependency descriptor
+	SVCLayerCaps map[string]SVCLayerCapConfig `yaml:"svc_layer_caps,omitempty"`
+
+	// Handling of RTCP packets that cannot be parsed, e.g. proprietary packet types sent by some clients.
+	// log (default) drops the whole compound packet and logs an error, ignore and count drop only the
+	// unknown packets, count also increments the livekit_rtcp_unknown_total metric
+	UnknownRTCP UnknownRTCPPolicy `yaml:"unknown_rtcp,omitempty"`
+
+	// Handling of a subscribed track assigned the SSRC of a track published by the same participant, both then share
+	// RTCP. remap (default) delivers packets sent by the publisher using the SSRC to the published track and feedback
+	// of the subscriber about the SSRC to the subscribed track, reject fails the later one, so that it is set up again
+	// with a different SSRC
+	SSRCCollision SSRCCollisionPolicy `yaml:"ssrc_collision,omitempty"`
+
+	// Handling of published RTP packets that cannot be parsed, either the RTP header or the payload header of the
+	// codec. Both drop the packet and increment the livekit_rtp_malformed_total metric, log (default) also logs an
+	// error, count drops it silently
+	MalformedRTP MalformedRTPPolicy `yaml:"malformed_rtp,omitempty"`
+
+	// Handling of an RTX repair stream associated, through the SDP or the repaired-rtp-stream-id header extension,
+	// in a way that conflicts with a known association, e.g. a duplicate RTX SSRC. best_effort (default) uses the
+	// latest association, strict keeps the known one and ignores the conflicting one
+	RTXAssociation RTXAssociationPolicy `yaml:"rtx_association,omitempty"`
+
+	// Matching of codecs offered by publishers to the enabled codecs. lenient (default) prefers a codec with the same
+	// format parameters and falls back to one with the same mime type, strict needs the same payload format, i.e. the
+	// format parameters identifying it like the H264 profile and packetization mode, and does not negotiate a track
+	// when no enabled codec has it
+	CodecMatching CodecMatchingMode `yaml:"codec_matching,omitempty"`
+
+	// When media of a track is set up for a subscriber. immediate (default) sets it up as soon as the track is
+	// subscribed, deferred waits until the subscriber first updates the settings of a video track, i.e. is ready to
+	// render it, which spares large rooms setting up tracks that are not displayed. Deferral applies to subscribers
+	// without adaptive stream that have sent settings of a track, and lasts at most 10s
+	Subscription SubscriptionMode `yaml:"subscription,omitempty"`
 
 	// Throttle periods for pli/fir rtcp packets
 	PLIThrottle PLIThrottleConfig `yaml:"pli_throttle,omitempty"`
 
+	// Action on subscribed video when the subscriber keeps reporting loss that retransmissions do not recover
+	LossFallback LossFallbackConfig `yaml:"loss_fallback,omitempty"`
+
+	// Fractions of packets lost, between 0 and 1 exclusive, in receiver reports of a subscriber, crossing one in
+	// either direction is logged for the subscriber of the track and increments the loss threshold metric
+	LossThresholds []float64 `yaml:"loss_thresholds,omitempty"`
+
+	// Switch a subscriber to another codec of the same track when it fails to decode the forwarded one
+	CodecFallback CodecFallbackConfig `yaml:"codec_fallback,omitempty"`
+
+	// Forward header extensions negotiated with both the publisher and the subscriber that the SFU does not
+	// interpret, copying them as is into forwarded packets. Retransmissions and padding do not carry them
+	ForwardUnknownHeaderExtensions bool `yaml:"forward_unknown_header_extensions,omitempty"`
+
+	// Strip the CSRC list, identifying the contributing sources of mixed audio, from forwarded packets, for
+	// subscribers that do not handle it. By default the list of the publisher is forwarded as is
+	StripCSRC bool `yaml:"strip_csrc,omitempty"`
+
+	// Bitrate available to retransmissions of a video track to a subscriber
+	RetransmitBudget RetransmitBudgetConfig `yaml:"retransmit_budget,omitempty"`
+
+	// Refuse participants joining while the node is CPU saturated
+	CPUAdmissionControl CPUAdmissionControlConfig `yaml:"cpu_admission_control,omitempty"`
+
+	// Do not negotiate reduced-size RTCP (rtcp-rsize, RFC 5506), for clients that expect strict compound RTCP
+	DisableRTCPReducedSize bool `yaml:"disable_rtcp_reduced_size,omitempty"`
+
+	// Attribute keys (e.g. mid, ice-ufrag, rtpmap, fmtp) moved to the front of each section of answers, in the given order.
+	// Attributes not listed follow in the order pion generates them, empty keeps the generated order
+	AnswerAttributeOrder []string `yaml:"answer_attribute_order,omitempty"`
+
+	// DSCP marking of media sent over UDP, per kind of media. Only sockets of the ICE port range are marked,
+	// it is not supported with udp_port. Audio and video share the sockets of a peer connection, they are
+	// marked as video once video is negotiated on it
+	DSCP DSCPConfig `yaml:"dscp,omitempty"`
+
+	// Limit on offers accepted from a client on each of its peer connections, further offers fail negotiation
+	// and the client is asked to reconnect
+	RenegotiationLimit RenegotiationLimitConfig `yaml:"renegotiation_limit,omitempty"`
+
 	CongestionControl CongestionControlConfig `yaml:"congestion_control,omitempty"`
 
 	// allow TCP and TURN/TLS fallback
@@ -138,6 +551,101 @@ type PLIThrottleConfig struct {
 	HighQuality time.Duration `yaml:"high_quality,omitempty"`
 }
 
+type LossFallbackConfig struct {
+	// none (default), key_frame requests a key frame from the publisher, subject to pli_throttle, so that the
+	// subscriber resumes decoding, lower_layer forwards the next lower spatial layer till the subscriber updates its settings
+	Action LossFallbackAction `yaml:"action,omitempty"`
+	// fraction of packets lost, in receiver reports, above which loss is considered sustained, defaults to 0.1
+	LossThreshold float64 `yaml:"loss_threshold,omitempty"`
+	// time loss has to stay above the threshold before falling back, and between further fallbacks, defaults to 5s
+	Duration time.Duration `yaml:"duration,omitempty"`
+}
+
+type RetransmitBudgetConfig struct {
+	// bits per second of retransmissions of a video track to a subscriber, NACKed packets beyond it are not
+	// retransmitted. 0 (default) does not limit retransmissions
+	Bitrate int64 `yaml:"bitrate,omitempty"`
+	// retransmit packets of lower layers first, spatial then temporal as given by the codec, when the budget does not
+	// cover all NACKed packets, i.e. base layer packets before enhancement layer packets. By default packets are
+	// retransmitted in the order they are NACKed
+	LayerPriority bool `yaml:"layer_priority,omitempty"`
+}
+
+type CPUAdmissionControlConfig struct {
+	// CPU load, 0 to 1, above which participants joining are refused, 0 disables admission control. Refused clients
+	// get a 503 response with a Retry-After header
+	CPULoadLimit float64 `yaml:"cpu_load_limit,omitempty"`
+	// time clients are asked to wait before connecting again, defaults to 10s
+	RetryAfter time.Duration `yaml:"retry_after,omitempty"`
+}
+
+type DSCPConfig struct {
+	// 0 to 63, e.g. 46 (EF) for audio and 34 (AF41) for video. Sockets are marked as audio until media is negotiated
+	Audio int `yaml:"audio,omitempty"`
+	Video int `yaml:"video,omitempty"`
+}
+
+type ICEPreferenceConfig struct {
+	// candidate type tried first, one of host_first or srflx_first. Candidates of the type are signalled with a higher
+	// priority than the others and, when the node is the controlling agent, pairs of other types wait longer before
+	// they are accepted. pion's priorities and acceptance waits are used when not set
+	CandidateOrder ICECandidateOrder `yaml:"candidate_order,omitempty"`
+	// network interfaces, in order of preference, whose candidates are signalled with a higher priority than
+	// candidates of the same type on other interfaces, steering clients of multi-homed nodes to an interface
+	Interfaces []string `yaml:"interfaces,omitempty"`
+}
+
+type RoomPacketBufferSizeConfig struct {
+	// room names matched, in path.Match syntax, e.g. "rec-*"
+	RoomNamePattern string `yaml:"room_name_pattern,omitempty"`
+	// packets buffered per kind, 0 keeps the node default
+	Video int `yaml:"video,omitempty"`
+	Audio int `yaml:"audio,omitempty"`
+	RTX   int `yaml:"rtx,omitempty"`
+}
+
+type RoomPublishCodecsConfig struct {
+	// room names matched, in path.Match syntax, e.g. "basic-*"
+	RoomNamePattern string `yaml:"room_name_pattern,omitempty"`
+	// mime types allowed to be published, e.g. audio/opus, video/vp8. RTX and RED follow the codecs they carry
+	Codecs []string `yaml:"codecs,omitempty"`
+}
+
+type RenegotiationLimitConfig struct {
+	// offers answered within the window, 0 does not limit renegotiations.
+	// The latest offer over the limit is answered once the window allows it.
+	MaxOffers int           `yaml:"max_offers,omitempty"`
+	Window    time.Duration `yaml:"window,omitempty"`
+}
+
+type SVCLayerCapConfig struct {
+	// highest layer forwarded, 0 being the lowest, unset does not cap the layer
+	MaxSpatialLayer  *int32 `yaml:"max_spatial_layer,omitempty"`
+	MaxTemporalLayer *int32 `yaml:"max_temporal_layer,omitempty"`
+}
+
+type ConnectionLogLevelConfig struct {
+	// path.Match patterns of the attributes of the connection, a connection matches when every non empty list has a
+	// matching pattern. Regions match the region of the node the participant connected to, SDKs the lowercase name
+	// of the client SDK, e.g. js or swift, and Versions the version of the client SDK, e.g. 2.1.*
+	Regions  []string `yaml:"regions,omitempty"`
+	SDKs     []string `yaml:"sdks,omitempty"`
+	Versions []string `yaml:"versions,omitempty"`
+	// log level of matching connections, e.g. debug
+	Level string `yaml:"level,omitempty"`
+}
+
+type CodecFallbackConfig struct {
+	// fallback codecs, in order of preference, per codec, e.g. video/av1: [video/vp9, video/vp8].
+	// A fallback is used only when the publisher publishes it as a simulcast codec of the track
+	Chains map[string][]string `yaml:"chains,omitempty"`
+	// number of key frame requests from a subscriber within window considered a decode failure, defaults to 5.
+	// Only requests following a forwarded key frame are counted, and not while the subscriber reports loss
+	KeyFrameRequests int `yaml:"key_frame_requests,omitempty"`
+	// defaults to 10s
+	Window time.Duration `yaml:"window,omitempty"`
+}
+
 type CongestionControlProbeConfig struct {
 	BaseInterval  time.Duration `yaml:"base_interval,omitempty"`
 	BackoffFactor float64       `yaml:"backoff_factor,omitempty"`
@@ -180,6 +688,66 @@ type CongestionControlConfig struct {
 	ChannelObserverProbeConfig       CongestionControlChannelObserverConfig `yaml:"channel_observer_probe_config,omitempty"`
 	ChannelObserverNonProbeConfig    CongestionControlChannelObserverConfig `yaml:"channel_observer_non_probe_config,omitempty"`
 	DisableEstimationUnmanagedTracks bool                                   `yaml:"disable_etimation_unmanaged_tracks,omitempty"`
+	// default allocation priority of video tracks by source (camera, screen_share, ...), from 1 (lowest) to 255,
+	// used when the subscriber does not set a priority
+	SourcePriorities map[string]uint8 `yaml:"source_priorities,omitempty"`
+	// run without any bandwidth estimation (neither REMB nor TWCC) and forward at full quality,
+	// for networks with guaranteed bandwidth
+	FixedBitrate bool `yaml:"fixed_bitrate,omitempty"`
+	// target bitrate (bps) of each simulcast spatial layer, low to high, allocated to subscribers instead of the
+	// bitrate measured from the publisher. 0 keeps the measured bitrate of a layer, ignored with fixed_bitrate
+	LayerTargetBitrates []int64 `yaml:"layer_target_bitrates,omitempty"`
+	// minimum time a subscriber stays on a video layer before switching to a higher one, smoothing out flicker
+	// when the estimated bandwidth oscillates. Switches down are not delayed, ignored with fixed_bitrate
+	LayerSwitchMinDwell time.Duration `yaml:"layer_switch_min_dwell,omitempty"`
+	// negotiate transport-cc on subscriber video whatever the estimation, so that subscribers send transport-cc
+	// feedback, e.g. for analytics. It is not used for estimation unless send_side_bandwidth_estimation is set
+	AlwaysTransportCC bool `yaml:"always_transport_cc,omitempty"`
+	// how aggressively bitrate ramps back up once congestion clears, 0 keeps the default ramp, otherwise it has to be
+	// above 1. With padding probes it scales the bandwidth probed for each step, with media probes it is the number
+	// of deficient tracks boosted a layer per probe (rounded)
+	RampUpFactor float64 `yaml:"ramp_up_factor,omitempty"`
+	// without send side bandwidth estimation, do not negotiate abs-send-time on subscriber video. The pacer then
+	// does not stamp send times on packets and subscribers estimate bandwidth for REMB from packet arrival alone
+	DisablePacingFallback bool `yaml:"disable_pacing_fallback,omitempty"`
+	// handling of track priorities requested by subscribers at runtime, "ignore" (default) keeps the priority of the
+	// track source without answering the request, "allow" applies them and "deny" rejects them
+	PriorityChanges PriorityChangePolicy `yaml:"priority_changes,omitempty"`
+	// minimum time between priority changes of a subscribed track, more frequent changes are rejected to
+	// avoid reallocation churn. 0 (default) does not limit changes
+	PriorityChangeMinInterval time.Duration `yaml:"priority_change_min_interval,omitempty"`
+	// layer new subscriptions start at, "highest" (default) starts at the best quality the channel is believed to
+	// carry, "lowest" starts at the lowest layer for a fast start and lets probing move the track up
+	InitialLayer InitialLayerMode `yaml:"initial_layer,omitempty"`
+	// how subscriber video degrades when the channel cannot carry even the lowest layers, "pause" pauses tracks and
+	// notifies subscribers of the paused streams, "lowest" keeps forwarding the lowest layer, "freeze" stops
+	// forwarding like pause without notifying subscribers, which keep showing the last frame. Empty (default) pauses
+	// or keeps the lowest layer as set by allow_pause
+	LowBandwidthPolicy LowBandwidthPolicy `yaml:"low_bandwidth_policy,omitempty"`
+	// time without any RTCP feedback (receiver reports, REMB or transport-cc) from a subscriber that is being sent
+	// media, after which no_rtcp_fallback applies. 0 (default) does not monitor feedback
+	NoRTCPTimeout time.Duration `yaml:"no_rtcp_timeout,omitempty"`
+	// handling of subscribers without RTCP feedback, "conservative" (default) allocates video on
+	// no_rtcp_channel_capacity until feedback arrives, "disconnect" disconnects the participant
+	NoRTCPFallback NoRTCPFallback `yaml:"no_rtcp_fallback,omitempty"`
+	// channel capacity (bps) assumed by the conservative fallback, 0 uses a default of 300 kbps
+	NoRTCPChannelCapacity int64 `yaml:"no_rtcp_channel_capacity,omitempty"`
+	// total bitrate (bps) of video sent to subscribers across the node, to protect a shared uplink. The limit is
+	// shared between subscribers by track priority, throttling lower priority tracks of the node first. Audio is not
+	// counted, 0 (default) does not limit. Needs congestion control to be enabled
+	NodeEgressLimit int64 `yaml:"node_egress_limit,omitempty"`
+}
+
+// IsPauseAllowed returns true if subscriber video can be paused when the channel is congested
+func (c *CongestionControlConfig) IsPauseAllowed() bool {
+	switch c.LowBandwidthPolicy {
+	case LowBandwidthPolicyPause, LowBandwidthPolicyFreeze:
+		return true
+	case LowBandwidthPolicyLowest:
+		return false
+	default:
+		return c.AllowPause
+	}
 }
 
 type AudioConfig struct {
@@ -197,6 +765,9 @@ type AudioConfig struct {
 	ActiveREDEncoding bool `yaml:"active_red_encoding,omitempty"`
 	// enable proxying weakest subscriber loss to publisher in RTCP Receiver Report
 	EnableLossProxying bool `yaml:"enable_loss_proxying,omitempty"`
+	// retransmit audio NACKed by subscribers only for active speakers of the room, i.e. the ones in speaker updates,
+	// NACKs of subscribers for audio of other participants are ignored
+	NACKActiveSpeakerOnly bool `yaml:"nack_active_speaker_only,omitempty"`
 }
 
 type StreamTrackerPacketConfig struct {
@@ -332,6 +903,8 @@ type LimitConfig struct {
 	MaxAttributesSize            uint32 `yaml:"max_attributes_size,omitempty"`
 	MaxRoomNameLength            int    `yaml:"max_room_name_length,omitempty"`
 	MaxParticipantIdentityLength int    `yaml:"max_participant_identity_length,omitempty"`
+	// maximum number of concurrent subscriptions of a participant, audio and video combined, 0 means no limit
+	SubscriptionLimit int32 `yaml:"subscription_limit,omitempty"`
 }
 
 type IngressConfig struct {
@@ -638,6 +1211,12 @@ func NewConfig(confString string, strictMode bool, c *cli.Context, baseFlags []c
 		conf.Limit.MaxRoomNameLength = conf.Room.MaxRoomNameLength
 	}
 
+	// without bandwidth estimation, there is nothing to allocate against
+	if conf.RTC.CongestionControl.FixedBitrate {
+		conf.RTC.CongestionControl.Enabled = false
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+	}
+
 	return &conf, nil
 }
 
@@ -49,6 +49,19 @@ room:
 	require.Error(t, err)
 }
 
+func TestConfig_FixedBitrate(t *testing.T) {
+	const content = `rtc:
+  congestion_control:
+    enabled: true
+    send_side_bandwidth_estimation: true
+    fixed_bitrate: true`
+	conf, err := NewConfig(content, true, nil, nil)
+	require.NoError(t, err)
+	require.True(t, conf.RTC.CongestionControl.FixedBitrate)
+	require.False(t, conf.RTC.CongestionControl.Enabled)
+	require.False(t, conf.RTC.CongestionControl.UseSendSideBWE)
+}
+
 func TestGeneratedFlags(t *testing.T) {
 	generatedFlags, err := GenerateCLIFlags(nil, false)
 	require.NoError(t, err)
@@ -0,0 +1,122 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	defaultAdmissionRetryAfter = 10 * time.Second
+
+	// the refusal reaches the signalling node as a leave request, which has no field for the time to wait. It is
+	// carried as the distance, in milliseconds, of a region with this name, the leave request is not sent to clients
+	admissionRetryAfterRegion = "admission-retry-after"
+)
+
+// AdmissionRejectedError is returned when a participant joining is refused because the node is overloaded
+type AdmissionRejectedError struct {
+	CPULoad float64
+	// time the client should wait before trying to connect again
+	RetryAfter time.Duration
+}
+
+func (e *AdmissionRejectedError) Error() string {
+	return fmt.Sprintf("%s: cpu load %.2f, retry after %s", ErrLimitExceeded, e.CPULoad, e.RetryAfter)
+}
+
+func (e *AdmissionRejectedError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// LeaveRequest returns the leave request the media node answers the signalling node with
+func (e *AdmissionRejectedError) LeaveRequest() *livekit.LeaveRequest {
+	return &livekit.LeaveRequest{
+		Reason: livekit.DisconnectReason_JOIN_FAILURE,
+		Regions: &livekit.RegionSettings{
+			Regions: []*livekit.RegionInfo{
+				{Region: admissionRetryAfterRegion, Distance: e.RetryAfter.Milliseconds()},
+			},
+		},
+	}
+}
+
+// AdmissionRetryAfterSeconds returns the Retry-After of a refusal from the leave request of the media node, in whole
+// seconds rounded up, at least 1
+func AdmissionRetryAfterSeconds(leave *livekit.LeaveRequest) int {
+	retryAfter := defaultAdmissionRetryAfter
+	for _, region := range leave.GetRegions().GetRegions() {
+		if region.GetRegion() == admissionRetryAfterRegion {
+			retryAfter = time.Duration(region.GetDistance()) * time.Millisecond
+		}
+	}
+	return max(int(math.Ceil(retryAfter.Seconds())), 1)
+}
+
+// CPUAdmissionControl refuses participants joining while the CPU load of the node is above a limit,
+// so that an overloaded node does not degrade the sessions it already serves. Peer connections, resumes and
+// migrations of participants that already joined are not refused.
+type CPUAdmissionControl struct {
+	cpuLoadLimit float64
+	retryAfter   time.Duration
+	cpuLoad      func() float64
+}
+
+// NewCPUAdmissionControl creates an admission control sampling the CPU load, 0 to 1, with the given function
+func NewCPUAdmissionControl(cpuLoadLimit float64, retryAfter time.Duration, cpuLoad func() float64) *CPUAdmissionControl {
+	return &CPUAdmissionControl{
+		cpuLoadLimit: cpuLoadLimit,
+		retryAfter:   retryAfter,
+		cpuLoad:      cpuLoad,
+	}
+}
+
+// Admit returns an *AdmissionRejectedError when a participant should not join, nil admission control admits all
+func (a *CPUAdmissionControl) Admit() error {
+	if a == nil {
+		return nil
+	}
+
+	if cpuLoad := a.cpuLoad(); cpuLoad > a.cpuLoadLimit {
+		return &AdmissionRejectedError{
+			CPULoad:    cpuLoad,
+			RetryAfter: a.retryAfter,
+		}
+	}
+	return nil
+}
+
+// AdmissionRetryAfter returns the time clients refused by the admission control are asked to wait
+func AdmissionRetryAfter(conf config.CPUAdmissionControlConfig) time.Duration {
+	if conf.RetryAfter == 0 {
+		return defaultAdmissionRetryAfter
+	}
+	return conf.RetryAfter
+}
+
+func (a *CPUAdmissionControl) validate() error {
+	if a.cpuLoadLimit <= 0 || a.cpuLoadLimit > 1 {
+		return fmt.Errorf("cpu load limit %.2f out of range (0, 1]", a.cpuLoadLimit)
+	}
+	if a.retryAfter < 0 {
+		return fmt.Errorf("invalid admission retry after %s", a.retryAfter)
+	}
+	return nil
+}
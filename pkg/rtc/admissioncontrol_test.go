@@ -0,0 +1,109 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/transport/transportfakes"
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestCPUAdmissionControl(t *testing.T) {
+	var cpuLoad atomic.Float64
+	admissionControl := NewCPUAdmissionControl(0.8, 5*time.Second, cpuLoad.Load)
+
+	cpuLoad.Store(0.5)
+	require.NoError(t, admissionControl.Admit())
+
+	cpuLoad.Store(0.8)
+	require.NoError(t, admissionControl.Admit())
+
+	cpuLoad.Store(0.95)
+	err := admissionControl.Admit()
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	var rejected *AdmissionRejectedError
+	require.True(t, errors.As(err, &rejected))
+	require.Equal(t, 5*time.Second, rejected.RetryAfter)
+	require.Equal(t, 0.95, rejected.CPULoad)
+
+	// admitted again once the load drops
+	cpuLoad.Store(0.3)
+	require.NoError(t, admissionControl.Admit())
+
+	// no admission control admits all
+	var none *CPUAdmissionControl
+	require.NoError(t, none.Admit())
+}
+
+func TestPCTransportAdmissionControl(t *testing.T) {
+	var cpuLoad atomic.Float64
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Handler:             &transportfakes.FakeHandler{},
+		Config: &WebRTCConfig{
+			AdmissionControl: NewCPUAdmissionControl(0.8, 5*time.Second, cpuLoad.Load),
+		},
+	}
+
+	// admission is per participant join, peer connections of admitted participants are not refused
+	cpuLoad.Store(0.9)
+	transport, err := NewPCTransport(params)
+	require.NoError(t, err)
+	transport.Close()
+}
+
+func TestAdmissionRetryAfter(t *testing.T) {
+	require.Equal(t, 10*time.Second, AdmissionRetryAfter(config.CPUAdmissionControlConfig{CPULoadLimit: 0.8}))
+	require.Equal(t, 3*time.Second, AdmissionRetryAfter(config.CPUAdmissionControlConfig{CPULoadLimit: 0.8, RetryAfter: 3 * time.Second}))
+}
+
+func TestAdmissionRetryAfterSeconds(t *testing.T) {
+	for _, tc := range []struct {
+		retryAfter time.Duration
+		seconds    int
+	}{
+		{5 * time.Second, 5},
+		{1500 * time.Millisecond, 2},
+		{300 * time.Millisecond, 1},
+		{0, 1},
+	} {
+		leave := (&AdmissionRejectedError{RetryAfter: tc.retryAfter}).LeaveRequest()
+		require.Equal(t, livekit.DisconnectReason_JOIN_FAILURE, leave.Reason)
+		require.Equal(t, tc.seconds, AdmissionRetryAfterSeconds(leave), tc.retryAfter)
+	}
+
+	// refused by a media node not carrying the time to wait
+	require.Equal(t, 10, AdmissionRetryAfterSeconds(&livekit.LeaveRequest{Reason: livekit.DisconnectReason_JOIN_FAILURE}))
+}
+
+func TestCPUAdmissionControlConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Nil(t, conf.AdmissionControl)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CPUAdmissionControl.CPULoadLimit = 0.8
+	})
+	require.NotNil(t, conf.AdmissionControl)
+	require.Equal(t, 0.8, conf.AdmissionControl.cpuLoadLimit)
+	require.Equal(t, defaultAdmissionRetryAfter, conf.AdmissionControl.retryAfter)
+}
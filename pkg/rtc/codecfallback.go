@@ -0,0 +1,65 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slices"
+)
+
+// codecFallback is the state of a subscriber that failed to decode one or more codecs of a track
+type codecFallback struct {
+	// lower case mime types the subscriber failed to decode
+	failed []string
+	// lower case mime type to subscribe with
+	fallback string
+}
+
+// selectFallbackCodec returns the first codec of chain that is available and has not failed, empty if there is none
+func selectFallbackCodec(chain []string, available []webrtc.RTPCodecParameters, failed []string) string {
+	for _, mime := range chain {
+		if slices.Contains(failed, mime) {
+			continue
+		}
+		for _, c := range available {
+			if strings.EqualFold(c.MimeType, mime) {
+				return mime
+			}
+		}
+	}
+	return ""
+}
+
+// applyCodecFallback removes failed codecs and moves the fallback codec to the front,
+// codecs are returned unchanged if none of them would remain
+func applyCodecFallback(codecs []webrtc.RTPCodecParameters, fallback *codecFallback) []webrtc.RTPCodecParameters {
+	filtered := make([]webrtc.RTPCodecParameters, 0, len(codecs))
+	for _, c := range codecs {
+		mime := strings.ToLower(c.MimeType)
+		switch {
+		case slices.Contains(fallback.failed, mime):
+		case mime == fallback.fallback:
+			filtered = append([]webrtc.RTPCodecParameters{c}, filtered...)
+		default:
+			filtered = append(filtered, c)
+		}
+	}
+	if len(filtered) == 0 {
+		return codecs
+	}
+	return filtered
+}
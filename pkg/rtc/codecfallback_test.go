@@ -0,0 +1,144 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+func codecParameters(mimes ...string) []webrtc.RTPCodecParameters {
+	codecs := make([]webrtc.RTPCodecParameters, 0, len(mimes))
+	for i, mime := range mimes {
+		codecs = append(codecs, webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: mime, ClockRate: 90000},
+			PayloadType:        webrtc.PayloadType(96 + i),
+		})
+	}
+	return codecs
+}
+
+func codecMimes(codecs []webrtc.RTPCodecParameters) []string {
+	mimes := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		mimes = append(mimes, strings.ToLower(c.MimeType))
+	}
+	return mimes
+}
+
+func TestSelectFallbackCodec(t *testing.T) {
+	chain := []string{"video/vp9", "video/vp8"}
+	available := codecParameters(webrtc.MimeTypeAV1, webrtc.MimeTypeVP9, webrtc.MimeTypeVP8)
+
+	require.Equal(t, "video/vp9", selectFallbackCodec(chain, available, []string{"video/av1"}))
+	require.Equal(t, "video/vp8", selectFallbackCodec(chain, available, []string{"video/av1", "video/vp9"}))
+	require.Equal(t, "video/vp8", selectFallbackCodec(chain, codecParameters(webrtc.MimeTypeAV1, webrtc.MimeTypeVP8), []string{"video/av1"}))
+	require.Empty(t, selectFallbackCodec(chain, codecParameters(webrtc.MimeTypeAV1), []string{"video/av1"}))
+	require.Empty(t, selectFallbackCodec(nil, available, []string{"video/av1"}))
+}
+
+func TestApplyCodecFallback(t *testing.T) {
+	codecs := codecParameters(webrtc.MimeTypeAV1, webrtc.MimeTypeVP8, webrtc.MimeTypeVP9)
+
+	require.Equal(t, []string{"video/vp9", "video/vp8"}, codecMimes(applyCodecFallback(codecs, &codecFallback{
+		failed:   []string{"video/av1"},
+		fallback: "video/vp9",
+	})))
+
+	// nothing left to subscribe with, unchanged
+	require.Equal(t, codecs, applyCodecFallback(codecs, &codecFallback{
+		failed:   []string{"video/av1", "video/vp8", "video/vp9"},
+		fallback: "video/h264",
+	}))
+}
+
+func TestMediaTrackReceiverDecodeFailure(t *testing.T) {
+	const subscriberID = livekit.ParticipantID("PA_sub")
+
+	newReceiver := func(codecs []webrtc.RTPCodecParameters) *MediaTrackReceiver {
+		r := NewMediaTrackReceiver(MediaTrackReceiverParams{
+			ReceiverConfig: ReceiverConfig{
+				CodecFallbacks: map[string][]string{
+					"video/av1": {"video/vp9", "video/vp8"},
+					"video/vp9": {"video/vp8"},
+				},
+			},
+			Logger: logger.GetLogger(),
+		}, &livekit.TrackInfo{Sid: "TR_video", Type: livekit.TrackType_VIDEO})
+		r.SetPotentialCodecs(codecs, nil)
+		return r
+	}
+
+	t.Run("falls back along the chain", func(t *testing.T) {
+		r := newReceiver(codecParameters(webrtc.MimeTypeAV1, webrtc.MimeTypeVP8))
+
+		r.handleDecodeFailure(subscriberID, webrtc.MimeTypeAV1)
+		require.Equal(t, &codecFallback{failed: []string{"video/av1"}, fallback: "video/vp8"}, r.codecFallbacks[subscriberID])
+
+		// no further fallback from vp8, stays
+		r.handleDecodeFailure(subscriberID, webrtc.MimeTypeVP8)
+		require.Equal(t, &codecFallback{failed: []string{"video/av1"}, fallback: "video/vp8"}, r.codecFallbacks[subscriberID])
+	})
+
+	t.Run("removed with the subscription", func(t *testing.T) {
+		r := newReceiver(codecParameters(webrtc.MimeTypeAV1, webrtc.MimeTypeVP8))
+
+		r.handleDecodeFailure(subscriberID, webrtc.MimeTypeAV1)
+		require.NotNil(t, r.codecFallbacks[subscriberID])
+
+		// resubscribing with the fallback
+		r.RemoveSubscriber(subscriberID, true)
+		require.NotNil(t, r.codecFallbacks[subscriberID])
+
+		r.RemoveSubscriber(subscriberID, false)
+		require.Nil(t, r.codecFallbacks[subscriberID])
+
+		r.handleDecodeFailure(subscriberID, webrtc.MimeTypeAV1)
+		r.Close(false)
+		require.Empty(t, r.codecFallbacks)
+	})
+
+	t.Run("fallback not published", func(t *testing.T) {
+		r := newReceiver(codecParameters(webrtc.MimeTypeAV1))
+
+		r.handleDecodeFailure(subscriberID, webrtc.MimeTypeAV1)
+		require.Nil(t, r.codecFallbacks[subscriberID])
+	})
+}
+
+func TestCodecFallbackConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Nil(t, conf.Receiver.CodecFallbacks)
+	require.Zero(t, conf.Receiver.DecodeFailure.KeyFrameRequests)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CodecFallback.Chains = map[string][]string{
+			"video/AV1": {"video/VP9", "video/VP8"},
+		}
+	})
+	require.Equal(t, map[string][]string{"video/av1": {"video/vp9", "video/vp8"}}, conf.Receiver.CodecFallbacks)
+	require.Equal(t, sfu.DecodeFailureParams{
+		KeyFrameRequests: defaultDecodeFailureKeyFrameRequests,
+		Window:           defaultDecodeFailureWindow,
+	}, conf.Receiver.DecodeFailure)
+}
@@ -15,18 +15,89 @@
 package rtc
 
 import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/ice/v2"
 	"github.com/pion/sdp/v3"
+	"github.com/pion/transport/v2/packetio"
+	"github.com/pion/transport/v2/stdnet"
 	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	sfuinterceptor "github.com/livekit/livekit-server/pkg/sfu/interceptor"
+	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+	"github.com/livekit/protocol/livekit"
+)
+
+var srtpProtectionProfiles = map[string]dtls.SRTPProtectionProfile{
+	"SRTP_AEAD_AES_256_GCM":       dtls.SRTP_AEAD_AES_256_GCM,
+	"SRTP_AEAD_AES_128_GCM":       dtls.SRTP_AEAD_AES_128_GCM,
+	"SRTP_AES128_CM_HMAC_SHA1_80": dtls.SRTP_AES128_CM_HMAC_SHA1_80,
+}
+
+var multicastDNSModes = map[string]ice.MulticastDNSMode{
+	"disabled":         ice.MulticastDNSModeDisabled,
+	"query_only":       ice.MulticastDNSModeQueryOnly,
+	"query_and_gather": ice.MulticastDNSModeQueryAndGather,
+}
+
+const (
+	// extension ids available in one-byte and two-byte RTP header extensions (RFC 8285)
+	maxOneByteHeaderExtensions = 14
+	maxTwoByteHeaderExtensions = 255
+)
+
+const (
+	// opus bitrate range, RFC 7587
+	minOpusBitrate = 6000
+	maxOpusBitrate = 510000
+)
+
+const (
+	defaultLossFallbackThreshold = 0.1
+	defaultLossFallbackDuration  = 5 * time.Second
+)
+
+const (
+	defaultDecodeFailureKeyFrameRequests = 5
+	defaultDecodeFailureWindow           = 10 * time.Second
+)
+
+const defaultKeyFrameReorderTolerance = 64
+
+type DTLSFingerprintMismatchPolicy int
+
+const (
+	// DTLSFingerprintMismatchPolicyReject fails the connection
+	DTLSFingerprintMismatchPolicyReject DTLSFingerprintMismatchPolicy = iota
+	// DTLSFingerprintMismatchPolicyRenegotiate asks the client for a full reconnect, negotiating fresh fingerprints
+	DTLSFingerprintMismatchPolicyRenegotiate
 )
 
+type ICERestartPolicy int
+
 const (
-	frameMarking        = "urn:ietf:params:rtp-hdrext:framemarking"
-	repairedRTPStreamID = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+	// ICERestartPolicyOnNetworkChange restarts ICE when a client resumes, e.g. after it switched networks
+	ICERestartPolicyOnNetworkChange ICERestartPolicy = iota
+	// ICERestartPolicyOnDisconnect also restarts ICE when the connection is disconnected, before the ICE failed timeout
+	ICERestartPolicyOnDisconnect
+	// ICERestartPolicyNever does not restart ICE from the server, not even when a client resumes
+	ICERestartPolicyNever
 )
 
 type WebRTCConfig struct {
@@ -36,11 +107,137 @@ type WebRTCConfig struct {
 	Receiver      ReceiverConfig
 	Publisher     DirectionConfig
 	Subscriber    DirectionConfig
+
+	ICETransportPolicies map[livekit.ParticipantInfo_Kind]webrtc.ICETransportPolicy
+	// time source of buffers created by the buffer factory, applied by SetBufferFactory, wall clock when nil
+	BufferClock buffer.Clock
+	// time after which buffers of the buffer factory not used by a track are closed, applied by SetBufferFactory
+	BufferIdleTimeout time.Duration
+	// allows negotiating more header extensions than fit in one-byte headers
+	TwoByteHeaderExtensions bool
+	// handling of a remote DTLS certificate that does not match the signalled fingerprint
+	DTLSFingerprintMismatchPolicy DTLSFingerprintMismatchPolicy
+	// when the server restarts ICE on connections it offers
+	ICERestartPolicy ICERestartPolicy
+	// handling of a simulcast stream carrying a rid that was not negotiated for its media section
+	RIDMismatchPolicy RIDMismatchPolicy
+	// maximum time to wait for ICE candidate gathering, 0 waits for pion to complete gathering
+	ICEGatheringTimeout time.Duration
+	// interval between sender reports sent to subscribers, 0 uses the default
+	SenderReportInterval time.Duration
+	// caps the size of datagrams sent to subscribers, 0 means no limit
+	MTU int
+	// packets queued for sending to a subscriber before the oldest are dropped, 0 writes without a queue
+	SubscriberSendQueueSize int
+	// range of SSRCs of streams sent to subscribers, applied by SetBufferFactory, 0, 0 keeps the SSRCs of pion
+	SSRCRangeStart uint32
+	SSRCRangeEnd   uint32
+	// adjusts the priority of local ICE candidates before they are signalled, nil keeps pion's priorities
+	ICECandidatePriority ICECandidatePriorityFunc
+	// refuses participants joining while the node is overloaded, nil admits all
+	AdmissionControl *CPUAdmissionControl
+	// do not negotiate reduced-size RTCP, i.e. offers and answers do not carry rtcp-rsize
+	DisableRTCPReducedSize bool
+	// codecs offered by publishers are only negotiated with a registered codec of the same payload format, not with
+	// any registered codec of the same mime type
+	StrictCodecMatching bool
+	// attribute keys moved to the front of each section of answers in the given order, empty keeps pion's order
+	AnswerAttributeOrder []string
+	// limits offers accepted from a client on each of its peer connections, offers over the limit fail negotiation
+	RenegotiationLimit RenegotiationLimit
+	// connections whose peer connections trace every packet, shared by snapshots and kept on reload
+	PacketTracer *PacketTracer
+	// sets up media of a subscribed track only once the subscriber updates the settings of the track
+	DeferredSubscription bool
+	// codecs allowed to be published by room name pattern, applied by SetRoom
+	RoomPublishCodecs []RoomPublishCodecs
+	// mime types allowed to be published in the room, empty allows all enabled codecs
+	PublishCodecs []string
+	// marks the sockets of each peer connection by the kind of media negotiated on it, nil does not mark
+	DSCP *DSCPMarking
+	// fails DTLS handshakes of each peer connection that cannot use one of the allowed cipher suites, nil allows the
+	// cipher suites of pion
+	DTLSCipherSuites *DTLSCipherSuiteFilter
+}
+
+// RoomPacketBufferSizes overrides the packet buffer sizes of rooms whose name matches the pattern, 0 keeps the default
+type RoomPacketBufferSizes struct {
+	RoomNamePattern string
+	Video           int
+	Audio           int
+	RTX             int
+}
+
+// RoomPublishCodecs restricts the codecs published in rooms whose name matches the pattern to the listed mime types
+type RoomPublishCodecs struct {
+	RoomNamePattern string
+	Codecs          []string
 }
 
 type ReceiverConfig struct {
-	PacketBufferSizeVideo int
-	PacketBufferSizeAudio int
+	PacketBufferSizeVideo       int
+	PacketBufferSizeAudio       int
+	PacketBufferSizeRTX         int
+	PacketBufferMaxAge          time.Duration
+	DDReorderTolerance          int
+	ReceiverReportIntervalVideo time.Duration
+	ReceiverReportIntervalAudio time.Duration
+	ReceiverReportJitterVideo   time.Duration
+	ReceiverReportJitterAudio   time.Duration
+	MaxTimestampJump            time.Duration
+	MaxSimulcastLayers          int
+	MaxLayerWidth               uint32
+	MaxLayerHeight              uint32
+	KeyFrameRequestMethods      map[string]config.KeyFrameRequestMethod
+	KeyFrameRequestLimiter      *buffer.KeyFrameRequestLimiter
+	AudioRedDistance            int
+	MaxAudioBitrate             int
+	UnknownRTCPPolicy           buffer.UnknownRTCPPolicy
+	SSRCCollisionPolicy         buffer.SSRCCollisionPolicy
+	MalformedRTPPolicy          buffer.MalformedRTPPolicy
+	RTXAssociationPolicy        buffer.RTXAssociationPolicy
+	PassthroughCodecs           []string
+	ReorderedFrameCodecs        []string
+	SyncOffsets                 map[livekit.TrackSource]time.Duration
+	// frame rate cap of forwarded video by track source
+	MaxFps         map[livekit.TrackSource]uint32
+	MaxRetransmits int
+	// wait for a natural key frame instead of requesting one when a subscriber starts a video track
+	DisableKeyFrameRequestOnSubscribe bool
+	LossFallback                      sfu.LossFallbackParams
+	ForwardUnknownHeaderExtensions    bool
+	// ascending fractions of packets lost reported by a subscriber whose crossing is notified
+	LossThresholds []float64
+	// strip the CSRC list of the publisher from forwarded packets
+	StripCSRC bool
+	// bitrate available to retransmissions of video to a subscriber
+	RetransmitBudget sfu.RetransmitBudgetParams
+	// fallback codecs, in order of preference, keyed by lower case mime type
+	CodecFallbacks map[string][]string
+	DecodeFailure  sfu.DecodeFailureParams
+	// target bitrate of each spatial layer used for allocation, 0 keeps the measured bitrate
+	LayerTargetBitrates []int64
+	// minimum time on a video layer before switching to a higher one
+	LayerSwitchMinDwell time.Duration
+	// send RTCP XR statistics summaries with receiver reports to publishers
+	RTCPExtendedReports bool
+	// what subscribers are sent when the publisher mutes a track
+	PubMutePolicy sfu.PubMutePolicy
+	// how padding only packets of publishers are forwarded
+	PaddingPolicy sfu.PaddingPolicy
+	// how keepalive packets of publishers, without payload and padding, are forwarded
+	KeepalivePolicy sfu.KeepalivePolicy
+	// packets held until the key frame packet carrying the dependency descriptor structure arrives, 0 drops them
+	KeyFrameReorderTolerance int
+	// packet buffer sizes by room name, applied by SetRoom, the first match applies
+	RoomPacketBufferSizes []RoomPacketBufferSizes
+	// time without packets after which a track is declared dead, by kind, 0 never declares tracks dead
+	DeadTrackTimeoutVideo time.Duration
+	DeadTrackTimeoutAudio time.Duration
+	// spatial layer forwarded regardless of bandwidth, by subscriber identity
+	PinnedSpatialLayers map[livekit.ParticipantIdentity]int32
+	// highest layers forwarded of SVC video by lower case mime type, an invalid layer is not capped
+	SVCLayerCaps map[string]buffer.VideoLayer
 }
 
 type RTPHeaderExtensionConfig struct {
@@ -48,15 +245,145 @@ type RTPHeaderExtensionConfig struct {
 	Video []string
 }
 
+// Without returns a copy of the config with the given extension URIs removed
+func (r RTPHeaderExtensionConfig) Without(uris []string) RTPHeaderExtensionConfig {
+	notBlocked := func(exts []string) []string {
+		filtered := make([]string, 0, len(exts))
+		for _, ext := range exts {
+			if !slices.Contains(uris, ext) {
+				filtered = append(filtered, ext)
+			}
+		}
+		return filtered
+	}
+	return RTPHeaderExtensionConfig{
+		Audio: notBlocked(r.Audio),
+		Video: notBlocked(r.Video),
+	}
+}
+
+// NumUnique returns the number of distinct extension URIs, audio and video share the id space
+func (r RTPHeaderExtensionConfig) NumUnique() int {
+	uris := make(map[string]struct{}, len(r.Audio)+len(r.Video))
+	for _, ext := range append(slices.Clone(r.Audio), r.Video...) {
+		uris[ext] = struct{}{}
+	}
+	return len(uris)
+}
+
 type RTCPFeedbackConfig struct {
 	Audio []webrtc.RTCPFeedback
 	Video []webrtc.RTCPFeedback
+	// feedback removed for specific codecs, keyed by lower case mime type
+	Disabled map[string][]webrtc.RTCPFeedback
+}
+
+// ForCodec returns the feedback to register for the given codec, feedback of its kind minus the disabled ones
+func (r RTCPFeedbackConfig) ForCodec(mimeType string) []webrtc.RTCPFeedback {
+	feedback := r.Video
+	if strings.HasPrefix(strings.ToLower(mimeType), "audio/") {
+		feedback = r.Audio
+	}
+
+	disabled := r.Disabled[strings.ToLower(mimeType)]
+	if len(disabled) == 0 {
+		return slices.Clone(feedback)
+	}
+	return slices.DeleteFunc(slices.Clone(feedback), func(fb webrtc.RTCPFeedback) bool {
+		return slices.Contains(disabled, fb)
+	})
 }
 
 type DirectionConfig struct {
 	RTPHeaderExtension RTPHeaderExtensionConfig
 	RTCPFeedback       RTCPFeedbackConfig
 	StrictACKs         bool
+	// with StrictACKs, data channels count as open from dialing until this long without an acknowledgement,
+	// 0 waits for the acknowledgement
+	ACKGrace time.Duration
+	// number of redundant encodings signalled for audio RED, 0 uses the default
+	RedDistance int
+	// opus sample rates registered, in order of preference, empty registers opus without signalling a rate
+	OpusSampleRates []uint32
+	// packet loss concealment asked for in opus format parameters of answers to publishers, empty keeps the in-band
+	// FEC offered by the publisher
+	AudioConcealment config.AudioConcealmentMode
+	// number of video mime types offered for each subscribed track, in order of preference, RTX not counted, in
+	// addition to the ones the track is published in, 0 offers all enabled codecs
+	MaxVideoCodecs int
+}
+
+// Merge layers override on top of d and returns the result, neither input is modified.
+//
+// Precedence rules:
+//   - RTP header extensions are the union of both, base extensions first, in order, followed by
+//     extensions only present in override. Duplicates are dropped.
+//   - RTCP feedback is replaced per kind, when override has a non-empty list for a kind, it is used
+//     as is, otherwise the base list is kept. Disabled feedback follows the same rule.
+//   - StrictACKs and ACKGrace are always taken from override.
+//   - RedDistance is taken from override when set, otherwise the base value is kept.
+//   - OpusSampleRates are taken from override when set, otherwise the base value is kept.
+//   - AudioConcealment is taken from override when set, otherwise the base value is kept.
+//   - MaxVideoCodecs is taken from override when set, otherwise the base value is kept.
+func (d DirectionConfig) Merge(override DirectionConfig) DirectionConfig {
+	union := func(base []string, override []string) []string {
+		merged := make([]string, 0, len(base)+len(override))
+		for _, ext := range append(slices.Clone(base), override...) {
+			if !slices.Contains(merged, ext) {
+				merged = append(merged, ext)
+			}
+		}
+		return merged
+	}
+	feedback := func(base []webrtc.RTCPFeedback, override []webrtc.RTCPFeedback) []webrtc.RTCPFeedback {
+		if len(override) != 0 {
+			return slices.Clone(override)
+		}
+		return slices.Clone(base)
+	}
+
+	disabled := maps.Clone(d.RTCPFeedback.Disabled)
+	if len(override.RTCPFeedback.Disabled) != 0 {
+		disabled = maps.Clone(override.RTCPFeedback.Disabled)
+	}
+
+	redDistance := d.RedDistance
+	if override.RedDistance != 0 {
+		redDistance = override.RedDistance
+	}
+
+	opusSampleRates := slices.Clone(d.OpusSampleRates)
+	if len(override.OpusSampleRates) != 0 {
+		opusSampleRates = slices.Clone(override.OpusSampleRates)
+	}
+
+	audioConcealment := d.AudioConcealment
+	if override.AudioConcealment != "" {
+		audioConcealment = override.AudioConcealment
+	}
+
+	maxVideoCodecs := d.MaxVideoCodecs
+	if override.MaxVideoCodecs != 0 {
+		maxVideoCodecs = override.MaxVideoCodecs
+	}
+
+	return DirectionConfig{
+		RTPHeaderExtension: RTPHeaderExtensionConfig{
+			Audio: union(d.RTPHeaderExtension.Audio, override.RTPHeaderExtension.Audio),
+			Video: union(d.RTPHeaderExtension.Video, override.RTPHeaderExtension.Video),
+		},
+		RTCPFeedback: RTCPFeedbackConfig{
+			Audio:    feedback(d.RTCPFeedback.Audio, override.RTCPFeedback.Audio),
+			Video:    feedback(d.RTCPFeedback.Video, override.RTCPFeedback.Video),
+			Disabled: disabled,
+		},
+		StrictACKs:       override.StrictACKs,
+		ACKGrace:         override.ACKGrace,
+		RedDistance:      redDistance,
+		OpusSampleRates:  opusSampleRates,
+		AudioConcealment: audioConcealment,
+		MaxVideoCodecs:   maxVideoCodecs,
+	}
 }
 
 func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
@@ -70,6 +397,91 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 	// we don't want to use active TCP on a server, clients should be dialing
 	webRTCConfig.SettingEngine.DisableActiveTCP(true)
 
+	if rtcConf.MDNSMode != "" {
+		mode, ok := multicastDNSModes[strings.ToLower(rtcConf.MDNSMode)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported mDNS mode %q", rtcConf.MDNSMode)
+		}
+		webRTCConfig.SettingEngine.SetICEMulticastDNSMode(mode)
+	}
+
+	iceCandidateOrder := ICECandidateOrderDefault
+	if rtcConf.ICEPreference.CandidateOrder != "" {
+		order, ok := iceCandidateOrders[config.ICECandidateOrder(strings.ToLower(string(rtcConf.ICEPreference.CandidateOrder)))]
+		if !ok {
+			return nil, fmt.Errorf("unsupported ICE candidate order %q", rtcConf.ICEPreference.CandidateOrder)
+		}
+		iceCandidateOrder = order
+
+		waits := iceCandidateOrderAcceptanceMinWaits[order]
+		webRTCConfig.SettingEngine.SetHostAcceptanceMinWait(waits.host)
+		webRTCConfig.SettingEngine.SetSrflxAcceptanceMinWait(waits.srflx)
+		webRTCConfig.SettingEngine.SetPrflxAcceptanceMinWait(waits.prflx)
+		webRTCConfig.SettingEngine.SetRelayAcceptanceMinWait(waits.relay)
+	}
+
+	if len(rtcConf.SRTPProtectionProfiles) != 0 {
+		profiles := make([]dtls.SRTPProtectionProfile, 0, len(rtcConf.SRTPProtectionProfiles))
+		for _, name := range rtcConf.SRTPProtectionProfiles {
+			profile, ok := srtpProtectionProfiles[strings.ToUpper(name)]
+			if !ok {
+				return nil, fmt.Errorf("unsupported SRTP protection profile %q", name)
+			}
+			profiles = append(profiles, profile)
+		}
+		webRTCConfig.SettingEngine.SetSRTPProtectionProfiles(profiles...)
+	}
+
+	dscpEnabled := rtcConf.DSCP.Audio != 0 || rtcConf.DSCP.Video != 0
+	if dscpEnabled {
+		if rtcConf.DSCP.Audio < 0 || rtcConf.DSCP.Audio > maxDSCP {
+			return nil, fmt.Errorf("audio DSCP %d out of range [0, %d]", rtcConf.DSCP.Audio, maxDSCP)
+		}
+		if rtcConf.DSCP.Video < 0 || rtcConf.DSCP.Video > maxDSCP {
+			return nil, fmt.Errorf("video DSCP %d out of range [0, %d]", rtcConf.DSCP.Video, maxDSCP)
+		}
+		// sockets of the UDP mux are created by rtcconfig, only the ones pion listens on can be marked
+		if webRTCConfig.UDPMux != nil {
+			return nil, fmt.Errorf("DSCP marking is not supported with udp_port, use an ICE port range")
+		}
+	}
+
+	var dtlsCipherSuites []dtls.CipherSuiteID
+	if len(rtcConf.DTLSCipherSuites) != 0 {
+		suites := make([]dtls.CipherSuiteID, 0, len(rtcConf.DTLSCipherSuites))
+		for _, name := range rtcConf.DTLSCipherSuites {
+			suite, ok := dtlsCipherSuites[strings.ToUpper(name)]
+			if !ok {
+				return nil, fmt.Errorf("unsupported DTLS cipher suite %q", name)
+			}
+			suites = append(suites, suite)
+		}
+		// handshakes over the UDP and TCP muxes created by rtcconfig cannot be inspected
+		if webRTCConfig.UDPMux != nil {
+			return nil, fmt.Errorf("DTLS cipher suites are not supported with udp_port, use an ICE port range")
+		}
+		if webRTCConfig.TCPMuxListener != nil {
+			return nil, fmt.Errorf("DTLS cipher suites are not supported with tcp_port, set it to 0")
+		}
+		dtlsCipherSuites = suites
+	}
+
+	// sockets pion listens on are wrapped per peer connection when packets sent or received on them are inspected,
+	// wrapping the network of the setting engine
+	if dscpEnabled || len(dtlsCipherSuites) != 0 {
+		n, err := stdnet.NewNet()
+		if err != nil {
+			return nil, err
+		}
+		if dscpEnabled {
+			webRTCConfig.DSCP = newDSCPMarking(n, uint8(rtcConf.DSCP.Audio), uint8(rtcConf.DSCP.Video), setSocketDSCP)
+		}
+		if len(dtlsCipherSuites) != 0 {
+			webRTCConfig.DTLSCipherSuites = newDTLSCipherSuiteFilter(n, dtlsCipherSuites)
+		}
+		webRTCConfig.SettingEngine.SetNet(n)
+	}
+
 	if rtcConf.PacketBufferSize == 0 {
 		rtcConf.PacketBufferSize = 500
 	}
@@ -79,6 +491,25 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 	if rtcConf.PacketBufferSizeAudio == 0 {
 		rtcConf.PacketBufferSizeAudio = rtcConf.PacketBufferSize
 	}
+	if rtcConf.PacketBufferSizeRTX == 0 {
+		rtcConf.PacketBufferSizeRTX = rtcConf.PacketBufferSizeVideo
+	}
+	roomPacketBufferSizes := make([]RoomPacketBufferSizes, 0, len(rtcConf.RoomPacketBufferSizes))
+	for _, sizes := range rtcConf.RoomPacketBufferSizes {
+		roomPacketBufferSizes = append(roomPacketBufferSizes, RoomPacketBufferSizes{
+			RoomNamePattern: sizes.RoomNamePattern,
+			Video:           sizes.Video,
+			Audio:           sizes.Audio,
+			RTX:             sizes.RTX,
+		})
+	}
+	roomPublishCodecs := make([]RoomPublishCodecs, 0, len(rtcConf.RoomPublishCodecs))
+	for _, codecs := range rtcConf.RoomPublishCodecs {
+		roomPublishCodecs = append(roomPublishCodecs, RoomPublishCodecs{
+			RoomNamePattern: codecs.RoomNamePattern,
+			Codecs:          slices.Clone(codecs.Codecs),
+		})
+	}
 
 	// publisher configuration
 	publisherConfig := DirectionConfig{
@@ -94,9 +525,9 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 				sdp.SDESMidURI,
 				sdp.SDESRTPStreamIDURI,
 				sdp.TransportCCURI,
-				frameMarking,
+				sfu.FrameMarkingURI,
 				dd.ExtensionURI,
-				repairedRTPStreamID,
+				sfuinterceptor.SDESRepairRTPStreamIDURI,
 				//act.AbsCaptureTimeURI,
 			},
 		},
@@ -116,6 +547,7 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 	// subscriber configuration
 	subscriberConfig := DirectionConfig{
 		StrictACKs: conf.RTC.StrictACKs,
+		ACKGrace:   conf.RTC.StrictACKsGrace,
 		RTPHeaderExtension: RTPHeaderExtensionConfig{
 			Video: []string{
 				dd.ExtensionURI,
@@ -133,26 +565,1075 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 			},
 		},
 	}
-	if rtcConf.CongestionControl.UseSendSideBWE {
+	switch {
+	case rtcConf.CongestionControl.FixedBitrate:
+		// no bandwidth estimation in either direction
+		publisherConfig.RTPHeaderExtension = publisherConfig.RTPHeaderExtension.Without([]string{sdp.TransportCCURI})
+		publisherConfig.RTCPFeedback.Video = slices.DeleteFunc(slices.Clone(publisherConfig.RTCPFeedback.Video), func(fb webrtc.RTCPFeedback) bool {
+			return fb.Type == webrtc.TypeRTCPFBTransportCC
+		})
+	case rtcConf.CongestionControl.UseSendSideBWE:
 		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.TransportCCURI)
 		subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBTransportCC})
-	} else {
-		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+	default:
+		if !rtcConf.CongestionControl.DisablePacingFallback {
+			// without transport-cc, the pacer stamps abs-send-time for subscribers to estimate bandwidth from
+			subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+		}
+		// the SFU does not send REMB, subscribers send it at a cadence decided by their own estimator,
+		// which is not signalled, so there is no REMB interval to configure here
 		subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB})
 	}
+	if rtcConf.CongestionControl.AlwaysTransportCC && !slices.Contains(subscriberConfig.RTPHeaderExtension.Video, sdp.TransportCCURI) {
+		// feedback is received, but estimation stays with the mode above
+		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.TransportCCURI)
+		subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBTransportCC})
+	}
+	if rtcConf.DisableSubscriberVideoFeedback {
+		// one-way broadcast, subscribers are not expected to send any video feedback
+		subscriberConfig.RTCPFeedback.Video = nil
+	}
+
+	if len(rtcConf.DisabledRTCPFeedback) != 0 {
+		disabledRTCPFeedback := make(map[string][]webrtc.RTCPFeedback, len(rtcConf.DisabledRTCPFeedback))
+		for mime, fbs := range rtcConf.DisabledRTCPFeedback {
+			mime = strings.ToLower(mime)
+			if !strings.HasPrefix(mime, "audio/") && !strings.HasPrefix(mime, "video/") {
+				return nil, fmt.Errorf("invalid mime type %q for disabled RTCP feedback", mime)
+			}
+			for _, fb := range fbs {
+				fbType, parameter, _ := strings.Cut(strings.TrimSpace(fb), " ")
+				if fbType == "" {
+					return nil, fmt.Errorf("empty RTCP feedback disabled for %s", mime)
+				}
+				disabledRTCPFeedback[mime] = append(disabledRTCPFeedback[mime], webrtc.RTCPFeedback{
+					Type:      fbType,
+					Parameter: strings.TrimSpace(parameter),
+				})
+			}
+		}
+		publisherConfig.RTCPFeedback.Disabled = disabledRTCPFeedback
+		subscriberConfig.RTCPFeedback.Disabled = disabledRTCPFeedback
+	}
 
-	return &WebRTCConfig{
+	// abs-send-time is carried in its own extension, so it can be negotiated alongside transport-cc
+	if rtcConf.PublisherAbsSendTime && !slices.Contains(publisherConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI) {
+		publisherConfig.RTPHeaderExtension.Video = append(publisherConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+	}
+
+	if rtcConf.SubscriberRTPStreamID {
+		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.SDESRTPStreamIDURI)
+	}
+
+	publisherConfig.RedDistance = rtcConf.AudioRedDistance
+	subscriberConfig.RedDistance = rtcConf.AudioRedDistance
+
+	publisherConfig.OpusSampleRates = slices.Clone(rtcConf.OpusSampleRates)
+	subscriberConfig.OpusSampleRates = slices.Clone(rtcConf.OpusSampleRates)
+
+	// only offered codecs are capped, publishers may publish any enabled codec
+	subscriberConfig.MaxVideoCodecs = rtcConf.MaxVideoCodecs
+
+	// in-band FEC is added by the encoder of the publisher, forwarded audio carries it or not for every subscriber
+	publisherConfig.AudioConcealment = rtcConf.AudioConcealment
+
+	keyFrameRequestMethods := make(map[string]config.KeyFrameRequestMethod, len(rtcConf.KeyFrameRequestMethods))
+	for mime, method := range rtcConf.KeyFrameRequestMethods {
+		keyFrameRequestMethods[strings.ToLower(mime)] = method
+	}
+
+	var unknownRTCPPolicy buffer.UnknownRTCPPolicy
+	switch rtcConf.UnknownRTCP {
+	case "", config.UnknownRTCPPolicyLog:
+		unknownRTCPPolicy = buffer.UnknownRTCPPolicyLog
+	case config.UnknownRTCPPolicyIgnore:
+		unknownRTCPPolicy = buffer.UnknownRTCPPolicyIgnore
+	case config.UnknownRTCPPolicyCount:
+		unknownRTCPPolicy = buffer.UnknownRTCPPolicyCount
+	default:
+		return nil, fmt.Errorf("unsupported unknown RTCP policy %q", rtcConf.UnknownRTCP)
+	}
+
+	var ssrcCollisionPolicy buffer.SSRCCollisionPolicy
+	switch rtcConf.SSRCCollision {
+	case "", config.SSRCCollisionPolicyRemap:
+		ssrcCollisionPolicy = buffer.SSRCCollisionPolicyRemap
+	case config.SSRCCollisionPolicyReject:
+		ssrcCollisionPolicy = buffer.SSRCCollisionPolicyReject
+	default:
+		return nil, fmt.Errorf("unsupported SSRC collision policy %q", rtcConf.SSRCCollision)
+	}
+
+	var rtxAssociationPolicy buffer.RTXAssociationPolicy
+	switch rtcConf.RTXAssociation {
+	case "", config.RTXAssociationPolicyBestEffort:
+		rtxAssociationPolicy = buffer.RTXAssociationPolicyBestEffort
+	case config.RTXAssociationPolicyStrict:
+		rtxAssociationPolicy = buffer.RTXAssociationPolicyStrict
+	default:
+		return nil, fmt.Errorf("unsupported RTX association policy %q", rtcConf.RTXAssociation)
+	}
+
+	var malformedRTPPolicy buffer.MalformedRTPPolicy
+	switch rtcConf.MalformedRTP {
+	case "", config.MalformedRTPPolicyLog:
+		malformedRTPPolicy = buffer.MalformedRTPPolicyLog
+	case config.MalformedRTPPolicyCount:
+		malformedRTPPolicy = buffer.MalformedRTPPolicyCount
+	default:
+		return nil, fmt.Errorf("unsupported malformed RTP policy %q", rtcConf.MalformedRTP)
+	}
+
+	lossFallback := sfu.LossFallbackParams{
+		Threshold: rtcConf.LossFallback.LossThreshold,
+		Duration:  rtcConf.LossFallback.Duration,
+	}
+	switch rtcConf.LossFallback.Action {
+	case "", config.LossFallbackActionNone:
+		lossFallback.Action = sfu.LossFallbackActionNone
+	case config.LossFallbackActionKeyFrame:
+		lossFallback.Action = sfu.LossFallbackActionKeyFrame
+	case config.LossFallbackActionLowerLayer:
+		lossFallback.Action = sfu.LossFallbackActionLowerLayer
+	default:
+		return nil, fmt.Errorf("unsupported loss fallback action %q", rtcConf.LossFallback.Action)
+	}
+	if lossFallback.Threshold == 0 {
+		lossFallback.Threshold = defaultLossFallbackThreshold
+	}
+	if lossFallback.Duration == 0 {
+		lossFallback.Duration = defaultLossFallbackDuration
+	}
+
+	var codecFallbacks map[string][]string
+	var decodeFailure sfu.DecodeFailureParams
+	if len(rtcConf.CodecFallback.Chains) != 0 {
+		codecFallbacks = make(map[string][]string, len(rtcConf.CodecFallback.Chains))
+		for mime, chain := range rtcConf.CodecFallback.Chains {
+			fallbacks := make([]string, 0, len(chain))
+			for _, fallback := range chain {
+				fallbacks = append(fallbacks, strings.ToLower(fallback))
+			}
+			codecFallbacks[strings.ToLower(mime)] = fallbacks
+		}
+		decodeFailure = sfu.DecodeFailureParams{
+			KeyFrameRequests: rtcConf.CodecFallback.KeyFrameRequests,
+			Window:           rtcConf.CodecFallback.Window,
+		}
+		if decodeFailure.KeyFrameRequests == 0 {
+			decodeFailure.KeyFrameRequests = defaultDecodeFailureKeyFrameRequests
+		}
+		if decodeFailure.Window == 0 {
+			decodeFailure.Window = defaultDecodeFailureWindow
+		}
+	}
+
+	renegotiationLimit := RenegotiationLimit{
+		MaxOffers: rtcConf.RenegotiationLimit.MaxOffers,
+		Window:    rtcConf.RenegotiationLimit.Window,
+	}
+
+	passthroughCodecs := make([]string, 0, len(rtcConf.PassthroughCodecs))
+	for _, mime := range rtcConf.PassthroughCodecs {
+		mime = strings.ToLower(mime)
+		if !strings.HasPrefix(mime, "audio/") && !strings.HasPrefix(mime, "video/") {
+			return nil, fmt.Errorf("invalid passthrough codec %q, expected a mime type", mime)
+		}
+		passthroughCodecs = append(passthroughCodecs, mime)
+	}
+
+	reorderedFrameCodecs := make([]string, 0, len(rtcConf.ReorderedFrameCodecs))
+	for _, mime := range rtcConf.ReorderedFrameCodecs {
+		mime = strings.ToLower(mime)
+		if !strings.HasPrefix(mime, "video/") {
+			return nil, fmt.Errorf("invalid reordered frame codec %q, expected a video mime type", mime)
+		}
+		reorderedFrameCodecs = append(reorderedFrameCodecs, mime)
+	}
+
+	var layerTargetBitrates []int64
+	if !rtcConf.CongestionControl.FixedBitrate {
+		// without bandwidth estimation every subscriber is forwarded at full quality, targets would not be used
+		layerTargetBitrates = slices.Clone(rtcConf.CongestionControl.LayerTargetBitrates)
+	}
+
+	var layerSwitchMinDwell time.Duration
+	if !rtcConf.CongestionControl.FixedBitrate {
+		// layers are only switched on changes of the estimated bandwidth
+		layerSwitchMinDwell = rtcConf.CongestionControl.LayerSwitchMinDwell
+	}
+
+	var dtlsFingerprintMismatchPolicy DTLSFingerprintMismatchPolicy
+	switch rtcConf.DTLSFingerprintMismatch {
+	case "", config.DTLSFingerprintMismatchReject:
+		dtlsFingerprintMismatchPolicy = DTLSFingerprintMismatchPolicyReject
+	case config.DTLSFingerprintMismatchRenegotiate:
+		dtlsFingerprintMismatchPolicy = DTLSFingerprintMismatchPolicyRenegotiate
+	default:
+		return nil, fmt.Errorf("unsupported DTLS fingerprint mismatch policy %q", rtcConf.DTLSFingerprintMismatch)
+	}
+
+	var iceRestartPolicy ICERestartPolicy
+	switch rtcConf.ICERestartPolicy {
+	case "", config.ICERestartPolicyOnNetworkChange:
+		iceRestartPolicy = ICERestartPolicyOnNetworkChange
+	case config.ICERestartPolicyOnDisconnect:
+		iceRestartPolicy = ICERestartPolicyOnDisconnect
+	case config.ICERestartPolicyNever:
+		iceRestartPolicy = ICERestartPolicyNever
+	default:
+		return nil, fmt.Errorf("unsupported ICE restart policy %q", rtcConf.ICERestartPolicy)
+	}
+
+	var ridMismatchPolicy RIDMismatchPolicy
+	switch rtcConf.RIDMismatchPolicy {
+	case "", config.RIDMismatchPolicyDrop:
+		ridMismatchPolicy = RIDMismatchPolicyDrop
+	case config.RIDMismatchPolicyRetry:
+		ridMismatchPolicy = RIDMismatchPolicyRetry
+	case config.RIDMismatchPolicyAssign:
+		ridMismatchPolicy = RIDMismatchPolicyAssign
+	default:
+		return nil, fmt.Errorf("unsupported RID mismatch policy %q", rtcConf.RIDMismatchPolicy)
+	}
+
+	var pubMutePolicy sfu.PubMutePolicy
+	switch rtcConf.PubMutePolicy {
+	case "", config.PubMutePolicySilence:
+		pubMutePolicy = sfu.PubMutePolicySilence
+	case config.PubMutePolicyStop:
+		pubMutePolicy = sfu.PubMutePolicyStop
+	case config.PubMutePolicyMarker:
+		pubMutePolicy = sfu.PubMutePolicyMarker
+	default:
+		return nil, fmt.Errorf("unsupported pub mute policy %q", rtcConf.PubMutePolicy)
+	}
+
+	var strictCodecMatching bool
+	switch rtcConf.CodecMatching {
+	case "", config.CodecMatchingLenient:
+	case config.CodecMatchingStrict:
+		strictCodecMatching = true
+	default:
+		return nil, fmt.Errorf("unsupported codec matching %q", rtcConf.CodecMatching)
+	}
+
+	var deferredSubscription bool
+	switch rtcConf.Subscription {
+	case "", config.SubscriptionModeImmediate:
+	case config.SubscriptionModeDeferred:
+		deferredSubscription = true
+	default:
+		return nil, fmt.Errorf("unsupported subscription mode %q", rtcConf.Subscription)
+	}
+
+	var paddingPolicy sfu.PaddingPolicy
+	switch rtcConf.PaddingPolicy {
+	case "", config.PaddingPolicyStripContiguous:
+		paddingPolicy = sfu.PaddingPolicyStripContiguous
+	case config.PaddingPolicyStrip:
+		paddingPolicy = sfu.PaddingPolicyStrip
+	case config.PaddingPolicyForward:
+		if rtcConf.CongestionControl.UseSendSideBWE && rtcConf.CongestionControl.ProbeMode == config.CongestionControlProbeModePadding {
+			return nil, fmt.Errorf("padding policy %q cannot be used with padding probes under send side bandwidth estimation", rtcConf.PaddingPolicy)
+		}
+		paddingPolicy = sfu.PaddingPolicyForward
+	default:
+		return nil, fmt.Errorf("unsupported padding policy %q", rtcConf.PaddingPolicy)
+	}
+
+	var keepalivePolicy sfu.KeepalivePolicy
+	switch rtcConf.KeepalivePolicy {
+	case "", config.KeepalivePolicyAsPadding:
+		keepalivePolicy = sfu.KeepalivePolicyAsPadding
+	case config.KeepalivePolicyDrop:
+		keepalivePolicy = sfu.KeepalivePolicyDrop
+	case config.KeepalivePolicyForward:
+		keepalivePolicy = sfu.KeepalivePolicyForward
+	default:
+		return nil, fmt.Errorf("unsupported keepalive policy %q", rtcConf.KeepalivePolicy)
+	}
+
+	var keyFrameReorderTolerance int
+	switch rtcConf.KeyFrameReorderPolicy {
+	case "", config.KeyFrameReorderPolicyDrop:
+	case config.KeyFrameReorderPolicyDefer:
+		keyFrameReorderTolerance = rtcConf.KeyFrameReorderTolerance
+		if keyFrameReorderTolerance == 0 {
+			keyFrameReorderTolerance = defaultKeyFrameReorderTolerance
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key frame reorder policy %q", rtcConf.KeyFrameReorderPolicy)
+	}
+
+	lossThresholds := slices.Clone(rtcConf.LossThresholds)
+	slices.Sort(lossThresholds)
+
+	maxFps := make(map[livekit.TrackSource]uint32, len(rtcConf.MaxFps))
+	for name, fps := range rtcConf.MaxFps {
+		source, ok := livekit.TrackSource_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown track source %q in max fps", name)
+		}
+		maxFps[livekit.TrackSource(source)] = fps
+	}
+
+	syncOffsets := make(map[livekit.TrackSource]time.Duration, len(rtcConf.SyncOffsets))
+	for name, offset := range rtcConf.SyncOffsets {
+		source, ok := livekit.TrackSource_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown track source %q in sync offsets", name)
+		}
+		syncOffsets[livekit.TrackSource(source)] = offset
+	}
+
+	pinnedSpatialLayers := make(map[livekit.ParticipantIdentity]int32, len(rtcConf.PinnedSpatialLayers))
+	for identity, layer := range rtcConf.PinnedSpatialLayers {
+		pinnedSpatialLayers[livekit.ParticipantIdentity(identity)] = layer
+	}
+
+	svcLayerCaps := make(map[string]buffer.VideoLayer, len(rtcConf.SVCLayerCaps))
+	for mime, capConf := range rtcConf.SVCLayerCaps {
+		layerCap := buffer.InvalidLayer
+		if capConf.MaxSpatialLayer != nil {
+			layerCap.Spatial = *capConf.MaxSpatialLayer
+		}
+		if capConf.MaxTemporalLayer != nil {
+			layerCap.Temporal = *capConf.MaxTemporalLayer
+		}
+		svcLayerCaps[strings.ToLower(mime)] = layerCap
+	}
+
+	retransmitBudget := sfu.RetransmitBudgetParams{
+		Bitrate:       rtcConf.RetransmitBudget.Bitrate,
+		LayerPriority: rtcConf.RetransmitBudget.LayerPriority,
+	}
+
+	for i, name := range rtcConf.ICEPreference.Interfaces {
+		if slices.Contains(rtcConf.ICEPreference.Interfaces[:i], name) {
+			return nil, fmt.Errorf("duplicate preferred ICE interface %q", name)
+		}
+	}
+	iceCandidatePriority := NewICECandidatePriority(
+		iceCandidateOrder,
+		rtcConf.ICEPreference.Interfaces,
+		newInterfaceAddresses(listInterfaceAddresses).interfaceOf,
+	)
+
+	var admissionControl *CPUAdmissionControl
+	if rtcConf.CPUAdmissionControl.CPULoadLimit != 0 {
+		admissionControl = NewCPUAdmissionControl(
+			rtcConf.CPUAdmissionControl.CPULoadLimit,
+			AdmissionRetryAfter(rtcConf.CPUAdmissionControl),
+			prometheus.GetCPULoad,
+		)
+	}
+
+	// shared by all copies of the config and kept on reload, so that the limit applies node wide
+	var keyFrameRequestLimiter *buffer.KeyFrameRequestLimiter
+	if rtcConf.MaxOutstandingKeyFrameRequests > 0 {
+		keyFrameRequestLimiter = buffer.NewKeyFrameRequestLimiter(buffer.KeyFrameRequestLimiterParams{
+			MaxOutstanding: rtcConf.MaxOutstandingKeyFrameRequests,
+		})
+	}
+
+	if _, err := streamallocator.SourcePrioritiesFromConfig(rtcConf.CongestionControl.SourcePriorities); err != nil {
+		return nil, err
+	}
+	if err := validateRampUpFactor(rtcConf.CongestionControl.RampUpFactor); err != nil {
+		return nil, err
+	}
+
+	switch rtcConf.CongestionControl.PriorityChanges {
+	case "", config.PriorityChangePolicyIgnore, config.PriorityChangePolicyAllow, config.PriorityChangePolicyDeny:
+	default:
+		return nil, fmt.Errorf("unsupported priority change policy %q", rtcConf.CongestionControl.PriorityChanges)
+	}
+	if rtcConf.CongestionControl.PriorityChangeMinInterval < 0 {
+		return nil, fmt.Errorf("invalid priority change min interval %s", rtcConf.CongestionControl.PriorityChangeMinInterval)
+	}
+
+	switch rtcConf.CongestionControl.InitialLayer {
+	case "", config.InitialLayerHighest, config.InitialLayerLowest:
+	default:
+		return nil, fmt.Errorf("unsupported initial layer %q", rtcConf.CongestionControl.InitialLayer)
+	}
+
+	switch rtcConf.CongestionControl.LowBandwidthPolicy {
+	case "", config.LowBandwidthPolicyPause, config.LowBandwidthPolicyLowest, config.LowBandwidthPolicyFreeze:
+	default:
+		return nil, fmt.Errorf("unsupported low bandwidth policy %q", rtcConf.CongestionControl.LowBandwidthPolicy)
+	}
+
+	switch rtcConf.CongestionControl.NoRTCPFallback {
+	case "", config.NoRTCPFallbackConservative, config.NoRTCPFallbackDisconnect:
+	default:
+		return nil, fmt.Errorf("unsupported no RTCP fallback %q", rtcConf.CongestionControl.NoRTCPFallback)
+	}
+	if rtcConf.CongestionControl.NoRTCPTimeout < 0 {
+		return nil, fmt.Errorf("invalid no RTCP timeout %s", rtcConf.CongestionControl.NoRTCPTimeout)
+	}
+	if rtcConf.CongestionControl.NoRTCPChannelCapacity < 0 {
+		return nil, fmt.Errorf("invalid no RTCP channel capacity %d", rtcConf.CongestionControl.NoRTCPChannelCapacity)
+	}
+	if rtcConf.CongestionControl.NodeEgressLimit < 0 {
+		return nil, fmt.Errorf("invalid node egress limit %d", rtcConf.CongestionControl.NodeEgressLimit)
+	}
+
+	iceTransportPolicies := make(map[livekit.ParticipantInfo_Kind]webrtc.ICETransportPolicy, len(rtcConf.ICETransportPolicies))
+	for kindStr, policyStr := range rtcConf.ICETransportPolicies {
+		kind, ok := livekit.ParticipantInfo_Kind_value[strings.ToUpper(kindStr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown participant kind %q in ICE transport policies", kindStr)
+		}
+		switch policy := strings.ToLower(policyStr); policy {
+		case webrtc.ICETransportPolicyAll.String(), webrtc.ICETransportPolicyRelay.String():
+			iceTransportPolicies[livekit.ParticipantInfo_Kind(kind)] = webrtc.NewICETransportPolicy(policy)
+		default:
+			return nil, fmt.Errorf("unsupported ICE transport policy %q for %s", policyStr, kindStr)
+		}
+	}
+
+	// never negotiate blocked extensions, in either direction, even if offered by the remote
+	if len(rtcConf.BlockedHeaderExtensions) != 0 {
+		publisherConfig.RTPHeaderExtension = publisherConfig.RTPHeaderExtension.Without(rtcConf.BlockedHeaderExtensions)
+		subscriberConfig.RTPHeaderExtension = subscriberConfig.RTPHeaderExtension.Without(rtcConf.BlockedHeaderExtensions)
+	}
+
+	c := &WebRTCConfig{
 		WebRTCConfig: *webRTCConfig,
 		Receiver: ReceiverConfig{
-			PacketBufferSizeVideo: rtcConf.PacketBufferSizeVideo,
-			PacketBufferSizeAudio: rtcConf.PacketBufferSizeAudio,
+			PacketBufferSizeVideo:             rtcConf.PacketBufferSizeVideo,
+			PacketBufferSizeAudio:             rtcConf.PacketBufferSizeAudio,
+			PacketBufferSizeRTX:               rtcConf.PacketBufferSizeRTX,
+			PacketBufferMaxAge:                rtcConf.PacketBufferMaxAge,
+			RoomPacketBufferSizes:             roomPacketBufferSizes,
+			DDReorderTolerance:                rtcConf.DDReorderTolerance,
+			KeyFrameReorderTolerance:          keyFrameReorderTolerance,
+			ReceiverReportIntervalVideo:       rtcConf.ReceiverReportIntervalVideo,
+			ReceiverReportIntervalAudio:       rtcConf.ReceiverReportIntervalAudio,
+			ReceiverReportJitterVideo:         rtcConf.ReceiverReportJitterVideo,
+			ReceiverReportJitterAudio:         rtcConf.ReceiverReportJitterAudio,
+			MaxTimestampJump:                  rtcConf.MaxTimestampJump,
+			DeadTrackTimeoutVideo:             rtcConf.DeadTrackTimeoutVideo,
+			DeadTrackTimeoutAudio:             rtcConf.DeadTrackTimeoutAudio,
+			MaxSimulcastLayers:                rtcConf.MaxSimulcastLayers,
+			MaxLayerWidth:                     rtcConf.MaxLayerWidth,
+			MaxLayerHeight:                    rtcConf.MaxLayerHeight,
+			KeyFrameRequestMethods:            keyFrameRequestMethods,
+			KeyFrameRequestLimiter:            keyFrameRequestLimiter,
+			AudioRedDistance:                  rtcConf.AudioRedDistance,
+			MaxAudioBitrate:                   rtcConf.MaxAudioBitrate,
+			UnknownRTCPPolicy:                 unknownRTCPPolicy,
+			SSRCCollisionPolicy:               ssrcCollisionPolicy,
+			RTXAssociationPolicy:              rtxAssociationPolicy,
+			MalformedRTPPolicy:                malformedRTPPolicy,
+			PassthroughCodecs:                 passthroughCodecs,
+			ReorderedFrameCodecs:              reorderedFrameCodecs,
+			LayerTargetBitrates:               layerTargetBitrates,
+			LayerSwitchMinDwell:               layerSwitchMinDwell,
+			RTCPExtendedReports:               rtcConf.RTCPExtendedReports,
+			PubMutePolicy:                     pubMutePolicy,
+			PaddingPolicy:                     paddingPolicy,
+			KeepalivePolicy:                   keepalivePolicy,
+			SyncOffsets:                       syncOffsets,
+			MaxFps:                            maxFps,
+			MaxRetransmits:                    rtcConf.MaxRetransmits,
+			DisableKeyFrameRequestOnSubscribe: rtcConf.DisableKeyFrameRequestOnSubscribe,
+			LossFallback:                      lossFallback,
+			LossThresholds:                    lossThresholds,
+			CodecFallbacks:                    codecFallbacks,
+			DecodeFailure:                     decodeFailure,
+			ForwardUnknownHeaderExtensions:    rtcConf.ForwardUnknownHeaderExtensions,
+			StripCSRC:                         rtcConf.StripCSRC,
+			RetransmitBudget:                  retransmitBudget,
+			PinnedSpatialLayers:               pinnedSpatialLayers,
+			SVCLayerCaps:                      svcLayerCaps,
 		},
 		Publisher:  publisherConfig,
 		Subscriber: subscriberConfig,
-	}, nil
+
+		ICETransportPolicies:          iceTransportPolicies,
+		TwoByteHeaderExtensions:       rtcConf.TwoByteHeaderExtensions,
+		DTLSFingerprintMismatchPolicy: dtlsFingerprintMismatchPolicy,
+		ICERestartPolicy:              iceRestartPolicy,
+		RIDMismatchPolicy:             ridMismatchPolicy,
+		ICEGatheringTimeout:           rtcConf.ICEGatheringTimeout,
+		SenderReportInterval:          rtcConf.SenderReportInterval,
+		MTU:                           rtcConf.MTU,
+		SubscriberSendQueueSize:       rtcConf.SubscriberSendQueueSize,
+		SSRCRangeStart:                rtcConf.SSRCRangeStart,
+		SSRCRangeEnd:                  rtcConf.SSRCRangeEnd,
+		ICECandidatePriority:          iceCandidatePriority,
+		AdmissionControl:              admissionControl,
+		DisableRTCPReducedSize:        rtcConf.DisableRTCPReducedSize,
+		StrictCodecMatching:           strictCodecMatching,
+		AnswerAttributeOrder:          slices.Clone(rtcConf.AnswerAttributeOrder),
+		RenegotiationLimit:            renegotiationLimit,
+		PacketTracer:                  NewPacketTracer(rtcConf.PacketTraceConnections),
+		DeferredSubscription:          deferredSubscription,
+		RoomPublishCodecs:             roomPublishCodecs,
+		BufferIdleTimeout:             rtcConf.BufferIdleTimeout,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
+// BufferProvider is what the SettingEngine needs to create RTP/RTCP buffers for incoming streams
+type BufferProvider interface {
+	GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser
+}
+
+var _ BufferProvider = (*buffer.Factory)(nil)
+
 func (c *WebRTCConfig) SetBufferFactory(factory *buffer.Factory) {
+	c.SetBufferFactoryWithProvider(factory, factory)
+}
+
+// SetBufferFactoryWithProvider sets the buffer factory used by transports and tracks, while the SettingEngine
+// creates buffers through the provider, e.g. a mock delegating to the factory in tests
+func (c *WebRTCConfig) SetBufferFactoryWithProvider(factory *buffer.Factory, provider BufferProvider) {
+	if metrics := prometheus.GetBufferFactoryMetrics(); metrics != nil {
+		factory.SetObserver(metrics)
+	}
+	if c.BufferClock != nil {
+		factory.SetClock(c.BufferClock)
+	}
+	if c.BufferIdleTimeout > 0 {
+		factory.SetIdleTimeout(c.BufferIdleTimeout)
+	}
+	if c.SSRCRangeEnd != 0 {
+		factory.SetSSRCRange(c.SSRCRangeStart, c.SSRCRangeEnd)
+	}
 	c.BufferFactory = factory
-	c.SettingEngine.BufferFactory = factory.GetOrNew
+	c.SettingEngine.BufferFactory = provider.GetOrNew
+}
+
+// SetRoom applies the packet buffer sizes and publish codecs configured for rooms matching the name, if any, over
+// the node defaults
+func (c *WebRTCConfig) SetRoom(roomName livekit.RoomName) {
+	for _, codecs := range c.RoomPublishCodecs {
+		if matched, _ := path.Match(codecs.RoomNamePattern, string(roomName)); matched {
+			c.PublishCodecs = codecs.Codecs
+			break
+		}
+	}
+
+	for _, sizes := range c.Receiver.RoomPacketBufferSizes {
+		if matched, _ := path.Match(sizes.RoomNamePattern, string(roomName)); !matched {
+			continue
+		}
+
+		if sizes.Video > 0 {
+			c.Receiver.PacketBufferSizeVideo = sizes.Video
+		}
+		if sizes.Audio > 0 {
+			c.Receiver.PacketBufferSizeAudio = sizes.Audio
+		}
+		if sizes.RTX > 0 {
+			c.Receiver.PacketBufferSizeRTX = sizes.RTX
+		}
+		return
+	}
+}
+
+// FilterPublishCodecs returns the codecs allowed to be published in the room, RTX and RED are kept as they carry
+// other codecs
+func (c *WebRTCConfig) FilterPublishCodecs(codecs []*livekit.Codec) []*livekit.Codec {
+	if len(c.PublishCodecs) == 0 {
+		return codecs
+	}
+
+	allowed := make([]*livekit.Codec, 0, len(codecs))
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Mime, videoRTXMimeType) || strings.EqualFold(codec.Mime, redCodecCapability.MimeType) ||
+			slices.ContainsFunc(c.PublishCodecs, func(mime string) bool { return strings.EqualFold(mime, codec.Mime) }) {
+			allowed = append(allowed, codec)
+		}
+	}
+	return allowed
+}
+
+// SetParticipantKind applies the ICE transport policy configured for the participant kind, if any
+func (c *WebRTCConfig) SetParticipantKind(kind livekit.ParticipantInfo_Kind) {
+	if policy, ok := c.ICETransportPolicies[kind]; ok {
+		c.Configuration.ICETransportPolicy = policy
+	} else {
+		c.Configuration.ICETransportPolicy = webrtc.ICETransportPolicyAll
+	}
+}
+
+// Snapshot returns a deep copy of the config. Resources shared across copies of the config, i.e. everything
+// referenced through a pointer, interface or func like the UDP mux, TCP listener, buffer factory and key frame
+// request limiter, are not duplicated.
+func (c *WebRTCConfig) Snapshot() *WebRTCConfig {
+	snapshot := cloneConfigValue(reflect.ValueOf(c).Elem()).Interface().(WebRTCConfig)
+	return &snapshot
+}
+
+// cloneConfigValue deep copies the slices, maps, arrays and structs of a config value, so that settings added to the
+// config later on are copied without having to be listed. Unexported fields, e.g. of the pion setting engine, are
+// copied as they are as they cannot be set.
+func cloneConfigValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(cloneConfigValue(v.Index(i)))
+		}
+		return clone
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), cloneConfigValue(iter.Value()))
+		}
+		return clone
+
+	case reflect.Array:
+		clone := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(cloneConfigValue(v.Index(i)))
+		}
+		return clone
+
+	case reflect.Struct:
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := clone.Field(i); field.CanSet() {
+				field.Set(cloneConfigValue(v.Field(i)))
+			}
+		}
+		return clone
+
+	default:
+		return v
+	}
+}
+
+// Restore replaces the contents of the config with a copy of the snapshot
+func (c *WebRTCConfig) Restore(snapshot *WebRTCConfig) {
+	*c = *snapshot.Snapshot()
+}
+
+// Validate checks the settings of the config. NewWebRTCConfig only parses the settings it converts and validates
+// the config it creates with Validate, so that configs are checked the same way wherever they come from.
+func (c *WebRTCConfig) Validate() error {
+	if c.Receiver.PacketBufferSizeVideo <= 0 || c.Receiver.PacketBufferSizeAudio <= 0 {
+		return fmt.Errorf("invalid packet buffer size, video: %d, audio: %d", c.Receiver.PacketBufferSizeVideo, c.Receiver.PacketBufferSizeAudio)
+	}
+	if c.Receiver.PacketBufferSizeRTX < 0 {
+		return fmt.Errorf("invalid RTX packet buffer size %d", c.Receiver.PacketBufferSizeRTX)
+	}
+	if c.Receiver.PacketBufferMaxAge < 0 {
+		return fmt.Errorf("invalid packet buffer max age %s", c.Receiver.PacketBufferMaxAge)
+	}
+	if c.BufferIdleTimeout < 0 {
+		return fmt.Errorf("invalid buffer idle timeout %s", c.BufferIdleTimeout)
+	}
+	if c.Receiver.KeyFrameReorderTolerance < 0 {
+		return fmt.Errorf("invalid key frame reorder tolerance %d", c.Receiver.KeyFrameReorderTolerance)
+	}
+	if c.Receiver.RetransmitBudget.Bitrate < 0 {
+		return fmt.Errorf("invalid retransmit budget bitrate %d", c.Receiver.RetransmitBudget.Bitrate)
+	}
+	if err := validateRoomPacketBufferSizes(c.Receiver.RoomPacketBufferSizes); err != nil {
+		return err
+	}
+	if err := validateRoomPublishCodecs(c.RoomPublishCodecs); err != nil {
+		return err
+	}
+	if c.Receiver.MaxSimulcastLayers < 0 {
+		return fmt.Errorf("invalid max simulcast layers %d", c.Receiver.MaxSimulcastLayers)
+	}
+	if err := validateReceiverReportJitter(c.Receiver.ReceiverReportIntervalVideo, c.Receiver.ReceiverReportJitterVideo); err != nil {
+		return err
+	}
+	if err := validateReceiverReportJitter(c.Receiver.ReceiverReportIntervalAudio, c.Receiver.ReceiverReportJitterAudio); err != nil {
+		return err
+	}
+
+	if c.Receiver.MaxTimestampJump < 0 {
+		return fmt.Errorf("invalid max timestamp jump %s", c.Receiver.MaxTimestampJump)
+	}
+
+	if !isValidDeadTrackTimeout(c.Receiver.DeadTrackTimeoutVideo) || !isValidDeadTrackTimeout(c.Receiver.DeadTrackTimeoutAudio) {
+		return fmt.Errorf("invalid dead track timeout, video: %s, audio: %s, must be 0 or at least %s", c.Receiver.DeadTrackTimeoutVideo, c.Receiver.DeadTrackTimeoutAudio, sfu.MinDeadTrackTimeout)
+	}
+	for mime, method := range c.Receiver.KeyFrameRequestMethods {
+		switch method {
+		case config.KeyFrameRequestMethodPLI, config.KeyFrameRequestMethodFIR:
+		default:
+			return fmt.Errorf("unsupported key frame request method %q for %s", method, mime)
+		}
+	}
+	for _, redDistance := range []int{c.Receiver.AudioRedDistance, c.Publisher.RedDistance, c.Subscriber.RedDistance} {
+		if redDistance < 0 || redDistance > sfu.MaxRedDistance {
+			return fmt.Errorf("audio red distance %d out of range [1, %d]", redDistance, sfu.MaxRedDistance)
+		}
+	}
+	for _, opusSampleRates := range [][]uint32{c.Publisher.OpusSampleRates, c.Subscriber.OpusSampleRates} {
+		if err := validateOpusSampleRates(opusSampleRates); err != nil {
+			return err
+		}
+	}
+	for _, audioConcealment := range []config.AudioConcealmentMode{c.Publisher.AudioConcealment, c.Subscriber.AudioConcealment} {
+		if err := validateAudioConcealment(audioConcealment); err != nil {
+			return err
+		}
+	}
+	if !isValidMaxAudioBitrate(c.Receiver.MaxAudioBitrate) {
+		return fmt.Errorf("max audio bitrate %d out of range [%d, %d]", c.Receiver.MaxAudioBitrate, minOpusBitrate, maxOpusBitrate)
+	}
+	for kind, policy := range c.ICETransportPolicies {
+		if policy != webrtc.ICETransportPolicyAll && policy != webrtc.ICETransportPolicyRelay {
+			return fmt.Errorf("unsupported ICE transport policy %d for %s", policy, kind)
+		}
+	}
+	for identity, layer := range c.Receiver.PinnedSpatialLayers {
+		if err := validatePinnedSpatialLayer(string(identity), layer); err != nil {
+			return err
+		}
+	}
+	for mime, layerCap := range c.Receiver.SVCLayerCaps {
+		if err := validateSVCLayerCap(mime, layerCap); err != nil {
+			return err
+		}
+	}
+	if c.Receiver.MaxRetransmits < 0 || c.Receiver.MaxRetransmits > sfu.MaxRetransmits {
+		return fmt.Errorf("max retransmits %d out of range [0, %d]", c.Receiver.MaxRetransmits, sfu.MaxRetransmits)
+	}
+	if c.ICEGatheringTimeout < 0 {
+		return fmt.Errorf("invalid ICE gathering timeout %s", c.ICEGatheringTimeout)
+	}
+	if c.SenderReportInterval < 0 {
+		return fmt.Errorf("invalid sender report interval %s", c.SenderReportInterval)
+	}
+	if c.Publisher.MaxVideoCodecs < 0 || c.Subscriber.MaxVideoCodecs < 0 {
+		return fmt.Errorf("invalid max video codecs, publisher: %d, subscriber: %d", c.Publisher.MaxVideoCodecs, c.Subscriber.MaxVideoCodecs)
+	}
+	if c.Publisher.ACKGrace < 0 || c.Subscriber.ACKGrace < 0 {
+		return fmt.Errorf("invalid ACK grace, publisher: %s, subscriber: %s", c.Publisher.ACKGrace, c.Subscriber.ACKGrace)
+	}
+	for i, key := range c.AnswerAttributeOrder {
+		if key == "" {
+			return fmt.Errorf("empty attribute key in answer attribute order")
+		}
+		if slices.Contains(c.AnswerAttributeOrder[:i], key) {
+			return fmt.Errorf("duplicate attribute key %q in answer attribute order", key)
+		}
+	}
+	for i, threshold := range c.Receiver.LossThresholds {
+		// fraction lost of a receiver report is at most 255/256, a threshold of 1 could never be crossed
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("loss threshold %v out of range (0, 1)", threshold)
+		}
+		if i > 0 && threshold == c.Receiver.LossThresholds[i-1] {
+			return fmt.Errorf("duplicate loss threshold %v", threshold)
+		}
+		if i > 0 && threshold < c.Receiver.LossThresholds[i-1] {
+			return fmt.Errorf("loss thresholds %v not in ascending order", c.Receiver.LossThresholds)
+		}
+	}
+	if c.MTU != 0 && c.MTU < pacer.MinMTU {
+		return fmt.Errorf("MTU %d below minimum %d", c.MTU, pacer.MinMTU)
+	}
+	if c.SubscriberSendQueueSize < 0 {
+		return fmt.Errorf("invalid subscriber send queue size %d", c.SubscriberSendQueueSize)
+	}
+	if err := validateSSRCRange(c.SSRCRangeStart, c.SSRCRangeEnd); err != nil {
+		return err
+	}
+	if err := validateLossFallback(c.Receiver.LossFallback); err != nil {
+		return err
+	}
+	if err := validateCodecFallback(c.Receiver.CodecFallbacks, c.Receiver.DecodeFailure); err != nil {
+		return err
+	}
+	if err := validateLayerTargetBitrates(c.Receiver.LayerTargetBitrates); err != nil {
+		return err
+	}
+	if err := validateLayerSwitchMinDwell(c.Receiver.LayerSwitchMinDwell); err != nil {
+		return err
+	}
+	if err := validateRenegotiationLimit(c.RenegotiationLimit); err != nil {
+		return err
+	}
+	if c.AdmissionControl != nil {
+		if err := c.AdmissionControl.validate(); err != nil {
+			return err
+		}
+	}
+	return c.validateHeaderExtensions()
+}
+
+// validateHeaderExtensions ensures the extensions negotiated in each direction fit in the extension id space
+func (c *WebRTCConfig) validateHeaderExtensions() error {
+	maxHeaderExtensions := maxOneByteHeaderExtensions
+	if c.TwoByteHeaderExtensions {
+		maxHeaderExtensions = maxTwoByteHeaderExtensions
+	}
+	for name, dc := range map[string]DirectionConfig{"publisher": c.Publisher, "subscriber": c.Subscriber} {
+		if n := dc.RTPHeaderExtension.NumUnique(); n > maxHeaderExtensions {
+			return fmt.Errorf("%s negotiates %d header extensions, more than the maximum of %d", name, n, maxHeaderExtensions)
+		}
+	}
+	return nil
+}
+
+// WebRTCConfigHolder holds the config used for new connections and allows replacing it at runtime.
+// Connections that are already established keep the config they were created with.
+type WebRTCConfigHolder struct {
+	current atomic.Pointer[WebRTCConfig]
+}
+
+func NewWebRTCConfigHolder(conf *WebRTCConfig) *WebRTCConfigHolder {
+	h := &WebRTCConfigHolder{}
+	h.current.Store(conf)
+	return h
+}
+
+// Load returns the current config, it must not be modified by the caller
+func (h *WebRTCConfigHolder) Load() *WebRTCConfig {
+	return h.current.Load()
+}
+
+// Snapshot returns a copy of the current config which can be handed back to Restore
+func (h *WebRTCConfigHolder) Snapshot() *WebRTCConfig {
+	return h.current.Load().Snapshot()
+}
+
+// Restore swaps a previously taken snapshot back in
+func (h *WebRTCConfigHolder) Restore(snapshot *WebRTCConfig) {
+	h.current.Store(snapshot.Snapshot())
+}
+
+// ApplyNewConfig validates conf and swaps it in, the current config is kept when validation fails.
+// Listeners of the replaced config (UDP mux, TCP listener) are not closed as existing connections may still use them.
+func (h *WebRTCConfigHolder) ApplyNewConfig(conf *WebRTCConfig) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	h.current.Store(conf.Snapshot())
+	return nil
+}
+
+// Reload creates the config for new connections from conf and applies it. The sockets and pion settings of the
+// current config are kept, settings applied to them (ports, IPs, ICE servers, mDNS, ICE candidate order, SRTP
+// profiles, DSCP and DTLS cipher suites) only change on restart. Packet tracing is kept as toggled at runtime. The key
+// frame request limiter is kept as tracks bound before the reload use it, the limit only changes on restart.
+func (h *WebRTCConfigHolder) Reload(conf *config.Config) error {
+	reloadConf := *conf
+	// the current config holds the ports, do not bind them again or look up external IPs
+	reloadConf.RTC.UDPPort = rtcconfig.PortRange{}
+	reloadConf.RTC.TCPPort = 0
+	reloadConf.RTC.ICEPortRangeStart = 0
+	reloadConf.RTC.ICEPortRangeEnd = 0
+	reloadConf.RTC.ForceTCP = false
+	reloadConf.RTC.UseExternalIP = false
+
+	next, err := NewWebRTCConfig(&reloadConf)
+	if err != nil {
+		return err
+	}
+	next.WebRTCConfig = h.current.Load().WebRTCConfig
+	next.PacketTracer = h.current.Load().PacketTracer
+	next.DTLSCipherSuites = h.current.Load().DTLSCipherSuites
+	next.DSCP = h.current.Load().DSCP
+	next.Receiver.KeyFrameRequestLimiter = h.current.Load().Receiver.KeyFrameRequestLimiter
+	return h.ApplyNewConfig(next)
+}
+
+// isValidMaxAudioBitrate checks a configured audio bitrate cap, 0 disables it
+func isValidMaxAudioBitrate(bitrate int) bool {
+	return bitrate == 0 || (bitrate >= minOpusBitrate && bitrate <= maxOpusBitrate)
+}
+
+func isValidDeadTrackTimeout(timeout time.Duration) bool {
+	return timeout == 0 || timeout >= sfu.MinDeadTrackTimeout
+}
+
+func validateSSRCRange(start, end uint32) error {
+	if start == 0 && end == 0 {
+		return nil
+	}
+	// 0 is reserved as unset SSRC
+	if start == 0 || end < start {
+		return fmt.Errorf("invalid SSRC range [%d, %d]", start, end)
+	}
+	return nil
+}
+
+func validateLossFallback(params sfu.LossFallbackParams) error {
+	if params.Action == sfu.LossFallbackActionNone {
+		return nil
+	}
+	// fraction lost of a receiver report is at most 255/256, a threshold of 1 could never be crossed
+	if params.Threshold <= 0 || params.Threshold >= 1 {
+		return fmt.Errorf("loss fallback threshold %v out of range (0, 1)", params.Threshold)
+	}
+	if params.Duration < 0 {
+		return fmt.Errorf("invalid loss fallback duration %s", params.Duration)
+	}
+	return nil
+}
+
+// validateOpusSampleRates checks configured opus sample rates, empty uses the default
+func validateOpusSampleRates(sampleRates []uint32) error {
+	if len(sampleRates) == 0 {
+		return nil
+	}
+	for i, sampleRate := range sampleRates {
+		if !slices.Contains(supportedOpusSampleRates, sampleRate) {
+			return fmt.Errorf("unsupported opus sample rate %d, expected one of %v", sampleRate, supportedOpusSampleRates)
+		}
+		if slices.Contains(sampleRates[:i], sampleRate) {
+			return fmt.Errorf("duplicate opus sample rate %d", sampleRate)
+		}
+	}
+	if !slices.Contains(sampleRates, opusCodecCapability.ClockRate) {
+		return fmt.Errorf("opus sample rates %v must include %d", sampleRates, opusCodecCapability.ClockRate)
+	}
+	return nil
+}
+
+func validateAudioConcealment(mode config.AudioConcealmentMode) error {
+	switch mode {
+	case "", config.AudioConcealmentFEC, config.AudioConcealmentPLC:
+		return nil
+	default:
+		return fmt.Errorf("unsupported audio concealment %q", mode)
+	}
+}
+
+func validateRenegotiationLimit(limit RenegotiationLimit) error {
+	if limit.MaxOffers < 0 || (limit.MaxOffers > 0 && limit.Window <= 0) {
+		return fmt.Errorf("invalid renegotiation limit, %d offers in %s", limit.MaxOffers, limit.Window)
+	}
+	return nil
+}
+
+func validateLayerTargetBitrates(targets []int64) error {
+	if len(targets) > int(buffer.DefaultMaxLayerSpatial)+1 {
+		return fmt.Errorf("%d layer target bitrates, at most %d spatial layers", len(targets), buffer.DefaultMaxLayerSpatial+1)
+	}
+	for i, target := range targets {
+		if target < 0 {
+			return fmt.Errorf("invalid target bitrate %d for layer %d", target, i)
+		}
+	}
+	return nil
+}
+
+func validateRoomPacketBufferSizes(roomSizes []RoomPacketBufferSizes) error {
+	for _, sizes := range roomSizes {
+		if sizes.RoomNamePattern == "" {
+			return fmt.Errorf("missing room name pattern of packet buffer sizes")
+		}
+		if _, err := path.Match(sizes.RoomNamePattern, ""); err != nil {
+			return fmt.Errorf("invalid room name pattern %q: %w", sizes.RoomNamePattern, err)
+		}
+		if sizes.Video < 0 || sizes.Audio < 0 || sizes.RTX < 0 {
+			return fmt.Errorf(
+				"invalid packet buffer sizes for rooms %q, video: %d, audio: %d, rtx: %d",
+				sizes.RoomNamePattern, sizes.Video, sizes.Audio, sizes.RTX,
+			)
+		}
+	}
+	return nil
+}
+
+func validateRoomPublishCodecs(roomCodecs []RoomPublishCodecs) error {
+	for _, codecs := range roomCodecs {
+		if codecs.RoomNamePattern == "" {
+			return fmt.Errorf("missing room name pattern of publish codecs")
+		}
+		if _, err := path.Match(codecs.RoomNamePattern, ""); err != nil {
+			return fmt.Errorf("invalid room name pattern %q: %w", codecs.RoomNamePattern, err)
+		}
+		if len(codecs.Codecs) == 0 {
+			return fmt.Errorf("no publish codecs for rooms %q", codecs.RoomNamePattern)
+		}
+		for _, mime := range codecs.Codecs {
+			if !strings.HasPrefix(strings.ToLower(mime), "audio/") && !strings.HasPrefix(strings.ToLower(mime), "video/") {
+				return fmt.Errorf("invalid publish codec %q for rooms %q, expected a mime type", mime, codecs.RoomNamePattern)
+			}
+		}
+	}
+	return nil
+}
+
+func validateLayerSwitchMinDwell(dwell time.Duration) error {
+	if dwell < 0 {
+		return fmt.Errorf("invalid layer switch min dwell %s", dwell)
+	}
+	return nil
+}
+
+func validateReceiverReportJitter(interval time.Duration, jitter time.Duration) error {
+	if interval <= 0 {
+		interval = time.Duration(buffer.ReportDelta)
+	}
+	if jitter < 0 || jitter >= interval {
+		return fmt.Errorf("invalid receiver report jitter %s, should be less than interval %s", jitter, interval)
+	}
+	return nil
+}
+
+func validateRampUpFactor(factor float64) error {
+	if factor != 0 && factor <= 1.0 {
+		return fmt.Errorf("invalid ramp up factor %v, should be above 1", factor)
+	}
+	return nil
+}
+
+func validatePinnedSpatialLayer(identity string, layer int32) error {
+	if layer < 0 || layer > buffer.DefaultMaxLayerSpatial {
+		return fmt.Errorf("pinned spatial layer %d of %q out of range [0, %d]", layer, identity, buffer.DefaultMaxLayerSpatial)
+	}
+	return nil
+}
+
+func validateSVCLayerCap(mime string, layerCap buffer.VideoLayer) error {
+	if !strings.HasPrefix(mime, "video/") {
+		return fmt.Errorf("invalid svc layer cap codec %q, expected a video mime type", mime)
+	}
+	if layerCap.Spatial < buffer.InvalidLayerSpatial || layerCap.Spatial > buffer.DefaultMaxLayerSpatial {
+		return fmt.Errorf("max spatial layer %d of %q out of range [0, %d]", layerCap.Spatial, mime, buffer.DefaultMaxLayerSpatial)
+	}
+	if layerCap.Temporal < buffer.InvalidLayerTemporal || layerCap.Temporal > buffer.DefaultMaxLayerTemporal {
+		return fmt.Errorf("max temporal layer %d of %q out of range [0, %d]", layerCap.Temporal, mime, buffer.DefaultMaxLayerTemporal)
+	}
+	return nil
+}
+
+func validateCodecFallback(fallbacks map[string][]string, params sfu.DecodeFailureParams) error {
+	if len(fallbacks) == 0 {
+		return nil
+	}
+	for mime, chain := range fallbacks {
+		if !strings.HasPrefix(mime, "video/") {
+			return fmt.Errorf("codec fallback for %q, only video codecs can fall back", mime)
+		}
+		for i, fallback := range chain {
+			if !strings.HasPrefix(fallback, "video/") || fallback == mime || slices.Contains(chain[:i], fallback) {
+				return fmt.Errorf("invalid codec fallback %q for %q", fallback, mime)
+			}
+		}
+	}
+	if params.KeyFrameRequests <= 0 || params.Window <= 0 {
+		return fmt.Errorf("invalid decode failure detection, %d key frame requests in %s", params.KeyFrameRequests, params.Window)
+	}
+	return nil
 }
@@ -0,0 +1,1534 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/transport/v2/packetio"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/mediatransportutil"
+	"github.com/livekit/protocol/livekit"
+)
+
+var testEnabledCodecs = []*livekit.Codec{
+	{Mime: webrtc.MimeTypeOpus},
+	{Mime: webrtc.MimeTypeVP8},
+}
+
+func newTestWebRTCConfig(t *testing.T, update func(conf *config.Config)) *WebRTCConfig {
+	conf, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	// disable mux, it doesn't play too well with unit test
+	conf.RTC.TCPPort = 0
+	if update != nil {
+		update(conf)
+	}
+
+	rtcConf, err := NewWebRTCConfig(conf)
+	require.NoError(t, err)
+	return rtcConf
+}
+
+// withICEPortRange uses a port range, some settings cannot be applied to the sockets of the UDP mux
+func withICEPortRange(conf *config.Config) {
+	conf.RTC.ICEPortRangeStart = 50000
+	conf.RTC.ICEPortRangeEnd = 50100
+}
+
+type bufferRequest struct {
+	packetType packetio.BufferPacketType
+	ssrc       uint32
+}
+
+type mockBufferProvider struct {
+	BufferProvider
+
+	lock     sync.Mutex
+	requests []bufferRequest
+}
+
+func (m *mockBufferProvider) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser {
+	m.lock.Lock()
+	m.requests = append(m.requests, bufferRequest{packetType: packetType, ssrc: ssrc})
+	m.lock.Unlock()
+
+	return m.BufferProvider.GetOrNew(packetType, ssrc)
+}
+
+func (m *mockBufferProvider) Requests() []bufferRequest {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]bufferRequest{}, m.requests...)
+}
+
+func TestSetBufferFactory(t *testing.T) {
+	t.Run("mock provider", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		factory := buffer.NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+		provider := &mockBufferProvider{BufferProvider: factory}
+		conf.SetBufferFactoryWithProvider(factory, provider)
+		// tracks and transports keep using the factory
+		require.Same(t, factory, conf.BufferFactory)
+
+		rtpBuffer := conf.SettingEngine.BufferFactory(packetio.RTPBufferPacket, 1234)
+		require.Same(t, factory.GetBuffer(1234), rtpBuffer)
+		rtcpReader := conf.SettingEngine.BufferFactory(packetio.RTCPBufferPacket, 1234)
+		require.IsType(t, &buffer.RTCPReader{}, rtcpReader)
+
+		require.Equal(t, []bufferRequest{
+			{packetType: packetio.RTPBufferPacket, ssrc: 1234},
+			{packetType: packetio.RTCPBufferPacket, ssrc: 1234},
+		}, provider.Requests())
+	})
+
+	t.Run("buffer factory", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		factory := buffer.NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+		conf.SetBufferFactory(factory)
+		require.Same(t, factory, conf.BufferFactory)
+
+		rtpBuffer := conf.SettingEngine.BufferFactory(packetio.RTPBufferPacket, 1234)
+		require.Same(t, factory.GetBuffer(1234), rtpBuffer)
+	})
+
+	t.Run("buffer clock", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		clock := &fixedClock{now: time.Unix(1000, 0)}
+		conf.BufferClock = clock
+		factory := buffer.NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+		conf.SetBufferFactory(factory)
+
+		opus := webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000},
+			PayloadType:        111,
+		}
+		rtpBuffer := conf.SettingEngine.BufferFactory(packetio.RTPBufferPacket, 1234).(*buffer.Buffer)
+		rtpBuffer.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{opus}}, opus.RTPCodecCapability, 0)
+		pkt, err := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: 1, Timestamp: 960, SSRC: 1234},
+			Payload: []byte{0x00, 0x01, 0x02},
+		}).Marshal()
+		require.NoError(t, err)
+		_, err = rtpBuffer.Write(pkt)
+		require.NoError(t, err)
+
+		// sender report is stamped with the time of the configured clock
+		rtpBuffer.SetSenderReportData(960, uint64(mediatransportutil.ToNtpTime(clock.now)))
+		require.Equal(t, clock.now, rtpBuffer.GetSenderReportData().At)
+	})
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestKeyFrameRequestMethods(t *testing.T) {
+	// mime types are normalized
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeyFrameRequestMethods = map[string]config.KeyFrameRequestMethod{
+			webrtc.MimeTypeVP9: config.KeyFrameRequestMethodFIR,
+			webrtc.MimeTypeAV1: config.KeyFrameRequestMethodPLI,
+		}
+	})
+	require.Equal(t, map[string]config.KeyFrameRequestMethod{
+		"video/vp9": config.KeyFrameRequestMethodFIR,
+		"video/av1": config.KeyFrameRequestMethodPLI,
+	}, conf.Receiver.KeyFrameRequestMethods)
+}
+
+// gatherCandidates returns the candidate types gathered by a peer connection created with the given configuration
+func gatherCandidates(t *testing.T, configuration webrtc.Configuration) []webrtc.ICECandidateType {
+	pc, err := webrtc.NewPeerConnection(configuration)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	var (
+		lock       sync.Mutex
+		candidates []webrtc.ICECandidateType
+	)
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		lock.Lock()
+		candidates = append(candidates, c.Typ)
+		lock.Unlock()
+	})
+
+	_, err = pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	require.NoError(t, pc.SetLocalDescription(offer))
+	<-gatherComplete
+
+	lock.Lock()
+	defer lock.Unlock()
+	return candidates
+}
+
+func TestICETransportPolicies(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICETransportPolicies = map[string]string{
+			"egress":   "relay",
+			"standard": "all",
+		}
+	})
+
+	t.Run("egress only gathers relay candidates", func(t *testing.T) {
+		egressConf := *conf
+		egressConf.SetParticipantKind(livekit.ParticipantInfo_EGRESS)
+		require.Equal(t, webrtc.ICETransportPolicyRelay, egressConf.Configuration.ICETransportPolicy)
+
+		for _, typ := range gatherCandidates(t, egressConf.Configuration) {
+			require.Equal(t, webrtc.ICECandidateTypeRelay, typ)
+		}
+	})
+
+	t.Run("unlisted kinds use all", func(t *testing.T) {
+		sipConf := *conf
+		sipConf.SetParticipantKind(livekit.ParticipantInfo_SIP)
+		require.Equal(t, webrtc.ICETransportPolicyAll, sipConf.Configuration.ICETransportPolicy)
+
+		// setting the kind on a copy should not leak into the shared config
+		require.Equal(t, webrtc.ICETransportPolicyAll, conf.Configuration.ICETransportPolicy)
+	})
+}
+
+func TestDirectionConfigMerge(t *testing.T) {
+	base := DirectionConfig{
+		RTPHeaderExtension: RTPHeaderExtensionConfig{
+			Audio: []string{sdp.SDESMidURI, sdp.AudioLevelURI},
+			Video: []string{sdp.SDESMidURI, sdp.TransportCCURI},
+		},
+		RTCPFeedback: RTCPFeedbackConfig{
+			Audio: []webrtc.RTCPFeedback{{Type: webrtc.TypeRTCPFBNACK}},
+			Video: []webrtc.RTCPFeedback{
+				{Type: webrtc.TypeRTCPFBNACK},
+				{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"},
+			},
+		},
+		StrictACKs: true,
+	}
+
+	t.Run("extension union", func(t *testing.T) {
+		merged := base.Merge(DirectionConfig{
+			RTPHeaderExtension: RTPHeaderExtensionConfig{
+				Audio: []string{sdp.AudioLevelURI},
+				Video: []string{dd.ExtensionURI, sdp.SDESMidURI, dd.ExtensionURI},
+			},
+			StrictACKs: true,
+		})
+		require.Equal(t, []string{sdp.SDESMidURI, sdp.AudioLevelURI}, merged.RTPHeaderExtension.Audio)
+		require.Equal(t, []string{sdp.SDESMidURI, sdp.TransportCCURI, dd.ExtensionURI}, merged.RTPHeaderExtension.Video)
+
+		// inputs are not modified
+		require.Equal(t, []string{sdp.SDESMidURI, sdp.TransportCCURI}, base.RTPHeaderExtension.Video)
+	})
+
+	t.Run("feedback override", func(t *testing.T) {
+		override := []webrtc.RTCPFeedback{
+			{Type: webrtc.TypeRTCPFBCCM, Parameter: "fir"},
+		}
+		merged := base.Merge(DirectionConfig{
+			RTCPFeedback: RTCPFeedbackConfig{Video: override},
+		})
+		require.Equal(t, override, merged.RTCPFeedback.Video)
+		// kind without override keeps base feedback
+		require.Equal(t, base.RTCPFeedback.Audio, merged.RTCPFeedback.Audio)
+	})
+
+	t.Run("StrictACKs precedence", func(t *testing.T) {
+		require.False(t, base.Merge(DirectionConfig{StrictACKs: false}).StrictACKs)
+		require.True(t, DirectionConfig{}.Merge(DirectionConfig{StrictACKs: true}).StrictACKs)
+	})
+}
+
+// connectPeers connects a peer using the given setting engine to one using the default setting engine with the
+// given SRTP protection profiles, returns true if the connection, including DTLS, is established
+func connectPeers(t *testing.T, se webrtc.SettingEngine, remoteProfiles ...dtls.SRTPProtectionProfile) bool {
+	se.SetIncludeLoopbackCandidate(true)
+	pcA, err := webrtc.NewAPI(webrtc.WithSettingEngine(se)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pcA.Close()
+
+	remoteSE := webrtc.SettingEngine{}
+	remoteSE.SetIncludeLoopbackCandidate(true)
+	remoteSE.SetSRTPProtectionProfiles(remoteProfiles...)
+	pcB, err := webrtc.NewAPI(webrtc.WithSettingEngine(remoteSE)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pcB.Close()
+
+	done := make(chan bool, 2)
+	onState := func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			done <- true
+		case webrtc.PeerConnectionStateFailed:
+			done <- false
+		}
+	}
+	pcA.OnConnectionStateChange(onState)
+	pcB.OnConnectionStateChange(onState)
+
+	_, err = pcB.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	offer, err := pcB.CreateOffer(nil)
+	require.NoError(t, err)
+	gatherCompleteB := webrtc.GatheringCompletePromise(pcB)
+	require.NoError(t, pcB.SetLocalDescription(offer))
+	<-gatherCompleteB
+
+	require.NoError(t, pcA.SetRemoteDescription(*pcB.LocalDescription()))
+	answer, err := pcA.CreateAnswer(nil)
+	require.NoError(t, err)
+	gatherCompleteA := webrtc.GatheringCompletePromise(pcA)
+	require.NoError(t, pcA.SetLocalDescription(answer))
+	<-gatherCompleteA
+	require.NoError(t, pcB.SetRemoteDescription(*pcA.LocalDescription()))
+
+	select {
+	case connected := <-done:
+		return connected
+	case <-time.After(10 * time.Second):
+		return false
+	}
+}
+
+func TestSRTPProtectionProfiles(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.SRTPProtectionProfiles = []string{"SRTP_AEAD_AES_256_GCM", "srtp_aead_aes_128_gcm"}
+	})
+
+	t.Run("gcm accepted", func(t *testing.T) {
+		require.True(t, connectPeers(t, conf.SettingEngine, dtls.SRTP_AEAD_AES_128_GCM))
+	})
+
+	t.Run("non gcm excluded", func(t *testing.T) {
+		require.False(t, connectPeers(t, conf.SettingEngine, dtls.SRTP_AES128_CM_HMAC_SHA1_80))
+	})
+}
+
+// gatherHostCandidateAddresses returns the addresses of the host candidates gathered using the setting engine
+func gatherHostCandidateAddresses(t *testing.T, se webrtc.SettingEngine) []string {
+	se.SetIncludeLoopbackCandidate(true)
+	pc, err := webrtc.NewAPI(webrtc.WithSettingEngine(se)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+
+	var (
+		lock      sync.Mutex
+		addresses []string
+	)
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil || c.Typ != webrtc.ICECandidateTypeHost {
+			return
+		}
+		lock.Lock()
+		addresses = append(addresses, c.Address)
+		lock.Unlock()
+	})
+
+	_, err = pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	require.NoError(t, pc.SetLocalDescription(offer))
+	<-gatherComplete
+
+	lock.Lock()
+	defer lock.Unlock()
+	return addresses
+}
+
+func TestMDNSMode(t *testing.T) {
+	// only gathering changes the signalled candidates, querying resolves remote ones
+	tests := []struct {
+		name       string
+		useMDNS    bool
+		mode       string
+		gatherMDNS bool
+	}{
+		{name: "follows use_mdns off", useMDNS: false},
+		{name: "disabled", useMDNS: true, mode: "disabled"},
+		{name: "query only", useMDNS: false, mode: "query_only"},
+		{name: "query and gather", useMDNS: false, mode: "Query_And_Gather", gatherMDNS: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+				conf.RTC.UseMDNS = tt.useMDNS
+				conf.RTC.MDNSMode = tt.mode
+			})
+			addresses := gatherHostCandidateAddresses(t, conf.SettingEngine)
+			require.NotEmpty(t, addresses)
+			for _, address := range addresses {
+				require.Equal(t, tt.gatherMDNS, strings.HasSuffix(address, ".local"), address)
+			}
+		})
+	}
+}
+
+func TestUnknownRTCPPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, buffer.UnknownRTCPPolicyLog, conf.Receiver.UnknownRTCPPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.UnknownRTCP = config.UnknownRTCPPolicyCount
+	})
+	require.Equal(t, buffer.UnknownRTCPPolicyCount, conf.Receiver.UnknownRTCPPolicy)
+}
+
+func TestMalformedRTPPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, buffer.MalformedRTPPolicyLog, conf.Receiver.MalformedRTPPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.MalformedRTP = config.MalformedRTPPolicyCount
+	})
+	require.Equal(t, buffer.MalformedRTPPolicyCount, conf.Receiver.MalformedRTPPolicy)
+}
+
+func TestMaxVideoCodecsConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Subscriber.MaxVideoCodecs)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.MaxVideoCodecs = 2
+	})
+	require.Zero(t, conf.Publisher.MaxVideoCodecs)
+	require.Equal(t, 2, conf.Subscriber.MaxVideoCodecs)
+	require.Equal(t, 2, conf.Snapshot().Subscriber.MaxVideoCodecs)
+	require.Equal(t, 2, conf.Subscriber.Merge(DirectionConfig{}).MaxVideoCodecs)
+	require.Equal(t, 1, conf.Subscriber.Merge(DirectionConfig{MaxVideoCodecs: 1}).MaxVideoCodecs)
+	require.NoError(t, conf.Validate())
+
+	conf.Subscriber.MaxVideoCodecs = -1
+	require.Error(t, conf.Validate())
+}
+
+func TestRTXAssociationPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, buffer.RTXAssociationPolicyBestEffort, conf.Receiver.RTXAssociationPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.RTXAssociation = config.RTXAssociationPolicyStrict
+	})
+	require.Equal(t, buffer.RTXAssociationPolicyStrict, conf.Receiver.RTXAssociationPolicy)
+}
+
+func TestHeaderExtensionCap(t *testing.T) {
+	withExtensions := func(conf *WebRTCConfig, n int) {
+		for i := conf.Publisher.RTPHeaderExtension.NumUnique(); i < n; i++ {
+			conf.Publisher.RTPHeaderExtension.Video = append(conf.Publisher.RTPHeaderExtension.Video, fmt.Sprintf("urn:test:ext:%d", i))
+		}
+	}
+
+	t.Run("defaults fit in one-byte headers", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.PublisherAbsSendTime = true
+		})
+		require.LessOrEqual(t, conf.Publisher.RTPHeaderExtension.NumUnique(), maxOneByteHeaderExtensions)
+		require.NoError(t, conf.Validate())
+	})
+
+	t.Run("exceeding one-byte range", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		withExtensions(conf, maxOneByteHeaderExtensions)
+		require.NoError(t, conf.Validate())
+
+		withExtensions(conf, maxOneByteHeaderExtensions+1)
+		require.Error(t, conf.Validate())
+	})
+
+	t.Run("two-byte headers", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.TwoByteHeaderExtensions = true
+		})
+		withExtensions(conf, maxOneByteHeaderExtensions+1)
+		require.NoError(t, conf.Validate())
+
+		withExtensions(conf, maxTwoByteHeaderExtensions+1)
+		require.Error(t, conf.Validate())
+	})
+
+	t.Run("audio and video share ids", func(t *testing.T) {
+		exts := RTPHeaderExtensionConfig{
+			Audio: []string{sdp.SDESMidURI, sdp.AudioLevelURI},
+			Video: []string{sdp.SDESMidURI, sdp.TransportCCURI},
+		}
+		require.Equal(t, 3, exts.NumUnique())
+	})
+}
+
+func TestWebRTCConfigHolder(t *testing.T) {
+	t.Run("snapshot is independent of the config", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(c *config.Config) {
+			c.RTC.KeyFrameRequestMethods = map[string]config.KeyFrameRequestMethod{"video/vp8": config.KeyFrameRequestMethodFIR}
+		})
+		snapshot := conf.Snapshot()
+
+		conf.Receiver.KeyFrameRequestMethods["video/h264"] = config.KeyFrameRequestMethodPLI
+		conf.Publisher.RTPHeaderExtension.Video[0] = "urn:test"
+		require.Len(t, snapshot.Receiver.KeyFrameRequestMethods, 1)
+		require.NotEqual(t, "urn:test", snapshot.Publisher.RTPHeaderExtension.Video[0])
+
+		conf.Restore(snapshot)
+		require.Len(t, conf.Receiver.KeyFrameRequestMethods, 1)
+		require.Equal(t, snapshot.Publisher, conf.Publisher)
+	})
+
+	t.Run("snapshot copies every setting", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		fillConfigValue(reflect.ValueOf(conf).Elem())
+		snapshot := conf.Snapshot()
+		requireNotAliased(t, "WebRTCConfig", reflect.ValueOf(conf).Elem(), reflect.ValueOf(snapshot).Elem())
+	})
+
+	t.Run("new config is swapped in", func(t *testing.T) {
+		initial := newTestWebRTCConfig(t, nil)
+		holder := NewWebRTCConfigHolder(initial)
+		inUse := *holder.Load()
+
+		updated := newTestWebRTCConfig(t, func(c *config.Config) {
+			c.RTC.MaxSimulcastLayers = 2
+		})
+		require.NoError(t, holder.ApplyNewConfig(updated))
+		require.Equal(t, 2, holder.Load().Receiver.MaxSimulcastLayers)
+
+		// copies taken before the swap are unaffected
+		require.Equal(t, 0, inUse.Receiver.MaxSimulcastLayers)
+	})
+
+	t.Run("invalid config is rolled back", func(t *testing.T) {
+		holder := NewWebRTCConfigHolder(newTestWebRTCConfig(t, nil))
+		current := holder.Load()
+
+		invalid := holder.Snapshot()
+		invalid.Receiver.AudioRedDistance = sfu.MaxRedDistance + 1
+		require.Error(t, holder.ApplyNewConfig(invalid))
+		require.Same(t, current, holder.Load())
+
+		invalid = holder.Snapshot()
+		invalid.ICETransportPolicies[livekit.ParticipantInfo_EGRESS] = webrtc.ICETransportPolicy(42)
+		require.Error(t, holder.ApplyNewConfig(invalid))
+		require.Same(t, current, holder.Load())
+	})
+
+	t.Run("reload keeps sockets", func(t *testing.T) {
+		holder := NewWebRTCConfigHolder(newTestWebRTCConfig(t, nil))
+		current := holder.Load()
+
+		c, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		c.RTC.MaxSimulcastLayers = 2
+		require.NoError(t, holder.Reload(c))
+		require.Equal(t, 2, holder.Load().Receiver.MaxSimulcastLayers)
+		require.Equal(t, current.UDPMux, holder.Load().UDPMux)
+		require.Equal(t, current.TCPMuxListener, holder.Load().TCPMuxListener)
+
+		reloaded := holder.Load()
+		c.RTC.MaxRetransmits = -1
+		require.Error(t, holder.Reload(c))
+		require.Same(t, reloaded, holder.Load())
+	})
+
+	t.Run("reload keeps key frame request limiter", func(t *testing.T) {
+		holder := NewWebRTCConfigHolder(newTestWebRTCConfig(t, func(c *config.Config) {
+			c.RTC.MaxOutstandingKeyFrameRequests = 10
+		}))
+		limiter := holder.Load().Receiver.KeyFrameRequestLimiter
+		require.NotNil(t, limiter)
+
+		c, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		c.RTC.MaxOutstandingKeyFrameRequests = 20
+		require.NoError(t, holder.Reload(c))
+		require.Same(t, limiter, holder.Load().Receiver.KeyFrameRequestLimiter)
+	})
+
+	t.Run("reload keeps DSCP marking", func(t *testing.T) {
+		// not enabled on the UDP mux by a reload
+		holder := NewWebRTCConfigHolder(newTestWebRTCConfig(t, nil))
+		c, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		c.RTC.DSCP = config.DSCPConfig{Audio: 46}
+		require.NoError(t, holder.Reload(c))
+		require.Nil(t, holder.Load().DSCP)
+
+		holder = NewWebRTCConfigHolder(newTestWebRTCConfig(t, func(c *config.Config) {
+			withICEPortRange(c)
+			c.RTC.DSCP = config.DSCPConfig{Audio: 46, Video: 34}
+		}))
+		marking := holder.Load().DSCP
+		c.RTC.DSCP = config.DSCPConfig{Audio: 10}
+		require.NoError(t, holder.Reload(c))
+		require.Same(t, marking, holder.Load().DSCP)
+		require.Equal(t, uint8(46), holder.Load().DSCP.Audio)
+		require.Equal(t, uint8(34), holder.Load().DSCP.Video)
+	})
+
+	t.Run("restore snapshot", func(t *testing.T) {
+		holder := NewWebRTCConfigHolder(newTestWebRTCConfig(t, nil))
+		snapshot := holder.Snapshot()
+
+		require.NoError(t, holder.ApplyNewConfig(newTestWebRTCConfig(t, func(c *config.Config) {
+			c.RTC.MaxSimulcastLayers = 1
+		})))
+		require.Equal(t, 1, holder.Load().Receiver.MaxSimulcastLayers)
+
+		holder.Restore(snapshot)
+		require.Equal(t, 0, holder.Load().Receiver.MaxSimulcastLayers)
+	})
+}
+
+// fillConfigValue adds an element to every empty slice and map reachable through exported fields
+func fillConfigValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Len() == 0 {
+			v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		}
+		for i := 0; i < v.Len(); i++ {
+			fillConfigValue(v.Index(i))
+		}
+
+	case reflect.Map:
+		if v.Len() == 0 {
+			v.Set(reflect.MakeMap(v.Type()))
+			v.SetMapIndex(reflect.Zero(v.Type().Key()), reflect.Zero(v.Type().Elem()))
+		}
+		for _, key := range v.MapKeys() {
+			// map elements are not addressable
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			fillConfigValue(elem)
+			v.SetMapIndex(key, elem)
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fillConfigValue(v.Index(i))
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				fillConfigValue(field)
+			}
+		}
+	}
+}
+
+// requireNotAliased fails when a slice or map reachable through exported fields of clone is the one of orig
+func requireNotAliased(t *testing.T, path string, orig reflect.Value, clone reflect.Value) {
+	switch orig.Kind() {
+	case reflect.Slice:
+		require.NotEqual(t, orig.Pointer(), clone.Pointer(), path)
+		for i := 0; i < orig.Len(); i++ {
+			requireNotAliased(t, fmt.Sprintf("%s[%d]", path, i), orig.Index(i), clone.Index(i))
+		}
+
+	case reflect.Map:
+		require.NotEqual(t, orig.Pointer(), clone.Pointer(), path)
+		for _, key := range orig.MapKeys() {
+			requireNotAliased(t, fmt.Sprintf("%s[%v]", path, key), orig.MapIndex(key), clone.MapIndex(key))
+		}
+
+	case reflect.Array:
+		for i := 0; i < orig.Len(); i++ {
+			requireNotAliased(t, fmt.Sprintf("%s[%d]", path, i), orig.Index(i), clone.Index(i))
+		}
+
+	case reflect.Struct:
+		for i := 0; i < orig.NumField(); i++ {
+			if field := orig.Type().Field(i); field.IsExported() {
+				requireNotAliased(t, path+"."+field.Name, orig.Field(i), clone.Field(i))
+			}
+		}
+	}
+}
+
+func TestSyncOffsets(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.SyncOffsets = map[string]time.Duration{
+			"microphone": 40 * time.Millisecond,
+			"CAMERA":     -20 * time.Millisecond,
+		}
+	})
+	require.Equal(t, map[livekit.TrackSource]time.Duration{
+		livekit.TrackSource_MICROPHONE: 40 * time.Millisecond,
+		livekit.TrackSource_CAMERA:     -20 * time.Millisecond,
+	}, conf.Receiver.SyncOffsets)
+}
+
+func TestRoomPacketBufferSizesConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PacketBufferSizeVideo = 500
+		conf.RTC.PacketBufferSizeAudio = 200
+		conf.RTC.RoomPacketBufferSizes = []config.RoomPacketBufferSizeConfig{
+			{RoomNamePattern: "rec-*", Video: 2000, Audio: 1000, RTX: 800},
+			{RoomNamePattern: "*", Video: 100},
+		}
+	})
+
+	recorded := *conf
+	recorded.SetRoom("rec-standup")
+	require.Equal(t, 2000, recorded.Receiver.PacketBufferSizeVideo)
+	require.Equal(t, 1000, recorded.Receiver.PacketBufferSizeAudio)
+	require.Equal(t, 800, recorded.Receiver.PacketBufferSizeRTX)
+
+	// first match applies, unset sizes keep the node default
+	interactive := *conf
+	interactive.SetRoom("standup")
+	require.Equal(t, 100, interactive.Receiver.PacketBufferSizeVideo)
+	require.Equal(t, 200, interactive.Receiver.PacketBufferSizeAudio)
+	require.Equal(t, 500, interactive.Receiver.PacketBufferSizeRTX)
+
+	require.Equal(t, 500, conf.Receiver.PacketBufferSizeVideo)
+
+	conf.Receiver.RoomPacketBufferSizes[0].RoomNamePattern = "["
+	require.Error(t, conf.Validate())
+}
+
+func TestRoomPublishCodecsConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{
+			{RoomNamePattern: "basic-*", Codecs: []string{"audio/opus", "video/VP8"}},
+			{RoomNamePattern: "pro-*", Codecs: []string{"audio/opus", "video/vp8", "video/h264"}},
+		}
+	})
+	codecs := []*livekit.Codec{
+		{Mime: webrtc.MimeTypeOpus},
+		{Mime: "audio/red"},
+		{Mime: webrtc.MimeTypeVP8},
+		{Mime: webrtc.MimeTypeVP9},
+		{Mime: webrtc.MimeTypeH264},
+		{Mime: webrtc.MimeTypeAV1},
+		{Mime: videoRTXMimeType},
+	}
+	mimes := func(codecs []*livekit.Codec) []string {
+		var mimes []string
+		for _, c := range codecs {
+			mimes = append(mimes, c.Mime)
+		}
+		return mimes
+	}
+
+	basic := *conf
+	basic.SetRoom("basic-standup")
+	require.Equal(t, []string{webrtc.MimeTypeOpus, "audio/red", webrtc.MimeTypeVP8, videoRTXMimeType}, mimes(basic.FilterPublishCodecs(codecs)))
+
+	// negotiation with publishers refuses disallowed codecs
+	me, err := createMediaEngine(basic.FilterPublishCodecs(codecs), basic.Publisher, false)
+	require.NoError(t, err)
+	answerer, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer answerer.Close()
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer offerer.Close()
+	_, err = offerer.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+	require.NoError(t, err)
+	offer, err := offerer.CreateOffer(nil)
+	require.NoError(t, err)
+	require.NoError(t, answerer.SetRemoteDescription(offer))
+	answer, err := answerer.CreateAnswer(nil)
+	require.NoError(t, err)
+	require.Contains(t, answer.SDP, "VP8/90000")
+	require.NotContains(t, answer.SDP, "H264/90000")
+	require.NotContains(t, answer.SDP, "VP9/90000")
+
+	pro := *conf
+	pro.SetRoom("pro-standup")
+	require.Contains(t, mimes(pro.FilterPublishCodecs(codecs)), webrtc.MimeTypeH264)
+
+	// rooms matching no pattern allow all codecs
+	other := *conf
+	other.SetRoom("standup")
+	require.Equal(t, codecs, other.FilterPublishCodecs(codecs))
+}
+
+func TestMaxAudioBitrateConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.MaxAudioBitrate = 32000
+	})
+	require.Equal(t, 32000, conf.Receiver.MaxAudioBitrate)
+}
+
+func TestLossFallback(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, sfu.LossFallbackActionNone, conf.Receiver.LossFallback.Action)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.LossFallback.Action = config.LossFallbackActionLowerLayer
+	})
+	require.Equal(t, sfu.LossFallbackParams{
+		Action:    sfu.LossFallbackActionLowerLayer,
+		Threshold: defaultLossFallbackThreshold,
+		Duration:  defaultLossFallbackDuration,
+	}, conf.Receiver.LossFallback)
+}
+
+func TestLossThresholds(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.Receiver.LossThresholds)
+
+	// sorted ascending
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.LossThresholds = []float64{0.2, 0.05, 0.5}
+	})
+	require.Equal(t, []float64{0.05, 0.2, 0.5}, conf.Receiver.LossThresholds)
+}
+
+func TestOpusSampleRatesConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.OpusSampleRates = []uint32{48000, 16000}
+	})
+	require.Equal(t, []uint32{48000, 16000}, conf.Publisher.OpusSampleRates)
+	require.Equal(t, []uint32{48000, 16000}, conf.Subscriber.OpusSampleRates)
+}
+
+func TestDeadTrackTimeout(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Receiver.DeadTrackTimeoutVideo)
+	require.Zero(t, conf.Receiver.DeadTrackTimeoutAudio)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.DeadTrackTimeoutVideo = 5 * time.Second
+		conf.RTC.DeadTrackTimeoutAudio = 10 * time.Second
+	})
+	require.Equal(t, 5*time.Second, conf.Receiver.DeadTrackTimeoutVideo)
+	require.Equal(t, 10*time.Second, conf.Receiver.DeadTrackTimeoutAudio)
+	require.NoError(t, conf.Validate())
+
+	conf.Receiver.DeadTrackTimeoutAudio = -time.Second
+	require.Error(t, conf.Validate())
+	conf.Receiver.DeadTrackTimeoutAudio = time.Nanosecond
+	require.Error(t, conf.Validate())
+}
+
+func TestStrictACKsGrace(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.StrictACKsGrace = 3 * time.Second
+	})
+	require.Zero(t, conf.Publisher.ACKGrace)
+	require.Equal(t, 3*time.Second, conf.Subscriber.ACKGrace)
+	require.Equal(t, 3*time.Second, conf.Snapshot().Subscriber.ACKGrace)
+	require.Zero(t, conf.Subscriber.Merge(DirectionConfig{StrictACKs: true}).ACKGrace)
+	require.NoError(t, conf.Validate())
+
+	conf.Subscriber.ACKGrace = -time.Second
+	require.Error(t, conf.Validate())
+}
+
+func TestCodecMatching(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.False(t, conf.StrictCodecMatching)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CodecMatching = config.CodecMatchingStrict
+	})
+	require.True(t, conf.StrictCodecMatching)
+}
+
+func TestSubscriptionMode(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.False(t, conf.DeferredSubscription)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.Subscription = config.SubscriptionModeDeferred
+	})
+	require.True(t, conf.DeferredSubscription)
+}
+
+func TestPaddingPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, sfu.PaddingPolicyStripContiguous, conf.Receiver.PaddingPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PaddingPolicy = config.PaddingPolicyStrip
+	})
+	require.Equal(t, sfu.PaddingPolicyStrip, conf.Receiver.PaddingPolicy)
+
+	// forwarding padding is fine with REMB or media probes
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PaddingPolicy = config.PaddingPolicyForward
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+	})
+	require.Equal(t, sfu.PaddingPolicyForward, conf.Receiver.PaddingPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PaddingPolicy = config.PaddingPolicyForward
+		conf.RTC.CongestionControl.UseSendSideBWE = true
+		conf.RTC.CongestionControl.ProbeMode = config.CongestionControlProbeModeMedia
+	})
+	require.Equal(t, sfu.PaddingPolicyForward, conf.Receiver.PaddingPolicy)
+}
+
+func TestKeepalivePolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, sfu.KeepalivePolicyAsPadding, conf.Receiver.KeepalivePolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeepalivePolicy = config.KeepalivePolicyDrop
+	})
+	require.Equal(t, sfu.KeepalivePolicyDrop, conf.Receiver.KeepalivePolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeepalivePolicy = config.KeepalivePolicyForward
+	})
+	require.Equal(t, sfu.KeepalivePolicyForward, conf.Receiver.KeepalivePolicy)
+}
+
+func TestKeyFrameReorderPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Receiver.KeyFrameReorderTolerance)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeyFrameReorderPolicy = config.KeyFrameReorderPolicyDrop
+		conf.RTC.KeyFrameReorderTolerance = 16
+	})
+	require.Zero(t, conf.Receiver.KeyFrameReorderTolerance)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeyFrameReorderPolicy = config.KeyFrameReorderPolicyDefer
+	})
+	require.Equal(t, defaultKeyFrameReorderTolerance, conf.Receiver.KeyFrameReorderTolerance)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.KeyFrameReorderPolicy = config.KeyFrameReorderPolicyDefer
+		conf.RTC.KeyFrameReorderTolerance = 16
+	})
+	require.Equal(t, 16, conf.Receiver.KeyFrameReorderTolerance)
+}
+
+func TestPinnedSpatialLayers(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.Receiver.PinnedSpatialLayers)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PinnedSpatialLayers = map[string]int32{"recorder": 2, "kiosk": 0}
+	})
+	require.Equal(t, map[livekit.ParticipantIdentity]int32{"recorder": 2, "kiosk": 0}, conf.Receiver.PinnedSpatialLayers)
+	require.NoError(t, conf.Validate())
+
+	snapshot := conf.Snapshot()
+	conf.Receiver.PinnedSpatialLayers["recorder"] = 3
+	require.Error(t, conf.Validate())
+	require.Equal(t, int32(2), snapshot.Receiver.PinnedSpatialLayers["recorder"])
+}
+
+func TestSVCLayerCaps(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.Receiver.SVCLayerCaps)
+
+	one, zero := int32(1), int32(0)
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.SVCLayerCaps = map[string]config.SVCLayerCapConfig{
+			"video/AV1": {MaxSpatialLayer: &one, MaxTemporalLayer: &one},
+			"video/vp9": {MaxTemporalLayer: &zero},
+		}
+	})
+	require.Equal(t, map[string]buffer.VideoLayer{
+		"video/av1": {Spatial: 1, Temporal: 1},
+		"video/vp9": {Spatial: buffer.InvalidLayerSpatial, Temporal: 0},
+	}, conf.Receiver.SVCLayerCaps)
+	require.NoError(t, conf.Validate())
+
+	snapshot := conf.Snapshot()
+	conf.Receiver.SVCLayerCaps["video/av1"] = buffer.VideoLayer{Spatial: buffer.DefaultMaxLayerSpatial + 1}
+	require.Error(t, conf.Validate())
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: 1}, snapshot.Receiver.SVCLayerCaps["video/av1"])
+}
+
+func TestAudioConcealmentConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.Publisher.AudioConcealment)
+	require.Empty(t, conf.Subscriber.AudioConcealment)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.AudioConcealment = config.AudioConcealmentPLC
+	})
+	require.Equal(t, config.AudioConcealmentPLC, conf.Publisher.AudioConcealment)
+	require.Empty(t, conf.Subscriber.AudioConcealment)
+	require.NoError(t, conf.Validate())
+	require.Equal(t, config.AudioConcealmentPLC, conf.Snapshot().Publisher.AudioConcealment)
+
+	merged := conf.Publisher.Merge(DirectionConfig{})
+	require.Equal(t, config.AudioConcealmentPLC, merged.AudioConcealment)
+	merged = conf.Publisher.Merge(DirectionConfig{AudioConcealment: config.AudioConcealmentFEC})
+	require.Equal(t, config.AudioConcealmentFEC, merged.AudioConcealment)
+}
+
+func TestLayerTargetBitrates(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.Receiver.LayerTargetBitrates)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.LayerTargetBitrates = []int64{150_000, 0, 2_000_000}
+	})
+	require.Equal(t, []int64{150_000, 0, 2_000_000}, conf.Receiver.LayerTargetBitrates)
+
+	// not used without bandwidth estimation
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.FixedBitrate = true
+		conf.RTC.CongestionControl.LayerTargetBitrates = []int64{150_000, 500_000, 2_000_000}
+	})
+	require.Empty(t, conf.Receiver.LayerTargetBitrates)
+}
+
+func TestLayerSwitchMinDwell(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Receiver.LayerSwitchMinDwell)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.LayerSwitchMinDwell = 2 * time.Second
+	})
+	require.Equal(t, 2*time.Second, conf.Receiver.LayerSwitchMinDwell)
+
+	// layers are not switched without bandwidth estimation
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.FixedBitrate = true
+		conf.RTC.CongestionControl.LayerSwitchMinDwell = 2 * time.Second
+	})
+	require.Zero(t, conf.Receiver.LayerSwitchMinDwell)
+}
+
+func TestPubMutePolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, sfu.PubMutePolicySilence, conf.Receiver.PubMutePolicy)
+
+	for mode, policy := range map[config.PubMutePolicy]sfu.PubMutePolicy{
+		config.PubMutePolicySilence: sfu.PubMutePolicySilence,
+		config.PubMutePolicyStop:    sfu.PubMutePolicyStop,
+		config.PubMutePolicyMarker:  sfu.PubMutePolicyMarker,
+	} {
+		conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.PubMutePolicy = mode
+		})
+		require.Equal(t, policy, conf.Receiver.PubMutePolicy, mode)
+	}
+}
+
+func TestICERestartPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, ICERestartPolicyOnNetworkChange, conf.ICERestartPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICERestartPolicy = config.ICERestartPolicyOnDisconnect
+	})
+	require.Equal(t, ICERestartPolicyOnDisconnect, conf.ICERestartPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICERestartPolicy = config.ICERestartPolicyNever
+	})
+	require.Equal(t, ICERestartPolicyNever, conf.ICERestartPolicy)
+}
+
+func TestReceiverReportJitter(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Receiver.ReceiverReportJitterVideo)
+	require.Zero(t, conf.Receiver.ReceiverReportJitterAudio)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ReceiverReportJitterVideo = 200 * time.Millisecond
+		conf.RTC.ReceiverReportIntervalAudio = 5 * time.Second
+		conf.RTC.ReceiverReportJitterAudio = 2 * time.Second
+	})
+	require.Equal(t, 200*time.Millisecond, conf.Receiver.ReceiverReportJitterVideo)
+	require.Equal(t, 2*time.Second, conf.Receiver.ReceiverReportJitterAudio)
+	require.NoError(t, conf.Validate())
+
+	// jitter has to be less than the interval, which defaults to one second
+	conf.Receiver.ReceiverReportJitterVideo = time.Second
+	require.Error(t, conf.Validate())
+}
+
+func TestMaxTimestampJump(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.Receiver.MaxTimestampJump)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.MaxTimestampJump = 5 * time.Second
+	})
+	require.Equal(t, 5*time.Second, conf.Receiver.MaxTimestampJump)
+	require.NoError(t, conf.Validate())
+
+	conf.Receiver.MaxTimestampJump = -time.Second
+	require.Error(t, conf.Validate())
+}
+
+func TestAnswerAttributeOrderConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Empty(t, conf.AnswerAttributeOrder)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.AnswerAttributeOrder = []string{"mid", "rtpmap"}
+	})
+	require.Equal(t, []string{"mid", "rtpmap"}, conf.AnswerAttributeOrder)
+}
+
+func TestSSRCRangeConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Zero(t, conf.SSRCRangeEnd)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.SSRCRangeStart = 1000
+		conf.RTC.SSRCRangeEnd = 1999
+	})
+	require.Equal(t, uint32(1000), conf.SSRCRangeStart)
+	require.Equal(t, uint32(1999), conf.SSRCRangeEnd)
+	require.NoError(t, conf.Validate())
+
+	conf.SSRCRangeStart = 2000
+	require.Error(t, conf.Validate())
+}
+
+func TestNewWebRTCConfigValid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		update func(conf *config.Config)
+	}{
+		{"source priorities", func(c *config.Config) {
+			c.RTC.CongestionControl.SourcePriorities = map[string]uint8{"screen_share": 255, "camera": 100}
+		}},
+		{"ramp up factor 0", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = 0
+		}},
+		{"ramp up factor 1.5", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = 1.5
+		}},
+		{"ramp up factor 2.5", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = 2.5
+		}},
+		{"priority changes default", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChanges = ""
+			c.RTC.CongestionControl.PriorityChangeMinInterval = time.Second
+		}},
+		{"priority changes ignore", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChanges = config.PriorityChangePolicyIgnore
+			c.RTC.CongestionControl.PriorityChangeMinInterval = time.Second
+		}},
+		{"priority changes allow", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChanges = config.PriorityChangePolicyAllow
+			c.RTC.CongestionControl.PriorityChangeMinInterval = time.Second
+		}},
+		{"priority changes deny", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChanges = config.PriorityChangePolicyDeny
+			c.RTC.CongestionControl.PriorityChangeMinInterval = time.Second
+		}},
+		{"low bandwidth policy default", func(c *config.Config) {
+			c.RTC.CongestionControl.LowBandwidthPolicy = ""
+		}},
+		{"low bandwidth policy pause", func(c *config.Config) {
+			c.RTC.CongestionControl.LowBandwidthPolicy = config.LowBandwidthPolicyPause
+		}},
+		{"low bandwidth policy lowest", func(c *config.Config) {
+			c.RTC.CongestionControl.LowBandwidthPolicy = config.LowBandwidthPolicyLowest
+		}},
+		{"low bandwidth policy freeze", func(c *config.Config) {
+			c.RTC.CongestionControl.LowBandwidthPolicy = config.LowBandwidthPolicyFreeze
+		}},
+		{"no RTCP fallback default", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPTimeout = 10 * time.Second
+			c.RTC.CongestionControl.NoRTCPFallback = ""
+		}},
+		{"no RTCP fallback conservative", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPTimeout = 10 * time.Second
+			c.RTC.CongestionControl.NoRTCPFallback = config.NoRTCPFallbackConservative
+		}},
+		{"no RTCP fallback disconnect", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPTimeout = 10 * time.Second
+			c.RTC.CongestionControl.NoRTCPFallback = config.NoRTCPFallbackDisconnect
+		}},
+		{"initial layer default", func(c *config.Config) {
+			c.RTC.CongestionControl.InitialLayer = ""
+		}},
+		{"initial layer highest", func(c *config.Config) {
+			c.RTC.CongestionControl.InitialLayer = config.InitialLayerHighest
+		}},
+		{"initial layer lowest", func(c *config.Config) {
+			c.RTC.CongestionControl.InitialLayer = config.InitialLayerLowest
+		}},
+		{"max retransmits", func(c *config.Config) {
+			c.RTC.MaxRetransmits = 1
+		}},
+		{"MTU", func(c *config.Config) {
+			c.RTC.MTU = 1200
+		}},
+		{"subscriber send queue size", func(c *config.Config) {
+			c.RTC.SubscriberSendQueueSize = 500
+		}},
+		{"max fps", func(c *config.Config) {
+			c.RTC.MaxFps = map[string]uint32{"screen_share": 5}
+		}},
+		{"RTX packet buffer size", func(c *config.Config) {
+			c.RTC.PacketBufferSizeRTX = 100
+		}},
+		{"RTCP extended reports", func(c *config.Config) {
+			c.RTC.RTCPExtendedReports = true
+		}},
+		{"reordered frame codecs", func(c *config.Config) {
+			c.RTC.ReorderedFrameCodecs = []string{"video/H264"}
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestWebRTCConfig(t, tc.update)
+		})
+	}
+}
+
+func TestNewWebRTCConfigInvalid(t *testing.T) {
+	zero, tooHigh := int32(0), int32(buffer.DefaultMaxLayerTemporal+1)
+
+	testCases := []struct {
+		name   string
+		update func(conf *config.Config)
+	}{
+		{"key frame request method", func(c *config.Config) {
+			c.RTC.KeyFrameRequestMethods = map[string]config.KeyFrameRequestMethod{webrtc.MimeTypeVP9: "lrr"}
+		}},
+		{"ICE transport policy of egress", func(c *config.Config) {
+			c.RTC.ICETransportPolicies = map[string]string{"egress": "host"}
+		}},
+		{"ICE transport policy of unknown kind", func(c *config.Config) {
+			c.RTC.ICETransportPolicies = map[string]string{"recorder": "relay"}
+		}},
+		{"negative audio red distance", func(c *config.Config) {
+			c.RTC.AudioRedDistance = -1
+		}},
+		{"audio red distance too high", func(c *config.Config) {
+			c.RTC.AudioRedDistance = sfu.MaxRedDistance + 1
+		}},
+		{"SRTP protection profile", func(c *config.Config) {
+			c.RTC.SRTPProtectionProfiles = []string{"SRTP_AEAD_AES_128_GCM", "SRTP_NULL_HMAC_SHA1_80"}
+		}},
+		{"mDNS mode", func(c *config.Config) {
+			c.RTC.MDNSMode = "gather_only"
+		}},
+		{"ICE candidate order", func(c *config.Config) {
+			c.RTC.ICEPreference = config.ICEPreferenceConfig{CandidateOrder: "relay_first"}
+		}},
+		{"duplicate ICE interface", func(c *config.Config) {
+			c.RTC.ICEPreference = config.ICEPreferenceConfig{Interfaces: []string{"eth1", "eth1"}}
+		}},
+		{"source priority", func(c *config.Config) {
+			c.RTC.CongestionControl.SourcePriorities = map[string]uint8{"webcam": 100}
+		}},
+		{"unknown RTCP policy", func(c *config.Config) {
+			c.RTC.UnknownRTCP = "drop"
+		}},
+		{"malformed RTP policy", func(c *config.Config) {
+			c.RTC.MalformedRTP = "ignore"
+		}},
+		{"max video codecs", func(c *config.Config) {
+			c.RTC.MaxVideoCodecs = -1
+		}},
+		{"RTX association", func(c *config.Config) {
+			c.RTC.RTXAssociation = "reject"
+		}},
+		{"sync offset", func(c *config.Config) {
+			c.RTC.SyncOffsets = map[string]time.Duration{"speaker": time.Millisecond}
+		}},
+		{"MTU", func(c *config.Config) {
+			c.RTC.MTU = pacer.MinMTU - 1
+		}},
+		{"room packet buffer size without pattern", func(c *config.Config) {
+			c.RTC.RoomPacketBufferSizes = []config.RoomPacketBufferSizeConfig{{RoomNamePattern: "", Video: 100}}
+		}},
+		{"room packet buffer size with invalid pattern", func(c *config.Config) {
+			c.RTC.RoomPacketBufferSizes = []config.RoomPacketBufferSizeConfig{{RoomNamePattern: "rec-[", Video: 100}}
+		}},
+		{"negative room packet buffer size", func(c *config.Config) {
+			c.RTC.RoomPacketBufferSizes = []config.RoomPacketBufferSizeConfig{{RoomNamePattern: "rec-*", Audio: -1}}
+		}},
+		{"room publish codecs without pattern", func(c *config.Config) {
+			c.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{{RoomNamePattern: "", Codecs: []string{"video/vp8"}}}
+		}},
+		{"room publish codecs with invalid pattern", func(c *config.Config) {
+			c.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{{RoomNamePattern: "basic-[", Codecs: []string{"video/vp8"}}}
+		}},
+		{"room publish codecs without codecs", func(c *config.Config) {
+			c.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{{RoomNamePattern: "basic-*"}}
+		}},
+		{"room publish codecs with invalid mime type", func(c *config.Config) {
+			c.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{{RoomNamePattern: "basic-*", Codecs: []string{"vp8"}}}
+		}},
+		{"subscriber send queue size", func(c *config.Config) {
+			c.RTC.SubscriberSendQueueSize = -1
+		}},
+		{"max retransmits", func(c *config.Config) {
+			c.RTC.MaxRetransmits = -1
+		}},
+		{"disabled RTCP feedback mime type", func(c *config.Config) {
+			c.RTC.DisabledRTCPFeedback = map[string][]string{"vp8": {webrtc.TypeRTCPFBGoogREMB}}
+		}},
+		{"max audio bitrate", func(c *config.Config) {
+			c.RTC.MaxAudioBitrate = 1000
+		}},
+		{"loss fallback action", func(c *config.Config) {
+			c.RTC.LossFallback = config.LossFallbackConfig{Action: "restart"}
+		}},
+		{"loss fallback threshold", func(c *config.Config) {
+			c.RTC.LossFallback = config.LossFallbackConfig{Action: config.LossFallbackActionKeyFrame, LossThreshold: 1.5}
+		}},
+		{"loss fallback threshold of one", func(c *config.Config) {
+			c.RTC.LossFallback = config.LossFallbackConfig{Action: config.LossFallbackActionKeyFrame, LossThreshold: 1}
+		}},
+		{"loss fallback duration", func(c *config.Config) {
+			c.RTC.LossFallback = config.LossFallbackConfig{Action: config.LossFallbackActionKeyFrame, Duration: -time.Second}
+		}},
+		{"zero loss threshold", func(c *config.Config) {
+			c.RTC.LossThresholds = []float64{0}
+		}},
+		{"negative loss threshold", func(c *config.Config) {
+			c.RTC.LossThresholds = []float64{-0.1}
+		}},
+		{"full loss threshold", func(c *config.Config) {
+			c.RTC.LossThresholds = []float64{1}
+		}},
+		{"loss threshold too high", func(c *config.Config) {
+			c.RTC.LossThresholds = []float64{1.5}
+		}},
+		{"loss thresholds not increasing", func(c *config.Config) {
+			c.RTC.LossThresholds = []float64{0.1, 0.1}
+		}},
+		{"unsupported opus sample rate", func(c *config.Config) {
+			c.RTC.OpusSampleRates = []uint32{48000, 44100}
+		}},
+		{"duplicate opus sample rate", func(c *config.Config) {
+			c.RTC.OpusSampleRates = []uint32{48000, 16000, 16000}
+		}},
+		{"opus sample rates without 48kHz", func(c *config.Config) {
+			c.RTC.OpusSampleRates = []uint32{16000}
+		}},
+		{"negative dead track timeout", func(c *config.Config) {
+			c.RTC.DeadTrackTimeoutVideo = -time.Second
+		}},
+		{"dead track timeout too short", func(c *config.Config) {
+			c.RTC.DeadTrackTimeoutVideo = time.Nanosecond
+		}},
+		{"dead track timeout under minimum", func(c *config.Config) {
+			c.RTC.DeadTrackTimeoutVideo = sfu.MinDeadTrackTimeout - 1
+		}},
+		{"strict ACKs grace", func(c *config.Config) {
+			c.RTC.StrictACKsGrace = -time.Second
+		}},
+		{"codec matching", func(c *config.Config) {
+			c.RTC.CodecMatching = "exact"
+		}},
+		{"subscription mode", func(c *config.Config) {
+			c.RTC.Subscription = "lazy"
+		}},
+		{"forwarded padding with padding probes", func(c *config.Config) {
+			c.RTC.PaddingPolicy = config.PaddingPolicyForward
+			c.RTC.CongestionControl.UseSendSideBWE = true
+			c.RTC.CongestionControl.ProbeMode = config.CongestionControlProbeModePadding
+		}},
+		{"dropped padding with padding probes", func(c *config.Config) {
+			c.RTC.PaddingPolicy = "drop"
+			c.RTC.CongestionControl.UseSendSideBWE = true
+			c.RTC.CongestionControl.ProbeMode = config.CongestionControlProbeModePadding
+		}},
+		{"keepalive policy", func(c *config.Config) {
+			c.RTC.KeepalivePolicy = "strip"
+		}},
+		{"key frame reorder policy", func(c *config.Config) {
+			c.RTC.KeyFrameReorderPolicy = "wait"
+		}},
+		{"key frame reorder tolerance", func(c *config.Config) {
+			c.RTC.KeyFrameReorderPolicy = config.KeyFrameReorderPolicyDefer
+			c.RTC.KeyFrameReorderTolerance = -1
+		}},
+		{"pinned spatial layer", func(c *config.Config) {
+			c.RTC.PinnedSpatialLayers = map[string]int32{"recorder": -1}
+		}},
+		{"SVC layer cap of audio codec", func(c *config.Config) {
+			c.RTC.SVCLayerCaps = map[string]config.SVCLayerCapConfig{"audio/opus": {MaxSpatialLayer: &zero}}
+		}},
+		{"SVC temporal layer cap too high", func(c *config.Config) {
+			c.RTC.SVCLayerCaps = map[string]config.SVCLayerCapConfig{"video/av1": {MaxTemporalLayer: &tooHigh}}
+		}},
+		{"audio concealment", func(c *config.Config) {
+			c.RTC.AudioConcealment = "dtx"
+		}},
+		{"CPU load limit too high", func(c *config.Config) {
+			c.RTC.CPUAdmissionControl = config.CPUAdmissionControlConfig{CPULoadLimit: 1.5}
+		}},
+		{"negative CPU load limit", func(c *config.Config) {
+			c.RTC.CPUAdmissionControl = config.CPUAdmissionControlConfig{CPULoadLimit: -0.5}
+		}},
+		{"CPU admission retry after", func(c *config.Config) {
+			c.RTC.CPUAdmissionControl = config.CPUAdmissionControlConfig{CPULoadLimit: 0.8, RetryAfter: -time.Second}
+		}},
+		{"DSCP too high", func(c *config.Config) {
+			withICEPortRange(c)
+			c.RTC.DSCP = config.DSCPConfig{Audio: 64}
+		}},
+		{"negative DSCP", func(c *config.Config) {
+			withICEPortRange(c)
+			c.RTC.DSCP = config.DSCPConfig{Audio: 46, Video: -1}
+		}},
+		{"DSCP with UDP mux", func(c *config.Config) {
+			c.RTC.UDPPort.Start = 7882
+			c.RTC.DSCP = config.DSCPConfig{Audio: 46}
+		}},
+		{"DTLS cipher suite", func(c *config.Config) {
+			withICEPortRange(c)
+			c.RTC.DTLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+		}},
+		{"DTLS cipher suites with UDP mux", func(c *config.Config) {
+			c.RTC.UDPPort.Start = 7882
+			c.RTC.DTLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+		}},
+		{"renegotiation limit max offers", func(c *config.Config) {
+			c.RTC.RenegotiationLimit = config.RenegotiationLimitConfig{MaxOffers: -1}
+		}},
+		{"renegotiation limit without window", func(c *config.Config) {
+			c.RTC.RenegotiationLimit = config.RenegotiationLimitConfig{MaxOffers: 10}
+		}},
+		{"codec fallback of audio codec", func(c *config.Config) {
+			c.RTC.CodecFallback = config.CodecFallbackConfig{Chains: map[string][]string{"audio/opus": {"audio/red"}}}
+		}},
+		{"codec fallback to itself", func(c *config.Config) {
+			c.RTC.CodecFallback = config.CodecFallbackConfig{Chains: map[string][]string{"video/av1": {"video/av1"}}}
+		}},
+		{"duplicate codec fallback", func(c *config.Config) {
+			c.RTC.CodecFallback = config.CodecFallbackConfig{Chains: map[string][]string{"video/av1": {"video/vp8", "video/vp8"}}}
+		}},
+		{"codec fallback key frame requests", func(c *config.Config) {
+			c.RTC.CodecFallback = config.CodecFallbackConfig{Chains: map[string][]string{"video/av1": {"video/vp8"}}, KeyFrameRequests: -1}
+		}},
+		{"codec fallback window", func(c *config.Config) {
+			c.RTC.CodecFallback = config.CodecFallbackConfig{Chains: map[string][]string{"video/av1": {"video/vp8"}}, Window: -time.Second}
+		}},
+		{"reordered frame codec", func(c *config.Config) {
+			c.RTC.ReorderedFrameCodecs = []string{"audio/opus"}
+		}},
+		{"negative layer target bitrate", func(c *config.Config) {
+			c.RTC.CongestionControl.LayerTargetBitrates = []int64{-1}
+		}},
+		{"too many layer target bitrates", func(c *config.Config) {
+			c.RTC.CongestionControl.LayerTargetBitrates = []int64{1, 2, 3, 4}
+		}},
+		{"layer switch min dwell", func(c *config.Config) {
+			c.RTC.CongestionControl.LayerSwitchMinDwell = -time.Second
+		}},
+		{"publisher mute policy", func(c *config.Config) {
+			c.RTC.PubMutePolicy = "comfort_noise"
+		}},
+		{"ICE restart policy", func(c *config.Config) {
+			c.RTC.ICERestartPolicy = "always"
+		}},
+		{"max fps source", func(c *config.Config) {
+			c.RTC.MaxFps = map[string]uint32{"window": 5}
+		}},
+		{"negative ramp up factor", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = -1
+		}},
+		{"ramp up factor below one", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = 0.5
+		}},
+		{"ramp up factor of one", func(c *config.Config) {
+			c.RTC.CongestionControl.RampUpFactor = 1
+		}},
+		{"receiver report jitter", func(c *config.Config) {
+			c.RTC.ReceiverReportJitterAudio = -time.Second
+		}},
+		{"priority changes", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChanges = "sometimes"
+		}},
+		{"priority change min interval", func(c *config.Config) {
+			c.RTC.CongestionControl.PriorityChangeMinInterval = -time.Second
+		}},
+		{"max timestamp jump", func(c *config.Config) {
+			c.RTC.MaxTimestampJump = -time.Second
+		}},
+		{"low bandwidth policy", func(c *config.Config) {
+			c.RTC.CongestionControl.LowBandwidthPolicy = "blur"
+		}},
+		{"no RTCP fallback", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPFallback = "ignore"
+		}},
+		{"no RTCP timeout", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPTimeout = -time.Second
+		}},
+		{"no RTCP channel capacity", func(c *config.Config) {
+			c.RTC.CongestionControl.NoRTCPChannelCapacity = -1
+		}},
+		{"initial layer", func(c *config.Config) {
+			c.RTC.CongestionControl.InitialLayer = "middle"
+		}},
+		{"RID mismatch policy", func(c *config.Config) {
+			c.RTC.RIDMismatchPolicy = "create"
+		}},
+		{"empty answer attribute", func(c *config.Config) {
+			c.RTC.AnswerAttributeOrder = []string{"mid", ""}
+		}},
+		{"duplicate answer attribute", func(c *config.Config) {
+			c.RTC.AnswerAttributeOrder = []string{"mid", "rtpmap", "mid"}
+		}},
+		{"SSRC range from zero", func(c *config.Config) {
+			c.RTC.SSRCRangeStart, c.RTC.SSRCRangeEnd = 0, 1999
+		}},
+		{"empty SSRC range", func(c *config.Config) {
+			c.RTC.SSRCRangeStart, c.RTC.SSRCRangeEnd = 2000, 1999
+		}},
+		{"SSRC range to zero", func(c *config.Config) {
+			c.RTC.SSRCRangeStart, c.RTC.SSRCRangeEnd = 1000, 0
+		}},
+		{"negative RTX packet buffer size", func(c *config.Config) {
+			c.RTC.PacketBufferSizeRTX = -1
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := config.NewConfig("", true, nil, nil)
+			require.NoError(t, err)
+			c.RTC.TCPPort = 0
+			tc.update(c)
+			_, err = NewWebRTCConfig(c)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// newLevelLogger creates a logger with the node's logging configuration at the given level
+func newLevelLogger(conf logger.Config, level string) (logger.Logger, error) {
+	conf.Level = level
+	l, err := logger.NewZapLogger(&conf)
+	if err != nil {
+		return nil, err
+	}
+	return l.WithName("livekit"), nil
+}
+
+type connectionLogLevelRule struct {
+	config.ConnectionLogLevelConfig
+	logger logger.Logger
+}
+
+// ConnectionLoggers provides the base logger of a connection, which logs at the level of the first configured
+// connection log level rule matching the connection, or at the level of the node if none matches
+type ConnectionLoggers struct {
+	rules []connectionLogLevelRule
+}
+
+func NewConnectionLoggers(conf *config.Config) (*ConnectionLoggers, error) {
+	c := &ConnectionLoggers{}
+	loggers := make(map[string]logger.Logger)
+	for _, rule := range conf.RTC.ConnectionLogLevels {
+		if _, err := zapcore.ParseLevel(rule.Level); err != nil || rule.Level == "" {
+			return nil, fmt.Errorf("unsupported connection log level %q", rule.Level)
+		}
+		for _, patterns := range [][]string{rule.Regions, rule.SDKs, rule.Versions} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid connection log level pattern %q: %w", pattern, err)
+				}
+			}
+		}
+
+		l, ok := loggers[rule.Level]
+		if !ok {
+			var err error
+			if l, err = newLevelLogger(conf.Logging.Config, rule.Level); err != nil {
+				return nil, err
+			}
+			loggers[rule.Level] = l
+		}
+		c.rules = append(c.rules, connectionLogLevelRule{
+			ConnectionLogLevelConfig: rule,
+			logger:                   l,
+		})
+	}
+	return c, nil
+}
+
+// GetLogger returns the logger of the first rule matching the connection, or the default logger
+func (c *ConnectionLoggers) GetLogger(region string, clientInfo *livekit.ClientInfo) logger.Logger {
+	sdk := strings.ToLower(clientInfo.GetSdk().String())
+	version := clientInfo.GetVersion()
+	for _, rule := range c.rules {
+		if matchesAny(rule.Regions, region) && matchesAny(rule.SDKs, sdk) && matchesAny(rule.Versions, version) {
+			return rule.logger
+		}
+	}
+	return logger.GetLogger()
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// observeLogger returns a logger writing to an observer instead of the output of l, at the level of l
+func observeLogger(t *testing.T, l logger.Logger) (*zap.SugaredLogger, *observer.ObservedLogs) {
+	zl, ok := l.(logger.ZapLogger)
+	require.True(t, ok)
+
+	var logs *observer.ObservedLogs
+	observed := zl.ToZap().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		var observerCore zapcore.Core
+		observerCore, logs = observer.New(core)
+		return observerCore
+	}))
+	return observed, logs
+}
+
+func TestConnectionLoggers(t *testing.T) {
+	conf, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	conf.Logging.Level = "info"
+	conf.RTC.ConnectionLogLevels = []config.ConnectionLogLevelConfig{
+		{Regions: []string{"us-*"}, SDKs: []string{"js"}, Versions: []string{"2.1.*"}, Level: "debug"},
+		{SDKs: []string{"swift"}, Level: "warn"},
+		{SDKs: []string{"android"}, Level: "debug"},
+	}
+	c, err := NewConnectionLoggers(conf)
+	require.NoError(t, err)
+	// one logger per level
+	require.Same(t, c.rules[0].logger, c.rules[2].logger)
+	require.NotSame(t, c.rules[0].logger, c.rules[1].logger)
+
+	t.Run("matching connection logs at the elevated level", func(t *testing.T) {
+		l := c.GetLogger("us-east", &livekit.ClientInfo{Sdk: livekit.ClientInfo_JS, Version: "2.1.5"})
+		require.Same(t, c.rules[0].logger, l)
+		observed, logs := observeLogger(t, l)
+		observed.Debugw("matched")
+		require.Equal(t, 1, logs.FilterMessage("matched").Len())
+		require.Equal(t, zapcore.DebugLevel, logs.All()[0].Level)
+
+		require.Same(t, c.rules[0].logger, c.GetLogger("", &livekit.ClientInfo{Sdk: livekit.ClientInfo_ANDROID}))
+	})
+
+	t.Run("matching connection logs at a lowered level", func(t *testing.T) {
+		l := c.GetLogger("eu-west", &livekit.ClientInfo{Sdk: livekit.ClientInfo_SWIFT})
+		require.Same(t, c.rules[1].logger, l)
+		observed, logs := observeLogger(t, l)
+		observed.Infow("dropped")
+		observed.Warnw("matched", nil)
+		require.Zero(t, logs.FilterMessage("dropped").Len())
+		require.Equal(t, 1, logs.FilterMessage("matched").Len())
+	})
+
+	t.Run("node level drops debug", func(t *testing.T) {
+		// same configuration as the matched logger, apart from the level
+		l, err := newLevelLogger(conf.Logging.Config, conf.Logging.Level)
+		require.NoError(t, err)
+		observed, logs := observeLogger(t, l)
+		observed.Debugw("dropped")
+		observed.Infow("logged")
+		require.Zero(t, logs.FilterMessage("dropped").Len())
+		require.Equal(t, 1, logs.FilterMessage("logged").Len())
+	})
+
+	t.Run("other connections log at the node level", func(t *testing.T) {
+		for _, tc := range []struct {
+			region     string
+			clientInfo *livekit.ClientInfo
+		}{
+			{"eu-west", &livekit.ClientInfo{Sdk: livekit.ClientInfo_JS, Version: "2.1.5"}},
+			{"us-east", &livekit.ClientInfo{Sdk: livekit.ClientInfo_JS, Version: "2.2.0"}},
+			{"us-east", &livekit.ClientInfo{Sdk: livekit.ClientInfo_GO, Version: "2.1.5"}},
+			{"us-east", nil},
+		} {
+			require.Equal(t, logger.GetLogger(), c.GetLogger(tc.region, tc.clientInfo))
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		conf.RTC.ConnectionLogLevels = []config.ConnectionLogLevelConfig{{SDKs: []string{"js"}, Level: "verbose"}}
+		_, err := NewConnectionLoggers(conf)
+		require.Error(t, err)
+
+		conf.RTC.ConnectionLogLevels = []config.ConnectionLogLevelConfig{{SDKs: []string{"js"}}}
+		_, err = NewConnectionLoggers(conf)
+		require.Error(t, err)
+
+		conf.RTC.ConnectionLogLevels = []config.ConnectionLogLevelConfig{{Versions: []string{"[2"}, Level: "debug"}}
+		_, err = NewConnectionLoggers(conf)
+		require.Error(t, err)
+	})
+}
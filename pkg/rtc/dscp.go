@@ -0,0 +1,157 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/pion/transport/v2"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DSCP is a 6 bit field
+const maxDSCP = 63
+
+// DSCPSetter marks packets subsequently sent on the socket with the given DSCP
+type DSCPSetter func(conn transport.UDPConn, dscp uint8) error
+
+// DSCPMarking marks the sockets of each peer connection with the DSCP of the kind of media negotiated on it
+type DSCPMarking struct {
+	Audio uint8
+	Video uint8
+
+	net     transport.Net
+	setDSCP DSCPSetter
+}
+
+func newDSCPMarking(n transport.Net, audio uint8, video uint8, setDSCP DSCPSetter) *DSCPMarking {
+	return &DSCPMarking{
+		Audio:   audio,
+		Video:   video,
+		net:     n,
+		setDSCP: setDSCP,
+	}
+}
+
+// newNet returns the network of the sockets of one peer connection
+func (m *DSCPMarking) newNet() *dscpNet {
+	return &dscpNet{
+		Net:     m.net,
+		marking: m,
+		dscp:    m.Audio,
+		conns:   make(map[*dscpUDPConn]struct{}),
+	}
+}
+
+// dscpNet wraps the UDP sockets pion listens on for a peer connection. Audio and video share the sockets with
+// bundle, so a socket is marked once with the DSCP of the media negotiated on the peer connection, i.e. video
+// when any video is negotiated, instead of per packet. Sockets are marked as audio until then, so that STUN and
+// DTLS, which are small and latency sensitive, are too.
+type dscpNet struct {
+	transport.Net
+	marking *DSCPMarking
+
+	lock  sync.Mutex
+	dscp  uint8
+	conns map[*dscpUDPConn]struct{}
+}
+
+func (n *dscpNet) ListenUDP(network string, locAddr *net.UDPAddr) (transport.UDPConn, error) {
+	conn, err := n.Net.ListenUDP(network, locAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &dscpUDPConn{
+		UDPConn: conn,
+		net:     n,
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	// a socket that cannot be marked still sends
+	_ = n.marking.setDSCP(conn, n.dscp)
+	n.conns[c] = struct{}{}
+	return c, nil
+}
+
+// setVideoNegotiated re-marks the sockets when the kind of media negotiated on the peer connection changes
+func (n *dscpNet) setVideoNegotiated(video bool) {
+	dscp := n.marking.Audio
+	if video {
+		dscp = n.marking.Video
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if dscp == n.dscp {
+		return
+	}
+	n.dscp = dscp
+	for c := range n.conns {
+		_ = n.marking.setDSCP(c.UDPConn, dscp)
+	}
+}
+
+func (n *dscpNet) remove(c *dscpUDPConn) {
+	n.lock.Lock()
+	delete(n.conns, c)
+	n.lock.Unlock()
+}
+
+type dscpUDPConn struct {
+	transport.UDPConn
+	net *dscpNet
+}
+
+func (c *dscpUDPConn) Close() error {
+	c.net.remove(c)
+	return c.UDPConn.Close()
+}
+
+// udpConnWrapper is implemented by sockets wrapping another one, the DSCP is set on the socket they wrap
+type udpConnWrapper interface {
+	unwrapUDPConn() transport.UDPConn
+}
+
+var errNotUDPSocket = errors.New("not a UDP socket")
+
+// setSocketDSCP sets the DSCP bits of the IP header of packets subsequently sent on the socket
+func setSocketDSCP(conn transport.UDPConn, dscp uint8) error {
+	for {
+		w, ok := conn.(udpConnWrapper)
+		if !ok {
+			break
+		}
+		conn = w.unwrapUDPConn()
+	}
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return errNotUDPSocket
+	}
+
+	// DSCP is the upper 6 bits of the traffic class / type of service, the lower 2 are ECN
+	tos := int(dscp) << 2
+	if addr.IP.To4() == nil {
+		return ipv6.NewPacketConn(conn).SetTrafficClass(tos)
+	}
+	return ipv4.NewPacketConn(conn).SetTOS(tos)
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pion/transport/v2"
+	"github.com/pion/transport/v2/stdnet"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv4"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+func TestDSCPMarking(t *testing.T) {
+	n, err := stdnet.NewNet()
+	require.NoError(t, err)
+
+	var marked []uint8
+	m := newDSCPMarking(n, 46, 34, func(_ transport.UDPConn, dscp uint8) error {
+		marked = append(marked, dscp)
+		return nil
+	})
+	dn := m.newNet()
+
+	// sockets are marked as audio until media is negotiated
+	conn, err := dn.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	require.Equal(t, []uint8{46}, marked)
+
+	receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer receiver.Close()
+
+	// packets sent do not change the marking
+	_, err = conn.WriteTo([]byte{0x80, 96, 0x00, 0x01}, receiver.LocalAddr())
+	require.NoError(t, err)
+	require.Equal(t, []uint8{46}, marked)
+
+	// audio only negotiation keeps the marking
+	dn.setVideoNegotiated(false)
+	require.Equal(t, []uint8{46}, marked)
+
+	// sockets are marked once when video is negotiated, and sockets listened on later are marked as video
+	dn.setVideoNegotiated(true)
+	dn.setVideoNegotiated(true)
+	require.Equal(t, []uint8{46, 34}, marked)
+
+	conn2, err := dn.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn2.Close()
+	require.Equal(t, []uint8{46, 34, 34}, marked)
+
+	// closed sockets are not marked
+	require.NoError(t, conn.Close())
+	dn.setVideoNegotiated(false)
+	require.Equal(t, []uint8{46, 34, 34, 46}, marked)
+
+	// peer connections are marked independently
+	m.newNet().setVideoNegotiated(true)
+	require.Equal(t, []uint8{46, 34, 34, 46}, marked)
+}
+
+func TestSetSocketDSCP(t *testing.T) {
+	n, err := stdnet.NewNet()
+	require.NoError(t, err)
+
+	conn, err := n.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, setSocketDSCP(conn, 46))
+	tos, err := ipv4.NewPacketConn(conn).TOS()
+	require.NoError(t, err)
+	require.Equal(t, 46<<2, tos)
+}
+
+func TestSetSocketDSCPWrapped(t *testing.T) {
+	n, err := stdnet.NewNet()
+	require.NoError(t, err)
+
+	conn, err := newDTLSCipherSuiteNet(n, nil, logger.GetLogger()).ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the socket wrapped by the DTLS cipher suite filter is marked
+	require.NoError(t, setSocketDSCP(conn, 34))
+	tos, err := ipv4.NewPacketConn(conn.(*dtlsCipherSuiteUDPConn).UDPConn).TOS()
+	require.NoError(t, err)
+	require.Equal(t, 34<<2, tos)
+}
+
+func TestDSCPConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, withICEPortRange)
+	require.Nil(t, conf.DSCP)
+
+	conf = newTestWebRTCConfig(t, func(c *config.Config) {
+		withICEPortRange(c)
+		c.RTC.DSCP = config.DSCPConfig{Audio: 46, Video: 34}
+	})
+	require.Equal(t, uint8(46), conf.DSCP.Audio)
+	require.Equal(t, uint8(34), conf.DSCP.Video)
+}
@@ -0,0 +1,270 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"encoding/binary"
+	"net"
+
+	"go.uber.org/atomic"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/transport/v2"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// cipher suites pion negotiates with certificates
+var dtlsCipherSuites = map[string]dtls.CipherSuiteID{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": dtls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   dtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   dtls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      dtls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+}
+
+const (
+	dtlsContentTypeAlert     = 21
+	dtlsContentTypeHandshake = 22
+	dtlsRecordHeaderSize     = 13
+	dtlsHandshakeHeaderSize  = 12
+	dtlsRandomSize           = 32
+
+	dtlsHandshakeTypeClientHello = 1
+	dtlsHandshakeTypeServerHello = 2
+
+	dtlsAlertLevelFatal       = 2
+	dtlsAlertHandshakeFailure = 40
+)
+
+// DTLSCipherSuiteFilter fails the DTLS handshakes of each peer connection that cannot use one of the allowed cipher
+// suites. pion does not allow restricting the cipher suites of its DTLS transport, hello messages are inspected
+// instead and replaced by a handshake_failure alert.
+type DTLSCipherSuiteFilter struct {
+	Allowed []dtls.CipherSuiteID
+
+	net transport.Net
+}
+
+func newDTLSCipherSuiteFilter(n transport.Net, allowed []dtls.CipherSuiteID) *DTLSCipherSuiteFilter {
+	return &DTLSCipherSuiteFilter{
+		Allowed: allowed,
+		net:     n,
+	}
+}
+
+// newNet returns the network of the sockets of one peer connection, wrapping the network marking them when not nil
+func (f *DTLSCipherSuiteFilter) newNet(dscp *dscpNet, logger logger.Logger) *dtlsCipherSuiteNet {
+	n := f.net
+	if dscp != nil {
+		n = dscp
+	}
+	return newDTLSCipherSuiteNet(n, f.Allowed, logger)
+}
+
+// dtlsCipherSuiteNet wraps the UDP sockets pion listens on for a peer connection
+type dtlsCipherSuiteNet struct {
+	transport.Net
+
+	allowed []dtls.CipherSuiteID
+	logger  logger.Logger
+}
+
+func newDTLSCipherSuiteNet(n transport.Net, allowed []dtls.CipherSuiteID, logger logger.Logger) *dtlsCipherSuiteNet {
+	return &dtlsCipherSuiteNet{
+		Net:     n,
+		allowed: allowed,
+		logger:  logger,
+	}
+}
+
+func (n *dtlsCipherSuiteNet) ListenUDP(network string, locAddr *net.UDPAddr) (transport.UDPConn, error) {
+	conn, err := n.Net.ListenUDP(network, locAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &dtlsCipherSuiteUDPConn{
+		UDPConn: conn,
+		net:     n,
+	}, nil
+}
+
+// dtlsCipherSuiteUDPConn rejects client hellos that do not offer any of the allowed cipher suites and server hellos,
+// sent or received, that select one that is not allowed. A rejected hello is not passed on, the remote is sent a
+// handshake_failure alert instead and a received one is read as an alert from the remote, so that both ends fail
+// the handshake right away instead of on the handshake timeout.
+type dtlsCipherSuiteUDPConn struct {
+	transport.UDPConn
+	net *dtlsCipherSuiteNet
+
+	// next sequence number of records sent in epoch 0, alerts sent in place of a received hello take it
+	sequenceNumber atomic.Uint64
+}
+
+func (c *dtlsCipherSuiteUDPConn) unwrapUDPConn() transport.UDPConn {
+	return c.UDPConn
+}
+
+func (c *dtlsCipherSuiteUDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.UDPConn.ReadFrom(b)
+	if err != nil || c.isAllowed(b[:n]) {
+		return n, addr, err
+	}
+
+	c.net.logger.Infow("rejecting received DTLS hello without an allowed cipher suite", "remote", addr)
+	if _, err := c.UDPConn.WriteTo(newDTLSHandshakeFailureAlert(b, c.sequenceNumber.Inc()-1), addr); err != nil {
+		c.net.logger.Warnw("could not send DTLS alert", err, "remote", addr)
+	}
+	// the hello is read as an alert from the remote, taking its sequence number
+	alert := newDTLSHandshakeFailureAlert(b, dtlsSequenceNumber(b))
+	return copy(b, alert), addr, nil
+}
+
+func (c *dtlsCipherSuiteUDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if !c.isAllowed(b) {
+		c.net.logger.Infow("rejecting sent DTLS hello without an allowed cipher suite", "remote", addr)
+		// the hello is not sent, the alert takes its sequence number
+		if _, err := c.UDPConn.WriteTo(newDTLSHandshakeFailureAlert(b, dtlsSequenceNumber(b)), addr); err != nil {
+			return 0, err
+		}
+		c.updateSequenceNumber(b)
+		return len(b), nil
+	}
+
+	c.updateSequenceNumber(b)
+	return c.UDPConn.WriteTo(b, addr)
+}
+
+// updateSequenceNumber moves the next sequence number of epoch 0 past the first record of a sent datagram
+func (c *dtlsCipherSuiteUDPConn) updateSequenceNumber(b []byte) {
+	if len(b) < dtlsRecordHeaderSize || !isDTLSRecord(b) || binary.BigEndian.Uint16(b[3:5]) != 0 {
+		return
+	}
+
+	sequenceNumber := dtlsSequenceNumber(b) + 1
+	for {
+		current := c.sequenceNumber.Load()
+		if current >= sequenceNumber || c.sequenceNumber.CAS(current, sequenceNumber) {
+			return
+		}
+	}
+}
+
+func (c *dtlsCipherSuiteUDPConn) isAllowed(b []byte) bool {
+	msgType, suites, ok := parseDTLSHello(b)
+	if !ok {
+		return true
+	}
+	if msgType == dtlsHandshakeTypeClientHello {
+		return slices.ContainsFunc(suites, func(suite dtls.CipherSuiteID) bool {
+			return slices.Contains(c.net.allowed, suite)
+		})
+	}
+	return len(suites) == 1 && slices.Contains(c.net.allowed, suites[0])
+}
+
+// isDTLSRecord demultiplexes DTLS from STUN and RTP by the first byte of the datagram (RFC 7983)
+func isDTLSRecord(b []byte) bool {
+	return len(b) != 0 && b[0] >= 20 && b[0] <= 63
+}
+
+// dtlsSequenceNumber returns the 48 bit sequence number of the first record of the datagram
+func dtlsSequenceNumber(b []byte) uint64 {
+	return uint64(binary.BigEndian.Uint16(b[5:7]))<<32 | uint64(binary.BigEndian.Uint32(b[7:11]))
+}
+
+// newDTLSHandshakeFailureAlert returns a fatal handshake_failure alert record of epoch 0, with the version of the
+// first record of hello
+func newDTLSHandshakeFailureAlert(hello []byte, sequenceNumber uint64) []byte {
+	alert := make([]byte, dtlsRecordHeaderSize+2)
+	alert[0] = dtlsContentTypeAlert
+	copy(alert[1:3], hello[1:3])
+	binary.BigEndian.PutUint16(alert[5:7], uint16(sequenceNumber>>32))
+	binary.BigEndian.PutUint32(alert[7:11], uint32(sequenceNumber))
+	binary.BigEndian.PutUint16(alert[11:13], 2)
+	alert[dtlsRecordHeaderSize] = dtlsAlertLevelFatal
+	alert[dtlsRecordHeaderSize+1] = dtlsAlertHandshakeFailure
+	return alert
+}
+
+// parseDTLSHello returns the cipher suites offered by a client hello or the one selected by a server hello,
+// when the first record of the datagram carries one of them. Other packets, and hellos fragmented before
+// the cipher suites, are not parsed.
+func parseDTLSHello(b []byte) (uint8, []dtls.CipherSuiteID, bool) {
+	if len(b) < dtlsRecordHeaderSize+dtlsHandshakeHeaderSize || b[0] != dtlsContentTypeHandshake {
+		return 0, nil, false
+	}
+	// hellos are sent before keys are negotiated, in epoch 0
+	if binary.BigEndian.Uint16(b[3:5]) != 0 {
+		return 0, nil, false
+	}
+	recordLen := int(binary.BigEndian.Uint16(b[11:13]))
+	record := b[dtlsRecordHeaderSize:]
+	if len(record) > recordLen {
+		record = record[:recordLen]
+	}
+	if len(record) < dtlsHandshakeHeaderSize {
+		return 0, nil, false
+	}
+
+	msgType := record[0]
+	if msgType != dtlsHandshakeTypeClientHello && msgType != dtlsHandshakeTypeServerHello {
+		return 0, nil, false
+	}
+	// only the first fragment has the cipher suites
+	if fragmentOffset := uint32(record[6])<<16 | uint32(record[7])<<8 | uint32(record[8]); fragmentOffset != 0 {
+		return 0, nil, false
+	}
+	fragmentLen := int(uint32(record[9])<<16 | uint32(record[10])<<8 | uint32(record[11]))
+	body := record[dtlsHandshakeHeaderSize:]
+	if len(body) > fragmentLen {
+		body = body[:fragmentLen]
+	}
+
+	// version and random
+	offset := 2 + dtlsRandomSize
+	// session ID
+	if len(body) < offset+1 {
+		return 0, nil, false
+	}
+	offset += 1 + int(body[offset])
+	if msgType == dtlsHandshakeTypeServerHello {
+		if len(body) < offset+2 {
+			return 0, nil, false
+		}
+		return msgType, []dtls.CipherSuiteID{dtls.CipherSuiteID(binary.BigEndian.Uint16(body[offset:]))}, true
+	}
+
+	// cookie
+	if len(body) < offset+1 {
+		return 0, nil, false
+	}
+	offset += 1 + int(body[offset])
+	if len(body) < offset+2 {
+		return 0, nil, false
+	}
+	suitesLen := int(binary.BigEndian.Uint16(body[offset:]))
+	offset += 2
+	if suitesLen%2 != 0 || len(body) < offset+suitesLen {
+		return 0, nil, false
+	}
+	suites := make([]dtls.CipherSuiteID, 0, suitesLen/2)
+	for i := offset; i < offset+suitesLen; i += 2 {
+		suites = append(suites, dtls.CipherSuiteID(binary.BigEndian.Uint16(body[i:])))
+	}
+	return msgType, suites, true
+}
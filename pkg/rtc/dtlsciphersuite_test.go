@@ -0,0 +1,183 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/transport/v2/stdnet"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+func TestDTLSCipherSuiteNet(t *testing.T) {
+	n, err := stdnet.NewNet()
+	require.NoError(t, err)
+	cn := newDTLSCipherSuiteNet(n, []dtls.CipherSuiteID{dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, logger.GetLogger())
+
+	// starts a handshake offering the given suites, returns the first packet received by the wrapped socket and the
+	// result of the handshake of the client
+	handshake := func(t *testing.T, suites ...dtls.CipherSuiteID) ([]byte, chan error) {
+		conn, err := cn.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		defer conn.Close()
+
+		client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+		require.NoError(t, err)
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		clientErr := make(chan error, 1)
+		go func() {
+			_, err := dtls.ClientWithContext(ctx, client, &dtls.Config{
+				CipherSuites:       suites,
+				InsecureSkipVerify: true,
+			})
+			clientErr <- err
+		}()
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(500*time.Millisecond)))
+		b := make([]byte, 1500)
+		n, _, err := conn.ReadFrom(b)
+		require.NoError(t, err)
+		return b[:n], clientErr
+	}
+
+	t.Run("client offering an allowed suite", func(t *testing.T) {
+		b, _ := handshake(t, dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+		msgType, suites, ok := parseDTLSHello(b)
+		require.True(t, ok)
+		require.EqualValues(t, dtlsHandshakeTypeClientHello, msgType)
+		require.Equal(t, []dtls.CipherSuiteID{dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, suites)
+	})
+
+	t.Run("client offering only disallowed suites", func(t *testing.T) {
+		// the hello is read as an alert and the client fails on the alert sent to it, before its handshake timeout
+		b, clientErr := handshake(t, dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA)
+		requireHandshakeFailureAlert(t, b)
+		select {
+		case err := <-clientErr:
+			require.ErrorContains(t, err, "HandshakeFailure")
+		case <-time.After(time.Second):
+			require.Fail(t, "client handshake not failed")
+		}
+	})
+
+	t.Run("server hello", func(t *testing.T) {
+		conn, err := cn.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		defer conn.Close()
+
+		receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		defer receiver.Close()
+
+		read := func(t *testing.T, conn net.PacketConn) []byte {
+			b := make([]byte, 1500)
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(500*time.Millisecond)))
+			n, _, err := conn.ReadFrom(b)
+			require.NoError(t, err)
+			return b[:n]
+		}
+
+		// an allowed suite selected by the local end is sent
+		_, err = conn.WriteTo(serverHello(dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, 0), receiver.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, serverHello(dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, 0), read(t, receiver))
+
+		// a disallowed suite selected by the local end is not sent, an alert is sent in its place
+		n, err := conn.WriteTo(serverHello(dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, 1), receiver.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, len(serverHello(dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, 1)), n)
+		b := read(t, receiver)
+		requireHandshakeFailureAlert(t, b)
+		require.Equal(t, uint64(1), dtlsSequenceNumber(b))
+
+		// a disallowed suite selected by the remote is read as an alert, the remote is sent an alert following the
+		// records sent so far
+		_, err = receiver.WriteTo(serverHello(dtls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA, 5), conn.LocalAddr())
+		require.NoError(t, err)
+		b = read(t, conn)
+		requireHandshakeFailureAlert(t, b)
+		require.Equal(t, uint64(5), dtlsSequenceNumber(b))
+
+		b = read(t, receiver)
+		requireHandshakeFailureAlert(t, b)
+		require.Equal(t, uint64(2), dtlsSequenceNumber(b))
+	})
+
+	t.Run("other packets", func(t *testing.T) {
+		require.True(t, (&dtlsCipherSuiteUDPConn{net: cn}).isAllowed([]byte{0x80, 96, 0x00, 0x01}))
+		// fragment of a hello without the cipher suites
+		require.True(t, (&dtlsCipherSuiteUDPConn{net: cn}).isAllowed(serverHello(dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, 0)[:40]))
+	})
+}
+
+func requireHandshakeFailureAlert(t *testing.T, b []byte) {
+	require.Len(t, b, dtlsRecordHeaderSize+2)
+	require.EqualValues(t, dtlsContentTypeAlert, b[0])
+	require.Equal(t, []byte{dtlsAlertLevelFatal, dtlsAlertHandshakeFailure}, b[dtlsRecordHeaderSize:])
+}
+
+// serverHello returns a DTLS 1.2 record carrying a server hello selecting the given suite
+func serverHello(suite dtls.CipherSuiteID, sequenceNumber uint32) []byte {
+	body := make([]byte, 2+dtlsRandomSize+1+2+1)
+	binary.BigEndian.PutUint16(body, 0xfefd)
+	binary.BigEndian.PutUint16(body[2+dtlsRandomSize+1:], uint16(suite))
+
+	handshake := make([]byte, dtlsHandshakeHeaderSize, dtlsHandshakeHeaderSize+len(body))
+	handshake[0] = dtlsHandshakeTypeServerHello
+	handshake[3] = byte(len(body))
+	handshake[11] = byte(len(body))
+	handshake = append(handshake, body...)
+
+	record := make([]byte, dtlsRecordHeaderSize, dtlsRecordHeaderSize+len(handshake))
+	record[0] = dtlsContentTypeHandshake
+	binary.BigEndian.PutUint16(record[1:], 0xfefd)
+	binary.BigEndian.PutUint32(record[7:], sequenceNumber)
+	binary.BigEndian.PutUint16(record[11:], uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+func TestDTLSCipherSuitesConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, withICEPortRange)
+	require.Nil(t, conf.DTLSCipherSuites)
+
+	conf = newTestWebRTCConfig(t, func(c *config.Config) {
+		withICEPortRange(c)
+		c.RTC.DTLSCipherSuites = []string{"tls_ecdhe_ecdsa_with_aes_128_gcm_sha256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}
+	})
+	require.Equal(t, []dtls.CipherSuiteID{
+		dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		dtls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}, conf.DTLSCipherSuites.Allowed)
+
+	// with DSCP marking
+	conf = newTestWebRTCConfig(t, func(c *config.Config) {
+		withICEPortRange(c)
+		c.RTC.DTLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+		c.RTC.DSCP = config.DSCPConfig{Audio: 46}
+	})
+	require.NotNil(t, conf.DTLSCipherSuites)
+	require.NotNil(t, conf.DSCP)
+}
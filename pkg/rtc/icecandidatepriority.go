@@ -0,0 +1,192 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// ICECandidateOrder decides the type of candidates clients are steered to first
+type ICECandidateOrder int
+
+const (
+	// ICECandidateOrderDefault keeps pion's type preferences and acceptance waits
+	ICECandidateOrderDefault ICECandidateOrder = iota
+	// ICECandidateOrderHostFirst gives host candidate pairs more time to succeed before other types are accepted
+	ICECandidateOrderHostFirst
+	// ICECandidateOrderSrflxFirst prefers server reflexive candidates over host ones
+	ICECandidateOrderSrflxFirst
+)
+
+func (o ICECandidateOrder) String() string {
+	switch o {
+	case ICECandidateOrderDefault:
+		return "DEFAULT"
+	case ICECandidateOrderHostFirst:
+		return "HOST_FIRST"
+	case ICECandidateOrderSrflxFirst:
+		return "SRFLX_FIRST"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+var iceCandidateOrders = map[config.ICECandidateOrder]ICECandidateOrder{
+	config.ICECandidateOrderHostFirst:  ICECandidateOrderHostFirst,
+	config.ICECandidateOrderSrflxFirst: ICECandidateOrderSrflxFirst,
+}
+
+// minimum wait before a candidate pair of each type is accepted by the controlling agent
+type iceAcceptanceMinWaits struct {
+	host, srflx, prflx, relay time.Duration
+}
+
+var iceCandidateOrderAcceptanceMinWaits = map[ICECandidateOrder]iceAcceptanceMinWaits{
+	ICECandidateOrderHostFirst:  {host: 0, srflx: time.Second, prflx: 1500 * time.Millisecond, relay: 3 * time.Second},
+	ICECandidateOrderSrflxFirst: {host: 500 * time.Millisecond, srflx: 0, prflx: time.Second, relay: 2 * time.Second},
+}
+
+// type preferences of local candidates replacing pion's (RFC 8445, section 5.1.2.2) for each order, host candidates
+// are preferred by pion already
+var iceCandidateOrderTypePreferences = map[ICECandidateOrder]map[webrtc.ICECandidateType]uint32{
+	ICECandidateOrderSrflxFirst: {
+		webrtc.ICECandidateTypeSrflx: 126,
+		webrtc.ICECandidateTypeHost:  100,
+	},
+}
+
+// ICECandidatePriorityFunc returns the priority signalled for a local candidate with the given address and type,
+// given the priority computed by pion. Remote agents pair candidates by it, so it decides which of the
+// local candidates a client prefers.
+type ICECandidatePriorityFunc func(address string, typ webrtc.ICECandidateType, priority uint32) uint32
+
+// NewICECandidatePriority ranks local candidates by the type preferences of the order and raises the local
+// preference of candidates gathered on the preferred interfaces, in order of preference, above the ones on other
+// interfaces. The candidate type takes precedence over the interface, e.g. a host candidate on any interface is
+// preferred over a relay candidate. Returns nil when pion's priorities are kept.
+func NewICECandidatePriority(order ICECandidateOrder, preferredInterfaces []string, interfaceOf func(address string) string) ICECandidatePriorityFunc {
+	typePreferences := iceCandidateOrderTypePreferences[order]
+	if len(typePreferences) == 0 && len(preferredInterfaces) == 0 {
+		return nil
+	}
+
+	return func(address string, typ webrtc.ICECandidateType, priority uint32) uint32 {
+		// RFC 8445, section 5.1.2.1
+		// priority = (2^24)*(type preference) + (2^8)*(local preference) + (2^0)*(256 - component ID)
+		typePref := priority >> 24
+		if pref, ok := typePreferences[typ]; ok {
+			typePref = pref
+		}
+
+		localPref := (priority >> 8) & 0xffff
+		if len(preferredInterfaces) != 0 {
+			if idx := slices.Index(preferredInterfaces, interfaceOf(address)); idx >= 0 {
+				localPref = 0xffff - uint32(idx)
+			} else {
+				// halving keeps the relative order of other candidates, below any preferred interface
+				localPref >>= 1
+			}
+		}
+		return typePref<<24 | localPref<<8 | priority&0xff
+	}
+}
+
+// interfaces are listed again when an address is not found, at most this often
+const interfaceAddressesRefreshInterval = 30 * time.Second
+
+// interfaceAddresses maps local addresses to the name of the interface they are assigned to. Interfaces are listed
+// once and again when an address of a candidate is not found, e.g. after an interface came up, instead of for every
+// candidate.
+type interfaceAddresses struct {
+	list func() (map[string]string, error)
+
+	lock     sync.Mutex
+	names    map[string]string
+	listedAt time.Time
+}
+
+func newInterfaceAddresses(list func() (map[string]string, error)) *interfaceAddresses {
+	return &interfaceAddresses{
+		list: list,
+	}
+}
+
+// interfaceOf returns the name of the local interface the address is assigned to, empty if it is not assigned to any
+func (a *interfaceAddresses) interfaceOf(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if name, ok := a.names[ip.String()]; ok {
+		return name
+	}
+	if !a.listedAt.IsZero() && time.Since(a.listedAt) < interfaceAddressesRefreshInterval {
+		return ""
+	}
+
+	a.listedAt = time.Now()
+	names, err := a.list()
+	if err != nil {
+		return ""
+	}
+	a.names = names
+	return a.names[ip.String()]
+}
+
+// listInterfaceAddresses returns the names of the local interfaces by the addresses assigned to them
+func listInterfaceAddresses() (map[string]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				names[ipNet.IP.String()] = iface.Name
+			}
+		}
+	}
+	return names, nil
+}
+
+// setCandidatePriority replaces the priority of an SDP candidate attribute value,
+// i.e. <foundation> <component> <transport> <priority> <address> <port> typ <type> ...
+func setCandidatePriority(value string, priority uint32) string {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return value
+	}
+	fields[3] = strconv.FormatUint(uint64(priority), 10)
+	return strings.Join(fields, " ")
+}
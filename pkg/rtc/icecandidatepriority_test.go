@@ -0,0 +1,198 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// candidatePriority returns the priority signalled for a candidate gathered by pion
+func candidatePriority(t *testing.T, priority ICECandidatePriorityFunc, c ice.Candidate) uint32 {
+	typ, err := webrtc.NewICECandidateType(c.Type().String())
+	require.NoError(t, err)
+	return priority(c.Address(), typ, c.Priority())
+}
+
+func newHostCandidate(t *testing.T, address string, network string, tcpType ice.TCPType) ice.Candidate {
+	c, err := ice.NewCandidateHost(&ice.CandidateHostConfig{
+		Network:   network,
+		Address:   address,
+		Port:      7882,
+		Component: 1,
+		TCPType:   tcpType,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func newSrflxCandidate(t *testing.T, address string) ice.Candidate {
+	c, err := ice.NewCandidateServerReflexive(&ice.CandidateServerReflexiveConfig{
+		Network:   "udp",
+		Address:   address,
+		Port:      7882,
+		Component: 1,
+		RelAddr:   "10.0.0.1",
+		RelPort:   7882,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestICECandidatePriorityPreferredInterfaces(t *testing.T) {
+	interfaces := map[string]string{
+		"10.0.0.1":    "eth0",
+		"192.168.0.1": "eth1",
+		"172.16.0.1":  "eth2",
+	}
+	priority := NewICECandidatePriority(ICECandidateOrderDefault, []string{"eth1", "eth2"}, func(address string) string {
+		return interfaces[address]
+	})
+
+	eth0 := candidatePriority(t, priority, newHostCandidate(t, "10.0.0.1", "udp", ice.TCPTypeUnspecified))
+	eth1 := candidatePriority(t, priority, newHostCandidate(t, "192.168.0.1", "udp", ice.TCPTypeUnspecified))
+	eth2 := candidatePriority(t, priority, newHostCandidate(t, "172.16.0.1", "udp", ice.TCPTypeUnspecified))
+	require.Greater(t, eth1, eth2)
+	require.Greater(t, eth2, eth0)
+
+	// type and component are kept, only the local preference changes
+	require.Equal(t, eth0&0xff0000ff, eth1&0xff0000ff)
+
+	// other candidates keep their relative order
+	eth0TCP := candidatePriority(t, priority, newHostCandidate(t, "10.0.0.1", "tcp", ice.TCPTypePassive))
+	require.Greater(t, eth0, eth0TCP)
+
+	// a tcp candidate on a preferred interface does not overtake udp
+	eth1TCP := candidatePriority(t, priority, newHostCandidate(t, "192.168.0.1", "tcp", ice.TCPTypePassive))
+	require.Greater(t, eth0, eth1TCP)
+	require.Greater(t, eth1TCP, eth0TCP)
+
+	// a srflx candidate, whose address is not local, does not overtake host candidates
+	srflx := candidatePriority(t, priority, newSrflxCandidate(t, "203.0.113.1"))
+	require.Greater(t, eth0TCP, srflx)
+}
+
+func TestICECandidatePriorityOrder(t *testing.T) {
+	host := newHostCandidate(t, "10.0.0.1", "udp", ice.TCPTypeUnspecified)
+	srflx := newSrflxCandidate(t, "203.0.113.1")
+
+	// pion's priorities already prefer host candidates
+	require.Nil(t, NewICECandidatePriority(ICECandidateOrderDefault, nil, nil))
+	require.Nil(t, NewICECandidatePriority(ICECandidateOrderHostFirst, nil, nil))
+	require.Greater(t, host.Priority(), srflx.Priority())
+
+	priority := NewICECandidatePriority(ICECandidateOrderSrflxFirst, nil, nil)
+	require.Greater(t, candidatePriority(t, priority, srflx), candidatePriority(t, priority, host))
+
+	// with preferred interfaces, the type still takes precedence
+	priority = NewICECandidatePriority(ICECandidateOrderSrflxFirst, []string{"eth0"}, func(address string) string {
+		if address == "10.0.0.1" {
+			return "eth0"
+		}
+		return ""
+	})
+	require.Greater(t, candidatePriority(t, priority, srflx), candidatePriority(t, priority, host))
+}
+
+func TestICECandidateOrderAcceptanceMinWaits(t *testing.T) {
+	// pairs of the type tried first are accepted right away, relay ones last
+	waits := iceCandidateOrderAcceptanceMinWaits[ICECandidateOrderHostFirst]
+	require.Zero(t, waits.host)
+	require.Less(t, waits.host, waits.srflx)
+	require.Less(t, waits.srflx, waits.relay)
+
+	waits = iceCandidateOrderAcceptanceMinWaits[ICECandidateOrderSrflxFirst]
+	require.Zero(t, waits.srflx)
+	require.Less(t, waits.srflx, waits.host)
+	require.Less(t, waits.host, waits.relay)
+}
+
+func TestInterfaceAddresses(t *testing.T) {
+	numListed := 0
+	names := map[string]string{"10.0.0.1": "eth0"}
+	var listErr error
+	a := newInterfaceAddresses(func() (map[string]string, error) {
+		numListed++
+		return names, listErr
+	})
+
+	// interfaces are listed once for addresses found
+	require.Equal(t, "eth0", a.interfaceOf("10.0.0.1"))
+	require.Equal(t, "eth0", a.interfaceOf("10.0.0.1"))
+	require.Equal(t, 1, numListed)
+	require.Empty(t, a.interfaceOf("not an address"))
+	require.Equal(t, 1, numListed)
+
+	// an address not found does not list again right away
+	names = map[string]string{"10.0.0.1": "eth0", "192.168.0.1": "eth1"}
+	require.Empty(t, a.interfaceOf("192.168.0.1"))
+	require.Equal(t, 1, numListed)
+
+	// but once the interfaces are old enough
+	a.listedAt = a.listedAt.Add(-interfaceAddressesRefreshInterval)
+	require.Equal(t, "eth1", a.interfaceOf("192.168.0.1"))
+	require.Equal(t, 2, numListed)
+
+	// failing to list keeps the addresses found before
+	listErr = errors.New("listing failed")
+	a.listedAt = a.listedAt.Add(-interfaceAddressesRefreshInterval)
+	require.Empty(t, a.interfaceOf("172.16.0.1"))
+	require.Equal(t, 3, numListed)
+	require.Equal(t, "eth0", a.interfaceOf("10.0.0.1"))
+
+	// addresses of the interfaces of the node are found
+	names, err := listInterfaceAddresses()
+	require.NoError(t, err)
+	require.NotEmpty(t, names["127.0.0.1"])
+}
+
+func TestSetCandidatePriority(t *testing.T) {
+	value := "1 1 udp 2130706431 192.168.0.1 7882 typ host"
+	require.Equal(t, "1 1 udp 42 192.168.0.1 7882 typ host", setCandidatePriority(value, 42))
+
+	c, err := ice.UnmarshalCandidate(setCandidatePriority(value, 42))
+	require.NoError(t, err)
+	require.Equal(t, uint32(42), c.Priority())
+}
+
+func TestICEPreference(t *testing.T) {
+	host := newHostCandidate(t, "10.0.0.1", "udp", ice.TCPTypeUnspecified)
+	srflx := newSrflxCandidate(t, "203.0.113.1")
+
+	conf := newTestWebRTCConfig(t, nil)
+	require.Nil(t, conf.ICECandidatePriority)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICEPreference.CandidateOrder = config.ICECandidateOrderHostFirst
+	})
+	require.Nil(t, conf.ICECandidatePriority)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICEPreference.CandidateOrder = "Srflx_First"
+	})
+	require.Greater(t, candidatePriority(t, conf.ICECandidatePriority, srflx), candidatePriority(t, conf.ICECandidatePriority, host))
+
+	// candidates on other interfaces are lowered below the preferred one
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.ICEPreference.Interfaces = []string{"eth1"}
+	})
+	require.Less(t, candidatePriority(t, conf.ICECandidatePriority, host), host.Priority())
+}
@@ -35,6 +35,19 @@ var opusCodecCapability = webrtc.RTPCodecCapability{
 	Channels:    2,
 	SDPFmtpLine: "minptime=10;useinbandfec=1",
 }
+
+// sample rates supported by opus (RFC 6716), signalled as maximum playback and capture rates (RFC 7587),
+// the RTP clock rate of opus is always 48000
+var supportedOpusSampleRates = []uint32{8000, 12000, 16000, 24000, 48000}
+
+// payload types of opus signalling sample rates other than 48000
+var opusPayloadTypes = map[uint32]webrtc.PayloadType{
+	24000: 112,
+	16000: 113,
+	12000: 114,
+	8000:  115,
+}
+
 var redCodecCapability = webrtc.RTPCodecCapability{
 	MimeType:    sfu.MimeTypeAudioRed,
 	ClockRate:   48000,
@@ -46,22 +59,62 @@ var videoRTX = webrtc.RTPCodecCapability{
 	ClockRate: 90000,
 }
 
-func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedback RTCPFeedbackConfig, filterOutH264HighProfile bool) error {
+// redFmtpLine returns the RED format parameters carrying the given number of redundant opus encodings, RFC 2198
+func redFmtpLine(opusPayload webrtc.PayloadType, redDistance int) string {
+	pts := make([]string, redDistance+1)
+	for i := range pts {
+		pts[i] = fmt.Sprintf("%d", opusPayload)
+	}
+	return strings.Join(pts, "/")
+}
+
+// opusSampleRateFmtpLine adds the maximum sample rate the SFU receives and sends to opus format parameters, RFC 7587
+func opusSampleRateFmtpLine(fmtpLine string, sampleRate uint32) string {
+	return fmt.Sprintf("%s;maxplaybackrate=%d;sprop-maxcapturerate=%d", fmtpLine, sampleRate, sampleRate)
+}
+
+// codecRegistrar is satisfied by *webrtc.MediaEngine
+type codecRegistrar interface {
+	RegisterCodec(codec webrtc.RTPCodecParameters, typ webrtc.RTPCodecType) error
+}
+
+func registerCodecs(me codecRegistrar, codecs []*livekit.Codec, rtcpFeedback RTCPFeedbackConfig, redDistance int, opusSampleRates []uint32, filterOutH264HighProfile bool) error {
 	opusCodec := opusCodecCapability
-	opusCodec.RTCPFeedback = rtcpFeedback.Audio
+	opusCodec.RTCPFeedback = rtcpFeedback.ForCodec(opusCodec.MimeType)
 	var opusPayload webrtc.PayloadType
 	if IsCodecEnabled(codecs, opusCodec) {
 		opusPayload = 111
-		if err := me.RegisterCodec(webrtc.RTPCodecParameters{
-			RTPCodecCapability: opusCodec,
-			PayloadType:        opusPayload,
-		}, webrtc.RTPCodecTypeAudio); err != nil {
-			return err
+		if len(opusSampleRates) == 0 {
+			if err := me.RegisterCodec(webrtc.RTPCodecParameters{
+				RTPCodecCapability: opusCodec,
+				PayloadType:        opusPayload,
+			}, webrtc.RTPCodecTypeAudio); err != nil {
+				return err
+			}
+		}
+		// registered in order of preference, offers list them in that order. A client signalling a lower
+		// maxplaybackrate matches the payload type of that rate, others match the first one
+		for _, sampleRate := range opusSampleRates {
+			codec := webrtc.RTPCodecParameters{
+				RTPCodecCapability: opusCodec,
+				PayloadType:        opusPayload,
+			}
+			if sampleRate != opusCodec.ClockRate {
+				codec.PayloadType = opusPayloadTypes[sampleRate]
+			}
+			codec.SDPFmtpLine = opusSampleRateFmtpLine(opusCodec.SDPFmtpLine, sampleRate)
+			if err := me.RegisterCodec(codec, webrtc.RTPCodecTypeAudio); err != nil {
+				return err
+			}
 		}
 
 		if IsCodecEnabled(codecs, redCodecCapability) {
+			redCodec := redCodecCapability
+			if redDistance > 0 {
+				redCodec.SDPFmtpLine = redFmtpLine(opusPayload, redDistance)
+			}
 			if err := me.RegisterCodec(webrtc.RTPCodecParameters{
-				RTPCodecCapability: redCodecCapability,
+				RTPCodecCapability: redCodec,
 				PayloadType:        63,
 			}, webrtc.RTPCodecTypeAudio); err != nil {
 				return err
@@ -72,65 +125,59 @@ func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedbac
 	rtxEnabled := IsCodecEnabled(codecs, videoRTX)
 
 	h264HighProfileFmtp := "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032"
+	// registered in order of preference
 	for _, codec := range []webrtc.RTPCodecParameters{
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeVP8,
-				ClockRate:    90000,
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:  webrtc.MimeTypeVP8,
+				ClockRate: 90000,
 			},
 			PayloadType: 96,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeVP9,
-				ClockRate:    90000,
-				SDPFmtpLine:  "profile-id=0",
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:    webrtc.MimeTypeVP9,
+				ClockRate:   90000,
+				SDPFmtpLine: "profile-id=0",
 			},
 			PayloadType: 98,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeVP9,
-				ClockRate:    90000,
-				SDPFmtpLine:  "profile-id=1",
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:    webrtc.MimeTypeVP9,
+				ClockRate:   90000,
+				SDPFmtpLine: "profile-id=1",
 			},
 			PayloadType: 100,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeH264,
-				ClockRate:    90000,
-				SDPFmtpLine:  "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:    webrtc.MimeTypeH264,
+				ClockRate:   90000,
+				SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
 			},
 			PayloadType: 125,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeH264,
-				ClockRate:    90000,
-				SDPFmtpLine:  "level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f",
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:    webrtc.MimeTypeH264,
+				ClockRate:   90000,
+				SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f",
 			},
 			PayloadType: 108,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeH264,
-				ClockRate:    90000,
-				SDPFmtpLine:  h264HighProfileFmtp,
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:    webrtc.MimeTypeH264,
+				ClockRate:   90000,
+				SDPFmtpLine: h264HighProfileFmtp,
 			},
 			PayloadType: 123,
 		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{
-				MimeType:     webrtc.MimeTypeAV1,
-				ClockRate:    90000,
-				RTCPFeedback: rtcpFeedback.Video,
+				MimeType:  webrtc.MimeTypeAV1,
+				ClockRate: 90000,
 			},
 			PayloadType: 35,
 		},
@@ -142,6 +189,7 @@ func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedbac
 			continue
 		}
 		if IsCodecEnabled(codecs, codec.RTPCodecCapability) {
+			codec.RTCPFeedback = rtcpFeedback.ForCodec(codec.MimeType)
 			if err := me.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
 				return err
 			}
@@ -180,7 +228,7 @@ func registerHeaderExtensions(me *webrtc.MediaEngine, rtpHeaderExtension RTPHead
 
 func createMediaEngine(codecs []*livekit.Codec, config DirectionConfig, filterOutH264HighProfile bool) (*webrtc.MediaEngine, error) {
 	me := &webrtc.MediaEngine{}
-	if err := registerCodecs(me, codecs, config.RTCPFeedback, filterOutH264HighProfile); err != nil {
+	if err := registerCodecs(me, codecs, config.RTCPFeedback, config.RedDistance, config.OpusSampleRates, filterOutH264HighProfile); err != nil {
 		return nil, err
 	}
 
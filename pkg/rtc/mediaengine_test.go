@@ -15,11 +15,18 @@
 package rtc
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/livekit-server/pkg/sfu/utils"
 	"github.com/livekit/protocol/livekit"
 )
 
@@ -38,3 +45,550 @@ func TestIsCodecEnabled(t *testing.T) {
 		require.False(t, IsCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}))
 	})
 }
+
+func TestOpusSampleRates(t *testing.T) {
+	// subscriber connection, offered by the server, answered by a client choosing one of the opus payload types
+	negotiate := func(t *testing.T, payloadType uint8, fmtpLine string) []webrtc.RTPCodecParameters {
+		serverME, err := createMediaEngine(testEnabledCodecs, DirectionConfig{OpusSampleRates: []uint32{48000, 16000}}, true)
+		require.NoError(t, err)
+		server, err := webrtc.NewAPI(webrtc.WithMediaEngine(serverME)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		t.Cleanup(func() { server.Close() })
+
+		track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "stream")
+		require.NoError(t, err)
+		sender, err := server.AddTrack(track)
+		require.NoError(t, err)
+
+		offer, err := server.CreateOffer(nil)
+		require.NoError(t, err)
+		require.NoError(t, server.SetLocalDescription(offer))
+		// both rates are opus/48000/2, RFC 7587
+		require.Contains(t, offer.SDP, "a=rtpmap:111 opus/48000/2")
+		require.Contains(t, offer.SDP, "a=fmtp:111 minptime=10;useinbandfec=1;maxplaybackrate=48000;sprop-maxcapturerate=48000")
+		require.Contains(t, offer.SDP, "a=rtpmap:113 opus/48000/2")
+		require.Contains(t, offer.SDP, "a=fmtp:113 minptime=10;useinbandfec=1;maxplaybackrate=16000;sprop-maxcapturerate=16000")
+		require.NotContains(t, offer.SDP, "opus/16000")
+		require.Less(t, strings.Index(offer.SDP, "a=rtpmap:111"), strings.Index(offer.SDP, "a=rtpmap:113"))
+
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		for _, md := range parsed.MediaDescriptions {
+			if md.MediaName.Media != "audio" {
+				continue
+			}
+			attrs := make([]sdp.Attribute, 0, len(md.Attributes))
+			for _, attr := range md.Attributes {
+				switch attr.Key {
+				case "rtpmap", "fmtp", "rtcp-fb":
+					continue
+				case "sendrecv":
+					attr.Key = "recvonly"
+				}
+				attrs = append(attrs, attr)
+			}
+			md.MediaName.Formats = []string{fmt.Sprint(payloadType)}
+			md.Attributes = append(attrs,
+				sdp.Attribute{Key: "rtpmap", Value: fmt.Sprintf("%d opus/48000/2", payloadType)},
+				sdp.Attribute{Key: "fmtp", Value: fmt.Sprintf("%d %s", payloadType, fmtpLine)},
+			)
+		}
+		answerSDP, err := parsed.Marshal()
+		require.NoError(t, err)
+		answer := webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: string(answerSDP)}
+		require.NoError(t, server.SetRemoteDescription(answer))
+
+		return sender.GetParameters().Codecs
+	}
+
+	upstream := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: "minptime=10;useinbandfec=1",
+		},
+		PayloadType: 111,
+	}
+
+	t.Run("client limited to 16kHz", func(t *testing.T) {
+		negotiated := negotiate(t, 113, "minptime=10;useinbandfec=1;maxplaybackrate=16000")
+		require.Len(t, negotiated, 1)
+		require.EqualValues(t, 113, negotiated[0].PayloadType)
+		require.Contains(t, negotiated[0].SDPFmtpLine, "maxplaybackrate=16000")
+
+		// forwarded as is, clock rate is the published one
+		codec, err := utils.CodecParametersFuzzySearch(upstream, negotiated)
+		require.NoError(t, err)
+		require.EqualValues(t, 48000, codec.ClockRate)
+		require.EqualValues(t, 113, codec.PayloadType)
+	})
+
+	t.Run("client not signalling a rate", func(t *testing.T) {
+		negotiated := negotiate(t, 111, "minptime=10;useinbandfec=1")
+		require.Len(t, negotiated, 1)
+		require.EqualValues(t, 111, negotiated[0].PayloadType)
+
+		codec, err := utils.CodecParametersFuzzySearch(upstream, negotiated)
+		require.NoError(t, err)
+		require.EqualValues(t, 48000, codec.ClockRate)
+		require.EqualValues(t, 111, codec.PayloadType)
+	})
+
+	t.Run("not signalled by default", func(t *testing.T) {
+		recorder := &codecRecorder{}
+		require.NoError(t, registerCodecs(recorder, testEnabledCodecs, RTCPFeedbackConfig{}, 0, nil, true))
+		for _, codec := range recorder.codecs {
+			require.NotContains(t, codec.SDPFmtpLine, "maxplaybackrate")
+		}
+	})
+}
+
+// negotiate has a remote peer offering a send only track of the given kind, with the given header extensions,
+// and returns the answer generated using the direction config
+func negotiate(t *testing.T, kind webrtc.RTPCodecType, offeredExtensions []string, directionConfig DirectionConfig) *sdp.MediaDescription {
+	offererME := &webrtc.MediaEngine{}
+	require.NoError(t, offererME.RegisterDefaultCodecs())
+	for _, ext := range offeredExtensions {
+		require.NoError(t, offererME.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: ext}, kind))
+	}
+	offerer, err := webrtc.NewAPI(webrtc.WithMediaEngine(offererME)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer offerer.Close()
+
+	answererME, err := createMediaEngine(testEnabledCodecs, directionConfig, false)
+	require.NoError(t, err)
+	answerer, err := webrtc.NewAPI(webrtc.WithMediaEngine(answererME)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer answerer.Close()
+
+	_, err = offerer.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+	require.NoError(t, err)
+	offer, err := offerer.CreateOffer(nil)
+	require.NoError(t, err)
+	require.NoError(t, answerer.SetRemoteDescription(offer))
+	answer, err := answerer.CreateAnswer(nil)
+	require.NoError(t, err)
+
+	parsed, err := answer.Unmarshal()
+	require.NoError(t, err)
+	for _, md := range parsed.MediaDescriptions {
+		if md.MediaName.Media == kind.String() {
+			return md
+		}
+	}
+	require.Fail(t, "no media section in answer", "kind", kind.String())
+	return nil
+}
+
+func extensionURIs(md *sdp.MediaDescription) []string {
+	var uris []string
+	for _, attr := range md.Attributes {
+		if attr.Key != sdp.AttrKeyExtMap {
+			continue
+		}
+		if parts := strings.Fields(attr.Value); len(parts) >= 2 {
+			uris = append(uris, parts[1])
+		}
+	}
+	return uris
+}
+
+func TestBlockedHeaderExtensions(t *testing.T) {
+	blocked := []string{dd.ExtensionURI, sdp.AudioLevelURI}
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.BlockedHeaderExtensions = blocked
+	})
+
+	for _, dc := range []DirectionConfig{conf.Publisher, conf.Subscriber} {
+		for _, uri := range blocked {
+			require.NotContains(t, dc.RTPHeaderExtension.Video, uri)
+			require.NotContains(t, dc.RTPHeaderExtension.Audio, uri)
+		}
+	}
+	// others are untouched
+	require.Contains(t, conf.Publisher.RTPHeaderExtension.Video, sdp.TransportCCURI)
+	require.Contains(t, conf.Publisher.RTPHeaderExtension.Audio, sdp.SDESMidURI)
+
+	t.Run("blocked extension offered by peer is not negotiated", func(t *testing.T) {
+		md := negotiate(t, webrtc.RTPCodecTypeVideo, []string{sdp.SDESMidURI, dd.ExtensionURI}, conf.Publisher)
+		uris := extensionURIs(md)
+		require.Contains(t, uris, sdp.SDESMidURI)
+		require.NotContains(t, uris, dd.ExtensionURI)
+
+		md = negotiate(t, webrtc.RTPCodecTypeAudio, []string{sdp.SDESMidURI, sdp.AudioLevelURI}, conf.Publisher)
+		uris = extensionURIs(md)
+		require.Contains(t, uris, sdp.SDESMidURI)
+		require.NotContains(t, uris, sdp.AudioLevelURI)
+	})
+
+	t.Run("not blocked by default", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		md := negotiate(t, webrtc.RTPCodecTypeVideo, []string{sdp.SDESMidURI, dd.ExtensionURI}, conf.Publisher)
+		require.Contains(t, extensionURIs(md), dd.ExtensionURI)
+	})
+}
+
+func TestPublisherAbsSendTime(t *testing.T) {
+	offered := []string{sdp.SDESMidURI, sdp.TransportCCURI, sdp.ABSSendTimeURI}
+
+	t.Run("disabled", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		require.NotContains(t, conf.Publisher.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+
+		uris := extensionURIs(negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Publisher))
+		require.Contains(t, uris, sdp.TransportCCURI)
+		require.NotContains(t, uris, sdp.ABSSendTimeURI)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.PublisherAbsSendTime = true
+		})
+		require.Contains(t, conf.Publisher.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+		require.Contains(t, conf.Publisher.RTPHeaderExtension.Video, sdp.TransportCCURI)
+		require.NotContains(t, conf.Publisher.RTPHeaderExtension.Audio, sdp.ABSSendTimeURI)
+
+		md := negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Publisher)
+		uris := extensionURIs(md)
+		require.Contains(t, uris, sdp.TransportCCURI)
+		require.Contains(t, uris, sdp.ABSSendTimeURI)
+
+		// both extensions get distinct ids
+		ids := make(map[string]string)
+		for _, attr := range md.Attributes {
+			if attr.Key != sdp.AttrKeyExtMap {
+				continue
+			}
+			if parts := strings.Fields(attr.Value); len(parts) >= 2 {
+				require.NotContains(t, ids, parts[0])
+				ids[parts[0]] = parts[1]
+			}
+		}
+	})
+}
+
+func TestAudioRedDistance(t *testing.T) {
+	redFmtp := func(t *testing.T, dc DirectionConfig) string {
+		me, err := createMediaEngine([]*livekit.Codec{
+			{Mime: webrtc.MimeTypeOpus},
+			{Mime: sfu.MimeTypeAudioRed},
+		}, dc, true)
+		require.NoError(t, err)
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		for _, md := range parsed.MediaDescriptions {
+			for _, attr := range md.Attributes {
+				if attr.Key == "fmtp" && strings.HasPrefix(attr.Value, "63 ") {
+					return strings.TrimPrefix(attr.Value, "63 ")
+				}
+			}
+		}
+		require.Fail(t, "no red fmtp in offer")
+		return ""
+	}
+
+	t.Run("default", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		require.Equal(t, "111/111", redFmtp(t, conf.Subscriber))
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.AudioRedDistance = 3
+		})
+		require.Equal(t, 3, conf.Receiver.AudioRedDistance)
+		require.Equal(t, "111/111/111/111", redFmtp(t, conf.Subscriber))
+		require.Equal(t, "111/111/111/111", redFmtp(t, conf.Publisher))
+	})
+}
+
+func TestFixedBitrate(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.FixedBitrate = true
+	})
+
+	offered := []string{sdp.SDESMidURI, sdp.TransportCCURI, sdp.ABSSendTimeURI}
+	for name, dc := range map[string]DirectionConfig{
+		"publisher":  conf.Publisher,
+		"subscriber": conf.Subscriber,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, fb := range dc.RTCPFeedback.Video {
+				require.NotEqual(t, webrtc.TypeRTCPFBTransportCC, fb.Type)
+				require.NotEqual(t, webrtc.TypeRTCPFBGoogREMB, fb.Type)
+			}
+
+			md := negotiate(t, webrtc.RTPCodecTypeVideo, offered, dc)
+			uris := extensionURIs(md)
+			require.NotContains(t, uris, sdp.TransportCCURI)
+			require.NotContains(t, uris, sdp.ABSSendTimeURI)
+			for _, attr := range md.Attributes {
+				if attr.Key != "rtcp-fb" {
+					continue
+				}
+				require.NotContains(t, attr.Value, webrtc.TypeRTCPFBTransportCC)
+				require.NotContains(t, attr.Value, webrtc.TypeRTCPFBGoogREMB)
+			}
+		})
+	}
+}
+
+func TestAlwaysTransportCC(t *testing.T) {
+	countFeedback := func(dc DirectionConfig, typ string) int {
+		count := 0
+		for _, fb := range dc.RTCPFeedback.Video {
+			if fb.Type == typ {
+				count++
+			}
+		}
+		return count
+	}
+
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+	})
+	require.Zero(t, countFeedback(conf.Subscriber, webrtc.TypeRTCPFBTransportCC))
+	require.NotContains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.TransportCCURI)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+		conf.RTC.CongestionControl.AlwaysTransportCC = true
+	})
+	require.Equal(t, 1, countFeedback(conf.Subscriber, webrtc.TypeRTCPFBGoogREMB))
+	require.Equal(t, 1, countFeedback(conf.Subscriber, webrtc.TypeRTCPFBTransportCC))
+
+	// both are negotiated, REMB stays the estimator
+	offered := []string{sdp.SDESMidURI, sdp.TransportCCURI, sdp.ABSSendTimeURI}
+	md := negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Subscriber)
+	uris := extensionURIs(md)
+	require.Contains(t, uris, sdp.TransportCCURI)
+	require.Contains(t, uris, sdp.ABSSendTimeURI)
+	var hasREMB, hasTransportCC bool
+	for _, attr := range md.Attributes {
+		if attr.Key != "rtcp-fb" {
+			continue
+		}
+		hasREMB = hasREMB || strings.Contains(attr.Value, webrtc.TypeRTCPFBGoogREMB)
+		hasTransportCC = hasTransportCC || strings.Contains(attr.Value, webrtc.TypeRTCPFBTransportCC)
+	}
+	require.True(t, hasREMB)
+	require.True(t, hasTransportCC)
+
+	// not added twice with send side estimation
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = true
+		conf.RTC.CongestionControl.AlwaysTransportCC = true
+	})
+	require.Zero(t, countFeedback(conf.Subscriber, webrtc.TypeRTCPFBGoogREMB))
+	require.Equal(t, 1, countFeedback(conf.Subscriber, webrtc.TypeRTCPFBTransportCC))
+	numTransportCCURIs := 0
+	for _, uri := range conf.Subscriber.RTPHeaderExtension.Video {
+		if uri == sdp.TransportCCURI {
+			numTransportCCURIs++
+		}
+	}
+	require.Equal(t, 1, numTransportCCURIs)
+}
+
+func TestDisablePacingFallback(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+	})
+	require.Contains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+
+	offered := []string{sdp.SDESMidURI, sdp.TransportCCURI, sdp.ABSSendTimeURI}
+	md := negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Subscriber)
+	require.Contains(t, extensionURIs(md), sdp.ABSSendTimeURI)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+		conf.RTC.CongestionControl.DisablePacingFallback = true
+	})
+	require.NotContains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+	// still estimated with REMB
+	hasREMB := false
+	for _, fb := range conf.Subscriber.RTCPFeedback.Video {
+		hasREMB = hasREMB || fb.Type == webrtc.TypeRTCPFBGoogREMB
+	}
+	require.True(t, hasREMB)
+
+	md = negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Subscriber)
+	require.NotContains(t, extensionURIs(md), sdp.ABSSendTimeURI)
+
+	// not used with send side estimation either way
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = true
+	})
+	require.NotContains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+}
+
+func TestTwoByteHeaderExtensions(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.TwoByteHeaderExtensions = true
+	})
+	for i := conf.Publisher.RTPHeaderExtension.NumUnique(); i <= maxOneByteHeaderExtensions+2; i++ {
+		conf.Publisher.RTPHeaderExtension.Video = append(conf.Publisher.RTPHeaderExtension.Video, fmt.Sprintf("urn:test:ext:%d", i))
+	}
+	require.NoError(t, conf.Validate())
+	offered := conf.Publisher.RTPHeaderExtension.Video
+	require.Greater(t, len(offered), maxOneByteHeaderExtensions)
+
+	offererME := &webrtc.MediaEngine{}
+	require.NoError(t, offererME.RegisterDefaultCodecs())
+	offerer, err := webrtc.NewAPI(webrtc.WithMediaEngine(offererME)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer offerer.Close()
+
+	answererME, err := createMediaEngine(testEnabledCodecs, conf.Publisher, false)
+	require.NoError(t, err)
+	answerer, err := webrtc.NewAPI(webrtc.WithMediaEngine(answererME)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer answerer.Close()
+
+	_, err = offerer.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+	require.NoError(t, err)
+	offer, err := offerer.CreateOffer(nil)
+	require.NoError(t, err)
+
+	// pion only assigns one-byte ids when offering, add the extensions as a client using two-byte headers would
+	var extmap strings.Builder
+	for i, uri := range offered {
+		extmap.WriteString(fmt.Sprintf("a=extmap:%d %s\r\n", i+1, uri))
+	}
+	offer.SDP = strings.Replace(offer.SDP, "t=0 0\r\n", "t=0 0\r\na=extmap-allow-mixed\r\n", 1)
+	offer.SDP = strings.Replace(offer.SDP, "a=mid:0\r\n", "a=mid:0\r\n"+extmap.String(), 1)
+
+	require.NoError(t, answerer.SetRemoteDescription(offer))
+	answer, err := answerer.CreateAnswer(nil)
+	require.NoError(t, err)
+
+	parsed, err := answer.Unmarshal()
+	require.NoError(t, err)
+	_, allowMixed := parsed.Attribute(sdp.AttrKeyExtMapAllowMixed)
+	require.True(t, allowMixed)
+	require.Len(t, parsed.MediaDescriptions, 1)
+
+	ids := make(map[string]int)
+	for _, attr := range parsed.MediaDescriptions[0].Attributes {
+		if attr.Key != sdp.AttrKeyExtMap {
+			continue
+		}
+		var ext sdp.ExtMap
+		require.NoError(t, ext.Unmarshal(attr.Key+":"+attr.Value))
+		ids[ext.URI.String()] = ext.Value
+	}
+	for i, uri := range offered {
+		require.Equal(t, i+1, ids[uri], uri)
+	}
+}
+
+func TestSubscriberRTPStreamID(t *testing.T) {
+	offered := []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI}
+
+	t.Run("disabled", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, nil)
+		require.NotContains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.SDESRTPStreamIDURI)
+		require.NotContains(t, extensionURIs(negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Subscriber)), sdp.SDESRTPStreamIDURI)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.SubscriberRTPStreamID = true
+		})
+		require.Contains(t, conf.Subscriber.RTPHeaderExtension.Video, sdp.SDESRTPStreamIDURI)
+		require.NotContains(t, conf.Subscriber.RTPHeaderExtension.Audio, sdp.SDESRTPStreamIDURI)
+		require.Contains(t, extensionURIs(negotiate(t, webrtc.RTPCodecTypeVideo, offered, conf.Subscriber)), sdp.SDESRTPStreamIDURI)
+	})
+}
+
+func TestDisabledRTCPFeedback(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.CongestionControl.UseSendSideBWE = false
+		conf.RTC.DisabledRTCPFeedback = map[string][]string{
+			"video/VP8": {webrtc.TypeRTCPFBGoogREMB},
+		}
+	})
+	require.Equal(t, map[string][]webrtc.RTCPFeedback{
+		"video/vp8": {{Type: webrtc.TypeRTCPFBGoogREMB}},
+	}, conf.Subscriber.RTCPFeedback.Disabled)
+
+	me, err := createMediaEngine([]*livekit.Codec{
+		{Mime: webrtc.MimeTypeVP8},
+		{Mime: webrtc.MimeTypeH264},
+	}, conf.Subscriber, true)
+	require.NoError(t, err)
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	parsed, err := offer.Unmarshal()
+	require.NoError(t, err)
+
+	var feedback []string
+	for _, md := range parsed.MediaDescriptions {
+		for _, attr := range md.Attributes {
+			if attr.Key == "rtcp-fb" {
+				feedback = append(feedback, attr.Value)
+			}
+		}
+	}
+	// removed only for VP8 (96), H.264 (125) and the other VP8 feedback are unchanged
+	require.NotContains(t, feedback, "96 "+webrtc.TypeRTCPFBGoogREMB)
+	require.Contains(t, feedback, "96 "+webrtc.TypeRTCPFBNACK)
+	require.Contains(t, feedback, "96 "+webrtc.TypeRTCPFBNACK+" pli")
+	require.Contains(t, feedback, "125 "+webrtc.TypeRTCPFBGoogREMB)
+}
+
+func TestDisableSubscriberVideoFeedback(t *testing.T) {
+	videoFeedback := func(conf *WebRTCConfig) []string {
+		me, err := createMediaEngine([]*livekit.Codec{
+			{Mime: webrtc.MimeTypeVP8},
+			{Mime: webrtc.MimeTypeH264},
+		}, conf.Subscriber, true)
+		require.NoError(t, err)
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+
+		var feedback []string
+		for _, md := range parsed.MediaDescriptions {
+			for _, attr := range md.Attributes {
+				if attr.Key == "rtcp-fb" {
+					feedback = append(feedback, attr.Value)
+				}
+			}
+		}
+		return feedback
+	}
+
+	conf := newTestWebRTCConfig(t, nil)
+	require.NotEmpty(t, videoFeedback(conf))
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.DisableSubscriberVideoFeedback = true
+	})
+	require.Empty(t, conf.Subscriber.RTCPFeedback.Video)
+	require.Empty(t, videoFeedback(conf))
+	// publisher feedback is unchanged
+	require.NotEmpty(t, conf.Publisher.RTCPFeedback.Video)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
 	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	util "github.com/livekit/mediatransportutil"
 )
 
@@ -196,14 +197,22 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 		t.params.Logger.Errorw("could not retrieve buffer pair", nil)
 		return newCodec
 	}
+	owner := string(t.ID())
+	if _, err := t.params.BufferFactory.ClaimRTCPReader(ssrc, owner, buffer.StreamDirectionIncoming); err != nil {
+		t.params.Logger.Errorw("could not claim rtcp reader", err, "ssrc", ssrc)
+		return newCodec
+	}
 
 	var lastRR uint32
-	rtcpReader.OnPacket(func(bytes []byte) {
-		pkts, err := rtcp.Unmarshal(bytes)
+	rtcpReader.OnPacketForOwner(owner, func(bytes []byte) {
+		pkts, numUnknown, err := buffer.UnmarshalRTCP(bytes, t.params.ReceiverConfig.UnknownRTCPPolicy)
 		if err != nil {
 			t.params.Logger.Errorw("could not unmarshal RTCP", err)
 			return
 		}
+		if t.params.ReceiverConfig.UnknownRTCPPolicy == buffer.UnknownRTCPPolicyCount {
+			prometheus.IncrementUnknownRTCP(prometheus.Incoming, numUnknown)
+		}
 
 		for _, pkt := range pkts {
 			switch pkt := pkt.(type) {
@@ -273,6 +282,14 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 			return false
 		}
 
+		rrInterval := t.params.ReceiverConfig.ReceiverReportIntervalVideo
+		rrJitter := t.params.ReceiverConfig.ReceiverReportJitterVideo
+		deadTrackTimeout := t.params.ReceiverConfig.DeadTrackTimeoutVideo
+		if ti.Type == livekit.TrackType_AUDIO {
+			rrInterval = t.params.ReceiverConfig.ReceiverReportIntervalAudio
+			rrJitter = t.params.ReceiverConfig.ReceiverReportJitterAudio
+			deadTrackTimeout = t.params.ReceiverConfig.DeadTrackTimeoutAudio
+		}
 		newWR := sfu.NewWebRTCReceiver(
 			receiver,
 			track,
@@ -282,10 +299,25 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 			t.params.VideoConfig.StreamTracker,
 			sfu.WithPliThrottleConfig(t.params.PLIThrottleConfig),
 			sfu.WithAudioConfig(t.params.AudioConfig),
+			sfu.WithDDReorderTolerance(t.params.ReceiverConfig.DDReorderTolerance),
+			sfu.WithKeyFrameReorderTolerance(t.params.ReceiverConfig.KeyFrameReorderTolerance),
+			sfu.WithReceiverReportInterval(rrInterval),
+			sfu.WithReceiverReportJitter(rrJitter),
+			sfu.WithMaxTimestampJump(t.params.ReceiverConfig.MaxTimestampJump),
+			sfu.WithPaddingPolicy(t.params.ReceiverConfig.PaddingPolicy),
+			sfu.WithKeepalivePolicy(t.params.ReceiverConfig.KeepalivePolicy),
+			sfu.WithRTCPExtendedReports(t.params.ReceiverConfig.RTCPExtendedReports),
+			sfu.WithMaxSimulcastLayers(t.params.ReceiverConfig.MaxSimulcastLayers),
+			sfu.WithMaxLayerResolution(t.params.ReceiverConfig.MaxLayerWidth, t.params.ReceiverConfig.MaxLayerHeight),
+			sfu.WithKeyFrameRequestMethods(t.params.ReceiverConfig.KeyFrameRequestMethods),
+			sfu.WithKeyFrameRequestLimiter(t.params.ReceiverConfig.KeyFrameRequestLimiter),
+			sfu.WithAudioRedDistance(t.params.ReceiverConfig.AudioRedDistance),
+			sfu.WithAudioMaxBitrate(t.params.ReceiverConfig.MaxAudioBitrate),
 			sfu.WithLoadBalanceThreshold(20),
 			sfu.WithStreamTrackers(),
 			sfu.WithForwardStats(t.params.ForwardStats),
 			sfu.WithEverHasDowntrackAdded(t.handleReceiverEverAddDowntrack),
+			sfu.WithDeadTrackTimeout(deadTrackTimeout),
 		)
 		newWR.OnCloseHandler(func() {
 			t.MediaTrackReceiver.SetClosing()
@@ -103,6 +103,7 @@ type MediaTrackReceiver struct {
 	potentialCodecs    []webrtc.RTPCodecParameters
 	state              mediaTrackReceiverState
 	isExpectedToResume bool
+	codecFallbacks     map[livekit.ParticipantID]*codecFallback
 
 	onSetupReceiver     func(mime string)
 	onMediaLossFeedback func(dt *sfu.DownTrack, report *rtcp.ReceiverReport)
@@ -125,6 +126,8 @@ func NewMediaTrackReceiver(params MediaTrackReceiverParams, ti *livekit.TrackInf
 		SubscriberConfig: params.SubscriberConfig,
 		Telemetry:        params.Telemetry,
 		Logger:           params.Logger,
+
+		NACKActiveSpeakerOnly: params.AudioConfig.NACKActiveSpeakerOnly,
 	})
 	t.MediaTrackSubscriptions.OnDownTrackCreated(t.onDownTrackCreated)
 
@@ -351,6 +354,7 @@ func (t *MediaTrackReceiver) Close(isExpectedToResume bool) {
 	}
 
 	t.state = mediaTrackReceiverStateClosed
+	t.codecFallbacks = nil
 	onclose := t.onClose
 	t.lock.Unlock()
 
@@ -481,6 +485,12 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) (types.Su
 		}
 	}
 
+	t.lock.RLock()
+	if fallback := t.codecFallbacks[sub.ID()]; fallback != nil {
+		potentialCodecs = applyCodecFallback(potentialCodecs, fallback)
+	}
+	t.lock.RUnlock()
+
 	streamId := string(t.PublisherID())
 	if sub.ProtocolVersion().SupportsPackedStreamId() {
 		// when possible, pack both IDs in streamID to allow new streams to be generated
@@ -496,6 +506,8 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) (types.Su
 		UpstreamCodecs: potentialCodecs,
 		Logger:         tLogger,
 		DisableRed:     t.trackInfo.GetDisableRed() || !t.params.AudioConfig.ActiveREDEncoding,
+
+		PassthroughCodecs: t.params.ReceiverConfig.PassthroughCodecs,
 	})
 	subTrack, err := t.MediaTrackSubscriptions.AddSubscriber(sub, wr)
 
@@ -520,6 +532,13 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) (types.Su
 // RemoveSubscriber removes participant from subscription
 // stop all forwarders to the client
 func (t *MediaTrackReceiver) RemoveSubscriber(subscriberID livekit.ParticipantID, isExpectedToResume bool) {
+	if !isExpectedToResume {
+		// the subscription ended, a later one negotiates codecs afresh
+		t.lock.Lock()
+		delete(t.codecFallbacks, subscriberID)
+		t.lock.Unlock()
+	}
+
 	_ = t.MediaTrackSubscriptions.RemoveSubscriber(subscriberID, isExpectedToResume)
 }
 
@@ -830,6 +849,42 @@ func (t *MediaTrackReceiver) onDownTrackCreated(downTrack *sfu.DownTrack) {
 			}
 		})
 	}
+	if t.Kind() == livekit.TrackType_VIDEO && len(t.params.ReceiverConfig.CodecFallbacks) != 0 {
+		downTrack.OnDecodeFailure(func(dt *sfu.DownTrack) {
+			t.handleDecodeFailure(dt.SubscriberID(), dt.Codec().MimeType)
+		})
+	}
+}
+
+// handleDecodeFailure resubscribes a subscriber that cannot decode the forwarded codec with
+// a fallback codec, when the track is published with one
+func (t *MediaTrackReceiver) handleDecodeFailure(subscriberID livekit.ParticipantID, mime string) {
+	mime = strings.ToLower(mime)
+
+	t.lock.Lock()
+	available := slices.Clone(t.potentialCodecs)
+	for _, receiver := range t.receivers {
+		available = append(available, receiver.Codec())
+	}
+	var failed []string
+	if prev := t.codecFallbacks[subscriberID]; prev != nil {
+		failed = slices.Clone(prev.failed)
+	}
+	failed = append(failed, mime)
+	fallback := selectFallbackCodec(t.params.ReceiverConfig.CodecFallbacks[mime], available, failed)
+	if fallback == "" {
+		t.lock.Unlock()
+		t.params.Logger.Infow("decode failure, no fallback codec available", "subscriberID", subscriberID, "mime", mime)
+		return
+	}
+	if t.codecFallbacks == nil {
+		t.codecFallbacks = make(map[livekit.ParticipantID]*codecFallback)
+	}
+	t.codecFallbacks[subscriberID] = &codecFallback{failed: failed, fallback: fallback}
+	t.lock.Unlock()
+
+	t.params.Logger.Infow("decode failure, falling back codec", "subscriberID", subscriberID, "mime", mime, "fallback", fallback)
+	go t.RemoveSubscriber(subscriberID, true)
 }
 
 func (t *MediaTrackReceiver) DebugInfo() map[string]interface{} {
@@ -44,6 +44,8 @@ type MediaTrackSubscriptions struct {
 	subscribedTracksMu sync.RWMutex
 	subscribedTracks   map[livekit.ParticipantID]types.SubscribedTrack
 
+	activeSpeaker atomic.Bool
+
 	onDownTrackCreated           func(downTrack *sfu.DownTrack)
 	onSubscriberMaxQualityChange func(subscriberID livekit.ParticipantID, codec webrtc.RTPCodecCapability, layer int32)
 }
@@ -54,6 +56,8 @@ type MediaTrackSubscriptionsParams struct {
 
 	ReceiverConfig   ReceiverConfig
 	SubscriberConfig DirectionConfig
+	// answer NACKs of subscribers for audio only while the publisher is an active speaker
+	NACKActiveSpeakerOnly bool
 
 	Telemetry telemetry.TelemetryService
 
@@ -67,6 +71,11 @@ func NewMediaTrackSubscriptions(params MediaTrackSubscriptionsParams) *MediaTrac
 	}
 }
 
+// SetActiveSpeaker sets whether the publisher is an active speaker of the room
+func (t *MediaTrackSubscriptions) SetActiveSpeaker(active bool) {
+	t.activeSpeaker.Store(active)
+}
+
 func (t *MediaTrackSubscriptions) OnDownTrackCreated(f func(downTrack *sfu.DownTrack)) {
 	t.onDownTrackCreated = f
 }
@@ -128,6 +137,13 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		trailer = sub.GetTrailer()
 	}
 
+	var ignoreNACKs func() bool
+	if t.params.NACKActiveSpeakerOnly && t.params.MediaTrack.Kind() == livekit.TrackType_AUDIO {
+		ignoreNACKs = func() bool {
+			return !t.activeSpeaker.Load()
+		}
+	}
+
 	downTrack, err := sfu.NewDownTrack(sfu.DowntrackParams{
 		Codecs:            codecs,
 		Source:            t.params.MediaTrack.Source(),
@@ -141,11 +157,37 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		Trailer:           trailer,
 		Logger:            LoggerWithTrack(sub.GetLogger().WithComponent(sutils.ComponentSub), trackID, t.params.IsRelayed),
 		RTCPWriter:        sub.WriteSubscriberRTCP,
+
+		UnknownRTCPPolicy:              t.params.ReceiverConfig.UnknownRTCPPolicy,
+		SyncOffset:                     t.params.ReceiverConfig.SyncOffsets[t.params.MediaTrack.Source()],
+		MaxRetransmits:                 t.params.ReceiverConfig.MaxRetransmits,
+		DisableKeyFrameRequestOnStart:  t.params.ReceiverConfig.DisableKeyFrameRequestOnSubscribe,
+		LossFallback:                   t.params.ReceiverConfig.LossFallback,
+		LossThresholds:                 t.params.ReceiverConfig.LossThresholds,
+		ForwardUnknownHeaderExtensions: t.params.ReceiverConfig.ForwardUnknownHeaderExtensions,
+		StripCSRC:                      t.params.ReceiverConfig.StripCSRC,
+		RetransmitBudget:               t.params.ReceiverConfig.RetransmitBudget,
+		IgnoreNACKs:                    ignoreNACKs,
+		ReorderedFrameCodecs:           t.params.ReceiverConfig.ReorderedFrameCodecs,
+		DecodeFailure:                  t.params.ReceiverConfig.DecodeFailure,
+		LayerTargetBitrates:            t.params.ReceiverConfig.LayerTargetBitrates,
+		LayerSwitchMinDwell:            t.params.ReceiverConfig.LayerSwitchMinDwell,
+		MaxFps:                         t.params.ReceiverConfig.MaxFps[t.params.MediaTrack.Source()],
+		MaxAudioBitrate:                t.params.ReceiverConfig.MaxAudioBitrate,
+		PubMutePolicy:                  t.params.ReceiverConfig.PubMutePolicy,
+		PaddingPolicy:                  t.params.ReceiverConfig.PaddingPolicy,
+		KeepalivePolicy:                t.params.ReceiverConfig.KeepalivePolicy,
+		SVCLayerCaps:                   t.params.ReceiverConfig.SVCLayerCaps,
+		PacketTrace:                    sub.GetPacketTrace(),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if layer, ok := t.params.ReceiverConfig.PinnedSpatialLayers[sub.Identity()]; ok && t.params.MediaTrack.Kind() == livekit.TrackType_VIDEO {
+		downTrack.SetPinnedSpatialLayer(layer)
+	}
+
 	if t.onDownTrackCreated != nil {
 		t.onDownTrackCreated(downTrack)
 	}
@@ -201,6 +243,42 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		go sub.UpdateMediaRTT(rtt)
 	})
 
+	// the stream of a dead track is paused for the subscriber till the publisher sends again,
+	// updates are serialised and send the latest state, so that they cannot be reordered
+	var deadChangeMu sync.Mutex
+	downTrack.OnUpTrackDeadChange(func(dt *sfu.DownTrack, _ bool) {
+		go func() {
+			deadChangeMu.Lock()
+			defer deadChangeMu.Unlock()
+
+			dead := dt.IsUpTrackDead()
+			state := livekit.StreamState_ACTIVE
+			if dead {
+				state = livekit.StreamState_PAUSED
+			}
+			err := sub.SendStreamStateUpdate(&livekit.StreamStateUpdate{
+				StreamStates: []*livekit.StreamStateInfo{{
+					ParticipantSid: string(subTrack.PublisherID()),
+					TrackSid:       string(trackID),
+					State:          state,
+				}},
+			})
+			if err != nil {
+				sub.GetLogger().Warnw("could not send stream state update", err, "trackID", trackID, "dead", dead)
+			}
+		}()
+	})
+
+	downTrack.OnLossThresholdCrossed(func(_ *sfu.DownTrack, crossing sfu.LossThresholdCrossing) {
+		sub.GetLogger().Infow(
+			"subscriber loss threshold crossed",
+			"trackID", trackID,
+			"threshold", crossing.Threshold,
+			"above", crossing.Above,
+			"fractionLost", crossing.FractionLost,
+		)
+	})
+
 	downTrack.AddReceiverReportListener(func(dt *sfu.DownTrack, report *rtcp.ReceiverReport) {
 		sub.HandleReceiverReport(dt, report)
 	})
@@ -262,6 +340,9 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		if addTrackParams.Red && (len(codecs) == 1 && strings.EqualFold(codecs[0].MimeType, webrtc.MimeTypeOpus)) {
 			addTrackParams.Red = false
 		}
+		for _, c := range codecs {
+			addTrackParams.MimeTypes = append(addTrackParams.MimeTypes, c.MimeType)
+		}
 
 		sub.VerifySubscribeParticipantInfo(subTrack.PublisherID(), subTrack.PublisherVersion())
 		if sub.SupportsTransceiverReuse() {
@@ -0,0 +1,101 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// NegotiationInfo is what the server negotiates with clients, for debugging client interop
+type NegotiationInfo struct {
+	Publisher               DirectionNegotiationInfo `json:"publisher"`
+	Subscriber              DirectionNegotiationInfo `json:"subscriber"`
+	TwoByteHeaderExtensions bool                     `json:"two_byte_header_extensions"`
+	RTCPReducedSize         bool                     `json:"rtcp_reduced_size"`
+}
+
+type DirectionNegotiationInfo struct {
+	AudioHeaderExtensions []string               `json:"audio_header_extensions"`
+	VideoHeaderExtensions []string               `json:"video_header_extensions"`
+	Codecs                []CodecNegotiationInfo `json:"codecs"`
+}
+
+type CodecNegotiationInfo struct {
+	MimeType     string   `json:"mime_type"`
+	PayloadType  uint8    `json:"payload_type"`
+	ClockRate    uint32   `json:"clock_rate"`
+	Channels     uint16   `json:"channels,omitempty"`
+	SDPFmtpLine  string   `json:"sdp_fmtp_line,omitempty"`
+	RTCPFeedback []string `json:"rtcp_feedback,omitempty"`
+}
+
+// codecRecorder records codecs in the order they are registered
+type codecRecorder struct {
+	codecs []CodecNegotiationInfo
+}
+
+func (c *codecRecorder) RegisterCodec(codec webrtc.RTPCodecParameters, _ webrtc.RTPCodecType) error {
+	var feedback []string
+	for _, fb := range codec.RTCPFeedback {
+		feedback = append(feedback, strings.TrimSpace(fb.Type+" "+fb.Parameter))
+	}
+	c.codecs = append(c.codecs, CodecNegotiationInfo{
+		MimeType:     codec.MimeType,
+		PayloadType:  uint8(codec.PayloadType),
+		ClockRate:    codec.ClockRate,
+		Channels:     codec.Channels,
+		SDPFmtpLine:  codec.SDPFmtpLine,
+		RTCPFeedback: feedback,
+	})
+	return nil
+}
+
+// NegotiationInfo returns the header extensions, codecs and feedback offered or accepted for the given enabled codecs,
+// the same way peer connections created with this config register them
+func (c *WebRTCConfig) NegotiationInfo(enabledCodecs []*livekit.Codec) (*NegotiationInfo, error) {
+	publisher, err := directionNegotiationInfo(enabledCodecs, c.Publisher, false)
+	if err != nil {
+		return nil, err
+	}
+	// subscriber peer connection is the offerer, it does not offer H.264 High Profile
+	subscriber, err := directionNegotiationInfo(enabledCodecs, c.Subscriber, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NegotiationInfo{
+		Publisher:               publisher,
+		Subscriber:              subscriber,
+		TwoByteHeaderExtensions: c.TwoByteHeaderExtensions,
+		RTCPReducedSize:         !c.DisableRTCPReducedSize,
+	}, nil
+}
+
+func directionNegotiationInfo(enabledCodecs []*livekit.Codec, config DirectionConfig, filterOutH264HighProfile bool) (DirectionNegotiationInfo, error) {
+	recorder := &codecRecorder{}
+	if err := registerCodecs(recorder, enabledCodecs, config.RTCPFeedback, config.RedDistance, config.OpusSampleRates, filterOutH264HighProfile); err != nil {
+		return DirectionNegotiationInfo{}, err
+	}
+
+	return DirectionNegotiationInfo{
+		AudioHeaderExtensions: append([]string{}, config.RTPHeaderExtension.Audio...),
+		VideoHeaderExtensions: append([]string{}, config.RTPHeaderExtension.Video...),
+		Codecs:                recorder.codecs,
+	}, nil
+}
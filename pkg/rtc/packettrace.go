@@ -0,0 +1,239 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/utils"
+)
+
+// PacketTracer turns per-packet tracing of connections on and off by connection ID, from config at startup or at
+// runtime. Tracing of a connection applies to its peer connections straight away, established or not.
+type PacketTracer struct {
+	lock       sync.RWMutex
+	enabled    map[livekit.ConnectionID]struct{}
+	numEnabled atomic.Int32
+}
+
+func NewPacketTracer(connectionIDs []string) *PacketTracer {
+	t := &PacketTracer{
+		enabled: make(map[livekit.ConnectionID]struct{}),
+	}
+	for _, connectionID := range connectionIDs {
+		t.SetEnabled(livekit.ConnectionID(connectionID), true)
+	}
+	return t
+}
+
+func (t *PacketTracer) SetEnabled(connectionID livekit.ConnectionID, enabled bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if enabled {
+		t.enabled[connectionID] = struct{}{}
+	} else {
+		delete(t.enabled, connectionID)
+	}
+	t.numEnabled.Store(int32(len(t.enabled)))
+}
+
+func (t *PacketTracer) IsEnabled(connectionID livekit.ConnectionID) bool {
+	// checked for every packet of every connection, skip the lookup while nothing is traced
+	if t == nil || t.numEnabled.Load() == 0 {
+		return false
+	}
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	_, ok := t.enabled[connectionID]
+	return ok
+}
+
+// EnabledConnections returns the IDs of the traced connections, sorted
+func (t *PacketTracer) EnabledConnections() []livekit.ConnectionID {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	connectionIDs := make([]livekit.ConnectionID, 0, len(t.enabled))
+	for connectionID := range t.enabled {
+		connectionIDs = append(connectionIDs, connectionID)
+	}
+	sort.Slice(connectionIDs, func(i, j int) bool { return connectionIDs[i] < connectionIDs[j] })
+	return connectionIDs
+}
+
+// NewTrace returns the trace of a connection, logging to logger whenever tracing of the connection is enabled
+func (t *PacketTracer) NewTrace(connectionID livekit.ConnectionID, logger logger.Logger) *sfu.PacketTrace {
+	if t == nil {
+		return nil
+	}
+
+	return sfu.NewPacketTrace(func() bool {
+		return t.IsEnabled(connectionID)
+	}, logger.WithValues("connID", connectionID).Infow)
+}
+
+type PacketTraceInterceptorFactory struct {
+	extensions RTPHeaderExtensionConfig
+	trace      *sfu.PacketTrace
+}
+
+// NewPacketTraceInterceptorFactory creates interceptors tracing every RTP packet, along with the values of the given
+// header extensions, and every RTCP packet of a peer connection while the trace is enabled
+func NewPacketTraceInterceptorFactory(extensions RTPHeaderExtensionConfig, trace *sfu.PacketTrace) *PacketTraceInterceptorFactory {
+	return &PacketTraceInterceptorFactory{
+		extensions: extensions,
+		trace:      trace,
+	}
+}
+
+func (f *PacketTraceInterceptorFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &PacketTraceInterceptor{
+		extensions: f.extensions,
+		trace:      f.trace,
+	}, nil
+}
+
+type PacketTraceInterceptor struct {
+	interceptor.NoOp
+	extensions RTPHeaderExtensionConfig
+	trace      *sfu.PacketTrace
+}
+
+func (p *PacketTraceInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, a, err := reader.Read(b, a)
+		if err == nil && p.trace.IsEnabled() {
+			p.traceRTCP("received RTCP", b[:n])
+		}
+		return n, a, err
+	})
+}
+
+func (p *PacketTraceInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, a interceptor.Attributes) (int, error) {
+		if p.trace.IsEnabled() {
+			for _, pkt := range pkts {
+				p.traceRTCPPacket("sent RTCP", pkt)
+			}
+		}
+		return writer.Write(pkts, a)
+	})
+}
+
+func (p *PacketTraceInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	extensionIDs := p.extensionIDs(info)
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+		if p.trace.IsEnabled() {
+			p.traceRTP("sent RTP", header, len(payload), extensionIDs)
+		}
+		return writer.Write(header, payload, a)
+	})
+}
+
+func (p *PacketTraceInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	extensionIDs := p.extensionIDs(info)
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, a, err := reader.Read(b, a)
+		if err != nil || !p.trace.IsEnabled() {
+			return n, a, err
+		}
+
+		var header rtp.Header
+		headerSize, err := header.Unmarshal(b[:n])
+		if err != nil {
+			p.trace.Trace("received malformed RTP", "ssrc", info.SSRC, "size", n, "error", err)
+			return n, a, nil
+		}
+		p.traceRTP("received RTP", &header, n-headerSize, extensionIDs)
+		return n, a, nil
+	})
+}
+
+// extensionIDs maps the IDs negotiated for the stream to the configured header extensions of its kind
+func (p *PacketTraceInterceptor) extensionIDs(info *interceptor.StreamInfo) map[uint8]string {
+	uris := p.extensions.Audio
+	if strings.HasPrefix(strings.ToLower(info.MimeType), "video") {
+		uris = p.extensions.Video
+	}
+
+	extensionIDs := make(map[uint8]string, len(uris))
+	for _, uri := range uris {
+		if id := utils.GetHeaderExtensionID(info.RTPHeaderExtensions, webrtc.RTPHeaderExtensionCapability{URI: uri}); id != 0 {
+			extensionIDs[uint8(id)] = uri
+		}
+	}
+	return extensionIDs
+}
+
+func (p *PacketTraceInterceptor) traceRTP(msg string, header *rtp.Header, payloadSize int, extensionIDs map[uint8]string) {
+	ids := header.GetExtensionIDs()
+	extensions := make(map[string]string, len(ids))
+	for _, id := range ids {
+		uri, ok := extensionIDs[id]
+		if !ok {
+			uri = fmt.Sprintf("unknown:%d", id)
+		}
+		extensions[uri] = hex.EncodeToString(header.GetExtension(id))
+	}
+
+	p.trace.Trace(
+		msg,
+		"ssrc", header.SSRC,
+		"payloadType", header.PayloadType,
+		"sequenceNumber", header.SequenceNumber,
+		"timestamp", header.Timestamp,
+		"marker", header.Marker,
+		"payloadSize", payloadSize,
+		"extensions", extensions,
+	)
+}
+
+func (p *PacketTraceInterceptor) traceRTCP(msg string, b []byte) {
+	pkts, err := rtcp.Unmarshal(b)
+	if err != nil {
+		p.trace.Trace(msg, "size", len(b), "error", err)
+		return
+	}
+
+	for _, pkt := range pkts {
+		p.traceRTCPPacket(msg, pkt)
+	}
+}
+
+func (p *PacketTraceInterceptor) traceRTCPPacket(msg string, pkt rtcp.Packet) {
+	p.trace.Trace(
+		msg,
+		"type", fmt.Sprintf("%T", pkt),
+		"destinationSSRCs", pkt.DestinationSSRC(),
+		"packet", pkt,
+	)
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+)
+
+func TestPacketTraceInterceptor(t *testing.T) {
+	type trace struct {
+		msg           string
+		keysAndValues map[string]interface{}
+	}
+	var traces []trace
+	record := func(msg string, keysAndValues ...interface{}) {
+		kv := make(map[string]interface{})
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			kv[keysAndValues[i].(string)] = keysAndValues[i+1]
+		}
+		traces = append(traces, trace{msg: msg, keysAndValues: kv})
+	}
+
+	var enabled atomic.Bool
+	enabled.Store(true)
+	i, err := NewPacketTraceInterceptorFactory(RTPHeaderExtensionConfig{
+		Video: []string{sdp.SDESMidURI},
+	}, sfu.NewPacketTrace(enabled.Load, record)).NewInterceptor("")
+	require.NoError(t, err)
+
+	t.Run("received RTP", func(t *testing.T) {
+		traces = nil
+		pkt := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    96,
+				SequenceNumber: 10,
+				SSRC:           1234,
+			},
+			Payload: []byte{1, 2, 3},
+		}
+		require.NoError(t, pkt.SetExtension(1, []byte("0")))
+		require.NoError(t, pkt.SetExtension(2, []byte{0xff}))
+		raw, err := pkt.Marshal()
+		require.NoError(t, err)
+
+		reader := i.BindRemoteStream(&interceptor.StreamInfo{
+			SSRC:                1234,
+			MimeType:            "video/VP8",
+			RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: sdp.SDESMidURI, ID: 1}},
+		}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+			return copy(b, raw), a, nil
+		}))
+		n, _, err := reader.Read(make([]byte, 1500), nil)
+		require.NoError(t, err)
+		require.Equal(t, len(raw), n)
+
+		require.Len(t, traces, 1)
+		require.Equal(t, "received RTP", traces[0].msg)
+		require.Equal(t, uint32(1234), traces[0].keysAndValues["ssrc"])
+		require.Equal(t, uint16(10), traces[0].keysAndValues["sequenceNumber"])
+		require.Equal(t, 3, traces[0].keysAndValues["payloadSize"])
+		require.Equal(t, map[string]string{
+			sdp.SDESMidURI: "30",
+			"unknown:2":    "ff",
+		}, traces[0].keysAndValues["extensions"])
+	})
+
+	t.Run("sent RTCP", func(t *testing.T) {
+		traces = nil
+		writer := i.BindRTCPWriter(interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+			return len(pkts), nil
+		}))
+		_, err := writer.Write([]rtcp.Packet{
+			&rtcp.PictureLossIndication{MediaSSRC: 1234},
+			&rtcp.TransportLayerNack{MediaSSRC: 1234, Nacks: rtcp.NackPairsFromSequenceNumbers([]uint16{10})},
+		}, nil)
+		require.NoError(t, err)
+
+		require.Len(t, traces, 2)
+		require.Equal(t, "sent RTCP", traces[0].msg)
+		require.Equal(t, "*rtcp.PictureLossIndication", traces[0].keysAndValues["type"])
+		require.Equal(t, "*rtcp.TransportLayerNack", traces[1].keysAndValues["type"])
+	})
+
+	t.Run("received RTCP", func(t *testing.T) {
+		traces = nil
+		raw, err := rtcp.Marshal([]rtcp.Packet{&rtcp.FullIntraRequest{MediaSSRC: 1234, FIR: []rtcp.FIREntry{{SSRC: 1234, SequenceNumber: 1}}}})
+		require.NoError(t, err)
+		reader := i.BindRTCPReader(interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+			return copy(b, raw), a, nil
+		}))
+		_, _, err = reader.Read(make([]byte, 1500), nil)
+		require.NoError(t, err)
+
+		require.Len(t, traces, 1)
+		require.Equal(t, "received RTCP", traces[0].msg)
+		require.Equal(t, "*rtcp.FullIntraRequest", traces[0].keysAndValues["type"])
+	})
+
+	t.Run("not traced while disabled", func(t *testing.T) {
+		traces = nil
+		enabled.Store(false)
+		defer enabled.Store(true)
+
+		writer := i.BindRTCPWriter(interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+			return len(pkts), nil
+		}))
+		n, err := writer.Write([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1234}}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Empty(t, traces)
+	})
+}
+
+func TestPacketTracer(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.False(t, conf.PacketTracer.IsEnabled("CO_traced"))
+	require.Empty(t, conf.PacketTracer.EnabledConnections())
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.PacketTraceConnections = []string{"CO_traced"}
+	})
+	tracer := conf.PacketTracer
+	traced := tracer.NewTrace("CO_traced", logger.GetLogger())
+	untraced := tracer.NewTrace("CO_untraced", logger.GetLogger())
+
+	// only the targeted connection is traced
+	require.True(t, traced.IsEnabled())
+	require.False(t, untraced.IsEnabled())
+
+	// toggled at runtime, applies to traces already handed out
+	tracer.SetEnabled("CO_untraced", true)
+	require.True(t, untraced.IsEnabled())
+	require.Equal(t, []livekit.ConnectionID{"CO_traced", "CO_untraced"}, tracer.EnabledConnections())
+
+	tracer.SetEnabled("CO_traced", false)
+	tracer.SetEnabled("CO_untraced", false)
+	require.False(t, traced.IsEnabled())
+	require.False(t, untraced.IsEnabled())
+	require.Empty(t, tracer.EnabledConnections())
+
+	// shared with snapshots and kept on reload, so that runtime changes apply to every config in use
+	tracer.SetEnabled("CO_traced", true)
+	require.True(t, conf.Snapshot().PacketTracer.IsEnabled("CO_traced"))
+
+	holder := NewWebRTCConfigHolder(conf)
+	c, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, holder.Reload(c))
+	require.Same(t, tracer, holder.Load().PacketTracer)
+
+	// nil tracer, e.g. in tests, does not trace
+	var nilTracer *PacketTracer
+	require.False(t, nilTracer.IsEnabled("CO_traced"))
+	require.Nil(t, nilTracer.NewTrace("CO_traced", logger.GetLogger()))
+	require.False(t, nilTracer.NewTrace("CO_traced", logger.GetLogger()).IsEnabled())
+}
@@ -59,6 +59,8 @@ const (
 	sdBatchSize       = 30
 	rttUpdateInterval = 5 * time.Second
 
+	defaultSenderReportInterval = 3 * time.Second
+
 	disconnectCleanupDuration = 5 * time.Second
 	migrationWaitDuration     = 3 * time.Second
 
@@ -140,9 +142,13 @@ type ParticipantParams struct {
 	SubscriberAllowPause         bool
 	SubscriptionLimitAudio       int32
 	SubscriptionLimitVideo       int32
+	SubscriptionLimit            int32
 	PlayoutDelay                 *livekit.PlayoutDelay
 	SyncStreams                  bool
 	ForwardStats                 *sfu.ForwardStats
+	EgressBudget                 *streamallocator.EgressBudget
+	// traces packets of the connection the participant joined with, nil does not trace
+	PacketTrace *sfu.PacketTrace
 }
 
 type ParticipantImpl struct {
@@ -321,6 +327,10 @@ func (p *ParticipantImpl) GetPacer() pacer.Pacer {
 	return p.TransportManager.GetSubscriberPacer()
 }
 
+func (p *ParticipantImpl) GetPacketTrace() *sfu.PacketTrace {
+	return p.params.PacketTrace
+}
+
 func (p *ParticipantImpl) ID() livekit.ParticipantID {
 	return p.params.SID
 }
@@ -1298,6 +1308,10 @@ func (h SubscriberTransportHandler) OnInitialConnected() {
 	h.p.onSubscriberInitialConnected()
 }
 
+func (h SubscriberTransportHandler) OnNoRTCP() {
+	h.p.onSubscriberNoRTCP()
+}
+
 // ----------------------------------------------------------
 
 type PrimaryTransportHandler struct {
@@ -1342,6 +1356,7 @@ func (p *ParticipantImpl) setupTransportManager() error {
 		Twcc:                         p.twcc,
 		ProtocolVersion:              p.params.ProtocolVersion,
 		CongestionControlConfig:      p.params.CongestionControlConfig,
+		EgressBudget:                 p.params.EgressBudget,
 		EnabledPublishCodecs:         p.enabledPublishCodecs,
 		EnabledSubscribeCodecs:       p.enabledSubscribeCodecs,
 		SimTracks:                    p.params.SimTracks,
@@ -1353,6 +1368,7 @@ func (p *ParticipantImpl) setupTransportManager() error {
 		TURNSEnabled:                 p.params.TURNSEnabled,
 		AllowPlayoutDelay:            p.params.PlayoutDelay.GetEnabled(),
 		DataChannelMaxBufferedAmount: p.params.DataChannelMaxBufferedAmount,
+		PacketTrace:                  p.params.PacketTrace,
 		Logger:                       p.params.Logger.WithComponent(sutils.ComponentTransport),
 		PublisherHandler:             pth,
 		SubscriberHandler:            sth,
@@ -1420,6 +1436,9 @@ func (p *ParticipantImpl) setupSubscriptionManager() {
 		OnSubscriptionError:    p.onSubscriptionError,
 		SubscriptionLimitVideo: p.params.SubscriptionLimitVideo,
 		SubscriptionLimitAudio: p.params.SubscriptionLimitAudio,
+		SubscriptionLimit:      p.params.SubscriptionLimit,
+		// adaptive stream clients send settings of a track only once they have it
+		DeferSubscription: p.params.Config.DeferredSubscription && !p.params.AdaptiveStream,
 	})
 }
 
@@ -1695,6 +1714,14 @@ func (p *ParticipantImpl) onAnyTransportFailed() {
 	p.setupDisconnectTimer()
 }
 
+func (p *ParticipantImpl) onSubscriberNoRTCP() {
+	p.subLogger.Infow("no RTCP feedback from subscriber, disconnecting")
+	// closing stops the subscriber transport, whose stream allocator reports this, do not wait on it
+	go func() {
+		_ = p.Close(true, types.ParticipantCloseReasonNoRTCP, false)
+	}()
+}
+
 // subscriberRTCPWorker sends SenderReports periodically when the participant is subscribed to
 // other publishedTracks in the room.
 func (p *ParticipantImpl) subscriberRTCPWorker() {
@@ -1703,62 +1730,80 @@ func (p *ParticipantImpl) subscriberRTCPWorker() {
 			os.Exit(1)
 		}
 	}()
+
+	interval := p.params.Config.SenderReportInterval
+	if interval <= 0 {
+		interval = defaultSenderReportInterval
+	}
+	sendPeriodically(interval, p.IsDisconnected, p.sendSubscriberReports)
+}
+
+// sendPeriodically calls send immediately and then every interval, until done or send returns false
+func sendPeriodically(interval time.Duration, done func() bool, send func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
-		if p.IsDisconnected() {
+		if done() || !send() {
 			return
 		}
 
-		subscribedTracks := p.SubscriptionManager.GetSubscribedTracks()
-
-		// send in batches of sdBatchSize
-		batchSize := 0
-		var pkts []rtcp.Packet
-		var sd []rtcp.SourceDescriptionChunk
-		for _, subTrack := range subscribedTracks {
-			sr := subTrack.DownTrack().CreateSenderReport()
-			chunks := subTrack.DownTrack().CreateSourceDescriptionChunks()
-			if sr == nil || chunks == nil {
-				continue
-			}
+		<-ticker.C
+	}
+}
 
-			pkts = append(pkts, sr)
-			sd = append(sd, chunks...)
-			numItems := 0
-			for _, chunk := range chunks {
-				numItems += len(chunk.Items)
-			}
-			batchSize = batchSize + 1 + numItems
-			if batchSize >= sdBatchSize {
-				if len(sd) != 0 {
-					pkts = append(pkts, &rtcp.SourceDescription{Chunks: sd})
-				}
-				if err := p.TransportManager.WriteSubscriberRTCP(pkts); err != nil {
-					if IsEOF(err) {
-						return
-					}
-					p.subLogger.Errorw("could not send down track reports", err)
-				}
+// sendSubscriberReports sends sender reports and source descriptions of all subscribed tracks,
+// returns false if the subscriber transport is closed
+func (p *ParticipantImpl) sendSubscriberReports() bool {
+	subscribedTracks := p.SubscriptionManager.GetSubscribedTracks()
 
-				pkts = pkts[:0]
-				sd = sd[:0]
-				batchSize = 0
-			}
+	// send in batches of sdBatchSize
+	batchSize := 0
+	var pkts []rtcp.Packet
+	var sd []rtcp.SourceDescriptionChunk
+	for _, subTrack := range subscribedTracks {
+		sr := subTrack.DownTrack().CreateSenderReport()
+		chunks := subTrack.DownTrack().CreateSourceDescriptionChunks()
+		if sr == nil || chunks == nil {
+			continue
 		}
 
-		if len(pkts) != 0 || len(sd) != 0 {
+		pkts = append(pkts, sr)
+		sd = append(sd, chunks...)
+		numItems := 0
+		for _, chunk := range chunks {
+			numItems += len(chunk.Items)
+		}
+		batchSize = batchSize + 1 + numItems
+		if batchSize >= sdBatchSize {
 			if len(sd) != 0 {
 				pkts = append(pkts, &rtcp.SourceDescription{Chunks: sd})
 			}
 			if err := p.TransportManager.WriteSubscriberRTCP(pkts); err != nil {
 				if IsEOF(err) {
-					return
+					return false
 				}
 				p.subLogger.Errorw("could not send down track reports", err)
 			}
+
+			pkts = pkts[:0]
+			sd = sd[:0]
+			batchSize = 0
 		}
+	}
 
-		time.Sleep(3 * time.Second)
+	if len(pkts) != 0 || len(sd) != 0 {
+		if len(sd) != 0 {
+			pkts = append(pkts, &rtcp.SourceDescription{Chunks: sd})
+		}
+		if err := p.TransportManager.WriteSubscriberRTCP(pkts); err != nil {
+			if IsEOF(err) {
+				return false
+			}
+			p.subLogger.Errorw("could not send down track reports", err)
+		}
 	}
+	return true
 }
 
 func (p *ParticipantImpl) onStreamStateChange(update *streamallocator.StreamStateUpdate) error {
@@ -1779,11 +1824,7 @@ func (p *ParticipantImpl) onStreamStateChange(update *streamallocator.StreamStat
 		})
 	}
 
-	return p.writeMessage(&livekit.SignalResponse{
-		Message: &livekit.SignalResponse_StreamStateUpdate{
-			StreamStateUpdate: streamStateUpdate,
-		},
-	})
+	return p.SendStreamStateUpdate(streamStateUpdate)
 }
 
 func (p *ParticipantImpl) onSubscribedMaxQualityChange(
@@ -2644,6 +2685,8 @@ func (p *ParticipantImpl) setupEnabledCodecs(publishEnabledCodecs []*livekit.Cod
 		return false
 	}
 
+	// codecs not allowed in the room are not negotiated with the publisher
+	publishEnabledCodecs = p.params.Config.FilterPublishCodecs(publishEnabledCodecs)
 	publishCodecs := make([]*livekit.Codec, 0, len(publishEnabledCodecs))
 	for _, c := range publishEnabledCodecs {
 		if shouldDisable(c, disabledCodecs.GetCodecs()) || shouldDisable(c, disabledCodecs.GetPublish()) {
@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -468,6 +469,62 @@ func TestDisableCodecs(t *testing.T) {
 	require.False(t, found264)
 }
 
+func TestRoomPublishCodecs(t *testing.T) {
+	participant := newParticipantForTestWithOpts("123", &participantOpts{
+		publisher:     true,
+		publishCodecs: []string{"audio/opus", "video/vp8"},
+	})
+
+	for _, codec := range participant.enabledPublishCodecs {
+		require.NotEqual(t, "video/h264", strings.ToLower(codec.Mime))
+		require.NotEqual(t, "video/vp9", strings.ToLower(codec.Mime))
+	}
+
+	participant.SetMigrateState(types.MigrateStateComplete)
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	transceiver, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendrecv})
+	require.NoError(t, err)
+	sdp, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+	pc.SetLocalDescription(sdp)
+
+	sink := &routingfakes.FakeMessageSink{}
+	participant.SetResponseSink(sink)
+	var answer webrtc.SessionDescription
+	var answerReceived atomic.Bool
+	sink.WriteMessageCalls(func(msg proto.Message) error {
+		if res, ok := msg.(*livekit.SignalResponse); ok {
+			if res.GetAnswer() != nil {
+				answer = FromProtoSessionDescription(res.GetAnswer())
+				answerReceived.Store(true)
+			}
+		}
+		return nil
+	})
+	participant.HandleOffer(sdp)
+
+	testutils.WithTimeout(t, func() string {
+		if answerReceived.Load() {
+			return ""
+		} else {
+			return "answer not received"
+		}
+	})
+	require.NoError(t, pc.SetRemoteDescription(answer), answer.SDP, sdp.SDP)
+
+	// disallowed codecs are refused in negotiation
+	var mimes []string
+	for _, c := range transceiver.Receiver().GetParameters().Codecs {
+		mimes = append(mimes, strings.ToLower(c.MimeType))
+	}
+	require.Contains(t, mimes, "video/vp8")
+	require.NotContains(t, mimes, "video/h264")
+	require.NotContains(t, mimes, "video/vp9")
+	require.NotContains(t, mimes, "video/av1")
+}
+
 func TestDisablePublishCodec(t *testing.T) {
 	participant := newParticipantForTestWithOpts("123", &participantOpts{
 		publisher: true,
@@ -710,12 +767,220 @@ func TestPreferAudioCodecForRed(t *testing.T) {
 	}
 }
 
+func TestMaxAudioBitrate(t *testing.T) {
+	t.Run("fmtp", func(t *testing.T) {
+		require.Equal(t, "111 minptime=10;useinbandfec=1;maxaveragebitrate=32000", capOpusMaxAverageBitrate("111 minptime=10;useinbandfec=1", 32000))
+		require.Equal(t, "111 stereo=1;maxaveragebitrate=32000", capOpusMaxAverageBitrate("111 stereo=1;maxaveragebitrate=510000", 32000))
+		require.Equal(t, "111 maxaveragebitrate=24000;stereo=1", capOpusMaxAverageBitrate("111 maxaveragebitrate=24000;stereo=1", 32000))
+	})
+
+	t.Run("publisher answer", func(t *testing.T) {
+		participant := newParticipantForTestWithOpts("123", &participantOpts{
+			publisher: true,
+		})
+		participant.params.Config.Receiver.MaxAudioBitrate = 32000
+		participant.SetMigrateState(types.MigrateStateComplete)
+
+		me := webrtc.MediaEngine{}
+		require.NoError(t, me.RegisterDefaultCodecs())
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(&me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		// stereo asks for the highest opus bitrate, which is capped as well
+		participant.AddTrack(&livekit.AddTrackRequest{
+			Type:   livekit.TrackType_AUDIO,
+			Cid:    "audiotrack",
+			Stereo: true,
+		})
+		track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "audiotrack", "audiotrack")
+		require.NoError(t, err)
+		_, err = pc.AddTrack(track)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		require.NoError(t, pc.SetLocalDescription(offer))
+
+		sink := &routingfakes.FakeMessageSink{}
+		participant.SetResponseSink(sink)
+		var answer webrtc.SessionDescription
+		var answerReceived atomic.Bool
+		sink.WriteMessageCalls(func(msg proto.Message) error {
+			if res, ok := msg.(*livekit.SignalResponse); ok {
+				if res.GetAnswer() != nil {
+					answer = FromProtoSessionDescription(res.GetAnswer())
+					answerReceived.Store(true)
+				}
+			}
+			return nil
+		})
+		participant.HandleOffer(offer)
+
+		testutils.WithTimeout(t, func() string {
+			if answerReceived.Load() {
+				return ""
+			} else {
+				return "answer not received"
+			}
+		})
+		require.Contains(t, answer.SDP, "maxaveragebitrate=32000")
+		require.NotContains(t, answer.SDP, "maxaveragebitrate=510000")
+		require.Contains(t, answer.SDP, "stereo=1")
+	})
+}
+
+func TestAudioConcealment(t *testing.T) {
+	t.Run("fmtp", func(t *testing.T) {
+		require.Equal(t, "111 minptime=10;useinbandfec=0", withoutOpusInbandFEC("111 minptime=10;useinbandfec=1"))
+		require.Equal(t, "111 minptime=10;useinbandfec=0", withoutOpusInbandFEC("111 minptime=10"))
+	})
+
+	answer := func(t *testing.T, audioConcealment config.AudioConcealmentMode) string {
+		participant := newParticipantForTestWithOpts("123", &participantOpts{
+			publisher: true,
+		})
+		participant.params.Config.Publisher.AudioConcealment = audioConcealment
+		participant.SetMigrateState(types.MigrateStateComplete)
+
+		me := webrtc.MediaEngine{}
+		require.NoError(t, me.RegisterDefaultCodecs())
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(&me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		participant.AddTrack(&livekit.AddTrackRequest{
+			Type:       livekit.TrackType_AUDIO,
+			Cid:        "audiotrack",
+			DisableDtx: true,
+		})
+		track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "audiotrack", "audiotrack")
+		require.NoError(t, err)
+		_, err = pc.AddTrack(track)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		require.NoError(t, pc.SetLocalDescription(offer))
+
+		sink := &routingfakes.FakeMessageSink{}
+		participant.SetResponseSink(sink)
+		var answer atomic.Value
+		sink.WriteMessageCalls(func(msg proto.Message) error {
+			if res, ok := msg.(*livekit.SignalResponse); ok && res.GetAnswer() != nil {
+				answer.Store(FromProtoSessionDescription(res.GetAnswer()).SDP)
+			}
+			return nil
+		})
+		participant.HandleOffer(offer)
+
+		testutils.WithTimeout(t, func() string {
+			if answer.Load() == nil {
+				return "answer not received"
+			}
+			return ""
+		})
+		return answer.Load().(string)
+	}
+
+	// in-band FEC offered by the publisher is kept by default
+	for _, audioConcealment := range []config.AudioConcealmentMode{"", config.AudioConcealmentFEC} {
+		require.Contains(t, answer(t, audioConcealment), "useinbandfec=1")
+	}
+
+	// publishers are asked not to encode in-band FEC, so that forwarded audio does not carry it
+	answerSDP := answer(t, config.AudioConcealmentPLC)
+	require.Contains(t, answerSDP, "useinbandfec=0")
+	require.NotContains(t, answerSDP, "useinbandfec=1")
+}
+
+func TestStrictCodecMatchingForPublisher(t *testing.T) {
+	answerVideo := func(t *testing.T, strict bool, fmtpLine string) *sdp.MediaDescription {
+		participant := newParticipantForTestWithOpts("123", &participantOpts{
+			publisher: true,
+		})
+		participant.params.Config.StrictCodecMatching = strict
+		participant.SetMigrateState(types.MigrateStateComplete)
+
+		me := webrtc.MediaEngine{}
+		require.NoError(t, me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: fmtpLine},
+			PayloadType:        102,
+		}, webrtc.RTPCodecTypeVideo))
+		require.NoError(t, me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: videoRTXMimeType, ClockRate: 90000, SDPFmtpLine: "apt=102"},
+			PayloadType:        103,
+		}, webrtc.RTPCodecTypeVideo))
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(&me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		participant.AddTrack(&livekit.AddTrackRequest{
+			Type: livekit.TrackType_VIDEO,
+			Cid:  "videotrack",
+		})
+		track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}, "videotrack", "videotrack")
+		require.NoError(t, err)
+		_, err = pc.AddTrack(track)
+		require.NoError(t, err)
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		require.NoError(t, pc.SetLocalDescription(offer))
+
+		sink := &routingfakes.FakeMessageSink{}
+		participant.SetResponseSink(sink)
+		var answer webrtc.SessionDescription
+		var answerReceived atomic.Bool
+		sink.WriteMessageCalls(func(msg proto.Message) error {
+			if res, ok := msg.(*livekit.SignalResponse); ok {
+				if res.GetAnswer() != nil {
+					answer = FromProtoSessionDescription(res.GetAnswer())
+					answerReceived.Store(true)
+				}
+			}
+			return nil
+		})
+		participant.HandleOffer(offer)
+
+		require.Eventually(t, func() bool { return answerReceived.Load() }, 5*time.Second, 10*time.Millisecond)
+		parsed, err := answer.Unmarshal()
+		require.NoError(t, err)
+		for _, m := range parsed.MediaDescriptions {
+			if m.MediaName.Media == "video" {
+				return m
+			}
+		}
+		require.Fail(t, "no video section in answer")
+		return nil
+	}
+
+	// same payload format as an enabled codec, only the level and level-asymmetry-allowed differ
+	nearMatch := "packetization-mode=1;profile-level-id=42e034"
+	// main profile is not enabled
+	mismatch := "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f"
+
+	for _, strict := range []bool{false, true} {
+		md := answerVideo(t, strict, nearMatch)
+		require.NotZero(t, md.MediaName.Port.Value)
+		codecs, err := codecsFromMediaDescription(md)
+		require.NoError(t, err)
+		require.True(t, strings.EqualFold(codecs[0].Name, "h264"))
+	}
+
+	// lenient negotiates the mismatch with an enabled codec of the same mime type
+	md := answerVideo(t, false, mismatch)
+	require.NotZero(t, md.MediaName.Port.Value)
+
+	// strict rejects the media section
+	md = answerVideo(t, true, mismatch)
+	require.Zero(t, md.MediaName.Port.Value)
+}
+
 type participantOpts struct {
 	permissions     *livekit.ParticipantPermission
 	protocolVersion types.ProtocolVersion
 	publisher       bool
 	clientConf      *livekit.ClientConfiguration
 	clientInfo      *livekit.ClientInfo
+	publishCodecs   []string
 }
 
 func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *participantOpts) *ParticipantImpl {
@@ -728,10 +993,16 @@ func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *p
 	conf, _ := config.NewConfig("", true, nil, nil)
 	// disable mux, it doesn't play too well with unit test
 	conf.RTC.TCPPort = 0
+	if len(opts.publishCodecs) != 0 {
+		conf.RTC.RoomPublishCodecs = []config.RoomPublishCodecsConfig{
+			{RoomNamePattern: "*", Codecs: opts.publishCodecs},
+		}
+	}
 	rtcConf, err := NewWebRTCConfig(conf)
 	if err != nil {
 		panic(err)
 	}
+	rtcConf.SetRoom("test")
 	ff := buffer.NewFactoryOfBufferFactory(500, 200)
 	rtcConf.SetBufferFactory(ff.CreateBufferFactory())
 	grants := &auth.ClaimGrants{
@@ -777,3 +1048,25 @@ func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *p
 func newParticipantForTest(identity livekit.ParticipantIdentity) *ParticipantImpl {
 	return newParticipantForTestWithOpts(identity, nil)
 }
+
+func TestSendPeriodically(t *testing.T) {
+	t.Run("sends at interval", func(t *testing.T) {
+		interval := 50 * time.Millisecond
+		var sends []time.Time
+		sendPeriodically(interval, func() bool { return false }, func() bool {
+			sends = append(sends, time.Now())
+			return len(sends) < 4
+		})
+		require.Len(t, sends, 4)
+		require.GreaterOrEqual(t, sends[3].Sub(sends[0]), 3*interval-10*time.Millisecond)
+	})
+
+	t.Run("stops when done", func(t *testing.T) {
+		var sends int
+		sendPeriodically(10*time.Millisecond, func() bool { return sends >= 2 }, func() bool {
+			sends++
+			return true
+		})
+		require.Equal(t, 2, sends)
+	})
+}
@@ -22,17 +22,108 @@ import (
 	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/livekit-server/pkg/sfu/utils"
 	"github.com/livekit/protocol/livekit"
 	lksdp "github.com/livekit/protocol/sdp"
 )
 
 func (p *ParticipantImpl) setCodecPreferencesForPublisher(offer webrtc.SessionDescription) webrtc.SessionDescription {
+	if p.params.Config.StrictCodecMatching {
+		offer = p.removeUnmatchedCodecsForPublisher(offer, "audio")
+		offer = p.removeUnmatchedCodecsForPublisher(offer, "video")
+	}
 	offer = p.setCodecPreferencesOpusRedForPublisher(offer)
 	offer = p.setCodecPreferencesVideoForPublisher(offer)
 	return offer
 }
 
+// removeUnmatchedCodecsForPublisher removes codecs of media sections pending for publish that do not have the payload
+// format of an enabled codec, pion would negotiate them with an enabled codec of the same mime type. RTX of a removed
+// codec is removed too, a media section left without codecs is rejected in the answer
+func (p *ParticipantImpl) removeUnmatchedCodecsForPublisher(offer webrtc.SessionDescription, mediaType string) webrtc.SessionDescription {
+	parsed, unmatchMedias, err := p.TransportManager.GetUnmatchMediaForOffer(offer, mediaType)
+	if err != nil || len(unmatchMedias) == 0 {
+		return offer
+	}
+
+	recorder := &codecRecorder{}
+	conf := p.params.Config.Publisher
+	if err := registerCodecs(recorder, p.enabledPublishCodecs, conf.RTCPFeedback, conf.RedDistance, conf.OpusSampleRates, false); err != nil {
+		p.pubLogger.Errorw("failed to list enabled codecs", err)
+		return offer
+	}
+	enabledCodecs := make([]webrtc.RTPCodecParameters, 0, len(recorder.codecs))
+	for _, c := range recorder.codecs {
+		enabledCodecs = append(enabledCodecs, webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    c.MimeType,
+				ClockRate:   c.ClockRate,
+				SDPFmtpLine: c.SDPFmtpLine,
+			},
+		})
+	}
+
+	for _, unmatchMedia := range unmatchMedias {
+		codecs, err := codecsFromMediaDescription(unmatchMedia)
+		if err != nil {
+			p.pubLogger.Errorw("extract codecs from media section failed", err, "media", unmatchMedia)
+			continue
+		}
+
+		removed := make(map[uint8]bool)
+		for _, c := range codecs {
+			if strings.EqualFold(c.Name, "rtx") {
+				continue
+			}
+			codec := webrtc.RTPCodecParameters{
+				RTPCodecCapability: webrtc.RTPCodecCapability{
+					MimeType:    mediaType + "/" + c.Name,
+					ClockRate:   c.ClockRate,
+					SDPFmtpLine: c.Fmtp,
+				},
+			}
+			if _, err := utils.CodecParametersFuzzySearch(codec, enabledCodecs); err != nil {
+				// not enabled at all, pion does not negotiate it either
+				continue
+			}
+			if _, err := utils.CodecParametersStrictSearch(codec, enabledCodecs); err != nil {
+				p.pubLogger.Infow("removing offered codec without an enabled payload format", "mime", codec.MimeType, "fmtp", c.Fmtp)
+				removed[c.PayloadType] = true
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		formats := unmatchMedia.MediaName.Formats[:0]
+		for _, c := range codecs {
+			if removed[c.PayloadType] {
+				continue
+			}
+			if apt, ok := strings.CutPrefix(c.Fmtp, "apt="); ok && strings.EqualFold(c.Name, "rtx") {
+				if aptPayload, err := strconv.ParseUint(apt, 10, 8); err == nil && removed[uint8(aptPayload)] {
+					continue
+				}
+			}
+			formats = append(formats, strconv.FormatUint(uint64(c.PayloadType), 10))
+		}
+		unmatchMedia.MediaName.Formats = formats
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		p.pubLogger.Errorw("failed to marshal offer", err)
+		return offer
+	}
+
+	return webrtc.SessionDescription{
+		Type: offer.Type,
+		SDP:  string(bytes),
+	}
+}
+
 func (p *ParticipantImpl) setCodecPreferencesOpusRedForPublisher(offer webrtc.SessionDescription) webrtc.SessionDescription {
 	parsed, unmatchAudios, err := p.TransportManager.GetUnmatchMediaForOffer(offer, "audio")
 	if err != nil || len(unmatchAudios) == 0 {
@@ -200,7 +291,7 @@ func (p *ParticipantImpl) setCodecPreferencesVideoForPublisher(offer webrtc.Sess
 	}
 }
 
-// configure publisher answer for audio track's dtx and stereo settings
+// configure publisher answer for audio track's dtx, stereo, max bitrate and concealment settings
 func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescription) webrtc.SessionDescription {
 	offer := p.TransportManager.LastPublisherOffer()
 	parsedOffer, err := offer.Unmarshal()
@@ -213,6 +304,8 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 		return answer
 	}
 
+	maxAudioBitrate := p.params.Config.Receiver.MaxAudioBitrate
+	noInbandFEC := p.params.Config.Publisher.AudioConcealment == config.AudioConcealmentPLC
 	for _, m := range parsed.MediaDescriptions {
 		switch m.MediaName.Media {
 		case "audio":
@@ -249,25 +342,33 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 				}
 			}
 
-			if ti == nil || (ti.DisableDtx && !ti.Stereo) {
+			useDtx := ti != nil && !ti.DisableDtx
+			stereo := ti != nil && ti.Stereo
+			if !useDtx && !stereo && maxAudioBitrate == 0 && !noInbandFEC {
 				// no need to configure
 				continue
 			}
 
 			opusPT, err := parsed.GetPayloadTypeForCodec(sdp.Codec{Name: "opus"})
 			if err != nil {
-				p.pubLogger.Infow("failed to get opus payload type", "error", err, "trackID", ti.Sid)
+				p.pubLogger.Infow("failed to get opus payload type", "error", err, "trackID", ti.GetSid())
 				continue
 			}
 
 			for i, attr := range m.Attributes {
 				if strings.HasPrefix(attr.String(), fmt.Sprintf("fmtp:%d", opusPT)) {
-					if !ti.DisableDtx {
+					if useDtx {
 						attr.Value += ";usedtx=1"
 					}
-					if ti.Stereo {
+					if stereo {
 						attr.Value += ";stereo=1;maxaveragebitrate=510000"
 					}
+					if maxAudioBitrate != 0 {
+						attr.Value = capOpusMaxAverageBitrate(attr.Value, maxAudioBitrate)
+					}
+					if noInbandFEC {
+						attr.Value = withoutOpusInbandFEC(attr.Value)
+					}
 					m.Attributes[i] = attr
 				}
 			}
@@ -285,3 +386,47 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 	answer.SDP = string(bytes)
 	return answer
 }
+
+// capOpusMaxAverageBitrate sets maxaveragebitrate of an opus fmtp attribute value, e.g. "111 minptime=10;useinbandfec=1",
+// to at most maxBitrate
+func capOpusMaxAverageBitrate(fmtp string, maxBitrate int) string {
+	format, params, _ := strings.Cut(fmtp, " ")
+	var capped []string
+	found := false
+	for _, param := range strings.Split(params, ";") {
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(key), "maxaveragebitrate") {
+			if bitrate, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && bitrate <= maxBitrate {
+				if !found {
+					capped = append(capped, param)
+				}
+			} else if !found {
+				capped = append(capped, fmt.Sprintf("maxaveragebitrate=%d", maxBitrate))
+			}
+			found = true
+			continue
+		}
+		capped = append(capped, param)
+	}
+	if !found {
+		capped = append(capped, fmt.Sprintf("maxaveragebitrate=%d", maxBitrate))
+	}
+	return format + " " + strings.Join(capped, ";")
+}
+
+// withoutOpusInbandFEC asks for no in-band FEC in an opus fmtp attribute value, e.g. "111 minptime=10;useinbandfec=1"
+func withoutOpusInbandFEC(fmtp string) string {
+	format, params, _ := strings.Cut(fmtp, " ")
+	var kept []string
+	for _, param := range strings.Split(params, ";") {
+		key, _, _ := strings.Cut(param, "=")
+		if param == "" || strings.EqualFold(strings.TrimSpace(key), "useinbandfec") {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return format + " " + strings.Join(append(kept, "useinbandfec=0"), ";")
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"time"
+)
+
+type RenegotiationLimit struct {
+	// maximum number of offers accepted from the remote within the window, 0 does not limit them
+	MaxOffers int
+	Window    time.Duration
+}
+
+// renegotiationLimiter limits offers accepted from the remote of a transport in a sliding window,
+// so that a client renegotiating constantly does not peg the node
+type renegotiationLimiter struct {
+	limit  RenegotiationLimit
+	offers []time.Time
+}
+
+func newRenegotiationLimiter(limit RenegotiationLimit) *renegotiationLimiter {
+	return &renegotiationLimiter{
+		limit: limit,
+	}
+}
+
+// allow returns true when an offer received at the given time is within the limit, and counts it
+func (r *renegotiationLimiter) allow(at time.Time) bool {
+	if r.limit.MaxOffers <= 0 {
+		return true
+	}
+
+	i := 0
+	for i < len(r.offers) && at.Sub(r.offers[i]) >= r.limit.Window {
+		i++
+	}
+	r.offers = r.offers[i:]
+
+	if len(r.offers) >= r.limit.MaxOffers {
+		return false
+	}
+	r.offers = append(r.offers, at)
+	return true
+}
+
+// wait returns how long after the given time the next offer is within the limit
+func (r *renegotiationLimiter) wait(at time.Time) time.Duration {
+	if r.limit.MaxOffers <= 0 || len(r.offers) < r.limit.MaxOffers {
+		return 0
+	}
+	return r.offers[len(r.offers)-r.limit.MaxOffers].Add(r.limit.Window).Sub(at)
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/transport/transportfakes"
+)
+
+func TestRenegotiationLimiter(t *testing.T) {
+	limiter := newRenegotiationLimiter(RenegotiationLimit{MaxOffers: 2, Window: time.Minute})
+
+	now := time.Now()
+	require.True(t, limiter.allow(now))
+	require.True(t, limiter.allow(now.Add(10*time.Second)))
+	require.False(t, limiter.allow(now.Add(20*time.Second)))
+
+	// refused offers do not count
+	require.True(t, limiter.allow(now.Add(time.Minute)))
+	require.False(t, limiter.allow(now.Add(time.Minute+5*time.Second)))
+	require.True(t, limiter.allow(now.Add(time.Minute+10*time.Second)))
+
+	// next offer is allowed once the oldest counted one leaves the window
+	require.Equal(t, 50*time.Second, limiter.wait(now.Add(time.Minute+20*time.Second)))
+
+	// no limit
+	unlimited := newRenegotiationLimiter(RenegotiationLimit{})
+	for i := 0; i < 100; i++ {
+		require.True(t, unlimited.allow(now))
+	}
+}
+
+func TestPCTransportRenegotiationLimit(t *testing.T) {
+	handler := &transportfakes.FakeHandler{}
+	answers := make(chan webrtc.SessionDescription, 3)
+	handler.OnAnswerCalls(func(sd webrtc.SessionDescription) error {
+		answers <- sd
+		return nil
+	})
+	transport, err := NewPCTransport(TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Config: &WebRTCConfig{
+			RenegotiationLimit: RenegotiationLimit{MaxOffers: 2, Window: 2 * time.Second},
+		},
+		Handler: handler,
+	})
+	require.NoError(t, err)
+	defer transport.Close()
+
+	remote, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer remote.Close()
+	_, err = remote.CreateDataChannel("data", nil)
+	require.NoError(t, err)
+
+	offer := func() {
+		sd, err := remote.CreateOffer(nil)
+		require.NoError(t, err)
+		require.NoError(t, remote.SetLocalDescription(sd))
+		transport.HandleRemoteDescription(sd)
+	}
+
+	for i := 0; i < 2; i++ {
+		offer()
+		select {
+		case answer := <-answers:
+			require.NoError(t, remote.SetRemoteDescription(answer))
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "answer not sent")
+		}
+	}
+
+	// third offer within the window is held back, without failing negotiation
+	offer()
+	require.Never(t, func() bool {
+		return len(answers) != 0 || handler.OnNegotiationFailedCallCount() != 0
+	}, time.Second, 10*time.Millisecond)
+
+	// and answered once the window allows it
+	select {
+	case answer := <-answers:
+		require.NoError(t, remote.SetRemoteDescription(answer))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "deferred answer not sent")
+	}
+	require.Zero(t, handler.OnNegotiationFailedCallCount())
+}
+
+func TestRenegotiationLimitConfig(t *testing.T) {
+	conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.RenegotiationLimit = config.RenegotiationLimitConfig{MaxOffers: 10, Window: time.Minute}
+	})
+	require.Equal(t, RenegotiationLimit{MaxOffers: 10, Window: time.Minute}, conf.RenegotiationLimit)
+}
@@ -0,0 +1,295 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/utils"
+)
+
+const (
+	// packets of a stream with a mismatched rid buffered while waiting for the rid to be negotiated
+	ridMismatchBufferSize = 50
+)
+
+type RIDMismatchPolicy int
+
+const (
+	// RIDMismatchPolicyDrop leaves the stream to pion, which does not receive it
+	RIDMismatchPolicyDrop RIDMismatchPolicy = iota
+	// RIDMismatchPolicyRetry buffers the packets of the stream till its rid is negotiated by a renegotiation or the
+	// stream switches to a negotiated rid, the stream is left to pion when the buffer fills up
+	RIDMismatchPolicyRetry
+	// RIDMismatchPolicyAssign rewrites the rid to the first negotiated one of the media section without a stream
+	// and not assigned to another stream
+	RIDMismatchPolicyAssign
+)
+
+func (p RIDMismatchPolicy) String() string {
+	switch p {
+	case RIDMismatchPolicyDrop:
+		return "DROP"
+	case RIDMismatchPolicyRetry:
+		return "RETRY"
+	case RIDMismatchPolicyAssign:
+		return "ASSIGN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// NegotiatedRID is a rid negotiated for a media section, receiving when a stream has been bound to it
+type NegotiatedRID struct {
+	RID       string
+	Receiving bool
+}
+
+// NegotiatedRIDsFunc returns the rids negotiated for the media section of the mid, in the order of the offer
+type NegotiatedRIDsFunc func(mid string) []NegotiatedRID
+
+// RIDMismatchInterceptorFactory creates interceptors applying the RID mismatch policy to the first packets of
+// incoming streams, which pion probes to find the mid and rid of streams not signalled with their SSRC
+type RIDMismatchInterceptorFactory struct {
+	policy         RIDMismatchPolicy
+	negotiatedRIDs NegotiatedRIDsFunc
+	logger         logger.Logger
+}
+
+func NewRIDMismatchInterceptorFactory(policy RIDMismatchPolicy, negotiatedRIDs NegotiatedRIDsFunc, logger logger.Logger) *RIDMismatchInterceptorFactory {
+	return &RIDMismatchInterceptorFactory{
+		policy:         policy,
+		negotiatedRIDs: negotiatedRIDs,
+		logger:         logger,
+	}
+}
+
+func (f *RIDMismatchInterceptorFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &RIDMismatchInterceptor{
+		policy:         f.policy,
+		negotiatedRIDs: f.negotiatedRIDs,
+		logger:         f.logger,
+		assignedRIDs:   make(map[string]map[string]uint32),
+	}, nil
+}
+
+type RIDMismatchInterceptor struct {
+	interceptor.NoOp
+	policy         RIDMismatchPolicy
+	negotiatedRIDs NegotiatedRIDsFunc
+	logger         logger.Logger
+
+	lock sync.Mutex
+	// mid -> rid -> ssrc of the stream the rid is assigned to, streams are probed concurrently
+	assignedRIDs map[string]map[string]uint32
+}
+
+func (r *RIDMismatchInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	if r.policy == RIDMismatchPolicyDrop {
+		return reader
+	}
+
+	midExtensionID := utils.GetHeaderExtensionID(info.RTPHeaderExtensions, webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESMidURI})
+	streamIDExtensionID := utils.GetHeaderExtensionID(info.RTPHeaderExtensions, webrtc.RTPHeaderExtensionCapability{URI: sdp.SDESRTPStreamIDURI})
+	if midExtensionID == 0 || streamIDExtensionID == 0 {
+		return reader
+	}
+
+	return &ridMismatchRTPReader{
+		RIDMismatchInterceptor: r,
+		ssrc:                   info.SSRC,
+		reader:                 reader,
+		tryTimes:               simulcastProbeCount,
+		midExtensionID:         uint8(midExtensionID),
+		streamIDExtensionID:    uint8(streamIDExtensionID),
+	}
+}
+
+func (r *RIDMismatchInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for mid, rids := range r.assignedRIDs {
+		for rid, ssrc := range rids {
+			if ssrc == info.SSRC {
+				delete(rids, rid)
+			}
+		}
+		if len(rids) == 0 {
+			delete(r.assignedRIDs, mid)
+		}
+	}
+}
+
+// assignRID returns the first negotiated rid of the media section without a stream and not assigned to another stream
+func (r *RIDMismatchInterceptor) assignRID(ssrc uint32, mid string, negotiatedRIDs []NegotiatedRID) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rids := r.assignedRIDs[mid]
+	for _, negotiated := range negotiatedRIDs {
+		if negotiated.Receiving {
+			continue
+		}
+		if assignedSSRC, ok := rids[negotiated.RID]; ok && assignedSSRC != ssrc {
+			continue
+		}
+
+		if rids == nil {
+			rids = make(map[string]uint32)
+			r.assignedRIDs[mid] = rids
+		}
+		rids[negotiated.RID] = ssrc
+		return negotiated.RID
+	}
+	return ""
+}
+
+func (r *RIDMismatchInterceptor) isNegotiated(mid string, rid string) bool {
+	for _, negotiated := range r.negotiatedRIDs(mid) {
+		if negotiated.RID == rid {
+			return true
+		}
+	}
+	return false
+}
+
+type ridMismatchPacket struct {
+	data       []byte
+	attributes interceptor.Attributes
+}
+
+type ridMismatchRTPReader struct {
+	*RIDMismatchInterceptor
+	ssrc                uint32
+	reader              interceptor.RTPReader
+	tryTimes            int
+	midExtensionID      uint8
+	streamIDExtensionID uint8
+	assignedRID         string
+	pending             []ridMismatchPacket
+}
+
+func (r *ridMismatchRTPReader) Read(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+	if len(r.pending) != 0 {
+		pkt := r.pending[0]
+		r.pending = r.pending[1:]
+		return copy(b, pkt.data), pkt.attributes, nil
+	}
+
+	n, a, err := r.reader.Read(b, a)
+	if r.tryTimes < 0 || err != nil {
+		return n, a, err
+	}
+
+	header := rtp.Header{}
+	hsize, err := header.Unmarshal(b[:n])
+	if err != nil {
+		return n, a, nil
+	}
+	mid := string(header.GetExtension(r.midExtensionID))
+	rid := string(header.GetExtension(r.streamIDExtensionID))
+	if mid == "" || rid == "" {
+		r.tryTimes--
+		return n, a, nil
+	}
+
+	negotiatedRIDs := r.negotiatedRIDs(mid)
+	if len(negotiatedRIDs) == 0 {
+		// not a simulcast media section
+		r.tryTimes = -1
+		return n, a, nil
+	}
+	for _, negotiated := range negotiatedRIDs {
+		if negotiated.RID == rid {
+			r.tryTimes = -1
+			return n, a, nil
+		}
+	}
+
+	r.tryTimes--
+	switch r.policy {
+	case RIDMismatchPolicyRetry:
+		r.tryTimes = -1
+		return r.retry(b, n, a, mid, rid)
+
+	case RIDMismatchPolicyAssign:
+		if r.assignedRID == "" {
+			r.assignedRID = r.assignRID(r.ssrc, mid, negotiatedRIDs)
+			if r.assignedRID == "" {
+				r.logger.Infow("no rid to assign to stream with mismatched rid", "ssrc", r.ssrc, "mid", mid, "rid", rid)
+				r.tryTimes = -1
+				return n, a, nil
+			}
+			r.logger.Infow("assigning rid to stream with mismatched rid", "ssrc", r.ssrc, "mid", mid, "rid", rid, "assignedRID", r.assignedRID)
+		}
+		if err := header.SetExtension(r.streamIDExtensionID, []byte(r.assignedRID)); err != nil {
+			return n, a, nil
+		}
+	}
+
+	hsize2 := header.MarshalSize()
+	if hsize2-hsize+n > len(b) { // not enough buf to set extension
+		return n, a, nil
+	}
+	copy(b[hsize2:], b[hsize:n])
+	if _, err := header.MarshalTo(b); err != nil {
+		return n, a, nil
+	}
+	return hsize2 - hsize + n, a, nil
+}
+
+// retry buffers the packets of the stream till the mismatched rid is negotiated, in which case the buffered packets
+// are read in order, or till a packet carries a negotiated rid, which is read first as the earlier packets would only
+// be consumed by the probing of pion.
+func (r *ridMismatchRTPReader) retry(b []byte, n int, a interceptor.Attributes, mid string, rid string) (int, interceptor.Attributes, error) {
+	r.logger.Debugw("buffering packets of stream with mismatched rid", "ssrc", r.ssrc, "mid", mid, "rid", rid)
+	buffered := []ridMismatchPacket{{data: append([]byte{}, b[:n]...), attributes: a}}
+	for len(buffered) < ridMismatchBufferSize {
+		n, a, err := r.reader.Read(b, a)
+		if err != nil {
+			return n, a, err
+		}
+		buffered = append(buffered, ridMismatchPacket{data: append([]byte{}, b[:n]...), attributes: a})
+
+		if r.isNegotiated(mid, rid) {
+			r.logger.Infow("mismatched rid negotiated", "ssrc", r.ssrc, "mid", mid, "rid", rid, "buffered", len(buffered))
+			r.pending = buffered[1:]
+			return copy(b, buffered[0].data), buffered[0].attributes, nil
+		}
+
+		header := rtp.Header{}
+		if _, err := header.Unmarshal(b[:n]); err != nil {
+			continue
+		}
+		if streamRID := string(header.GetExtension(r.streamIDExtensionID)); streamRID != rid && streamRID != "" {
+			if streamMID := string(header.GetExtension(r.midExtensionID)); streamMID != "" && r.isNegotiated(streamMID, streamRID) {
+				r.logger.Infow("stream switched to negotiated rid", "ssrc", r.ssrc, "mid", mid, "rid", rid, "streamRID", streamRID)
+				return n, a, nil
+			}
+		}
+	}
+
+	r.logger.Infow("no negotiated rid for stream with mismatched rid", "ssrc", r.ssrc, "mid", mid, "rid", rid, "buffered", len(buffered))
+	r.pending = buffered[1:]
+	return copy(b, buffered[0].data), buffered[0].attributes, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	testMidExtID = 1
+	testRIDExtID = 2
+)
+
+func TestRIDMismatchInterceptor(t *testing.T) {
+	// media section 0 negotiated q, h and f, q already receiving, x is negotiated once renegotiated is set
+	renegotiated := false
+	negotiatedRIDs := func(mid string) []NegotiatedRID {
+		if mid != "0" {
+			return nil
+		}
+		rids := []NegotiatedRID{{RID: "q", Receiving: true}, {RID: "h"}, {RID: "f"}}
+		if renegotiated {
+			rids = append(rids, NegotiatedRID{RID: "x"})
+		}
+		return rids
+	}
+
+	newPacket := func(t *testing.T, sn uint16, mid string, rid string) []byte {
+		pkt := &rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: sn, SSRC: 1234},
+			Payload: []byte{0x01, 0x02, 0x03},
+		}
+		require.NoError(t, pkt.Header.SetExtension(testMidExtID, []byte(mid)))
+		if rid != "" {
+			require.NoError(t, pkt.Header.SetExtension(testRIDExtID, []byte(rid)))
+		}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+
+	newInterceptor := func(t *testing.T, policy RIDMismatchPolicy) interceptor.Interceptor {
+		i, err := NewRIDMismatchInterceptorFactory(policy, negotiatedRIDs, logger.GetLogger()).NewInterceptor("")
+		require.NoError(t, err)
+		return i
+	}
+
+	streamInfo := func(ssrc uint32) *interceptor.StreamInfo {
+		return &interceptor.StreamInfo{
+			SSRC: ssrc,
+			RTPHeaderExtensions: []interceptor.RTPHeaderExtension{
+				{URI: sdp.SDESMidURI, ID: testMidExtID},
+				{URI: sdp.SDESRTPStreamIDURI, ID: testRIDExtID},
+			},
+		}
+	}
+
+	// binds a stream reading the given packets, renegotiating x after renegotiateAfter packets when not 0
+	bindStream := func(i interceptor.Interceptor, ssrc uint32, renegotiateAfter int, packets ...[]byte) interceptor.RTPReader {
+		numRead := 0
+		return i.BindRemoteStream(streamInfo(ssrc), interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+			if len(packets) == 0 {
+				return 0, a, io.EOF
+			}
+			n := copy(b, packets[0])
+			packets = packets[1:]
+			numRead++
+			if numRead == renegotiateAfter {
+				renegotiated = true
+			}
+			return n, a, nil
+		}))
+	}
+
+	// returns what pion would read from the stream
+	readStream := func(t *testing.T, reader interceptor.RTPReader) []*rtp.Packet {
+		var read []*rtp.Packet
+		for {
+			b := make([]byte, 1500)
+			n, _, err := reader.Read(b, nil)
+			if err == io.EOF {
+				return read
+			}
+			require.NoError(t, err)
+
+			pkt := &rtp.Packet{}
+			require.NoError(t, pkt.Unmarshal(b[:n]))
+			require.Equal(t, []byte{0x01, 0x02, 0x03}, pkt.Payload)
+			read = append(read, pkt)
+		}
+	}
+
+	rid := func(pkt *rtp.Packet) string {
+		return string(pkt.GetExtension(testRIDExtID))
+	}
+
+	sequenceNumbers := func(read []*rtp.Packet) []uint16 {
+		var sns []uint16
+		for _, pkt := range read {
+			sns = append(sns, pkt.SequenceNumber)
+		}
+		return sns
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		read := readStream(t, bindStream(newInterceptor(t, RIDMismatchPolicyDrop), 1234, 0, newPacket(t, 1, "0", "x"), newPacket(t, 2, "0", "x")))
+		require.Equal(t, "x", rid(read[0]))
+		require.Equal(t, "x", rid(read[1]))
+	})
+
+	t.Run("retry renegotiated", func(t *testing.T) {
+		defer func() { renegotiated = false }()
+
+		read := readStream(t, bindStream(
+			newInterceptor(t, RIDMismatchPolicyRetry),
+			1234,
+			3,
+			newPacket(t, 1, "0", "x"),
+			newPacket(t, 2, "0", "x"),
+			newPacket(t, 3, "0", "x"),
+			newPacket(t, 4, "0", "x"),
+		))
+		// buffered packets are read in order once x is negotiated
+		require.Equal(t, []uint16{1, 2, 3, 4}, sequenceNumbers(read))
+		for _, pkt := range read {
+			require.Equal(t, "x", rid(pkt))
+		}
+	})
+
+	t.Run("retry switched rid", func(t *testing.T) {
+		read := readStream(t, bindStream(
+			newInterceptor(t, RIDMismatchPolicyRetry),
+			1234,
+			0,
+			newPacket(t, 1, "0", "x"),
+			newPacket(t, 2, "0", "x"),
+			newPacket(t, 3, "0", "h"),
+			newPacket(t, 4, "0", "x"),
+		))
+		// packet with the negotiated rid is read first, later packets are not touched
+		require.Equal(t, []uint16{3, 4}, sequenceNumbers(read))
+		require.Equal(t, "h", rid(read[0]))
+		require.Equal(t, "x", rid(read[1]))
+	})
+
+	t.Run("retry buffer full", func(t *testing.T) {
+		var packets [][]byte
+		for sn := uint16(1); sn <= ridMismatchBufferSize+1; sn++ {
+			packets = append(packets, newPacket(t, sn, "0", "x"))
+		}
+		read := readStream(t, bindStream(newInterceptor(t, RIDMismatchPolicyRetry), 1234, 0, packets...))
+		// stream is left to pion with its packets in order
+		require.Len(t, read, ridMismatchBufferSize+1)
+		for i, pkt := range read {
+			require.Equal(t, uint16(i+1), pkt.SequenceNumber)
+			require.Equal(t, "x", rid(pkt))
+		}
+	})
+
+	t.Run("assign", func(t *testing.T) {
+		read := readStream(t, bindStream(newInterceptor(t, RIDMismatchPolicyAssign), 1234, 0, newPacket(t, 1, "0", "x"), newPacket(t, 2, "0", "x")))
+		// first negotiated rid without a stream
+		require.Equal(t, "h", rid(read[0]))
+		require.Equal(t, "h", rid(read[1]))
+		require.Equal(t, uint16(2), read[1].SequenceNumber)
+	})
+
+	t.Run("assign concurrent streams", func(t *testing.T) {
+		i := newInterceptor(t, RIDMismatchPolicyAssign)
+		reader1 := bindStream(i, 1234, 0, newPacket(t, 1, "0", "x"))
+		reader2 := bindStream(i, 5678, 0, newPacket(t, 1, "0", "y"))
+
+		// neither stream is receiving yet, each gets a different rid
+		require.Equal(t, "h", rid(readStream(t, reader1)[0]))
+		require.Equal(t, "f", rid(readStream(t, reader2)[0]))
+
+		// rid of an unbound stream can be assigned again
+		i.UnbindRemoteStream(streamInfo(1234))
+		require.Equal(t, "h", rid(readStream(t, bindStream(i, 9012, 0, newPacket(t, 1, "0", "z")))[0]))
+	})
+
+	t.Run("matching rid", func(t *testing.T) {
+		for _, policy := range []RIDMismatchPolicy{RIDMismatchPolicyRetry, RIDMismatchPolicyAssign} {
+			read := readStream(t, bindStream(newInterceptor(t, policy), 1234, 0, newPacket(t, 1, "0", "f")))
+			require.Equal(t, "f", rid(read[0]))
+		}
+	})
+
+	t.Run("not simulcast", func(t *testing.T) {
+		for _, policy := range []RIDMismatchPolicy{RIDMismatchPolicyRetry, RIDMismatchPolicyAssign} {
+			read := readStream(t, bindStream(newInterceptor(t, policy), 1234, 0, newPacket(t, 1, "1", "x")))
+			require.Equal(t, "x", rid(read[0]))
+		}
+	})
+}
+
+func TestRIDMismatchPolicy(t *testing.T) {
+	conf := newTestWebRTCConfig(t, nil)
+	require.Equal(t, RIDMismatchPolicyDrop, conf.RIDMismatchPolicy)
+
+	conf = newTestWebRTCConfig(t, func(conf *config.Config) {
+		conf.RTC.RIDMismatchPolicy = config.RIDMismatchPolicyAssign
+	})
+	require.Equal(t, RIDMismatchPolicyAssign, conf.RIDMismatchPolicy)
+}
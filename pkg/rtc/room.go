@@ -144,6 +144,8 @@ func NewRoom(
 	agentClient agent.Client,
 	egressLauncher EgressLauncher,
 ) *Room {
+	config.SetRoom(livekit.RoomName(room.Name))
+
 	r := &Room{
 		protoRoom: proto.Clone(room).(*livekit.Room),
 		internal:  internal,
@@ -171,6 +173,12 @@ func NewRoom(
 		disconnectSignalOnResumeNoMessagesParticipants: make(map[livekit.ParticipantIdentity]*disconnectSignalOnResumeNoMessages),
 	}
 
+	r.bufferFactory.SetSSRCCollisionPolicy(config.Receiver.SSRCCollisionPolicy)
+	r.bufferFactory.SetMalformedRTPPolicy(config.Receiver.MalformedRTPPolicy)
+	r.bufferFactory.SetRTXAssociationPolicy(config.Receiver.RTXAssociationPolicy)
+	r.bufferFactory.SetTrackingPacketsRTX(config.Receiver.PacketBufferSizeRTX)
+	r.bufferFactory.SetMaxPacketAge(config.Receiver.PacketBufferMaxAge)
+
 	if r.protoRoom.EmptyTimeout == 0 {
 		r.protoRoom.EmptyTimeout = roomConfig.EmptyTimeout
 	}
@@ -1311,6 +1319,7 @@ func (r *Room) audioUpdateWorker() {
 		if len(changedSpeakers) > 0 {
 			r.sendSpeakerChanges(changedSpeakers)
 		}
+		r.updateActiveSpeakerTracks(nextActiveMap)
 
 		lastActiveMap = nextActiveMap
 
@@ -1318,6 +1327,23 @@ func (r *Room) audioUpdateWorker() {
 	}
 }
 
+// updateActiveSpeakerTracks marks the published audio of active speakers, so that NACKs of subscribers are answered
+// only for active speakers when configured
+func (r *Room) updateActiveSpeakerTracks(activeSpeakers map[livekit.ParticipantID]*livekit.SpeakerInfo) {
+	if !r.audioConfig.NACKActiveSpeakerOnly {
+		return
+	}
+
+	for _, p := range r.GetParticipants() {
+		_, active := activeSpeakers[p.ID()]
+		for _, track := range p.GetPublishedTracks() {
+			if track.Kind() == livekit.TrackType_AUDIO {
+				track.SetActiveSpeaker(active)
+			}
+		}
+	}
+}
+
 func (r *Room) connectionQualityWorker() {
 	ticker := time.NewTicker(connectionquality.UpdateInterval)
 	defer ticker.Stop()
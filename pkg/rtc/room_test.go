@@ -362,6 +362,45 @@ func TestPushAndDequeueUpdates(t *testing.T) {
 	}
 }
 
+func TestRoomPacketBufferSizes(t *testing.T) {
+	conf := WebRTCConfig{
+		Receiver: ReceiverConfig{
+			PacketBufferSizeVideo: 500,
+			PacketBufferSizeAudio: 200,
+			PacketBufferSizeRTX:   500,
+			RoomPacketBufferSizes: []RoomPacketBufferSizes{
+				{RoomNamePattern: "rec-*", Video: 2000, Audio: 1000},
+			},
+		},
+	}
+	newRoom := func(name string) *Room {
+		rm := NewRoom(
+			&livekit.Room{Name: name},
+			nil,
+			conf,
+			config.RoomConfig{EmptyTimeout: 5 * 60, DepartureTimeout: 1},
+			&config.AudioConfig{UpdateInterval: audioUpdateInterval},
+			&livekit.ServerInfo{NodeId: "testnode"},
+			telemetry.NewTelemetryService(webhook.NewDefaultNotifier("", "", nil), &telemetryfakes.FakeAnalyticsService{}),
+			nil, nil,
+		)
+		t.Cleanup(func() { rm.Close(types.ParticipantCloseReasonNone) })
+		return rm
+	}
+
+	recorded := newRoom("rec-standup")
+	require.Equal(t, 2000, recorded.config.Receiver.PacketBufferSizeVideo)
+	require.Equal(t, 1000, recorded.config.Receiver.PacketBufferSizeAudio)
+	require.Equal(t, 500, recorded.config.Receiver.PacketBufferSizeRTX)
+
+	interactive := newRoom("standup")
+	require.Equal(t, 500, interactive.config.Receiver.PacketBufferSizeVideo)
+	require.Equal(t, 200, interactive.config.Receiver.PacketBufferSizeAudio)
+
+	// node defaults are not changed by rooms
+	require.Equal(t, 500, conf.Receiver.PacketBufferSizeVideo)
+}
+
 func TestRoomClosure(t *testing.T) {
 	t.Run("room closes after participant leaves", func(t *testing.T) {
 		rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
@@ -513,6 +552,37 @@ func TestActiveSpeakers(t *testing.T) {
 		})
 	})
 
+	t.Run("audio of active speakers is NACKed", func(t *testing.T) {
+		rm := newRoomWithParticipants(t, testRoomOpts{num: 2, protocol: 3, nackActiveSpeaker: true})
+		defer rm.Close(types.ParticipantCloseReasonNone)
+		participants := rm.GetParticipants()
+		p := participants[0].(*typesfakes.FakeLocalParticipant)
+		track := p.GetPublishedTracks()[0].(*typesfakes.FakeMediaTrack)
+		otherTrack := participants[1].GetPublishedTracks()[0].(*typesfakes.FakeMediaTrack)
+		isActiveSpeaker := func(track *typesfakes.FakeMediaTrack) bool {
+			n := track.SetActiveSpeakerCallCount()
+			return n > 0 && track.SetActiveSpeakerArgsForCall(n-1)
+		}
+
+		p.GetAudioLevelReturns(30, true)
+		testutils.WithTimeout(t, func() string {
+			if !isActiveSpeaker(track) {
+				return "speaker's audio not NACKed"
+			}
+			return ""
+		})
+		require.NotZero(t, otherTrack.SetActiveSpeakerCallCount())
+		require.False(t, isActiveSpeaker(otherTrack))
+
+		p.GetAudioLevelReturns(127, false)
+		testutils.WithTimeout(t, func() string {
+			if isActiveSpeaker(track) {
+				return "audio NACKed after speaker went quiet"
+			}
+			return ""
+		})
+	})
+
 	t.Run("audio level is smoothed", func(t *testing.T) {
 		rm := newRoomWithParticipants(t, testRoomOpts{num: 2, protocol: 3, audioSmoothIntervals: 3})
 		defer rm.Close(types.ParticipantCloseReasonNone)
@@ -790,6 +860,7 @@ type testRoomOpts struct {
 	numHidden            int
 	protocol             types.ProtocolVersion
 	audioSmoothIntervals uint32
+	nackActiveSpeaker    bool
 }
 
 func newRoomWithParticipants(t *testing.T, opts testRoomOpts) *Room {
@@ -804,6 +875,8 @@ func newRoomWithParticipants(t *testing.T, opts testRoomOpts) *Room {
 		&config.AudioConfig{
 			UpdateInterval:  audioUpdateInterval,
 			SmoothIntervals: opts.audioSmoothIntervals,
+
+			NACKActiveSpeakerOnly: opts.nackActiveSpeaker,
 		},
 		&livekit.ServerInfo{
 			Edition:  livekit.ServerInfo_Standard,
@@ -31,6 +31,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 )
 
 const (
@@ -283,10 +284,22 @@ func (t *SubscribedTrack) applySettings() {
 		if temporal != buffer.InvalidLayerTemporal {
 			dt.SetMaxTemporalLayer(temporal)
 		}
+		if err := dt.SetPriority(allocationPriority(t.settings.Priority)); err != nil {
+			t.logger.Infow("could not change track priority", "error", err, "priority", t.settings.Priority)
+		}
 	}
 	t.settingsLock.Unlock()
 }
 
+// allocationPriority converts a subscription priority, where 1 is the highest, to a stream allocator priority,
+// where 1 is the lowest. 0 is unset in both
+func allocationPriority(priority uint32) uint8 {
+	if priority == 0 {
+		return 0
+	}
+	return streamallocator.PriorityMax - uint8(min(priority, uint32(streamallocator.PriorityMax))) + streamallocator.PriorityMin
+}
+
 func (t *SubscribedTrack) NeedsNegotiation() bool {
 	return t.needsNegotiation.Load()
 }
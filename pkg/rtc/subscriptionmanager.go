@@ -42,6 +42,8 @@ var (
 	subscriptionTimeout    = iceFailedTimeoutTotal
 	trackRemoveGracePeriod = time.Second
 	maxUnsubscribeWait     = time.Second
+	// amount of time a deferred subscription waits for settings of the subscriber before subscribing anyway
+	maxDeferredSubscriptionWait = 10 * time.Second
 )
 
 const (
@@ -58,6 +60,11 @@ type SubscriptionManagerParams struct {
 	Telemetry           telemetry.TelemetryService
 
 	SubscriptionLimitVideo, SubscriptionLimitAudio int32
+	// limit of concurrent subscriptions of all kinds, 0 means no limit
+	SubscriptionLimit int32
+	// subscribe to a video track only once the subscriber updates its settings, i.e. is ready to render it,
+	// applies once the subscriber has sent settings of any track, as other clients never do
+	DeferSubscription bool
 }
 
 // SubscriptionManager manages a participant's subscriptions
@@ -66,6 +73,8 @@ type SubscriptionManager struct {
 	lock                sync.RWMutex
 	subscriptions       map[livekit.TrackID]*trackSubscription
 	pendingUnsubscribes atomic.Int32
+	// subscriber sends track settings, deferring subscriptions till it does cannot stall them
+	settingsReceived atomic.Bool
 
 	subscribedVideoCount, subscribedAudioCount atomic.Int32
 
@@ -222,7 +231,13 @@ func (m *SubscriptionManager) UpdateSubscribedTrackSettings(trackID livekit.Trac
 	}
 	m.lock.Unlock()
 
-	sub.setSettings(settings)
+	m.settingsReceived.Store(true)
+	if sub.setSettings(settings) && m.params.DeferSubscription && sub.isDesired() {
+		// subscriber is ready for the deferred subscription, give it the full time to reconcile from now
+		sub.logger.Debugw("subscriber ready, subscribing to deferred track")
+		sub.resetStartedAt()
+		m.queueReconcile(trackID)
+	}
 }
 
 // OnSubscribeStatusChanged callback will be notified when a participant subscribes or unsubscribes to another participant
@@ -299,6 +314,17 @@ func (m *SubscriptionManager) reconcileSubscription(s *trackSubscription) {
 		return
 	}
 	if s.needsSubscribe() {
+		if m.isDeferred(s) {
+			// deferred until the subscriber updates settings of the track, reconciled periodically till it times out
+			if s.durationSinceStart() < maxDeferredSubscriptionWait {
+				return
+			}
+
+			// give it the full time to reconcile from now
+			s.logger.Debugw("no settings from subscriber, subscribing to deferred track")
+			s.deferralExpired.Store(true)
+			s.resetStartedAt()
+		}
 		if m.pendingUnsubscribes.Load() != 0 && s.durationSinceStart() < maxUnsubscribeWait {
 			// enqueue this in a bit, after pending unsubscribes are complete
 			go func() {
@@ -437,6 +463,10 @@ func (m *SubscriptionManager) reconcileWorker() {
 }
 
 func (m *SubscriptionManager) hasCapacityForSubscription(kind livekit.TrackType) bool {
+	if m.params.SubscriptionLimit > 0 && m.subscribedVideoCount.Load()+m.subscribedAudioCount.Load() >= m.params.SubscriptionLimit {
+		return false
+	}
+
 	switch kind {
 	case livekit.TrackType_VIDEO:
 		if m.params.SubscriptionLimitVideo > 0 && m.subscribedVideoCount.Load() >= m.params.SubscriptionLimitVideo {
@@ -451,6 +481,20 @@ func (m *SubscriptionManager) hasCapacityForSubscription(kind livekit.TrackType)
 	return true
 }
 
+// isDeferred returns true when subscribing to a video track waits for the subscriber to update its settings,
+// subscribers that have not sent settings of any track are not deferred
+func (m *SubscriptionManager) isDeferred(s *trackSubscription) bool {
+	if !m.params.DeferSubscription || !m.settingsReceived.Load() || s.hasSettings() || s.deferralExpired.Load() {
+		return false
+	}
+
+	if kind, ok := s.getKind(); ok {
+		return kind == livekit.TrackType_VIDEO
+	}
+	res := m.params.TrackResolver(m.params.Participant.Identity(), s.trackID)
+	return res.Track != nil && res.Track.Kind() == livekit.TrackType_VIDEO
+}
+
 func (m *SubscriptionManager) subscribe(s *trackSubscription) error {
 	s.logger.Debugw("executing subscribe")
 
@@ -738,6 +782,8 @@ type trackSubscription struct {
 	// the later of when subscription was requested OR when the first failure was encountered OR when permission is granted
 	// this timestamp determines when failures are reported
 	subStartedAt atomic.Pointer[time.Time]
+	// deferred subscription stopped waiting for settings of the subscriber
+	deferralExpired atomic.Bool
 }
 
 func newTrackSubscription(subscriberID livekit.ParticipantID, trackID livekit.TrackID, l logger.Logger) *trackSubscription {
@@ -894,14 +940,23 @@ func (s *trackSubscription) setRemovedNotifierLocked(notifier types.ChangeNotifi
 	return true
 }
 
-func (s *trackSubscription) setSettings(settings *livekit.UpdateTrackSettings) {
+// set settings and return true if these are the first settings of the subscription
+func (s *trackSubscription) setSettings(settings *livekit.UpdateTrackSettings) bool {
 	s.lock.Lock()
+	isFirst := s.settings == nil && settings != nil
 	s.settings = settings
 	subTrack := s.subscribedTrack
 	s.lock.Unlock()
 	if subTrack != nil {
 		subTrack.UpdateSubscriberSettings(settings, false)
 	}
+	return isFirst
+}
+
+func (s *trackSubscription) hasSettings() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.settings != nil
 }
 
 // mark the subscription as bound - when we've received the client's answer
@@ -982,6 +1037,11 @@ func (s *trackSubscription) maybeRecordSuccess(ts telemetry.TelemetryService, pI
 	ts.TrackSubscribed(context.Background(), pID, mediaTrack.ToProto(), pi, !eventSent)
 }
 
+func (s *trackSubscription) resetStartedAt() {
+	t := time.Now()
+	s.subStartedAt.Store(&t)
+}
+
 func (s *trackSubscription) durationSinceStart() time.Duration {
 	t := s.subStartedAt.Load()
 	if t == nil {
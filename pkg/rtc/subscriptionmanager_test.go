@@ -36,6 +36,7 @@ func init() {
 	reconcileInterval = 50 * time.Millisecond
 	notFoundTimeout = 200 * time.Millisecond
 	subscriptionTimeout = 200 * time.Millisecond
+	maxDeferredSubscriptionWait = 300 * time.Millisecond
 }
 
 const (
@@ -350,6 +351,86 @@ func TestUpdateSettingsBeforeSubscription(t *testing.T) {
 	require.Equal(t, settings.Height, applied.Height)
 }
 
+func TestDeferredSubscription(t *testing.T) {
+	newDeferredSubscriptionManager := func(t *testing.T, kind livekit.TrackType) (*SubscriptionManager, *atomic.Int32) {
+		sm := newTestSubscriptionManagerWithParams(t, testSubscriptionParams{DeferSubscription: true})
+		t.Cleanup(func() { sm.Close(false) })
+		resolver := newTestResolver(true, true, "pub", "pubID")
+		resolver.kind = kind
+		sm.params.TrackResolver = resolver.Resolve
+		subCount := &atomic.Int32{}
+		sm.params.OnTrackSubscribed = func(subTrack types.SubscribedTrack) {
+			subCount.Add(1)
+		}
+		return sm, subCount
+	}
+	settings := &livekit.UpdateTrackSettings{
+		Width:  100,
+		Height: 100,
+	}
+
+	t.Run("deferred till settings", func(t *testing.T) {
+		sm, subCount := newDeferredSubscriptionManager(t, livekit.TrackType_VIDEO)
+		// subscriber sends settings
+		sm.UpdateSubscribedTrackSettings("other", settings)
+
+		sm.SubscribeToTrack("track")
+		s := sm.subscriptions["track"]
+		require.True(t, s.isDesired())
+
+		// media is not set up until the subscriber is ready
+		time.Sleep(reconcileInterval + subCheckInterval)
+		require.True(t, s.needsSubscribe())
+		require.Nil(t, s.getSubscribedTrack())
+		require.Zero(t, subCount.Load())
+		tm := sm.params.Telemetry.(*telemetryfakes.FakeTelemetryService)
+		require.Zero(t, tm.TrackSubscribeRequestedCallCount())
+
+		sm.UpdateSubscribedTrackSettings("track", settings)
+		require.Eventually(t, func() bool {
+			return subCount.Load() == 1
+		}, subSettleTimeout, subCheckInterval, "track was not subscribed")
+		require.False(t, s.needsSubscribe())
+
+		st := s.getSubscribedTrack().(*typesfakes.FakeSubscribedTrack)
+		require.Eventually(t, func() bool {
+			return st.UpdateSubscriberSettingsCallCount() == 1
+		}, subSettleTimeout, subCheckInterval, "UpdateSubscriberSettings should be called once")
+	})
+
+	t.Run("subscribed when settings do not arrive", func(t *testing.T) {
+		sm, subCount := newDeferredSubscriptionManager(t, livekit.TrackType_VIDEO)
+		sm.UpdateSubscribedTrackSettings("other", settings)
+
+		sm.SubscribeToTrack("track")
+		time.Sleep(reconcileInterval + subCheckInterval)
+		require.Zero(t, subCount.Load())
+
+		require.Eventually(t, func() bool {
+			return subCount.Load() == 1
+		}, maxDeferredSubscriptionWait+subSettleTimeout, subCheckInterval, "track was not subscribed")
+	})
+
+	t.Run("not deferred for subscribers that do not send settings", func(t *testing.T) {
+		sm, subCount := newDeferredSubscriptionManager(t, livekit.TrackType_VIDEO)
+
+		sm.SubscribeToTrack("track")
+		require.Eventually(t, func() bool {
+			return subCount.Load() == 1
+		}, subSettleTimeout, subCheckInterval, "track was not subscribed")
+	})
+
+	t.Run("audio not deferred", func(t *testing.T) {
+		sm, subCount := newDeferredSubscriptionManager(t, livekit.TrackType_AUDIO)
+		sm.UpdateSubscribedTrackSettings("other", settings)
+
+		sm.SubscribeToTrack("track")
+		require.Eventually(t, func() bool {
+			return subCount.Load() == 1
+		}, subSettleTimeout, subCheckInterval, "track was not subscribed")
+	})
+}
+
 func TestSubscriptionLimits(t *testing.T) {
 	sm := newTestSubscriptionManagerWithParams(t, testSubscriptionParams{
 		SubscriptionLimitAudio: 1,
@@ -447,9 +528,56 @@ func TestSubscriptionLimits(t *testing.T) {
 	require.Len(t, sm.GetSubscribedTracks(), 1)
 }
 
+func TestSubscriptionLimitTotal(t *testing.T) {
+	sm := newTestSubscriptionManagerWithParams(t, testSubscriptionParams{
+		SubscriptionLimit: 2,
+	})
+	defer sm.Close(false)
+	resolver := newTestResolver(true, true, "pub", "pubID")
+	sm.params.TrackResolver = resolver.Resolve
+	subCount := atomic.Int32{}
+	sm.params.OnTrackSubscribed = func(subTrack types.SubscribedTrack) {
+		subCount.Add(1)
+	}
+
+	sm.SubscribeToTrack("track1")
+	sm.SubscribeToTrack("track2")
+	require.Eventually(t, func() bool {
+		return subCount.Load() == 2
+	}, subSettleTimeout, subCheckInterval, "tracks were not subscribed")
+
+	// beyond the cap, subscription is refused and stays pending
+	sm.SubscribeToTrack("track3")
+	s3 := sm.subscriptions["track3"]
+	time.Sleep(subscriptionTimeout * 2)
+	require.True(t, s3.needsSubscribe())
+	require.Nil(t, s3.getSubscribedTrack())
+	require.Len(t, sm.GetSubscribedTracks(), 2)
+
+	tm := sm.params.Telemetry.(*telemetryfakes.FakeTelemetryService)
+	require.Equal(t, 1, tm.TrackSubscribeFailedCallCount())
+	_, _, trackID, err, isUserError := tm.TrackSubscribeFailedArgsForCall(0)
+	require.Equal(t, livekit.TrackID("track3"), trackID)
+	require.ErrorIs(t, err, ErrSubscriptionLimitExceeded)
+	require.True(t, isUserError)
+
+	// subscribed once another subscription is released
+	s1 := sm.subscriptions["track1"]
+	sm.UnsubscribeFromTrack("track1")
+	time.Sleep(reconcileInterval)
+	setTestSubscribedTrackClosed(t, s1.getSubscribedTrack(), false)
+
+	require.Eventually(t, func() bool {
+		return subCount.Load() == 3
+	}, subSettleTimeout, subCheckInterval, "track was not subscribed after release")
+	require.NotNil(t, s3.getSubscribedTrack())
+}
+
 type testSubscriptionParams struct {
 	SubscriptionLimitAudio int32
 	SubscriptionLimitVideo int32
+	SubscriptionLimit      int32
+	DeferSubscription      bool
 }
 
 func newTestSubscriptionManager(t *testing.T) *SubscriptionManager {
@@ -473,6 +601,8 @@ func newTestSubscriptionManagerWithParams(t *testing.T, params testSubscriptionP
 		Telemetry:              &telemetryfakes.FakeTelemetryService{},
 		SubscriptionLimitAudio: params.SubscriptionLimitAudio,
 		SubscriptionLimitVideo: params.SubscriptionLimitVideo,
+		SubscriptionLimit:      params.SubscriptionLimit,
+		DeferSubscription:      params.DeferSubscription,
 	})
 }
 
@@ -484,6 +614,7 @@ type testResolver struct {
 	pubID         livekit.ParticipantID
 
 	paused bool
+	kind   livekit.TrackType
 }
 
 func newTestResolver(hasPermission bool, hasTrack bool, pubIdentity livekit.ParticipantIdentity, pubID livekit.ParticipantID) *testResolver {
@@ -519,6 +650,7 @@ func (t *testResolver) Resolve(identity livekit.ParticipantIdentity, trackID liv
 		st.PublisherIdentityReturns(t.pubIdentity)
 		mt.AddSubscriberReturns(st, nil)
 		st.MediaTrackReturns(mt)
+		mt.KindReturns(t.kind)
 		res.Track = mt
 	}
 	return res
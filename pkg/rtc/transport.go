@@ -15,6 +15,7 @@
 package rtc
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
 	"strconv"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/bep/debounce"
 	"github.com/pion/dtls/v2/pkg/crypto/elliptic"
+	"github.com/pion/dtls/v2/pkg/crypto/fingerprint"
 	"github.com/pion/ice/v2"
 	"github.com/pion/interceptor"
 	"github.com/pion/interceptor/pkg/cc"
@@ -35,6 +37,7 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
 	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
 
 	lkinterceptor "github.com/livekit/mediatransportutil/pkg/interceptor"
 	lktwcc "github.com/livekit/mediatransportutil/pkg/twcc"
@@ -46,6 +49,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/rtc/transport"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu"
 	sfuinterceptor "github.com/livekit/livekit-server/pkg/sfu/interceptor"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	pd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/playoutdelay"
@@ -68,6 +72,8 @@ const (
 	iceFailedTimeoutTotal  = iceFailedTimeout + iceDisconnectedTimeout // total time between connecting and failure
 	iceKeepaliveInterval   = 2 * time.Second                           // pion's default
 
+	iceRestartOnDisconnectInterval = iceFailedTimeoutTotal // time a restart gets to connect before restarting again
+
 	minTcpICEConnectTimeout = 5 * time.Second
 	maxTcpICEConnectTimeout = 12 * time.Second // js-sdk has a default 15s timeout for first connection, let server detect failure earlier before that
 
@@ -96,6 +102,7 @@ const (
 	signalSendOffer
 	signalRemoteDescriptionReceived
 	signalICERestart
+	signalDeferredRemoteOffer
 )
 
 func (s signal) String() string {
@@ -112,6 +119,8 @@ func (s signal) String() string {
 		return "REMOTE_DESCRIPTION_RECEIVED"
 	case signalICERestart:
 		return "ICE_RESTART"
+	case signalDeferredRemoteOffer:
+		return "DEFERRED_REMOTE_OFFER"
 	default:
 		return fmt.Sprintf("%d", int(s))
 	}
@@ -147,21 +156,29 @@ type PCTransport struct {
 	pc     *webrtc.PeerConnection
 	me     *webrtc.MediaEngine
 
+	dscpNet *dscpNet
+
 	lock sync.RWMutex
 
 	firstOfferReceived      bool
 	firstOfferNoDataChannel bool
+	renegotiationLimiter    *renegotiationLimiter
 	reliableDC              *webrtc.DataChannel
 	reliableDCOpened        bool
+	reliableDCDialedAt      time.Time
 	lossyDC                 *webrtc.DataChannel
 	lossyDCOpened           bool
+	lossyDCDialedAt         time.Time
 
 	iceStartedAt               time.Time
 	iceConnectedAt             time.Time
+	iceRestartOnDisconnectAt   time.Time
 	firstConnectedAt           time.Time
 	connectedAt                time.Time
 	tcpICETimer                *time.Timer
 	connectAfterICETimer       *time.Timer // timer to wait for pc to connect after ice connected
+	iceGatheringTimer          *time.Timer
+	iceGatheringTimedOut       atomic.Bool
 	resetShortConnOnICERestart atomic.Bool
 	signalingRTT               atomic.Uint32 // milliseconds
 
@@ -197,6 +214,8 @@ type PCTransport struct {
 	signalStateCheckTimer     *time.Timer
 	currentOfferIceCredential string // ice user:pwd, for publish side ice restart checking
 	pendingRestartIceOffer    *webrtc.SessionDescription
+	deferredRemoteOffer       *webrtc.SessionDescription // latest offer over the renegotiation limit
+	deferredRemoteOfferTimer  *time.Timer
 
 	connectionDetails *types.ICEConnectionDetails
 }
@@ -210,6 +229,7 @@ type TransportParams struct {
 	Twcc                         *lktwcc.Responder
 	DirectionConfig              DirectionConfig
 	CongestionControlConfig      config.CongestionControlConfig
+	EgressBudget                 *streamallocator.EgressBudget
 	EnabledCodecs                []*livekit.Codec
 	Logger                       logger.Logger
 	Transport                    livekit.SignalTarget
@@ -219,9 +239,10 @@ type TransportParams struct {
 	IsSendSide                   bool
 	AllowPlayoutDelay            bool
 	DataChannelMaxBufferedAmount uint64
+	PacketTrace                  *sfu.PacketTrace
 }
 
-func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimator cc.BandwidthEstimator)) (*webrtc.PeerConnection, *webrtc.MediaEngine, error) {
+func newPeerConnection(params TransportParams, dscp *dscpNet, onBandwidthEstimator func(estimator cc.BandwidthEstimator)) (*webrtc.PeerConnection, *webrtc.MediaEngine, error) {
 	directionConfig := params.DirectionConfig
 	if params.AllowPlayoutDelay {
 		directionConfig.RTPHeaderExtension.Video = append(directionConfig.RTPHeaderExtension.Video, pd.PlayoutDelayURI)
@@ -265,6 +286,11 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 		se.SetLite(false)
 	}
 	se.SetDTLSRetransmissionInterval(dtlsRetransmissionInterval)
+	if params.Config.DTLSCipherSuites != nil {
+		se.SetNet(params.Config.DTLSCipherSuites.newNet(dscp, params.Logger))
+	} else if dscp != nil {
+		se.SetNet(dscp)
+	}
 	se.SetICETimeouts(iceDisconnectedTimeout, iceFailedTimeout, iceKeepaliveInterval)
 
 	// if client don't support prflx over relay, we should not expose private address to it, use single external ip as host candidate
@@ -325,6 +351,12 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 					ir.Add(tf)
 				}
 			}
+		} else if params.CongestionControlConfig.AlwaysTransportCC {
+			// stamp transport wide sequence numbers for subscribers to send feedback on, without estimating from it
+			tf, err := twcc.NewHeaderExtensionInterceptor()
+			if err == nil {
+				ir.Add(tf)
+			}
 		}
 	} else {
 		// sfu only use interceptor to send XR but don't read response from it (use buffer instead),
@@ -368,20 +400,55 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 			}
 		}
 	}
+	var pc *webrtc.PeerConnection
+	if !params.IsSendSide && params.Config.RIDMismatchPolicy != RIDMismatchPolicyDrop {
+		// behind unhandle simulcast interceptor, which sets the rid of migrated streams
+		ir.Add(NewRIDMismatchInterceptorFactory(params.Config.RIDMismatchPolicy, func(mid string) []NegotiatedRID {
+			return getNegotiatedRIDs(pc, mid)
+		}, params.Logger))
+	}
 	// put rtx interceptor behind unhandle simulcast interceptor so it can get the correct mid & rid
 	ir.Add(sfuinterceptor.NewRTXInfoExtractorFactory(setTWCCForVideo, func(repair, base uint32) {
 		params.Logger.Debugw("rtx pair found from extension", "repair", repair, "base", base)
-		params.Config.BufferFactory.SetRTXPair(repair, base)
+		if err := params.Config.BufferFactory.SetRTXPair(repair, base); err != nil {
+			params.Logger.Warnw("could not set rtx pair", err, "repair", repair, "base", base)
+		}
 	}, params.Logger))
+	if params.PacketTrace != nil {
+		// added last to see packets as the application does, traced with the logger of the peer connection
+		trace := sfu.NewPacketTrace(params.PacketTrace.IsEnabled, params.Logger.Infow)
+		ir.Add(NewPacketTraceInterceptorFactory(directionConfig.RTPHeaderExtension, trace))
+	}
 	api := webrtc.NewAPI(
 		webrtc.WithMediaEngine(me),
 		webrtc.WithSettingEngine(se),
 		webrtc.WithInterceptorRegistry(ir),
 	)
-	pc, err := api.NewPeerConnection(params.Config.Configuration)
+	pc, err = api.NewPeerConnection(params.Config.Configuration)
 	return pc, me, err
 }
 
+func getNegotiatedRIDs(pc *webrtc.PeerConnection, mid string) []NegotiatedRID {
+	if pc == nil {
+		return nil
+	}
+
+	for _, tr := range pc.GetTransceivers() {
+		if tr.Mid() != mid || tr.Receiver() == nil {
+			continue
+		}
+
+		var rids []NegotiatedRID
+		for _, track := range tr.Receiver().Tracks() {
+			if track.RID() != "" {
+				rids = append(rids, NegotiatedRID{RID: track.RID(), Receiving: track.SSRC() != 0})
+			}
+		}
+		return rids
+	}
+	return nil
+}
+
 func NewPCTransport(params TransportParams) (*PCTransport, error) {
 	if params.Logger == nil {
 		params.Logger = logger.GetLogger()
@@ -397,16 +464,25 @@ func NewPCTransport(params TransportParams) (*PCTransport, error) {
 		}),
 		previousTrackDescription: make(map[string]*trackDescription),
 		canReuseTransceiver:      true,
+		renegotiationLimiter:     newRenegotiationLimiter(params.Config.RenegotiationLimit),
 		connectionDetails:        types.NewICEConnectionDetails(params.Transport, params.Logger),
 	}
 	if params.IsSendSide {
 		t.streamAllocator = streamallocator.NewStreamAllocator(streamallocator.StreamAllocatorParams{
-			Config: params.CongestionControlConfig,
-			Logger: params.Logger.WithComponent(utils.ComponentCongestionControl),
+			Config:       params.CongestionControlConfig,
+			EgressBudget: params.EgressBudget,
+			Logger:       params.Logger.WithComponent(utils.ComponentCongestionControl),
 		})
 		t.streamAllocator.OnStreamStateChange(params.Handler.OnStreamStateChange)
+		t.streamAllocator.OnNoRTCP(params.Handler.OnNoRTCP)
 		t.streamAllocator.Start()
-		t.pacer = pacer.NewPassThrough(params.Logger)
+		if params.Config.SubscriberSendQueueSize > 0 {
+			t.pacer = pacer.NewNoQueue(params.Logger)
+			t.pacer.SetMaxQueuedPackets(params.Config.SubscriberSendQueueSize)
+		} else {
+			t.pacer = pacer.NewPassThrough(params.Logger)
+		}
+		t.pacer.SetMTU(params.Config.MTU)
 	}
 
 	if err := t.createPeerConnection(); err != nil {
@@ -419,8 +495,12 @@ func NewPCTransport(params TransportParams) (*PCTransport, error) {
 }
 
 func (t *PCTransport) createPeerConnection() error {
+	if t.params.Config.DSCP != nil {
+		t.dscpNet = t.params.Config.DSCP.newNet()
+	}
+
 	var bwe cc.BandwidthEstimator
-	pc, me, err := newPeerConnection(t.params, func(estimator cc.BandwidthEstimator) {
+	pc, me, err := newPeerConnection(t.params, t.dscpNet, func(estimator cc.BandwidthEstimator) {
 		bwe = estimator
 	})
 	if err != nil {
@@ -446,6 +526,25 @@ func (t *PCTransport) createPeerConnection() error {
 	return nil
 }
 
+// updateDSCP marks the sockets by the media negotiated, video when any media section negotiated a video codec
+func (t *PCTransport) updateDSCP() {
+	if t.dscpNet == nil {
+		return
+	}
+
+	video := false
+	for _, tr := range t.pc.GetTransceivers() {
+		if tr.Kind() != webrtc.RTPCodecTypeVideo || tr.Mid() == "" || tr.Direction() == webrtc.RTPTransceiverDirectionInactive {
+			continue
+		}
+		if tr.Receiver() != nil && len(tr.Receiver().GetParameters().Codecs) != 0 {
+			video = true
+			break
+		}
+	}
+	t.dscpNet.setVideoNegotiated(video)
+}
+
 func (t *PCTransport) GetPacer() pacer.Pacer {
 	return t.pacer
 }
@@ -592,7 +691,15 @@ func (t *PCTransport) setConnectedAt(at time.Time) bool {
 
 func (t *PCTransport) onICEGatheringStateChange(state webrtc.ICEGathererState) {
 	t.params.Logger.Debugw("ice gathering state change", "state", state.String())
-	if state != webrtc.ICEGathererStateComplete {
+	switch state {
+	case webrtc.ICEGathererStateGathering:
+		t.setICEGatheringTimer()
+		return
+
+	case webrtc.ICEGathererStateComplete:
+		t.clearICEGatheringTimer()
+
+	default:
 		return
 	}
 
@@ -601,6 +708,60 @@ func (t *PCTransport) onICEGatheringStateChange(state webrtc.ICEGathererState) {
 	})
 }
 
+// setICEGatheringTimer bounds the gathering that just started, on timeout gathering is considered complete with
+// the candidates gathered so far, for example when waiting on a TURN allocation that does not get a response
+func (t *PCTransport) setICEGatheringTimer() {
+	t.iceGatheringTimedOut.Store(false)
+
+	timeout := t.params.Config.ICEGatheringTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.iceGatheringTimer != nil {
+		t.iceGatheringTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		t.lock.Lock()
+		if t.iceGatheringTimer != timer {
+			t.lock.Unlock()
+			return
+		}
+		t.iceGatheringTimer = nil
+		t.lock.Unlock()
+
+		if t.pc.ICEGatheringState() != webrtc.ICEGatheringStateGathering {
+			return
+		}
+
+		t.params.Logger.Infow("ICE gathering timeout, completing with available candidates", "timeout", timeout)
+		t.iceGatheringTimedOut.Store(true)
+		t.postEvent(event{
+			signal: signalICEGatheringComplete,
+		})
+	})
+	t.iceGatheringTimer = timer
+}
+
+func (t *PCTransport) clearICEGatheringTimer() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.iceGatheringTimer != nil {
+		t.iceGatheringTimer.Stop()
+		t.iceGatheringTimer = nil
+	}
+}
+
+// isICEGathering returns true while gathering is in progress and has not timed out
+func (t *PCTransport) isICEGathering() bool {
+	return t.pc.ICEGatheringState() == webrtc.ICEGatheringStateGathering && !t.iceGatheringTimedOut.Load()
+}
+
 func (t *PCTransport) onICECandidateTrickle(c *webrtc.ICECandidate) {
 	t.postEvent(event{
 		signal: signalLocalICECandidate,
@@ -638,9 +799,31 @@ func (t *PCTransport) onICEConnectionStateChange(state webrtc.ICEConnectionState
 
 	case webrtc.ICEConnectionStateChecking:
 		t.setICEStartedAt(time.Now())
+
+	case webrtc.ICEConnectionStateDisconnected:
+		// only the offering side can restart, a restart resets the ICE failed timeout
+		if t.params.IsOfferer && t.params.Config.ICERestartPolicy == ICERestartPolicyOnDisconnect && t.shouldRestartICEOnDisconnect(time.Now()) {
+			t.params.Logger.Infow("ICE disconnected, restarting")
+			if err := t.ICERestart(); err != nil {
+				t.params.Logger.Warnw("failed to restart ICE on disconnect", err)
+			}
+		}
 	}
 }
 
+// shouldRestartICEOnDisconnect debounces restarts on disconnect, a flapping connection is restarted once until the
+// restart had the time to connect
+func (t *PCTransport) shouldRestartICEOnDisconnect(at time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.iceRestartOnDisconnectAt.IsZero() && at.Sub(t.iceRestartOnDisconnectAt) < iceRestartOnDisconnectInterval {
+		return false
+	}
+	t.iceRestartOnDisconnectAt = at
+	return true
+}
+
 func (t *PCTransport) onPeerConnectionStateChange(state webrtc.PeerConnectionState) {
 	t.params.Logger.Debugw("peer connection state change", "state", state.String())
 	switch state {
@@ -654,10 +837,71 @@ func (t *PCTransport) onPeerConnectionStateChange(state webrtc.PeerConnectionSta
 		}
 	case webrtc.PeerConnectionStateFailed:
 		t.clearConnTimer()
+		if t.isDTLSFingerprintMismatch() {
+			t.handleDTLSFingerprintMismatch()
+			return
+		}
 		t.handleConnectionFailed(false)
 	}
 }
 
+func (t *PCTransport) isDTLSFingerprintMismatch() bool {
+	sctpTransport := t.pc.SCTP()
+	if sctpTransport == nil {
+		return false
+	}
+	dtlsTransport := sctpTransport.Transport()
+	if dtlsTransport == nil || dtlsTransport.State() != webrtc.DTLSTransportStateFailed {
+		return false
+	}
+
+	return isDTLSFingerprintMismatch(dtlsTransport.GetRemoteCertificate(), t.pc.RemoteDescription())
+}
+
+func (t *PCTransport) handleDTLSFingerprintMismatch() {
+	switch t.params.Config.DTLSFingerprintMismatchPolicy {
+	case DTLSFingerprintMismatchPolicyRenegotiate:
+		// a new session negotiates fresh fingerprints on both sides
+		t.params.Logger.Infow("DTLS fingerprint mismatch, renegotiating")
+		t.params.Handler.OnNegotiationFailed()
+	default:
+		t.params.Logger.Warnw("DTLS fingerprint mismatch, rejecting connection", nil)
+		t.params.Handler.OnFailed(false)
+	}
+}
+
+// isDTLSFingerprintMismatch checks if the certificate presented by the remote does not match
+// the fingerprint signalled in the remote description
+func isDTLSFingerprintMismatch(remoteCert []byte, remoteDescription *webrtc.SessionDescription) bool {
+	if len(remoteCert) == 0 || remoteDescription == nil {
+		return false
+	}
+
+	cert, err := x509.ParseCertificate(remoteCert)
+	if err != nil {
+		return false
+	}
+
+	parsed, err := remoteDescription.Unmarshal()
+	if err != nil {
+		return false
+	}
+	fp, fpHash, err := lksdp.ExtractFingerprint(parsed)
+	if err != nil {
+		return false
+	}
+
+	hashAlgo, err := fingerprint.HashFromString(fpHash)
+	if err != nil {
+		return false
+	}
+	certFP, err := fingerprint.Fingerprint(cert, hashAlgo)
+	if err != nil {
+		return false
+	}
+	return !strings.EqualFold(certFP, fp)
+}
+
 func (t *PCTransport) onDataChannel(dc *webrtc.DataChannel) {
 	t.params.Logger.Debugw(dc.Label() + " data channel open")
 	switch dc.Label() {
@@ -696,11 +940,20 @@ func (t *PCTransport) isFullyEstablished() bool {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	dataChannelReady := t.firstOfferNoDataChannel || (t.reliableDCOpened && t.lossyDCOpened)
+	now := time.Now()
+	grace := t.params.DirectionConfig.ACKGrace
+	dataChannelReady := t.firstOfferNoDataChannel ||
+		(isDataChannelReady(t.reliableDCOpened, t.reliableDCDialedAt, grace, now) && isDataChannelReady(t.lossyDCOpened, t.lossyDCDialedAt, grace, now))
 
 	return dataChannelReady && !t.connectedAt.IsZero()
 }
 
+// isDataChannelReady returns true if the data channel is open or, while an acknowledgement is awaited under strict
+// ACKs, it was dialed no longer than the grace period ago
+func isDataChannelReady(opened bool, dialedAt time.Time, grace time.Duration, now time.Time) bool {
+	return opened || (!dialedAt.IsZero() && now.Sub(dialedAt) <= grace)
+}
+
 func (t *PCTransport) SetPreferTCP(preferTCP bool) {
 	t.preferTCP.Store(preferTCP)
 }
@@ -767,6 +1020,7 @@ func (t *PCTransport) AddTrack(trackLocal webrtc.TrackLocal, params types.AddTra
 	}
 
 	configureAudioTransceiver(transceiver, params.Stereo, !params.Red || !t.params.ClientInfo.SupportsAudioRED())
+	configureVideoTransceiver(transceiver, t.params.DirectionConfig.MaxVideoCodecs, params.MimeTypes)
 	return
 }
 
@@ -783,6 +1037,7 @@ func (t *PCTransport) AddTransceiverFromTrack(trackLocal webrtc.TrackLocal, para
 	}
 
 	configureAudioTransceiver(transceiver, params.Stereo, !params.Red || !t.params.ClientInfo.SupportsAudioRED())
+	configureVideoTransceiver(transceiver, t.params.DirectionConfig.MaxVideoCodecs, params.MimeTypes)
 
 	return
 }
@@ -819,6 +1074,8 @@ func (t *PCTransport) CreateDataChannel(label string, dci *webrtc.DataChannelIni
 	var (
 		dcPtr   **webrtc.DataChannel
 		dcReady *bool
+
+		dcDialedAt *time.Time
 	)
 	switch dc.Label() {
 	default:
@@ -829,9 +1086,11 @@ func (t *PCTransport) CreateDataChannel(label string, dci *webrtc.DataChannelIni
 	case ReliableDataChannel:
 		dcPtr = &t.reliableDC
 		dcReady = &t.reliableDCOpened
+		dcDialedAt = &t.reliableDCDialedAt
 	case LossyDataChannel:
 		dcPtr = &t.lossyDC
 		dcReady = &t.lossyDCOpened
+		dcDialedAt = &t.lossyDCDialedAt
 	}
 
 	dcReadyHandler := func() {
@@ -865,6 +1124,26 @@ func (t *PCTransport) CreateDataChannel(label string, dci *webrtc.DataChannelIni
 			}
 			dcReadyHandler()
 		})
+		if grace := t.params.DirectionConfig.ACKGrace; grace > 0 {
+			// tolerate a late acknowledgement, fail only if it is still missing after the grace period
+			dc.OnDial(func() {
+				t.lock.Lock()
+				*dcDialedAt = time.Now()
+				t.lock.Unlock()
+
+				t.maybeNotifyFullyEstablished()
+
+				time.AfterFunc(grace, func() {
+					t.lock.RLock()
+					acked := *dcReady
+					t.lock.RUnlock()
+					if !acked && !t.isClosed.Load() {
+						t.params.Logger.Infow(dc.Label()+" data channel not acknowledged within grace", "grace", grace)
+						t.handleConnectionFailed(false)
+					}
+				})
+			})
+		}
 	} else {
 		dc.OnOpen(func() {
 			if t.params.IsSendSide {
@@ -962,6 +1241,7 @@ func (t *PCTransport) Close() {
 
 	<-t.eventsQueue.Stop()
 	t.clearSignalStateCheckTimer()
+	t.clearDeferredRemoteOfferTimer()
 
 	if t.streamAllocator != nil {
 		t.streamAllocator.Stop()
@@ -973,6 +1253,7 @@ func (t *PCTransport) Close() {
 	_ = t.pc.Close()
 
 	t.clearConnTimer()
+	t.clearICEGatheringTimer()
 }
 
 func (t *PCTransport) clearConnTimer() {
@@ -1047,6 +1328,10 @@ func (t *PCTransport) ICERestart() error {
 		t.params.Logger.Warnw("trying to restart ICE on closed peer connection", nil)
 		return ErrIceRestartOnClosedPeerConnection
 	}
+	if t.params.Config.ICERestartPolicy == ICERestartPolicyNever {
+		t.params.Logger.Debugw("not restarting ICE, restart policy is never")
+		return nil
+	}
 
 	t.postEvent(event{
 		signal: signalICERestart,
@@ -1065,6 +1350,7 @@ func (t *PCTransport) AddTrackToStreamAllocator(subTrack types.SubscribedTrack)
 
 	t.streamAllocator.AddTrack(subTrack.DownTrack(), streamallocator.AddTrackParams{
 		Source:      subTrack.MediaTrack().Source(),
+		Priority:    subTrack.DownTrack().Priority(),
 		IsSimulcast: subTrack.MediaTrack().IsSimulcast(),
 		PublisherID: subTrack.MediaTrack().PublisherID(),
 	})
@@ -1094,6 +1380,14 @@ func (t *PCTransport) SetChannelCapacityOfStreamAllocator(channelCapacity int64)
 	t.streamAllocator.SetChannelCapacity(channelCapacity)
 }
 
+func (t *PCTransport) GetBandwidthEstimateOfStreamAllocator() *streamallocator.BandwidthEstimate {
+	if t.streamAllocator == nil {
+		return nil
+	}
+
+	return t.streamAllocator.GetBandwidthEstimate()
+}
+
 func (t *PCTransport) preparePC(previousAnswer webrtc.SessionDescription) error {
 	// sticky data channel to first m-lines, if someday we don't send sdp without media streams to
 	// client's subscribe pc after joining, should change this step
@@ -1288,6 +1582,8 @@ func (t *PCTransport) postEvent(e event) {
 			err = e.handleRemoteDescriptionReceived(e)
 		case signalICERestart:
 			err = e.handleICERestart(e)
+		case signalDeferredRemoteOffer:
+			err = e.handleDeferredRemoteOffer(e)
 		}
 		if err != nil {
 			if !e.isClosed.Load() {
@@ -1360,6 +1656,17 @@ func (t *PCTransport) clearLocalDescriptionSent() {
 func (t *PCTransport) handleLocalICECandidate(e event) error {
 	c := e.data.(*webrtc.ICECandidate)
 
+	if c != nil && t.iceGatheringTimedOut.Load() {
+		t.params.Logger.Debugw("dropping local candidate gathered after timeout", "candidate", c.String())
+		return nil
+	}
+
+	if c != nil && t.params.Config.ICECandidatePriority != nil {
+		adjusted := *c
+		adjusted.Priority = t.params.Config.ICECandidatePriority(c.Address, c.Typ, c.Priority)
+		c = &adjusted
+	}
+
 	filtered := false
 	if c != nil {
 		if t.preferTCP.Load() && c.Protocol != webrtc.ICEProtocolTCP {
@@ -1433,6 +1740,13 @@ func (t *PCTransport) filterCandidates(sd webrtc.SessionDescription, preferTCP,
 		filteredAttrs := make([]sdp.Attribute, 0, len(attrs))
 		for _, a := range attrs {
 			if a.IsICECandidate() {
+				if isLocal && t.params.Config.ICECandidatePriority != nil {
+					if c, err := ice.UnmarshalCandidate(a.Value); err == nil {
+						if typ, err := webrtc.NewICECandidateType(c.Type().String()); err == nil {
+							a.Value = setCandidatePriority(a.Value, t.params.Config.ICECandidatePriority(c.Address(), typ, c.Priority()))
+						}
+					}
+				}
 				c, err := ice.UnmarshalCandidate(a.Value)
 				if err != nil {
 					t.params.Logger.Errorw("failed to unmarshal candidate in sdp", err, "isLocal", isLocal, "sdp", sd.SDP)
@@ -1471,6 +1785,112 @@ func (t *PCTransport) filterCandidates(sd webrtc.SessionDescription, preferTCP,
 	return sd
 }
 
+// removeRTCPReducedSize drops the rtcp-rsize attribute pion always adds to media sections,
+// so that the remote does not negotiate reduced-size RTCP (RFC 5506)
+func (t *PCTransport) removeRTCPReducedSize(sd webrtc.SessionDescription) webrtc.SessionDescription {
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not unmarshal SDP to remove rtcp-rsize", err)
+		return sd
+	}
+
+	for _, m := range parsed.MediaDescriptions {
+		attrs := make([]sdp.Attribute, 0, len(m.Attributes))
+		for _, a := range m.Attributes {
+			if a.Key != sdp.AttrKeyRTCPRsize {
+				attrs = append(attrs, a)
+			}
+		}
+		m.Attributes = attrs
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not marshal SDP to remove rtcp-rsize", err)
+		return sd
+	}
+	sd.SDP = string(bytes)
+	return sd
+}
+
+// mapLocalSSRCs replaces the SSRCs pion allocated for local streams with the ones they are sent with
+func (t *PCTransport) mapLocalSSRCs(sd webrtc.SessionDescription) webrtc.SessionDescription {
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not unmarshal SDP to map SSRCs", err)
+		return sd
+	}
+
+	mapSSRC := func(field string) string {
+		ssrc, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return field
+		}
+		return strconv.FormatUint(uint64(t.params.Config.BufferFactory.LocalSSRC(uint32(ssrc))), 10)
+	}
+	for _, m := range parsed.MediaDescriptions {
+		for i, a := range m.Attributes {
+			switch a.Key {
+			case sdp.AttrKeySSRC:
+				// <ssrc> <attribute>
+				fields := strings.SplitN(a.Value, " ", 2)
+				fields[0] = mapSSRC(fields[0])
+				m.Attributes[i].Value = strings.Join(fields, " ")
+			case sdp.AttrKeySSRCGroup:
+				// <semantics> <ssrc>...
+				fields := strings.Split(a.Value, " ")
+				for j := 1; j < len(fields); j++ {
+					fields[j] = mapSSRC(fields[j])
+				}
+				m.Attributes[i].Value = strings.Join(fields, " ")
+			}
+		}
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not marshal SDP to map SSRCs", err)
+		return sd
+	}
+	sd.SDP = string(bytes)
+	return sd
+}
+
+// orderAttributes moves the attributes with the given keys to the front of the session
+// and of each media section, in the order of the keys. Other attributes keep their relative order.
+func (t *PCTransport) orderAttributes(sd webrtc.SessionDescription, order []string) webrtc.SessionDescription {
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not unmarshal SDP to order attributes", err)
+		return sd
+	}
+
+	rank := func(a sdp.Attribute) int {
+		if i := slices.Index(order, a.Key); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	sortAttributes := func(attrs []sdp.Attribute) {
+		slices.SortStableFunc(attrs, func(a, b sdp.Attribute) int {
+			return rank(a) - rank(b)
+		})
+	}
+
+	sortAttributes(parsed.Attributes)
+	for _, m := range parsed.MediaDescriptions {
+		sortAttributes(m.Attributes)
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		t.params.Logger.Warnw("could not marshal SDP to order attributes", err)
+		return sd
+	}
+	sd.SDP = string(bytes)
+	return sd
+}
+
 func (t *PCTransport) clearSignalStateCheckTimer() {
 	if t.signalStateCheckTimer != nil {
 		t.signalStateCheckTimer.Stop()
@@ -1576,6 +1996,12 @@ func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) error {
 	// see filtered candidates.
 	//
 	offer = t.filterCandidates(offer, preferTCP, true)
+	if t.params.Config.DisableRTCPReducedSize {
+		offer = t.removeRTCPReducedSize(offer)
+	}
+	if t.params.Config.SSRCRangeEnd != 0 {
+		offer = t.mapLocalSSRCs(offer)
+	}
 	if preferTCP {
 		t.params.Logger.Debugw("local offer (filtered)", "sdp", offer.SDP)
 	}
@@ -1649,6 +2075,8 @@ func (t *PCTransport) setRemoteDescription(sd webrtc.SessionDescription) error {
 			t.previousTrackDescription = make(map[string]*trackDescription)
 		}
 		t.lock.Unlock()
+
+		t.updateDSCP()
 	}
 
 	for _, c := range t.pendingRemoteCandidates {
@@ -1685,6 +2113,7 @@ func (t *PCTransport) createAndSendAnswer() error {
 		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "local_description").Add(1)
 		return errors.Wrap(err, "setting local description failed")
 	}
+	t.updateDSCP()
 
 	//
 	// Filter after setting local description as pion expects the answer
@@ -1693,6 +2122,15 @@ func (t *PCTransport) createAndSendAnswer() error {
 	// see filtered candidates.
 	//
 	answer = t.filterCandidates(answer, preferTCP, true)
+	if t.params.Config.DisableRTCPReducedSize {
+		answer = t.removeRTCPReducedSize(answer)
+	}
+	if t.params.Config.SSRCRangeEnd != 0 {
+		answer = t.mapLocalSSRCs(answer)
+	}
+	if len(t.params.Config.AnswerAttributeOrder) != 0 {
+		answer = t.orderAttributes(answer, t.params.Config.AnswerAttributeOrder)
+	}
 	if preferTCP {
 		t.params.Logger.Debugw("local answer (filtered)", "sdp", answer.SDP)
 	}
@@ -1706,7 +2144,56 @@ func (t *PCTransport) createAndSendAnswer() error {
 	return t.localDescriptionSent()
 }
 
+func (t *PCTransport) deferRemoteOffer(sd *webrtc.SessionDescription, wait time.Duration) {
+	limit := t.params.Config.RenegotiationLimit
+	t.params.Logger.Infow(
+		"deferring remote offer over renegotiation limit",
+		"maxOffers", limit.MaxOffers,
+		"window", limit.Window,
+		"wait", wait,
+	)
+
+	t.deferredRemoteOffer = sd
+	if t.deferredRemoteOfferTimer != nil {
+		return
+	}
+	t.deferredRemoteOfferTimer = time.AfterFunc(wait, func() {
+		t.postEvent(event{
+			signal: signalDeferredRemoteOffer,
+		})
+	})
+}
+
+func (t *PCTransport) clearDeferredRemoteOfferTimer() {
+	if t.deferredRemoteOfferTimer != nil {
+		t.deferredRemoteOfferTimer.Stop()
+		t.deferredRemoteOfferTimer = nil
+	}
+}
+
+func (t *PCTransport) handleDeferredRemoteOffer(_ event) error {
+	t.deferredRemoteOfferTimer = nil
+
+	sd := t.deferredRemoteOffer
+	if sd == nil {
+		return nil
+	}
+	t.deferredRemoteOffer = nil
+	return t.handleRemoteOfferReceived(sd)
+}
+
 func (t *PCTransport) handleRemoteOfferReceived(sd *webrtc.SessionDescription) error {
+	// only accessed from the events queue
+	now := time.Now()
+	if !t.renegotiationLimiter.allow(now) {
+		// an offer over the limit is not a negotiation failure, failing it would trigger a full reconnect,
+		// hold the latest one and answer it when the window allows
+		t.deferRemoteOffer(sd, t.renegotiationLimiter.wait(now))
+		return nil
+	}
+	// a newer offer supersedes one still held back
+	t.deferredRemoteOffer = nil
+
 	parsed, err := sd.Unmarshal()
 	if err != nil {
 		return nil
@@ -1735,7 +2222,7 @@ func (t *PCTransport) handleRemoteOfferReceived(sd *webrtc.SessionDescription) e
 		t.clearLocalDescriptionSent()
 	}
 
-	if offerRestartICE && t.pc.ICEGatheringState() == webrtc.ICEGatheringStateGathering {
+	if offerRestartICE && t.isICEGathering() {
 		t.params.Logger.Debugw("remote offer restart ice while ice gathering")
 		t.pendingRestartIceOffer = sd
 		return nil
@@ -1752,7 +2239,9 @@ func (t *PCTransport) handleRemoteOfferReceived(sd *webrtc.SessionDescription) e
 	if len(rtxRepairs) > 0 {
 		t.params.Logger.Debugw("rtx pairs found from sdp", "ssrcs", rtxRepairs)
 		for repair, base := range rtxRepairs {
-			t.params.Config.BufferFactory.SetRTXPair(repair, base)
+			if err := t.params.Config.BufferFactory.SetRTXPair(repair, base); err != nil {
+				t.params.Logger.Warnw("could not set rtx pair", err, "repair", repair, "base", base)
+			}
 		}
 	}
 
@@ -1794,7 +2283,7 @@ func (t *PCTransport) doICERestart() error {
 	}
 
 	// if restart is requested, and we are not ready, then continue afterwards
-	if t.pc.ICEGatheringState() == webrtc.ICEGatheringStateGathering {
+	if t.isICEGathering() {
 		t.params.Logger.Debugw("deferring ICE restart to after gathering")
 		t.restartAfterGathering = true
 		return nil
@@ -1879,6 +2368,51 @@ func configureAudioTransceiver(tr *webrtc.RTPTransceiver, stereo bool, nack bool
 	tr.SetCodecPreferences(configCodecs)
 }
 
+// configure subscriber transceiver to offer at most maxVideoCodecs video codecs in order of preference, each mime type
+// counts once whatever the number of its format parameter variants. Codecs the track is published in are always
+// offered so that the track can be subscribed to
+func configureVideoTransceiver(tr *webrtc.RTPTransceiver, maxVideoCodecs int, publishedMimeTypes []string) {
+	sender := tr.Sender()
+	if sender == nil || maxVideoCodecs <= 0 || tr.Kind() != webrtc.RTPCodecTypeVideo {
+		return
+	}
+
+	containsMimeType := func(mimeTypes []string, mimeType string) bool {
+		return slices.ContainsFunc(mimeTypes, func(m string) bool { return strings.EqualFold(m, mimeType) })
+	}
+	codecs := sender.GetParameters().Codecs
+	var offeredMimeTypes []string
+	for _, c := range codecs {
+		if strings.EqualFold(c.MimeType, videoRTXMimeType) || containsMimeType(offeredMimeTypes, c.MimeType) {
+			continue
+		}
+		if len(offeredMimeTypes) < maxVideoCodecs || containsMimeType(publishedMimeTypes, c.MimeType) {
+			offeredMimeTypes = append(offeredMimeTypes, c.MimeType)
+		}
+	}
+
+	offeredPayloadTypes := make(map[webrtc.PayloadType]bool)
+	configCodecs := make([]webrtc.RTPCodecParameters, 0, len(codecs))
+	for _, c := range codecs {
+		if containsMimeType(offeredMimeTypes, c.MimeType) {
+			offeredPayloadTypes[c.PayloadType] = true
+			configCodecs = append(configCodecs, c)
+		}
+	}
+	for _, c := range codecs {
+		if !strings.EqualFold(c.MimeType, videoRTXMimeType) {
+			continue
+		}
+		// RTX of an offered codec
+		apt, err := strconv.ParseUint(strings.TrimPrefix(c.SDPFmtpLine, "apt="), 10, 8)
+		if err == nil && offeredPayloadTypes[webrtc.PayloadType(apt)] {
+			configCodecs = append(configCodecs, c)
+		}
+	}
+
+	tr.SetCodecPreferences(configCodecs)
+}
+
 func nonSimulcastRTXRepairsFromSDP(s *sdp.SessionDescription, logger logger.Logger) map[uint32]uint32 {
 	rtxRepairFlows := map[uint32]uint32{}
 	for _, media := range s.MediaDescriptions {
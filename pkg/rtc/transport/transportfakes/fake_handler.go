@@ -62,6 +62,10 @@ type FakeHandler struct {
 	onNegotiationStateChangedArgsForCall []struct {
 		arg1 transport.NegotiationState
 	}
+	OnNoRTCPStub        func()
+	onNoRTCPMutex       sync.RWMutex
+	onNoRTCPArgsForCall []struct {
+	}
 	OnOfferStub        func(webrtc.SessionDescription) error
 	onOfferMutex       sync.RWMutex
 	onOfferArgsForCall []struct {
@@ -391,6 +395,30 @@ func (fake *FakeHandler) OnNegotiationStateChangedArgsForCall(i int) transport.N
 	return argsForCall.arg1
 }
 
+func (fake *FakeHandler) OnNoRTCP() {
+	fake.onNoRTCPMutex.Lock()
+	fake.onNoRTCPArgsForCall = append(fake.onNoRTCPArgsForCall, struct {
+	}{})
+	stub := fake.OnNoRTCPStub
+	fake.recordInvocation("OnNoRTCP", []interface{}{})
+	fake.onNoRTCPMutex.Unlock()
+	if stub != nil {
+		fake.OnNoRTCPStub()
+	}
+}
+
+func (fake *FakeHandler) OnNoRTCPCallCount() int {
+	fake.onNoRTCPMutex.RLock()
+	defer fake.onNoRTCPMutex.RUnlock()
+	return len(fake.onNoRTCPArgsForCall)
+}
+
+func (fake *FakeHandler) OnNoRTCPCalls(stub func()) {
+	fake.onNoRTCPMutex.Lock()
+	defer fake.onNoRTCPMutex.Unlock()
+	fake.OnNoRTCPStub = stub
+}
+
 func (fake *FakeHandler) OnOffer(arg1 webrtc.SessionDescription) error {
 	fake.onOfferMutex.Lock()
 	ret, specificReturn := fake.onOfferReturnsOnCall[len(fake.onOfferArgsForCall)]
@@ -565,6 +593,8 @@ func (fake *FakeHandler) Invocations() map[string][][]interface{} {
 	defer fake.onNegotiationFailedMutex.RUnlock()
 	fake.onNegotiationStateChangedMutex.RLock()
 	defer fake.onNegotiationStateChangedMutex.RUnlock()
+	fake.onNoRTCPMutex.RLock()
+	defer fake.onNoRTCPMutex.RUnlock()
 	fake.onOfferMutex.RLock()
 	defer fake.onOfferMutex.RUnlock()
 	fake.onStreamStateChangeMutex.RLock()
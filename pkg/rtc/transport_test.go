@@ -15,7 +15,15 @@
 package rtc
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"math/big"
+	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -25,9 +33,11 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
 
 	"github.com/livekit/livekit-server/pkg/rtc/transport"
 	"github.com/livekit/livekit-server/pkg/rtc/transport/transportfakes"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/testutils"
 	"github.com/livekit/protocol/livekit"
 )
@@ -622,3 +632,539 @@ func TestConfigureAudioTransceiver(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigureVideoTransceiver(t *testing.T) {
+	codecs := []*livekit.Codec{
+		{Mime: webrtc.MimeTypeVP8},
+		{Mime: webrtc.MimeTypeVP9},
+		{Mime: webrtc.MimeTypeH264},
+		{Mime: webrtc.MimeTypeAV1},
+		{Mime: videoRTXMimeType},
+	}
+	offeredMimeTypes := func(t *testing.T, maxVideoCodecs int, publishedMimeTypes []string) []string {
+		me, err := createMediaEngine(codecs, DirectionConfig{}, false)
+		require.NoError(t, err)
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		defer pc.Close()
+
+		tr, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RtpTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		require.NoError(t, err)
+		configureVideoTransceiver(tr, maxVideoCodecs, publishedMimeTypes)
+
+		offer, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		require.Len(t, parsed.MediaDescriptions, 1)
+		offered, err := codecsFromMediaDescription(parsed.MediaDescriptions[0])
+		require.NoError(t, err)
+
+		var mimeTypes []string
+		var numRTX int
+		for _, c := range offered {
+			if strings.EqualFold(c.Name, "rtx") {
+				numRTX++
+				continue
+			}
+			mimeTypes = append(mimeTypes, "video/"+c.Name)
+		}
+		// each codec with its RTX
+		require.Equal(t, len(mimeTypes), numRTX)
+		return mimeTypes
+	}
+
+	// VP9 is registered with two profiles, H264 with three
+	all := []string{"video/VP8", "video/VP9", "video/VP9", "video/H264", "video/H264", "video/H264", "video/AV1"}
+	require.Equal(t, all, offeredMimeTypes(t, 0, nil))
+	require.Equal(t, all, offeredMimeTypes(t, 4, nil))
+	require.Equal(t, all[:3], offeredMimeTypes(t, 2, nil))
+	require.Equal(t, all[:1], offeredMimeTypes(t, 1, []string{"video/vp8"}))
+
+	// codecs the track is published in are offered beyond the max
+	require.Equal(t, []string{"video/VP8", "video/AV1"}, offeredMimeTypes(t, 1, []string{"video/av1"}))
+	require.Equal(t, all[:6], offeredMimeTypes(t, 2, []string{"video/H264"}))
+}
+
+func TestSSRCRange(t *testing.T) {
+	conf := &WebRTCConfig{SSRCRangeStart: 1000, SSRCRangeEnd: 1999}
+	conf.SetBufferFactory(buffer.NewFactoryOfBufferFactory(500, 200).CreateBufferFactory())
+	handler := &transportfakes.FakeHandler{}
+	transport, err := NewPCTransport(TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Config:              conf,
+		EnabledCodecs: []*livekit.Codec{
+			{Mime: webrtc.MimeTypeOpus},
+			{Mime: webrtc.MimeTypeVP8},
+		},
+		IsOfferer: true,
+		Handler:   handler,
+	})
+	require.NoError(t, err)
+	defer transport.Close()
+
+	var pionSSRCs []uint32
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		tr, err := transport.pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		require.NoError(t, err)
+		pionSSRCs = append(pionSSRCs, uint32(tr.Sender().GetParameters().Encodings[0].SSRC))
+	}
+
+	offer := atomic.Value{}
+	handler.OnOfferCalls(func(sd webrtc.SessionDescription) error {
+		offer.Store(&sd)
+		return nil
+	})
+	transport.Negotiate(true)
+	require.Eventually(t, func() bool {
+		return offer.Load() != nil
+	}, 10*time.Second, 10*time.Millisecond, "offer not sent")
+
+	parsed, err := offer.Load().(*webrtc.SessionDescription).Unmarshal()
+	require.NoError(t, err)
+	require.Len(t, parsed.MediaDescriptions, 2)
+	var offeredSSRCs []uint32
+	for _, m := range parsed.MediaDescriptions {
+		for _, a := range m.Attributes {
+			if a.Key != sdp.AttrKeySSRC {
+				continue
+			}
+			ssrc, err := strconv.ParseUint(strings.Fields(a.Value)[0], 10, 32)
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, ssrc, uint64(1000))
+			require.LessOrEqual(t, ssrc, uint64(1999))
+			if !slices.Contains(offeredSSRCs, uint32(ssrc)) {
+				offeredSSRCs = append(offeredSSRCs, uint32(ssrc))
+			}
+		}
+	}
+
+	// down tracks are sent with the offered SSRCs
+	require.Len(t, offeredSSRCs, 2)
+	for i, ssrc := range pionSSRCs {
+		require.Equal(t, offeredSSRCs[i], conf.BufferFactory.LocalSSRC(ssrc))
+	}
+}
+
+func TestDTLSFingerprintMismatch(t *testing.T) {
+	// fingerprint of a certificate the offerer does not use
+	otherCert, _ := generateTestCertificate(t)
+	otherFingerprints, err := otherCert.GetFingerprints()
+	require.NoError(t, err)
+	replaceFingerprint := func(sd webrtc.SessionDescription) webrtc.SessionDescription {
+		parsed, err := sd.Unmarshal()
+		require.NoError(t, err)
+		fpLine := otherFingerprints[0].Algorithm + " " + otherFingerprints[0].Value
+		for i := range parsed.Attributes {
+			if parsed.Attributes[i].Key == "fingerprint" {
+				parsed.Attributes[i].Value = fpLine
+			}
+		}
+		for _, m := range parsed.MediaDescriptions {
+			for i := range m.Attributes {
+				if m.Attributes[i].Key == "fingerprint" {
+					m.Attributes[i].Value = fpLine
+				}
+			}
+		}
+		bytes, err := parsed.Marshal()
+		require.NoError(t, err)
+		sd.SDP = string(bytes)
+		return sd
+	}
+
+	for _, policy := range []DTLSFingerprintMismatchPolicy{DTLSFingerprintMismatchPolicyReject, DTLSFingerprintMismatchPolicyRenegotiate} {
+		t.Run(fmt.Sprintf("policy %d", policy), func(t *testing.T) {
+			params := TransportParams{
+				ParticipantID:       "id",
+				ParticipantIdentity: "identity",
+				Config:              &WebRTCConfig{DTLSFingerprintMismatchPolicy: policy},
+				IsOfferer:           true,
+			}
+
+			paramsA := params
+			handlerA := &transportfakes.FakeHandler{}
+			paramsA.Handler = handlerA
+			transportA, err := NewPCTransport(paramsA)
+			require.NoError(t, err)
+			_, err = transportA.pc.CreateDataChannel(ReliableDataChannel, nil)
+			require.NoError(t, err)
+			defer transportA.Close()
+
+			paramsB := params
+			handlerB := &transportfakes.FakeHandler{}
+			paramsB.Handler = handlerB
+			paramsB.IsOfferer = false
+			transportB, err := NewPCTransport(paramsB)
+			require.NoError(t, err)
+			defer transportB.Close()
+
+			handleICEExchange(t, transportA, transportB, handlerA, handlerB)
+			handlerB.OnAnswerCalls(func(answer webrtc.SessionDescription) error {
+				transportA.HandleRemoteDescription(answer)
+				return nil
+			})
+			handlerA.OnOfferCalls(func(offer webrtc.SessionDescription) error {
+				transportB.HandleRemoteDescription(replaceFingerprint(offer))
+				return nil
+			})
+			transportA.Negotiate(true)
+
+			require.Eventually(t, func() bool {
+				return transportB.pc.ConnectionState() == webrtc.PeerConnectionStateFailed
+			}, 10*time.Second, 10*time.Millisecond, "answerer did not fail")
+
+			switch policy {
+			case DTLSFingerprintMismatchPolicyReject:
+				require.Eventually(t, func() bool {
+					return handlerB.OnFailedCallCount() == 1
+				}, 5*time.Second, 10*time.Millisecond)
+				require.Zero(t, handlerB.OnNegotiationFailedCallCount())
+			case DTLSFingerprintMismatchPolicyRenegotiate:
+				require.Eventually(t, func() bool {
+					return handlerB.OnNegotiationFailedCallCount() == 1
+				}, 5*time.Second, 10*time.Millisecond)
+				require.Zero(t, handlerB.OnFailedCallCount())
+			}
+		})
+	}
+}
+
+func TestIsDTLSFingerprintMismatch(t *testing.T) {
+	cert, certDER := generateTestCertificate(t)
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{Certificates: []webrtc.Certificate{*cert}})
+	require.NoError(t, err)
+	defer pc.Close()
+	_, err = pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+
+	_, otherCertDER := generateTestCertificate(t)
+
+	require.False(t, isDTLSFingerprintMismatch(certDER, &offer))
+	require.True(t, isDTLSFingerprintMismatch(otherCertDER, &offer))
+	require.False(t, isDTLSFingerprintMismatch(nil, &offer))
+}
+
+func TestIsDataChannelReady(t *testing.T) {
+	dialedAt := time.Now()
+	grace := 2 * time.Second
+
+	// not dialed yet
+	require.False(t, isDataChannelReady(false, time.Time{}, grace, dialedAt))
+
+	// without grace, the acknowledgement is needed
+	require.False(t, isDataChannelReady(false, dialedAt, 0, dialedAt.Add(time.Millisecond)))
+	require.True(t, isDataChannelReady(true, dialedAt, 0, dialedAt.Add(time.Millisecond)))
+
+	// late acknowledgement within the grace period is tolerated
+	require.True(t, isDataChannelReady(false, dialedAt, grace, dialedAt.Add(time.Second)))
+	require.True(t, isDataChannelReady(true, dialedAt, grace, dialedAt.Add(time.Minute)))
+
+	// missing acknowledgement is enforced once the grace period passed
+	require.False(t, isDataChannelReady(false, dialedAt, grace, dialedAt.Add(grace+time.Millisecond)))
+}
+
+func generateTestCertificate(t *testing.T) (*webrtc.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	cert := webrtc.CertificateFromX509(key, parsed)
+	return &cert, der
+}
+
+func TestICEGatheringTimeout(t *testing.T) {
+	// a TURN server which never answers, keeps relay candidate gathering pending
+	turnConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer turnConn.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := turnConn.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	conf := &WebRTCConfig{ICEGatheringTimeout: 500 * time.Millisecond}
+	conf.Configuration.ICEServers = []webrtc.ICEServer{
+		{
+			URLs:       []string{fmt.Sprintf("turn:%s?transport=udp", turnConn.LocalAddr().String())},
+			Username:   "user",
+			Credential: "pass",
+		},
+	}
+
+	handler := &transportfakes.FakeHandler{}
+	transport, err := NewPCTransport(TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Config:              conf,
+		IsOfferer:           true,
+		Handler:             handler,
+	})
+	require.NoError(t, err)
+	defer transport.Close()
+	_, err = transport.pc.CreateDataChannel(ReliableDataChannel, nil)
+	require.NoError(t, err)
+
+	var offerCount atomic.Int32
+	handler.OnOfferCalls(func(sd webrtc.SessionDescription) error {
+		offerCount.Inc()
+		return nil
+	})
+	transport.Negotiate(true)
+	require.Eventually(t, func() bool {
+		return offerCount.Load() == 1
+	}, 10*time.Second, 10*time.Millisecond, "offer not received")
+
+	// ICE restart is deferred while gathering
+	require.NoError(t, transport.ICERestart())
+	require.Eventually(t, func() bool {
+		return offerCount.Load() == 2
+	}, 5*time.Second, 10*time.Millisecond, "deferred ICE restart not processed on gathering timeout")
+
+	// gathering completed at the timeout while the relay candidate is still pending
+	require.Equal(t, webrtc.ICEGatheringStateGathering, transport.pc.ICEGatheringState())
+	require.False(t, transport.isICEGathering())
+}
+
+func TestICERestartOnDisconnect(t *testing.T) {
+	for _, policy := range []ICERestartPolicy{ICERestartPolicyOnNetworkChange, ICERestartPolicyOnDisconnect, ICERestartPolicyNever} {
+		t.Run(fmt.Sprintf("policy %d", policy), func(t *testing.T) {
+			handler := &transportfakes.FakeHandler{}
+			transport, err := NewPCTransport(TransportParams{
+				ParticipantID:       "id",
+				ParticipantIdentity: "identity",
+				Config:              &WebRTCConfig{ICERestartPolicy: policy},
+				IsOfferer:           true,
+				Handler:             handler,
+			})
+			require.NoError(t, err)
+			defer transport.Close()
+			_, err = transport.pc.CreateDataChannel(ReliableDataChannel, nil)
+			require.NoError(t, err)
+
+			var restarts atomic.Int32
+			handler.OnOfferCalls(func(sd webrtc.SessionDescription) error {
+				restarts.Inc()
+				return nil
+			})
+
+			transport.onICEConnectionStateChange(webrtc.ICEConnectionStateDisconnected)
+			if policy == ICERestartPolicyOnDisconnect {
+				require.Eventually(t, func() bool {
+					return restarts.Load() == 1
+				}, 5*time.Second, 10*time.Millisecond, "ICE not restarted on disconnect")
+
+				// flapping is restarted once until the restart had the time to connect
+				transport.onICEConnectionStateChange(webrtc.ICEConnectionStateConnected)
+				transport.onICEConnectionStateChange(webrtc.ICEConnectionStateDisconnected)
+				require.Never(t, func() bool {
+					return restarts.Load() != 1
+				}, 500*time.Millisecond, 10*time.Millisecond, "ICE restarted again on disconnect")
+				require.False(t, transport.shouldRestartICEOnDisconnect(time.Now()))
+				require.True(t, transport.shouldRestartICEOnDisconnect(time.Now().Add(iceRestartOnDisconnectInterval)))
+			} else {
+				require.Never(t, func() bool {
+					return restarts.Load() != 0
+				}, 500*time.Millisecond, 10*time.Millisecond, "ICE restarted on disconnect")
+			}
+
+			// restarts of a resuming client are done unless the policy is never
+			before := restarts.Load()
+			require.NoError(t, transport.ICERestart())
+			if policy == ICERestartPolicyNever {
+				require.Never(t, func() bool {
+					return restarts.Load() != before
+				}, 500*time.Millisecond, 10*time.Millisecond, "ICE restarted on resume")
+			} else {
+				require.Eventually(t, func() bool {
+					return restarts.Load() == before+1
+				}, 5*time.Second, 10*time.Millisecond, "ICE not restarted on resume")
+			}
+		})
+	}
+}
+
+func TestRTCPReducedSize(t *testing.T) {
+	for _, disable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("disable=%v", disable), func(t *testing.T) {
+			handler := &transportfakes.FakeHandler{}
+			transport, err := NewPCTransport(TransportParams{
+				ParticipantID:       "id",
+				ParticipantIdentity: "identity",
+				Config:              &WebRTCConfig{DisableRTCPReducedSize: disable},
+				EnabledCodecs: []*livekit.Codec{
+					{Mime: webrtc.MimeTypeOpus},
+					{Mime: webrtc.MimeTypeVP8},
+				},
+				IsOfferer: true,
+				Handler:   handler,
+			})
+			require.NoError(t, err)
+			defer transport.Close()
+
+			_, err = transport.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+			require.NoError(t, err)
+			_, err = transport.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+			require.NoError(t, err)
+
+			offer := atomic.Value{}
+			handler.OnOfferCalls(func(sd webrtc.SessionDescription) error {
+				offer.Store(&sd)
+				return nil
+			})
+			transport.Negotiate(true)
+			require.Eventually(t, func() bool {
+				return offer.Load() != nil
+			}, 10*time.Second, 10*time.Millisecond, "offer not sent")
+
+			parsed, err := offer.Load().(*webrtc.SessionDescription).Unmarshal()
+			require.NoError(t, err)
+			require.Len(t, parsed.MediaDescriptions, 2)
+			for _, m := range parsed.MediaDescriptions {
+				_, rsize := m.Attribute(sdp.AttrKeyRTCPRsize)
+				require.Equal(t, !disable, rsize, m.MediaName.Media)
+			}
+		})
+	}
+}
+
+func TestAnswerAttributeOrder(t *testing.T) {
+	order := []string{sdp.AttrKeyMID, "rtpmap", "ice-ufrag", sdp.AttrKeyRTCPRsize}
+
+	t.Run("sections", func(t *testing.T) {
+		transport, err := NewPCTransport(TransportParams{
+			ParticipantID:       "id",
+			ParticipantIdentity: "identity",
+			Config:              &WebRTCConfig{},
+		})
+		require.NoError(t, err)
+		defer transport.Close()
+
+		sd := transport.orderAttributes(webrtc.SessionDescription{
+			Type: webrtc.SDPTypeAnswer,
+			SDP: "v=0\r\n" +
+				"o=- 1 2 IN IP4 0.0.0.0\r\n" +
+				"s=-\r\n" +
+				"t=0 0\r\n" +
+				"a=msid-semantic:WMS *\r\n" +
+				"a=group:BUNDLE 0\r\n" +
+				"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+				"c=IN IP4 0.0.0.0\r\n" +
+				"a=setup:active\r\n" +
+				"a=ice-ufrag:ufrag\r\n" +
+				"a=mid:0\r\n" +
+				"a=rtcp-mux\r\n" +
+				"a=rtcp-rsize\r\n" +
+				"a=rtpmap:111 opus/48000/2\r\n" +
+				"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+				"a=recvonly\r\n",
+		}, order)
+
+		parsed, err := sd.Unmarshal()
+		require.NoError(t, err)
+		require.Equal(t, []string{"msid-semantic", "group"}, attributeKeys(parsed.Attributes))
+		require.Len(t, parsed.MediaDescriptions, 1)
+		require.Equal(t,
+			[]string{"mid", "rtpmap", "ice-ufrag", "rtcp-rsize", "setup", "rtcp-mux", "fmtp", "recvonly"},
+			attributeKeys(parsed.MediaDescriptions[0].Attributes),
+		)
+	})
+
+	for _, configured := range [][]string{nil, order} {
+		t.Run(fmt.Sprintf("answer/order=%v", configured), func(t *testing.T) {
+			offerer, err := NewPCTransport(TransportParams{
+				ParticipantID:       "offerer",
+				ParticipantIdentity: "offerer",
+				Config:              &WebRTCConfig{},
+				EnabledCodecs: []*livekit.Codec{
+					{Mime: webrtc.MimeTypeOpus},
+					{Mime: webrtc.MimeTypeVP8},
+				},
+				IsOfferer: true,
+				Handler:   &transportfakes.FakeHandler{},
+			})
+			require.NoError(t, err)
+			defer offerer.Close()
+
+			handler := &transportfakes.FakeHandler{}
+			answerer, err := NewPCTransport(TransportParams{
+				ParticipantID:       "answerer",
+				ParticipantIdentity: "answerer",
+				Config:              &WebRTCConfig{AnswerAttributeOrder: configured},
+				EnabledCodecs: []*livekit.Codec{
+					{Mime: webrtc.MimeTypeOpus},
+					{Mime: webrtc.MimeTypeVP8},
+				},
+				Handler: handler,
+			})
+			require.NoError(t, err)
+			defer answerer.Close()
+
+			_, err = offerer.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+			require.NoError(t, err)
+			_, err = offerer.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+			require.NoError(t, err)
+			offer, err := offerer.pc.CreateOffer(nil)
+			require.NoError(t, err)
+
+			answer := atomic.Value{}
+			handler.OnAnswerCalls(func(sd webrtc.SessionDescription) error {
+				answer.Store(&sd)
+				return nil
+			})
+			answerer.HandleRemoteDescription(offer)
+			require.Eventually(t, func() bool {
+				return answer.Load() != nil
+			}, 10*time.Second, 10*time.Millisecond, "answer not sent")
+
+			parsed, err := answer.Load().(*webrtc.SessionDescription).Unmarshal()
+			require.NoError(t, err)
+			require.Len(t, parsed.MediaDescriptions, 2)
+			for _, m := range parsed.MediaDescriptions {
+				keys := attributeKeys(m.Attributes)
+				if configured == nil {
+					// generated order, pion leads with the DTLS role
+					require.Equal(t, sdp.AttrKeyConnectionSetup, keys[0], m.MediaName.Media)
+					continue
+				}
+
+				// configured keys lead, in the configured order
+				ranks := make([]int, 0, len(keys))
+				for _, k := range keys {
+					if i := slices.Index(configured, k); i >= 0 {
+						ranks = append(ranks, i)
+					} else {
+						ranks = append(ranks, len(configured))
+					}
+				}
+				require.True(t, slices.IsSorted(ranks), "%s: %v", m.MediaName.Media, keys)
+				require.Equal(t, sdp.AttrKeyMID, keys[0], m.MediaName.Media)
+			}
+		})
+	}
+}
+
+func attributeKeys(attrs []sdp.Attribute) []string {
+	keys := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		keys = append(keys, a.Key)
+	}
+	return keys
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 )
 
 const (
@@ -74,6 +75,7 @@ type TransportManagerParams struct {
 	Twcc                         *twcc.Responder
 	ProtocolVersion              types.ProtocolVersion
 	CongestionControlConfig      config.CongestionControlConfig
+	EgressBudget                 *streamallocator.EgressBudget
 	EnabledSubscribeCodecs       []*livekit.Codec
 	EnabledPublishCodecs         []*livekit.Codec
 	SimTracks                    map[uint32]SimulcastTrackInfo
@@ -85,6 +87,7 @@ type TransportManagerParams struct {
 	TURNSEnabled                 bool
 	AllowPlayoutDelay            bool
 	DataChannelMaxBufferedAmount uint64
+	PacketTrace                  *sfu.PacketTrace
 	Logger                       logger.Logger
 	PublisherHandler             transport.Handler
 	SubscriberHandler            transport.Handler
@@ -139,6 +142,7 @@ func NewTransportManager(params TransportManagerParams) (*TransportManager, erro
 		Logger:                  LoggerWithPCTarget(params.Logger, livekit.SignalTarget_PUBLISHER),
 		SimTracks:               params.SimTracks,
 		ClientInfo:              params.ClientInfo,
+		PacketTrace:             params.PacketTrace,
 		Transport:               livekit.SignalTarget_PUBLISHER,
 		Handler:                 TransportManagerPublisherTransportHandler{TransportManagerTransportHandler{params.PublisherHandler, t}},
 	})
@@ -154,6 +158,7 @@ func NewTransportManager(params TransportManagerParams) (*TransportManager, erro
 		Config:                       params.Config,
 		DirectionConfig:              params.Config.Subscriber,
 		CongestionControlConfig:      params.CongestionControlConfig,
+		EgressBudget:                 params.EgressBudget,
 		EnabledCodecs:                params.EnabledSubscribeCodecs,
 		Logger:                       LoggerWithPCTarget(params.Logger, livekit.SignalTarget_SUBSCRIBER),
 		ClientInfo:                   params.ClientInfo,
@@ -161,6 +166,7 @@ func NewTransportManager(params TransportManagerParams) (*TransportManager, erro
 		IsSendSide:                   true,
 		AllowPlayoutDelay:            params.AllowPlayoutDelay,
 		DataChannelMaxBufferedAmount: params.DataChannelMaxBufferedAmount,
+		PacketTrace:                  params.PacketTrace,
 		Transport:                    livekit.SignalTarget_SUBSCRIBER,
 		Handler:                      TransportManagerTransportHandler{params.SubscriberHandler, t},
 	})
@@ -726,6 +732,10 @@ func (t *TransportManager) SetSubscriberChannelCapacity(channelCapacity int64) {
 	t.subscriber.SetChannelCapacityOfStreamAllocator(channelCapacity)
 }
 
+func (t *TransportManager) GetSubscriberBandwidthEstimate() *streamallocator.BandwidthEstimate {
+	return t.subscriber.GetBandwidthEstimateOfStreamAllocator()
+}
+
 func (t *TransportManager) hasRecentSignalLocked() bool {
 	return time.Since(t.lastSignalAt) < PingTimeoutSeconds*time.Second
 }
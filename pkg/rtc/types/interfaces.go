@@ -30,6 +30,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
@@ -106,6 +107,7 @@ const (
 	ParticipantCloseReasonDataChannelError
 	ParticipantCloseReasonMigrateCodecMismatch
 	ParticipantCloseReasonSignalSourceClose
+	ParticipantCloseReasonNoRTCP
 )
 
 func (p ParticipantCloseReason) String() string {
@@ -158,6 +160,8 @@ func (p ParticipantCloseReason) String() string {
 		return "MIGRATE_CODEC_MISMATCH"
 	case ParticipantCloseReasonSignalSourceClose:
 		return "SIGNAL_SOURCE_CLOSE"
+	case ParticipantCloseReasonNoRTCP:
+		return "NO_RTCP"
 	default:
 		return fmt.Sprintf("%d", int(p))
 	}
@@ -184,7 +188,7 @@ func (p ParticipantCloseReason) ToDisconnectReason() livekit.DisconnectReason {
 		return livekit.DisconnectReason_ROOM_DELETED
 	case ParticipantCloseReasonSimulateNodeFailure, ParticipantCloseReasonSimulateServerLeave:
 		return livekit.DisconnectReason_SERVER_SHUTDOWN
-	case ParticipantCloseReasonNegotiateFailed, ParticipantCloseReasonPublicationError, ParticipantCloseReasonSubscriptionError, ParticipantCloseReasonDataChannelError, ParticipantCloseReasonMigrateCodecMismatch:
+	case ParticipantCloseReasonNegotiateFailed, ParticipantCloseReasonPublicationError, ParticipantCloseReasonSubscriptionError, ParticipantCloseReasonDataChannelError, ParticipantCloseReasonMigrateCodecMismatch, ParticipantCloseReasonNoRTCP:
 		return livekit.DisconnectReason_STATE_MISMATCH
 	case ParticipantCloseReasonSignalSourceClose:
 		return livekit.DisconnectReason_SIGNAL_CLOSE
@@ -291,6 +295,8 @@ type Participant interface {
 type AddTrackParams struct {
 	Stereo bool
 	Red    bool
+	// mime types the track is published in
+	MimeTypes []string
 }
 
 //counterfeiter:generate . LocalParticipant
@@ -377,6 +383,7 @@ type LocalParticipant interface {
 	SendDataPacket(kind livekit.DataPacket_Kind, encoded []byte) error
 	SendRoomUpdate(room *livekit.Room) error
 	SendConnectionQualityUpdate(update *livekit.ConnectionQualityUpdate) error
+	SendStreamStateUpdate(update *livekit.StreamStateUpdate) error
 	SubscriptionPermissionUpdate(publisherID livekit.ParticipantID, trackID livekit.TrackID, allowed bool)
 	SendRefreshToken(token string) error
 	HandleReconnectAndSendResponse(reconnectReason livekit.ReconnectReason, reconnectResponse *livekit.ReconnectResponse) error
@@ -423,8 +430,12 @@ type LocalParticipant interface {
 	// down stream bandwidth management
 	SetSubscriberAllowPause(allowPause bool)
 	SetSubscriberChannelCapacity(channelCapacity int64)
+	// latest estimate of the subscriber connection, nil if none has been received yet
+	GetSubscriberBandwidthEstimate() *streamallocator.BandwidthEstimate
 
 	GetPacer() pacer.Pacer
+	// nil if the connection of the participant cannot be traced
+	GetPacketTrace() *sfu.PacketTrace
 }
 
 // Room is a container of participants, and can provide room-level actions
@@ -468,6 +479,9 @@ type MediaTrack interface {
 	IsSimulcast() bool
 
 	GetAudioLevel() (level float64, active bool)
+	// marks the publisher as an active speaker of the room, audio of others is not retransmitted to subscribers
+	// when NACKs of audio are limited to active speakers
+	SetActiveSpeaker(active bool)
 
 	Close(isExpectedToResume bool)
 	IsOpen() bool
@@ -282,6 +282,11 @@ type FakeLocalMediaTrack struct {
 	revokeDisallowedSubscribersReturnsOnCall map[int]struct {
 		result1 []livekit.ParticipantIdentity
 	}
+	SetActiveSpeakerStub        func(bool)
+	setActiveSpeakerMutex       sync.RWMutex
+	setActiveSpeakerArgsForCall []struct {
+		arg1 bool
+	}
 	SetMutedStub        func(bool)
 	setMutedMutex       sync.RWMutex
 	setMutedArgsForCall []struct {
@@ -1806,6 +1811,38 @@ func (fake *FakeLocalMediaTrack) RevokeDisallowedSubscribersReturnsOnCall(i int,
 	}{result1}
 }
 
+func (fake *FakeLocalMediaTrack) SetActiveSpeaker(arg1 bool) {
+	fake.setActiveSpeakerMutex.Lock()
+	fake.setActiveSpeakerArgsForCall = append(fake.setActiveSpeakerArgsForCall, struct {
+		arg1 bool
+	}{arg1})
+	stub := fake.SetActiveSpeakerStub
+	fake.recordInvocation("SetActiveSpeaker", []interface{}{arg1})
+	fake.setActiveSpeakerMutex.Unlock()
+	if stub != nil {
+		fake.SetActiveSpeakerStub(arg1)
+	}
+}
+
+func (fake *FakeLocalMediaTrack) SetActiveSpeakerCallCount() int {
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
+	return len(fake.setActiveSpeakerArgsForCall)
+}
+
+func (fake *FakeLocalMediaTrack) SetActiveSpeakerCalls(stub func(bool)) {
+	fake.setActiveSpeakerMutex.Lock()
+	defer fake.setActiveSpeakerMutex.Unlock()
+	fake.SetActiveSpeakerStub = stub
+}
+
+func (fake *FakeLocalMediaTrack) SetActiveSpeakerArgsForCall(i int) bool {
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
+	argsForCall := fake.setActiveSpeakerArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLocalMediaTrack) SetMuted(arg1 bool) {
 	fake.setMutedMutex.Lock()
 	fake.setMutedArgsForCall = append(fake.setMutedArgsForCall, struct {
@@ -2239,6 +2276,8 @@ func (fake *FakeLocalMediaTrack) Invocations() map[string][][]interface{} {
 	defer fake.restartMutex.RUnlock()
 	fake.revokeDisallowedSubscribersMutex.RLock()
 	defer fake.revokeDisallowedSubscribersMutex.RUnlock()
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
 	fake.setMutedMutex.RLock()
 	defer fake.setMutedMutex.RUnlock()
 	fake.setRTTMutex.RLock()
@@ -10,6 +10,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -283,6 +284,16 @@ type FakeLocalParticipant struct {
 	getPacerReturnsOnCall map[int]struct {
 		result1 pacer.Pacer
 	}
+	GetPacketTraceStub        func() *sfu.PacketTrace
+	getPacketTraceMutex       sync.RWMutex
+	getPacketTraceArgsForCall []struct {
+	}
+	getPacketTraceReturns struct {
+		result1 *sfu.PacketTrace
+	}
+	getPacketTraceReturnsOnCall map[int]struct {
+		result1 *sfu.PacketTrace
+	}
 	GetPendingTrackStub        func(livekit.TrackID) *livekit.TrackInfo
 	getPendingTrackMutex       sync.RWMutex
 	getPendingTrackArgsForCall []struct {
@@ -345,6 +356,16 @@ type FakeLocalParticipant struct {
 	getSubscribedTracksReturnsOnCall map[int]struct {
 		result1 []types.SubscribedTrack
 	}
+	GetSubscriberBandwidthEstimateStub        func() *streamallocator.BandwidthEstimate
+	getSubscriberBandwidthEstimateMutex       sync.RWMutex
+	getSubscriberBandwidthEstimateArgsForCall []struct {
+	}
+	getSubscriberBandwidthEstimateReturns struct {
+		result1 *streamallocator.BandwidthEstimate
+	}
+	getSubscriberBandwidthEstimateReturnsOnCall map[int]struct {
+		result1 *streamallocator.BandwidthEstimate
+	}
 	GetTrailerStub        func() []byte
 	getTrailerMutex       sync.RWMutex
 	getTrailerArgsForCall []struct {
@@ -733,6 +754,17 @@ type FakeLocalParticipant struct {
 	sendSpeakerUpdateReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SendStreamStateUpdateStub        func(*livekit.StreamStateUpdate) error
+	sendStreamStateUpdateMutex       sync.RWMutex
+	sendStreamStateUpdateArgsForCall []struct {
+		arg1 *livekit.StreamStateUpdate
+	}
+	sendStreamStateUpdateReturns struct {
+		result1 error
+	}
+	sendStreamStateUpdateReturnsOnCall map[int]struct {
+		result1 error
+	}
 	SetAttributesStub        func(map[string]string) error
 	setAttributesMutex       sync.RWMutex
 	setAttributesArgsForCall []struct {
@@ -2384,6 +2416,59 @@ func (fake *FakeLocalParticipant) GetPacerReturnsOnCall(i int, result1 pacer.Pac
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetPacketTrace() *sfu.PacketTrace {
+	fake.getPacketTraceMutex.Lock()
+	ret, specificReturn := fake.getPacketTraceReturnsOnCall[len(fake.getPacketTraceArgsForCall)]
+	fake.getPacketTraceArgsForCall = append(fake.getPacketTraceArgsForCall, struct {
+	}{})
+	stub := fake.GetPacketTraceStub
+	fakeReturns := fake.getPacketTraceReturns
+	fake.recordInvocation("GetPacketTrace", []interface{}{})
+	fake.getPacketTraceMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetPacketTraceCallCount() int {
+	fake.getPacketTraceMutex.RLock()
+	defer fake.getPacketTraceMutex.RUnlock()
+	return len(fake.getPacketTraceArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetPacketTraceCalls(stub func() *sfu.PacketTrace) {
+	fake.getPacketTraceMutex.Lock()
+	defer fake.getPacketTraceMutex.Unlock()
+	fake.GetPacketTraceStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetPacketTraceReturns(result1 *sfu.PacketTrace) {
+	fake.getPacketTraceMutex.Lock()
+	defer fake.getPacketTraceMutex.Unlock()
+	fake.GetPacketTraceStub = nil
+	fake.getPacketTraceReturns = struct {
+		result1 *sfu.PacketTrace
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetPacketTraceReturnsOnCall(i int, result1 *sfu.PacketTrace) {
+	fake.getPacketTraceMutex.Lock()
+	defer fake.getPacketTraceMutex.Unlock()
+	fake.GetPacketTraceStub = nil
+	if fake.getPacketTraceReturnsOnCall == nil {
+		fake.getPacketTraceReturnsOnCall = make(map[int]struct {
+			result1 *sfu.PacketTrace
+		})
+	}
+	fake.getPacketTraceReturnsOnCall[i] = struct {
+		result1 *sfu.PacketTrace
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) GetPendingTrack(arg1 livekit.TrackID) *livekit.TrackInfo {
 	fake.getPendingTrackMutex.Lock()
 	ret, specificReturn := fake.getPendingTrackReturnsOnCall[len(fake.getPendingTrackArgsForCall)]
@@ -2718,6 +2803,59 @@ func (fake *FakeLocalParticipant) GetSubscribedTracksReturnsOnCall(i int, result
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthEstimate() *streamallocator.BandwidthEstimate {
+	fake.getSubscriberBandwidthEstimateMutex.Lock()
+	ret, specificReturn := fake.getSubscriberBandwidthEstimateReturnsOnCall[len(fake.getSubscriberBandwidthEstimateArgsForCall)]
+	fake.getSubscriberBandwidthEstimateArgsForCall = append(fake.getSubscriberBandwidthEstimateArgsForCall, struct {
+	}{})
+	stub := fake.GetSubscriberBandwidthEstimateStub
+	fakeReturns := fake.getSubscriberBandwidthEstimateReturns
+	fake.recordInvocation("GetSubscriberBandwidthEstimate", []interface{}{})
+	fake.getSubscriberBandwidthEstimateMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthEstimateCallCount() int {
+	fake.getSubscriberBandwidthEstimateMutex.RLock()
+	defer fake.getSubscriberBandwidthEstimateMutex.RUnlock()
+	return len(fake.getSubscriberBandwidthEstimateArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthEstimateCalls(stub func() *streamallocator.BandwidthEstimate) {
+	fake.getSubscriberBandwidthEstimateMutex.Lock()
+	defer fake.getSubscriberBandwidthEstimateMutex.Unlock()
+	fake.GetSubscriberBandwidthEstimateStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthEstimateReturns(result1 *streamallocator.BandwidthEstimate) {
+	fake.getSubscriberBandwidthEstimateMutex.Lock()
+	defer fake.getSubscriberBandwidthEstimateMutex.Unlock()
+	fake.GetSubscriberBandwidthEstimateStub = nil
+	fake.getSubscriberBandwidthEstimateReturns = struct {
+		result1 *streamallocator.BandwidthEstimate
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthEstimateReturnsOnCall(i int, result1 *streamallocator.BandwidthEstimate) {
+	fake.getSubscriberBandwidthEstimateMutex.Lock()
+	defer fake.getSubscriberBandwidthEstimateMutex.Unlock()
+	fake.GetSubscriberBandwidthEstimateStub = nil
+	if fake.getSubscriberBandwidthEstimateReturnsOnCall == nil {
+		fake.getSubscriberBandwidthEstimateReturnsOnCall = make(map[int]struct {
+			result1 *streamallocator.BandwidthEstimate
+		})
+	}
+	fake.getSubscriberBandwidthEstimateReturnsOnCall[i] = struct {
+		result1 *streamallocator.BandwidthEstimate
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) GetTrailer() []byte {
 	fake.getTrailerMutex.Lock()
 	ret, specificReturn := fake.getTrailerReturnsOnCall[len(fake.getTrailerArgsForCall)]
@@ -4892,6 +5030,67 @@ func (fake *FakeLocalParticipant) SendSpeakerUpdateReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) SendStreamStateUpdate(arg1 *livekit.StreamStateUpdate) error {
+	fake.sendStreamStateUpdateMutex.Lock()
+	ret, specificReturn := fake.sendStreamStateUpdateReturnsOnCall[len(fake.sendStreamStateUpdateArgsForCall)]
+	fake.sendStreamStateUpdateArgsForCall = append(fake.sendStreamStateUpdateArgsForCall, struct {
+		arg1 *livekit.StreamStateUpdate
+	}{arg1})
+	stub := fake.SendStreamStateUpdateStub
+	fakeReturns := fake.sendStreamStateUpdateReturns
+	fake.recordInvocation("SendStreamStateUpdate", []interface{}{arg1})
+	fake.sendStreamStateUpdateMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) SendStreamStateUpdateCallCount() int {
+	fake.sendStreamStateUpdateMutex.RLock()
+	defer fake.sendStreamStateUpdateMutex.RUnlock()
+	return len(fake.sendStreamStateUpdateArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SendStreamStateUpdateCalls(stub func(*livekit.StreamStateUpdate) error) {
+	fake.sendStreamStateUpdateMutex.Lock()
+	defer fake.sendStreamStateUpdateMutex.Unlock()
+	fake.SendStreamStateUpdateStub = stub
+}
+
+func (fake *FakeLocalParticipant) SendStreamStateUpdateArgsForCall(i int) *livekit.StreamStateUpdate {
+	fake.sendStreamStateUpdateMutex.RLock()
+	defer fake.sendStreamStateUpdateMutex.RUnlock()
+	argsForCall := fake.sendStreamStateUpdateArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLocalParticipant) SendStreamStateUpdateReturns(result1 error) {
+	fake.sendStreamStateUpdateMutex.Lock()
+	defer fake.sendStreamStateUpdateMutex.Unlock()
+	fake.SendStreamStateUpdateStub = nil
+	fake.sendStreamStateUpdateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) SendStreamStateUpdateReturnsOnCall(i int, result1 error) {
+	fake.sendStreamStateUpdateMutex.Lock()
+	defer fake.sendStreamStateUpdateMutex.Unlock()
+	fake.SendStreamStateUpdateStub = nil
+	if fake.sendStreamStateUpdateReturnsOnCall == nil {
+		fake.sendStreamStateUpdateReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.sendStreamStateUpdateReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) SetAttributes(arg1 map[string]string) error {
 	fake.setAttributesMutex.Lock()
 	ret, specificReturn := fake.setAttributesReturnsOnCall[len(fake.setAttributesArgsForCall)]
@@ -6537,6 +6736,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.getLoggerMutex.RUnlock()
 	fake.getPacerMutex.RLock()
 	defer fake.getPacerMutex.RUnlock()
+	fake.getPacketTraceMutex.RLock()
+	defer fake.getPacketTraceMutex.RUnlock()
 	fake.getPendingTrackMutex.RLock()
 	defer fake.getPendingTrackMutex.RUnlock()
 	fake.getPlayoutDelayConfigMutex.RLock()
@@ -6549,6 +6750,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.getSubscribedParticipantsMutex.RUnlock()
 	fake.getSubscribedTracksMutex.RLock()
 	defer fake.getSubscribedTracksMutex.RUnlock()
+	fake.getSubscriberBandwidthEstimateMutex.RLock()
+	defer fake.getSubscriberBandwidthEstimateMutex.RUnlock()
 	fake.getTrailerMutex.RLock()
 	defer fake.getTrailerMutex.RUnlock()
 	fake.handleAnswerMutex.RLock()
@@ -6643,6 +6846,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.sendRoomUpdateMutex.RUnlock()
 	fake.sendSpeakerUpdateMutex.RLock()
 	defer fake.sendSpeakerUpdateMutex.RUnlock()
+	fake.sendStreamStateUpdateMutex.RLock()
+	defer fake.sendStreamStateUpdateMutex.RUnlock()
 	fake.setAttributesMutex.RLock()
 	defer fake.setAttributesMutex.RUnlock()
 	fake.setICEConfigMutex.RLock()
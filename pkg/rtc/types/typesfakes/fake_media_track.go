@@ -233,6 +233,11 @@ type FakeMediaTrack struct {
 	revokeDisallowedSubscribersReturnsOnCall map[int]struct {
 		result1 []livekit.ParticipantIdentity
 	}
+	SetActiveSpeakerStub        func(bool)
+	setActiveSpeakerMutex       sync.RWMutex
+	setActiveSpeakerArgsForCall []struct {
+		arg1 bool
+	}
 	SetMutedStub        func(bool)
 	setMutedMutex       sync.RWMutex
 	setMutedArgsForCall []struct {
@@ -1477,6 +1482,38 @@ func (fake *FakeMediaTrack) RevokeDisallowedSubscribersReturnsOnCall(i int, resu
 	}{result1}
 }
 
+func (fake *FakeMediaTrack) SetActiveSpeaker(arg1 bool) {
+	fake.setActiveSpeakerMutex.Lock()
+	fake.setActiveSpeakerArgsForCall = append(fake.setActiveSpeakerArgsForCall, struct {
+		arg1 bool
+	}{arg1})
+	stub := fake.SetActiveSpeakerStub
+	fake.recordInvocation("SetActiveSpeaker", []interface{}{arg1})
+	fake.setActiveSpeakerMutex.Unlock()
+	if stub != nil {
+		fake.SetActiveSpeakerStub(arg1)
+	}
+}
+
+func (fake *FakeMediaTrack) SetActiveSpeakerCallCount() int {
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
+	return len(fake.setActiveSpeakerArgsForCall)
+}
+
+func (fake *FakeMediaTrack) SetActiveSpeakerCalls(stub func(bool)) {
+	fake.setActiveSpeakerMutex.Lock()
+	defer fake.setActiveSpeakerMutex.Unlock()
+	fake.SetActiveSpeakerStub = stub
+}
+
+func (fake *FakeMediaTrack) SetActiveSpeakerArgsForCall(i int) bool {
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
+	argsForCall := fake.setActiveSpeakerArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeMediaTrack) SetMuted(arg1 bool) {
 	fake.setMutedMutex.Lock()
 	fake.setMutedArgsForCall = append(fake.setMutedArgsForCall, struct {
@@ -1813,6 +1850,8 @@ func (fake *FakeMediaTrack) Invocations() map[string][][]interface{} {
 	defer fake.removeSubscriberMutex.RUnlock()
 	fake.revokeDisallowedSubscribersMutex.RLock()
 	defer fake.revokeDisallowedSubscribersMutex.RUnlock()
+	fake.setActiveSpeakerMutex.RLock()
+	defer fake.setActiveSpeakerMutex.RUnlock()
 	fake.setMutedMutex.RLock()
 	defer fake.setMutedMutex.RUnlock()
 	fake.sourceMutex.RLock()
@@ -21,6 +21,7 @@ import (
 
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -37,6 +38,8 @@ type WrappedReceiverParams struct {
 	UpstreamCodecs []webrtc.RTPCodecParameters
 	Logger         logger.Logger
 	DisableRed     bool
+	// codecs that must be forwarded as published, no alternative codec is derived for them
+	PassthroughCodecs []string
 }
 
 type WrappedReceiver struct {
@@ -54,7 +57,7 @@ func NewWrappedReceiver(params WrappedReceiverParams) *WrappedReceiver {
 	}
 
 	codecs := params.UpstreamCodecs
-	if len(codecs) == 1 {
+	if len(codecs) == 1 && !slices.Contains(params.PassthroughCodecs, strings.ToLower(codecs[0].MimeType)) {
 		if strings.EqualFold(codecs[0].MimeType, sfu.MimeTypeAudioRed) {
 			// if upstream is opus/red, then add opus to match clients that don't support red
 			codecs = append(codecs, webrtc.RTPCodecParameters{
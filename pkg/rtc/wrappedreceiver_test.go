@@ -0,0 +1,91 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/utils"
+	"github.com/livekit/protocol/logger"
+)
+
+func TestWrappedReceiverPassthroughCodecs(t *testing.T) {
+	red := webrtc.RTPCodecParameters{RTPCodecCapability: redCodecCapability, PayloadType: 63}
+	opus := webrtc.RTPCodecParameters{RTPCodecCapability: opusCodecCapability, PayloadType: 111}
+
+	// codecs a subscriber would bind to, first match wins
+	bind := func(upstream []webrtc.RTPCodecParameters, subscriber []webrtc.RTPCodecParameters) (string, error) {
+		for _, c := range upstream {
+			if match, err := utils.CodecParametersFuzzySearch(c, subscriber); err == nil {
+				return match.MimeType, nil
+			}
+		}
+		return "", webrtc.ErrUnsupportedCodec
+	}
+
+	t.Run("red converted to opus by default", func(t *testing.T) {
+		wr := NewWrappedReceiver(WrappedReceiverParams{
+			UpstreamCodecs: []webrtc.RTPCodecParameters{red},
+			Logger:         logger.GetLogger(),
+		})
+		mime, err := bind(wr.Codecs(), []webrtc.RTPCodecParameters{opus})
+		require.NoError(t, err)
+		require.Equal(t, webrtc.MimeTypeOpus, mime)
+	})
+
+	t.Run("mismatched subscriber refused for passthrough codec", func(t *testing.T) {
+		conf := newTestWebRTCConfig(t, func(conf *config.Config) {
+			conf.RTC.PassthroughCodecs = []string{"Audio/RED"}
+		})
+		require.Equal(t, []string{sfu.MimeTypeAudioRed}, conf.Receiver.PassthroughCodecs)
+
+		wr := NewWrappedReceiver(WrappedReceiverParams{
+			UpstreamCodecs:    []webrtc.RTPCodecParameters{red},
+			Logger:            logger.GetLogger(),
+			PassthroughCodecs: conf.Receiver.PassthroughCodecs,
+		})
+		require.Len(t, wr.Codecs(), 1)
+
+		_, err := bind(wr.Codecs(), []webrtc.RTPCodecParameters{opus})
+		require.ErrorIs(t, err, webrtc.ErrUnsupportedCodec)
+
+		// subscribers supporting the published codec are unaffected
+		mime, err := bind(wr.Codecs(), []webrtc.RTPCodecParameters{opus, red})
+		require.NoError(t, err)
+		require.Equal(t, sfu.MimeTypeAudioRed, mime)
+	})
+
+	t.Run("other codecs are not affected", func(t *testing.T) {
+		wr := NewWrappedReceiver(WrappedReceiverParams{
+			UpstreamCodecs:    []webrtc.RTPCodecParameters{opus},
+			Logger:            logger.GetLogger(),
+			PassthroughCodecs: []string{sfu.MimeTypeAudioRed},
+		})
+		require.Len(t, wr.Codecs(), 2)
+	})
+
+	t.Run("invalid passthrough codec", func(t *testing.T) {
+		c, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		c.RTC.PassthroughCodecs = []string{"red"}
+		_, err = NewWebRTCConfig(c)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/protocol/livekit"
+)
+
+// NegotiationInfoHandler returns what would be negotiated with the caller, derived from the current config of the node,
+// to help debug client interop
+type NegotiationInfoHandler struct {
+	rtcConfig     *rtc.WebRTCConfigHolder
+	enabledCodecs []*livekit.Codec
+}
+
+func NewNegotiationInfoHandler(rtcConfig *rtc.WebRTCConfigHolder, roomConf config.RoomConfig) *NegotiationInfoHandler {
+	enabledCodecs := make([]*livekit.Codec, 0, len(roomConf.EnabledCodecs))
+	for _, codec := range roomConf.EnabledCodecs {
+		enabledCodecs = append(enabledCodecs, &livekit.Codec{
+			Mime:     codec.Mime,
+			FmtpLine: codec.FmtpLine,
+		})
+	}
+	return &NegotiationInfoHandler{
+		rtcConfig:     rtcConfig,
+		enabledCodecs: enabledCodecs,
+	}
+}
+
+func (h *NegotiationInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, err := EnsureJoinPermission(r.Context()); err != nil {
+		handleError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	info, err := h.rtcConfig.Load().NegotiationInfo(h.enabledCodecs)
+	if err != nil {
+		handleError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		handleError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
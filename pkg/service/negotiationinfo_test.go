@@ -0,0 +1,124 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/auth/authfakes"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestNegotiationInfoHandler(t *testing.T) {
+	api := "APIabcdefg"
+	secret := "somesecretencodedinbase62"
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns(secret)
+	m := service.NewAPIKeyAuthMiddleware(provider)
+
+	newWebRTCConfig := func(update func(conf *config.Config)) (*config.Config, *rtc.WebRTCConfig) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		conf.RTC.TCPPort = 0
+		if update != nil {
+			update(conf)
+		}
+		rtcConf, err := rtc.NewWebRTCConfig(conf)
+		require.NoError(t, err)
+		return conf, rtcConf
+	}
+
+	conf, rtcConf := newWebRTCConfig(nil)
+	holder := rtc.NewWebRTCConfigHolder(rtcConf)
+	handler := service.NewNegotiationInfoHandler(holder, conf.Room)
+
+	token, err := auth.NewAccessToken(api, secret).
+		AddGrant(&auth.VideoGrant{Room: "room", RoomJoin: true}).
+		SetIdentity("identity").
+		ToJWT()
+	require.NoError(t, err)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/rtc/negotiation", nil)
+		if token != "" {
+			service.SetAuthorizationToken(r, token)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r, handler.ServeHTTP)
+		return w
+	}
+	getInfo := func() *rtc.NegotiationInfo {
+		w := get(token)
+		require.Equal(t, http.StatusOK, w.Code)
+		info := &rtc.NegotiationInfo{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), info))
+		return info
+	}
+	hasFeedback := func(codecs []rtc.CodecNegotiationInfo, mime string, feedback string) bool {
+		for _, c := range codecs {
+			if c.MimeType == mime {
+				for _, fb := range c.RTCPFeedback {
+					if fb == feedback {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	t.Run("requires join permission", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, get("").Code)
+	})
+
+	t.Run("reflects config", func(t *testing.T) {
+		info := getInfo()
+		require.True(t, info.RTCPReducedSize)
+		require.NotEmpty(t, info.Publisher.Codecs)
+		require.Equal(t, rtcConf.Publisher.RTPHeaderExtension.Video, info.Publisher.VideoHeaderExtensions)
+		require.Equal(t, rtcConf.Subscriber.RTPHeaderExtension.Audio, info.Subscriber.AudioHeaderExtensions)
+
+		// receive side bandwidth estimation for subscribers by default
+		require.Contains(t, info.Subscriber.VideoHeaderExtensions, sdp.ABSSendTimeURI)
+		require.NotContains(t, info.Subscriber.VideoHeaderExtensions, sdp.TransportCCURI)
+		require.True(t, hasFeedback(info.Subscriber.Codecs, "video/VP8", "goog-remb"))
+		require.False(t, hasFeedback(info.Subscriber.Codecs, "video/VP8", "transport-cc"))
+	})
+
+	t.Run("reflects updated config", func(t *testing.T) {
+		_, sendSideBWEConf := newWebRTCConfig(func(conf *config.Config) {
+			conf.RTC.CongestionControl.UseSendSideBWE = true
+			conf.RTC.DisableRTCPReducedSize = true
+		})
+		require.NoError(t, holder.ApplyNewConfig(sendSideBWEConf))
+
+		info := getInfo()
+		require.False(t, info.RTCPReducedSize)
+		require.Contains(t, info.Subscriber.VideoHeaderExtensions, sdp.TransportCCURI)
+		require.NotContains(t, info.Subscriber.VideoHeaderExtensions, sdp.ABSSendTimeURI)
+		require.True(t, hasFeedback(info.Subscriber.Codecs, "video/VP8", "transport-cc"))
+		require.False(t, hasFeedback(info.Subscriber.Codecs, "video/VP8", "goog-remb"))
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+// PacketTraceHandler turns packet tracing of connections on this node on and off at runtime. GET lists the traced
+// connections, PUT starts tracing the connection given by connection_id and DELETE stops it. Tracing is node wide,
+// so it needs the grant to list rooms rather than permissions in a room.
+type PacketTraceHandler struct {
+	rtcConfig *rtc.WebRTCConfigHolder
+}
+
+func NewPacketTraceHandler(rtcConfig *rtc.WebRTCConfigHolder) *PacketTraceHandler {
+	return &PacketTraceHandler{
+		rtcConfig: rtcConfig,
+	}
+}
+
+func (h *PacketTraceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	tracer := h.rtcConfig.Load().PacketTracer
+	switch r.Method {
+	case http.MethodGet:
+		// lists the traced connections
+
+	case http.MethodPut, http.MethodDelete:
+		connectionID := livekit.ConnectionID(r.FormValue("connection_id"))
+		if connectionID == "" {
+			handleError(w, r, http.StatusBadRequest, ErrConnectionIDEmpty)
+			return
+		}
+
+		enabled := r.Method == http.MethodPut
+		tracer.SetEnabled(connectionID, enabled)
+		logger.Infow("packet trace updated", "connID", connectionID, "enabled", enabled)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := json.Marshal(tracer.EnabledConnections())
+	if err != nil {
+		handleError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
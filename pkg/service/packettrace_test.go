@@ -0,0 +1,91 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/auth/authfakes"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestPacketTraceHandler(t *testing.T) {
+	api := "APIabcdefg"
+	secret := "somesecretencodedinbase62"
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns(secret)
+	m := service.NewAPIKeyAuthMiddleware(provider)
+
+	conf, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	conf.RTC.TCPPort = 0
+	rtcConf, err := rtc.NewWebRTCConfig(conf)
+	require.NoError(t, err)
+	holder := rtc.NewWebRTCConfigHolder(rtcConf)
+	handler := service.NewPacketTraceHandler(holder)
+
+	newToken := func(grant *auth.VideoGrant) string {
+		token, err := auth.NewAccessToken(api, secret).AddGrant(grant).ToJWT()
+		require.NoError(t, err)
+		return token
+	}
+	listToken := newToken(&auth.VideoGrant{RoomList: true})
+
+	do := func(method string, target string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		service.SetAuthorizationToken(r, token)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r, handler.ServeHTTP)
+		return w
+	}
+	traced := func(w *httptest.ResponseRecorder) []livekit.ConnectionID {
+		require.Equal(t, http.StatusOK, w.Code)
+		var connectionIDs []livekit.ConnectionID
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &connectionIDs))
+		return connectionIDs
+	}
+
+	t.Run("requires list permission", func(t *testing.T) {
+		token := newToken(&auth.VideoGrant{Room: "room", RoomAdmin: true, RoomJoin: true})
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodPut, "/debug/packet_trace?connection_id=CO_traced", token).Code)
+		require.False(t, holder.Load().PacketTracer.IsEnabled("CO_traced"))
+	})
+
+	t.Run("requires connection", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/debug/packet_trace", listToken).Code)
+	})
+
+	t.Run("toggles tracing of the connection", func(t *testing.T) {
+		require.Empty(t, traced(do(http.MethodGet, "/debug/packet_trace", listToken)))
+
+		require.Equal(t, []livekit.ConnectionID{"CO_traced"}, traced(do(http.MethodPut, "/debug/packet_trace?connection_id=CO_traced", listToken)))
+		require.True(t, holder.Load().PacketTracer.IsEnabled("CO_traced"))
+		require.False(t, holder.Load().PacketTracer.IsEnabled("CO_untraced"))
+		require.Equal(t, []livekit.ConnectionID{"CO_traced"}, traced(do(http.MethodGet, "/debug/packet_trace", listToken)))
+
+		require.Empty(t, traced(do(http.MethodDelete, "/debug/packet_trace?connection_id=CO_traced", listToken)))
+		require.False(t, holder.Load().PacketTracer.IsEnabled("CO_traced"))
+	})
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/livekit/livekit-server/pkg/agent"
 	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 	sutils "github.com/livekit/livekit-server/pkg/utils"
 	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
 	"github.com/livekit/protocol/auth"
@@ -66,7 +67,7 @@ type RoomManager struct {
 	lock sync.RWMutex
 
 	config            *config.Config
-	rtcConfig         *rtc.WebRTCConfig
+	rtcConfig         *rtc.WebRTCConfigHolder
 	serverInfo        *livekit.ServerInfo
 	currentNode       routing.LocalNode
 	router            routing.Router
@@ -87,6 +88,9 @@ type RoomManager struct {
 	iceConfigCache *sutils.IceConfigCache[iceConfigCacheKey]
 
 	forwardStats *sfu.ForwardStats
+	egressBudget *streamallocator.EgressBudget
+
+	connectionLoggers *rtc.ConnectionLoggers
 }
 
 func NewLocalRoomManager(
@@ -108,9 +112,25 @@ func NewLocalRoomManager(
 		return nil, err
 	}
 
+	switch conf.RTC.PreferredTURNTransport {
+	case "", config.TURNTransportUDP, config.TURNTransportTCP, config.TURNTransportTLS:
+	default:
+		return nil, fmt.Errorf("unsupported preferred TURN transport %q", conf.RTC.PreferredTURNTransport)
+	}
+
+	connectionLoggers, err := rtc.NewConnectionLoggers(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var egressBudget *streamallocator.EgressBudget
+	if conf.RTC.CongestionControl.NodeEgressLimit > 0 {
+		egressBudget = streamallocator.NewEgressBudget(conf.RTC.CongestionControl.NodeEgressLimit)
+	}
+
 	return &RoomManager{
 		config:            conf,
-		rtcConfig:         rtcConf,
+		rtcConfig:         rtc.NewWebRTCConfigHolder(rtcConf),
 		currentNode:       currentNode,
 		router:            router,
 		roomStore:         roomStore,
@@ -122,6 +142,8 @@ func NewLocalRoomManager(
 		turnAuthHandler:   turnAuthHandler,
 		bus:               bus,
 		forwardStats:      forwardStats,
+		egressBudget:      egressBudget,
+		connectionLoggers: connectionLoggers,
 
 		rooms: make(map[livekit.RoomName]*rtc.Room),
 
@@ -228,12 +250,12 @@ func (r *RoomManager) Stop() {
 	r.roomServers.Kill()
 	r.participantServers.Kill()
 
-	if r.rtcConfig != nil {
-		if r.rtcConfig.UDPMux != nil {
-			_ = r.rtcConfig.UDPMux.Close()
+	if rtcConfig := r.rtcConfig.Load(); rtcConfig != nil {
+		if rtcConfig.UDPMux != nil {
+			_ = rtcConfig.UDPMux.Close()
 		}
-		if r.rtcConfig.TCPMuxListener != nil {
-			_ = r.rtcConfig.TCPMuxListener.Close()
+		if rtcConfig.TCPMuxListener != nil {
+			_ = rtcConfig.TCPMuxListener.Close()
 		}
 	}
 
@@ -244,6 +266,12 @@ func (r *RoomManager) Stop() {
 	}
 }
 
+// ReloadRTCConfig replaces the WebRTC config used for new participants and rooms with one created from conf,
+// existing ones keep theirs. The current config is kept when the new one fails validation.
+func (r *RoomManager) ReloadRTCConfig(conf *config.Config) error {
+	return r.rtcConfig.Reload(conf)
+}
+
 // StartSession starts WebRTC session when a new participant is connected, takes place on RTC node
 func (r *RoomManager) StartSession(
 	ctx context.Context,
@@ -254,6 +282,24 @@ func (r *RoomManager) StartSession(
 ) error {
 	sessionStartTime := time.Now()
 
+	// admission is per participant join, peer connections, resumes and migrations of joined participants are not refused.
+	// Checked before the room is created and a participant with the same identity is removed, so that a refused join
+	// leaves no empty room behind and does not kick the participant it would have replaced
+	if pi.Identity != "" && !pi.Reconnect {
+		if err := r.rtcConfig.Load().AdmissionControl.Admit(); err != nil {
+			logger.Infow("refusing participant", "room", roomName, "participant", pi.Identity, "error", err)
+			var rejected *rtc.AdmissionRejectedError
+			if errors.As(err, &rejected) {
+				_ = responseSink.WriteMessage(&livekit.SignalResponse{
+					Message: &livekit.SignalResponse_Leave{
+						Leave: rejected.LeaveRequest(),
+					},
+				})
+			}
+			return err
+		}
+	}
+
 	room, err := r.getOrCreateRoom(ctx, roomName)
 	if err != nil {
 		return err
@@ -377,11 +423,13 @@ func (r *RoomManager) StartSession(
 	clientConf := r.clientConfManager.GetConfiguration(pi.Client)
 
 	pv := types.ProtocolVersion(pi.Client.Protocol)
-	rtcConf := *r.rtcConfig
+	rtcConf := *r.rtcConfig.Load()
+	rtcConf.SetRoom(room.Name())
 	rtcConf.SetBufferFactory(room.GetBufferFactory())
+	rtcConf.SetParticipantKind(pi.Grants.GetParticipantKind())
 	sid := livekit.ParticipantID(guid.New(utils.ParticipantPrefix))
 	pLogger := rtc.LoggerWithParticipant(
-		rtc.LoggerWithRoom(logger.GetLogger(), room.Name(), room.ID()),
+		rtc.LoggerWithRoom(r.connectionLoggers.GetLogger(pi.Region, pi.Client), room.Name(), room.ID()),
 		pi.Identity,
 		sid,
 		false)
@@ -405,7 +453,7 @@ func (r *RoomManager) StartSession(
 	if r.config.RTC.ReconnectOnDataChannelError != nil {
 		reconnectOnDataChannelError = *r.config.RTC.ReconnectOnDataChannelError
 	}
-	subscriberAllowPause := r.config.RTC.CongestionControl.AllowPause
+	subscriberAllowPause := r.config.RTC.CongestionControl.IsPauseAllowed()
 	if pi.SubscriberAllowPause != nil {
 		subscriberAllowPause = *pi.SubscriberAllowPause
 	}
@@ -449,9 +497,12 @@ func (r *RoomManager) StartSession(
 		SubscriberAllowPause:         subscriberAllowPause,
 		SubscriptionLimitAudio:       r.config.Limit.SubscriptionLimitAudio,
 		SubscriptionLimitVideo:       r.config.Limit.SubscriptionLimitVideo,
+		SubscriptionLimit:            r.config.Limit.SubscriptionLimit,
 		PlayoutDelay:                 roomInternal.GetPlayoutDelay(),
 		SyncStreams:                  roomInternal.GetSyncStreams(),
 		ForwardStats:                 r.forwardStats,
+		EgressBudget:                 r.egressBudget,
+		PacketTrace:                  rtcConf.PacketTracer.NewTrace(requestSource.ConnectionID(), pLogger),
 	})
 	if err != nil {
 		return err
@@ -554,7 +605,7 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 	}
 
 	// construct ice servers
-	newRoom := rtc.NewRoom(ri, internal, *r.rtcConfig, r.config.Room, &r.config.Audio, r.serverInfo, r.telemetry, r.agentClient, r.egressLauncher)
+	newRoom := rtc.NewRoom(ri, internal, *r.rtcConfig.Load(), r.config.Room, &r.config.Audio, r.serverInfo, r.telemetry, r.agentClient, r.egressLauncher)
 
 	roomTopic := rpc.FormatRoomTopic(roomName)
 	roomServer := must.Get(rpc.NewTypedRoomServer(r, r.bus))
@@ -857,6 +908,7 @@ func (r *RoomManager) iceServersForParticipant(apiKey string, participant types.
 	if !hasSTUN {
 		iceServers = append(iceServers, iceServerForStunServers(rtcconfig.DefaultStunServers))
 	}
+	PreferTURNTransport(iceServers, rtcConf.PreferredTURNTransport)
 	return iceServers
 }
 
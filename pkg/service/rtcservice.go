@@ -241,6 +241,16 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// media node refused the participant joining as it is overloaded
+	if initialResponse.GetLeave().GetReason() == livekit.DisconnectReason_JOIN_FAILURE {
+		cr.RequestSink.Close()
+		cr.ResponseSource.Close()
+		prometheus.IncrementParticipantJoinFail(1)
+		w.Header().Set("Retry-After", strconv.Itoa(rtc.AdmissionRetryAfterSeconds(initialResponse.GetLeave())))
+		handleError(w, r, http.StatusServiceUnavailable, rtc.ErrLimitExceeded, loggerFields...)
+		return
+	}
+
 	prometheus.IncrementParticipantJoin(1)
 
 	if !pi.Reconnect && initialResponse.GetJoin() != nil {
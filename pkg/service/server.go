@@ -133,6 +133,8 @@ func NewLivekitServer(conf *config.Config,
 	mux.Handle("/rtc", rtcService)
 	mux.Handle("/agent", agentService)
 	mux.HandleFunc("/rtc/validate", rtcService.Validate)
+	mux.Handle("/rtc/negotiation", NewNegotiationInfoHandler(roomManager.rtcConfig, conf.Room))
+	mux.Handle("/debug/packet_trace", NewPacketTraceHandler(roomManager.rtcConfig))
 	mux.HandleFunc("/", s.defaultHandler)
 
 	s.httpServer = &http.Server{
@@ -19,6 +19,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -146,6 +147,56 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 	return turn.NewServer(serverConfig)
 }
 
+// PreferTURNTransport orders URLs of TURN servers using the given transport first, both across the ICE servers and
+// within each of them. Everything else keeps its relative order
+func PreferTURNTransport(iceServers []*livekit.ICEServer, transport config.TURNTransport) {
+	if transport == "" {
+		return
+	}
+
+	isPreferred := func(url string) bool {
+		return turnTransportOf(url) == transport
+	}
+	for _, is := range iceServers {
+		slices.SortStableFunc(is.Urls, func(a, b string) int {
+			return compareBool(isPreferred(b), isPreferred(a))
+		})
+	}
+	slices.SortStableFunc(iceServers, func(a, b *livekit.ICEServer) int {
+		return compareBool(
+			len(b.Urls) > 0 && isPreferred(b.Urls[0]),
+			len(a.Urls) > 0 && isPreferred(a.Urls[0]),
+		)
+	})
+}
+
+// turnTransportOf returns the transport of a TURN URL, empty for other URLs
+func turnTransportOf(url string) config.TURNTransport {
+	switch {
+	case strings.HasPrefix(url, "turns:"):
+		return config.TURNTransportTLS
+	case strings.HasPrefix(url, "turn:"):
+		if strings.HasSuffix(url, "?transport=tcp") {
+			return config.TURNTransportTCP
+		}
+		// UDP unless specified otherwise
+		return config.TURNTransportUDP
+	default:
+		return ""
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
 func getTURNAuthHandlerFunc(handler *TURNAuthHandler) turn.AuthHandler {
 	return handler.HandleAuth
 }
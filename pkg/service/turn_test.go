@@ -0,0 +1,81 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestPreferTURNTransport(t *testing.T) {
+	newICEServers := func() []*livekit.ICEServer {
+		return []*livekit.ICEServer{
+			{Urls: []string{"turn:10.0.0.1:3478?transport=udp", "turns:turn.example.com:443?transport=tcp"}},
+			{Urls: []string{"turn:turn-udp.example.com:3478?transport=udp"}},
+			{Urls: []string{"turn:turn-tcp.example.com:3478?transport=tcp"}},
+			{Urls: []string{"turns:turn-tls.example.com:443?transport=tcp"}},
+			{Urls: []string{"stun:stun.example.com:19302"}},
+		}
+	}
+	urls := func(iceServers []*livekit.ICEServer) [][]string {
+		var urls [][]string
+		for _, is := range iceServers {
+			urls = append(urls, is.Urls)
+		}
+		return urls
+	}
+
+	t.Run("no preference keeps order", func(t *testing.T) {
+		iceServers := newICEServers()
+		service.PreferTURNTransport(iceServers, "")
+		require.Equal(t, urls(newICEServers()), urls(iceServers))
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		iceServers := newICEServers()
+		service.PreferTURNTransport(iceServers, config.TURNTransportTLS)
+		require.Equal(t, [][]string{
+			{"turns:turn.example.com:443?transport=tcp", "turn:10.0.0.1:3478?transport=udp"},
+			{"turns:turn-tls.example.com:443?transport=tcp"},
+			{"turn:turn-udp.example.com:3478?transport=udp"},
+			{"turn:turn-tcp.example.com:3478?transport=tcp"},
+			{"stun:stun.example.com:19302"},
+		}, urls(iceServers))
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		iceServers := newICEServers()
+		service.PreferTURNTransport(iceServers, config.TURNTransportTCP)
+		require.Equal(t, [][]string{
+			{"turn:turn-tcp.example.com:3478?transport=tcp"},
+			{"turn:10.0.0.1:3478?transport=udp", "turns:turn.example.com:443?transport=tcp"},
+			{"turn:turn-udp.example.com:3478?transport=udp"},
+			{"turns:turn-tls.example.com:443?transport=tcp"},
+			{"stun:stun.example.com:19302"},
+		}, urls(iceServers))
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		iceServers := newICEServers()
+		service.PreferTURNTransport(iceServers, config.TURNTransportUDP)
+		require.Equal(t, urls(newICEServers()), urls(iceServers))
+	})
+}
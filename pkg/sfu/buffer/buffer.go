@@ -15,10 +15,13 @@
 package buffer
 
 import (
+	"cmp"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -69,6 +72,24 @@ type ExtPacket struct {
 	AbsCaptureTimeExt    *act.AbsCaptureTime
 }
 
+type KeyFrameRequestMethod int
+
+const (
+	KeyFrameRequestMethodPLI KeyFrameRequestMethod = iota
+	KeyFrameRequestMethodFIR
+)
+
+func (k KeyFrameRequestMethod) String() string {
+	switch k {
+	case KeyFrameRequestMethodPLI:
+		return "PLI"
+	case KeyFrameRequestMethodFIR:
+		return "FIR"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
 // Buffer contains all packets
 type Buffer struct {
 	sync.RWMutex
@@ -84,11 +105,16 @@ type Buffer struct {
 	closeOnce       sync.Once
 	mediaSSRC       uint32
 	clockRate       uint32
+	clock           Clock
 	lastReport      int64
+	rrInterval      int64
+	rrJitter        int64
+	rrJitterOffset  int64
 	twccExtID       uint8
 	audioLevelExtID uint8
 	bound           bool
 	closed          atomic.Bool
+	lastWrite       atomic.Int64 // time of the latest packet or of creation, to detect idle buffers
 	mime            string
 
 	snRangeMap *utils.RangeMap[uint64, uint64]
@@ -100,13 +126,19 @@ type Buffer struct {
 	audioLevelParams        audio.AudioLevelParams
 	audioLevel              *audio.AudioLevel
 	enableAudioLossProxying bool
+	enableRTCPXR            bool
 
 	lastPacketRead int
 
-	pliThrottle int64
+	pliThrottle           int64
+	keyFrameRequestMethod KeyFrameRequestMethod
+	firSeqNum             uint8
+
+	keyFrameRequestLimiter *KeyFrameRequestLimiter
 
 	rtpStats             *RTPStatsReceiver
 	rrSnapshotId         uint32
+	xrSnapshotId         uint32
 	deltaStatsSnapshotId uint32
 	ppsSnapshotId        uint32
 
@@ -125,6 +157,8 @@ type Buffer struct {
 	// dependency descriptor
 	ddExtID  uint8
 	ddParser *DependencyDescriptorParser
+	// reorder tolerance of the parser, kept for a parser created after it is set
+	ddReorderTolerance int
 
 	paused              bool
 	frameRateCalculator [DefaultMaxLayerSpatial + 1]FrameRateCalculator
@@ -137,8 +171,55 @@ type Buffer struct {
 
 	primaryBufferForRTX *Buffer
 	rtxPktBuf           []byte
+	// set for buffers of RTX streams, packets kept until the buffer is paired with its primary buffer and
+	// capacity of its bucket
+	maxRTXPkts int
 
 	absCaptureTimeExtID uint8
+
+	malformedRTPPolicy MalformedRTPPolicy
+	onMalformedRTP     func()
+
+	// timestamp jumps larger than maxTSJump beyond the time elapsed between arrivals are handled as discontinuities,
+	// the stream is rebased onto the timestamp expected from the last timestamp step by adding tsAdjustment to
+	// timestamps of subsequent packets
+	maxTSJump            time.Duration
+	tsJumpInitialized    bool
+	tsJumpHighestSN      uint16
+	tsJumpHighestTS      uint32
+	tsJumpHighestArrival int64
+	tsJumpLastStep       uint32
+	tsAdjustment         uint32
+	tsAdjustmentPrev     uint32
+	tsAdjustmentStartSN  uint16
+	tsDiscontinuityCount int
+
+	// keep keepalive packets, i.e. ones without payload and padding, for forwarding instead of dropping them
+	forwardKeepalive bool
+	// keep in-order padding only packets so that the padding policy of the down tracks applies to them
+	forwardPadding bool
+
+	// number of packets a key frame packet carrying the dependency descriptor structure can arrive behind packets
+	// that need it, those are held until the structure arrives, 0 drops them
+	keyFrameReorderTolerance int
+	deferredDDPackets        []deferredDDPacket
+
+	// packets older than this are not retransmitted, the bucket is sized to hold packets of that age only and shrunk
+	// when the packet rate drops, evicting older packets, 0 does not limit the age. The bucket stores packets only, so
+	// arrival times are kept by sequence number alongside, 16 bytes per packet the bucket can hold
+	maxPacketAge   time.Duration
+	packetArrivals []packetArrival
+}
+
+type packetArrival struct {
+	sn          uint16
+	arrivalTime int64
+}
+
+type deferredDDPacket struct {
+	packet      *rtp.Packet
+	arrivalTime int64
+	flowState   RTPFlowState
 }
 
 // NewBuffer constructs a new Buffer
@@ -150,10 +231,13 @@ func NewBuffer(ssrc uint32, maxVideoPkts, maxAudioPkts int) *Buffer {
 		maxAudioPkts: maxAudioPkts,
 		snRangeMap:   utils.NewRangeMap[uint64, uint64](100),
 		pliThrottle:  int64(500 * time.Millisecond),
+		rrInterval:   ReportDelta,
+		clock:        RealClock,
 		logger:       l.WithComponent(sutils.ComponentPub).WithComponent(sutils.ComponentSFU),
 	}
 	b.readCond = sync.NewCond(&b.RWMutex)
 	b.extPackets.SetMinCapacity(7)
+	b.lastWrite.Store(b.clock.Now().UnixNano())
 	return b
 }
 
@@ -196,6 +280,159 @@ func (b *Buffer) SetAudioLossProxying(enable bool) {
 	b.enableAudioLossProxying = enable
 }
 
+// SetRTCPExtendedReports adds an RTCP XR statistics summary of loss, duplicates and jitter to each receiver report
+func (b *Buffer) SetRTCPExtendedReports(enable bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.enableRTCPXR = enable
+}
+
+// SetMaxTimestampJump sets the largest RTP timestamp change beyond the time elapsed between packet arrivals that is
+// tolerated, larger jumps are handled as a discontinuity and subsequent packets are rebased on the expected timestamp.
+// 0 passes timestamps through as received
+func (b *Buffer) SetMaxTimestampJump(maxJump time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.maxTSJump = maxJump
+}
+
+// SetForwardKeepalive keeps in-order keepalive packets, i.e. ones without payload and padding, for forwarding,
+// by default they are dropped like padding only packets
+func (b *Buffer) SetForwardKeepalive(forward bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.forwardKeepalive = forward
+}
+
+// SetForwardPadding keeps in-order padding only packets for forwarding, by default they are dropped here and
+// the sequence number gap is hidden from the down tracks
+func (b *Buffer) SetForwardPadding(forward bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.forwardPadding = forward
+}
+
+// SetKeyFrameReorderTolerance sets the number of packets a key frame packet carrying the dependency descriptor
+// structure can arrive behind packets that cannot be parsed without it. Those packets are held and forwarded after
+// the structure arrives. 0 drops them
+func (b *Buffer) SetKeyFrameReorderTolerance(tolerance int) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.keyFrameReorderTolerance = max(0, tolerance)
+	if b.keyFrameReorderTolerance == 0 {
+		b.deferredDDPackets = nil
+	}
+}
+
+// SetMaxPacketAge sets the age after which packets are not retransmitted anymore, the bucket then grows only to
+// hold packets of that age. 0 retransmits any packet the bucket holds
+func (b *Buffer) SetMaxPacketAge(maxAge time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.maxPacketAge = max(0, maxAge)
+	b.packetArrivals = nil
+	if b.maxPacketAge > 0 {
+		// room for every packet the bucket can hold, it grows in steps of the initial size up to the max packets
+		size := 1
+		for size < max(b.maxVideoPkts, b.maxAudioPkts)+max(InitPacketBufferSizeVideo, InitPacketBufferSizeAudio) {
+			size <<= 1
+		}
+		b.packetArrivals = make([]packetArrival, size)
+	}
+}
+
+// SetMalformedRTPPolicy sets the handling of packets that cannot be parsed, onMalformed is called for each of them
+// whatever the policy
+func (b *Buffer) SetMalformedRTPPolicy(policy MalformedRTPPolicy, onMalformed func()) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.malformedRTPPolicy = policy
+	b.onMalformedRTP = onMalformed
+}
+
+// countMalformedRTPLocked counts a malformed packet, returns true when it is dropped silently
+func (b *Buffer) countMalformedRTPLocked() bool {
+	if b.onMalformedRTP != nil {
+		b.onMalformedRTP()
+	}
+
+	return b.malformedRTPPolicy == MalformedRTPPolicyCount
+}
+
+// SetClock sets the time source of the buffer, nil restores the wall clock. RTP statistics use the clock set when
+// the buffer is bound.
+func (b *Buffer) SetClock(clock Clock) {
+	if clock == nil {
+		clock = RealClock
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.clock = clock
+	// idle time is measured on the new clock
+	b.lastWrite.Store(clock.Now().UnixNano())
+}
+
+// isReclaimable returns true if the buffer is not used by a track and has not received a packet for the given
+// duration. Buffers of tracks, primary or repair, are closed by the track, they are idle while the publisher does not
+// send a layer, e.g. when muted or paused by dynacast, and have to keep working once it sends again.
+func (b *Buffer) isReclaimable(timeout time.Duration) bool {
+	b.RLock()
+	inUse := b.bound || b.primaryBufferForRTX != nil
+	now := b.clock.Now().UnixNano()
+	b.RUnlock()
+
+	return !inUse && now-b.lastWrite.Load() >= timeout.Nanoseconds()
+}
+
+// SetReceiverReportInterval sets the minimum interval between RTCP receiver reports,
+// non-positive values leave the default of ReportDelta in place
+func (b *Buffer) SetReceiverReportInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.rrInterval = interval.Nanoseconds()
+}
+
+// SetReceiverReportJitter randomises the interval between RTCP receiver reports by up to +/- jitter so that
+// reports of many buffers are not sent in bursts, should be less than the receiver report interval
+func (b *Buffer) SetReceiverReportJitter(jitter time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.rrJitter = max(0, jitter.Nanoseconds())
+	b.rrJitterOffset = b.receiverReportJitterOffsetLocked()
+}
+
+func (b *Buffer) receiverReportJitterOffsetLocked() int64 {
+	if b.rrJitter == 0 {
+		return 0
+	}
+	return rand.Int63n(2*b.rrJitter+1) - b.rrJitter
+}
+
+func (b *Buffer) SetDDReorderTolerance(tolerance int) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.ddReorderTolerance = tolerance
+	if b.ddParser != nil {
+		b.ddParser.SetReorderTolerance(tolerance)
+	}
+}
+
 func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapability, bitrates int) {
 	b.Lock()
 	defer b.Unlock()
@@ -206,13 +443,15 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 	b.rtpStats = NewRTPStatsReceiver(RTPStatsParams{
 		ClockRate: codec.ClockRate,
 		Logger:    b.logger,
+		Clock:     b.clock,
 	})
 	b.rrSnapshotId = b.rtpStats.NewSnapshotId()
+	b.xrSnapshotId = b.rtpStats.NewSnapshotId()
 	b.deltaStatsSnapshotId = b.rtpStats.NewSnapshotId()
 	b.ppsSnapshotId = b.rtpStats.NewSnapshotId()
 
 	b.clockRate = codec.ClockRate
-	b.lastReport = time.Now().UnixNano()
+	b.lastReport = b.clock.Now().UnixNano()
 	b.mime = strings.ToLower(codec.MimeType)
 	for _, codecParameter := range params.Codecs {
 		if strings.EqualFold(codecParameter.MimeType, codec.MimeType) {
@@ -237,6 +476,7 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 			b.ddParser = NewDependencyDescriptorParser(b.ddExtID, b.logger, func(spatial, temporal int32) {
 				frc.SetMaxLayer(spatial, temporal)
 			})
+			b.ddParser.SetReorderTolerance(b.ddReorderTolerance)
 
 		case sdp.AudioLevelURI:
 			b.audioLevelExtID = uint8(ext.ID)
@@ -253,7 +493,7 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 		b.bucket = bucket.NewBucket(InitPacketBufferSizeAudio)
 	case strings.HasPrefix(b.mime, "video/"):
 		b.codecType = webrtc.RTPCodecTypeVideo
-		b.bucket = bucket.NewBucket(InitPacketBufferSizeVideo)
+		b.bucket = bucket.NewBucket(b.initVideoPktsLocked())
 		if b.frameRateCalculator[0] == nil {
 			if strings.EqualFold(codec.MimeType, webrtc.MimeTypeVP8) {
 				b.frameRateCalculator[0] = NewFrameRateCalculatorVP8(b.clockRate, b.logger)
@@ -267,9 +507,9 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 			}
 		}
 		if bitrates > 0 {
-			pps := bitrates / 8 / 1200
+			pps := b.packetsToCacheLocked(bitrates / 8 / 1200)
 			for pps > b.bucket.Capacity() {
-				if b.bucket.Grow() >= b.maxVideoPkts {
+				if b.bucket.Grow() >= b.maxPktsLocked() {
 					break
 				}
 			}
@@ -308,6 +548,13 @@ func (b *Buffer) Write(pkt []byte) (n int, err error) {
 	var rtpPacket rtp.Packet
 	err = rtpPacket.Unmarshal(pkt)
 	if err != nil {
+		b.RLock()
+		counted := b.countMalformedRTPLocked()
+		b.RUnlock()
+		if counted {
+			// do not fail the write, the transport would log it
+			return len(pkt), nil
+		}
 		return
 	}
 
@@ -340,7 +587,8 @@ func (b *Buffer) Write(pkt []byte) (n int, err error) {
 		}
 	}
 
-	now := time.Now().UnixNano()
+	now := b.clock.Now().UnixNano()
+	b.lastWrite.Store(now)
 	if b.twcc != nil && b.twccExtID != 0 && !b.closed.Load() {
 		if ext := rtpPacket.GetExtension(b.twccExtID); ext != nil {
 			b.twcc.Push(rtpPacket.SSRC, binary.BigEndian.Uint16(ext[0:2]), now, rtpPacket.Marker)
@@ -367,6 +615,7 @@ func (b *Buffer) Write(pkt []byte) (n int, err error) {
 			packet:      packet,
 			arrivalTime: now,
 		})
+		b.trimPendingRTXLocked()
 		b.Unlock()
 		b.readCond.Broadcast()
 		return
@@ -379,6 +628,51 @@ func (b *Buffer) Write(pkt []byte) (n int, err error) {
 	return
 }
 
+// SetMaxRTXPkts marks the buffer as the one of an RTX stream, only the latest maxPkts packets received
+// before it is paired with its primary buffer are kept and its bucket holds at most maxPkts packets
+func (b *Buffer) SetMaxRTXPkts(maxPkts int) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.maxRTXPkts = maxPkts
+	b.trimPendingRTXLocked()
+
+	// bucket of a buffer bound before it was paired is sized for video
+	if b.bucket != nil && b.codecType == webrtc.RTPCodecTypeVideo && b.bucket.Capacity() > maxPkts {
+		b.bucket = bucket.NewBucket(b.initVideoPktsLocked())
+	}
+}
+
+// initVideoPktsLocked returns the initial capacity of the bucket of a video buffer, which it grows in steps of
+func (b *Buffer) initVideoPktsLocked() int {
+	if b.maxRTXPkts > 0 {
+		return min(InitPacketBufferSizeVideo, b.maxRTXPkts)
+	}
+	return InitPacketBufferSizeVideo
+}
+
+// maxPktsLocked returns the capacity the bucket grows to
+func (b *Buffer) maxPktsLocked() int {
+	switch {
+	case b.maxRTXPkts > 0:
+		return b.maxRTXPkts
+	case b.codecType == webrtc.RTPCodecTypeAudio:
+		return b.maxAudioPkts
+	default:
+		return b.maxVideoPkts
+	}
+}
+
+func (b *Buffer) trimPendingRTXLocked() {
+	if b.maxRTXPkts <= 0 || len(b.pPackets) <= b.maxRTXPkts {
+		return
+	}
+
+	drop := len(b.pPackets) - b.maxRTXPkts
+	b.pPackets = append(b.pPackets[:0], b.pPackets[drop:]...)
+	b.lastPacketRead = max(b.lastPacketRead-drop, 0)
+}
+
 func (b *Buffer) SetPrimaryBufferForRTX(primaryBuffer *Buffer) {
 	b.Lock()
 	b.primaryBufferForRTX = primaryBuffer
@@ -485,6 +779,10 @@ func (b *Buffer) Close() error {
 			}
 		}
 
+		if b.keyFrameRequestLimiter != nil {
+			b.keyFrameRequestLimiter.Release(b)
+		}
+
 		b.readCond.Broadcast()
 		if b.onClose != nil {
 			b.onClose()
@@ -504,23 +802,70 @@ func (b *Buffer) SetPLIThrottle(duration int64) {
 	b.pliThrottle = duration
 }
 
+func (b *Buffer) SetKeyFrameRequestMethod(method KeyFrameRequestMethod) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.keyFrameRequestMethod = method
+}
+
+// SetKeyFrameRequestLimiter sets a limiter shared across buffers, key frame requests are sent only
+// when the limiter has room and are released on receiving a key frame
+func (b *Buffer) SetKeyFrameRequestLimiter(limiter *KeyFrameRequestLimiter) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.keyFrameRequestLimiter = limiter
+}
+
 func (b *Buffer) SendPLI(force bool) {
 	b.RLock()
 	rtpStats := b.rtpStats
 	pliThrottle := b.pliThrottle
+	limiter := b.keyFrameRequestLimiter
 	b.RUnlock()
 
 	if (rtpStats == nil && !force) || !rtpStats.CheckAndUpdatePli(pliThrottle, force) {
 		return
 	}
 
-	b.logger.Debugw("send pli", "ssrc", b.mediaSSRC, "force", force)
-	pli := []rtcp.Packet{
-		&rtcp.PictureLossIndication{SenderSSRC: b.mediaSSRC, MediaSSRC: b.mediaSSRC},
+	if limiter != nil {
+		limiter.Request(b, func() {
+			b.sendKeyFrameRequest(force)
+		})
+		return
+	}
+
+	b.sendKeyFrameRequest(force)
+}
+
+func (b *Buffer) sendKeyFrameRequest(force bool) {
+	if b.closed.Load() {
+		return
+	}
+
+	b.Lock()
+	method := b.keyFrameRequestMethod
+	var keyFrameRequest rtcp.Packet
+	switch method {
+	case KeyFrameRequestMethodFIR:
+		// sequence number is incremented for every new request as per RFC 5104, section 4.3.1.1
+		b.firSeqNum++
+		keyFrameRequest = &rtcp.FullIntraRequest{
+			SenderSSRC: b.mediaSSRC,
+			MediaSSRC:  b.mediaSSRC,
+			FIR: []rtcp.FIREntry{
+				{SSRC: b.mediaSSRC, SequenceNumber: b.firSeqNum},
+			},
+		}
+	default:
+		keyFrameRequest = &rtcp.PictureLossIndication{SenderSSRC: b.mediaSSRC, MediaSSRC: b.mediaSSRC}
 	}
+	b.Unlock()
 
+	b.logger.Debugw("send key frame request", "ssrc", b.mediaSSRC, "force", force, "method", method)
 	if b.onRtcpFeedback != nil {
-		b.onRtcpFeedback(pli)
+		b.onRtcpFeedback([]rtcp.Packet{keyFrameRequest})
 	}
 }
 
@@ -551,11 +896,15 @@ func (b *Buffer) calc(rawPkt []byte, rtpPacket *rtp.Packet, arrivalTime int64, i
 	if rtpPacket == nil {
 		rtpPacket = &rtp.Packet{}
 		if err := rtpPacket.Unmarshal(rawPkt); err != nil {
-			b.logger.Errorw("could not unmarshal RTP packet", err)
+			if !b.countMalformedRTPLocked() {
+				b.logger.Errorw("could not unmarshal RTP packet", err)
+			}
 			return
 		}
 	}
 
+	b.adjustTimestampJump(rtpPacket, arrivalTime)
+
 	// process header extensions always as padding packets could be used for probing
 	b.processHeaderExtensions(rtpPacket, arrivalTime, isRTX)
 
@@ -564,7 +913,9 @@ func (b *Buffer) calc(rawPkt []byte, rtpPacket *rtp.Packet, arrivalTime int64, i
 		return
 	}
 
-	if len(rtpPacket.Payload) == 0 && (!flowState.IsOutOfOrder || flowState.IsDuplicate) {
+	isKeepaliveForwarded := b.forwardKeepalive && !rtpPacket.Padding && !flowState.IsDuplicate
+	isPaddingForwarded := b.forwardPadding && rtpPacket.Padding && !flowState.IsDuplicate
+	if len(rtpPacket.Payload) == 0 && !isKeepaliveForwarded && !isPaddingForwarded && (!flowState.IsOutOfOrder || flowState.IsDuplicate) {
 		// drop padding only in-order or duplicate packet
 		if !flowState.IsOutOfOrder {
 			// in-order packet - increment sequence number offset for subsequent packets
@@ -611,6 +962,12 @@ func (b *Buffer) calc(rawPkt []byte, rtpPacket *rtp.Packet, arrivalTime int64, i
 	flowState.ExtSequenceNumber -= snAdjustment
 	rtpPacket.Header.SequenceNumber = uint16(flowState.ExtSequenceNumber)
 	_, err = b.bucket.AddPacketWithSequenceNumber(rawPkt, rtpPacket.Header.SequenceNumber)
+	if err == nil && b.packetArrivals != nil {
+		b.packetArrivals[int(rtpPacket.Header.SequenceNumber)&(len(b.packetArrivals)-1)] = packetArrival{
+			sn:          rtpPacket.Header.SequenceNumber,
+			arrivalTime: arrivalTime,
+		}
+	}
 	if err != nil {
 		if !flowState.IsDuplicate {
 			if errors.Is(err, bucket.ErrPacketTooOld) {
@@ -653,6 +1010,51 @@ func (b *Buffer) calc(rawPkt []byte, rtpPacket *rtp.Packet, arrivalTime int64, i
 	}
 
 	b.doFpsCalc(ep)
+
+	if ep.DependencyDescriptor != nil && ep.DependencyDescriptor.StructureUpdated && len(b.deferredDDPackets) != 0 {
+		b.processDeferredDDPackets(flowState.ExtSequenceNumber)
+	}
+}
+
+func (b *Buffer) maybeDeferDDPacket(rtpPacket *rtp.Packet, arrivalTime int64, flowState RTPFlowState, err error) {
+	if b.keyFrameReorderTolerance == 0 || (!errors.Is(err, dd.ErrDDReaderNoStructure) && !errors.Is(err, dd.ErrDDReaderInvalidTemplateIndex)) {
+		return
+	}
+
+	// the structure has not arrived yet, e.g. the first packet of a key frame is late, hold the packet
+	b.deferredDDPackets = slices.DeleteFunc(b.deferredDDPackets, func(dp deferredDDPacket) bool {
+		return dp.flowState.ExtSequenceNumber+uint64(b.keyFrameReorderTolerance) < flowState.ExtSequenceNumber
+	})
+	if len(b.deferredDDPackets) >= b.keyFrameReorderTolerance {
+		b.deferredDDPackets = b.deferredDDPackets[1:]
+	}
+	b.deferredDDPackets = append(b.deferredDDPackets, deferredDDPacket{
+		packet:      rtpPacket.Clone(),
+		arrivalTime: arrivalTime,
+		flowState:   flowState,
+	})
+}
+
+func (b *Buffer) processDeferredDDPackets(structureExtSequenceNumber uint64) {
+	deferred := b.deferredDDPackets
+	b.deferredDDPackets = nil
+
+	slices.SortFunc(deferred, func(x, y deferredDDPacket) int {
+		return cmp.Compare(x.flowState.ExtSequenceNumber, y.flowState.ExtSequenceNumber)
+	})
+	for _, dp := range deferred {
+		if dp.flowState.ExtSequenceNumber+uint64(b.keyFrameReorderTolerance) < structureExtSequenceNumber {
+			// structure arrived too late for this packet
+			continue
+		}
+
+		ep := b.getExtPacket(dp.packet, dp.arrivalTime, dp.flowState)
+		if ep == nil {
+			continue
+		}
+		b.extPackets.PushBack(ep)
+		b.doFpsCalc(ep)
+	}
 }
 
 func (b *Buffer) patchExtPacket(ep *ExtPacket, buf []byte) *ExtPacket {
@@ -740,6 +1142,69 @@ func (b *Buffer) doFpsCalc(ep *ExtPacket) {
 	}
 }
 
+// adjustTimestampJump compares the timestamp change of a packet to the time elapsed since the highest packet arrived,
+// so that gaps in real time, e.g. DTX silence or a paused source, are not taken for a jump. Only timestamps moving
+// ahead of the arrival time are a jump, packets held back by a network stall arrive late with a small change.
+func (b *Buffer) adjustTimestampJump(p *rtp.Packet, arrivalTime int64) {
+	if b.maxTSJump <= 0 || b.clockRate == 0 {
+		return
+	}
+
+	if !b.tsJumpInitialized {
+		b.tsJumpInitialized = true
+		b.tsJumpHighestSN = p.SequenceNumber
+		b.tsJumpHighestTS = p.Timestamp
+		b.tsJumpHighestArrival = arrivalTime
+		b.tsAdjustmentStartSN = p.SequenceNumber
+		return
+	}
+
+	snDiff := p.SequenceNumber - b.tsJumpHighestSN
+	if snDiff == 0 || snDiff >= (1<<15) {
+		// duplicate or out-of-order, use the adjustment in place when the packet was sent
+		if p.SequenceNumber-b.tsAdjustmentStartSN >= (1 << 15) {
+			p.Timestamp += b.tsAdjustmentPrev
+		} else {
+			p.Timestamp += b.tsAdjustment
+		}
+		return
+	}
+
+	ts := p.Timestamp + b.tsAdjustment
+	jump := int64(int32(ts - b.tsJumpHighestTS))
+	elapsed := max(arrivalTime-b.tsJumpHighestArrival, 0) * int64(b.clockRate) / 1e9
+	if maxJump := b.maxTSJump.Nanoseconds() * int64(b.clockRate) / 1e9; jump > elapsed+maxJump || jump < -maxJump {
+		// continue at the last timestamp step
+		expectedTS := b.tsJumpHighestTS + uint32(snDiff)*b.tsJumpLastStep
+
+		b.tsAdjustmentPrev = b.tsAdjustment
+		b.tsAdjustment += expectedTS - ts
+		b.tsAdjustmentStartSN = p.SequenceNumber
+
+		b.tsDiscontinuityCount++
+		if (b.tsDiscontinuityCount-1)%100 == 0 {
+			b.logger.Infow(
+				"timestamp jump, handling as discontinuity",
+				"sn", p.SequenceNumber,
+				"ts", p.Timestamp,
+				"highestTS", b.tsJumpHighestTS,
+				"expectedTS", expectedTS,
+				"jump", jump,
+				"count", b.tsDiscontinuityCount,
+			)
+		}
+		ts = expectedTS
+	} else if jump > 0 {
+		// packets of a frame share its timestamp, keep the step between frames
+		b.tsJumpLastStep = uint32(jump) / uint32(snDiff)
+	}
+	p.Timestamp = ts
+
+	b.tsJumpHighestSN = p.SequenceNumber
+	b.tsJumpHighestTS = ts
+	b.tsJumpHighestArrival = arrivalTime
+}
+
 func (b *Buffer) updateStreamState(p *rtp.Packet, arrivalTime int64) RTPFlowState {
 	flowState := b.rtpStats.Update(
 		arrivalTime,
@@ -807,6 +1272,7 @@ func (b *Buffer) getExtPacket(rtpPacket *rtp.Packet, arrivalTime int64, flowStat
 	if b.ddParser != nil {
 		ddVal, videoLayer, err := b.ddParser.Parse(ep.Packet)
 		if err != nil {
+			b.maybeDeferDDPacket(rtpPacket, arrivalTime, flowState, err)
 			return nil
 		} else if ddVal != nil {
 			ep.DependencyDescriptor = ddVal
@@ -818,7 +1284,9 @@ func (b *Buffer) getExtPacket(rtpPacket *rtp.Packet, arrivalTime int64, flowStat
 	case "video/vp8":
 		vp8Packet := VP8{}
 		if err := vp8Packet.Unmarshal(rtpPacket.Payload); err != nil {
-			b.logger.Warnw("could not unmarshal VP8 packet", err)
+			if !b.countMalformedRTPLocked() {
+				b.logger.Warnw("could not unmarshal VP8 packet", err)
+			}
 			return nil
 		}
 		ep.KeyFrame = vp8Packet.IsKeyFrame
@@ -836,7 +1304,9 @@ func (b *Buffer) getExtPacket(rtpPacket *rtp.Packet, arrivalTime int64, flowStat
 			var vp9Packet codecs.VP9Packet
 			_, err := vp9Packet.Unmarshal(rtpPacket.Payload)
 			if err != nil {
-				b.logger.Warnw("could not unmarshal VP9 packet", err)
+				if !b.countMalformedRTPLocked() {
+					b.logger.Warnw("could not unmarshal VP9 packet", err)
+				}
 				return nil
 			}
 			ep.VideoLayer = VideoLayer{
@@ -858,6 +1328,9 @@ func (b *Buffer) getExtPacket(rtpPacket *rtp.Packet, arrivalTime int64, flowStat
 		if b.rtpStats != nil {
 			b.rtpStats.UpdateKeyFrame(1)
 		}
+		if b.keyFrameRequestLimiter != nil {
+			b.keyFrameRequestLimiter.Release(b)
+		}
 	}
 
 	if b.absCaptureTimeExtID != 0 {
@@ -888,11 +1361,12 @@ func (b *Buffer) doNACKs() {
 }
 
 func (b *Buffer) doReports(arrivalTime int64) {
-	if arrivalTime-b.lastReport < ReportDelta {
+	if arrivalTime-b.lastReport < b.rrInterval+b.rrJitterOffset {
 		return
 	}
 
 	b.lastReport = arrivalTime
+	b.rrJitterOffset = b.receiverReportJitterOffsetLocked()
 
 	// RTCP reports
 	pkts := b.getRTCP()
@@ -905,26 +1379,77 @@ func (b *Buffer) doReports(arrivalTime int64) {
 
 func (b *Buffer) mayGrowBucket() {
 	cap := b.bucket.Capacity()
-	maxPkts := b.maxVideoPkts
-	if b.codecType == webrtc.RTPCodecTypeAudio {
-		maxPkts = b.maxAudioPkts
-	}
-	if cap >= maxPkts {
+	maxPkts := b.maxPktsLocked()
+	// bucket is sized by the max packet age when it is shorter than the second of packets held otherwise
+	sizedByAge := b.maxPacketAge > 0 && b.maxPacketAge < time.Second
+	if cap >= maxPkts && !sizedByAge {
 		return
 	}
 	oldCap := cap
 	if deltaInfo := b.rtpStats.DeltaInfo(b.ppsSnapshotId); deltaInfo != nil {
 		duration := deltaInfo.EndTime.Sub(deltaInfo.StartTime)
 		if duration > 500*time.Millisecond {
-			pps := int(time.Duration(deltaInfo.Packets) * time.Second / duration)
+			pps := b.packetsToCacheLocked(int(time.Duration(deltaInfo.Packets) * time.Second / duration))
 			for pps > cap && cap < maxPkts {
 				cap = b.bucket.Grow()
 			}
 			if cap > oldCap {
 				b.logger.Debugw("grow bucket", "from", oldCap, "to", cap, "pps", pps)
 			}
+			if sizedByAge {
+				b.mayShrinkBucketLocked(pps)
+			}
+		}
+	}
+}
+
+// mayShrinkBucketLocked replaces the bucket with a smaller one when it can hold more than twice the packets of the
+// max packet age, e.g. after the packet rate dropped, keeping the packets that are not older than the max age
+func (b *Buffer) mayShrinkBucketLocked(pps int) {
+	initCap := b.initVideoPktsLocked()
+	if b.codecType == webrtc.RTPCodecTypeAudio {
+		initCap = InitPacketBufferSizeAudio
+	}
+	// bucket grows in steps of its initial capacity
+	cap := max(1, (pps+initCap-1)/initCap) * initCap
+	oldCap := b.bucket.Capacity()
+	if oldCap < 2*cap {
+		return
+	}
+
+	shrunk := bucket.NewBucket(initCap)
+	for shrunk.Capacity() < cap {
+		shrunk.Grow()
+	}
+
+	now := b.clock.Now().UnixNano()
+	headSN := b.bucket.HeadSequenceNumber()
+	buf := make([]byte, bucket.MaxPktSize)
+	for i := cap - 1; i >= 0; i-- {
+		sn := headSN - uint16(i)
+		arrival := b.packetArrivals[int(sn)&(len(b.packetArrivals)-1)]
+		if arrival.sn != sn || now-arrival.arrivalTime > b.maxPacketAge.Nanoseconds() {
+			continue
 		}
+		n, err := b.bucket.GetPacket(buf, sn)
+		if err != nil {
+			continue
+		}
+		if _, err := shrunk.AddPacketWithSequenceNumber(buf[:n], sn); err != nil {
+			b.logger.Debugw("could not keep packet in shrunk bucket", "error", err, "sn", sn)
+		}
+	}
+	b.bucket = shrunk
+	b.logger.Debugw("shrink bucket", "from", oldCap, "to", cap, "pps", pps)
+}
+
+// packetsToCacheLocked returns the packets to hold in the bucket for the given packet rate, a second of packets or
+// packets of the max packet age if it is shorter
+func (b *Buffer) packetsToCacheLocked(pps int) int {
+	if b.maxPacketAge <= 0 || b.maxPacketAge >= time.Second {
+		return pps
 	}
+	return int((int64(pps)*b.maxPacketAge.Nanoseconds() + int64(time.Second) - 1) / int64(time.Second))
 }
 
 func (b *Buffer) buildNACKPacket() ([]rtcp.Packet, int) {
@@ -955,9 +1480,10 @@ func (b *Buffer) buildReceptionReport() *rtcp.ReceptionReport {
 func (b *Buffer) SetSenderReportData(rtpTime uint32, ntpTime uint64) {
 	b.RLock()
 	srData := &RTCPSenderReportData{
-		RTPTimestamp: rtpTime,
+		// in the timebase of forwarded packets after any timestamp discontinuity
+		RTPTimestamp: rtpTime + b.tsAdjustment,
 		NTPTimestamp: mediatransportutil.NtpTime(ntpTime),
-		At:           time.Now(),
+		At:           b.clock.Now(),
 	}
 
 	didSet := false
@@ -1000,13 +1526,29 @@ func (b *Buffer) getRTCP() []rtcp.Packet {
 		})
 	}
 
+	if b.enableRTCPXR && b.rtpStats != nil {
+		if summary := b.rtpStats.GetRtcpStatisticsSummary(b.mediaSSRC, b.xrSnapshotId); summary != nil {
+			pkts = append(pkts, &rtcp.ExtendedReport{
+				SenderSSRC: b.mediaSSRC,
+				Reports:    []rtcp.ReportBlock{summary},
+			})
+		}
+	}
+
 	return pkts
 }
 
+// GetPacket returns a packet for retransmission, failing with bucket.ErrPacketTooOld past the max packet age
 func (b *Buffer) GetPacket(buff []byte, sn uint16) (int, error) {
 	b.Lock()
 	defer b.Unlock()
 
+	if b.packetArrivals != nil && !b.closed.Load() {
+		arrival := b.packetArrivals[int(sn)&(len(b.packetArrivals)-1)]
+		if arrival.sn != sn || b.clock.Now().UnixNano()-arrival.arrivalTime > b.maxPacketAge.Nanoseconds() {
+			return 0, fmt.Errorf("%w, sn %d, max age %s", bucket.ErrPacketTooOld, sn, b.maxPacketAge)
+		}
+	}
 	return b.getPacket(buff, sn)
 }
 
@@ -1090,7 +1632,7 @@ func (b *Buffer) GetAudioLevel() (float64, bool) {
 		return 0, false
 	}
 
-	return b.audioLevel.GetLevel(time.Now().UnixNano())
+	return b.audioLevel.GetLevel(b.clock.Now().UnixNano())
 }
 
 func (b *Buffer) OnFpsChanged(f func()) {
@@ -15,6 +15,7 @@
 package buffer
 
 import (
+	"encoding/hex"
 	"math"
 	"sync"
 	"testing"
@@ -25,6 +26,8 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 
+	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/mediatransportutil/pkg/bucket"
 	"github.com/livekit/mediatransportutil/pkg/nack"
 )
 
@@ -314,6 +317,327 @@ func TestFractionLostReport(t *testing.T) {
 	wg.Wait()
 }
 
+func TestReceiverReportInterval(t *testing.T) {
+	countReports := func(codec webrtc.RTPCodecParameters, interval time.Duration) int {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(interval)
+
+		numReports := 0
+		buff.OnRtcpFeedback(func(fb []rtcp.Packet) {
+			for _, pkt := range fb {
+				if _, ok := pkt.(*rtcp.ReceiverReport); ok {
+					numReports++
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{codec},
+		}, codec.RTPCodecCapability, 0)
+
+		// 2 seconds worth of packets at 20ms spacing, using synthetic arrival times
+		start := time.Now().UnixNano()
+		for i := 1; i <= 100; i++ {
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    uint8(codec.PayloadType),
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 960),
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+
+			buff.Lock()
+			buff.calc(b, nil, start+int64(i)*int64(20*time.Millisecond), false)
+			buff.Unlock()
+		}
+		return numReports
+	}
+
+	// default interval of one second
+	require.InDelta(t, 2, countReports(opusCodec, 0), 1)
+	require.InDelta(t, 2, countReports(vp8Codec, 0), 1)
+
+	// configured per kind
+	require.InDelta(t, 4, countReports(opusCodec, 500*time.Millisecond), 1)
+	require.InDelta(t, 8, countReports(vp8Codec, 250*time.Millisecond), 1)
+}
+
+func TestReceiverReportJitter(t *testing.T) {
+	reportGaps := func(interval time.Duration, jitter time.Duration) []time.Duration {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(interval)
+		buff.SetReceiverReportJitter(jitter)
+
+		var arrivalTime int64
+		var reportTimes []int64
+		buff.OnRtcpFeedback(func(fb []rtcp.Packet) {
+			for _, pkt := range fb {
+				if _, ok := pkt.(*rtcp.ReceiverReport); ok {
+					reportTimes = append(reportTimes, arrivalTime)
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{opusCodec},
+		}, opusCodec.RTPCodecCapability, 0)
+
+		// 20 seconds worth of packets at 1ms spacing, using synthetic arrival times
+		start := time.Now().UnixNano()
+		for i := 1; i <= 20_000; i++ {
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    uint8(opusCodec.PayloadType),
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 48),
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+
+			arrivalTime = start + int64(i)*int64(time.Millisecond)
+			buff.Lock()
+			buff.calc(b, nil, arrivalTime, false)
+			buff.Unlock()
+		}
+
+		var gaps []time.Duration
+		for i := 1; i < len(reportTimes); i++ {
+			gaps = append(gaps, time.Duration(reportTimes[i]-reportTimes[i-1]))
+		}
+		return gaps
+	}
+
+	// without jitter, reports are sent at the interval
+	gaps := reportGaps(500*time.Millisecond, 0)
+	require.NotEmpty(t, gaps)
+	for _, gap := range gaps {
+		require.InDelta(t, 500*time.Millisecond, gap, float64(time.Millisecond))
+	}
+
+	// with jitter, reports are spread within interval +/- jitter
+	gaps = reportGaps(500*time.Millisecond, 200*time.Millisecond)
+	require.NotEmpty(t, gaps)
+	jittered := false
+	for _, gap := range gaps {
+		require.GreaterOrEqual(t, gap, 300*time.Millisecond)
+		require.LessOrEqual(t, gap, 701*time.Millisecond)
+		if gap < 490*time.Millisecond || gap > 510*time.Millisecond {
+			jittered = true
+		}
+	}
+	require.True(t, jittered)
+}
+
+func TestMaxTimestampJump(t *testing.T) {
+	// returns the forwarded timestamps of 100 packets at 20ms spacing, with a jump of 10 minutes after packet 50
+	// and the packets after packet 25 arriving after a stall of 10s
+	forwardedTimestamps := func(maxJump time.Duration) ([]uint64, *Buffer) {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetMaxTimestampJump(maxJump)
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{opusCodec},
+		}, opusCodec.RTPCodecCapability, 0)
+
+		start := time.Now().UnixNano()
+		var timestamps []uint64
+		for i := 1; i <= 100; i++ {
+			ts := uint32(i * 960)
+			if i > 50 {
+				ts += 10 * 60 * 48000
+			}
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    uint8(opusCodec.PayloadType),
+					SequenceNumber: uint16(i),
+					Timestamp:      ts,
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+
+			arrivalTime := start + int64(i)*int64(20*time.Millisecond)
+			if i > 25 {
+				arrivalTime += int64(10 * time.Second)
+			}
+			buff.Lock()
+			buff.calc(b, nil, arrivalTime, false)
+			ep := buff.extPackets.PopBack()
+			buff.Unlock()
+
+			require.Equal(t, uint32(ep.ExtTimestamp), ep.Packet.Timestamp)
+			timestamps = append(timestamps, ep.ExtTimestamp)
+		}
+		return timestamps, buff
+	}
+
+	// forwarded as received by default
+	timestamps, buff := forwardedTimestamps(0)
+	require.Equal(t, uint64(960+10*60*48000), timestamps[50]-timestamps[49])
+	require.Zero(t, buff.tsDiscontinuityCount)
+
+	// jumps within the tolerance are forwarded as received
+	timestamps, buff = forwardedTimestamps(20 * time.Minute)
+	require.Equal(t, uint64(960+10*60*48000), timestamps[50]-timestamps[49])
+	require.Zero(t, buff.tsDiscontinuityCount)
+
+	// larger jumps are a discontinuity, the stream continues from the expected timestamp
+	timestamps, buff = forwardedTimestamps(5 * time.Second)
+	for i := 1; i < len(timestamps); i++ {
+		require.Equal(t, uint64(960), timestamps[i]-timestamps[i-1])
+	}
+	require.Equal(t, 1, buff.tsDiscontinuityCount)
+	require.Less(t, buff.rtpStats.jitter, float64(48000))
+
+	// out-of-order packets from before the jump keep their timebase
+	pkt := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    uint8(opusCodec.PayloadType),
+			SequenceNumber: 50,
+			Timestamp:      50 * 960,
+			SSRC:           123,
+		},
+	}
+	buff.adjustTimestampJump(&pkt, time.Now().UnixNano())
+	require.Equal(t, uint32(50*960), pkt.Timestamp)
+}
+
+func TestMaxTimestampJumpDTX(t *testing.T) {
+	buff := NewBuffer(123, 1, 1)
+	buff.SetReceiverReportInterval(time.Hour)
+	buff.SetMaxTimestampJump(5 * time.Second)
+	buff.Bind(webrtc.RTPParameters{
+		HeaderExtensions: nil,
+		Codecs:           []webrtc.RTPCodecParameters{opusCodec},
+	}, opusCodec.RTPCodecCapability, 0)
+
+	// sequence number contiguous packets with 10s of DTX silence after packet 25, timestamps follow the arrival time
+	start := time.Now().UnixNano()
+	var timestamps []uint64
+	for i := 1; i <= 50; i++ {
+		ts := uint32(i * 960)
+		arrivalTime := start + int64(i)*int64(20*time.Millisecond)
+		if i > 25 {
+			ts += 10 * 48000
+			arrivalTime += int64(10 * time.Second)
+		}
+		pkt := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    uint8(opusCodec.PayloadType),
+				SequenceNumber: uint16(i),
+				Timestamp:      ts,
+				SSRC:           123,
+			},
+			Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+		}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+
+		buff.Lock()
+		buff.calc(b, nil, arrivalTime, false)
+		ep := buff.extPackets.PopBack()
+		buff.Unlock()
+
+		timestamps = append(timestamps, ep.ExtTimestamp)
+	}
+
+	// the silence is kept in the timeline
+	require.Equal(t, uint64(960+10*48000), timestamps[25]-timestamps[24])
+	require.Zero(t, buff.tsDiscontinuityCount)
+}
+
+func TestRTCPExtendedReports(t *testing.T) {
+	statisticsSummary := func(t *testing.T, enable bool) *rtcp.StatisticsSummaryReportBlock {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetRTCPExtendedReports(enable)
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{opusCodec},
+		}, opusCodec.RTPCodecCapability, 0)
+
+		start := time.Now().UnixNano()
+		for i := 1; i <= 100; i++ {
+			if i == 50 {
+				// lost
+				continue
+			}
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    uint8(opusCodec.PayloadType),
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 960),
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+
+			// every other packet arrives late to introduce jitter
+			arrivalTime := start + int64(i)*int64(20*time.Millisecond) + int64(i%2)*int64(5*time.Millisecond)
+			buff.Lock()
+			buff.calc(b, nil, arrivalTime, false)
+			if i == 60 {
+				buff.calc(b, nil, arrivalTime, false)
+			}
+			buff.Unlock()
+		}
+
+		buff.Lock()
+		pkts := buff.getRTCP()
+		buff.Unlock()
+
+		// reports survive the wire
+		b, err := rtcp.Marshal(pkts)
+		require.NoError(t, err)
+		pkts, err = rtcp.Unmarshal(b)
+		require.NoError(t, err)
+
+		var summary *rtcp.StatisticsSummaryReportBlock
+		for _, pkt := range pkts {
+			if xr, ok := pkt.(*rtcp.ExtendedReport); ok {
+				require.Len(t, xr.Reports, 1)
+				summary, ok = xr.Reports[0].(*rtcp.StatisticsSummaryReportBlock)
+				require.True(t, ok)
+			}
+		}
+		return summary
+	}
+
+	require.Nil(t, statisticsSummary(t, false))
+
+	summary := statisticsSummary(t, true)
+	require.NotNil(t, summary)
+	require.True(t, summary.LossReports)
+	require.True(t, summary.DuplicateReports)
+	require.True(t, summary.JitterReports)
+	require.Equal(t, uint32(123), summary.SSRC)
+	require.Equal(t, uint16(1), summary.BeginSeq)
+	require.Equal(t, uint16(101), summary.EndSeq)
+	require.Equal(t, uint32(1), summary.LostPackets)
+	require.Equal(t, uint32(1), summary.DupPackets)
+	require.NotZero(t, summary.MaxJitter)
+	require.LessOrEqual(t, summary.MinJitter, summary.MeanJitter)
+	require.LessOrEqual(t, summary.MeanJitter, summary.MaxJitter)
+}
+
 func BenchmarkMemcpu(b *testing.B) {
 	buf := make([]byte, 1500*1500*10)
 	buf2 := make([]byte, 1500*1500*20)
@@ -324,3 +648,257 @@ func BenchmarkMemcpu(b *testing.B) {
 	}
 
 }
+
+func TestKeyFrameRequestMethod(t *testing.T) {
+	sendKeyFrameRequests := func(method KeyFrameRequestMethod) []rtcp.Packet {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetKeyFrameRequestMethod(method)
+
+		var pkts []rtcp.Packet
+		buff.OnRtcpFeedback(func(fb []rtcp.Packet) {
+			pkts = append(pkts, fb...)
+		})
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{vp8Codec},
+		}, vp8Codec.RTPCodecCapability, 0)
+
+		buff.SendPLI(true)
+		buff.SendPLI(true)
+		return pkts
+	}
+
+	t.Run("pli", func(t *testing.T) {
+		pkts := sendKeyFrameRequests(KeyFrameRequestMethodPLI)
+		require.Len(t, pkts, 2)
+		for _, pkt := range pkts {
+			require.Equal(t, &rtcp.PictureLossIndication{SenderSSRC: 123, MediaSSRC: 123}, pkt)
+		}
+	})
+
+	t.Run("fir", func(t *testing.T) {
+		pkts := sendKeyFrameRequests(KeyFrameRequestMethodFIR)
+		require.Len(t, pkts, 2)
+		for i, pkt := range pkts {
+			fir, ok := pkt.(*rtcp.FullIntraRequest)
+			require.True(t, ok)
+			require.Equal(t, uint32(123), fir.MediaSSRC)
+			// every new request should use the next sequence number
+			require.Equal(t, []rtcp.FIREntry{{SSRC: 123, SequenceNumber: uint8(i + 1)}}, fir.FIR)
+		}
+	})
+}
+
+func TestForwardKeepalive(t *testing.T) {
+	// returns the number of packets kept for forwarding of a media packet followed by a keepalive packet
+	keptPackets := func(forward bool, padding bool) int {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetForwardKeepalive(forward)
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{opusCodec},
+		}, opusCodec.RTPCodecCapability, 0)
+
+		media := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    uint8(opusCodec.PayloadType),
+				SequenceNumber: 1,
+				Timestamp:      960,
+				SSRC:           123,
+			},
+			Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+		}
+		keepalive := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    uint8(opusCodec.PayloadType),
+				SequenceNumber: 2,
+				Timestamp:      960,
+				SSRC:           123,
+			},
+		}
+		if padding {
+			keepalive.Header.Padding = true
+			keepalive.PaddingSize = 255
+		}
+
+		now := time.Now().UnixNano()
+		buff.Lock()
+		defer buff.Unlock()
+		for i, pkt := range []rtp.Packet{media, keepalive} {
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+			buff.calc(b, nil, now+int64(i)*int64(20*time.Millisecond), false)
+		}
+		return buff.extPackets.Len()
+	}
+
+	// dropped like padding only packets by default
+	require.Equal(t, 1, keptPackets(false, false))
+
+	// kept for forwarding
+	require.Equal(t, 2, keptPackets(true, false))
+
+	// padding only packets are still dropped
+	require.Equal(t, 1, keptPackets(true, true))
+}
+
+func TestKeyFrameReorderTolerance(t *testing.T) {
+	av1Codec := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:  "video/av1",
+			ClockRate: 90000,
+		},
+		PayloadType: 45,
+	}
+
+	// returns the sequence numbers of packets kept for forwarding
+	keptPackets := func(tolerance int, sns []uint16) []uint16 {
+		buff := NewBuffer(123, 1, 1)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetKeyFrameReorderTolerance(tolerance)
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: []webrtc.RTPHeaderExtensionParameter{{URI: dd.ExtensionURI, ID: testDDExtID}},
+			Codecs:           []webrtc.RTPCodecParameters{av1Codec},
+		}, av1Codec.RTPCodecCapability, 0)
+
+		structureBuf, err := hex.DecodeString(testDDStructureHex)
+		require.NoError(t, err)
+
+		now := time.Now().UnixNano()
+		buff.Lock()
+		defer buff.Unlock()
+		for i, sn := range sns {
+			var pkt *rtp.Packet
+			switch {
+			case sn < 100:
+				// before the key frame, without dependency descriptor
+				pkt = &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: sn}, Payload: []byte{0x01}}
+			case sn == 100:
+				// first packet of the key frame, carrying the structure
+				pkt = newDDTestPacket(t, sn, structureBuf)
+			default:
+				pkt = newDDTestPacket(t, sn, ddSinglePacketFrame(0x0172+sn-100))
+			}
+			pkt.PayloadType = uint8(av1Codec.PayloadType)
+			pkt.SSRC = 123
+			pkt.Timestamp = 3000 * uint32(sn)
+
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+			buff.calc(b, nil, now+int64(i)*int64(time.Millisecond), false)
+		}
+
+		var kept []uint16
+		for i := 0; i < buff.extPackets.Len(); i++ {
+			kept = append(kept, buff.extPackets.At(i).Packet.SequenceNumber)
+		}
+		return kept
+	}
+
+	// frames ahead of a late structure are dropped by default
+	require.Equal(t, []uint16{99, 100}, keptPackets(0, []uint16{99, 101, 100}))
+
+	// held and forwarded after the structure
+	require.Equal(t, []uint16{99, 100, 101}, keptPackets(8, []uint16{99, 101, 100}))
+	require.Equal(t, []uint16{99, 100, 101, 102, 103}, keptPackets(8, []uint16{99, 103, 101, 102, 100}))
+
+	// held up to the tolerance, earlier packets are dropped
+	require.Equal(t, []uint16{99, 100, 102, 103}, keptPackets(2, []uint16{99, 101, 102, 103, 100}))
+}
+
+func TestMaxPacketAge(t *testing.T) {
+	newBuffer := func(clock Clock, maxAge time.Duration) *Buffer {
+		buff := NewBuffer(123, 500, 200)
+		buff.SetClock(clock)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetMaxPacketAge(maxAge)
+		buff.Bind(webrtc.RTPParameters{
+			Codecs: []webrtc.RTPCodecParameters{vp8Codec},
+		}, vp8Codec.RTPCodecCapability, 0)
+		return buff
+	}
+	write := func(buff *Buffer, sn uint16) {
+		pkt, err := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: sn, Timestamp: 3000 * uint32(sn), SSRC: 123},
+			Payload: []byte{0x10, 0x01, 0x02},
+		}).Marshal()
+		require.NoError(t, err)
+		_, err = buff.Write(pkt)
+		require.NoError(t, err)
+	}
+	retransmittable := func(buff *Buffer, sn uint16) error {
+		_, err := buff.GetPacket(make([]byte, bucket.MaxPktSize), sn)
+		return err
+	}
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	buff := newBuffer(clock, 100*time.Millisecond)
+	write(buff, 1)
+	write(buff, 2)
+	clock.Advance(50 * time.Millisecond)
+	write(buff, 3)
+	require.NoError(t, retransmittable(buff, 1))
+	require.NoError(t, retransmittable(buff, 3))
+
+	// older than the max age
+	clock.Advance(60 * time.Millisecond)
+	require.ErrorIs(t, retransmittable(buff, 1), bucket.ErrPacketTooOld)
+	require.ErrorIs(t, retransmittable(buff, 2), bucket.ErrPacketTooOld)
+	require.NoError(t, retransmittable(buff, 3))
+
+	// not received
+	require.Error(t, retransmittable(buff, 4))
+
+	// without max age, any packet in the bucket
+	clock = &fakeClock{now: time.Unix(1000, 0)}
+	buff = newBuffer(clock, 0)
+	write(buff, 1)
+	clock.Advance(time.Minute)
+	require.NoError(t, retransmittable(buff, 1))
+
+	// bucket grows to hold packets of the max age only
+	buff.Lock()
+	require.Equal(t, 2000, buff.packetsToCacheLocked(2000))
+	buff.Unlock()
+	buff = newBuffer(clock, 100*time.Millisecond)
+	buff.Lock()
+	require.Equal(t, 200, buff.packetsToCacheLocked(2000))
+	require.Equal(t, 1, buff.packetsToCacheLocked(1))
+	buff.Unlock()
+
+	// bucket grown for a higher packet rate is shrunk, evicting packets older than the max age
+	clock = &fakeClock{now: time.Unix(1000, 0)}
+	buff = newBuffer(clock, 100*time.Millisecond)
+	buff.Lock()
+	buff.bucket.Grow()
+	require.Equal(t, 2*InitPacketBufferSizeVideo, buff.bucket.Capacity())
+	buff.Unlock()
+	for sn := uint16(1); sn <= 10; sn++ {
+		write(buff, sn)
+	}
+	clock.Advance(60 * time.Millisecond)
+	for sn := uint16(11); sn <= 20; sn++ {
+		write(buff, sn)
+	}
+	clock.Advance(60 * time.Millisecond)
+
+	buff.Lock()
+	defer buff.Unlock()
+	// rate still needing the capacity keeps the bucket
+	buff.mayShrinkBucketLocked(InitPacketBufferSizeVideo + 1)
+	require.Equal(t, 2*InitPacketBufferSizeVideo, buff.bucket.Capacity())
+
+	buff.mayShrinkBucketLocked(100)
+	require.Equal(t, InitPacketBufferSizeVideo, buff.bucket.Capacity())
+	for sn := uint16(1); sn <= 20; sn++ {
+		_, err := buff.bucket.GetPacket(make([]byte, bucket.MaxPktSize), sn)
+		if sn <= 10 {
+			require.Error(t, err, sn)
+		} else {
+			require.NoError(t, err, sn)
+		}
+	}
+}
@@ -30,6 +30,7 @@ import (
 var (
 	ErrFrameEarlierThanKeyFrame            = fmt.Errorf("frame is earlier than current keyframe")
 	ErrDDStructureAttachedToNonFirstPacket = fmt.Errorf("dependency descriptor structure is attached to non-first packet of a frame")
+	ErrDDReorderToleranceExceeded          = fmt.Errorf("dependency descriptor packet is out of order beyond reorder tolerance")
 )
 
 type DependencyDescriptorParser struct {
@@ -46,7 +47,14 @@ type DependencyDescriptorParser struct {
 	activeDecodeTargetsMask   uint32
 	frameChecker              *FrameIntegrityChecker
 
-	ddNotFoundCount atomic.Uint32
+	// number of packets a packet can arrive behind the highest seen sequence number and still be parsed,
+	// 0 means no limit
+	reorderTolerance uint64
+	highestExtSeq    uint64
+	highestExtSeqSet bool
+
+	ddNotFoundCount     atomic.Uint32
+	reorderDroppedCount atomic.Uint32
 }
 
 func NewDependencyDescriptorParser(ddExtID uint8, logger logger.Logger, onMaxLayerChanged func(int32, int32)) *DependencyDescriptorParser {
@@ -60,6 +68,13 @@ func NewDependencyDescriptorParser(ddExtID uint8, logger logger.Logger, onMaxLay
 	}
 }
 
+func (r *DependencyDescriptorParser) SetReorderTolerance(tolerance int) {
+	if tolerance < 0 {
+		tolerance = 0
+	}
+	r.reorderTolerance = uint64(tolerance)
+}
+
 type ExtDependencyDescriptor struct {
 	Descriptor *dd.DependencyDescriptor
 
@@ -97,6 +112,24 @@ func (r *DependencyDescriptorParser) Parse(pkt *rtp.Packet) (*ExtDependencyDescr
 	}
 
 	extSeq := r.seqWrapAround.Update(pkt.SequenceNumber).ExtendedVal
+	if !r.highestExtSeqSet || extSeq > r.highestExtSeq {
+		r.highestExtSeqSet = true
+		r.highestExtSeq = extSeq
+	} else if r.reorderTolerance != 0 && r.highestExtSeq-extSeq > r.reorderTolerance {
+		// a descriptor this far behind could be associated with the wrong frame after frame number extension,
+		// drop it rather than risk forwarding with wrong layer information
+		reorderDroppedCount := r.reorderDroppedCount.Inc()
+		if (reorderDroppedCount-1)%100 == 0 {
+			r.logger.Debugw(
+				"drop dependency descriptor beyond reorder tolerance",
+				"extSeq", extSeq,
+				"highestExtSeq", r.highestExtSeq,
+				"tolerance", r.reorderTolerance,
+				"count", reorderDroppedCount,
+			)
+		}
+		return nil, videoLayer, ErrDDReorderToleranceExceeded
+	}
 
 	if ddVal.FrameDependencies != nil {
 		videoLayer.Spatial, videoLayer.Temporal = int32(ddVal.FrameDependencies.SpatialId), int32(ddVal.FrameDependencies.TemporalId)
@@ -0,0 +1,107 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const testDDExtID = 5
+
+// captured descriptor carrying the template dependency structure for frame 0x0172
+const testDDStructureHex = "c1017280081485214eafffaaaa863cf0430c10c302afc0aaa0063c00430010c002a000a80006000040001d954926e082b04a0941b820ac1282503157f974000ca864330e222222eca8655304224230eca877530077004200ef008601df010d"
+
+func newDDTestPacket(t *testing.T, sn uint16, ddBuf []byte) *rtp.Packet {
+	pkt := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			SequenceNumber: sn,
+		},
+		Payload: []byte{0x01},
+	}
+	require.NoError(t, pkt.SetExtension(testDDExtID, ddBuf))
+	return pkt
+}
+
+// single packet frame using template 6 of the captured structure
+func ddSinglePacketFrame(frameNum uint16) []byte {
+	return []byte{0xc6, byte(frameNum >> 8), byte(frameNum)}
+}
+
+func newDDTestParser(t *testing.T, tolerance int) *DependencyDescriptorParser {
+	parser := NewDependencyDescriptorParser(testDDExtID, logger.GetLogger(), func(_, _ int32) {})
+	parser.SetReorderTolerance(tolerance)
+
+	structureBuf, err := hex.DecodeString(testDDStructureHex)
+	require.NoError(t, err)
+	extDD, _, err := parser.Parse(newDDTestPacket(t, 100, structureBuf))
+	require.NoError(t, err)
+	require.True(t, extDD.StructureUpdated)
+	return parser
+}
+
+func TestDependencyDescriptorParserReorderTolerance(t *testing.T) {
+	t.Run("reordered within tolerance", func(t *testing.T) {
+		parser := newDDTestParser(t, 5)
+
+		extDD, _, err := parser.Parse(newDDTestPacket(t, 110, ddSinglePacketFrame(0x0172+10)))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0x0172+10), extDD.ExtFrameNum)
+
+		// arrives 3 packets late, should still be associated with its own frame
+		extDD, _, err = parser.Parse(newDDTestPacket(t, 107, ddSinglePacketFrame(0x0172+7)))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0x0172+7), extDD.ExtFrameNum)
+		require.True(t, extDD.Integrity)
+
+		// exactly at the edge of the window
+		extDD, _, err = parser.Parse(newDDTestPacket(t, 105, ddSinglePacketFrame(0x0172+5)))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0x0172+5), extDD.ExtFrameNum)
+	})
+
+	t.Run("reordered beyond tolerance", func(t *testing.T) {
+		parser := newDDTestParser(t, 5)
+
+		_, _, err := parser.Parse(newDDTestPacket(t, 110, ddSinglePacketFrame(0x0172+10)))
+		require.NoError(t, err)
+
+		extDD, _, err := parser.Parse(newDDTestPacket(t, 104, ddSinglePacketFrame(0x0172+4)))
+		require.ErrorIs(t, err, ErrDDReorderToleranceExceeded)
+		require.Nil(t, extDD)
+
+		// in-order packets continue to be parsed after a drop
+		extDD, _, err = parser.Parse(newDDTestPacket(t, 111, ddSinglePacketFrame(0x0172+11)))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0x0172+11), extDD.ExtFrameNum)
+	})
+
+	t.Run("no tolerance configured", func(t *testing.T) {
+		parser := newDDTestParser(t, 0)
+
+		_, _, err := parser.Parse(newDDTestPacket(t, 110, ddSinglePacketFrame(0x0172+10)))
+		require.NoError(t, err)
+
+		extDD, _, err := parser.Parse(newDDTestPacket(t, 104, ddSinglePacketFrame(0x0172+4)))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0x0172+4), extDD.ExtFrameNum)
+	})
+}
@@ -15,15 +15,102 @@
 package buffer
 
 import (
+	"errors"
 	"io"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/pion/transport/v2/packetio"
 )
 
+// FactoryObserver is notified of buffer lookups through factories, split by whether a buffer was allocated or an
+// existing one reused, and of malformed RTP packets dropped by their buffers, whatever the malformed RTP policy. A high allocation rate points to
+// buffers being torn down and recreated.
+type FactoryObserver interface {
+	OnBufferLookup(packetType packetio.BufferPacketType, allocated bool)
+	OnMalformedRTP()
+}
+
+// MalformedRTPPolicy decides how buffers handle RTP packets that cannot be parsed, either the RTP header or the
+// payload header of the codec
+type MalformedRTPPolicy int
+
+const (
+	// MalformedRTPPolicyLog drops the packet and logs it
+	MalformedRTPPolicyLog MalformedRTPPolicy = iota
+	// MalformedRTPPolicyCount drops the packet silently
+	MalformedRTPPolicyCount
+)
+
+func (m MalformedRTPPolicy) String() string {
+	switch m {
+	case MalformedRTPPolicyLog:
+		return "LOG"
+	case MalformedRTPPolicyCount:
+		return "COUNT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// SSRCCollisionPolicy decides how an SSRC claimed by more than one stream is handled. Published and subscribed
+// tracks of a participant share a factory, so a subscribed track can be assigned the SSRC of a published one.
+// RTCP of both then arrives at the same reader, which cannot tell which transport a packet came in on.
+type SSRCCollisionPolicy int
+
+const (
+	// SSRCCollisionPolicyRemap maps each RTCP packet of the SSRC to the stream it belongs to, packets sent using the SSRC
+	// to the published stream and feedback about the SSRC to the subscribed stream
+	SSRCCollisionPolicyRemap SSRCCollisionPolicy = iota
+	// SSRCCollisionPolicyReject refuses the later stream, so it can be set up again with a different SSRC
+	SSRCCollisionPolicyReject
+)
+
+func (s SSRCCollisionPolicy) String() string {
+	switch s {
+	case SSRCCollisionPolicyRemap:
+		return "REMAP"
+	case SSRCCollisionPolicyReject:
+		return "REJECT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+var ErrRTXAssociationConflict = errors.New("rtx association conflicts with a known one")
+
+// RTXAssociationPolicy decides how an RTX association conflicting with a known one is handled, e.g. a repair SSRC
+// associated with a second primary stream or an SSRC associated as both a repair and a primary stream
+type RTXAssociationPolicy int
+
+const (
+	// RTXAssociationPolicyBestEffort replaces the known association with the latest one
+	RTXAssociationPolicyBestEffort RTXAssociationPolicy = iota
+	// RTXAssociationPolicyStrict keeps the known association and rejects the conflicting one
+	RTXAssociationPolicyStrict
+)
+
+func (r RTXAssociationPolicy) String() string {
+	switch r {
+	case RTXAssociationPolicyBestEffort:
+		return "BEST_EFFORT"
+	case RTXAssociationPolicyStrict:
+		return "STRICT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type FactoryOfBufferFactory struct {
 	trackingPacketsVideo int
 	trackingPacketsAudio int
+	trackingPacketsRTX   int
+	ssrcCollisionPolicy  SSRCCollisionPolicy
+	malformedRTPPolicy   MalformedRTPPolicy
+	clock                Clock
+	rtxAssociationPolicy RTXAssociationPolicy
+	maxPacketAge         time.Duration
 }
 
 func NewFactoryOfBufferFactory(trackingPacketsVideo int, trackingPacketsAudio int) *FactoryOfBufferFactory {
@@ -33,10 +120,43 @@ func NewFactoryOfBufferFactory(trackingPacketsVideo int, trackingPacketsAudio in
 	}
 }
 
+func (f *FactoryOfBufferFactory) SetSSRCCollisionPolicy(policy SSRCCollisionPolicy) {
+	f.ssrcCollisionPolicy = policy
+}
+
+func (f *FactoryOfBufferFactory) SetMalformedRTPPolicy(policy MalformedRTPPolicy) {
+	f.malformedRTPPolicy = policy
+}
+
+func (f *FactoryOfBufferFactory) SetRTXAssociationPolicy(policy RTXAssociationPolicy) {
+	f.rtxAssociationPolicy = policy
+}
+
+// SetTrackingPacketsRTX sets the packets buffered for RTX streams, 0 does not limit them
+func (f *FactoryOfBufferFactory) SetTrackingPacketsRTX(trackingPacketsRTX int) {
+	f.trackingPacketsRTX = trackingPacketsRTX
+}
+
+// SetMaxPacketAge sets the age after which packets are not retransmitted, 0 does not limit it
+func (f *FactoryOfBufferFactory) SetMaxPacketAge(maxAge time.Duration) {
+	f.maxPacketAge = maxAge
+}
+
+// SetClock sets the time source of buffers of the factories created afterwards
+func (f *FactoryOfBufferFactory) SetClock(clock Clock) {
+	f.clock = clock
+}
+
 func (f *FactoryOfBufferFactory) CreateBufferFactory() *Factory {
 	return &Factory{
 		trackingPacketsVideo: f.trackingPacketsVideo,
 		trackingPacketsAudio: f.trackingPacketsAudio,
+		trackingPacketsRTX:   f.trackingPacketsRTX,
+		ssrcCollisionPolicy:  f.ssrcCollisionPolicy,
+		malformedRTPPolicy:   f.malformedRTPPolicy,
+		clock:                f.clock,
+		rtxAssociationPolicy: f.rtxAssociationPolicy,
+		maxPacketAge:         f.maxPacketAge,
 		rtpBuffers:           make(map[uint32]*Buffer),
 		rtcpReaders:          make(map[uint32]*RTCPReader),
 		rtxPair:              make(map[uint32]uint32),
@@ -47,9 +167,148 @@ type Factory struct {
 	sync.RWMutex
 	trackingPacketsVideo int
 	trackingPacketsAudio int
+	trackingPacketsRTX   int
+	ssrcCollisionPolicy  SSRCCollisionPolicy
 	rtpBuffers           map[uint32]*Buffer
 	rtcpReaders          map[uint32]*RTCPReader
 	rtxPair              map[uint32]uint32 // repair -> base
+	malformedRTPPolicy   MalformedRTPPolicy
+	observer             FactoryObserver
+	clock                Clock
+	rtxAssociationPolicy RTXAssociationPolicy
+	maxPacketAge         time.Duration
+
+	// buffers and RTCP readers not used by a track for this long are closed, 0 keeps them
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+
+	// SSRCs of sent streams are allocated in [ssrcRangeStart, ssrcRangeEnd], pion's SSRC -> allocated SSRC
+	ssrcRangeStart uint32
+	ssrcRangeEnd   uint32
+	localSSRCs     map[uint32]uint32
+	usedSSRCs      map[uint32]bool
+}
+
+func (f *Factory) SetObserver(observer FactoryObserver) {
+	f.Lock()
+	defer f.Unlock()
+	f.observer = observer
+}
+
+func (f *Factory) observeLookupLocked(packetType packetio.BufferPacketType, allocated bool) {
+	if f.observer != nil {
+		f.observer.OnBufferLookup(packetType, allocated)
+	}
+}
+
+// SetClock sets the time source of buffers created afterwards, nil uses the wall clock.
+// Existing buffers keep their clock.
+func (f *Factory) SetClock(clock Clock) {
+	f.Lock()
+	defer f.Unlock()
+	f.clock = clock
+}
+
+// SetIdleTimeout closes buffers and RTCP readers that are not used by a track for the timeout, releasing their memory,
+// e.g. ones created for streams that were never published. Buffers are checked every half timeout, 0 keeps them.
+// Buffers and RTCP readers used by a track are closed by the track only.
+func (f *Factory) SetIdleTimeout(timeout time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.idleTimeout = timeout
+	if f.idleTimer != nil {
+		f.idleTimer.Stop()
+		f.idleTimer = nil
+	}
+	f.maybeStartIdleTimerLocked()
+}
+
+// SetSSRCRange allocates the SSRCs of streams sent through the factory in [start, end], see LocalSSRC. 0, 0 keeps
+// the SSRCs allocated by pion. Setting the same range again keeps the SSRCs allocated
+func (f *Factory) SetSSRCRange(start, end uint32) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.localSSRCs != nil && start == f.ssrcRangeStart && end == f.ssrcRangeEnd {
+		return
+	}
+	f.ssrcRangeStart = start
+	f.ssrcRangeEnd = end
+	f.localSSRCs = make(map[uint32]uint32)
+	f.usedSSRCs = make(map[uint32]bool)
+}
+
+// LocalSSRC returns the SSRC a stream is sent with, given the SSRC pion allocated for it. With an SSRC range, an SSRC
+// of the range not used by another stream of the factory is allocated on first use and kept for the life of the
+// factory, so that the SSRC signalled and the one packets are sent with agree. When the range is exhausted, the
+// SSRC of pion is kept
+func (f *Factory) LocalSSRC(ssrc uint32) uint32 {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.ssrcRangeEnd == 0 {
+		return ssrc
+	}
+	if local, ok := f.localSSRCs[ssrc]; ok {
+		return local
+	}
+
+	local := ssrc
+	size := uint64(f.ssrcRangeEnd) - uint64(f.ssrcRangeStart) + 1
+	start := rand.Uint64()
+	for i := uint64(0); i < size; i++ {
+		candidate := f.ssrcRangeStart + uint32((start+i)%size)
+		if _, incoming := f.rtpBuffers[candidate]; !incoming && !f.usedSSRCs[candidate] {
+			local = candidate
+			break
+		}
+	}
+	f.localSSRCs[ssrc] = local
+	f.usedSSRCs[local] = true
+	return local
+}
+
+func (f *Factory) maybeStartIdleTimerLocked() {
+	// the timer runs only while there are buffers, so that an abandoned factory does not keep it going
+	if f.idleTimeout <= 0 || f.idleTimer != nil || (len(f.rtpBuffers) == 0 && len(f.rtcpReaders) == 0) {
+		return
+	}
+
+	f.idleTimer = time.AfterFunc(f.idleTimeout/2, f.closeIdleBuffers)
+}
+
+func (f *Factory) closeIdleBuffers() {
+	f.Lock()
+	f.idleTimer = nil
+	var idle []io.Closer
+	for ssrc, buffer := range f.rtpBuffers {
+		if buffer.isReclaimable(f.idleTimeout) {
+			idle = append(idle, buffer)
+			delete(f.rtpBuffers, ssrc)
+		}
+	}
+	now := f.getClockLocked().Now().UnixNano()
+	for ssrc, reader := range f.rtcpReaders {
+		if reader.isReclaimable(now, f.idleTimeout) {
+			idle = append(idle, reader)
+			delete(f.rtcpReaders, ssrc)
+		}
+	}
+	f.maybeStartIdleTimerLocked()
+	f.Unlock()
+
+	// closing calls back into the factory
+	for _, closer := range idle {
+		_ = closer.Close()
+	}
+}
+
+func (f *Factory) getClockLocked() Clock {
+	if f.clock == nil {
+		return RealClock
+	}
+	return f.clock
 }
 
 func (f *Factory) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser {
@@ -57,25 +316,32 @@ func (f *Factory) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io
 	defer f.Unlock()
 	switch packetType {
 	case packetio.RTCPBufferPacket:
-		if reader, ok := f.rtcpReaders[ssrc]; ok {
-			return reader
-		}
-		reader := NewRTCPReader(ssrc)
-		f.rtcpReaders[ssrc] = reader
-		reader.OnClose(func() {
-			f.Lock()
-			delete(f.rtcpReaders, ssrc)
-			f.Unlock()
-		})
-		return reader
+		return f.getOrNewRTCPReaderLocked(ssrc)
 	case packetio.RTPBufferPacket:
 		if reader, ok := f.rtpBuffers[ssrc]; ok {
+			f.observeLookupLocked(packetType, false)
 			return reader
 		}
+		f.observeLookupLocked(packetType, true)
 		buffer := NewBuffer(ssrc, f.trackingPacketsVideo, f.trackingPacketsAudio)
+		if f.clock != nil {
+			buffer.SetClock(f.clock)
+		}
+		var onMalformedRTP func()
+		if f.observer != nil {
+			onMalformedRTP = f.observer.OnMalformedRTP
+		}
+		buffer.SetMalformedRTPPolicy(f.malformedRTPPolicy, onMalformedRTP)
+		if f.maxPacketAge > 0 {
+			buffer.SetMaxPacketAge(f.maxPacketAge)
+		}
 		f.rtpBuffers[ssrc] = buffer
+		f.maybeStartIdleTimerLocked()
 		for repair, base := range f.rtxPair {
 			if repair == ssrc {
+				if f.trackingPacketsRTX > 0 {
+					buffer.SetMaxRTXPkts(f.trackingPacketsRTX)
+				}
 				baseBuffer, ok := f.rtpBuffers[base]
 				if ok {
 					buffer.SetPrimaryBufferForRTX(baseBuffer)
@@ -91,15 +357,58 @@ func (f *Factory) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io
 		}
 		buffer.OnClose(func() {
 			f.Lock()
-			delete(f.rtpBuffers, ssrc)
-			delete(f.rtxPair, ssrc)
+			if f.rtpBuffers[ssrc] == buffer {
+				delete(f.rtpBuffers, ssrc)
+				delete(f.rtxPair, ssrc)
+			}
+			reader := f.rtcpReaders[ssrc]
 			f.Unlock()
+
+			// published stream is gone, its RTCP too
+			if reader != nil {
+				_ = reader.releaseDirection(StreamDirectionIncoming)
+			}
 		})
 		return buffer
 	}
 	return nil
 }
 
+func (f *Factory) getOrNewRTCPReaderLocked(ssrc uint32) *RTCPReader {
+	if reader, ok := f.rtcpReaders[ssrc]; ok {
+		f.observeLookupLocked(packetio.RTCPBufferPacket, false)
+		return reader
+	}
+	f.observeLookupLocked(packetio.RTCPBufferPacket, true)
+	reader := NewRTCPReader(ssrc)
+	reader.createdAt = f.getClockLocked().Now().UnixNano()
+	f.rtcpReaders[ssrc] = reader
+	f.maybeStartIdleTimerLocked()
+	reader.OnClose(func() {
+		f.Lock()
+		if f.rtcpReaders[ssrc] == reader {
+			delete(f.rtcpReaders, ssrc)
+		}
+		f.Unlock()
+	})
+	return reader
+}
+
+// ClaimRTCPReader returns the RTCP reader of an SSRC for the stream identified by owner,
+// applying the SSRC collision policy when another stream already claimed it.
+// Handlers are set with OnPacketForOwner and the claim dropped with Release, claims of an incoming stream
+// are also dropped when its buffer is closed and all claims when the reader is closed.
+func (f *Factory) ClaimRTCPReader(ssrc uint32, owner string, direction StreamDirection) (*RTCPReader, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	reader := f.getOrNewRTCPReaderLocked(ssrc)
+	if err := reader.claim(owner, direction, f.ssrcCollisionPolicy == SSRCCollisionPolicyReject); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
 func (f *Factory) GetBufferPair(ssrc uint32) (*Buffer, *RTCPReader) {
 	f.RLock()
 	defer f.RUnlock()
@@ -118,14 +427,45 @@ func (f *Factory) GetRTCPReader(ssrc uint32) *RTCPReader {
 	return f.rtcpReaders[ssrc]
 }
 
-func (f *Factory) SetRTXPair(repair, base uint32) {
+// SetRTXPair associates the repair stream with its primary stream. An association conflicting with a known one
+// fails with ErrRTXAssociationConflict under RTXAssociationPolicyStrict and replaces the known one otherwise.
+func (f *Factory) SetRTXPair(repair, base uint32) error {
 	f.Lock()
+	if f.rtxAssociationPolicy == RTXAssociationPolicyStrict && f.isRTXPairConflictingLocked(repair, base) {
+		f.Unlock()
+		return ErrRTXAssociationConflict
+	}
 	repairBuffer, baseBuffer := f.rtpBuffers[repair], f.rtpBuffers[base]
-	if repairBuffer == nil || baseBuffer == nil {
+	if repairBuffer == nil || baseBuffer == nil || f.rtxAssociationPolicy == RTXAssociationPolicyStrict {
+		// strict policy needs every association to detect conflicts
 		f.rtxPair[repair] = base
 	}
 	f.Unlock()
+	if repairBuffer != nil && f.trackingPacketsRTX > 0 {
+		repairBuffer.SetMaxRTXPkts(f.trackingPacketsRTX)
+	}
 	if repairBuffer != nil && baseBuffer != nil {
 		repairBuffer.SetPrimaryBufferForRTX(baseBuffer)
 	}
+	return nil
+}
+
+func (f *Factory) isRTXPairConflictingLocked(repair, base uint32) bool {
+	if repair == base {
+		return true
+	}
+	if knownBase, ok := f.rtxPair[repair]; ok {
+		return knownBase != base
+	}
+	if _, ok := f.rtxPair[base]; ok {
+		// primary stream is known as a repair stream
+		return true
+	}
+	for knownRepair, knownBase := range f.rtxPair {
+		if knownBase == repair || (knownBase == base && knownRepair != repair) {
+			// repair stream is known as a primary stream, or primary stream has another repair stream
+			return true
+		}
+	}
+	return false
 }
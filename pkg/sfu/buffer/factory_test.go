@@ -0,0 +1,578 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/v2/packetio"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type testFactoryObserver struct {
+	lock         sync.Mutex
+	lookups      map[packetio.BufferPacketType]map[bool]int
+	malformedRTP int
+}
+
+func newTestFactoryObserver() *testFactoryObserver {
+	return &testFactoryObserver{
+		lookups: make(map[packetio.BufferPacketType]map[bool]int),
+	}
+}
+
+func (o *testFactoryObserver) OnBufferLookup(packetType packetio.BufferPacketType, allocated bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.lookups[packetType] == nil {
+		o.lookups[packetType] = make(map[bool]int)
+	}
+	o.lookups[packetType][allocated]++
+}
+
+func (o *testFactoryObserver) OnMalformedRTP() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.malformedRTP++
+}
+
+func (o *testFactoryObserver) numLookups(packetType packetio.BufferPacketType, allocated bool) int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.lookups[packetType][allocated]
+}
+
+func (o *testFactoryObserver) numMalformedRTP() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.malformedRTP
+}
+
+func TestFactoryObserver(t *testing.T) {
+	observer := newTestFactoryObserver()
+	factory := NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+	factory.SetObserver(observer)
+
+	// first lookup allocates, later ones reuse
+	rtpBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 1234)
+	require.Same(t, rtpBuffer, factory.GetOrNew(packetio.RTPBufferPacket, 1234))
+	require.Same(t, rtpBuffer, factory.GetOrNew(packetio.RTPBufferPacket, 1234))
+	factory.GetOrNew(packetio.RTCPBufferPacket, 1234)
+
+	require.Equal(t, 1, observer.numLookups(packetio.RTPBufferPacket, true))
+	require.Equal(t, 2, observer.numLookups(packetio.RTPBufferPacket, false))
+	require.Equal(t, 1, observer.numLookups(packetio.RTCPBufferPacket, true))
+	require.Zero(t, observer.numLookups(packetio.RTCPBufferPacket, false))
+
+	// closed buffers are allocated again
+	require.NoError(t, rtpBuffer.Close())
+	require.NotSame(t, rtpBuffer, factory.GetOrNew(packetio.RTPBufferPacket, 1234))
+	require.Equal(t, 2, observer.numLookups(packetio.RTPBufferPacket, true))
+
+	// factories without an observer do not notify
+	NewFactoryOfBufferFactory(500, 200).CreateBufferFactory().GetOrNew(packetio.RTPBufferPacket, 5678)
+	require.Equal(t, 2, observer.numLookups(packetio.RTPBufferPacket, true))
+}
+
+func TestFactoryMalformedRTP(t *testing.T) {
+	observer := newTestFactoryObserver()
+	newFactory := func(policy MalformedRTPPolicy) *Factory {
+		ff := NewFactoryOfBufferFactory(500, 200)
+		ff.SetMalformedRTPPolicy(policy)
+		factory := ff.CreateBufferFactory()
+		factory.SetObserver(observer)
+		return factory
+	}
+
+	// shorter than the fixed RTP header
+	truncated := []byte{0x80, 0x60, 0x00}
+
+	t.Run("log", func(t *testing.T) {
+		factory := newFactory(MalformedRTPPolicyLog)
+		buffer := factory.GetOrNew(packetio.RTPBufferPacket, 1234).(*Buffer)
+
+		_, err := buffer.Write(truncated)
+		require.Error(t, err)
+		require.Equal(t, 1, observer.numMalformedRTP())
+	})
+
+	t.Run("count", func(t *testing.T) {
+		factory := newFactory(MalformedRTPPolicyCount)
+		buffer := factory.GetOrNew(packetio.RTPBufferPacket, 5678).(*Buffer)
+
+		n, err := buffer.Write(truncated)
+		require.NoError(t, err)
+		require.Equal(t, len(truncated), n)
+		_, err = buffer.Write(truncated)
+		require.NoError(t, err)
+		require.Equal(t, 3, observer.numMalformedRTP())
+	})
+}
+
+func TestFactorySSRCCollision(t *testing.T) {
+	newFactory := func(policy SSRCCollisionPolicy) *Factory {
+		ff := NewFactoryOfBufferFactory(500, 200)
+		ff.SetSSRCCollisionPolicy(policy)
+		return ff.CreateBufferFactory()
+	}
+
+	t.Run("remap", func(t *testing.T) {
+		factory := newFactory(SSRCCollisionPolicyRemap)
+
+		var published, subscribed []rtcp.Packet
+		handler := func(received *[]rtcp.Packet) func([]byte) {
+			return func(b []byte) {
+				pkts, err := rtcp.Unmarshal(b)
+				require.NoError(t, err)
+				*received = append(*received, pkts...)
+			}
+		}
+		reader, err := factory.ClaimRTCPReader(1234, "published", StreamDirectionIncoming)
+		require.NoError(t, err)
+		reader.OnPacketForOwner("published", handler(&published))
+
+		// without a collision, the stream gets every packet
+		senderReport := &rtcp.SenderReport{SSRC: 1234, NTPTime: 1, RTPTime: 2}
+		pli := &rtcp.PictureLossIndication{SenderSSRC: 1, MediaSSRC: 1234}
+		compound, err := rtcp.Marshal([]rtcp.Packet{senderReport, pli})
+		require.NoError(t, err)
+		_, err = reader.Write(compound)
+		require.NoError(t, err)
+		require.Equal(t, []rtcp.Packet{senderReport, pli}, published)
+
+		colliding, err := factory.ClaimRTCPReader(1234, "subscribed", StreamDirectionOutgoing)
+		require.NoError(t, err)
+		require.Same(t, reader, colliding)
+		colliding.OnPacketForOwner("subscribed", handler(&subscribed))
+
+		// claiming again is not a collision
+		_, err = factory.ClaimRTCPReader(1234, "subscribed", StreamDirectionOutgoing)
+		require.NoError(t, err)
+
+		// packets sent using the SSRC go to the published stream, feedback about it to the subscribed stream
+		published = nil
+		receiverReport := &rtcp.ReceiverReport{
+			SSRC:              1,
+			Reports:           []rtcp.ReceptionReport{{SSRC: 1234, FractionLost: 10}},
+			ProfileExtensions: []byte{},
+		}
+		compound, err = rtcp.Marshal([]rtcp.Packet{senderReport, receiverReport, pli})
+		require.NoError(t, err)
+		_, err = reader.Write(compound)
+		require.NoError(t, err)
+		require.Equal(t, []rtcp.Packet{senderReport}, published)
+		require.Equal(t, []rtcp.Packet{receiverReport, pli}, subscribed)
+
+		// reader stays open until all streams are done with it
+		published, subscribed = nil, nil
+		require.NoError(t, colliding.Release("subscribed"))
+		_, err = reader.Write(compound)
+		require.NoError(t, err)
+		require.Len(t, published, 3)
+		require.Empty(t, subscribed)
+		require.Same(t, reader, factory.GetRTCPReader(1234))
+
+		require.NoError(t, reader.Release("published"))
+		require.Nil(t, factory.GetRTCPReader(1234))
+	})
+
+	t.Run("released with the buffer of the published stream", func(t *testing.T) {
+		factory := newFactory(SSRCCollisionPolicyRemap)
+
+		buff := factory.GetOrNew(packetio.RTPBufferPacket, 1234).(*Buffer)
+		reader, err := factory.ClaimRTCPReader(1234, "published", StreamDirectionIncoming)
+		require.NoError(t, err)
+		_, err = factory.ClaimRTCPReader(1234, "subscribed", StreamDirectionOutgoing)
+		require.NoError(t, err)
+
+		require.NoError(t, buff.Close())
+		require.Same(t, reader, factory.GetRTCPReader(1234))
+		reader.lock.RLock()
+		require.Len(t, reader.claims, 1)
+		require.NotNil(t, reader.claims["subscribed"])
+		reader.lock.RUnlock()
+
+		// closing the reader drops the remaining claims
+		require.NoError(t, reader.Close())
+		require.Nil(t, factory.GetRTCPReader(1234))
+		reader.lock.RLock()
+		require.Empty(t, reader.claims)
+		reader.lock.RUnlock()
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		factory := newFactory(SSRCCollisionPolicyReject)
+
+		reader, err := factory.ClaimRTCPReader(1234, "published", StreamDirectionIncoming)
+		require.NoError(t, err)
+
+		_, err = factory.ClaimRTCPReader(1234, "subscribed", StreamDirectionOutgoing)
+		require.ErrorIs(t, err, ErrSSRCCollision)
+
+		// same stream and other SSRCs are not affected
+		claimed, err := factory.ClaimRTCPReader(1234, "published", StreamDirectionIncoming)
+		require.NoError(t, err)
+		require.Same(t, reader, claimed)
+		_, err = factory.ClaimRTCPReader(5678, "subscribed", StreamDirectionOutgoing)
+		require.NoError(t, err)
+
+		// SSRC can be claimed once released
+		require.NoError(t, reader.Release("published"))
+		_, err = factory.ClaimRTCPReader(1234, "subscribed", StreamDirectionOutgoing)
+		require.NoError(t, err)
+	})
+}
+
+func TestFactoryRTXBufferSize(t *testing.T) {
+	ff := NewFactoryOfBufferFactory(500, 200)
+	ff.SetTrackingPacketsRTX(2)
+	factory := ff.CreateBufferFactory()
+
+	write := func(b *Buffer, ssrc uint32, sn uint16) {
+		pkt, err := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 97, SequenceNumber: sn, SSRC: ssrc},
+			Payload: []byte{0x00, 0x01, 0x02},
+		}).Marshal()
+		require.NoError(t, err)
+		_, err = b.Write(pkt)
+		require.NoError(t, err)
+	}
+	pendingSNs := func(b *Buffer) []uint16 {
+		b.RLock()
+		defer b.RUnlock()
+		var sns []uint16
+		for _, pp := range b.pPackets {
+			var pkt rtp.Packet
+			require.NoError(t, pkt.Unmarshal(pp.packet))
+			sns = append(sns, pkt.SequenceNumber)
+		}
+		return sns
+	}
+
+	// repair stream known before its buffer is created, primary stream not received yet
+	factory.SetRTXPair(2000, 1000)
+	rtxBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 2000).(*Buffer)
+	for sn := uint16(1); sn <= 5; sn++ {
+		write(rtxBuffer, 2000, sn)
+	}
+	require.Equal(t, []uint16{4, 5}, pendingSNs(rtxBuffer))
+
+	// buffers of primary streams are sized independently
+	primaryBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 3000).(*Buffer)
+	for sn := uint16(1); sn <= 5; sn++ {
+		write(primaryBuffer, 3000, sn)
+	}
+	require.Equal(t, []uint16{1, 2, 3, 4, 5}, pendingSNs(primaryBuffer))
+
+	// repair stream found after its buffer is created
+	lateRTXBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 4000).(*Buffer)
+	for sn := uint16(1); sn <= 3; sn++ {
+		write(lateRTXBuffer, 4000, sn)
+	}
+	factory.SetRTXPair(4000, 5000)
+	require.Equal(t, []uint16{2, 3}, pendingSNs(lateRTXBuffer))
+
+	// bucket of a repair stream bound before it is paired is sized for RTX, it does not grow past the RTX size
+	bucketCapacity := func(b *Buffer) int {
+		b.RLock()
+		defer b.RUnlock()
+		return b.bucket.Capacity()
+	}
+	rtxCodec := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "video/rtx", ClockRate: 90000},
+		PayloadType:        97,
+	}
+	boundRTXBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 6000).(*Buffer)
+	boundRTXBuffer.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{rtxCodec}}, rtxCodec.RTPCodecCapability, 10_000_000)
+	require.Greater(t, bucketCapacity(boundRTXBuffer), 2)
+	factory.SetRTXPair(6000, 7000)
+	require.Equal(t, 2, bucketCapacity(boundRTXBuffer))
+	boundRTXBuffer.Lock()
+	boundRTXBuffer.mayGrowBucket()
+	boundRTXBuffer.Unlock()
+	require.Equal(t, 2, bucketCapacity(boundRTXBuffer))
+
+	// repair stream paired before it is bound
+	pairedRTXBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 8000).(*Buffer)
+	factory.SetRTXPair(8000, 9000)
+	pairedRTXBuffer.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{rtxCodec}}, rtxCodec.RTPCodecCapability, 10_000_000)
+	require.Equal(t, 2, bucketCapacity(pairedRTXBuffer))
+}
+
+func TestFactoryRTXAssociation(t *testing.T) {
+	primaryOf := func(b *Buffer) *Buffer {
+		b.RLock()
+		defer b.RUnlock()
+		return b.primaryBufferForRTX
+	}
+
+	for _, policy := range []RTXAssociationPolicy{RTXAssociationPolicyBestEffort, RTXAssociationPolicyStrict} {
+		t.Run(policy.String(), func(t *testing.T) {
+			ff := NewFactoryOfBufferFactory(500, 200)
+			ff.SetRTXAssociationPolicy(policy)
+			factory := ff.CreateBufferFactory()
+
+			firstBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 1000).(*Buffer)
+			secondBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 3000).(*Buffer)
+			rtxBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 2000).(*Buffer)
+
+			require.NoError(t, factory.SetRTXPair(2000, 1000))
+			require.Equal(t, firstBuffer, primaryOf(rtxBuffer))
+			// same association again is not a conflict
+			require.NoError(t, factory.SetRTXPair(2000, 1000))
+
+			// duplicate RTX SSRC, claimed as the repair stream of another primary stream
+			err := factory.SetRTXPair(2000, 3000)
+			switch policy {
+			case RTXAssociationPolicyStrict:
+				require.ErrorIs(t, err, ErrRTXAssociationConflict)
+				require.Equal(t, firstBuffer, primaryOf(rtxBuffer))
+
+				// repair stream as a primary stream and primary stream as a repair stream
+				require.ErrorIs(t, factory.SetRTXPair(4000, 2000), ErrRTXAssociationConflict)
+				require.ErrorIs(t, factory.SetRTXPair(1000, 5000), ErrRTXAssociationConflict)
+				require.ErrorIs(t, factory.SetRTXPair(6000, 6000), ErrRTXAssociationConflict)
+
+			case RTXAssociationPolicyBestEffort:
+				require.NoError(t, err)
+				require.Equal(t, secondBuffer, primaryOf(rtxBuffer))
+			}
+		})
+	}
+}
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestFactoryClock(t *testing.T) {
+	receiverReports := func(clock *fakeClock, spacing func(i int) time.Duration) []rtcp.ReceptionReport {
+		ff := NewFactoryOfBufferFactory(500, 200)
+		ff.SetClock(clock)
+		factory := ff.CreateBufferFactory()
+
+		buff := factory.GetOrNew(packetio.RTPBufferPacket, 123).(*Buffer)
+		var reports []rtcp.ReceptionReport
+		buff.OnRtcpFeedback(func(fb []rtcp.Packet) {
+			for _, pkt := range fb {
+				if rr, ok := pkt.(*rtcp.ReceiverReport); ok {
+					reports = append(reports, rr.Reports...)
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{
+			Codecs: []webrtc.RTPCodecParameters{opusCodec},
+		}, opusCodec.RTPCodecCapability, 0)
+
+		// 2 seconds worth of 20ms packets
+		for i := 1; i <= 100; i++ {
+			clock.Advance(spacing(i))
+			pkt, err := (&rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    uint8(opusCodec.PayloadType),
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 960),
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}).Marshal()
+			require.NoError(t, err)
+			_, err = buff.Write(pkt)
+			require.NoError(t, err)
+		}
+		return reports
+	}
+
+	// evenly paced packets, reports at exactly every ReportDelta without jitter
+	reports := receiverReports(&fakeClock{now: time.Unix(1000, 0)}, func(int) time.Duration { return 20 * time.Millisecond })
+	require.Len(t, reports, 2)
+	for _, report := range reports {
+		require.Zero(t, report.Jitter)
+		require.Zero(t, report.TotalLost)
+	}
+
+	// packets alternately arriving 5ms early and late, same reports every run
+	alternating := func(i int) time.Duration {
+		if i%2 == 0 {
+			return 30 * time.Millisecond
+		}
+		return 10 * time.Millisecond
+	}
+	reports = receiverReports(&fakeClock{now: time.Unix(1000, 0)}, alternating)
+	require.Len(t, reports, 2)
+	require.NotZero(t, reports[1].Jitter)
+	require.Equal(t, reports, receiverReports(&fakeClock{now: time.Unix(2000, 0)}, alternating))
+
+	// buffers keep the clock they were created with
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	factory := NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+	wallClockBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 1).(*Buffer)
+	factory.SetClock(clock)
+	fakeClockBuffer := factory.GetOrNew(packetio.RTPBufferPacket, 2).(*Buffer)
+	require.Equal(t, RealClock, wallClockBuffer.clock)
+	require.Same(t, clock, fakeClockBuffer.clock)
+
+	// RTP statistics of the buffer use its clock, e.g. for the start time and throttling PLIs
+	fakeClockBuffer.Bind(webrtc.RTPParameters{
+		Codecs: []webrtc.RTPCodecParameters{opusCodec},
+	}, opusCodec.RTPCodecCapability, 0)
+	pkt, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: 1, Timestamp: 960, SSRC: 2},
+		Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+	}).Marshal()
+	require.NoError(t, err)
+	startedAt := clock.Now()
+	_, err = fakeClockBuffer.Write(pkt)
+	require.NoError(t, err)
+	clock.Advance(time.Second)
+	stats := fakeClockBuffer.rtpStats.ToProto()
+	require.True(t, stats.StartTime.AsTime().Equal(startedAt))
+	require.Equal(t, float64(1), stats.Duration)
+
+	throttle := time.Second.Nanoseconds()
+	require.True(t, fakeClockBuffer.rtpStats.CheckAndUpdatePli(throttle, false))
+	require.False(t, fakeClockBuffer.rtpStats.CheckAndUpdatePli(throttle, false))
+	clock.Advance(time.Second)
+	require.True(t, fakeClockBuffer.rtpStats.CheckAndUpdatePli(throttle, false))
+}
+
+func TestFactoryIdleTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	factory := NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+	factory.SetClock(clock)
+	factory.SetIdleTimeout(20 * time.Millisecond)
+
+	active := factory.GetOrNew(packetio.RTPBufferPacket, 1234).(*Buffer)
+	idle := factory.GetOrNew(packetio.RTPBufferPacket, 5678).(*Buffer)
+
+	// buffers of a track, e.g. of a muted layer, and its repair stream
+	bound := factory.GetOrNew(packetio.RTPBufferPacket, 1000).(*Buffer)
+	bound.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{opusCodec}}, opusCodec.RTPCodecCapability, 0)
+	rtx := factory.GetOrNew(packetio.RTPBufferPacket, 2000).(*Buffer)
+	require.NoError(t, factory.SetRTXPair(2000, 1000))
+
+	// RTCP readers of streams used by a track and one never used
+	claimed, err := factory.ClaimRTCPReader(1000, "published", StreamDirectionIncoming)
+	require.NoError(t, err)
+	handled, err := factory.ClaimRTCPReader(2000, "subscribed", StreamDirectionOutgoing)
+	require.NoError(t, err)
+	unused := factory.GetOrNew(packetio.RTCPBufferPacket, 5678).(*RTCPReader)
+
+	pkt, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: 1, Timestamp: 960, SSRC: 1234},
+		Payload: []byte{0x00, 0x01, 0x02},
+	}).Marshal()
+	require.NoError(t, err)
+
+	clock.Advance(15 * time.Millisecond)
+	_, err = active.Write(pkt)
+	require.NoError(t, err)
+
+	// nothing idle for the timeout yet
+	time.Sleep(50 * time.Millisecond)
+	require.Same(t, active, factory.GetBuffer(1234))
+	require.Same(t, idle, factory.GetBuffer(5678))
+	require.Same(t, unused, factory.GetRTCPReader(5678))
+
+	// buffer and reader without packets are reclaimed, the buffer that received a packet since is kept
+	clock.Advance(10 * time.Millisecond)
+	require.Eventually(t, func() bool {
+		return factory.GetBuffer(5678) == nil && factory.GetRTCPReader(5678) == nil
+	}, time.Second, 5*time.Millisecond)
+	require.Same(t, active, factory.GetBuffer(1234))
+	_, err = idle.Write(pkt)
+	require.ErrorIs(t, err, io.EOF)
+	_, err = unused.Write([]byte{0x01})
+	require.ErrorIs(t, err, io.EOF)
+
+	clock.Advance(20 * time.Millisecond)
+	require.Eventually(t, func() bool { return factory.GetBuffer(1234) == nil }, time.Second, 5*time.Millisecond)
+
+	// buffers and readers of the track are kept however long they do not receive packets
+	clock.Advance(time.Minute)
+	time.Sleep(50 * time.Millisecond)
+	require.Same(t, bound, factory.GetBuffer(1000))
+	require.Same(t, rtx, factory.GetBuffer(2000))
+	require.Same(t, claimed, factory.GetRTCPReader(1000))
+	require.Same(t, handled, factory.GetRTCPReader(2000))
+
+	// buffers created after others were reclaimed are checked again
+	recreated := factory.GetOrNew(packetio.RTPBufferPacket, 1234).(*Buffer)
+	require.NotSame(t, active, recreated)
+	clock.Advance(20 * time.Millisecond)
+	require.Eventually(t, func() bool { return factory.GetBuffer(1234) == nil }, time.Second, 5*time.Millisecond)
+
+	// without a timeout buffers are kept
+	factory.SetIdleTimeout(0)
+	kept := factory.GetOrNew(packetio.RTPBufferPacket, 1234).(*Buffer)
+	clock.Advance(time.Minute)
+	time.Sleep(50 * time.Millisecond)
+	require.Same(t, kept, factory.GetBuffer(1234))
+}
+
+func TestFactorySSRCRange(t *testing.T) {
+	t.Run("not set", func(t *testing.T) {
+		f := NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+		require.Equal(t, uint32(1234), f.LocalSSRC(1234))
+	})
+
+	t.Run("allocated in range", func(t *testing.T) {
+		f := NewFactoryOfBufferFactory(500, 200).CreateBufferFactory()
+		f.SetSSRCRange(1000, 1009)
+		// an incoming stream uses an SSRC of the range
+		f.GetOrNew(packetio.RTPBufferPacket, 1005)
+
+		allocated := make(map[uint32]bool)
+		for ssrc := uint32(1); ssrc <= 9; ssrc++ {
+			local := f.LocalSSRC(ssrc)
+			require.GreaterOrEqual(t, local, uint32(1000))
+			require.LessOrEqual(t, local, uint32(1009))
+			require.NotEqual(t, uint32(1005), local)
+			require.False(t, allocated[local], "SSRC %d allocated twice", local)
+			allocated[local] = true
+
+			// kept for the stream
+			require.Equal(t, local, f.LocalSSRC(ssrc))
+		}
+
+		// range exhausted
+		require.Equal(t, uint32(1234), f.LocalSSRC(1234))
+
+		// set again for another participant of the room
+		local := f.LocalSSRC(1)
+		f.SetSSRCRange(1000, 1009)
+		require.Equal(t, local, f.LocalSSRC(1))
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultKeyFrameRequestTimeout = 2 * time.Second
+)
+
+type KeyFrameRequestLimiterParams struct {
+	// maximum number of key frame requests outstanding at any time
+	MaxOutstanding int
+	// a request is considered complete if no key frame arrives within this duration
+	Timeout time.Duration
+}
+
+type outstandingKeyFrameRequest struct {
+	timer *time.Timer
+}
+
+type queuedKeyFrameRequest struct {
+	buffer *Buffer
+	send   func()
+}
+
+// KeyFrameRequestLimiter limits the number of key frame requests outstanding across buffers.
+// A request is outstanding from the time it is sent till a key frame is received on that buffer,
+// the buffer is closed or the request times out. Requests over the limit are queued and sent
+// in order as outstanding requests complete.
+type KeyFrameRequestLimiter struct {
+	params KeyFrameRequestLimiterParams
+
+	lock        sync.Mutex
+	outstanding map[*Buffer]*outstandingKeyFrameRequest
+	queued      []queuedKeyFrameRequest
+}
+
+func NewKeyFrameRequestLimiter(params KeyFrameRequestLimiterParams) *KeyFrameRequestLimiter {
+	if params.Timeout <= 0 {
+		params.Timeout = DefaultKeyFrameRequestTimeout
+	}
+	return &KeyFrameRequestLimiter{
+		params:      params,
+		outstanding: make(map[*Buffer]*outstandingKeyFrameRequest),
+	}
+}
+
+func (k *KeyFrameRequestLimiter) Request(b *Buffer, send func()) {
+	k.lock.Lock()
+	if _, ok := k.outstanding[b]; ok {
+		// already holding a slot, no need for another one
+		k.lock.Unlock()
+		send()
+		return
+	}
+
+	if len(k.outstanding) >= k.params.MaxOutstanding {
+		for _, q := range k.queued {
+			if q.buffer == b {
+				// already waiting, will be sent when a slot frees up
+				k.lock.Unlock()
+				return
+			}
+		}
+		k.queued = append(k.queued, queuedKeyFrameRequest{buffer: b, send: send})
+		k.lock.Unlock()
+		return
+	}
+
+	k.acquireLocked(b)
+	k.lock.Unlock()
+
+	send()
+}
+
+// Release completes the request of the buffer, outstanding or queued, if any
+func (k *KeyFrameRequestLimiter) Release(b *Buffer) {
+	k.release(b, nil)
+}
+
+func (k *KeyFrameRequestLimiter) release(b *Buffer, expired *outstandingKeyFrameRequest) {
+	k.lock.Lock()
+	if r, ok := k.outstanding[b]; ok {
+		if expired != nil && expired != r {
+			// timer of an earlier request which has already completed
+			k.lock.Unlock()
+			return
+		}
+		r.timer.Stop()
+		delete(k.outstanding, b)
+	} else if expired != nil {
+		k.lock.Unlock()
+		return
+	} else {
+		for i, q := range k.queued {
+			if q.buffer == b {
+				k.queued = append(k.queued[:i], k.queued[i+1:]...)
+				break
+			}
+		}
+	}
+
+	var toSend []func()
+	for len(k.outstanding) < k.params.MaxOutstanding && len(k.queued) != 0 {
+		q := k.queued[0]
+		k.queued = k.queued[1:]
+
+		k.acquireLocked(q.buffer)
+		toSend = append(toSend, q.send)
+	}
+	k.lock.Unlock()
+
+	// send asynchronously as caller could be holding the lock of the releasing buffer
+	for _, send := range toSend {
+		go send()
+	}
+}
+
+func (k *KeyFrameRequestLimiter) NumOutstanding() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return len(k.outstanding)
+}
+
+func (k *KeyFrameRequestLimiter) NumQueued() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return len(k.queued)
+}
+
+func (k *KeyFrameRequestLimiter) acquireLocked(b *Buffer) {
+	r := &outstandingKeyFrameRequest{}
+	r.timer = time.AfterFunc(k.params.Timeout, func() {
+		k.release(b, r)
+	})
+	k.outstanding[b] = r
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type keyFrameRequestRecorder struct {
+	lock sync.Mutex
+	sent []int
+}
+
+func (r *keyFrameRequestRecorder) sender(id int) func() {
+	return func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.sent = append(r.sent, id)
+	}
+}
+
+func (r *keyFrameRequestRecorder) getSent() []int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]int(nil), r.sent...)
+}
+
+func TestKeyFrameRequestLimiter(t *testing.T) {
+	t.Run("requests beyond limit are queued", func(t *testing.T) {
+		limiter := NewKeyFrameRequestLimiter(KeyFrameRequestLimiterParams{MaxOutstanding: 2, Timeout: time.Minute})
+		recorder := &keyFrameRequestRecorder{}
+		buffs := []*Buffer{NewBuffer(1, 1, 1), NewBuffer(2, 1, 1), NewBuffer(3, 1, 1), NewBuffer(4, 1, 1)}
+
+		for i, b := range buffs {
+			limiter.Request(b, recorder.sender(i))
+		}
+		require.Equal(t, []int{0, 1}, recorder.getSent())
+		require.Equal(t, 2, limiter.NumOutstanding())
+		require.Equal(t, 2, limiter.NumQueued())
+
+		// repeated request while queued does not queue again
+		limiter.Request(buffs[2], recorder.sender(2))
+		require.Equal(t, 2, limiter.NumQueued())
+
+		// repeated request while outstanding is sent without taking another slot
+		limiter.Request(buffs[0], recorder.sender(0))
+		require.Equal(t, []int{0, 1, 0}, recorder.getSent())
+		require.Equal(t, 2, limiter.NumOutstanding())
+
+		// completing requests releases queued ones in order
+		limiter.Release(buffs[0])
+		require.Eventually(t, func() bool {
+			return len(recorder.getSent()) == 4
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, []int{0, 1, 0, 2}, recorder.getSent())
+		require.Equal(t, 2, limiter.NumOutstanding())
+		require.Equal(t, 1, limiter.NumQueued())
+
+		limiter.Release(buffs[1])
+		require.Eventually(t, func() bool {
+			return len(recorder.getSent()) == 5
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, []int{0, 1, 0, 2, 3}, recorder.getSent())
+		require.Equal(t, 0, limiter.NumQueued())
+
+		limiter.Release(buffs[2])
+		limiter.Release(buffs[3])
+		require.Equal(t, 0, limiter.NumOutstanding())
+	})
+
+	t.Run("releasing queued request", func(t *testing.T) {
+		limiter := NewKeyFrameRequestLimiter(KeyFrameRequestLimiterParams{MaxOutstanding: 1, Timeout: time.Minute})
+		recorder := &keyFrameRequestRecorder{}
+		b1, b2 := NewBuffer(1, 1, 1), NewBuffer(2, 1, 1)
+
+		limiter.Request(b1, recorder.sender(1))
+		limiter.Request(b2, recorder.sender(2))
+		require.Equal(t, 1, limiter.NumQueued())
+
+		limiter.Release(b2)
+		require.Equal(t, 0, limiter.NumQueued())
+		require.Equal(t, 1, limiter.NumOutstanding())
+		require.Equal(t, []int{1}, recorder.getSent())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		limiter := NewKeyFrameRequestLimiter(KeyFrameRequestLimiterParams{MaxOutstanding: 1, Timeout: 50 * time.Millisecond})
+		recorder := &keyFrameRequestRecorder{}
+		b1, b2 := NewBuffer(1, 1, 1), NewBuffer(2, 1, 1)
+
+		limiter.Request(b1, recorder.sender(1))
+		limiter.Request(b2, recorder.sender(2))
+		require.Equal(t, []int{1}, recorder.getSent())
+
+		// no key frame on b1, slot should be given to b2 after timeout
+		require.Eventually(t, func() bool {
+			return len(recorder.getSent()) == 2
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, []int{1, 2}, recorder.getSent())
+	})
+}
+
+func TestBufferKeyFrameRequestLimiter(t *testing.T) {
+	limiter := NewKeyFrameRequestLimiter(KeyFrameRequestLimiterParams{MaxOutstanding: 1, Timeout: time.Minute})
+
+	var lock sync.Mutex
+	numRequests := make(map[uint32]int)
+	newBuffer := func(ssrc uint32) *Buffer {
+		buff := NewBuffer(ssrc, 1, 1)
+		buff.SetKeyFrameRequestLimiter(limiter)
+		buff.OnRtcpFeedback(func(fb []rtcp.Packet) {
+			lock.Lock()
+			defer lock.Unlock()
+			for _, pkt := range fb {
+				if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
+					numRequests[ssrc]++
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{vp8Codec},
+		}, vp8Codec.RTPCodecCapability, 0)
+		return buff
+	}
+	getNumRequests := func(ssrc uint32) int {
+		lock.Lock()
+		defer lock.Unlock()
+		return numRequests[ssrc]
+	}
+
+	b1 := newBuffer(1)
+	b2 := newBuffer(2)
+
+	b1.SendPLI(true)
+	b2.SendPLI(true)
+	require.Equal(t, 1, getNumRequests(1))
+	require.Equal(t, 0, getNumRequests(2))
+
+	// closing the buffer with outstanding request lets the queued one through
+	require.NoError(t, b1.Close())
+	require.Eventually(t, func() bool {
+		return getNumRequests(2) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, limiter.NumOutstanding())
+}
@@ -15,16 +15,104 @@
 package buffer
 
 import (
+	"errors"
 	"io"
+	"sync"
+	"time"
 
+	"github.com/pion/rtcp"
 	"go.uber.org/atomic"
 )
 
+type UnknownRTCPPolicy int
+
+const (
+	// UnknownRTCPPolicyLog fails the whole compound packet when any part of it cannot be parsed
+	UnknownRTCPPolicyLog UnknownRTCPPolicy = iota
+	// UnknownRTCPPolicyIgnore drops packets that cannot be parsed and keeps the rest
+	UnknownRTCPPolicyIgnore
+	// UnknownRTCPPolicyCount is UnknownRTCPPolicyIgnore, callers additionally count dropped packets
+	UnknownRTCPPolicyCount
+)
+
+func (u UnknownRTCPPolicy) String() string {
+	switch u {
+	case UnknownRTCPPolicyLog:
+		return "LOG"
+	case UnknownRTCPPolicyIgnore:
+		return "IGNORE"
+	case UnknownRTCPPolicyCount:
+		return "COUNT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// UnmarshalRTCP parses a compound RTCP packet. With UnknownRTCPPolicyLog, this is rtcp.Unmarshal.
+// Otherwise, packets of unknown type and packets that fail to parse are dropped, the number dropped
+// is returned and no error is reported.
+func UnmarshalRTCP(bytes []byte, policy UnknownRTCPPolicy) ([]rtcp.Packet, int, error) {
+	if policy == UnknownRTCPPolicyLog {
+		pkts, err := rtcp.Unmarshal(bytes)
+		return pkts, 0, err
+	}
+
+	var pkts []rtcp.Packet
+	numUnknown := 0
+	for len(bytes) != 0 {
+		var header rtcp.Header
+		if err := header.Unmarshal(bytes); err != nil {
+			// cannot find the next packet boundary, drop the remainder
+			return pkts, numUnknown + 1, nil
+		}
+
+		size := (int(header.Length) + 1) * 4
+		if size > len(bytes) {
+			return pkts, numUnknown + 1, nil
+		}
+
+		parsed, err := rtcp.Unmarshal(bytes[:size])
+		bytes = bytes[size:]
+		if err != nil {
+			numUnknown++
+			continue
+		}
+		for _, pkt := range parsed {
+			if _, ok := pkt.(*rtcp.RawPacket); ok {
+				numUnknown++
+				continue
+			}
+			pkts = append(pkts, pkt)
+		}
+	}
+	return pkts, numUnknown, nil
+}
+
+var ErrSSRCCollision = errors.New("ssrc already claimed by another stream")
+
+// StreamDirection is the direction of a stream claiming an RTCP reader, as seen from the SFU
+type StreamDirection int
+
+const (
+	// StreamDirectionIncoming is a published stream, its RTCP is sent by the publisher using the SSRC, e.g. sender reports
+	StreamDirectionIncoming StreamDirection = iota
+	// StreamDirectionOutgoing is a subscribed stream, its RTCP is feedback about the SSRC, e.g. receiver reports and NACKs
+	StreamDirectionOutgoing
+)
+
+type rtcpClaim struct {
+	direction StreamDirection
+	onPacket  func([]byte)
+}
+
 type RTCPReader struct {
-	ssrc     uint32
-	closed   atomic.Bool
-	onPacket atomic.Value // func([]byte)
-	onClose  func()
+	ssrc      uint32
+	closed    atomic.Bool
+	onClose   func()
+	createdAt int64
+
+	lock   sync.RWMutex
+	claims map[string]*rtcpClaim // owner -> claim, of streams using the reader
 }
 
 func NewRTCPReader(ssrc uint32) *RTCPReader {
@@ -36,27 +124,201 @@ func (r *RTCPReader) Write(p []byte) (n int, err error) {
 		err = io.EOF
 		return
 	}
-	if f, ok := r.onPacket.Load().(func([]byte)); ok && f != nil {
-		f(p)
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if !r.isCollidingLocked() {
+		for _, c := range r.claims {
+			if c.onPacket != nil {
+				c.onPacket(p)
+			}
+		}
+		return
+	}
+
+	// streams of both directions share the SSRC, remap each packet to the stream it belongs to
+	incoming, outgoing, ok := r.splitByDirection(p)
+	for _, c := range r.claims {
+		if c.onPacket == nil {
+			continue
+		}
+		pkt := p
+		if ok {
+			pkt = outgoing
+			if c.direction == StreamDirectionIncoming {
+				pkt = incoming
+			}
+		}
+		if len(pkt) != 0 {
+			c.onPacket(pkt)
+		}
 	}
 	return
 }
 
+func (r *RTCPReader) isCollidingLocked() bool {
+	var directions [2]bool
+	for _, c := range r.claims {
+		directions[c.direction] = true
+	}
+	return directions[StreamDirectionIncoming] && directions[StreamDirectionOutgoing]
+}
+
+// splitByDirection splits a compound packet into packets sent by the SSRC, i.e. by the publisher, and the others,
+// i.e. feedback of the subscriber about the SSRC. Packets that cannot be parsed go to both, ok is false when the
+// compound packet cannot be parsed at all
+func (r *RTCPReader) splitByDirection(p []byte) (incoming []byte, outgoing []byte, ok bool) {
+	pkts, err := rtcp.Unmarshal(p)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	var incomingPkts, outgoingPkts []rtcp.Packet
+	for _, pkt := range pkts {
+		switch {
+		case isRawRTCP(pkt):
+			incomingPkts = append(incomingPkts, pkt)
+			outgoingPkts = append(outgoingPkts, pkt)
+		case isSentBySSRC(pkt, r.ssrc):
+			incomingPkts = append(incomingPkts, pkt)
+		default:
+			outgoingPkts = append(outgoingPkts, pkt)
+		}
+	}
+
+	if len(incomingPkts) != 0 {
+		if incoming, err = rtcp.Marshal(incomingPkts); err != nil {
+			return nil, nil, false
+		}
+	}
+	if len(outgoingPkts) != 0 {
+		if outgoing, err = rtcp.Marshal(outgoingPkts); err != nil {
+			return nil, nil, false
+		}
+	}
+	return incoming, outgoing, true
+}
+
+func isRawRTCP(pkt rtcp.Packet) bool {
+	_, ok := pkt.(*rtcp.RawPacket)
+	return ok
+}
+
+func isSentBySSRC(pkt rtcp.Packet, ssrc uint32) bool {
+	switch pkt := pkt.(type) {
+	case *rtcp.SenderReport:
+		return pkt.SSRC == ssrc
+	case *rtcp.ExtendedReport:
+		return pkt.SenderSSRC == ssrc
+	case *rtcp.SourceDescription:
+		for _, chunk := range pkt.Chunks {
+			if chunk.Source == ssrc {
+				return true
+			}
+		}
+	case *rtcp.Goodbye:
+		for _, source := range pkt.Sources {
+			if source == ssrc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (r *RTCPReader) OnClose(fn func()) {
 	r.onClose = fn
 }
 
+// Close closes the reader, releasing the claims of all streams
 func (r *RTCPReader) Close() error {
 	if r.closed.Swap(true) {
 		return nil
 	}
 
+	r.lock.Lock()
+	r.claims = nil
+	r.lock.Unlock()
+
 	r.onClose()
 	return nil
 }
 
-func (r *RTCPReader) OnPacket(f func([]byte)) {
-	r.onPacket.Store(f)
+// isReclaimable returns true if no stream has used the reader for the given duration since it was created
+func (r *RTCPReader) isReclaimable(now int64, timeout time.Duration) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return len(r.claims) == 0 && now-r.createdAt >= timeout.Nanoseconds()
+}
+
+// claim registers owner as a stream of the given direction using the reader, with exclusive set, fails if another
+// stream already did
+func (r *RTCPReader) claim(owner string, direction StreamDirection, exclusive bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.claims[owner]; ok {
+		c.direction = direction
+		return nil
+	}
+	if exclusive && len(r.claims) != 0 {
+		return ErrSSRCCollision
+	}
+
+	if r.claims == nil {
+		r.claims = make(map[string]*rtcpClaim)
+	}
+	r.claims[owner] = &rtcpClaim{direction: direction}
+	return nil
+}
+
+// OnPacketForOwner sets the handler of a stream that claimed the reader. When streams of both directions claimed it,
+// each receives the packets of its direction only
+func (r *RTCPReader) OnPacketForOwner(owner string, f func([]byte)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.claims[owner]; ok {
+		c.onPacket = f
+	}
+}
+
+// Release drops the claim of owner, the reader is closed once no stream uses it
+func (r *RTCPReader) Release(owner string) error {
+	r.lock.Lock()
+	if _, ok := r.claims[owner]; !ok {
+		r.lock.Unlock()
+		return nil
+	}
+	delete(r.claims, owner)
+	remaining := len(r.claims)
+	r.lock.Unlock()
+
+	if remaining != 0 {
+		return nil
+	}
+	return r.Close()
+}
+
+// releaseDirection drops the claims of streams of the given direction, closing the reader when they were the last ones
+func (r *RTCPReader) releaseDirection(direction StreamDirection) error {
+	r.lock.Lock()
+	released := false
+	for owner, c := range r.claims {
+		if c.direction == direction {
+			delete(r.claims, owner)
+			released = true
+		}
+	}
+	remaining := len(r.claims)
+	r.lock.Unlock()
+
+	if !released || remaining != 0 {
+		return nil
+	}
+	return r.Close()
 }
 
 func (r *RTCPReader) Read(_ []byte) (n int, err error) { return }
@@ -0,0 +1,65 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalRTCP(t *testing.T) {
+	known, err := rtcp.Marshal([]rtcp.Packet{
+		&rtcp.ReceiverReport{SSRC: 1234},
+		&rtcp.PictureLossIndication{SenderSSRC: 1234, MediaSSRC: 5678},
+	})
+	require.NoError(t, err)
+
+	// proprietary packet type, header only with one word of payload
+	unknownType := []byte{0x80, 210, 0x00, 0x01, 0xde, 0xad, 0xbe, 0xef}
+	// receiver report claiming a reception report block that is not present
+	malformed := []byte{0x81, 201, 0x00, 0x01, 0x00, 0x00, 0x04, 0xd2}
+
+	compound := append(append(append([]byte{}, known[:8]...), unknownType...), malformed...)
+	compound = append(compound, known[8:]...)
+
+	t.Run("log", func(t *testing.T) {
+		_, numUnknown, err := UnmarshalRTCP(compound, UnknownRTCPPolicyLog)
+		require.Error(t, err)
+		require.Zero(t, numUnknown)
+
+		pkts, _, err := UnmarshalRTCP(known, UnknownRTCPPolicyLog)
+		require.NoError(t, err)
+		require.Len(t, pkts, 2)
+	})
+
+	for _, policy := range []UnknownRTCPPolicy{UnknownRTCPPolicyIgnore, UnknownRTCPPolicyCount} {
+		t.Run(policy.String(), func(t *testing.T) {
+			pkts, numUnknown, err := UnmarshalRTCP(compound, policy)
+			require.NoError(t, err)
+			require.Equal(t, 2, numUnknown)
+			require.Len(t, pkts, 2)
+			require.IsType(t, &rtcp.ReceiverReport{}, pkts[0])
+			require.IsType(t, &rtcp.PictureLossIndication{}, pkts[1])
+
+			// truncated trailing packet is dropped
+			pkts, numUnknown, err = UnmarshalRTCP(append(append([]byte{}, known...), 0x80, 210), policy)
+			require.NoError(t, err)
+			require.Equal(t, 1, numUnknown)
+			require.Len(t, pkts, 2)
+		})
+	}
+}
@@ -110,6 +110,12 @@ type snapshot struct {
 
 	maxRtt    uint32
 	maxJitter float64
+
+	// distribution of jitter over the snapshot, for extended reports
+	minJitter        float64
+	jitterSum        float64
+	jitterSquaredSum float64
+	jitterSamples    uint32
 }
 
 // ------------------------------------------------------------------
@@ -162,11 +168,14 @@ func (r *RTCPSenderReportData) MarshalLogObject(e zapcore.ObjectEncoder) error {
 type RTPStatsParams struct {
 	ClockRate uint32
 	Logger    logger.Logger
+	// time source, nil uses the wall clock
+	Clock Clock
 }
 
 type rtpStatsBase struct {
 	params RTPStatsParams
 	logger logger.Logger
+	clock  Clock
 
 	lock sync.RWMutex
 
@@ -230,9 +239,14 @@ type rtpStatsBase struct {
 }
 
 func newRTPStatsBase(params RTPStatsParams) *rtpStatsBase {
+	clock := params.Clock
+	if clock == nil {
+		clock = RealClock
+	}
 	return &rtpStatsBase{
 		params:         params,
 		logger:         params.Logger,
+		clock:          clock,
 		nextSnapshotID: cFirstSnapshotID,
 		snapshots:      make([]snapshot, 2),
 	}
@@ -321,7 +335,7 @@ func (r *rtpStatsBase) Stop() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	r.endTime = time.Now()
+	r.endTime = r.clock.Now()
 }
 
 func (r *rtpStatsBase) newSnapshotID(extStartSN uint64) uint32 {
@@ -335,7 +349,7 @@ func (r *rtpStatsBase) newSnapshotID(extStartSN uint64) uint32 {
 	}
 
 	if r.initialized {
-		r.snapshots[id-cFirstSnapshotID] = r.initSnapshot(time.Now(), extStartSN)
+		r.snapshots[id-cFirstSnapshotID] = r.initSnapshot(r.clock.Now(), extStartSN)
 	}
 	return id
 }
@@ -375,7 +389,7 @@ func (r *rtpStatsBase) CheckAndUpdatePli(throttle int64, force bool) bool {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	if !r.endTime.IsZero() || (!force && time.Now().UnixNano()-r.lastPli.UnixNano() < throttle) {
+	if !r.endTime.IsZero() || (!force && r.clock.Now().UnixNano()-r.lastPli.UnixNano() < throttle) {
 		return false
 	}
 	r.updatePliLocked(1)
@@ -422,7 +436,7 @@ func (r *rtpStatsBase) UpdatePliTime() {
 }
 
 func (r *rtpStatsBase) updatePliTimeLocked() {
-	r.lastPli = time.Now()
+	r.lastPli = r.clock.Now()
 }
 
 func (r *rtpStatsBase) LastPli() time.Time {
@@ -441,7 +455,7 @@ func (r *rtpStatsBase) UpdateLayerLockPliAndTime(pliCount uint32) {
 	}
 
 	r.layerLockPlis += pliCount
-	r.lastLayerLockPli = time.Now()
+	r.lastLayerLockPli = r.clock.Now()
 }
 
 func (r *rtpStatsBase) UpdateFir(firCount uint32) {
@@ -463,7 +477,7 @@ func (r *rtpStatsBase) UpdateFirTime() {
 		return
 	}
 
-	r.lastFir = time.Now()
+	r.lastFir = r.clock.Now()
 }
 
 func (r *rtpStatsBase) UpdateKeyFrame(kfCount uint32) {
@@ -475,7 +489,7 @@ func (r *rtpStatsBase) UpdateKeyFrame(kfCount uint32) {
 	}
 
 	r.keyFrames += kfCount
-	r.lastKeyFrame = time.Now()
+	r.lastKeyFrame = r.clock.Now()
 }
 
 func (r *rtpStatsBase) UpdateRtt(rtt uint32) {
@@ -507,7 +521,7 @@ func (r *rtpStatsBase) GetRtt() uint32 {
 }
 
 func (r *rtpStatsBase) maybeAdjustFirstPacketTime(srData *RTCPSenderReportData, tsOffset uint64, extStartTS uint64) (err error, loggingFields []interface{}) {
-	if time.Since(r.startTime) > cFirstPacketTimeAdjustWindow {
+	if r.clock.Now().Sub(r.startTime) > cFirstPacketTimeAdjustWindow {
 		return
 	}
 
@@ -517,7 +531,7 @@ func (r *rtpStatsBase) maybeAdjustFirstPacketTime(srData *RTCPSenderReportData,
 	// abnormal delay (maybe due to pacing or maybe due to queuing
 	// in some network element along the way), push back first time
 	// to an earlier instance.
-	timeSinceReceive := time.Since(srData.AtAdjusted)
+	timeSinceReceive := r.clock.Now().Sub(srData.AtAdjusted)
 	extNowTS := srData.RTPTimestampExt - tsOffset + uint64(timeSinceReceive.Nanoseconds()*int64(r.params.ClockRate)/1e9)
 	samplesDiff := int64(extNowTS - extStartTS)
 	if samplesDiff < 0 {
@@ -526,7 +540,7 @@ func (r *rtpStatsBase) maybeAdjustFirstPacketTime(srData *RTCPSenderReportData,
 	}
 
 	samplesDuration := time.Duration(float64(samplesDiff) / float64(r.params.ClockRate) * float64(time.Second))
-	timeSinceFirst := time.Since(time.Unix(0, r.firstTime))
+	timeSinceFirst := r.clock.Now().Sub(time.Unix(0, r.firstTime))
 	now := r.firstTime + timeSinceFirst.Nanoseconds()
 	firstTime := now - samplesDuration.Nanoseconds()
 
@@ -811,7 +825,7 @@ func (r *rtpStatsBase) toProto(
 
 	endTime := r.endTime
 	if endTime.IsZero() {
-		endTime = time.Now()
+		endTime = r.clock.Now()
 	}
 	elapsed := endTime.Sub(r.startTime).Seconds()
 	if elapsed == 0.0 {
@@ -937,6 +951,12 @@ func (r *rtpStatsBase) updateJitter(ets uint64, packetTime int64) float64 {
 				if r.jitter > s.maxJitter {
 					s.maxJitter = r.jitter
 				}
+				if s.jitterSamples == 0 || r.jitter < s.minJitter {
+					s.minJitter = r.jitter
+				}
+				s.jitterSum += r.jitter
+				s.jitterSquaredSum += r.jitter * r.jitter
+				s.jitterSamples++
 			}
 		}
 
@@ -959,7 +979,7 @@ func (r *rtpStatsBase) getAndResetSnapshot(snapshotID uint32, extStartSN uint64,
 	}
 
 	// snapshot now
-	now := r.getSnapshot(time.Now(), extHighestSN+1)
+	now := r.getSnapshot(r.clock.Now(), extHighestSN+1)
 	r.snapshots[idx] = now
 	return &then, &now
 }
@@ -175,7 +175,7 @@ func (r *RTPStatsReceiver) Update(
 
 		r.initialized = true
 
-		r.startTime = time.Now()
+		r.startTime = r.clock.Now()
 
 		r.firstTime = packetTime
 		r.highestTime = packetTime
@@ -430,7 +430,7 @@ func (r *RTPStatsReceiver) SetRtcpSenderReportData(srData *RTCPSenderReportData)
 			"receivedPropagationDelay", propagationDelay.String(),
 			"receivedDeltaPropagationDelay", deltaPropagationDelay.String(),
 			"deltaHighCount", r.propagationDelayDeltaHighCount,
-			"sinceDeltaHighStart", time.Since(r.propagationDelayDeltaHighStartTime).String(),
+			"sinceDeltaHighStart", r.clock.Now().Sub(r.propagationDelayDeltaHighStartTime).String(),
 			"propagationDelaySpike", r.propagationDelaySpike.String(),
 			"current", &srDataCopy,
 			"rtpStats", lockedRTPStatsReceiverLogEncoder{r},
@@ -464,7 +464,7 @@ func (r *RTPStatsReceiver) SetRtcpSenderReportData(srData *RTCPSenderReportData)
 				r.logger.Debugw("sharp increase in propagation delay", getPropagationFields()...)
 				r.propagationDelayDeltaHighCount++
 				if r.propagationDelayDeltaHighStartTime.IsZero() {
-					r.propagationDelayDeltaHighStartTime = time.Now()
+					r.propagationDelayDeltaHighStartTime = r.clock.Now()
 				}
 				if r.propagationDelaySpike == 0 {
 					r.propagationDelaySpike = propagationDelay
@@ -472,7 +472,7 @@ func (r *RTPStatsReceiver) SetRtcpSenderReportData(srData *RTCPSenderReportData)
 					r.propagationDelaySpike += time.Duration(cPropagationDelaySpikeAdaptationFactor * float64(propagationDelay-r.propagationDelaySpike))
 				}
 
-				if r.propagationDelayDeltaHighCount >= cPropagationDelayDeltaHighResetNumReports && time.Since(r.propagationDelayDeltaHighStartTime) >= cPropagationDelayDeltaHighResetWait {
+				if r.propagationDelayDeltaHighCount >= cPropagationDelayDeltaHighResetNumReports && r.clock.Now().Sub(r.propagationDelayDeltaHighStartTime) >= cPropagationDelayDeltaHighResetWait {
 					r.logger.Debugw("re-initializing propagation delay", append(getPropagationFields(), "newPropagationDelay", r.propagationDelaySpike.String())...)
 					initPropagationDelay(r.propagationDelaySpike)
 				}
@@ -577,7 +577,7 @@ func (r *RTPStatsReceiver) GetRtcpReceptionReport(ssrc uint32, proxyFracLost uin
 	if r.srNewest != nil {
 		lastSR = uint32(r.srNewest.NTPTimestamp >> 16)
 		if !r.srNewest.At.IsZero() {
-			delayUS := time.Since(r.srNewest.At).Microseconds()
+			delayUS := r.clock.Now().Sub(r.srNewest.At).Microseconds()
 			dlsr = uint32(delayUS * 65536 / 1e6)
 		}
 	}
@@ -593,6 +593,46 @@ func (r *RTPStatsReceiver) GetRtcpReceptionReport(ssrc uint32, proxyFracLost uin
 	}
 }
 
+// GetRtcpStatisticsSummary returns an RTCP XR statistics summary block (RFC 3611) of loss, duplicates and jitter
+// since the previous call with the same snapshot
+func (r *RTPStatsReceiver) GetRtcpStatisticsSummary(ssrc uint32, snapshotID uint32) *rtcp.StatisticsSummaryReportBlock {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	then, now := r.getAndResetSnapshot(snapshotID, r.sequenceNumber.GetExtendedStart(), r.sequenceNumber.GetExtendedHighest())
+	if now == nil || then == nil || now.extStartSN <= then.extStartSN || now.extStartSN-then.extStartSN > cNumSequenceNumbers {
+		return nil
+	}
+
+	packetsLost := now.packetsLost - then.packetsLost
+	if int64(packetsLost) < 0 {
+		packetsLost = 0
+	}
+
+	// jitter is not updated by every packet, use the current value when there were no updates in the interval
+	minJitter, maxJitter, meanJitter, devJitter := r.jitter, r.jitter, r.jitter, float64(0)
+	if then.jitterSamples != 0 {
+		minJitter, maxJitter = then.minJitter, then.maxJitter
+		meanJitter = then.jitterSum / float64(then.jitterSamples)
+		devJitter = math.Sqrt(math.Max(then.jitterSquaredSum/float64(then.jitterSamples)-meanJitter*meanJitter, 0))
+	}
+
+	return &rtcp.StatisticsSummaryReportBlock{
+		LossReports:      true,
+		DuplicateReports: true,
+		JitterReports:    true,
+		SSRC:             ssrc,
+		BeginSeq:         uint16(then.extStartSN),
+		EndSeq:           uint16(now.extStartSN),
+		LostPackets:      uint32(packetsLost),
+		DupPackets:       uint32(now.packetsDuplicate - then.packetsDuplicate),
+		MinJitter:        uint32(minJitter),
+		MaxJitter:        uint32(maxJitter),
+		MeanJitter:       uint32(meanJitter),
+		DevJitter:        uint32(devJitter),
+	}
+}
+
 func (r *RTPStatsReceiver) DeltaInfo(snapshotID uint32) *RTPDeltaInfo {
 	r.lock.Lock()
 	defer r.lock.Unlock()
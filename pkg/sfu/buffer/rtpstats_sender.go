@@ -228,7 +228,7 @@ func (r *RTPStatsSender) NewSenderSnapshotId() uint32 {
 	}
 
 	if r.initialized {
-		r.senderSnapshots[id-cFirstSnapshotID] = r.initSenderSnapshot(time.Now(), r.extHighestSN)
+		r.senderSnapshots[id-cFirstSnapshotID] = r.initSenderSnapshot(r.clock.Now(), r.extHighestSN)
 	}
 	return id
 }
@@ -257,7 +257,7 @@ func (r *RTPStatsSender) Update(
 
 		r.initialized = true
 
-		r.startTime = time.Now()
+		r.startTime = r.clock.Now()
 
 		r.firstTime = packetTime
 		r.highestTime = packetTime
@@ -466,7 +466,7 @@ func (r *RTPStatsSender) UpdateFromReceiverReport(rr rtcp.ReceptionReport) (rtt
 	if !r.lastRRTime.IsZero() && r.extHighestSNFromRR > extHighestSNFromRR {
 		r.logger.Debugw(
 			fmt.Sprintf("receiver report potentially out of order, highestSN: existing: %d, received: %d", r.extHighestSNFromRR, extHighestSNFromRR),
-			"sinceLastRR", time.Since(r.lastRRTime).String(),
+			"sinceLastRR", r.clock.Now().Sub(r.lastRRTime).String(),
 			"receivedRR", rr,
 			"rtpStats", lockedRTPStatsSenderLogEncoder{r},
 		)
@@ -524,9 +524,9 @@ func (r *RTPStatsSender) UpdateFromReceiverReport(rr rtcp.ReceptionReport) (rtt
 		}
 
 		if int64(extReceivedRRSN-s.extLastRRSN) < 0 || (extReceivedRRSN-s.extLastRRSN) > (1<<15) {
-			timeSinceLastRR := time.Since(r.lastRRTime)
+			timeSinceLastRR := r.clock.Now().Sub(r.lastRRTime)
 			if r.lastRRTime.IsZero() {
-				timeSinceLastRR = time.Since(r.startTime)
+				timeSinceLastRR = r.clock.Now().Sub(r.startTime)
 			}
 			r.logger.Infow(
 				"rr interval too big, skipping",
@@ -544,9 +544,9 @@ func (r *RTPStatsSender) UpdateFromReceiverReport(rr rtcp.ReceptionReport) (rtt
 		eis := &s.intervalStats
 		eis.aggregate(&is)
 		if is.packetsNotFound != 0 {
-			timeSinceLastRR := time.Since(r.lastRRTime)
+			timeSinceLastRR := r.clock.Now().Sub(r.lastRRTime)
 			if r.lastRRTime.IsZero() {
-				timeSinceLastRR = time.Since(r.startTime)
+				timeSinceLastRR = r.clock.Now().Sub(r.startTime)
 			}
 			if r.metadataCacheOverflowCount%10 == 0 {
 				r.logger.Infow(
@@ -566,7 +566,7 @@ func (r *RTPStatsSender) UpdateFromReceiverReport(rr rtcp.ReceptionReport) (rtt
 		s.extLastRRSN = extReceivedRRSN
 	}
 
-	r.lastRRTime = time.Now()
+	r.lastRRTime = r.clock.Now()
 	r.lastRR = rr
 	return
 }
@@ -614,7 +614,7 @@ func (r *RTPStatsSender) GetRtcpSenderReport(ssrc uint32, publisherSRData *RTCPS
 		return nil
 	}
 
-	timeSincePublisherSRAdjusted := time.Since(publisherSRData.AtAdjusted)
+	timeSincePublisherSRAdjusted := r.clock.Now().Sub(publisherSRData.AtAdjusted)
 	now := publisherSRData.AtAdjusted.Add(timeSincePublisherSRAdjusted)
 	var (
 		nowNTP    mediatransportutil.NtpTime
@@ -641,12 +641,12 @@ func (r *RTPStatsSender) GetRtcpSenderReport(ssrc uint32, publisherSRData *RTCPS
 			"curr", srData,
 			"feed", publisherSRData,
 			"tsOffset", tsOffset,
-			"timeNow", time.Now().String(),
+			"timeNow", r.clock.Now().String(),
 			"now", now.String(),
 			"timeSinceHighest", now.Sub(time.Unix(0, r.highestTime)).String(),
 			"timeSinceFirst", now.Sub(time.Unix(0, r.firstTime)).String(),
 			"timeSincePublisherSRAdjusted", timeSincePublisherSRAdjusted.String(),
-			"timeSincePublisherSR", time.Since(publisherSRData.At).String(),
+			"timeSincePublisherSR", r.clock.Now().Sub(publisherSRData.At).String(),
 			"nowRTPExt", nowRTPExt,
 			"rtpStats", lockedRTPStatsSenderLogEncoder{r},
 		}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil"
+	"github.com/livekit/protocol/logger"
+)
+
+func TestRTPStatsSenderReportMapping(t *testing.T) {
+	clockRate := uint32(90000)
+	r := NewRTPStatsSender(RTPStatsParams{
+		ClockRate: clockRate,
+		Logger:    logger.GetLogger(),
+	})
+
+	now := time.Now()
+	publisherSR := func(at time.Time, rtpTimestamp uint64) *RTCPSenderReportData {
+		return &RTCPSenderReportData{
+			RTPTimestamp:    uint32(rtpTimestamp),
+			RTPTimestampExt: rtpTimestamp,
+			NTPTimestamp:    mediatransportutil.ToNtpTime(at),
+			At:              at,
+			AtAdjusted:      at,
+		}
+	}
+
+	// nothing sent yet
+	require.Nil(t, r.GetRtcpSenderReport(0x1234, publisherSR(now, 100_000), 0))
+
+	r.Update(now.UnixNano(), 1000, 95_000, true, 12, 100, 0)
+
+	// each report carries the latest mapping of the publisher, translated to the outgoing timestamps
+	tsOffset := uint64(5_000)
+	interval := 3 * time.Second
+	for i := 0; i < 3; i++ {
+		at := now.Add(time.Duration(i) * interval)
+		rtpTimestamp := uint64(100_000) + uint64(i)*uint64(interval.Seconds()*float64(clockRate))
+
+		sr := r.GetRtcpSenderReport(0x1234, publisherSR(at, rtpTimestamp), tsOffset)
+		require.NotNil(t, sr)
+		require.Equal(t, uint32(0x1234), sr.SSRC)
+		require.Equal(t, uint64(mediatransportutil.ToNtpTime(at)), sr.NTPTime)
+		require.Equal(t, uint32(rtpTimestamp-tsOffset), sr.RTPTime)
+	}
+}
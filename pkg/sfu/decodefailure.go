@@ -0,0 +1,90 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	// key frame requests of a subscriber reporting more loss are attributed to the loss
+	decodeFailureMaxFractionLost = 0.02
+)
+
+type DecodeFailureParams struct {
+	// number of key frame requests (PLI/FIR) from the subscriber within window considered a decode failure, 0 disables detection.
+	// Only requests following a forwarded key frame while the subscriber reports little loss are counted
+	KeyFrameRequests int
+	Window           time.Duration
+}
+
+// decodeFailureDetector detects a subscriber that is unable to decode the forwarded codec,
+// i.e. it keeps requesting key frames although they are being forwarded and it is not losing packets
+type decodeFailureDetector struct {
+	params   DecodeFailureParams
+	requests []time.Time
+	// set on the send path, cleared when a request is counted
+	keyFrameForwarded atomic.Bool
+	fractionLost      uint8
+}
+
+func newDecodeFailureDetector(params DecodeFailureParams) *decodeFailureDetector {
+	return &decodeFailureDetector{
+		params:   params,
+		requests: make([]time.Time, 0, params.KeyFrameRequests),
+	}
+}
+
+func (d *decodeFailureDetector) onKeyFrameForwarded() {
+	d.keyFrameForwarded.Store(true)
+}
+
+// updateLoss records the fraction lost of the latest receiver report of the subscriber
+func (d *decodeFailureDetector) updateLoss(fractionLost uint8) {
+	d.fractionLost = fractionLost
+}
+
+// update records a key frame request, returns true when requests within the window reach the threshold.
+// Requests are reset after a failure is detected.
+func (d *decodeFailureDetector) update(at time.Time) bool {
+	if float64(d.fractionLost)/256.0 > decodeFailureMaxFractionLost {
+		// loss explains the requests, restart detection
+		d.requests = d.requests[:0]
+		return false
+	}
+	if !d.keyFrameForwarded.Swap(false) {
+		// a repeated request for the same key frame
+		return false
+	}
+
+	expired := 0
+	for _, ts := range d.requests {
+		if at.Sub(ts) < d.params.Window {
+			break
+		}
+		expired++
+	}
+	d.requests = append(d.requests[:0], d.requests[expired:]...)
+	d.requests = append(d.requests, at)
+
+	if len(d.requests) < d.params.KeyFrameRequests {
+		return false
+	}
+
+	d.requests = d.requests[:0]
+	return true
+}
@@ -27,20 +27,22 @@ import (
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/sdp/v3"
-	"github.com/pion/transport/v2/packetio"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
+	sfuinterceptor "github.com/livekit/livekit-server/pkg/sfu/interceptor"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	act "github.com/livekit/livekit-server/pkg/sfu/rtpextension/abscapturetime"
 	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
 	pd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/playoutdelay"
 	"github.com/livekit/livekit-server/pkg/sfu/utils"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 // TrackSender defines an interface send media to remote peer
@@ -50,6 +52,7 @@ type TrackSender interface {
 	UpTrackMaxPublishedLayerChange(maxPublishedLayer int32)
 	UpTrackMaxTemporalLayerSeenChange(maxTemporalLayerSeen int32)
 	UpTrackBitrateReport(availableLayers []int32, bitrates Bitrates)
+	UpTrackDeadChange(dead bool)
 	WriteRTP(p *buffer.ExtPacket, layer int32) error
 	Close()
 	IsClosed() bool
@@ -86,6 +89,33 @@ const (
 
 // -------------------------------------------------------------------
 
+// PubMutePolicy is what a down track sends when the publisher mutes the track, forwarding stops in any case
+type PubMutePolicy int
+
+const (
+	// PubMutePolicySilence sends silence frames on audio tracks for a while, settling the decoder of the subscriber
+	PubMutePolicySilence PubMutePolicy = iota
+	// PubMutePolicyStop sends nothing further
+	PubMutePolicyStop
+	// PubMutePolicyMarker sends a single blank frame with the marker bit set, closing out the last frame, audio and video
+	PubMutePolicyMarker
+)
+
+func (p PubMutePolicy) String() string {
+	switch p {
+	case PubMutePolicySilence:
+		return "SILENCE"
+	case PubMutePolicyStop:
+		return "STOP"
+	case PubMutePolicyMarker:
+		return "MARKER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// -------------------------------------------------------------------
+
 var (
 	ErrUnknownKind                       = errors.New("unknown kind of codec")
 	ErrOutOfOrderSequenceNumberCacheMiss = errors.New("out-of-order sequence number not found in cache")
@@ -96,6 +126,23 @@ var (
 	ErrPayloadOverflow                   = errors.New("payload overflow")
 )
 
+const FrameMarkingURI = "urn:ietf:params:rtp-hdrext:framemarking"
+
+// header extensions interpreted or set by the SFU, anything else is unknown and only forwarded when opaque
+// forwarding is enabled
+var knownHeaderExtensions = []string{
+	sdp.SDESMidURI,
+	sdp.SDESRTPStreamIDURI,
+	sfuinterceptor.SDESRepairRTPStreamIDURI,
+	sdp.TransportCCURI,
+	sdp.ABSSendTimeURI,
+	sdp.AudioLevelURI,
+	FrameMarkingURI,
+	dd.ExtensionURI,
+	pd.PlayoutDelayURI,
+	act.AbsCaptureTimeURI,
+}
+
 var (
 	VP8KeyFrame8x8 = []byte{
 		0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x08, 0x00,
@@ -197,6 +244,9 @@ type DownTrackStreamAllocatorListener interface {
 
 	// check if subscription mute can be applied
 	IsSubscribeMutable(dt *DownTrack) bool
+
+	// priority requested by the subscriber, returns an error if the change is rejected
+	SetTrackPriority(dt *DownTrack, priority uint8) error
 }
 
 type ReceiverReportListener func(dt *DownTrack, report *rtcp.ReceiverReport)
@@ -214,6 +264,47 @@ type DowntrackParams struct {
 	Logger            logger.Logger
 	Trailer           []byte
 	RTCPWriter        func([]rtcp.Packet) error
+	UnknownRTCPPolicy buffer.UnknownRTCPPolicy
+	// shift of forwarded timestamps relative to the sender report mapping, to compensate for a known pipeline delay
+	SyncOffset time.Duration
+	// lower case mime types of codecs whose frames could be sent out of presentation order, e.g. B-frames
+	ReorderedFrameCodecs []string
+	// number of times a packet is retransmitted, 0 uses the default
+	MaxRetransmits int
+	// do not request a key frame to start forwarding, wait for the next one sent by the publisher
+	DisableKeyFrameRequestOnStart bool
+	// action on loss reported by the subscriber that is not recovered, video only
+	LossFallback LossFallbackParams
+	// ascending fractions of packets lost, 0 to 1, whose crossing by a subscriber receiver report is notified
+	LossThresholds []float64
+	// detection of a subscriber failing to decode the forwarded codec, video only
+	DecodeFailure DecodeFailureParams
+	// target bitrate (bps) of each spatial layer used for allocation instead of the measured one, 0 keeps measured
+	LayerTargetBitrates []int64
+	// minimum time on a video layer before the allocation switches to a higher one
+	LayerSwitchMinDwell time.Duration
+	// frame rate cap of forwarded video, temporal layers above it are not allocated, 0 does not cap
+	MaxFps uint32
+	// bitrate cap (bps) of forwarded audio, audio above it is dropped, 0 does not cap
+	MaxAudioBitrate int
+	// what is sent when the publisher mutes the track
+	PubMutePolicy PubMutePolicy
+	// copy header extensions not known to the SFU, negotiated with both publisher and subscriber, as is
+	ForwardUnknownHeaderExtensions bool
+	// remove the CSRC list of the publisher from forwarded packets, including retransmissions
+	StripCSRC bool
+	// bitrate available to retransmissions of video and their order when it does not cover all NACKed packets
+	RetransmitBudget RetransmitBudgetParams
+	// NACKs of the subscriber are not answered while it returns true, nil always answers them
+	IgnoreNACKs func() bool
+	// how padding only packets of the publisher are forwarded
+	PaddingPolicy PaddingPolicy
+	// how keepalive packets of the publisher, without payload and padding, are forwarded
+	KeepalivePolicy KeepalivePolicy
+	// highest layers forwarded of SVC video forwarded using the dependency descriptor, by lower case mime type
+	SVCLayerCaps map[string]buffer.VideoLayer
+	// traces the decisions taken on feedback received from the subscriber, nil does not trace
+	PacketTrace *PacketTrace
 }
 
 // DownTrack implements TrackLocal, is the track used to write packets
@@ -241,7 +332,9 @@ type DownTrack struct {
 	transportWideExtID        int
 	dependencyDescriptorExtID int
 	playoutDelayExtID         int
+	ridExtID                  int
 	absCaptureTimeExtID       int
+	unknownExtIDs             atomic.Pointer[map[uint8]uint8] // publisher side id -> subscriber side id of forwarded unknown extensions
 	transceiver               atomic.Pointer[webrtc.RTPTransceiver]
 	writeStream               webrtc.TrackLocalWriter
 	rtcpReader                *buffer.RTCPReader
@@ -275,6 +368,7 @@ type DownTrack struct {
 	streamAllocatorListener         DownTrackStreamAllocatorListener
 	streamAllocatorReportGeneration int
 	streamAllocatorBytesCounter     atomic.Uint32
+	priority                        atomic.Uint32
 	/* STREAM-ALLOCATOR-DATA
 	bytesSent                       atomic.Uint32
 	bytesRetransmitted              atomic.Uint32
@@ -282,8 +376,18 @@ type DownTrack struct {
 
 	playoutDelay *PlayoutDelayController
 
+	lossFallback *lossFallback
+
+	lossThresholds *lossThresholds
+
+	retransmitBudget *retransmitBudget
+
+	decodeFailureDetector *decodeFailureDetector
+
 	pacer pacer.Pacer
 
+	upTrackDead atomic.Bool
+
 	maxLayerNotifierChMu     sync.RWMutex
 	maxLayerNotifierCh       chan string
 	maxLayerNotifierChClosed bool
@@ -295,7 +399,10 @@ type DownTrack struct {
 	cbMu                        sync.RWMutex
 	onStatsUpdate               func(dt *DownTrack, stat *livekit.AnalyticsStat)
 	onMaxSubscribedLayerChanged func(dt *DownTrack, layer int32)
+	onUpTrackDeadChange         func(dt *DownTrack, dead bool)
 	onRttUpdate                 func(dt *DownTrack, rtt uint32)
+	onDecodeFailure             func(dt *DownTrack)
+	onLossThresholdCrossed      func(dt *DownTrack, crossing LossThresholdCrossing)
 	onCloseHandler              func(isExpectedToResume bool)
 
 	createdAt int64
@@ -335,6 +442,14 @@ func NewDownTrack(params DowntrackParams) (*DownTrack, error) {
 		false,
 		d.getExpectedRTPTimestamp,
 	)
+	d.forwarder.SetSyncOffset(d.params.SyncOffset)
+	d.forwarder.SetReorderedFrameCodecs(d.params.ReorderedFrameCodecs)
+	d.forwarder.SetLayerSwitchMinDwell(d.params.LayerSwitchMinDwell)
+	d.forwarder.SetMaxFps(d.params.MaxFps, d.params.Receiver.GetTemporalLayerFpsForSpatial)
+	d.forwarder.SetMaxAudioBitrate(d.params.MaxAudioBitrate)
+	d.forwarder.SetPaddingPolicy(d.params.PaddingPolicy)
+	d.forwarder.SetKeepalivePolicy(d.params.KeepalivePolicy)
+	d.forwarder.SetSVCLayerCaps(d.params.SVCLayerCaps)
 
 	d.rtpStats = buffer.NewRTPStatsSender(buffer.RTPStatsParams{
 		ClockRate: d.codec.ClockRate,
@@ -354,6 +469,9 @@ func NewDownTrack(params DowntrackParams) (*DownTrack, error) {
 		}
 	})
 
+	if len(params.LossThresholds) != 0 {
+		d.lossThresholds = newLossThresholds(params.LossThresholds)
+	}
 	if d.kind == webrtc.RTPCodecTypeVideo {
 		if delay := params.PlayoutDelayLimit; delay.GetEnabled() {
 			var err error
@@ -362,6 +480,15 @@ func NewDownTrack(params DowntrackParams) (*DownTrack, error) {
 				return nil, err
 			}
 		}
+		if params.LossFallback.Action != LossFallbackActionNone {
+			d.lossFallback = newLossFallback(params.LossFallback)
+		}
+		if params.RetransmitBudget.Bitrate > 0 {
+			d.retransmitBudget = newRetransmitBudget(params.RetransmitBudget)
+		}
+		if params.DecodeFailure.KeyFrameRequests > 0 {
+			d.decodeFailureDetector = newDecodeFailureDetector(params.DecodeFailure)
+		}
 		go d.maxLayerNotifierWorker()
 		go d.keyFrameRequester()
 	}
@@ -406,19 +533,30 @@ func (d *DownTrack) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecParameters,
 		return codec, nil
 	}
 
-	d.params.Logger.Debugw("DownTrack.Bind", "codecs", d.upstreamCodecs, "matchCodec", codec, "ssrc", t.SSRC())
-	d.ssrc = uint32(t.SSRC())
+	// sent with the SSRC signalled to the subscriber, which may differ from the one allocated by pion
+	ssrc := d.params.BufferFactory.LocalSSRC(uint32(t.SSRC()))
+	d.params.Logger.Debugw("DownTrack.Bind", "codecs", d.upstreamCodecs, "matchCodec", codec, "ssrc", ssrc)
+	rr, err := d.params.BufferFactory.ClaimRTCPReader(ssrc, string(d.SubscriberID()), buffer.StreamDirectionOutgoing)
+	if err != nil {
+		onBinding := d.onBinding
+		d.bindLock.Unlock()
+		d.params.Logger.Warnw("bind error for ssrc collision", err, "ssrc", ssrc)
+		if onBinding != nil {
+			onBinding(err)
+		}
+		return webrtc.RTPCodecParameters{}, err
+	}
+	rr.OnPacketForOwner(string(d.SubscriberID()), func(pkt []byte) {
+		d.handleRTCP(pkt)
+	})
+	d.rtcpReader = rr
+
+	d.ssrc = ssrc
 	d.payloadType = uint8(codec.PayloadType)
 	d.writeStream = t.WriteStream()
 	d.mime = strings.ToLower(codec.MimeType)
-	if rr := d.params.BufferFactory.GetOrNew(packetio.RTCPBufferPacket, uint32(t.SSRC())).(*buffer.RTCPReader); rr != nil {
-		rr.OnPacket(func(pkt []byte) {
-			d.handleRTCP(pkt)
-		})
-		d.rtcpReader = rr
-	}
 
-	d.sequencer = newSequencer(d.params.MaxTrack, d.kind == webrtc.RTPCodecTypeVideo, d.params.Logger)
+	d.sequencer = newSequencer(d.params.MaxTrack, d.kind == webrtc.RTPCodecTypeVideo, d.params.MaxRetransmits, d.params.Logger)
 
 	d.codec = codec.RTPCodecCapability
 	if d.onBinding != nil {
@@ -564,6 +702,8 @@ func (d *DownTrack) SetRTPHeaderExtensions(rtpHeaderExtensions []webrtc.RTPHeade
 			d.dependencyDescriptorExtID = ext.ID
 		case pd.PlayoutDelayURI:
 			d.playoutDelayExtID = ext.ID
+		case sdp.SDESRTPStreamIDURI:
+			d.ridExtID = ext.ID
 		case sdp.TransportCCURI:
 			if isBWEEnabled {
 				d.transportWideExtID = ext.ID
@@ -574,6 +714,23 @@ func (d *DownTrack) SetRTPHeaderExtensions(rtpHeaderExtensions []webrtc.RTPHeade
 			d.absCaptureTimeExtID = ext.ID
 		}
 	}
+
+	if d.params.ForwardUnknownHeaderExtensions {
+		unknownExtIDs := make(map[uint8]uint8)
+		upstreamExtensions := d.params.Receiver.HeaderExtensions()
+		for _, ext := range rtpHeaderExtensions {
+			if slices.Contains(knownHeaderExtensions, ext.URI) {
+				continue
+			}
+			for _, upstreamExt := range upstreamExtensions {
+				if upstreamExt.URI == ext.URI {
+					unknownExtIDs[uint8(upstreamExt.ID)] = uint8(ext.ID)
+					break
+				}
+			}
+		}
+		d.unknownExtIDs.Store(&unknownExtIDs)
+	}
 }
 
 // Kind controls if this TrackLocal is audio or video
@@ -653,6 +810,10 @@ func (d *DownTrack) keyFrameRequester() {
 
 		locked, layer := d.forwarder.CheckSync()
 		if !locked && layer != buffer.InvalidLayerSpatial && d.writable.Load() {
+			if d.params.DisableKeyFrameRequestOnStart && !d.forwarder.GetState().Started {
+				continue
+			}
+
 			d.params.Logger.Debugw("sending PLI for layer lock", "layer", layer)
 			d.params.Receiver.SendPLI(layer, false)
 			d.rtpStats.UpdateLayerLockPliAndTime(1)
@@ -725,6 +886,12 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 		return ErrPayloadOverflow
 	}
 	payload = payload[:len(tp.codecBytes)+n]
+	if len(payload) == 0 && extPkt.Packet.Padding && extPkt.Packet.PaddingSize != 0 {
+		// forwarded padding only packet, padding is not part of the payload, restore it to match the header
+		payload = payload[:extPkt.Packet.PaddingSize]
+		clear(payload)
+		payload[len(payload)-1] = extPkt.Packet.PaddingSize
+	}
 
 	hdr, err := d.getTranslatedRTPHeader(extPkt, &tp)
 	if err != nil {
@@ -762,6 +929,30 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 			// retransmited sequence numbers. But, that is highly improbable, if not impossible.
 		}
 	}
+	if d.ridExtID != 0 && layer >= 0 {
+		// not cached in sequencer, retransmitted packets go out without it
+		extensions = append(
+			extensions,
+			pacer.ExtensionData{
+				ID:      uint8(d.ridExtID),
+				Payload: []byte(buffer.SpatialLayerToRid(layer, d.params.Receiver.TrackInfo())),
+			},
+		)
+	}
+	// not cached in sequencer, retransmitted packets go out without them
+	if unknownExtIDs := d.unknownExtIDs.Load(); unknownExtIDs != nil {
+		for upstreamID, id := range *unknownExtIDs {
+			if payload := extPkt.Packet.GetExtension(upstreamID); payload != nil {
+				extensions = append(
+					extensions,
+					pacer.ExtensionData{
+						ID:      id,
+						Payload: payload,
+					},
+				)
+			}
+		}
+	}
 	var actBytes []byte
 	if extPkt.AbsCaptureTimeExt != nil && d.absCaptureTimeExtID != 0 {
 		// normalize capture time to SFU clock.
@@ -789,6 +980,8 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 	}
 
 	if d.sequencer != nil {
+		// temporal layer of every codec, padding only packets have none
+		temporal := int8(max(0, extPkt.Temporal))
 		d.sequencer.push(
 			extPkt.Arrival,
 			extPkt.ExtSequenceNumber,
@@ -796,6 +989,7 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 			tp.rtp.extTimestamp,
 			hdr.Marker,
 			int8(layer),
+			temporal,
 			payload[:len(tp.codecBytes)],
 			tp.incomingHeaderSize,
 			tp.ddBytes,
@@ -934,16 +1128,16 @@ func (d *DownTrack) Mute(muted bool) {
 		isSubscribeMutable = sal.IsSubscribeMutable(d)
 	}
 	changed := d.forwarder.Mute(muted, isSubscribeMutable)
-	d.handleMute(muted, changed)
+	d.handleMute(muted, changed, PubMutePolicySilence)
 }
 
 // PubMute enables or disables media forwarding - publisher side
 func (d *DownTrack) PubMute(pubMuted bool) {
 	changed := d.forwarder.PubMute(pubMuted)
-	d.handleMute(pubMuted, changed)
+	d.handleMute(pubMuted, changed, d.params.PubMutePolicy)
 }
 
-func (d *DownTrack) handleMute(muted bool, changed bool) {
+func (d *DownTrack) handleMute(muted bool, changed bool, policy PubMutePolicy) {
 	if !changed {
 		return
 	}
@@ -986,10 +1180,18 @@ func (d *DownTrack) handleMute(muted bool, changed bool) {
 	// comfort noise information. But, in case the publisher stops at an
 	// inopportune frame (due to media stream stop or injecting audio from a file),
 	// the decoder could be in a noisy state. So, inject blank frames on publisher
-	// mute too.
+	// mute too, unless configured otherwise.
 	d.blankFramesGeneration.Inc()
-	if d.kind == webrtc.RTPCodecTypeAudio && muted {
-		d.writeBlankFrameRTP(RTPBlankFramesMuteSeconds, d.blankFramesGeneration.Load())
+	if !muted {
+		return
+	}
+	switch policy {
+	case PubMutePolicySilence:
+		if d.kind == webrtc.RTPCodecTypeAudio {
+			d.writeBlankFrameRTP(RTPBlankFramesMuteSeconds, d.blankFramesGeneration.Load())
+		}
+	case PubMutePolicyMarker:
+		d.writeBlankFrameRTP(0, d.blankFramesGeneration.Load())
 	}
 }
 
@@ -1044,8 +1246,7 @@ func (d *DownTrack) CloseWithFlush(flush bool) {
 
 	if d.rtcpReader != nil && flush {
 		d.params.Logger.Debugw("downtrack close rtcp reader")
-		d.rtcpReader.Close()
-		d.rtcpReader.OnPacket(nil)
+		d.rtcpReader.Release(string(d.SubscriberID()))
 	}
 	d.bindLock.Unlock()
 
@@ -1089,6 +1290,41 @@ func (d *DownTrack) SetMaxSpatialLayer(spatialLayer int32) {
 	}
 }
 
+// SetPinnedSpatialLayer pins the subscriber to a spatial layer, forwarded regardless of the estimated bandwidth.
+// buffer.InvalidLayerSpatial unpins, handing the track back to the stream allocator
+func (d *DownTrack) SetPinnedSpatialLayer(spatialLayer int32) {
+	changed, maxLayer := d.forwarder.SetPinnedSpatialLayer(spatialLayer)
+	if !changed {
+		return
+	}
+
+	d.postMaxLayerNotifierEvent("pinned")
+
+	if sal := d.getStreamAllocatorListener(); sal != nil {
+		sal.OnSubscribedLayerChanged(d, maxLayer)
+	}
+}
+
+// SetPriority sets the allocation priority requested by the subscriber, 0 restores the default priority
+func (d *DownTrack) SetPriority(priority uint8) error {
+	if sal := d.getStreamAllocatorListener(); sal != nil {
+		if err := sal.SetTrackPriority(d, priority); err != nil {
+			return err
+		}
+	}
+
+	d.priority.Store(uint32(priority))
+	return nil
+}
+
+func (d *DownTrack) Priority() uint8 {
+	return uint8(d.priority.Load())
+}
+
+func (d *DownTrack) IsSpatialLayerPinned() bool {
+	return d.forwarder.IsSpatialLayerPinned()
+}
+
 func (d *DownTrack) SetMaxTemporalLayer(temporalLayer int32) {
 	changed, maxLayer := d.forwarder.SetMaxTemporalLayer(temporalLayer)
 	if !changed {
@@ -1162,6 +1398,7 @@ func (d *DownTrack) maybeAddTransition(bitrate int64, distance float64, pauseRea
 }
 
 func (d *DownTrack) UpTrackBitrateReport(availableLayers []int32, bitrates Bitrates) {
+	bitrates = d.allocationBitrates(bitrates)
 	d.maybeAddTransition(
 		d.forwarder.GetOptimalBandwidthNeeded(bitrates),
 		d.forwarder.DistanceToDesired(availableLayers, bitrates),
@@ -1169,6 +1406,21 @@ func (d *DownTrack) UpTrackBitrateReport(availableLayers []int32, bitrates Bitra
 	)
 }
 
+// UpTrackDeadChange indicates that the publisher stopped sending packets for the dead track timeout, or resumed
+func (d *DownTrack) UpTrackDeadChange(dead bool) {
+	if d.upTrackDead.Swap(dead) == dead {
+		return
+	}
+
+	if onUpTrackDeadChange := d.getOnUpTrackDeadChange(); onUpTrackDeadChange != nil {
+		onUpTrackDeadChange(d, dead)
+	}
+}
+
+func (d *DownTrack) IsUpTrackDead() bool {
+	return d.upTrackDead.Load()
+}
+
 // OnCloseHandler method to be called on remote tracked removed
 func (d *DownTrack) OnCloseHandler(fn func(isExpectedToResume bool)) {
 	d.cbMu.Lock()
@@ -1226,6 +1478,36 @@ func (d *DownTrack) getOnRttUpdate() func(dt *DownTrack, rtt uint32) {
 	return d.onRttUpdate
 }
 
+// OnDecodeFailure is called when the subscriber keeps requesting key frames, i.e. it is likely unable to decode the codec
+func (d *DownTrack) OnDecodeFailure(fn func(dt *DownTrack)) {
+	d.cbMu.Lock()
+	defer d.cbMu.Unlock()
+
+	d.onDecodeFailure = fn
+}
+
+func (d *DownTrack) getOnDecodeFailure() func(dt *DownTrack) {
+	d.cbMu.RLock()
+	defer d.cbMu.RUnlock()
+
+	return d.onDecodeFailure
+}
+
+// OnLossThresholdCrossed is called when the loss reported by the subscriber crosses one of the configured thresholds
+func (d *DownTrack) OnLossThresholdCrossed(fn func(dt *DownTrack, crossing LossThresholdCrossing)) {
+	d.cbMu.Lock()
+	defer d.cbMu.Unlock()
+
+	d.onLossThresholdCrossed = fn
+}
+
+func (d *DownTrack) getOnLossThresholdCrossed() func(dt *DownTrack, crossing LossThresholdCrossing) {
+	d.cbMu.RLock()
+	defer d.cbMu.RUnlock()
+
+	return d.onLossThresholdCrossed
+}
+
 func (d *DownTrack) OnMaxLayerChanged(fn func(dt *DownTrack, layer int32)) {
 	d.cbMu.Lock()
 	defer d.cbMu.Unlock()
@@ -1240,30 +1522,73 @@ func (d *DownTrack) getOnMaxLayerChanged() func(dt *DownTrack, layer int32) {
 	return d.onMaxSubscribedLayerChanged
 }
 
+func (d *DownTrack) OnUpTrackDeadChange(fn func(dt *DownTrack, dead bool)) {
+	d.cbMu.Lock()
+	defer d.cbMu.Unlock()
+
+	d.onUpTrackDeadChange = fn
+}
+
+func (d *DownTrack) getOnUpTrackDeadChange() func(dt *DownTrack, dead bool) {
+	d.cbMu.RLock()
+	defer d.cbMu.RUnlock()
+
+	return d.onUpTrackDeadChange
+}
+
 func (d *DownTrack) IsDeficient() bool {
 	return d.forwarder.IsDeficient()
 }
 
+// getLayeredBitrate returns the published layers with their bitrates as seen by the allocator
+func (d *DownTrack) getLayeredBitrate() ([]int32, Bitrates) {
+	al, brs := d.params.Receiver.GetLayeredBitrate()
+	return al, d.allocationBitrates(brs)
+}
+
+// allocationBitrates applies the layer targets and the frame rate cap to the bitrates measured by the receiver
+func (d *DownTrack) allocationBitrates(brs Bitrates) Bitrates {
+	brs = withLayerTargetBitrates(brs, d.params.LayerTargetBitrates)
+	return withMaxFps(brs, d.params.MaxFps, d.params.Receiver.GetTemporalLayerFpsForSpatial)
+}
+
 func (d *DownTrack) BandwidthRequested() int64 {
-	_, brs := d.params.Receiver.GetLayeredBitrate()
+	_, brs := d.getLayeredBitrate()
 	return d.forwarder.BandwidthRequested(brs)
 }
 
+func (d *DownTrack) OptimalBandwidthNeeded() int64 {
+	_, brs := d.getLayeredBitrate()
+	return d.forwarder.GetOptimalBandwidthNeeded(brs)
+}
+
 func (d *DownTrack) DistanceToDesired() float64 {
-	al, brs := d.params.Receiver.GetLayeredBitrate()
+	al, brs := d.getLayeredBitrate()
 	return d.forwarder.DistanceToDesired(al, brs)
 }
 
 func (d *DownTrack) AllocateOptimal(allowOvershoot bool) VideoAllocation {
-	al, brs := d.params.Receiver.GetLayeredBitrate()
+	al, brs := d.getLayeredBitrate()
 	allocation := d.forwarder.AllocateOptimal(al, brs, allowOvershoot)
 	d.postKeyFrameRequestEvent()
 	d.maybeAddTransition(allocation.BandwidthNeeded, allocation.DistanceToDesired, allocation.PauseReason)
 	return allocation
 }
 
+func (d *DownTrack) AllocateLowest() (VideoAllocation, bool) {
+	al, brs := d.getLayeredBitrate()
+	allocation, isAllocated := d.forwarder.AllocateLowest(al, brs)
+	if !isAllocated {
+		return allocation, false
+	}
+
+	d.postKeyFrameRequestEvent()
+	d.maybeAddTransition(allocation.BandwidthNeeded, allocation.DistanceToDesired, allocation.PauseReason)
+	return allocation, true
+}
+
 func (d *DownTrack) ProvisionalAllocatePrepare() {
-	al, brs := d.params.Receiver.GetLayeredBitrate()
+	al, brs := d.getLayeredBitrate()
 	d.forwarder.ProvisionalAllocatePrepare(al, brs)
 }
 
@@ -1305,7 +1630,7 @@ func (d *DownTrack) ProvisionalAllocateCommit() VideoAllocation {
 }
 
 func (d *DownTrack) AllocateNextHigher(availableChannelCapacity int64, allowOvershoot bool) (VideoAllocation, bool) {
-	al, brs := d.params.Receiver.GetLayeredBitrate()
+	al, brs := d.getLayeredBitrate()
 	allocation, available := d.forwarder.AllocateNextHigher(availableChannelCapacity, al, brs, allowOvershoot)
 	d.postKeyFrameRequestEvent()
 	d.maybeAddTransition(allocation.BandwidthNeeded, allocation.DistanceToDesired, allocation.PauseReason)
@@ -1313,7 +1638,7 @@ func (d *DownTrack) AllocateNextHigher(availableChannelCapacity int64, allowOver
 }
 
 func (d *DownTrack) GetNextHigherTransition(allowOvershoot bool) (VideoTransition, bool) {
-	availableLayers, brs := d.params.Receiver.GetLayeredBitrate()
+	availableLayers, brs := d.getLayeredBitrate()
 	transition, available := d.forwarder.GetNextHigherTransition(brs, allowOvershoot)
 	d.params.Logger.Debugw(
 		"stream: get next higher layer",
@@ -1326,7 +1651,7 @@ func (d *DownTrack) GetNextHigherTransition(allowOvershoot bool) (VideoTransitio
 }
 
 func (d *DownTrack) Pause() VideoAllocation {
-	al, brs := d.params.Receiver.GetLayeredBitrate()
+	al, brs := d.getLayeredBitrate()
 	allocation := d.forwarder.Pause(al, brs)
 	d.maybeAddTransition(allocation.BandwidthNeeded, allocation.DistanceToDesired, allocation.PauseReason)
 	return allocation
@@ -1399,7 +1724,8 @@ func (d *DownTrack) writeBlankFrameRTP(duration float32, generation uint32) chan
 		// send a number of blank frames just in case there is loss.
 		// Intentionally ignoring check for mute or bandwidth constrained mute
 		// as this is used to clear client side buffer.
-		numFrames := int(float32(frameRate) * duration)
+		// at least one frame, which closes out the last forwarded frame
+		numFrames := max(int(float32(frameRate)*duration), 1)
 		frameDuration := time.Duration(1000/frameRate) * time.Millisecond
 
 		ticker := time.NewTicker(frameDuration)
@@ -1535,11 +1861,14 @@ func (d *DownTrack) getH264BlankFrame(_frameEndNeeded bool) ([]byte, error) {
 }
 
 func (d *DownTrack) handleRTCP(bytes []byte) {
-	pkts, err := rtcp.Unmarshal(bytes)
+	pkts, numUnknown, err := buffer.UnmarshalRTCP(bytes, d.params.UnknownRTCPPolicy)
 	if err != nil {
 		d.params.Logger.Errorw("could not unmarshal rtcp receiver packets", err)
 		return
 	}
+	if d.params.UnknownRTCPPolicy == buffer.UnknownRTCPPolicyCount {
+		prometheus.IncrementUnknownRTCP(prometheus.Incoming, numUnknown)
+	}
 
 	pliOnce := true
 	sendPliOnce := func() {
@@ -1552,6 +1881,7 @@ func (d *DownTrack) handleRTCP(bytes []byte) {
 				d.rtpStats.UpdatePliTime()
 				pliOnce = false
 			}
+			d.params.PacketTrace.Trace("key frame request", "trackID", d.id, "layer", layer, "forwarded", layer != buffer.InvalidLayerSpatial)
 		}
 	}
 
@@ -1566,12 +1896,14 @@ func (d *DownTrack) handleRTCP(bytes []byte) {
 			if p.MediaSSRC == d.ssrc {
 				numPLIs++
 				sendPliOnce()
+				d.updateDecodeFailure()
 			}
 
 		case *rtcp.FullIntraRequest:
 			if p.MediaSSRC == d.ssrc {
 				numFIRs++
 				sendPliOnce()
+				d.updateDecodeFailure()
 			}
 
 		case *rtcp.ReceiverEstimatedMaximumBitrate:
@@ -1602,6 +1934,32 @@ func (d *DownTrack) handleRTCP(bytes []byte) {
 				}
 				*/
 
+				if d.decodeFailureDetector != nil {
+					d.decodeFailureDetector.updateLoss(r.FractionLost)
+				}
+
+				if d.lossFallback != nil && d.lossFallback.update(r.FractionLost, time.Now()) {
+					switch d.params.LossFallback.Action {
+					case LossFallbackActionKeyFrame:
+						d.params.Logger.Debugw("sustained loss, requesting key frame", "fractionLost", r.FractionLost)
+						sendPliOnce()
+					case LossFallbackActionLowerLayer:
+						if maxLayer := d.forwarder.MaxLayer(); maxLayer.Spatial > 0 {
+							d.params.Logger.Debugw("sustained loss, lowering max spatial layer", "fractionLost", r.FractionLost, "maxLayer", maxLayer)
+							d.SetMaxSpatialLayer(maxLayer.Spatial - 1)
+						}
+					}
+				}
+
+				if d.lossThresholds != nil {
+					for _, crossing := range d.lossThresholds.update(r.FractionLost) {
+						prometheus.IncrementLossThresholdCrossed(d.kind.String(), crossing.Threshold, crossing.Above)
+						if onLossThresholdCrossed := d.getOnLossThresholdCrossed(); onLossThresholdCrossed != nil {
+							onLossThresholdCrossed(d, crossing)
+						}
+					}
+				}
+
 				if d.playoutDelay != nil {
 					d.playoutDelay.OnSeqAcked(uint16(r.LastSequenceNumber))
 					// screen share track has inaccuracy jitter due to its low frame rate and bursty traffic
@@ -1627,14 +1985,30 @@ func (d *DownTrack) handleRTCP(bytes []byte) {
 					numNACKs += uint32(len(packetList))
 					nacks = append(nacks, packetList...)
 				}
-				go d.retransmitPackets(nacks)
+				ignored := d.params.IgnoreNACKs != nil && d.params.IgnoreNACKs()
+				if !ignored {
+					go d.retransmitPackets(nacks)
+				}
+				if d.params.PacketTrace.IsEnabled() {
+					d.params.PacketTrace.Trace("NACK", "trackID", d.id, "sequenceNumbers", nacks, "ignored", ignored)
+				}
 			}
 
 		case *rtcp.TransportLayerCC:
 			if p.MediaSSRC == d.ssrc {
-				if sal := d.getStreamAllocatorListener(); sal != nil {
+				sal := d.getStreamAllocatorListener()
+				if sal != nil {
 					sal.OnTransportCCFeedback(d, p)
 				}
+				if d.params.PacketTrace.IsEnabled() {
+					d.params.PacketTrace.Trace(
+						"TWCC feedback",
+						"trackID", d.id,
+						"baseSequenceNumber", p.BaseSequenceNumber,
+						"packetStatusCount", p.PacketStatusCount,
+						"estimated", sal != nil,
+					)
+				}
 			}
 
 		case *rtcp.ExtendedReport:
@@ -1682,6 +2056,17 @@ func (d *DownTrack) handleRTCP(bytes []byte) {
 	}
 }
 
+func (d *DownTrack) updateDecodeFailure() {
+	if d.decodeFailureDetector == nil || !d.decodeFailureDetector.update(time.Now()) {
+		return
+	}
+
+	d.params.Logger.Infow("subscriber keeps requesting key frames, possible decode failure", "codec", d.Codec().MimeType)
+	if onDecodeFailure := d.getOnDecodeFailure(); onDecodeFailure != nil {
+		onDecodeFailure(d)
+	}
+}
+
 func (d *DownTrack) SetConnected() {
 	d.bindLock.Lock()
 	if !d.connected.Swap(true) {
@@ -1719,7 +2104,15 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 	nackMisses := uint32(0)
 	numRepeatedNACKs := uint32(0)
 	// STREAM-ALLOCATOR-DATA nackInfos := make([]NackInfo, 0, len(filtered))
-	for _, epm := range d.sequencer.getExtPacketMetas(filtered) {
+	var epms []extPacketMeta
+	if d.retransmitBudget != nil {
+		// NACKs are recorded only for packets the budget lets through
+		epms = d.sequencer.peekExtPacketMetas(filtered)
+		d.retransmitBudget.prioritize(epms)
+	} else {
+		epms = d.sequencer.getExtPacketMetas(filtered)
+	}
+	for _, epm := range epms {
 		if disallowedLayers[epm.layer] {
 			continue
 		}
@@ -1740,9 +2133,19 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 				break
 			}
 			nackMisses++
+			if d.retransmitBudget != nil {
+				d.sequencer.markNacked(&epm)
+			}
 			continue
 		}
 
+		if d.retransmitBudget != nil {
+			if !d.retransmitBudget.consume(n, time.Now()) {
+				continue
+			}
+			d.sequencer.markNacked(&epm)
+		}
+
 		if epm.nacked > 1 {
 			numRepeatedNACKs++
 		}
@@ -1757,6 +2160,9 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 		pkt.Header.Timestamp = epm.timestamp
 		pkt.Header.SSRC = d.ssrc
 		pkt.Header.PayloadType = d.payloadType
+		if d.params.StripCSRC {
+			pkt.Header.CSRC = nil
+		}
 
 		poolEntity := PacketFactory.Get().(*[]byte)
 		payload := *poolEntity
@@ -1850,6 +2256,9 @@ func (d *DownTrack) getTranslatedRTPHeader(extPkt *buffer.ExtPacket, tp *Transla
 	if tp.marker {
 		hdr.Marker = tp.marker
 	}
+	if d.params.StripCSRC {
+		hdr.CSRC = nil
+	}
 
 	return &hdr, nil
 }
@@ -2088,6 +2497,9 @@ func (d *DownTrack) sendingPacket(hdr *rtp.Header, payloadSize int, spmd *sendPa
 	if spmd.isKeyFrame {
 		d.isNACKThrottled.Store(false)
 		d.rtpStats.UpdateKeyFrame(1)
+		if d.decodeFailureDetector != nil {
+			d.decodeFailureDetector.onKeyFrameForwarded()
+		}
 		d.params.Logger.Debugw(
 			"forwarded key frame",
 			"layer", spmd.layer,
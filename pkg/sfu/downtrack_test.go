@@ -0,0 +1,643 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+)
+
+type pliCountingReceiver struct {
+	TrackReceiver
+
+	plis atomic.Int32
+}
+
+func (r *pliCountingReceiver) TrackID() livekit.TrackID {
+	return "TR_test"
+}
+
+func (r *pliCountingReceiver) SendPLI(_layer int32, _force bool) {
+	r.plis.Inc()
+}
+
+func (r *pliCountingReceiver) DeleteDownTrack(_participantID livekit.ParticipantID) {
+}
+
+func TestDownTrackKeyFrameRequestOnStart(t *testing.T) {
+	newDownTrack := func(t *testing.T, disableKeyFrameRequestOnStart bool) (*DownTrack, *pliCountingReceiver) {
+		receiver := &pliCountingReceiver{}
+		d, err := NewDownTrack(DowntrackParams{
+			Codecs: []webrtc.RTPCodecParameters{{
+				RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+				PayloadType:        96,
+			}},
+			Receiver:                      receiver,
+			SubID:                         "PA_test",
+			MaxTrack:                      100,
+			Logger:                        logger.GetLogger(),
+			DisableKeyFrameRequestOnStart: disableKeyFrameRequestOnStart,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { d.CloseWithFlush(false) })
+
+		// subscribed with a layer allocated, waiting for a key frame to start forwarding
+		d.forwarder.lock.Lock()
+		d.forwarder.vls.SetTarget(buffer.VideoLayer{Spatial: 0, Temporal: 0})
+		d.forwarder.vls.SetRequestSpatial(0)
+		d.forwarder.lock.Unlock()
+		d.writable.Store(true)
+		d.postKeyFrameRequestEvent()
+		return d, receiver
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		_, receiver := newDownTrack(t, false)
+		require.Eventually(t, func() bool { return receiver.plis.Load() > 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		_, receiver := newDownTrack(t, true)
+		// requester runs at least every keyFrameIntervalMax
+		time.Sleep(2 * keyFrameIntervalMax * time.Millisecond)
+		require.Zero(t, receiver.plis.Load())
+	})
+}
+
+func TestDownTrackLossFallback(t *testing.T) {
+	const ssrc = 1234
+
+	newDownTrack := func(t *testing.T, action LossFallbackAction) (*DownTrack, *pliCountingReceiver) {
+		receiver := &pliCountingReceiver{}
+		d, err := NewDownTrack(DowntrackParams{
+			Codecs: []webrtc.RTPCodecParameters{{
+				RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+				PayloadType:        96,
+			}},
+			Receiver: receiver,
+			SubID:    "PA_test",
+			MaxTrack: 100,
+			Logger:   logger.GetLogger(),
+			LossFallback: LossFallbackParams{
+				Action:    action,
+				Threshold: 0.1,
+				Duration:  50 * time.Millisecond,
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { d.CloseWithFlush(false) })
+
+		d.ssrc = ssrc
+		d.SetMaxSpatialLayer(2)
+		d.forwarder.lock.Lock()
+		d.forwarder.vls.SetRequestSpatial(0)
+		d.forwarder.lock.Unlock()
+		return d, receiver
+	}
+
+	receiverReport := func(t *testing.T, fractionLost uint8) []byte {
+		buf, err := (&rtcp.ReceiverReport{
+			SSRC: 5678,
+			Reports: []rtcp.ReceptionReport{{
+				SSRC:         ssrc,
+				FractionLost: fractionLost,
+			}},
+		}).Marshal()
+		require.NoError(t, err)
+		return buf
+	}
+
+	t.Run("key frame on sustained loss", func(t *testing.T) {
+		d, receiver := newDownTrack(t, LossFallbackActionKeyFrame)
+
+		// 25% loss
+		d.handleRTCP(receiverReport(t, 64))
+		require.Zero(t, receiver.plis.Load())
+
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 64))
+		require.EqualValues(t, 1, receiver.plis.Load())
+
+		// falls back again only after loss persists for another duration
+		d.handleRTCP(receiverReport(t, 64))
+		require.EqualValues(t, 1, receiver.plis.Load())
+	})
+
+	t.Run("transient loss", func(t *testing.T) {
+		d, receiver := newDownTrack(t, LossFallbackActionKeyFrame)
+
+		d.handleRTCP(receiverReport(t, 64))
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 0))
+		d.handleRTCP(receiverReport(t, 64))
+		require.Zero(t, receiver.plis.Load())
+
+		// below threshold
+		d.handleRTCP(receiverReport(t, 12))
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 12))
+		require.Zero(t, receiver.plis.Load())
+	})
+
+	t.Run("lower layer on sustained loss", func(t *testing.T) {
+		d, receiver := newDownTrack(t, LossFallbackActionLowerLayer)
+
+		d.handleRTCP(receiverReport(t, 64))
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 64))
+		require.EqualValues(t, 1, d.MaxLayer().Spatial)
+
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 64))
+		require.EqualValues(t, 0, d.MaxLayer().Spatial)
+
+		// stays on the lowest layer
+		time.Sleep(60 * time.Millisecond)
+		d.handleRTCP(receiverReport(t, 64))
+		require.EqualValues(t, 0, d.MaxLayer().Spatial)
+		require.Zero(t, receiver.plis.Load())
+	})
+}
+
+func TestDownTrackLossThresholds(t *testing.T) {
+	const ssrc = 1234
+
+	d, err := NewDownTrack(DowntrackParams{
+		Codecs: []webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+			PayloadType:        111,
+		}},
+		Receiver:       &pliCountingReceiver{},
+		SubID:          "PA_test",
+		MaxTrack:       100,
+		Logger:         logger.GetLogger(),
+		LossThresholds: []float64{0.05, 0.2},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.CloseWithFlush(false) })
+	d.ssrc = ssrc
+
+	var crossings []LossThresholdCrossing
+	d.OnLossThresholdCrossed(func(_ *DownTrack, crossing LossThresholdCrossing) {
+		crossings = append(crossings, crossing)
+	})
+
+	receiverReport := func(fractionLost uint8) []byte {
+		buf, err := (&rtcp.ReceiverReport{
+			SSRC: 5678,
+			Reports: []rtcp.ReceptionReport{{
+				SSRC:         ssrc,
+				FractionLost: fractionLost,
+			}},
+		}).Marshal()
+		require.NoError(t, err)
+		return buf
+	}
+
+	// below all thresholds
+	d.handleRTCP(receiverReport(5))
+	require.Empty(t, crossings)
+
+	// 10% loss crosses the first threshold
+	d.handleRTCP(receiverReport(26))
+	require.Equal(t, []LossThresholdCrossing{{Threshold: 0.05, Above: true, FractionLost: 26.0 / 256}}, crossings)
+
+	// staying above does not emit again
+	crossings = nil
+	d.handleRTCP(receiverReport(30))
+	require.Empty(t, crossings)
+
+	// 25% loss crosses the second threshold
+	d.handleRTCP(receiverReport(64))
+	require.Equal(t, []LossThresholdCrossing{{Threshold: 0.2, Above: true, FractionLost: 64.0 / 256}}, crossings)
+
+	// no loss falls back below both thresholds, highest first
+	crossings = nil
+	d.handleRTCP(receiverReport(0))
+	require.Equal(t, []LossThresholdCrossing{
+		{Threshold: 0.2, Above: false, FractionLost: 0},
+		{Threshold: 0.05, Above: false, FractionLost: 0},
+	}, crossings)
+
+	// report of another stream is ignored
+	crossings = nil
+	buf, err := (&rtcp.ReceiverReport{
+		SSRC:    5678,
+		Reports: []rtcp.ReceptionReport{{SSRC: ssrc + 1, FractionLost: 128}},
+	}).Marshal()
+	require.NoError(t, err)
+	d.handleRTCP(buf)
+	require.Empty(t, crossings)
+}
+
+func TestDownTrackDecodeFailure(t *testing.T) {
+	const ssrc = 1234
+
+	receiver := &pliCountingReceiver{}
+	d, err := NewDownTrack(DowntrackParams{
+		Codecs: []webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1, ClockRate: 90000},
+			PayloadType:        96,
+		}},
+		Receiver: receiver,
+		SubID:    "PA_test",
+		MaxTrack: 100,
+		Logger:   logger.GetLogger(),
+		DecodeFailure: DecodeFailureParams{
+			KeyFrameRequests: 3,
+			Window:           100 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.CloseWithFlush(false) })
+	d.ssrc = ssrc
+
+	var failures atomic.Int32
+	d.OnDecodeFailure(func(dt *DownTrack) {
+		require.Equal(t, d, dt)
+		failures.Inc()
+	})
+
+	pli := func(t *testing.T) []byte {
+		buf, err := (&rtcp.PictureLossIndication{SenderSSRC: 5678, MediaSSRC: ssrc}).Marshal()
+		require.NoError(t, err)
+		return buf
+	}
+
+	// key frame request after a forwarded key frame
+	keyFramePLI := func(t *testing.T) []byte {
+		d.decodeFailureDetector.onKeyFrameForwarded()
+		return pli(t)
+	}
+	rr := func(t *testing.T, fractionLost uint8) []byte {
+		buf, err := (&rtcp.ReceiverReport{
+			SSRC:    5678,
+			Reports: []rtcp.ReceptionReport{{SSRC: ssrc, FractionLost: fractionLost}},
+		}).Marshal()
+		require.NoError(t, err)
+		return buf
+	}
+
+	// occasional key frame requests
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	time.Sleep(120 * time.Millisecond)
+	d.handleRTCP(keyFramePLI(t))
+	require.Zero(t, failures.Load())
+
+	// repeated requests while waiting for a key frame
+	d.handleRTCP(pli(t))
+	d.handleRTCP(pli(t))
+	require.Zero(t, failures.Load())
+
+	// subscriber keeps requesting key frames
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	require.EqualValues(t, 1, failures.Load())
+
+	// detection restarts after a failure
+	d.handleRTCP(keyFramePLI(t))
+	require.EqualValues(t, 1, failures.Load())
+
+	// requests of a subscriber losing packets
+	d.handleRTCP(rr(t, 64))
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	require.EqualValues(t, 1, failures.Load())
+
+	// loss recovered
+	d.handleRTCP(rr(t, 0))
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	d.handleRTCP(keyFramePLI(t))
+	require.EqualValues(t, 2, failures.Load())
+}
+
+func TestDownTrackStripCSRC(t *testing.T) {
+	newDownTrack := func(stripCSRC bool) *DownTrack {
+		d, err := NewDownTrack(DowntrackParams{
+			Codecs: []webrtc.RTPCodecParameters{{
+				RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+				PayloadType:        111,
+			}},
+			Receiver:  &pliCountingReceiver{},
+			SubID:     "PA_test",
+			MaxTrack:  100,
+			Logger:    logger.GetLogger(),
+			StripCSRC: stripCSRC,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { d.CloseWithFlush(false) })
+		return d
+	}
+
+	// audio mixed from two contributors
+	extPkt := &buffer.ExtPacket{
+		Packet: &rtp.Packet{
+			Header:  rtp.Header{CSRC: []uint32{0x1234, 0x5678}},
+			Payload: []byte{0x01, 0x02},
+		},
+	}
+
+	hdr, err := newDownTrack(false).getTranslatedRTPHeader(extPkt, &TranslationParams{})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0x1234, 0x5678}, hdr.CSRC)
+
+	hdr, err = newDownTrack(true).getTranslatedRTPHeader(extPkt, &TranslationParams{})
+	require.NoError(t, err)
+	require.Empty(t, hdr.CSRC)
+
+	// upstream packet is not modified
+	require.Equal(t, []uint32{0x1234, 0x5678}, extPkt.Packet.CSRC)
+}
+
+type headerExtensionsReceiver struct {
+	pliCountingReceiver
+
+	headerExtensions []webrtc.RTPHeaderExtensionParameter
+}
+
+func (r *headerExtensionsReceiver) HeaderExtensions() []webrtc.RTPHeaderExtensionParameter {
+	return r.headerExtensions
+}
+
+func TestDownTrackForwardUnknownHeaderExtensions(t *testing.T) {
+	receiver := &headerExtensionsReceiver{
+		headerExtensions: []webrtc.RTPHeaderExtensionParameter{
+			{URI: sdp.AudioLevelURI, ID: 1},
+			{URI: "urn:test:experimental", ID: 5},
+			{URI: "urn:test:publisher-only", ID: 6},
+		},
+	}
+	subscriberExtensions := []webrtc.RTPHeaderExtensionParameter{
+		{URI: sdp.AudioLevelURI, ID: 2},
+		{URI: "urn:test:experimental", ID: 9},
+		{URI: "urn:test:subscriber-only", ID: 10},
+	}
+
+	newDownTrack := func(forward bool) *DownTrack {
+		d, err := NewDownTrack(DowntrackParams{
+			Codecs: []webrtc.RTPCodecParameters{{
+				RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+				PayloadType:        111,
+			}},
+			Receiver:                       receiver,
+			SubID:                          "PA_test",
+			MaxTrack:                       100,
+			Logger:                         logger.GetLogger(),
+			ForwardUnknownHeaderExtensions: forward,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { d.CloseWithFlush(false) })
+		return d
+	}
+
+	t.Run("forwarded", func(t *testing.T) {
+		d := newDownTrack(true)
+		d.SetRTPHeaderExtensions(subscriberExtensions)
+		// only the extension negotiated on both sides and not interpreted by the SFU
+		require.Equal(t, map[uint8]uint8{5: 9}, *d.unknownExtIDs.Load())
+	})
+
+	t.Run("not forwarded", func(t *testing.T) {
+		d := newDownTrack(false)
+		d.SetRTPHeaderExtensions(subscriberExtensions)
+		require.Nil(t, d.unknownExtIDs.Load())
+	})
+}
+
+type recordingPacer struct {
+	pacer.Pacer
+
+	lock    sync.Mutex
+	headers []rtp.Header
+}
+
+func (p *recordingPacer) Enqueue(pkt pacer.Packet) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.headers = append(p.headers, *pkt.Header)
+}
+
+func (p *recordingPacer) Headers() []rtp.Header {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]rtp.Header{}, p.headers...)
+}
+
+func TestDownTrackPubMutePolicy(t *testing.T) {
+	opus := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}
+	vp8 := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	}
+
+	// a down track that has been forwarding when the publisher mutes
+	pubMute := func(t *testing.T, codec webrtc.RTPCodecParameters, policy PubMutePolicy) *recordingPacer {
+		p := &recordingPacer{}
+		d, err := NewDownTrack(DowntrackParams{
+			Codecs:        []webrtc.RTPCodecParameters{codec},
+			Receiver:      &pliCountingReceiver{},
+			SubID:         "PA_test",
+			MaxTrack:      100,
+			Logger:        logger.GetLogger(),
+			Pacer:         p,
+			PubMutePolicy: policy,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { d.CloseWithFlush(false) })
+
+		d.mime = strings.ToLower(codec.MimeType)
+		d.payloadType = uint8(codec.PayloadType)
+		d.writable.Store(true)
+		d.rtpStats.Update(time.Now().UnixNano(), 100, 1000, true, 12, 20, 0)
+
+		d.PubMute(true)
+		return p
+	}
+
+	t.Run("silence", func(t *testing.T) {
+		p := pubMute(t, opus, PubMutePolicySilence)
+		require.Eventually(t, func() bool { return len(p.Headers()) >= 5 }, time.Second, 10*time.Millisecond)
+
+		// nothing injected on video
+		p = pubMute(t, vp8, PubMutePolicySilence)
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, p.Headers())
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		p := pubMute(t, opus, PubMutePolicyStop)
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, p.Headers())
+	})
+
+	t.Run("marker", func(t *testing.T) {
+		for _, codec := range []webrtc.RTPCodecParameters{opus, vp8} {
+			p := pubMute(t, codec, PubMutePolicyMarker)
+			require.Eventually(t, func() bool { return len(p.Headers()) > 0 }, time.Second, 10*time.Millisecond)
+			time.Sleep(100 * time.Millisecond)
+
+			// a packet closing out the last forwarded frame could precede the blank frame
+			headers := p.Headers()
+			require.LessOrEqual(t, len(headers), 2, codec.MimeType)
+			for _, hdr := range headers {
+				require.True(t, hdr.Marker)
+				require.Equal(t, uint8(codec.PayloadType), hdr.PayloadType)
+			}
+		}
+	})
+}
+
+type layeredReceiver struct {
+	pliCountingReceiver
+
+	bitrates Bitrates
+	layerFps []float32
+}
+
+func (r *layeredReceiver) GetLayeredBitrate() ([]int32, Bitrates) {
+	return []int32{0, 1}, r.bitrates
+}
+
+func (r *layeredReceiver) GetTemporalLayerFpsForSpatial(_layer int32) []float32 {
+	return r.layerFps
+}
+
+func TestDownTrackMaxFps(t *testing.T) {
+	receiver := &layeredReceiver{
+		bitrates: Bitrates{
+			{1, 2, 3, 0},
+			{5, 6, 7, 0},
+		},
+		layerFps: []float32{7.5, 15, 30, 0},
+	}
+	d, err := NewDownTrack(DowntrackParams{
+		Codecs: []webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+			PayloadType:        96,
+		}},
+		Receiver: receiver,
+		SubID:    "PA_test",
+		MaxTrack: 100,
+		Logger:   logger.GetLogger(),
+		MaxFps:   15,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.CloseWithFlush(false) })
+
+	d.forwarder.DetermineCodec(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, nil)
+	d.SetMaxSpatialLayer(1)
+	d.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	d.forwarder.SetMaxPublishedLayer(1)
+	d.forwarder.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+
+	// capped without subscriber settings, bandwidth needed excludes temporal layers above the cap
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: 1}, d.AllocateOptimal(false).TargetLayer)
+	require.Equal(t, receiver.bitrates[1][1], d.OptimalBandwidthNeeded())
+
+	// re-evaluated on every allocation as frame rates are measured
+	receiver.layerFps = []float32{15, 30, 60, 0}
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: 0}, d.AllocateOptimal(false).TargetLayer)
+	require.Equal(t, receiver.bitrates[1][0], d.OptimalBandwidthNeeded())
+}
+
+type readCountingReceiver struct {
+	pliCountingReceiver
+
+	reads atomic.Int32
+}
+
+func (r *readCountingReceiver) ReadRTP(_buf []byte, _layer uint8, _sn uint16) (int, error) {
+	r.reads.Inc()
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestDownTrackIgnoreNACKs(t *testing.T) {
+	const ssrc = 1234
+
+	var ignore atomic.Bool
+	// decisions on NACKs are traced
+	var nacksIgnored []bool
+	trace := NewPacketTrace(func() bool { return true }, func(msg string, keysAndValues ...interface{}) {
+		for i := 0; msg == "NACK" && i+1 < len(keysAndValues); i += 2 {
+			if keysAndValues[i] == "ignored" {
+				nacksIgnored = append(nacksIgnored, keysAndValues[i+1].(bool))
+			}
+		}
+	})
+	receiver := &readCountingReceiver{}
+	d, err := NewDownTrack(DowntrackParams{
+		Codecs: []webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+			PayloadType:        111,
+		}},
+		Receiver:    receiver,
+		SubID:       "PA_test",
+		MaxTrack:    100,
+		Logger:      logger.GetLogger(),
+		IgnoreNACKs: ignore.Load,
+		PacketTrace: trace,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.CloseWithFlush(false) })
+
+	d.ssrc = ssrc
+	d.sequencer = newSequencer(100, false, 0, logger.GetLogger())
+	d.sequencer.push(time.Now().UnixNano(), 1, 1, 960, true, 0, 0, nil, 0, nil, nil)
+
+	nack := func() {
+		buf, err := (&rtcp.TransportLayerNack{
+			SenderSSRC: 5678,
+			MediaSSRC:  ssrc,
+			Nacks:      []rtcp.NackPair{{PacketID: 1}},
+		}).Marshal()
+		require.NoError(t, err)
+		d.handleRTCP(buf)
+	}
+
+	// not retransmitted while ignored, packets are retransmitted only after some time since sending
+	time.Sleep((ignoreRetransmission + 10) * time.Millisecond)
+	ignore.Store(true)
+	nack()
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, receiver.reads.Load())
+
+	// retransmitted once answered again
+	ignore.Store(false)
+	nack()
+	require.Eventually(t, func() bool { return receiver.reads.Load() == 1 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []bool{true, false}, nacksIgnored)
+}
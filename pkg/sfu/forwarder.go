@@ -26,6 +26,7 @@ import (
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/exp/slices"
 
 	"github.com/livekit/protocol/logger"
 
@@ -240,12 +241,29 @@ type Forwarder struct {
 	lastSwitchExtIncomingTS uint64
 	referenceLayerSpatial   int32
 	dummyStartTSOffset      uint64
+	syncOffset              time.Duration
+	reorderedFrameCodecs    []string
+	svcLayerCaps            map[string]buffer.VideoLayer
 	refInfos                [buffer.DefaultMaxLayerSpatial + 1]refInfo
 	refIsSVC                bool
 
 	provisional *VideoAllocationProvisional
 
-	lastAllocation VideoAllocation
+	lastAllocation      VideoAllocation
+	layerSwitchMinDwell time.Duration
+	lastLayerSwitchAt   time.Time
+	maxFps              uint32
+	layerFps            func(spatial int32) []float32
+
+	// token bucket of the audio bitrate cap, bits that can be forwarded as of audioBitsAt
+	maxAudioBitrate int
+	audioBits       float64
+	audioBitsAt     int64
+	audioBitsValid  bool
+
+	// spatial layer the subscriber is pinned to, max spatial layer requested by the subscriber is applied when unpinned
+	pinnedSpatialLayer  int32
+	requestedMaxSpatial int32
 
 	rtpMunger *RTPMunger
 
@@ -267,6 +285,7 @@ func NewForwarder(
 		getExpectedRTPTimestamp: getExpectedRTPTimestamp,
 		referenceLayerSpatial:   buffer.InvalidLayerSpatial,
 		lastAllocation:          VideoAllocationDefault,
+		pinnedSpatialLayer:      buffer.InvalidLayerSpatial,
 		rtpMunger:               NewRTPMunger(logger),
 		vls:                     videolayerselector.NewNull(logger),
 		codecMunger:             codecmunger.NewNull(logger),
@@ -306,6 +325,98 @@ func (f *Forwarder) SetMaxTemporalLayerSeen(maxTemporalLayerSeen int32) bool {
 	return true
 }
 
+// SetSyncOffset shifts forwarded timestamps against the sender report mapping, delaying (or advancing, if negative)
+// the track relative to other tracks of the publisher. It applies from the start of forwarding.
+func (f *Forwarder) SetSyncOffset(offset time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.syncOffset = offset
+}
+
+// SetReorderedFrameCodecs sets codecs, as lower case mime types, whose frames could be sent out of presentation order,
+// e.g. H.264 with B-frames. Timestamps synthesized on switches and for blank frames follow the highest timestamp sent
+// rather than the last one for those codecs.
+func (f *Forwarder) SetReorderedFrameCodecs(mimes []string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.reorderedFrameCodecs = mimes
+}
+
+// SetSVCLayerCaps sets the highest layers forwarded per codec, keyed by lower case mime type, of tracks forwarded
+// using the dependency descriptor. It applies to the codec determined after it is set.
+func (f *Forwarder) SetSVCLayerCaps(caps map[string]buffer.VideoLayer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.svcLayerCaps = caps
+}
+
+// SetLayerSwitchMinDwell sets the minimum time on a target layer before allocations switch to a higher one,
+// 0 switches right away. Switches down and pauses are not delayed to stay within the available bandwidth.
+func (f *Forwarder) SetLayerSwitchMinDwell(dwell time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.layerSwitchMinDwell = dwell
+}
+
+// SetMaxFps caps the temporal layer targeted by optimal allocations to the highest one whose frame rate, as measured
+// by layerFps, does not exceed maxFps, 0 does not cap. Allocations under a bandwidth constraint get bitrates with the
+// temporal layers above the cap removed, see withMaxFps.
+func (f *Forwarder) SetMaxFps(maxFps uint32, layerFps func(spatial int32) []float32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maxFps = maxFps
+	f.layerFps = layerFps
+}
+
+// SetMaxAudioBitrate caps the bitrate (bps) of forwarded audio payload, 0 does not cap. Audio above the cap, allowing
+// for a second of burst, is dropped, e.g. from a publisher not honouring the negotiated maxaveragebitrate.
+func (f *Forwarder) SetMaxAudioBitrate(bitrate int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maxAudioBitrate = bitrate
+	f.audioBitsValid = false
+}
+
+// SetPaddingPolicy sets how padding only packets of the publisher are forwarded
+func (f *Forwarder) SetPaddingPolicy(policy PaddingPolicy) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.rtpMunger.SetPaddingPolicy(policy)
+}
+
+// SetKeepalivePolicy sets how keepalive packets, i.e. ones without payload and padding, of the publisher are forwarded
+func (f *Forwarder) SetKeepalivePolicy(policy KeepalivePolicy) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.rtpMunger.SetKeepalivePolicy(policy)
+}
+
+// should be called with lock held
+func (f *Forwarder) getExtLastTS(state RTPMungerState) uint64 {
+	if slices.Contains(f.reorderedFrameCodecs, strings.ToLower(f.codec.MimeType)) {
+		return state.ExtHighestTS
+	}
+	return state.ExtLastTS
+}
+
+// should be called with lock held
+func (f *Forwarder) getSyncOffsetTS() uint64 {
+	if f.syncOffset == 0 || f.codec.ClockRate == 0 {
+		return 0
+	}
+
+	// negative offsets wrap around, like other timestamp offsets
+	return uint64(f.syncOffset.Nanoseconds() * int64(f.codec.ClockRate) / 1e9)
+}
+
 func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions []webrtc.RTPHeaderExtensionParameter) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -345,11 +456,7 @@ func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions [
 		// DD-TODO : we only enable dd layer selector for av1/vp9 now, in the future we can enable it for vp8 too
 		isDDAvailable := ddAvailable(extensions)
 		if isDDAvailable {
-			if f.vls != nil {
-				f.vls = videolayerselector.NewDependencyDescriptorFromNull(f.vls)
-			} else {
-				f.vls = videolayerselector.NewDependencyDescriptor(f.logger)
-			}
+			f.vls = f.newDependencyDescriptorSelector()
 		} else {
 			if f.vls != nil {
 				f.vls = videolayerselector.NewVP9FromNull(f.vls)
@@ -363,11 +470,7 @@ func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions [
 		// DD-TODO : we only enable dd layer selector for av1/vp9 now, in the future we can enable it for vp8 too
 		isDDAvailable := ddAvailable(extensions)
 		if isDDAvailable {
-			if f.vls != nil {
-				f.vls = videolayerselector.NewDependencyDescriptorFromNull(f.vls)
-			} else {
-				f.vls = videolayerselector.NewDependencyDescriptor(f.logger)
-			}
+			f.vls = f.newDependencyDescriptorSelector()
 		} else {
 			if f.vls != nil {
 				f.vls = videolayerselector.NewSimulcastFromNull(f.vls)
@@ -379,6 +482,21 @@ func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions [
 	}
 }
 
+// should be called with lock held
+func (f *Forwarder) newDependencyDescriptorSelector() *videolayerselector.DependencyDescriptor {
+	var ddSelector *videolayerselector.DependencyDescriptor
+	if f.vls != nil {
+		ddSelector = videolayerselector.NewDependencyDescriptorFromNull(f.vls)
+	} else {
+		ddSelector = videolayerselector.NewDependencyDescriptor(f.logger)
+	}
+	if layerCap, ok := f.svcLayerCaps[strings.ToLower(f.codec.MimeType)]; ok {
+		f.logger.Debugw("capping svc layers", "layerCap", layerCap)
+		ddSelector.SetLayerCap(layerCap)
+	}
+	return ddSelector
+}
+
 func (f *Forwarder) GetState() ForwarderState {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
@@ -502,6 +620,12 @@ func (f *Forwarder) SetMaxSpatialLayer(spatialLayer int32) (bool, buffer.VideoLa
 	}
 
 	existingMax := f.vls.GetMax()
+	if f.pinnedSpatialLayer != buffer.InvalidLayerSpatial {
+		// takes effect when unpinned
+		f.requestedMaxSpatial = spatialLayer
+		return false, existingMax
+	}
+
 	if spatialLayer == existingMax.Spatial {
 		return false, existingMax
 	}
@@ -511,6 +635,49 @@ func (f *Forwarder) SetMaxSpatialLayer(spatialLayer int32) (bool, buffer.VideoLa
 	return true, f.vls.GetMax()
 }
 
+// SetPinnedSpatialLayer pins forwarding to the given spatial layer, it then is the max spatial layer regardless
+// of what the subscriber requests. buffer.InvalidLayerSpatial unpins and restores the max requested by the subscriber
+func (f *Forwarder) SetPinnedSpatialLayer(spatialLayer int32) (bool, buffer.VideoLayer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.kind == webrtc.RTPCodecTypeAudio {
+		return false, buffer.InvalidLayer
+	}
+
+	if spatialLayer < buffer.InvalidLayerSpatial || spatialLayer > buffer.DefaultMaxLayerSpatial {
+		return false, f.vls.GetMax()
+	}
+
+	if spatialLayer == f.pinnedSpatialLayer {
+		return false, f.vls.GetMax()
+	}
+
+	if f.pinnedSpatialLayer == buffer.InvalidLayerSpatial {
+		f.requestedMaxSpatial = f.vls.GetMax().Spatial
+	}
+	f.pinnedSpatialLayer = spatialLayer
+
+	f.logger.Debugw("setting pinned spatial layer", "layer", spatialLayer)
+	if spatialLayer == buffer.InvalidLayerSpatial {
+		f.vls.SetMaxSpatial(f.requestedMaxSpatial)
+	} else {
+		f.vls.SetMaxSpatial(spatialLayer)
+	}
+	return true, f.vls.GetMax()
+}
+
+func (f *Forwarder) PinnedSpatialLayer() int32 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.pinnedSpatialLayer
+}
+
+func (f *Forwarder) IsSpatialLayerPinned() bool {
+	return f.PinnedSpatialLayer() != buffer.InvalidLayerSpatial
+}
+
 func (f *Forwarder) SetMaxTemporalLayer(temporalLayer int32) (bool, buffer.VideoLayer) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -649,7 +816,8 @@ func (f *Forwarder) GetSenderReportParams() (int32, uint64, *buffer.RTCPSenderRe
 		return buffer.InvalidLayerSpatial, 0, nil
 	}
 
-	return currentLayerSpatial, f.refInfos[refLayer].tsOffset, f.refInfos[refLayer].senderReport
+	// sender reports keep the unshifted mapping, so that receivers apply the sync offset
+	return currentLayerSpatial, f.refInfos[refLayer].tsOffset + f.getSyncOffsetTS(), f.refInfos[refLayer].senderReport
 }
 
 func (f *Forwarder) isDeficientLocked() bool {
@@ -724,11 +892,14 @@ func (f *Forwarder) AllocateOptimal(availableLayers []int32, brs Bitrates, allow
 	}
 	alloc.BandwidthNeeded = optimalBandwidthNeeded
 
-	getMaxTemporal := func() int32 {
+	getMaxTemporal := func(spatial int32) int32 {
 		maxTemporal := maxLayer.Temporal
 		if maxSeenLayer.Temporal != buffer.InvalidLayerTemporal && maxSeenLayer.Temporal < maxTemporal {
 			maxTemporal = maxSeenLayer.Temporal
 		}
+		if f.maxFps > 0 && spatial >= 0 {
+			maxTemporal = min(maxTemporal, temporalLayerForMaxFps(f.layerFps(spatial), f.maxFps))
+		}
 		return maxTemporal
 	}
 
@@ -739,9 +910,10 @@ func (f *Forwarder) AllocateOptimal(availableLayers []int32, brs Bitrates, allow
 			maxSpatial = maxSeenLayer.Spatial
 		}
 
+		spatial := int32(math.Min(float64(maxSeenLayer.Spatial), float64(maxSpatial)))
 		alloc.TargetLayer = buffer.VideoLayer{
-			Spatial:  int32(math.Min(float64(maxSeenLayer.Spatial), float64(maxSpatial))),
-			Temporal: getMaxTemporal(),
+			Spatial:  spatial,
+			Temporal: getMaxTemporal(spatial),
 		}
 	}
 
@@ -793,13 +965,13 @@ func (f *Forwarder) AllocateOptimal(availableLayers []int32, brs Bitrates, allow
 				//   2. current layer resuming - can latch on when it starts
 				alloc.TargetLayer = buffer.VideoLayer{
 					Spatial:  currentLayer.Spatial,
-					Temporal: getMaxTemporal(),
+					Temporal: getMaxTemporal(currentLayer.Spatial),
 				}
 			} else {
 				// current layer has stopped, switch to highest available
 				alloc.TargetLayer = buffer.VideoLayer{
 					Spatial:  requestLayerSpatial,
-					Temporal: getMaxTemporal(),
+					Temporal: getMaxTemporal(requestLayerSpatial),
 				}
 			}
 			alloc.RequestLayerSpatial = alloc.TargetLayer.Spatial
@@ -835,6 +1007,29 @@ func (f *Forwarder) AllocateOptimal(availableLayers []int32, brs Bitrates, allow
 	return f.updateAllocation(alloc, "optimal")
 }
 
+// AllocateLowest allocates the lowest layer with a known bitrate, used to start forwarding fast and let bandwidth
+// estimation move the track up. Returns false if no layer is available
+func (f *Forwarder) AllocateLowest(availableLayers []int32, brs Bitrates) (VideoAllocation, bool) {
+	if f.kind == webrtc.RTPCodecTypeAudio {
+		return f.lastAllocation, false
+	}
+
+	f.ProvisionalAllocatePrepare(availableLayers, brs)
+	for spatial := int32(0); spatial <= buffer.DefaultMaxLayerSpatial; spatial++ {
+		for temporal := int32(0); temporal <= buffer.DefaultMaxLayerTemporal; temporal++ {
+			layer := buffer.VideoLayer{
+				Spatial:  spatial,
+				Temporal: temporal,
+			}
+			if isAllocated, _ := f.ProvisionalAllocate(brs[spatial][temporal], layer, false, false); isAllocated {
+				return f.ProvisionalAllocateCommit(), true
+			}
+		}
+	}
+
+	return f.lastAllocation, false
+}
+
 func (f *Forwarder) ProvisionalAllocatePrepare(availableLayers []int32, bitrates Bitrates) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -1283,7 +1478,9 @@ func (f *Forwarder) AllocateNextHigher(availableChannelCapacity int64, available
 					alloc.IsDeficient = false
 				}
 
-				return true, f.updateAllocation(alloc, "next-higher"), true
+				updated := f.updateAllocation(alloc, "next-higher")
+				// the switch could be held back by the minimum dwell on the current layer
+				return true, updated, updated.TargetLayer != targetLayer
 			}
 		}
 
@@ -1467,6 +1664,8 @@ func (f *Forwarder) updateAllocation(alloc VideoAllocation, reason string) Video
 		alloc.TargetLayer.Temporal = 0
 	}
 
+	alloc = f.applyLayerSwitchMinDwellLocked(alloc)
+
 	if alloc.IsDeficient != f.lastAllocation.IsDeficient ||
 		alloc.PauseReason != f.lastAllocation.PauseReason ||
 		alloc.TargetLayer != f.lastAllocation.TargetLayer ||
@@ -1483,6 +1682,35 @@ func (f *Forwarder) updateAllocation(alloc VideoAllocation, reason string) Video
 	return f.lastAllocation
 }
 
+// applyLayerSwitchMinDwellLocked keeps the current target layer when an allocation would switch to a higher one
+// before the minimum dwell time on it passed. The allocation stays deficient, so that the allocator tries the
+// higher layer again once the bandwidth still allows it. Switching to a pinned spatial layer is not held back.
+func (f *Forwarder) applyLayerSwitchMinDwellLocked(alloc VideoAllocation) VideoAllocation {
+	targetLayer := f.vls.GetTarget()
+	if !alloc.TargetLayer.IsValid() || alloc.TargetLayer == targetLayer {
+		return alloc
+	}
+
+	now := time.Now()
+	if f.layerSwitchMinDwell > 0 &&
+		f.pinnedSpatialLayer == buffer.InvalidLayerSpatial &&
+		targetLayer.IsValid() &&
+		alloc.TargetLayer.GreaterThan(targetLayer) &&
+		now.Sub(f.lastLayerSwitchAt) < f.layerSwitchMinDwell {
+		bandwidthRequested := getBandwidthNeeded(alloc.Bitrates, targetLayer, f.lastAllocation.BandwidthRequested)
+		alloc.BandwidthDelta -= alloc.BandwidthRequested - bandwidthRequested
+		alloc.BandwidthRequested = bandwidthRequested
+		alloc.TargetLayer = targetLayer
+		alloc.RequestLayerSpatial = f.vls.GetRequestSpatial()
+		alloc.DistanceToDesired = f.lastAllocation.DistanceToDesired
+		alloc.IsDeficient = true
+		return alloc
+	}
+
+	f.lastLayerSwitchAt = now
+	return alloc
+}
+
 func (f *Forwarder) setTargetLayer(targetLayer buffer.VideoLayer, requestLayerSpatial int32) {
 	f.vls.SetTarget(targetLayer)
 	if targetLayer.IsValid() {
@@ -1599,6 +1827,9 @@ func (f *Forwarder) processSourceSwitch(extPkt *buffer.ExtPacket, layer int32) e
 		f.started = true
 		f.referenceLayerSpatial = layer
 		f.rtpMunger.SetLastSnTs(extPkt)
+		if syncOffsetTS := f.getSyncOffsetTS(); syncOffsetTS != 0 {
+			f.rtpMunger.UpdateSnTsOffsets(extPkt, 0, syncOffsetTS)
+		}
 		f.codecMunger.SetLast(extPkt)
 
 		f.clearRefSenderReportsLocked()
@@ -1664,7 +1895,7 @@ func (f *Forwarder) processSourceSwitch(extPkt *buffer.ExtPacket, layer int32) e
 	// Ideally, extRefTS and extExpectedTS should be very close and extLastTS should be before both of those.
 	// But, cases like muting/unmuting, clock vagaries, pacing, etc. make them not satisfy those conditions always.
 	rtpMungerState := f.rtpMunger.GetLast()
-	extLastTS := rtpMungerState.ExtLastTS
+	extLastTS := f.getExtLastTS(rtpMungerState)
 	extExpectedTS := extLastTS
 	extRefTS := extLastTS
 	refTS := uint32(extRefTS)
@@ -1721,6 +1952,10 @@ func (f *Forwarder) processSourceSwitch(extPkt *buffer.ExtPacket, layer int32) e
 		}
 	}
 
+	if !f.skipReferenceTS {
+		extRefTS += f.getSyncOffsetTS()
+	}
+
 	bigJump := false
 	var extNextTS uint64
 	if f.lastSSRC == 0 {
@@ -1839,7 +2074,8 @@ func (f *Forwarder) processSourceSwitch(extPkt *buffer.ExtPacket, layer int32) e
 		)
 	}
 
-	f.rtpMunger.UpdateSnTsOffsets(extPkt, 1, extNextTS-extLastTS)
+	// offset is relative to the last timestamp sent even if frames are reordered
+	f.rtpMunger.UpdateSnTsOffsets(extPkt, 1, extNextTS-rtpMungerState.ExtLastTS)
 	f.codecMunger.UpdateOffsets(extPkt)
 	return nil
 }
@@ -1882,9 +2118,41 @@ func (f *Forwarder) getTranslationParamsAudio(extPkt *buffer.ExtPacket, layer in
 		tp.shouldDrop = true
 		return tp, err
 	}
+	if tp.shouldDrop || tp.rtp.snOrdering == SequenceNumberOrderingOutOfOrder {
+		return tp, nil
+	}
+
+	if !f.allowAudioBitsLocked(extPkt) {
+		// update sequence number offset to prevent holes
+		f.rtpMunger.PacketDropped(extPkt)
+		return TranslationParams{shouldDrop: true}, nil
+	}
 	return tp, nil
 }
 
+// should be called with lock held
+func (f *Forwarder) allowAudioBitsLocked(extPkt *buffer.ExtPacket) bool {
+	if f.maxAudioBitrate <= 0 {
+		return true
+	}
+
+	maxBits := float64(f.maxAudioBitrate)
+	if !f.audioBitsValid {
+		f.audioBits = maxBits
+		f.audioBitsValid = true
+	} else if elapsed := extPkt.Arrival - f.audioBitsAt; elapsed > 0 {
+		f.audioBits = min(maxBits, f.audioBits+maxBits*float64(elapsed)/float64(time.Second))
+	}
+	f.audioBitsAt = max(f.audioBitsAt, extPkt.Arrival)
+
+	bits := float64(len(extPkt.Packet.Payload) * 8)
+	if bits > f.audioBits {
+		return false
+	}
+	f.audioBits -= bits
+	return true
+}
+
 // should be called with lock held
 func (f *Forwarder) getTranslationParamsVideo(extPkt *buffer.ExtPacket, layer int32) (TranslationParams, error) {
 	tp := TranslationParams{}
@@ -2028,7 +2296,7 @@ func (f *Forwarder) GetSnTsForBlankFrames(frameRate uint32, numPackets int) ([]S
 		numPackets++
 	}
 
-	extLastTS := f.rtpMunger.GetLast().ExtLastTS
+	extLastTS := f.getExtLastTS(f.rtpMunger.GetLast())
 	extExpectedTS := extLastTS
 	if f.getExpectedRTPTimestamp != nil {
 		tsExt, err := f.getExpectedRTPTimestamp(time.Now())
@@ -2082,6 +2350,63 @@ func getOptimalBandwidthNeeded(muted bool, pubMuted bool, maxPublishedLayer int3
 	return 0
 }
 
+// withLayerTargetBitrates scales the measured bitrates of each spatial layer with a target so that its highest
+// temporal layer needs the target, layers that are not measured stay unavailable
+func withLayerTargetBitrates(brs Bitrates, targets []int64) Bitrates {
+	for s := 0; s < len(targets) && s < len(brs); s++ {
+		if targets[s] <= 0 {
+			continue
+		}
+
+		var top int64
+		for t := len(brs[s]) - 1; t >= 0; t-- {
+			if brs[s][t] > 0 {
+				top = brs[s][t]
+				break
+			}
+		}
+		if top == 0 {
+			continue
+		}
+
+		for t := range brs[s] {
+			brs[s][t] = brs[s][t] * targets[s] / top
+		}
+	}
+	return brs
+}
+
+// withMaxFps marks the temporal layers of each spatial layer whose frame rate exceeds maxFps as unavailable, so that
+// the allocation never targets them, the lowest temporal layer is always kept
+func withMaxFps(brs Bitrates, maxFps uint32, layerFps func(spatial int32) []float32) Bitrates {
+	if maxFps == 0 {
+		return brs
+	}
+
+	for s := range brs {
+		for t := temporalLayerForMaxFps(layerFps(int32(s)), maxFps) + 1; t < int32(len(brs[s])); t++ {
+			brs[s][t] = 0
+		}
+	}
+	return brs
+}
+
+// temporalLayerForMaxFps returns the highest temporal layer with a frame rate at or below maxFps, the lowest layer
+// when all exceed it. All layers are allowed until frame rates have been measured.
+func temporalLayerForMaxFps(layerFps []float32, maxFps uint32) int32 {
+	if len(layerFps) == 0 {
+		return buffer.DefaultMaxLayerTemporal
+	}
+
+	layer := int32(0)
+	for i, f := range layerFps {
+		if f > 0 && f <= float32(maxFps) {
+			layer = int32(i)
+		}
+	}
+	return layer
+}
+
 func getBandwidthNeeded(brs Bitrates, layer buffer.VideoLayer, fallback int64) int64 {
 	if layer.IsValid() && brs[layer.Spatial][layer.Temporal] > 0 {
 		return brs[layer.Spatial][layer.Temporal]
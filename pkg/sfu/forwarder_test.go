@@ -16,14 +16,18 @@ package sfu
 
 import (
 	"testing"
+	"time"
 
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/protocol/logger"
 
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
 	"github.com/livekit/livekit-server/pkg/sfu/testutils"
+	"github.com/livekit/livekit-server/pkg/sfu/videolayerselector"
 )
 
 func disable(f *Forwarder) {
@@ -391,6 +395,52 @@ func TestForwarderAllocateOptimal(t *testing.T) {
 	require.Equal(t, expectedResult, f.lastAllocation)
 }
 
+func TestForwarderAllocateLowest(t *testing.T) {
+	newTestForwarder := func() *Forwarder {
+		f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+		f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
+		f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+		f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+		f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+		return f
+	}
+
+	bitrates := Bitrates{
+		{0, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+	}
+
+	// by default, a new subscription starts at the highest layer
+	f := newTestForwarder()
+	result := f.AllocateOptimal([]int32{0, 1, 2}, bitrates, true)
+	require.Equal(t, buffer.VideoLayer{Spatial: 2, Temporal: 3}, result.TargetLayer)
+
+	// lowest layer with a bitrate to start fast
+	f = newTestForwarder()
+	result, isAllocated := f.AllocateLowest([]int32{0, 1, 2}, bitrates)
+	require.True(t, isAllocated)
+	require.Equal(t, buffer.VideoLayer{Spatial: 0, Temporal: 1}, result.TargetLayer)
+	require.Equal(t, int32(0), result.RequestLayerSpatial)
+	require.Equal(t, bitrates[0][1], result.BandwidthRequested)
+	require.Equal(t, buffer.VideoLayer{Spatial: 0, Temporal: 1}, f.TargetLayer())
+	// below optimal, bandwidth estimation moves it up
+	require.Greater(t, result.DistanceToDesired, 0.0)
+	require.True(t, f.IsDeficient())
+
+	// nothing to start on without bitrates
+	f = newTestForwarder()
+	_, isAllocated = f.AllocateLowest(nil, Bitrates{})
+	require.False(t, isAllocated)
+	require.Equal(t, buffer.InvalidLayer, f.TargetLayer())
+
+	// or when muted
+	f = newTestForwarder()
+	f.Mute(true, true)
+	_, isAllocated = f.AllocateLowest([]int32{0, 1, 2}, bitrates)
+	require.False(t, isAllocated)
+}
+
 func TestForwarderProvisionalAllocate(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 	f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
@@ -611,6 +661,310 @@ func TestForwarderProvisionalAllocate(t *testing.T) {
 	require.Equal(t, buffer.InvalidLayer, f.CurrentLayer())
 }
 
+func TestForwarderLayerTargetBitrates(t *testing.T) {
+	bitrates := Bitrates{
+		{100, 200, 300, 400},
+		{500, 600, 700, 800},
+		{900, 1000, 1100, 1200},
+	}
+
+	// measured bitrates of each layer with a target are scaled to the target
+	targets := []int64{0, 400, 600}
+	brs := withLayerTargetBitrates(bitrates, targets)
+	require.Equal(t, Bitrates{
+		{100, 200, 300, 400},
+		{250, 300, 350, 400},
+		{450, 500, 550, 600},
+	}, brs)
+
+	// layers not measured stay unavailable
+	require.Equal(t, Bitrates{{}, {0, 100, 0, 0}}, withLayerTargetBitrates(Bitrates{{}, {0, 200, 0, 0}}, targets))
+
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+	f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+
+	// measured bitrate of the highest layer does not fit
+	f.ProvisionalAllocatePrepare(nil, bitrates)
+	isCandidate, _ := f.ProvisionalAllocate(600, buffer.VideoLayer{Spatial: 2, Temporal: 3}, true, false)
+	require.False(t, isCandidate)
+
+	// target bitrate of the highest layer fits
+	f.ProvisionalAllocatePrepare(nil, brs)
+	isCandidate, usedBitrate := f.ProvisionalAllocate(600, buffer.VideoLayer{Spatial: 2, Temporal: 3}, true, false)
+	require.True(t, isCandidate)
+	require.Equal(t, int64(600), usedBitrate)
+
+	result := f.ProvisionalAllocateCommit()
+	require.Equal(t, buffer.VideoLayer{Spatial: 2, Temporal: 3}, result.TargetLayer)
+	require.Equal(t, int64(600), result.BandwidthRequested)
+	require.False(t, result.IsDeficient)
+}
+
+func TestTemporalLayerForMaxFps(t *testing.T) {
+	layerFps := []float32{7.5, 15, 30, 0}
+
+	// forwarded frame rate does not exceed the cap
+	require.Equal(t, int32(2), temporalLayerForMaxFps(layerFps, 30))
+	require.Equal(t, int32(1), temporalLayerForMaxFps(layerFps, 20))
+	require.Equal(t, int32(1), temporalLayerForMaxFps(layerFps, 15))
+	require.Equal(t, int32(0), temporalLayerForMaxFps(layerFps, 10))
+
+	// lowest layer when every layer exceeds the cap
+	require.Equal(t, int32(0), temporalLayerForMaxFps(layerFps, 5))
+
+	// not capped until frame rates are measured
+	require.Equal(t, buffer.DefaultMaxLayerTemporal, temporalLayerForMaxFps(nil, 5))
+}
+
+func TestForwarderMaxAudioBitrate(t *testing.T) {
+	f := newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
+	// 100 bytes every 20 ms is 40 kbps
+	f.SetMaxAudioBitrate(32000)
+
+	start := time.Now()
+	sn := uint16(23333)
+	forward := func(at time.Duration) TranslationParams {
+		extPkt, _ := testutils.GetTestExtPacket(&testutils.TestExtPacketParams{
+			SequenceNumber: sn,
+			Timestamp:      uint32(sn) * 960,
+			SSRC:           0x12345678,
+			PayloadSize:    100,
+			ArrivalTime:    start.Add(at),
+		})
+		sn++
+		tp, err := f.GetTranslationParams(extPkt, 0)
+		require.NoError(t, err)
+		return tp
+	}
+
+	// a second of burst is forwarded, then packets over the cap are dropped without leaving holes
+	var forwarded, dropped int
+	var lastSN uint64
+	for i := 0; i < 250; i++ {
+		tp := forward(time.Duration(i) * 20 * time.Millisecond)
+		if tp.shouldDrop {
+			dropped++
+			continue
+		}
+		if forwarded != 0 {
+			require.Equal(t, lastSN+1, tp.rtp.extSequenceNumber)
+		}
+		lastSN = tp.rtp.extSequenceNumber
+		forwarded++
+	}
+	require.NotZero(t, dropped)
+	// 5 seconds at the cap and the burst
+	require.InDelta(t, 6*32000/800, forwarded, 2)
+
+	// not capped
+	f.SetMaxAudioBitrate(0)
+	for i := 0; i < 50; i++ {
+		require.False(t, forward(5*time.Second+time.Duration(i)*time.Millisecond).shouldDrop)
+	}
+}
+
+func TestForwarderMaxFps(t *testing.T) {
+	measuredFps := [][]float32{
+		{7.5, 15, 30, 0},
+		{7.5, 15, 30, 0},
+		nil,
+	}
+	layerFps := func(spatial int32) []float32 {
+		return measuredFps[spatial]
+	}
+	bitrates := Bitrates{
+		{1, 2, 3, 0},
+		{5, 6, 7, 0},
+		{9, 10, 11, 0},
+	}
+
+	// temporal layers above the cap are unavailable, spatial layers without measured frame rates are not capped
+	brs := withMaxFps(bitrates, 15, layerFps)
+	require.Equal(t, Bitrates{
+		{1, 2, 0, 0},
+		{5, 6, 0, 0},
+		{9, 10, 11, 0},
+	}, brs)
+	require.Equal(t, bitrates, withMaxFps(bitrates, 0, layerFps))
+
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+	f.SetMaxSpatialLayer(1)
+	f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+	f.SetMaxFps(15, layerFps)
+
+	// optimal allocation is capped without subscriber settings
+	result := f.AllocateOptimal([]int32{0, 1}, brs, false)
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: 1}, result.TargetLayer)
+
+	// and follows the measured frame rates
+	measuredFps[1] = []float32{15, 30, 60, 0}
+	result = f.AllocateOptimal([]int32{0, 1}, withMaxFps(bitrates, 15, layerFps), false)
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: 0}, result.TargetLayer)
+	measuredFps[1] = []float32{7.5, 15, 30, 0}
+
+	// allocations under a constraint move to the next spatial layer instead of a temporal layer above the cap
+	f.ProvisionalAllocatePrepare(nil, brs)
+	isCandidate, _ := f.ProvisionalAllocate(brs[0][0], buffer.VideoLayer{Spatial: 0, Temporal: 0}, true, false)
+	require.True(t, isCandidate)
+	result = f.ProvisionalAllocateCommit()
+	require.Equal(t, buffer.VideoLayer{Spatial: 0, Temporal: 0}, result.TargetLayer)
+	require.True(t, result.IsDeficient)
+
+	expectedLayers := []buffer.VideoLayer{
+		{Spatial: 0, Temporal: 1},
+		{Spatial: 1, Temporal: 0},
+		{Spatial: 1, Temporal: 1},
+	}
+	for _, expectedLayer := range expectedLayers {
+		f.vls.SetCurrent(f.vls.GetTarget())
+		var boosted bool
+		result, boosted = f.AllocateNextHigher(brs[1][1], nil, brs, false)
+		require.True(t, boosted)
+		require.Equal(t, expectedLayer, result.TargetLayer)
+	}
+	require.False(t, result.IsDeficient)
+}
+
+func TestForwarderLayerSwitchMinDwell(t *testing.T) {
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+	f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+	f.SetLayerSwitchMinDwell(time.Minute)
+
+	bitrates := Bitrates{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+	}
+	lowLayer := buffer.VideoLayer{Spatial: 0, Temporal: 0}
+	highLayer := buffer.VideoLayer{Spatial: 2, Temporal: 3}
+
+	// allocates the highest layer fitting the channel capacity
+	allocate := func(capacity int64) VideoAllocation {
+		f.ProvisionalAllocatePrepare(nil, bitrates)
+		isCandidate, _ := f.ProvisionalAllocate(capacity, highLayer, true, false)
+		if !isCandidate {
+			isCandidate, _ = f.ProvisionalAllocate(capacity, lowLayer, true, false)
+			require.True(t, isCandidate)
+		}
+		return f.ProvisionalAllocateCommit()
+	}
+	elapse := func() {
+		f.lastLayerSwitchAt = f.lastLayerSwitchAt.Add(-time.Minute)
+	}
+
+	// starting is not held back
+	result := allocate(bitrates[2][3])
+	require.Equal(t, highLayer, result.TargetLayer)
+
+	// switching down is not held back either
+	result = allocate(bitrates[0][0])
+	require.Equal(t, lowLayer, result.TargetLayer)
+	require.Equal(t, lowLayer, f.TargetLayer())
+
+	// oscillating bandwidth does not switch up before the dwell time passed
+	for i := 0; i < 5; i++ {
+		result = allocate(bitrates[2][3])
+		require.Equal(t, lowLayer, result.TargetLayer)
+		require.Equal(t, lowLayer.Spatial, result.RequestLayerSpatial)
+		require.Equal(t, bitrates[0][0], result.BandwidthRequested)
+		require.Zero(t, result.BandwidthDelta)
+		require.True(t, result.IsDeficient)
+		require.Equal(t, lowLayer, f.TargetLayer())
+
+		result = allocate(bitrates[0][0])
+		require.Equal(t, lowLayer, result.TargetLayer)
+	}
+
+	// switches up after dwelling on the layer
+	elapse()
+	result = allocate(bitrates[2][3])
+	require.Equal(t, highLayer, result.TargetLayer)
+	require.Equal(t, bitrates[2][3], result.BandwidthRequested)
+	require.False(t, result.IsDeficient)
+
+	// probing up is held back too and reported as not boosted
+	result = allocate(bitrates[0][0])
+	require.Equal(t, lowLayer, result.TargetLayer)
+	f.vls.SetCurrent(lowLayer)
+	result, boosted := f.AllocateNextHigher(bitrates[2][3], nil, bitrates, false)
+	require.False(t, boosted)
+	require.Equal(t, lowLayer, result.TargetLayer)
+
+	elapse()
+	result, boosted = f.AllocateNextHigher(bitrates[2][3], nil, bitrates, false)
+	require.True(t, boosted)
+	require.Equal(t, buffer.VideoLayer{Spatial: 0, Temporal: 1}, result.TargetLayer)
+}
+
+func TestForwarderPinnedSpatialLayer(t *testing.T) {
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+	f.SetMaxSpatialLayer(0)
+	f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+	f.SetLayerSwitchMinDwell(time.Minute)
+	require.False(t, f.IsSpatialLayerPinned())
+
+	bitrates := Bitrates{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+	}
+	lowLayer := buffer.VideoLayer{Spatial: 0, Temporal: 3}
+	pinnedLayer := buffer.VideoLayer{Spatial: 2, Temporal: 3}
+
+	f.vls.SetTarget(lowLayer)
+	f.vls.SetRequestSpatial(lowLayer.Spatial)
+	f.vls.SetCurrent(lowLayer)
+	f.lastLayerSwitchAt = time.Now()
+
+	// out of range layers are ignored
+	changed, _ := f.SetPinnedSpatialLayer(buffer.DefaultMaxLayerSpatial + 1)
+	require.False(t, changed)
+
+	changed, maxLayer := f.SetPinnedSpatialLayer(pinnedLayer.Spatial)
+	require.True(t, changed)
+	require.Equal(t, pinnedLayer, maxLayer)
+	require.True(t, f.IsSpatialLayerPinned())
+	require.Equal(t, pinnedLayer.Spatial, f.PinnedSpatialLayer())
+
+	// subscriber requests do not override the pinned layer
+	changed, maxLayer = f.SetMaxSpatialLayer(1)
+	require.False(t, changed)
+	require.Equal(t, pinnedLayer, maxLayer)
+	require.Equal(t, pinnedLayer, f.MaxLayer())
+
+	// switches to the pinned layer right away, not held back by the dwell time
+	result := f.AllocateOptimal([]int32{0, 1, 2}, bitrates, true)
+	require.Equal(t, pinnedLayer, result.TargetLayer)
+	require.Equal(t, pinnedLayer.Spatial, result.RequestLayerSpatial)
+	require.Equal(t, bitrates[2][3], result.BandwidthRequested)
+	require.Equal(t, pinnedLayer, f.TargetLayer())
+
+	// unpinning restores the layer last requested by the subscriber
+	changed, maxLayer = f.SetPinnedSpatialLayer(buffer.InvalidLayerSpatial)
+	require.True(t, changed)
+	require.Equal(t, buffer.VideoLayer{Spatial: 1, Temporal: buffer.DefaultMaxLayerTemporal}, maxLayer)
+	require.False(t, f.IsSpatialLayerPinned())
+
+	changed, _ = f.SetPinnedSpatialLayer(buffer.InvalidLayerSpatial)
+	require.False(t, changed)
+
+	// audio cannot be pinned
+	f = newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
+	changed, maxLayer = f.SetPinnedSpatialLayer(0)
+	require.False(t, changed)
+	require.Equal(t, buffer.InvalidLayer, maxLayer)
+}
+
 func TestForwarderProvisionalAllocateMute(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 	f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
@@ -1367,6 +1721,39 @@ func TestForwarderGetTranslationParamsAudio(t *testing.T) {
 	require.Equal(t, f.lastSSRC, params.SSRC)
 }
 
+func TestForwarderSyncOffset(t *testing.T) {
+	for _, offset := range []time.Duration{20 * time.Millisecond, -10 * time.Millisecond} {
+		f := newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
+		f.SetSyncOffset(offset)
+		offsetTS := uint64(offset.Nanoseconds() * int64(testutils.TestOpusCodec.ClockRate) / 1e9)
+
+		for i := 0; i < 3; i++ {
+			params := &testutils.TestExtPacketParams{
+				SequenceNumber: 23333 + uint16(i),
+				Timestamp:      0xabcdef + uint32(i*960),
+				SSRC:           0x12345678,
+				PayloadSize:    20,
+			}
+			extPkt, _ := testutils.GetTestExtPacket(params)
+
+			// forwarded timestamps are shifted by the offset
+			expectedTP := TranslationParams{
+				rtp: TranslationParamsRTP{
+					snOrdering:        SequenceNumberOrderingContiguous,
+					extSequenceNumber: 23333 + uint64(i),
+					extTimestamp:      0xabcdef + uint64(i*960) + offsetTS,
+				},
+			}
+			actualTP, err := f.GetTranslationParams(extPkt, 0)
+			require.NoError(t, err)
+			require.Equal(t, expectedTP, actualTP)
+		}
+
+		// while the offset reported for sender reports is not
+		require.Zero(t, f.rtpMunger.GetTSOffset()+f.getSyncOffsetTS())
+	}
+}
+
 func TestForwarderGetTranslationParamsVideo(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
@@ -1928,6 +2315,68 @@ func TestForwarderGetSnTsForBlankFrames(t *testing.T) {
 	require.Equal(t, sntsExpected, snts)
 }
 
+func TestForwarderReorderedFrames(t *testing.T) {
+	// frames in decode order, I0 P3 B1 B2, at 30 fps
+	frames := []testutils.TestExtPacketParams{
+		{SequenceNumber: 23333, Timestamp: 0xabcdef, IsKeyFrame: true},
+		{SequenceNumber: 23334, Timestamp: 0xabcdef + 9000},
+		{SequenceNumber: 23335, Timestamp: 0xabcdef + 3000},
+		{SequenceNumber: 23336, Timestamp: 0xabcdef + 6000},
+	}
+
+	forward := func(t *testing.T, reorderedFrameCodecs []string) *Forwarder {
+		f := newForwarder(testutils.TestH264Codec, webrtc.RTPCodecTypeVideo)
+		f.SetReorderedFrameCodecs(reorderedFrameCodecs)
+		f.vls.SetTarget(buffer.VideoLayer{Spatial: 0, Temporal: 0})
+		f.vls.SetCurrent(buffer.InvalidLayer)
+
+		for _, frame := range frames {
+			frame.SetMarker = true
+			frame.SSRC = 0x12345678
+			frame.PayloadSize = 20
+			extPkt, err := testutils.GetTestExtPacket(&frame)
+			require.NoError(t, err)
+
+			// forwarded in arrival order, as published
+			tp, err := f.GetTranslationParams(extPkt, 0)
+			require.NoError(t, err)
+			require.False(t, tp.shouldDrop)
+			require.Equal(t, uint64(frame.SequenceNumber), tp.rtp.extSequenceNumber)
+			require.Equal(t, uint64(frame.Timestamp), tp.rtp.extTimestamp)
+		}
+		return f
+	}
+
+	// blank frames at 60 fps
+	frameDuration := uint64(testutils.TestH264Codec.ClockRate / 60)
+
+	t.Run("in order", func(t *testing.T) {
+		f := forward(t, nil)
+
+		// follows the last frame, behind the P-frame already sent
+		snts, frameEndNeeded, err := f.GetSnTsForBlankFrames(60, 1)
+		require.NoError(t, err)
+		require.False(t, frameEndNeeded)
+		require.Equal(t, []SnTs{{extSequenceNumber: 23337, extTimestamp: 0xabcdef + 6000 + 1 + frameDuration}}, snts)
+	})
+
+	t.Run("reordered", func(t *testing.T) {
+		f := forward(t, []string{"video/h264"})
+
+		// follows the highest timestamp sent
+		snts, frameEndNeeded, err := f.GetSnTsForBlankFrames(60, 1)
+		require.NoError(t, err)
+		require.False(t, frameEndNeeded)
+		require.Equal(t, []SnTs{{extSequenceNumber: 23337, extTimestamp: 0xabcdef + 9000 + 1 + frameDuration}}, snts)
+
+		// only for the configured codecs
+		f = forward(t, []string{"video/h265"})
+		snts, _, err = f.GetSnTsForBlankFrames(60, 1)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0xabcdef+6000+1)+frameDuration, snts[0].extTimestamp)
+	})
+}
+
 func TestForwarderGetPaddingVP8(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
@@ -2007,3 +2456,131 @@ func TestForwarderGetPaddingVP8(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, marshalledVP8, buf)
 }
+
+func TestForwarderSVCLayerCaps(t *testing.T) {
+	caps := map[string]buffer.VideoLayer{
+		"video/av1": {Spatial: 1, Temporal: 1},
+		"video/vp9": {Spatial: buffer.InvalidLayerSpatial, Temporal: 0},
+	}
+	extensions := []webrtc.RTPHeaderExtensionParameter{{URI: dd.ExtensionURI, ID: 8}}
+	bitrates := Bitrates{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+	}
+
+	testCases := []struct {
+		codec    webrtc.RTPCodecCapability
+		expected buffer.VideoLayer
+	}{
+		{
+			codec:    webrtc.RTPCodecCapability{MimeType: "video/AV1", ClockRate: 90000},
+			expected: buffer.VideoLayer{Spatial: 1, Temporal: 1},
+		},
+		{
+			codec:    webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000},
+			expected: buffer.VideoLayer{Spatial: 2, Temporal: 0},
+		},
+	}
+	for _, tc := range testCases {
+		f := NewForwarder(webrtc.RTPCodecTypeVideo, logger.GetLogger(), true, nil)
+		f.SetSVCLayerCaps(caps)
+		f.DetermineCodec(tc.codec, extensions)
+		require.IsType(t, &videolayerselector.DependencyDescriptor{}, f.vls)
+
+		f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
+		f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+		f.SetMaxPublishedLayer(buffer.DefaultMaxLayerSpatial)
+		f.SetMaxTemporalLayerSeen(buffer.DefaultMaxLayerTemporal)
+		require.Equal(t, tc.expected, f.MaxLayer())
+
+		result := f.AllocateOptimal([]int32{0, 1, 2}, bitrates, true)
+		require.Equal(t, tc.expected, result.TargetLayer)
+		require.Equal(t, bitrates[tc.expected.Spatial][tc.expected.Temporal], result.BandwidthRequested)
+	}
+
+	// not capped without a cap for the codec
+	f := NewForwarder(webrtc.RTPCodecTypeVideo, logger.GetLogger(), true, nil)
+	f.SetSVCLayerCaps(caps)
+	f.DetermineCodec(testutils.TestVP8Codec, extensions)
+	f.SetMaxSpatialLayer(buffer.DefaultMaxLayerSpatial)
+	f.SetMaxTemporalLayer(buffer.DefaultMaxLayerTemporal)
+	require.Equal(t, buffer.VideoLayer{Spatial: buffer.DefaultMaxLayerSpatial, Temporal: buffer.DefaultMaxLayerTemporal}, f.MaxLayer())
+}
+
+func TestForwarderPaddingPolicyThroughBuffer(t *testing.T) {
+	// writes a media packet, a padding only packet and a media packet to a buffer set up like the receiver does
+	// and returns the translation of the packets read from the buffer
+	forward := func(policy PaddingPolicy) []TranslationParams {
+		codec := webrtc.RTPCodecParameters{RTPCodecCapability: testutils.TestOpusCodec, PayloadType: 111}
+		buff := buffer.NewBuffer(123, 100, 100)
+		buff.SetReceiverReportInterval(time.Hour)
+		buff.SetForwardPadding(policy != PaddingPolicyStripContiguous)
+		buff.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{codec}}, codec.RTPCodecCapability, 0)
+		defer buff.Close()
+
+		f := newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
+		f.SetPaddingPolicy(policy)
+
+		for sn := uint16(1); sn <= 3; sn++ {
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    111,
+					SequenceNumber: sn,
+					Timestamp:      uint32(sn) * 960,
+					SSRC:           123,
+				},
+				Payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1},
+			}
+			if sn == 2 {
+				pkt.Header.Padding = true
+				pkt.PaddingSize = 255
+				pkt.Payload = nil
+			}
+			b, err := pkt.Marshal()
+			require.NoError(t, err)
+			_, err = buff.Write(b)
+			require.NoError(t, err)
+		}
+
+		numPackets := 3
+		if policy == PaddingPolicyStripContiguous {
+			// padding only packet does not make it out of the buffer
+			numPackets = 2
+		}
+		var tps []TranslationParams
+		for i := 0; i < numPackets; i++ {
+			extPkt, err := buff.ReadExtended(make([]byte, 1500))
+			require.NoError(t, err)
+			tp, err := f.GetTranslationParams(extPkt, 0)
+			require.NoError(t, err)
+			tps = append(tps, tp)
+		}
+		return tps
+	}
+
+	// gap of the dropped padding only packet is hidden
+	tps := forward(PaddingPolicyStripContiguous)
+	require.Len(t, tps, 2)
+	require.False(t, tps[1].shouldDrop)
+	require.Equal(t, SequenceNumberOrderingContiguous, tps[1].rtp.snOrdering)
+	require.Equal(t, tps[0].rtp.extSequenceNumber+1, tps[1].rtp.extSequenceNumber)
+
+	// padding only packet reaches the down track and is dropped there
+	tps = forward(PaddingPolicyStrip)
+	require.Len(t, tps, 3)
+	require.True(t, tps[1].shouldDrop)
+	require.False(t, tps[2].shouldDrop)
+	require.Equal(t, SequenceNumberOrderingContiguous, tps[2].rtp.snOrdering)
+	require.Equal(t, tps[0].rtp.extSequenceNumber+1, tps[2].rtp.extSequenceNumber)
+
+	// padding only packet is forwarded
+	tps = forward(PaddingPolicyForward)
+	require.Len(t, tps, 3)
+	for i := 1; i < 3; i++ {
+		require.False(t, tps[i].shouldDrop)
+		require.Equal(t, SequenceNumberOrderingContiguous, tps[i].rtp.snOrdering)
+		require.Equal(t, tps[0].rtp.extSequenceNumber+uint64(i), tps[i].rtp.extSequenceNumber)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"time"
+)
+
+type LossFallbackAction int
+
+const (
+	// LossFallbackActionNone keeps relying on retransmissions and redundancy
+	LossFallbackActionNone LossFallbackAction = iota
+	// LossFallbackActionKeyFrame requests a key frame from the publisher, so that the subscriber can resume decoding
+	LossFallbackActionKeyFrame
+	// LossFallbackActionLowerLayer forwards the next lower spatial layer
+	LossFallbackActionLowerLayer
+)
+
+func (a LossFallbackAction) String() string {
+	switch a {
+	case LossFallbackActionNone:
+		return "NONE"
+	case LossFallbackActionKeyFrame:
+		return "KEY_FRAME"
+	case LossFallbackActionLowerLayer:
+		return "LOWER_LAYER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type LossFallbackParams struct {
+	Action LossFallbackAction
+	// fraction of packets lost, 0 to 1, in subscriber receiver reports considered as loss
+	Threshold float64
+	// time loss has to be reported continuously before falling back
+	Duration time.Duration
+}
+
+// lossFallback detects loss that is not recovered, reported by a subscriber for longer than the configured duration
+type lossFallback struct {
+	params    LossFallbackParams
+	lossSince time.Time
+}
+
+func newLossFallback(params LossFallbackParams) *lossFallback {
+	return &lossFallback{
+		params: params,
+	}
+}
+
+// update takes the fraction lost of a receiver report, returns true when the fallback should be applied.
+// Sustained loss falls back again after every duration it persists.
+func (l *lossFallback) update(fractionLost uint8, at time.Time) bool {
+	if float64(fractionLost)/256.0 < l.params.Threshold {
+		l.lossSince = time.Time{}
+		return false
+	}
+
+	if l.lossSince.IsZero() {
+		l.lossSince = at
+		return false
+	}
+
+	if at.Sub(l.lossSince) < l.params.Duration {
+		return false
+	}
+
+	l.lossSince = at
+	return true
+}
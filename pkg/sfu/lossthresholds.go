@@ -0,0 +1,59 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+// LossThresholdCrossing is a loss threshold crossed by the fraction lost of a subscriber receiver report
+type LossThresholdCrossing struct {
+	// fraction of packets lost, 0 to 1, configured as threshold
+	Threshold float64
+	// true when loss rose to or above the threshold, false when it fell back below
+	Above bool
+	// fraction of packets lost, 0 to 1, in the receiver report that crossed the threshold
+	FractionLost float64
+}
+
+// lossThresholds tracks which of a set of ascending loss thresholds the latest receiver report is at or above
+type lossThresholds struct {
+	thresholds []float64
+	// number of thresholds at or below the loss of the latest report
+	level int
+}
+
+func newLossThresholds(thresholds []float64) *lossThresholds {
+	return &lossThresholds{
+		thresholds: thresholds,
+	}
+}
+
+// update takes the fraction lost of a receiver report, returns the thresholds crossed since the previous report,
+// nearest to the previous loss first
+func (l *lossThresholds) update(fractionLost uint8) []LossThresholdCrossing {
+	loss := float64(fractionLost) / 256.0
+
+	level := 0
+	for level < len(l.thresholds) && loss >= l.thresholds[level] {
+		level++
+	}
+
+	var crossings []LossThresholdCrossing
+	for i := l.level; i < level; i++ {
+		crossings = append(crossings, LossThresholdCrossing{Threshold: l.thresholds[i], Above: true, FractionLost: loss})
+	}
+	for i := l.level - 1; i >= level; i-- {
+		crossings = append(crossings, LossThresholdCrossing{Threshold: l.thresholds[i], Above: false, FractionLost: loss})
+	}
+	l.level = level
+	return crossings
+}
@@ -21,12 +21,42 @@ import (
 
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/rtp"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
+const (
+	// RFC 8285 one-byte headers carry ids 1-14 with up to 16 bytes of payload
+	maxOneByteHeaderExtensionID   = 14
+	maxOneByteHeaderExtensionSize = 16
+
+	extensionProfileTwoByte = 0x1000
+
+	// bytes of a datagram not visible to the pacer: IPv6 and UDP headers, SRTP authentication tag
+	// and the transport-cc extension added by the interceptor after the pacer
+	mtuOverhead = 40 + 8 + 10 + 8
+	// RTP fixed header with room for the header extensions set by the pacer
+	minRTPHeaderSize = 12 + 64
+	// a full padding packet is the smallest payload that has to fit
+	minRTPPayloadSize = 255
+
+	// MinMTU is the smallest MTU that leaves room for a minimum viable RTP packet
+	MinMTU = mtuOverhead + minRTPHeaderSize + minRTPPayloadSize
+)
+
+var ErrPacketTooLarge = errors.New("packet exceeds MTU")
+
 type Base struct {
 	logger logger.Logger
 
 	packetTime *PacketTime
+
+	maxPacketSize   atomic.Int32
+	packetsTooLarge atomic.Uint32
+
+	maxQueuedPackets atomic.Int32
+	packetsDropped   atomic.Uint32
 }
 
 func NewBase(logger logger.Logger) *Base {
@@ -42,6 +72,43 @@ func (b *Base) SetInterval(_interval time.Duration) {
 func (b *Base) SetBitrate(_bitrate int) {
 }
 
+// SetMTU caps the size of datagrams sent, 0 means no limit.
+// Packets are not re-fragmented, ones that do not fit are dropped, counted and logged.
+func (b *Base) SetMTU(mtu int) {
+	if mtu <= 0 {
+		b.maxPacketSize.Store(0)
+		return
+	}
+	b.maxPacketSize.Store(int32(mtu - mtuOverhead))
+}
+
+// SetMaxQueuedPackets caps the packets waiting to be sent by pacers that queue them, 0 means no limit.
+// The oldest packets are dropped once the queue is full, a slow subscriber gets the most recent media.
+func (b *Base) SetMaxQueuedPackets(maxPackets int) {
+	b.maxQueuedPackets.Store(int32(max(maxPackets, 0)))
+}
+
+// isQueueFull returns true when a queue of the given length cannot take another packet
+func (b *Base) isQueueFull(queued int) bool {
+	maxQueued := int(b.maxQueuedPackets.Load())
+	return maxQueued > 0 && queued >= maxQueued
+}
+
+// dropPacket releases a queued packet that is not sent
+func (b *Base) dropPacket(p *Packet) {
+	if p.Pool != nil && p.PoolEntity != nil {
+		p.Pool.Put(p.PoolEntity)
+	}
+
+	if count := b.packetsDropped.Inc(); count%100 == 1 {
+		b.logger.Infow(
+			"dropping queued packet, send queue full",
+			"maxQueuedPackets", b.maxQueuedPackets.Load(),
+			"count", count,
+		)
+	}
+}
+
 func (b *Base) SendPacket(p *Packet) (int, error) {
 	defer func() {
 		if p.Pool != nil && p.PoolEntity != nil {
@@ -55,6 +122,21 @@ func (b *Base) SendPacket(p *Packet) (int, error) {
 		return 0, err
 	}
 
+	if maxPacketSize := int(b.maxPacketSize.Load()); maxPacketSize > 0 {
+		if size := p.Header.MarshalSize() + len(p.Payload); size > maxPacketSize {
+			prometheus.IncrementMTUExceeded()
+			if count := b.packetsTooLarge.Inc(); count%100 == 1 {
+				b.logger.Warnw(
+					"dropping packet exceeding MTU", ErrPacketTooLarge,
+					"size", size,
+					"maxPacketSize", maxPacketSize,
+					"count", count,
+				)
+			}
+			return 0, ErrPacketTooLarge
+		}
+	}
+
 	var written int
 	written, err = p.WriteStream.WriteRTP(p.Header, p.Payload)
 	if err != nil {
@@ -74,6 +156,12 @@ func (b *Base) writeRTPHeaderExtensions(p *Packet) (time.Time, error) {
 	p.Header.ExtensionProfile = 0
 	p.Header.Extensions = []rtp.Extension{}
 
+	// profile is decided by the first extension set, pick two-byte headers up front if any extension needs it
+	if needsTwoByteHeaderExtensions(p) {
+		p.Header.Extension = true
+		p.Header.ExtensionProfile = extensionProfileTwoByte
+	}
+
 	for _, ext := range p.Extensions {
 		if ext.ID == 0 || len(ext.Payload) == 0 {
 			continue
@@ -99,4 +187,19 @@ func (b *Base) writeRTPHeaderExtensions(p *Packet) (time.Time, error) {
 	return sendingAt, nil
 }
 
+func needsTwoByteHeaderExtensions(p *Packet) bool {
+	if p.AbsSendTimeExtID > maxOneByteHeaderExtensionID || p.TransportWideExtID > maxOneByteHeaderExtensionID {
+		return true
+	}
+	for _, ext := range p.Extensions {
+		if ext.ID == 0 || len(ext.Payload) == 0 {
+			continue
+		}
+		if ext.ID > maxOneByteHeaderExtensionID || len(ext.Payload) > maxOneByteHeaderExtensionSize {
+			return true
+		}
+	}
+	return false
+}
+
 // ------------------------------------------------
@@ -0,0 +1,153 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+func TestWriteRTPHeaderExtensions(t *testing.T) {
+	roundTrip := func(t *testing.T, hdr *rtp.Header) *rtp.Header {
+		buf, err := (&rtp.Packet{Header: *hdr, Payload: []byte{0xff}}).Marshal()
+		require.NoError(t, err)
+
+		var parsed rtp.Packet
+		require.NoError(t, parsed.Unmarshal(buf))
+		return &parsed.Header
+	}
+
+	tests := []struct {
+		name       string
+		extensions []ExtensionData
+		profile    uint16
+	}{
+		{
+			name: "one-byte",
+			extensions: []ExtensionData{
+				{ID: 1, Payload: []byte{0x01}},
+				{ID: 14, Payload: bytes.Repeat([]byte{0x02}, 16)},
+			},
+			profile: 0xBEDE,
+		},
+		{
+			name: "id above one-byte range",
+			extensions: []ExtensionData{
+				{ID: 1, Payload: []byte{0x01}},
+				{ID: 15, Payload: []byte{0x02, 0x03}},
+			},
+			profile: extensionProfileTwoByte,
+		},
+		{
+			name: "payload above one-byte size",
+			extensions: []ExtensionData{
+				{ID: 1, Payload: []byte{0x01}},
+				{ID: 2, Payload: bytes.Repeat([]byte{0x02}, 17)},
+			},
+			profile: extensionProfileTwoByte,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBase(logger.GetLogger())
+			p := &Packet{
+				Header:     &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 1234},
+				Extensions: tt.extensions,
+			}
+			_, err := b.writeRTPHeaderExtensions(p)
+			require.NoError(t, err)
+			require.Equal(t, tt.profile, p.Header.ExtensionProfile)
+
+			parsed := roundTrip(t, p.Header)
+			require.Equal(t, tt.profile, parsed.ExtensionProfile)
+			for _, ext := range tt.extensions {
+				require.Equal(t, ext.Payload, parsed.GetExtension(ext.ID))
+			}
+		})
+	}
+
+	t.Run("abs-send-time above one-byte range", func(t *testing.T) {
+		b := NewBase(logger.GetLogger())
+		p := &Packet{
+			Header:           &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 1234},
+			Extensions:       []ExtensionData{{ID: 1, Payload: []byte{0x01}}},
+			AbsSendTimeExtID: 20,
+		}
+		_, err := b.writeRTPHeaderExtensions(p)
+		require.NoError(t, err)
+
+		parsed := roundTrip(t, p.Header)
+		require.Equal(t, []byte{0x01}, parsed.GetExtension(1))
+		require.Len(t, parsed.GetExtension(20), 3)
+	})
+}
+
+type sizeRecordingWriter struct {
+	sizes []int
+}
+
+func (w *sizeRecordingWriter) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	size := header.MarshalSize() + len(payload)
+	w.sizes = append(w.sizes, size)
+	return size, nil
+}
+
+func (w *sizeRecordingWriter) Write(b []byte) (int, error) {
+	w.sizes = append(w.sizes, len(b))
+	return len(b), nil
+}
+
+func TestSendPacketMTU(t *testing.T) {
+	mtu := 1200
+	w := &sizeRecordingWriter{}
+	b := NewBase(logger.GetLogger())
+	b.SetMTU(mtu)
+
+	var dropped int
+	for payloadSize := minRTPPayloadSize; payloadSize <= 1500; payloadSize += 50 {
+		_, err := b.SendPacket(&Packet{
+			Header:             &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, SSRC: 1234},
+			Extensions:         []ExtensionData{{ID: 1, Payload: []byte{0x01, 0x02}}},
+			Payload:            make([]byte, payloadSize),
+			AbsSendTimeExtID:   2,
+			TransportWideExtID: 3,
+			WriteStream:        w,
+		})
+		if err != nil {
+			require.ErrorIs(t, err, ErrPacketTooLarge)
+			dropped++
+		}
+	}
+	require.NotZero(t, dropped)
+	require.Equal(t, uint32(dropped), b.packetsTooLarge.Load())
+	require.NotEmpty(t, w.sizes)
+	for _, size := range w.sizes {
+		require.LessOrEqual(t, size+mtuOverhead, mtu)
+	}
+
+	// no limit
+	b.SetMTU(0)
+	_, err := b.SendPacket(&Packet{
+		Header:      &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 2, SSRC: 1234},
+		Payload:     make([]byte, 1500),
+		WriteStream: w,
+	})
+	require.NoError(t, err)
+}
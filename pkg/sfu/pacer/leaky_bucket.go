@@ -81,6 +81,10 @@ func (l *LeakyBucket) Enqueue(p Packet) {
 	defer l.lock.Unlock()
 
 	if !l.isStopped {
+		for l.Base.isQueueFull(l.packets.Len()) {
+			dropped := l.packets.PopFront()
+			l.Base.dropPacket(&dropped)
+		}
 		l.packets.PushBack(p)
 	}
 }
@@ -60,6 +60,10 @@ func (n *NoQueue) Enqueue(p Packet) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
+	for n.Base.isQueueFull(n.packets.Len()) {
+		dropped := n.packets.PopFront()
+		n.Base.dropPacket(&dropped)
+	}
 	n.packets.PushBack(p)
 	if n.packets.Len() == 1 && !n.isStopped {
 		select {
@@ -0,0 +1,114 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// slowWriter blocks writes until released, like a subscriber whose socket does not drain
+type slowWriter struct {
+	release chan struct{}
+
+	lock            sync.Mutex
+	sequenceNumbers []uint16
+}
+
+func (w *slowWriter) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	<-w.release
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.sequenceNumbers = append(w.sequenceNumbers, header.SequenceNumber)
+	return header.MarshalSize() + len(payload), nil
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *slowWriter) SequenceNumbers() []uint16 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]uint16{}, w.sequenceNumbers...)
+}
+
+var _ webrtc.TrackLocalWriter = (*slowWriter)(nil)
+
+func TestNoQueueMaxQueuedPackets(t *testing.T) {
+	writer := &slowWriter{release: make(chan struct{})}
+	n := NewNoQueue(logger.GetLogger())
+	defer n.Stop()
+	n.SetMaxQueuedPackets(10)
+
+	// first packet is taken by the worker, which blocks on writing it
+	enqueue := func(sn uint16) {
+		n.Enqueue(Packet{
+			Header:      &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: sn, SSRC: 1234},
+			Payload:     []byte{0x01, 0x02},
+			WriteStream: writer,
+		})
+	}
+	enqueue(0)
+	require.Eventually(t, func() bool {
+		n.lock.RLock()
+		defer n.lock.RUnlock()
+		return n.packets.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	for sn := uint16(1); sn <= 100; sn++ {
+		enqueue(sn)
+
+		n.lock.RLock()
+		require.LessOrEqual(t, n.packets.Len(), 10)
+		n.lock.RUnlock()
+	}
+	require.Equal(t, uint32(90), n.packetsDropped.Load())
+
+	// the most recent packets are sent once the subscriber drains
+	close(writer.release)
+	expected := []uint16{0}
+	for sn := uint16(91); sn <= 100; sn++ {
+		expected = append(expected, sn)
+	}
+	require.Eventually(t, func() bool { return len(writer.SequenceNumbers()) == len(expected) }, time.Second, 10*time.Millisecond)
+	require.Equal(t, expected, writer.SequenceNumbers())
+}
+
+func TestNoQueueUnbounded(t *testing.T) {
+	writer := &slowWriter{release: make(chan struct{})}
+	n := NewNoQueue(logger.GetLogger())
+	defer n.Stop()
+
+	for sn := uint16(0); sn < 100; sn++ {
+		n.Enqueue(Packet{
+			Header:      &rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: sn, SSRC: 1234},
+			Payload:     []byte{0x01, 0x02},
+			WriteStream: writer,
+		})
+	}
+	require.Zero(t, n.packetsDropped.Load())
+
+	close(writer.release)
+	require.Eventually(t, func() bool { return len(writer.SequenceNumbers()) == 100 }, time.Second, 10*time.Millisecond)
+}
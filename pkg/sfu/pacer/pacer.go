@@ -44,6 +44,8 @@ type Pacer interface {
 
 	SetInterval(interval time.Duration)
 	SetBitrate(bitrate int)
+	SetMTU(mtu int)
+	SetMaxQueuedPackets(maxPackets int)
 }
 
 // ------------------------------------------------
@@ -0,0 +1,43 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+// PacketTraceFunc receives a trace message with its key value pairs, e.g. logger.Logger.Infow
+type PacketTraceFunc func(msg string, keysAndValues ...interface{})
+
+// PacketTrace traces the packets and feedback decisions of a connection while tracing of the connection is enabled,
+// which can change at any time. A nil PacketTrace is never enabled.
+type PacketTrace struct {
+	isEnabled func() bool
+	trace     PacketTraceFunc
+}
+
+func NewPacketTrace(isEnabled func() bool, trace PacketTraceFunc) *PacketTrace {
+	return &PacketTrace{
+		isEnabled: isEnabled,
+		trace:     trace,
+	}
+}
+
+// IsEnabled can be checked before collecting the values of a trace on hot paths
+func (p *PacketTrace) IsEnabled() bool {
+	return p != nil && p.isEnabled()
+}
+
+func (p *PacketTrace) Trace(msg string, keysAndValues ...interface{}) {
+	if p.IsEnabled() {
+		p.trace(msg, keysAndValues...)
+	}
+}
@@ -42,8 +42,14 @@ var (
 	ErrDownTrackAlreadyExist = errors.New("DownTrack already exist")
 	ErrBufferNotFound        = errors.New("buffer not found")
 	ErrDuplicateLayer        = errors.New("duplicate layer")
+	ErrMaxLayersExceeded     = errors.New("maximum number of simulcast layers exceeded")
+	ErrMaxResolutionExceeded = errors.New("maximum layer resolution exceeded")
 )
 
+// MinDeadTrackTimeout is the shortest time without packets after which a track can be declared dead,
+// shorter timeouts would declare tracks dead on ordinary jitter
+const MinDeadTrackTimeout = time.Second
+
 type AudioLevelHandle func(level uint8, duration uint32)
 
 type Bitrates [buffer.DefaultMaxLayerSpatial + 1][buffer.DefaultMaxLayerTemporal + 1]int64
@@ -92,6 +98,28 @@ type WebRTCReceiver struct {
 	pliThrottleConfig config.PLIThrottleConfig
 	audioConfig       config.AudioConfig
 
+	ddReorderTolerance int
+	rrInterval         time.Duration
+	rrJitter           time.Duration
+	maxTSJump          time.Duration
+	keepalivePolicy    KeepalivePolicy
+	paddingPolicy      PaddingPolicy
+	rtcpXR             bool
+	maxSimulcastLayers int
+	// largest accepted layer resolution, the longer side is checked against the larger of the two
+	maxLayerWidth  uint32
+	maxLayerHeight uint32
+	// highest SVC spatial layer whose resolution in the dependency descriptor structure is accepted
+	maxAcceptedSpatial atomic.Int32
+	// packets held for a late dependency descriptor structure of a key frame
+	keyFrameReorderTolerance int
+
+	keyFrameRequestMethods map[string]config.KeyFrameRequestMethod
+	keyFrameRequestLimiter *buffer.KeyFrameRequestLimiter
+
+	redDistance     int
+	audioMaxBitrate int
+
 	trackID        livekit.TrackID
 	streamID       string
 	kind           webrtc.RTPCodecType
@@ -130,6 +158,13 @@ type WebRTCReceiver struct {
 	redPktWriter    func(pkt *buffer.ExtPacket, spatialLayer int32) int
 
 	forwardStats *ForwardStats
+
+	deadTrackTimeout time.Duration
+	lastPacketAt     atomic.Int64
+	upTrackPaused    atomic.Bool
+	dynacastPaused   atomic.Bool
+	dead             atomic.Bool
+	onDeadChange     func(dead bool)
 }
 
 // SVC-TODO: Have to use more conditions to differentiate between
@@ -168,6 +203,121 @@ func WithAudioConfig(audioConfig config.AudioConfig) ReceiverOpts {
 	}
 }
 
+// WithDDReorderTolerance sets the number of packets a dependency descriptor can be reordered by before being dropped
+func WithDDReorderTolerance(tolerance int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.ddReorderTolerance = tolerance
+		return w
+	}
+}
+
+// WithKeyFrameReorderTolerance sets the number of packets held until a late key frame packet carrying the
+// dependency descriptor structure arrives, 0 drops them
+func WithKeyFrameReorderTolerance(tolerance int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.keyFrameReorderTolerance = tolerance
+		return w
+	}
+}
+
+// WithReceiverReportInterval sets the interval between RTCP receiver reports sent to the publisher
+func WithReceiverReportInterval(interval time.Duration) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.rrInterval = interval
+		return w
+	}
+}
+
+// WithReceiverReportJitter randomises the interval between RTCP receiver reports sent to the publisher by up to +/- jitter
+func WithReceiverReportJitter(jitter time.Duration) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.rrJitter = jitter
+		return w
+	}
+}
+
+// WithMaxTimestampJump handles RTP timestamp jumps larger than maxJump as stream discontinuities
+func WithMaxTimestampJump(maxJump time.Duration) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.maxTSJump = maxJump
+		return w
+	}
+}
+
+// WithPaddingPolicy keeps padding only packets of the publisher in the buffer when the policy is applied by the down tracks
+func WithPaddingPolicy(policy PaddingPolicy) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.paddingPolicy = policy
+		return w
+	}
+}
+
+// WithKeepalivePolicy keeps keepalive packets of the publisher in the buffer when they are forwarded per policy
+func WithKeepalivePolicy(policy KeepalivePolicy) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.keepalivePolicy = policy
+		return w
+	}
+}
+
+// WithRTCPExtendedReports sends an RTCP XR statistics summary with each receiver report sent to the publisher
+func WithRTCPExtendedReports(enable bool) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.rtcpXR = enable
+		return w
+	}
+}
+
+// WithMaxSimulcastLayers limits the number of simulcast layers accepted from the publisher, 0 means no limit
+func WithMaxSimulcastLayers(maxLayers int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.maxSimulcastLayers = maxLayers
+		return w
+	}
+}
+
+// WithMaxLayerResolution rejects video layers the publisher declares larger than the given resolution, in either
+// orientation. 0 does not limit the dimension
+func WithMaxLayerResolution(width, height uint32) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.maxLayerWidth = width
+		w.maxLayerHeight = height
+		return w
+	}
+}
+
+// WithKeyFrameRequestMethods sets the RTCP packet used to request key frames, keyed by lower case codec mime type
+func WithKeyFrameRequestMethods(methods map[string]config.KeyFrameRequestMethod) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.keyFrameRequestMethods = methods
+		return w
+	}
+}
+
+// WithKeyFrameRequestLimiter limits outstanding key frame requests using a limiter shared across receivers
+func WithKeyFrameRequestLimiter(limiter *buffer.KeyFrameRequestLimiter) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.keyFrameRequestLimiter = limiter
+		return w
+	}
+}
+
+// WithAudioRedDistance sets the number of redundant encodings used for audio RED, 0 uses the default
+func WithAudioRedDistance(redDistance int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.redDistance = redDistance
+		return w
+	}
+}
+
+// WithAudioMaxBitrate limits the redundancy added to forwarded audio RED to stay within bitrate, 0 means no limit
+func WithAudioMaxBitrate(bitrate int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.audioMaxBitrate = bitrate
+		return w
+	}
+}
+
 // WithStreamTrackers enables StreamTracker use for simulcast
 func WithStreamTrackers() ReceiverOpts {
 	return func(w *WebRTCReceiver) *WebRTCReceiver {
@@ -195,6 +345,15 @@ func WithForwardStats(forwardStats *ForwardStats) ReceiverOpts {
 	}
 }
 
+// WithDeadTrackTimeout declares the track dead when no packets are received for the timeout while it is not paused,
+// 0 disables
+func WithDeadTrackTimeout(timeout time.Duration) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.deadTrackTimeout = timeout
+		return w
+	}
+}
+
 func WithEverHasDowntrackAdded(f func()) ReceiverOpts {
 	return func(w *WebRTCReceiver) *WebRTCReceiver {
 		w.onDowntrackEverAdded = f
@@ -228,6 +387,7 @@ func NewWebRTCReceiver(
 		w = opt(w)
 	}
 	w.trackInfo.Store(proto.Clone(trackInfo).(*livekit.TrackInfo))
+	w.maxAcceptedSpatial.Store(buffer.DefaultMaxLayerSpatial)
 
 	w.downTrackSpreader = NewDownTrackSpreader(DownTrackSpreaderParams{
 		Threshold: w.lbThreshold,
@@ -259,6 +419,11 @@ func NewWebRTCReceiver(
 		}
 	}
 
+	if w.deadTrackTimeout > 0 {
+		w.lastPacketAt.Store(time.Now().UnixNano())
+		go w.deadTrackWorker()
+	}
+
 	return w
 }
 
@@ -288,6 +453,25 @@ func (w *WebRTCReceiver) getOnMaxLayerChange() func(maxLayer int32) {
 	return w.onMaxLayerChange
 }
 
+// OnDeadChange is called when the track is declared dead, i.e. no packets were received for the dead track timeout,
+// and when packets are received again
+func (w *WebRTCReceiver) OnDeadChange(fn func(dead bool)) {
+	w.bufferMu.Lock()
+	w.onDeadChange = fn
+	w.bufferMu.Unlock()
+}
+
+func (w *WebRTCReceiver) getOnDeadChange() func(dead bool) {
+	w.bufferMu.RLock()
+	defer w.bufferMu.RUnlock()
+
+	return w.onDeadChange
+}
+
+func (w *WebRTCReceiver) IsDead() bool {
+	return w.dead.Load()
+}
+
 func (w *WebRTCReceiver) GetConnectionScoreAndQuality() (float32, livekit.ConnectionQuality) {
 	return w.connectionStats.GetScoreAndQuality()
 }
@@ -352,6 +536,27 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	if w.Kind() == webrtc.RTPCodecTypeVideo && !w.isSVC {
 		layer = buffer.RidToSpatialLayer(track.RID(), w.trackInfo.Load())
 	}
+	if w.Kind() == webrtc.RTPCodecTypeVideo {
+		// all spatial layers of SVC are received on one track
+		minLayer, maxLayer := layer, layer
+		if w.isSVC {
+			minLayer, maxLayer = 0, buffer.DefaultMaxLayerSpatial
+		}
+		for checkLayer := minLayer; checkLayer <= maxLayer; checkLayer++ {
+			if width, height := layerResolution(w.trackInfo.Load(), checkLayer); !w.isLayerResolutionAccepted(width, height) {
+				w.logger.Warnw(
+					"rejecting layer", ErrMaxResolutionExceeded,
+					"layer", checkLayer,
+					"rid", track.RID(),
+					"width", width,
+					"height", height,
+					"maxWidth", w.maxLayerWidth,
+					"maxHeight", w.maxLayerHeight,
+				)
+				return ErrMaxResolutionExceeded
+			}
+		}
+	}
 	buff.SetLogger(w.logger.WithValues("layer", layer))
 	buff.SetAudioLevelParams(audio.AudioLevelParams{
 		ActiveLevel:     w.audioConfig.ActiveLevel,
@@ -360,6 +565,23 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 		SmoothIntervals: w.audioConfig.SmoothIntervals,
 	})
 	buff.SetAudioLossProxying(w.audioConfig.EnableLossProxying)
+	buff.SetDDReorderTolerance(w.ddReorderTolerance)
+	buff.SetKeyFrameReorderTolerance(w.keyFrameReorderTolerance)
+	buff.SetReceiverReportInterval(w.rrInterval)
+	buff.SetReceiverReportJitter(w.rrJitter)
+	buff.SetMaxTimestampJump(w.maxTSJump)
+	// padding only packets are dropped by the buffer with the default policy, others need them in the down tracks,
+	// keepalive packets follow the padding policy unless they have a policy of their own
+	forwardPadding := w.paddingPolicy != PaddingPolicyStripContiguous
+	buff.SetForwardPadding(forwardPadding)
+	buff.SetForwardKeepalive(
+		w.keepalivePolicy == KeepalivePolicyForward || (w.keepalivePolicy == KeepalivePolicyAsPadding && forwardPadding),
+	)
+	buff.SetRTCPExtendedReports(w.rtcpXR)
+	buff.SetKeyFrameRequestMethod(w.keyFrameRequestMethod())
+	if w.keyFrameRequestLimiter != nil {
+		buff.SetKeyFrameRequestLimiter(w.keyFrameRequestLimiter)
+	}
 	buff.OnRtcpFeedback(w.sendRTCP)
 	buff.OnRtcpSenderReport(func() {
 		srData := buff.GetSenderReportData()
@@ -388,6 +610,16 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 		w.bufferMu.Unlock()
 		return ErrDuplicateLayer
 	}
+	if w.maxSimulcastLayers > 0 && w.numUpTracksLocked() >= w.maxSimulcastLayers {
+		w.bufferMu.Unlock()
+		w.logger.Warnw(
+			"rejecting simulcast layer", ErrMaxLayersExceeded,
+			"layer", layer,
+			"rid", track.RID(),
+			"maxLayers", w.maxSimulcastLayers,
+		)
+		return ErrMaxLayersExceeded
+	}
 	w.upTracks[layer] = track
 	w.buffers[layer] = buff
 	rtt := w.rtt
@@ -404,10 +636,79 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	return nil
 }
 
+func (w *WebRTCReceiver) keyFrameRequestMethod() buffer.KeyFrameRequestMethod {
+	switch w.keyFrameRequestMethods[strings.ToLower(w.codec.MimeType)] {
+	case config.KeyFrameRequestMethodFIR:
+		return buffer.KeyFrameRequestMethodFIR
+	default:
+		return buffer.KeyFrameRequestMethodPLI
+	}
+}
+
+// updateMaxAcceptedSpatial caps the SVC spatial layers forwarded to those below the first one whose resolution in
+// the structure is not accepted, a publisher may send larger layers than it declared
+func (w *WebRTCReceiver) updateMaxAcceptedSpatial(structure *dd.FrameDependencyStructure) {
+	maxSpatial := int32(buffer.DefaultMaxLayerSpatial)
+	for spatial, res := range structure.Resolutions {
+		if !w.isLayerResolutionAccepted(uint32(res.Width), uint32(res.Height)) {
+			maxSpatial = int32(spatial) - 1
+			break
+		}
+	}
+
+	if prev := w.maxAcceptedSpatial.Swap(maxSpatial); prev != maxSpatial && maxSpatial < buffer.DefaultMaxLayerSpatial {
+		w.logger.Warnw(
+			"not forwarding spatial layers", ErrMaxResolutionExceeded,
+			"maxSpatial", maxSpatial,
+			"resolutions", structure.Resolutions,
+			"maxWidth", w.maxLayerWidth,
+			"maxHeight", w.maxLayerHeight,
+		)
+	}
+}
+
+func (w *WebRTCReceiver) isLayerResolutionAccepted(width, height uint32) bool {
+	longSide, shortSide := max(width, height), min(width, height)
+	maxLongSide, maxShortSide := max(w.maxLayerWidth, w.maxLayerHeight), min(w.maxLayerWidth, w.maxLayerHeight)
+	if maxShortSide == 0 {
+		// only one dimension is limited, it applies to the longer side
+		return maxLongSide == 0 || longSide <= maxLongSide
+	}
+	return longSide <= maxLongSide && shortSide <= maxShortSide
+}
+
+// layerResolution returns the resolution the publisher declared for a spatial layer, that of the track
+// if layers are not declared
+func layerResolution(ti *livekit.TrackInfo, layer int32) (uint32, uint32) {
+	layers := ti.GetLayers()
+	if len(layers) == 0 {
+		return ti.GetWidth(), ti.GetHeight()
+	}
+	for _, l := range layers {
+		if buffer.VideoQualityToSpatialLayer(l.Quality, ti) == layer {
+			return l.Width, l.Height
+		}
+	}
+	return 0, 0
+}
+
+func (w *WebRTCReceiver) numUpTracksLocked() int {
+	numUpTracks := 0
+	for _, upTrack := range w.upTracks {
+		if upTrack != nil {
+			numUpTracks++
+		}
+	}
+	return numUpTracks
+}
+
 // SetUpTrackPaused indicates upstream will not be sending any data.
 // this will reflect the "muted" status and will pause streamtracker to ensure we don't turn off
 // the layer
 func (w *WebRTCReceiver) SetUpTrackPaused(paused bool) {
+	// time without packets while paused does not count towards declaring the track dead
+	w.lastPacketAt.Store(time.Now().UnixNano())
+	w.upTrackPaused.Store(paused)
 	w.streamTrackerManager.SetPaused(paused)
 
 	w.bufferMu.RLock()
@@ -435,6 +736,9 @@ func (w *WebRTCReceiver) AddDownTrack(track TrackSender) error {
 	track.TrackInfoAvailable()
 	track.UpTrackMaxPublishedLayerChange(w.streamTrackerManager.GetMaxPublishedLayer())
 	track.UpTrackMaxTemporalLayerSeenChange(w.streamTrackerManager.GetMaxTemporalLayerSeen())
+	if w.dead.Load() {
+		track.UpTrackDeadChange(true)
+	}
 
 	w.downTrackSpreader.Store(track)
 	w.logger.Debugw("downtrack added", "subscriberID", track.SubscriberID())
@@ -471,6 +775,10 @@ func (w *WebRTCReceiver) notifyMaxExpectedLayer(layer int32) {
 }
 
 func (w *WebRTCReceiver) SetMaxExpectedSpatialLayer(layer int32) {
+	// publisher stops sending all layers when none are subscribed (dynacast), that does not count towards
+	// declaring the track dead either
+	w.lastPacketAt.Store(time.Now().UnixNano())
+	w.dynacastPaused.Store(layer == buffer.InvalidLayerSpatial)
 	w.streamTrackerManager.SetMaxExpectedSpatialLayer(layer)
 	w.notifyMaxExpectedLayer(layer)
 
@@ -719,6 +1027,19 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 		if err == io.EOF {
 			return
 		}
+		if w.deadTrackTimeout > 0 {
+			w.observePacket(time.Now())
+		}
+
+		if w.isSVC {
+			if ddExt := pkt.DependencyDescriptor; ddExt != nil && ddExt.StructureUpdated && ddExt.Descriptor != nil && ddExt.Descriptor.AttachedStructure != nil {
+				w.updateMaxAcceptedSpatial(ddExt.Descriptor.AttachedStructure)
+			}
+			if pkt.Spatial > w.maxAcceptedSpatial.Load() {
+				// neither forwarded nor tracked, so that the layer is not allocated
+				continue
+			}
+		}
 
 		spatialTracker := tracker
 		spatialLayer := layer
@@ -772,6 +1093,58 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 	}
 }
 
+func (w *WebRTCReceiver) deadTrackWorker() {
+	// checked a few times per timeout to declare the track dead soon after the timeout
+	ticker := time.NewTicker(w.deadTrackTimeout / 4)
+	defer ticker.Stop()
+
+	for !w.closed.Load() {
+		<-ticker.C
+		w.checkDeadTrack(time.Now())
+	}
+}
+
+func (w *WebRTCReceiver) observePacket(at time.Time) {
+	w.lastPacketAt.Store(at.UnixNano())
+	if w.dead.Swap(false) {
+		w.logger.Infow("track alive again")
+		w.notifyDeadChange(false)
+	}
+}
+
+func (w *WebRTCReceiver) checkDeadTrack(now time.Time) {
+	if w.upTrackPaused.Load() || w.dynacastPaused.Load() || w.dead.Load() {
+		return
+	}
+
+	silence := now.Sub(time.Unix(0, w.lastPacketAt.Load()))
+	if silence < w.deadTrackTimeout {
+		return
+	}
+
+	if !w.dead.Swap(true) {
+		w.logger.Infow("track declared dead, no packets received", "silence", silence, "timeout", w.deadTrackTimeout)
+		w.notifyDeadChange(true)
+	}
+}
+
+func (w *WebRTCReceiver) notifyDeadChange(dead bool) {
+	notify := func(dt TrackSender) {
+		dt.UpTrackDeadChange(dead)
+	}
+	w.downTrackSpreader.Broadcast(notify)
+	if pr := w.primaryReceiver.Load(); pr != nil {
+		pr.downTrackSpreader.Broadcast(notify)
+	}
+	if rr := w.redReceiver.Load(); rr != nil {
+		rr.downTrackSpreader.Broadcast(notify)
+	}
+
+	if onDeadChange := w.getOnDeadChange(); onDeadChange != nil {
+		onDeadChange(dead)
+	}
+}
+
 // closeTracks close all tracks from Receiver
 func (w *WebRTCReceiver) closeTracks() {
 	w.connectionStats.Close()
@@ -821,7 +1194,7 @@ func (w *WebRTCReceiver) GetPrimaryReceiverForRed() TrackReceiver {
 		pr := NewRedPrimaryReceiver(w, DownTrackSpreaderParams{
 			Threshold: w.lbThreshold,
 			Logger:    w.logger,
-		})
+		}, w.redDistance)
 		if w.primaryReceiver.CompareAndSwap(nil, pr) {
 			w.bufferMu.Lock()
 			w.redPktWriter = pr.ForwardRTP
@@ -841,7 +1214,7 @@ func (w *WebRTCReceiver) GetRedReceiver() TrackReceiver {
 		pr := NewRedReceiver(w, DownTrackSpreaderParams{
 			Threshold: w.lbThreshold,
 			Logger:    w.logger,
-		})
+		}, w.redDistance, w.audioMaxBitrate)
 		if w.redReceiver.CompareAndSwap(nil, pr) {
 			w.bufferMu.Lock()
 			w.redPktWriter = pr.ForwardRTP
@@ -21,10 +21,19 @@ import (
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gammazero/workerpool"
+	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	dd "github.com/livekit/livekit-server/pkg/sfu/rtpextension/dependencydescriptor"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
 )
 
 func TestWebRTCReceiver_OnCloseHandler(t *testing.T) {
@@ -52,6 +61,195 @@ func TestWebRTCReceiver_OnCloseHandler(t *testing.T) {
 	}
 }
 
+func TestWebRTCReceiver_MaxSimulcastLayers(t *testing.T) {
+	newReceiver := func(maxLayers int, existingLayers ...int32) *WebRTCReceiver {
+		w := &WebRTCReceiver{
+			logger: logger.GetLogger(),
+			kind:   webrtc.RTPCodecTypeVideo,
+		}
+		w = WithMaxSimulcastLayers(maxLayers)(w)
+		for _, layer := range existingLayers {
+			w.upTracks[layer] = &webrtc.TrackRemote{}
+		}
+		return w
+	}
+
+	t.Run("layers beyond the cap are refused", func(t *testing.T) {
+		w := newReceiver(2, 0, 1)
+		buff := buffer.NewBuffer(123, 100, 100)
+		err := w.AddUpTrack(&webrtc.TrackRemote{}, buff)
+		require.ErrorIs(t, err, ErrMaxLayersExceeded)
+		require.Nil(t, w.buffers[0])
+		require.Equal(t, 2, w.numUpTracksLocked())
+	})
+
+	t.Run("single layer cap", func(t *testing.T) {
+		w := newReceiver(1, 2)
+		err := w.AddUpTrack(&webrtc.TrackRemote{}, buffer.NewBuffer(123, 100, 100))
+		require.ErrorIs(t, err, ErrMaxLayersExceeded)
+	})
+
+	t.Run("duplicate layer takes precedence", func(t *testing.T) {
+		w := newReceiver(1, 0)
+		err := w.AddUpTrack(&webrtc.TrackRemote{}, buffer.NewBuffer(123, 100, 100))
+		require.ErrorIs(t, err, ErrDuplicateLayer)
+	})
+}
+
+func TestWebRTCReceiver_MaxLayerResolution(t *testing.T) {
+	simulcastInfo := &livekit.TrackInfo{
+		Type: livekit.TrackType_VIDEO,
+		Layers: []*livekit.VideoLayer{
+			{Quality: livekit.VideoQuality_LOW, Width: 480, Height: 270},
+			{Quality: livekit.VideoQuality_MEDIUM, Width: 960, Height: 540},
+			{Quality: livekit.VideoQuality_HIGH, Width: 7680, Height: 4320},
+		},
+	}
+	width, height := layerResolution(simulcastInfo, 1)
+	require.Equal(t, uint32(960), width)
+	require.Equal(t, uint32(540), height)
+
+	// track resolution is used when layers are not declared
+	width, height = layerResolution(&livekit.TrackInfo{Type: livekit.TrackType_VIDEO, Width: 1280, Height: 720}, 0)
+	require.Equal(t, uint32(1280), width)
+	require.Equal(t, uint32(720), height)
+
+	w := WithMaxLayerResolution(1920, 1080)(&WebRTCReceiver{
+		logger: logger.GetLogger(),
+		kind:   webrtc.RTPCodecTypeVideo,
+	})
+	require.True(t, w.isLayerResolutionAccepted(1920, 1080))
+	require.True(t, w.isLayerResolutionAccepted(1080, 1920))
+	require.False(t, w.isLayerResolutionAccepted(1921, 1080))
+	require.False(t, w.isLayerResolutionAccepted(1080, 1440))
+
+	// oversized layer is rejected before it is received
+	w.trackInfo.Store(&livekit.TrackInfo{Type: livekit.TrackType_VIDEO, Width: 7680, Height: 4320})
+	err := w.AddUpTrack(&webrtc.TrackRemote{}, buffer.NewBuffer(123, 100, 100))
+	require.ErrorIs(t, err, ErrMaxResolutionExceeded)
+	require.Nil(t, w.buffers[0])
+
+	// only the longer side is limited when one dimension is set
+	w = WithMaxLayerResolution(1920, 0)(&WebRTCReceiver{})
+	require.True(t, w.isLayerResolutionAccepted(1080, 1920))
+	require.False(t, w.isLayerResolutionAccepted(2560, 1080))
+
+	w = WithMaxLayerResolution(0, 0)(&WebRTCReceiver{})
+	require.True(t, w.isLayerResolutionAccepted(7680, 4320))
+
+	// every declared spatial layer of SVC is checked, not only the first one
+	w = WithMaxLayerResolution(1920, 1080)(&WebRTCReceiver{
+		logger: logger.GetLogger(),
+		kind:   webrtc.RTPCodecTypeVideo,
+		isSVC:  true,
+	})
+	w.trackInfo.Store(simulcastInfo)
+	err = w.AddUpTrack(&webrtc.TrackRemote{}, buffer.NewBuffer(123, 100, 100))
+	require.ErrorIs(t, err, ErrMaxResolutionExceeded)
+
+	// spatial layers above the first one too large in the dependency descriptor structure are not forwarded
+	w.maxAcceptedSpatial.Store(buffer.DefaultMaxLayerSpatial)
+	w.updateMaxAcceptedSpatial(&dd.FrameDependencyStructure{
+		Resolutions: []dd.RenderResolution{{Width: 480, Height: 270}, {Width: 960, Height: 540}, {Width: 3840, Height: 2160}},
+	})
+	require.Equal(t, int32(1), w.maxAcceptedSpatial.Load())
+	w.updateMaxAcceptedSpatial(&dd.FrameDependencyStructure{
+		Resolutions: []dd.RenderResolution{{Width: 3840, Height: 2160}},
+	})
+	require.Equal(t, int32(-1), w.maxAcceptedSpatial.Load())
+	w.updateMaxAcceptedSpatial(&dd.FrameDependencyStructure{
+		Resolutions: []dd.RenderResolution{{Width: 480, Height: 270}, {Width: 960, Height: 540}, {Width: 1920, Height: 1080}},
+	})
+	require.Equal(t, buffer.DefaultMaxLayerSpatial, w.maxAcceptedSpatial.Load())
+}
+
+func TestWebRTCReceiver_KeyFrameRequestMethod(t *testing.T) {
+	methods := map[string]config.KeyFrameRequestMethod{
+		"video/vp9": config.KeyFrameRequestMethodFIR,
+		"video/av1": config.KeyFrameRequestMethodFIR,
+		"video/vp8": config.KeyFrameRequestMethodPLI,
+	}
+
+	tests := []struct {
+		mimeType string
+		expected buffer.KeyFrameRequestMethod
+	}{
+		{webrtc.MimeTypeVP9, buffer.KeyFrameRequestMethodFIR},
+		{webrtc.MimeTypeAV1, buffer.KeyFrameRequestMethodFIR},
+		{webrtc.MimeTypeVP8, buffer.KeyFrameRequestMethodPLI},
+		// not configured, falls back to PLI
+		{webrtc.MimeTypeH264, buffer.KeyFrameRequestMethodPLI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			w := &WebRTCReceiver{
+				codec: webrtc.RTPCodecParameters{
+					RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: tt.mimeType},
+				},
+			}
+			w = WithKeyFrameRequestMethods(methods)(w)
+			require.Equal(t, tt.expected, w.keyFrameRequestMethod())
+		})
+	}
+}
+
+func TestWebRTCReceiver_DeadTrack(t *testing.T) {
+	w := &WebRTCReceiver{
+		logger: logger.GetLogger(),
+		kind:   webrtc.RTPCodecTypeVideo,
+		downTrackSpreader: NewDownTrackSpreader(DownTrackSpreaderParams{
+			Logger: logger.GetLogger(),
+		}),
+	}
+	w = WithDeadTrackTimeout(time.Second)(w)
+
+	var receiverChanges, downTrackChanges []bool
+	w.OnDeadChange(func(dead bool) {
+		receiverChanges = append(receiverChanges, dead)
+	})
+	dt := &DownTrack{}
+	dt.OnUpTrackDeadChange(func(_ *DownTrack, dead bool) {
+		downTrackChanges = append(downTrackChanges, dead)
+	})
+	w.downTrackSpreader.Store(dt)
+
+	start := time.Now()
+	w.observePacket(start)
+
+	// silent for less than the timeout
+	w.checkDeadTrack(start.Add(999 * time.Millisecond))
+	require.False(t, w.IsDead())
+	require.Empty(t, receiverChanges)
+
+	// silent for the timeout, declared dead once
+	w.checkDeadTrack(start.Add(time.Second))
+	w.checkDeadTrack(start.Add(2 * time.Second))
+	require.True(t, w.IsDead())
+	require.True(t, dt.IsUpTrackDead())
+	require.Equal(t, []bool{true}, receiverChanges)
+	require.Equal(t, []bool{true}, downTrackChanges)
+
+	// packets received again
+	w.observePacket(start.Add(3 * time.Second))
+	require.False(t, w.IsDead())
+	require.False(t, dt.IsUpTrackDead())
+	require.Equal(t, []bool{true, false}, receiverChanges)
+	require.Equal(t, []bool{true, false}, downTrackChanges)
+
+	// paused tracks are not declared dead
+	w.upTrackPaused.Store(true)
+	w.checkDeadTrack(start.Add(time.Minute))
+	require.False(t, w.IsDead())
+	require.Equal(t, []bool{true, false}, receiverChanges)
+
+	// neither are tracks with all layers paused by dynacast
+	w.upTrackPaused.Store(false)
+	w.dynacastPaused.Store(true)
+	w.checkDeadTrack(start.Add(time.Minute))
+	require.False(t, w.IsDead())
+	require.Equal(t, []bool{true, false}, receiverChanges)
+}
+
 func BenchmarkWriteRTP(b *testing.B) {
 	cases := []int{1, 2, 5, 10, 100, 250, 500}
 	workers := runtime.NumCPU()
@@ -42,6 +42,7 @@ type RedPrimaryReceiver struct {
 	logger            logger.Logger
 	closed            atomic.Bool
 
+	redDistance      int
 	firstPktReceived bool
 	lastSeq          uint16
 
@@ -49,11 +50,15 @@ type RedPrimaryReceiver struct {
 	pktHistory byte
 }
 
-func NewRedPrimaryReceiver(receiver TrackReceiver, dsp DownTrackSpreaderParams) *RedPrimaryReceiver {
+func NewRedPrimaryReceiver(receiver TrackReceiver, dsp DownTrackSpreaderParams, redDistance int) *RedPrimaryReceiver {
+	if redDistance <= 0 || redDistance > MaxRedDistance {
+		redDistance = maxRedCount
+	}
 	return &RedPrimaryReceiver{
 		TrackReceiver:     receiver,
 		downTrackSpreader: NewDownTrackSpreader(dsp),
 		logger:            dsp.Logger,
+		redDistance:       redDistance,
 	}
 }
 
@@ -178,7 +183,7 @@ func (r *RedPrimaryReceiver) getSendPktsFromRed(rtp *rtp.Packet) ([]*rtp.Packet,
 	var recoverBits byte
 	if needRecover {
 		bitIndex := r.lastSeq - rtp.SequenceNumber
-		for i := 0; i < maxRedCount; i++ {
+		for i := 0; i < r.redDistance; i++ {
 			if bitIndex > 7 {
 				break
 			}
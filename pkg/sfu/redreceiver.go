@@ -32,10 +32,19 @@ const (
 	maxRedCount = 2
 	mtuSize     = 1500
 
+	// RED block timestamp offset is 14 bits, at opus clock rate of 48 kHz, that allows redundant encodings up to
+	// ~341 ms older than the primary, i.e. 17 packets at the default opus frame duration of 20 ms.
+	// Receive history used for recovery is 8 packets deep, which is the tighter limit.
+	MaxRedDistance = 8
+
 	// the RedReceiver is only for chrome / native webrtc now, we always negotiate opus payload to 111 with those clients,
 	// so it is safe to use a fixed payload 111 here for performance(avoid encoding red blocks for each downtrack that
 	// have a different opus payload type).
 	opusPT = 111
+
+	opusClockRate = 48000
+	// frame duration assumed when it cannot be derived from consecutive packets, 20 ms at opus clock rate
+	defaultOpusFrameTicks = opusClockRate / 50
 )
 
 type RedReceiver struct {
@@ -43,15 +52,21 @@ type RedReceiver struct {
 	downTrackSpreader *DownTrackSpreader
 	logger            logger.Logger
 	closed            atomic.Bool
-	pktBuff           [maxRedCount]*rtp.Packet
+	pktBuff           []*rtp.Packet
 	redPayloadBuf     [mtuSize]byte
+	maxBitrate        int
 }
 
-func NewRedReceiver(receiver TrackReceiver, dsp DownTrackSpreaderParams) *RedReceiver {
+func NewRedReceiver(receiver TrackReceiver, dsp DownTrackSpreaderParams, redDistance int, maxBitrate int) *RedReceiver {
+	if redDistance <= 0 || redDistance > MaxRedDistance {
+		redDistance = maxRedCount
+	}
 	return &RedReceiver{
 		TrackReceiver:     receiver,
 		downTrackSpreader: NewDownTrackSpreader(dsp),
 		logger:            dsp.Logger,
+		pktBuff:           make([]*rtp.Packet, redDistance),
+		maxBitrate:        maxBitrate,
 	}
 }
 
@@ -142,6 +157,14 @@ func (r *RedReceiver) encodeRedForPrimary(pkt *rtp.Packet, redPayload []byte) (i
 		redPkts = append(redPkts, prev)
 	}
 
+	if r.maxBitrate > 0 {
+		frameTicks := uint32(defaultOpusFrameTicks)
+		if newest := r.pktBuff[redLength-1]; newest != nil && pkt.SequenceNumber-newest.SequenceNumber == 1 && pkt.Timestamp-newest.Timestamp < (1<<14) {
+			frameTicks = pkt.Timestamp - newest.Timestamp
+		}
+		redPkts = limitRedundancy(redPkts, pkt, int(uint64(r.maxBitrate)*uint64(frameTicks)/(opusClockRate*8)))
+	}
+
 	// insert primary packet in history buffer
 	// NOTE: packet is copied from retransmission buffer and used in forwarding path. So, not making another
 	// copy here and just maintaining pointer to the packet as the forwarding path should not alter the packet.
@@ -160,6 +183,21 @@ func (r *RedReceiver) encodeRedForPrimary(pkt *rtp.Packet, redPayload []byte) (i
 	return encodeRedForPrimary(redPkts, pkt, redPayload)
 }
 
+// limitRedundancy drops the oldest redundant encodings till the RED payload fits in maxPayloadSize,
+// the primary encoding is always kept
+func limitRedundancy(redPkts []*rtp.Packet, primary *rtp.Packet, maxPayloadSize int) []*rtp.Packet {
+	payloadSize := len(primary.Payload) + 1
+	for _, p := range redPkts {
+		payloadSize += len(p.Payload) + 4
+	}
+
+	for len(redPkts) != 0 && payloadSize > maxPayloadSize {
+		payloadSize -= len(redPkts[0].Payload) + 4
+		redPkts = redPkts[1:]
+	}
+	return redPkts
+}
+
 func encodeRedForPrimary(redPkts []*rtp.Packet, primary *rtp.Packet, redPayload []byte) (int, error) {
 	payloadSize := len(primary.Payload) + 1
 	for _, p := range redPkts {
@@ -276,12 +276,15 @@ func generateRedPkts(t *testing.T, pkts []*rtp.Packet, redCount int) []*rtp.Pack
 	return redPkts
 }
 
-func testRedRedPrimaryReceiver(t *testing.T, maxPktCount, redCount int, sendPktIdx, expectPktIdx []int) {
+func testRedRedPrimaryReceiver(t *testing.T, maxPktCount, redCount int, sendPktIdx, expectPktIdx []int, opts ...ReceiverOpts) {
 	dt := &dummyDowntrack{TrackSender: &DownTrack{}}
 	w := &WebRTCReceiver{
 		kind:   webrtc.RTPCodecTypeAudio,
 		logger: logger.GetLogger(),
 	}
+	for _, opt := range opts {
+		w = opt(w)
+	}
 	require.Equal(t, w.GetPrimaryReceiverForRed(), w)
 	w.isRED = true
 	red := w.GetPrimaryReceiverForRed().(*RedPrimaryReceiver)
@@ -376,6 +379,97 @@ func TestRedPrimaryReceiver(t *testing.T) {
 	})
 }
 
+func TestRedDistance(t *testing.T) {
+	redDistance := 4
+
+	t.Run("encoding", func(t *testing.T) {
+		dt := &dummyDowntrack{TrackSender: &DownTrack{}}
+		w := &WebRTCReceiver{
+			kind:   webrtc.RTPCodecTypeAudio,
+			logger: logger.GetLogger(),
+		}
+		w = WithAudioRedDistance(redDistance)(w)
+		red := w.GetRedReceiver().(*RedReceiver)
+		require.NoError(t, red.AddDownTrack(dt))
+
+		header := rtp.Header{SequenceNumber: 65534, Timestamp: (uint32(1) << 31) - 2*tsStep, PayloadType: 111}
+		expectPkt := make([]*rtp.Packet, 0, redDistance+1)
+		for _, pkt := range generatePkts(header, 10, tsStep) {
+			expectPkt = append(expectPkt, pkt)
+			if len(expectPkt) > redDistance+1 {
+				expectPkt = expectPkt[1:]
+			}
+			red.ForwardRTP(&buffer.ExtPacket{
+				Packet: pkt,
+			}, 0)
+			verifyRedEncodings(t, dt.lastReceivedPkt, expectPkt)
+		}
+		require.Len(t, expectPkt, redDistance+1)
+	})
+
+	t.Run("full recover", func(t *testing.T) {
+		maxPktCount := 19
+		var sendPktIndex, recvPktIndex []int
+		for i := 0; i < maxPktCount; i++ {
+			recvPktIndex = append(recvPktIndex, i)
+
+			// drop packets covered by red encoding
+			if i%(redDistance+1) != 0 {
+				continue
+			}
+			sendPktIndex = append(sendPktIndex, i)
+		}
+
+		testRedRedPrimaryReceiver(t, maxPktCount, redDistance, sendPktIndex, recvPktIndex, WithAudioRedDistance(redDistance))
+	})
+
+	t.Run("default distance recovers less", func(t *testing.T) {
+		sendPktIndex := []int{0, 5, 10}
+		recvPktIndex := []int{0, 3, 4, 5, 8, 9, 10}
+		testRedRedPrimaryReceiver(t, 11, redDistance, sendPktIndex, recvPktIndex)
+	})
+}
+
+func TestRedMaxBitrate(t *testing.T) {
+	redDistance := 4
+	// 10 ms packets at 200 kbps leave 250 bytes per RED payload, room for the primary and one redundant encoding
+	maxBitrate := 200000
+	maxPayloadSize := maxBitrate * int(tsStep) / (opusClockRate * 8)
+
+	dt := &dummyDowntrack{TrackSender: &DownTrack{}}
+	w := &WebRTCReceiver{
+		kind:   webrtc.RTPCodecTypeAudio,
+		logger: logger.GetLogger(),
+	}
+	w = WithAudioRedDistance(redDistance)(w)
+	w = WithAudioMaxBitrate(maxBitrate)(w)
+	red := w.GetRedReceiver().(*RedReceiver)
+	require.NoError(t, red.AddDownTrack(dt))
+
+	header := rtp.Header{SequenceNumber: 65534, Timestamp: (uint32(1) << 31) - 2*tsStep, PayloadType: 111}
+	expectPkt := make([]*rtp.Packet, 0, 2)
+	for i := 0; i < 10; i++ {
+		pkt := &rtp.Packet{Header: header, Payload: make([]byte, 100)}
+		pkt.Payload[0] = byte(i)
+		header.SequenceNumber++
+		header.Timestamp += tsStep
+
+		expectPkt = append(expectPkt, pkt)
+		if len(expectPkt) > 2 {
+			expectPkt = expectPkt[1:]
+		}
+		red.ForwardRTP(&buffer.ExtPacket{
+			Packet: pkt,
+		}, 0)
+		require.LessOrEqual(t, len(dt.lastReceivedPkt.Payload), maxPayloadSize)
+		verifyRedEncodings(t, dt.lastReceivedPkt, expectPkt)
+	}
+
+	// primary is forwarded even if it does not fit
+	primary := &rtp.Packet{Header: header, Payload: make([]byte, maxPayloadSize)}
+	require.Empty(t, limitRedundancy(expectPkt, primary, maxPayloadSize))
+}
+
 func TestExtractPrimaryEncodingForRED(t *testing.T) {
 	header := rtp.Header{SequenceNumber: 65530, Timestamp: (uint32(1) << 31) - 2*tsStep, PayloadType: 111}
 	pkts := generatePkts(header, 10, tsStep)
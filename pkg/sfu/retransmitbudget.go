@@ -0,0 +1,86 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// retransmissions can burst up to this much of the bitrate after a quiet period
+const retransmitBudgetBurst = 250 * time.Millisecond
+
+type RetransmitBudgetParams struct {
+	// bits per second available to retransmissions, 0 does not limit them
+	Bitrate int64
+	// retransmit packets of lower spatial and temporal layers first when the budget does not cover all NACKed packets
+	LayerPriority bool
+}
+
+// retransmitBudget bounds the bitrate of retransmissions with a token bucket
+type retransmitBudget struct {
+	params   RetransmitBudgetParams
+	maxBytes int64
+
+	lock       sync.Mutex
+	bytes      int64
+	lastUpdate time.Time
+}
+
+func newRetransmitBudget(params RetransmitBudgetParams) *retransmitBudget {
+	maxBytes := params.Bitrate * int64(retransmitBudgetBurst) / int64(8*time.Second)
+	return &retransmitBudget{
+		params:   params,
+		maxBytes: maxBytes,
+		bytes:    maxBytes,
+	}
+}
+
+// prioritize orders NACKed packets by layer, lowest first, keeping the NACK order within a layer
+func (r *retransmitBudget) prioritize(epms []extPacketMeta) {
+	if !r.params.LayerPriority {
+		return
+	}
+
+	slices.SortStableFunc(epms, func(x, y extPacketMeta) int {
+		if c := cmp.Compare(x.layer, y.layer); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.temporal, y.temporal)
+	})
+}
+
+// consume takes a packet of the given size from the budget, returns false if the budget does not cover it
+func (r *retransmitBudget) consume(size int, at time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if at.After(r.lastUpdate) {
+		if !r.lastUpdate.IsZero() {
+			// a full burst refills the bucket, bounding elapsed also keeps the product in range
+			elapsed := min(at.Sub(r.lastUpdate), retransmitBudgetBurst)
+			r.bytes = min(r.maxBytes, r.bytes+r.params.Bitrate*int64(elapsed)/int64(8*time.Second))
+		}
+		r.lastUpdate = at
+	}
+
+	if int64(size) > r.bytes {
+		return false
+	}
+	r.bytes -= int64(size)
+	return true
+}
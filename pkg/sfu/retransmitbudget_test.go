@@ -0,0 +1,71 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetransmitBudget(t *testing.T) {
+	// NACKed packets of 100 bytes, in NACK order, enhancement layers interleaved with the base layer
+	nacked := func() []extPacketMeta {
+		var epms []extPacketMeta
+		sn := uint16(100)
+		for _, l := range []struct{ spatial, temporal int8 }{{1, 1}, {0, 1}, {1, 0}, {0, 0}, {1, 1}, {0, 1}, {1, 0}, {0, 0}} {
+			epms = append(epms, extPacketMeta{packetMeta: packetMeta{targetSeqNo: sn, layer: l.spatial, temporal: l.temporal}})
+			sn++
+		}
+		return epms
+	}
+	// returns the sequence numbers the budget lets through
+	retransmit := func(r *retransmitBudget, epms []extPacketMeta, at time.Time) []uint16 {
+		r.prioritize(epms)
+		var sent []uint16
+		for _, epm := range epms {
+			if r.consume(100, at) {
+				sent = append(sent, epm.targetSeqNo)
+			}
+		}
+		return sent
+	}
+
+	// 9.6 kbps bursts up to 300 bytes, i.e. three packets
+	now := time.Now()
+
+	t.Run("base layer preferred under constrained budget", func(t *testing.T) {
+		r := newRetransmitBudget(RetransmitBudgetParams{Bitrate: 9600, LayerPriority: true})
+		require.Equal(t, []uint16{103, 107, 101}, retransmit(r, nacked(), now))
+
+		// budget exhausted
+		require.Empty(t, retransmit(r, nacked(), now))
+
+		// refills at the bitrate, 100 bytes in 83.3ms
+		require.Equal(t, []uint16{103}, retransmit(r, nacked(), now.Add(90*time.Millisecond)))
+	})
+
+	t.Run("NACK order without layer priority", func(t *testing.T) {
+		r := newRetransmitBudget(RetransmitBudgetParams{Bitrate: 9600})
+		require.Equal(t, []uint16{100, 101, 102}, retransmit(r, nacked(), now))
+	})
+
+	t.Run("refill bounded by burst", func(t *testing.T) {
+		r := newRetransmitBudget(RetransmitBudgetParams{Bitrate: 9600, LayerPriority: true})
+		require.Len(t, retransmit(r, nacked(), now), 3)
+		require.Len(t, retransmit(r, nacked(), now.Add(time.Hour)), 3)
+	})
+}
@@ -37,6 +37,57 @@ const (
 	RtxGateWindow = 2000
 )
 
+// PaddingPolicy is how padding only packets of the publisher, e.g. sent to probe for bandwidth, are forwarded
+type PaddingPolicy int
+
+const (
+	// PaddingPolicyStripContiguous drops padding only packets that follow the last forwarded packet, ones following
+	// a gap are forwarded so that the subscriber sees the gap right away
+	PaddingPolicyStripContiguous PaddingPolicy = iota
+	// PaddingPolicyStrip drops all padding only packets, a gap is then seen with the next packet carrying media
+	PaddingPolicyStrip
+	// PaddingPolicyForward forwards padding only packets like any other packet
+	PaddingPolicyForward
+)
+
+func (p PaddingPolicy) String() string {
+	switch p {
+	case PaddingPolicyStripContiguous:
+		return "STRIP_CONTIGUOUS"
+	case PaddingPolicyStrip:
+		return "STRIP"
+	case PaddingPolicyForward:
+		return "FORWARD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// KeepalivePolicy is how keepalive packets of the publisher, i.e. ones without payload and padding, are forwarded
+type KeepalivePolicy int
+
+const (
+	// KeepalivePolicyAsPadding forwards keepalive packets like padding only packets, per the padding policy
+	KeepalivePolicyAsPadding KeepalivePolicy = iota
+	// KeepalivePolicyDrop drops all keepalive packets
+	KeepalivePolicyDrop
+	// KeepalivePolicyForward forwards keepalive packets like any other packet
+	KeepalivePolicyForward
+)
+
+func (k KeepalivePolicy) String() string {
+	switch k {
+	case KeepalivePolicyAsPadding:
+		return "AS_PADDING"
+	case KeepalivePolicyDrop:
+		return "DROP"
+	case KeepalivePolicyForward:
+		return "FORWARD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type TranslationParamsRTP struct {
 	snOrdering        SequenceNumberOrdering
 	extSequenceNumber uint64
@@ -55,15 +106,16 @@ type RTPMungerState struct {
 	ExtSecondLastSN  uint64
 	ExtLastTS        uint64
 	ExtSecondLastTS  uint64
+	ExtHighestTS     uint64
 	LastMarker       bool
 	SecondLastMarker bool
 }
 
 func (r RTPMungerState) String() string {
 	return fmt.Sprintf(
-		"RTPMungerState{extLastSN: %d, extSecondLastSN: %d, extLastTS: %d, extSecondLastTS: %d, lastMarker: %v, secondLastMarker: %v)",
+		"RTPMungerState{extLastSN: %d, extSecondLastSN: %d, extLastTS: %d, extSecondLastTS: %d, extHighestTS: %d, lastMarker: %v, secondLastMarker: %v)",
 		r.ExtLastSN, r.ExtSecondLastSN,
-		r.ExtLastTS, r.ExtSecondLastTS,
+		r.ExtLastTS, r.ExtSecondLastTS, r.ExtHighestTS,
 		r.LastMarker, r.SecondLastMarker,
 	)
 }
@@ -83,12 +135,17 @@ type RTPMunger struct {
 	extLastTS       uint64
 	extSecondLastTS uint64
 	tsOffset        uint64
+	// highest timestamp sent, differs from last when frames are sent out of presentation order, e.g. B-frames
+	extHighestTS uint64
 
 	lastMarker       bool
 	secondLastMarker bool
 
 	extRtxGateSn      uint64
 	isInRtxGateRegion bool
+
+	paddingPolicy   PaddingPolicy
+	keepalivePolicy KeepalivePolicy
 }
 
 func NewRTPMunger(logger logger.Logger) *RTPMunger {
@@ -98,6 +155,14 @@ func NewRTPMunger(logger logger.Logger) *RTPMunger {
 	}
 }
 
+func (r *RTPMunger) SetPaddingPolicy(policy PaddingPolicy) {
+	r.paddingPolicy = policy
+}
+
+func (r *RTPMunger) SetKeepalivePolicy(policy KeepalivePolicy) {
+	r.keepalivePolicy = policy
+}
+
 func (r *RTPMunger) DebugInfo() map[string]interface{} {
 	return map[string]interface{}{
 		"ExtHighestIncomingSN": r.extHighestIncomingSN,
@@ -106,6 +171,7 @@ func (r *RTPMunger) DebugInfo() map[string]interface{} {
 		"SNOffset":             r.snOffset,
 		"ExtLastTS":            r.extLastTS,
 		"ExtSecondLastTS":      r.extSecondLastTS,
+		"ExtHighestTS":         r.extHighestTS,
 		"TSOffset":             r.tsOffset,
 		"LastMarker":           r.lastMarker,
 		"SecondLastMarker":     r.secondLastMarker,
@@ -118,6 +184,7 @@ func (r *RTPMunger) GetLast() RTPMungerState {
 		ExtSecondLastSN:  r.extSecondLastSN,
 		ExtLastTS:        r.extLastTS,
 		ExtSecondLastTS:  r.extSecondLastTS,
+		ExtHighestTS:     r.extHighestTS,
 		LastMarker:       r.lastMarker,
 		SecondLastMarker: r.secondLastMarker,
 	}
@@ -132,6 +199,10 @@ func (r *RTPMunger) SeedLast(state RTPMungerState) {
 	r.extSecondLastSN = state.ExtSecondLastSN
 	r.extLastTS = state.ExtLastTS
 	r.extSecondLastTS = state.ExtSecondLastTS
+	r.extHighestTS = state.ExtHighestTS
+	if int64(r.extHighestTS-r.extLastTS) < 0 {
+		r.extHighestTS = r.extLastTS
+	}
 	r.lastMarker = state.LastMarker
 	r.secondLastMarker = state.SecondLastMarker
 }
@@ -146,6 +217,7 @@ func (r *RTPMunger) SetLastSnTs(extPkt *buffer.ExtPacket) {
 
 	r.extLastTS = extPkt.ExtTimestamp
 	r.extSecondLastTS = extPkt.ExtTimestamp
+	r.extHighestTS = extPkt.ExtTimestamp
 	r.tsOffset = 0
 }
 
@@ -187,8 +259,27 @@ func (r *RTPMunger) PacketDropped(extPkt *buffer.ExtPacket) {
 
 func (r *RTPMunger) UpdateAndGetSnTs(extPkt *buffer.ExtPacket, marker bool) (TranslationParamsRTP, error) {
 	diff := int64(extPkt.ExtSequenceNumber - r.extHighestIncomingSN)
-	if (diff == 1 && len(extPkt.Packet.Payload) != 0) || diff > 1 {
-		// in-order - either contiguous packet with payload OR packet following a gap, may or may not have payload
+	isPaddingOnlyDropped := false
+	if len(extPkt.Packet.Payload) == 0 {
+		policy := r.paddingPolicy
+		if !extPkt.Packet.Padding {
+			// keepalive packet
+			switch r.keepalivePolicy {
+			case KeepalivePolicyDrop:
+				policy = PaddingPolicyStrip
+			case KeepalivePolicyForward:
+				policy = PaddingPolicyForward
+			}
+		}
+		switch policy {
+		case PaddingPolicyStripContiguous:
+			isPaddingOnlyDropped = diff == 1
+		case PaddingPolicyStrip:
+			isPaddingOnlyDropped = diff >= 1
+		}
+	}
+	if diff >= 1 && !isPaddingOnlyDropped {
+		// in-order - either packet with payload OR padding only packet not dropped by the padding policy
 		r.extHighestIncomingSN = extPkt.ExtSequenceNumber
 
 		ordering := SequenceNumberOrderingContiguous
@@ -203,6 +294,9 @@ func (r *RTPMunger) UpdateAndGetSnTs(extPkt *buffer.ExtPacket, marker bool) (Tra
 		r.extLastSN = extMungedSN
 		r.extSecondLastTS = r.extLastTS
 		r.extLastTS = extMungedTS
+		if int64(extMungedTS-r.extHighestTS) > 0 {
+			r.extHighestTS = extMungedTS
+		}
 		r.secondLastMarker = r.lastMarker
 		r.lastMarker = marker
 
@@ -254,8 +348,8 @@ func (r *RTPMunger) UpdateAndGetSnTs(extPkt *buffer.ExtPacket, marker bool) (Tra
 		}, nil
 	}
 
-	// if padding only packet, can be dropped and sequence number adjusted, if contiguous
-	if diff == 1 {
+	// if padding only packet, can be dropped and sequence number adjusted
+	if isPaddingOnlyDropped {
 		r.extHighestIncomingSN = extPkt.ExtSequenceNumber
 
 		if err := r.snRangeMap.ExcludeRange(r.extHighestIncomingSN, r.extHighestIncomingSN+1); err != nil {
@@ -264,8 +358,12 @@ func (r *RTPMunger) UpdateAndGetSnTs(extPkt *buffer.ExtPacket, marker bool) (Tra
 
 		r.updateSnOffset()
 
+		ordering := SequenceNumberOrderingContiguous
+		if diff > 1 {
+			ordering = SequenceNumberOrderingGap
+		}
 		return TranslationParamsRTP{
-			snOrdering: SequenceNumberOrderingContiguous,
+			snOrdering: ordering,
 		}, ErrPaddingOnlyPacket
 	}
 
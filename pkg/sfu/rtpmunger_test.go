@@ -305,6 +305,138 @@ func TestPaddingOnlyPacket(t *testing.T) {
 	require.Equal(t, uint64(1), snOffset)
 }
 
+func TestPaddingPolicy(t *testing.T) {
+	params := &testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+	}
+	extPkt, _ := testutils.GetTestExtPacket(params)
+
+	t.Run("strip", func(t *testing.T) {
+		r := newRTPMunger()
+		r.SetPaddingPolicy(PaddingPolicyStrip)
+		r.SetLastSnTs(extPkt)
+
+		// padding only packet with a gap is dropped too
+		params := &testutils.TestExtPacketParams{
+			SequenceNumber: 23335,
+			Timestamp:      0xabcdef,
+			SSRC:           0x12345678,
+		}
+		paddingPkt, _ := testutils.GetTestExtPacket(params)
+
+		tp, err := r.UpdateAndGetSnTs(paddingPkt, paddingPkt.Packet.Marker)
+		require.ErrorIs(t, err, ErrPaddingOnlyPacket)
+		require.Equal(t, TranslationParamsRTP{snOrdering: SequenceNumberOrderingGap}, tp)
+		require.Equal(t, uint64(23335), r.extHighestIncomingSN)
+		require.Equal(t, uint64(23333), r.extLastSN)
+
+		// the next packet with payload fills the sequence number of the dropped one, keeping the gap
+		params = &testutils.TestExtPacketParams{
+			SequenceNumber: 23336,
+			Timestamp:      0xabcdef,
+			SSRC:           0x12345678,
+			PayloadSize:    10,
+		}
+		mediaPkt, _ := testutils.GetTestExtPacket(params)
+
+		tp, err = r.UpdateAndGetSnTs(mediaPkt, mediaPkt.Packet.Marker)
+		require.NoError(t, err)
+		require.Equal(t, TranslationParamsRTP{
+			snOrdering:        SequenceNumberOrderingContiguous,
+			extSequenceNumber: 23335,
+			extTimestamp:      0xabcdef,
+		}, tp)
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		r := newRTPMunger()
+		r.SetPaddingPolicy(PaddingPolicyForward)
+		r.SetLastSnTs(extPkt)
+
+		// contiguous padding only packet is forwarded
+		tp, err := r.UpdateAndGetSnTs(extPkt, extPkt.Packet.Marker)
+		require.NoError(t, err)
+		require.Equal(t, TranslationParamsRTP{
+			snOrdering:        SequenceNumberOrderingContiguous,
+			extSequenceNumber: 23333,
+			extTimestamp:      0xabcdef,
+		}, tp)
+		require.Equal(t, uint64(23333), r.extHighestIncomingSN)
+		require.Equal(t, uint64(0), r.snOffset)
+	})
+}
+
+func TestKeepalivePolicy(t *testing.T) {
+	// contiguous zero payload packets, keepalive and padding only
+	params := &testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+	}
+	keepalivePkt, _ := testutils.GetTestExtPacket(params)
+	params = &testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+		PaddingSize:    255,
+	}
+	paddingPkt, _ := testutils.GetTestExtPacket(params)
+
+	t.Run("as padding", func(t *testing.T) {
+		r := newRTPMunger()
+		r.SetPaddingPolicy(PaddingPolicyForward)
+		r.SetLastSnTs(keepalivePkt)
+
+		tp, err := r.UpdateAndGetSnTs(keepalivePkt, keepalivePkt.Packet.Marker)
+		require.NoError(t, err)
+		require.Equal(t, uint64(23333), tp.extSequenceNumber)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		r := newRTPMunger()
+		r.SetPaddingPolicy(PaddingPolicyForward)
+		r.SetKeepalivePolicy(KeepalivePolicyDrop)
+		r.SetLastSnTs(keepalivePkt)
+
+		_, err := r.UpdateAndGetSnTs(keepalivePkt, keepalivePkt.Packet.Marker)
+		require.ErrorIs(t, err, ErrPaddingOnlyPacket)
+
+		// padding only packets still follow the padding policy
+		r = newRTPMunger()
+		r.SetPaddingPolicy(PaddingPolicyForward)
+		r.SetKeepalivePolicy(KeepalivePolicyDrop)
+		r.SetLastSnTs(keepalivePkt)
+
+		tp, err := r.UpdateAndGetSnTs(paddingPkt, paddingPkt.Packet.Marker)
+		require.NoError(t, err)
+		require.Equal(t, uint64(23333), tp.extSequenceNumber)
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		r := newRTPMunger()
+		r.SetKeepalivePolicy(KeepalivePolicyForward)
+		r.SetLastSnTs(keepalivePkt)
+
+		tp, err := r.UpdateAndGetSnTs(keepalivePkt, keepalivePkt.Packet.Marker)
+		require.NoError(t, err)
+		require.Equal(t, TranslationParamsRTP{
+			snOrdering:        SequenceNumberOrderingContiguous,
+			extSequenceNumber: 23333,
+			extTimestamp:      0xabcdef,
+		}, tp)
+
+		// padding only packets still follow the padding policy, contiguous ones are dropped by default
+		r = newRTPMunger()
+		r.SetKeepalivePolicy(KeepalivePolicyForward)
+		r.SetLastSnTs(keepalivePkt)
+
+		_, err = r.UpdateAndGetSnTs(paddingPkt, paddingPkt.Packet.Marker)
+		require.ErrorIs(t, err, ErrPaddingOnlyPacket)
+	})
+}
+
 func TestGapInSequenceNumber(t *testing.T) {
 	r := newRTPMunger()
 
@@ -583,3 +715,31 @@ func TestIsOnFrameBoundary(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, r.IsOnFrameBoundary())
 }
+
+func TestUpdateAndGetSnTsReorderedFrames(t *testing.T) {
+	r := newRTPMunger()
+
+	params := &testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+		PayloadSize:    10,
+	}
+	extPkt, _ := testutils.GetTestExtPacket(params)
+	r.SetLastSnTs(extPkt)
+	require.Equal(t, uint64(0xabcdef), r.GetLast().ExtHighestTS)
+
+	// P-frame followed by a B-frame with an earlier timestamp
+	for _, ts := range []uint32{0xabcdef + 9000, 0xabcdef + 3000} {
+		params.SequenceNumber++
+		params.Timestamp = ts
+		extPkt, _ = testutils.GetTestExtPacket(params)
+		tp, err := r.UpdateAndGetSnTs(extPkt, true)
+		require.NoError(t, err)
+		require.Equal(t, uint64(ts), tp.extTimestamp)
+	}
+
+	state := r.GetLast()
+	require.Equal(t, uint64(0xabcdef+3000), state.ExtLastTS)
+	require.Equal(t, uint64(0xabcdef+9000), state.ExtHighestTS)
+}
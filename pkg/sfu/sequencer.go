@@ -26,7 +26,10 @@ import (
 const (
 	defaultRtt           = 70
 	ignoreRetransmission = 100 // Ignore packet retransmission after ignoreRetransmission milliseconds
-	maxAck               = 3
+	defaultMaxAck        = 3
+
+	// MaxRetransmits is the largest configurable number of retransmissions of a packet
+	MaxRetransmits = math.MaxUint8
 )
 
 func btoi(b bool) int {
@@ -66,6 +69,8 @@ type packetMeta struct {
 	nacked uint8
 	// Spatial layer of packet
 	layer int8
+	// Temporal layer of packet, 0 for codecs without temporal layers
+	temporal int8
 	// Information that differs depending on the codec
 	codecBytes       [8]byte
 	numCodecBytesIn  uint8
@@ -83,6 +88,7 @@ type extPacketMeta struct {
 	packetMeta
 	extSequenceNumber uint64
 	extTimestamp      uint64
+	slot              int
 }
 
 // Sequencer stores the packet sequence received by the down track
@@ -98,15 +104,20 @@ type sequencer struct {
 	meta         []packetMeta
 	snRangeMap   *utils.RangeMap[uint64, uint64]
 	rtt          uint32
+	maxAck       uint8
 	logger       logger.Logger
 }
 
-func newSequencer(size int, maybeSparse bool, logger logger.Logger) *sequencer {
+func newSequencer(size int, maybeSparse bool, maxAck int, logger logger.Logger) *sequencer {
+	if maxAck <= 0 || maxAck > MaxRetransmits {
+		maxAck = defaultMaxAck
+	}
 	s := &sequencer{
 		size:      size,
 		startTime: time.Now().UnixNano(),
 		meta:      make([]packetMeta, size),
 		rtt:       defaultRtt,
+		maxAck:    uint8(maxAck),
 		logger:    logger,
 	}
 
@@ -133,6 +144,7 @@ func (s *sequencer) push(
 	extModifiedTS uint64,
 	marker bool,
 	layer int8,
+	temporal int8,
 	codecBytes []byte,
 	numCodecBytesIn int,
 	ddBytes []byte,
@@ -204,6 +216,7 @@ func (s *sequencer) push(
 		timestamp:       uint32(extModifiedTS),
 		marker:          marker,
 		layer:           layer,
+		temporal:        temporal,
 		numCodecBytesIn: uint8(numCodecBytesIn),
 		lastNack:        s.getRefTime(packetTime), // delay retransmissions after the original transmission
 	}
@@ -289,6 +302,34 @@ func (s *sequencer) getExtPacketMetas(seqNo []uint16) []extPacketMeta {
 	s.Lock()
 	defer s.Unlock()
 
+	return s.getExtPacketMetasLocked(seqNo, true)
+}
+
+// peekExtPacketMetas is getExtPacketMetas without recording the NACK, packets that are retransmitted are recorded
+// with markNacked, so that packets that are not, e.g. for lack of retransmit budget, can be retransmitted on the next NACK
+func (s *sequencer) peekExtPacketMetas(seqNo []uint16) []extPacketMeta {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.getExtPacketMetasLocked(seqNo, false)
+}
+
+// markNacked records the NACK of a packet returned by peekExtPacketMetas
+func (s *sequencer) markNacked(epm *extPacketMeta) {
+	s.Lock()
+	defer s.Unlock()
+
+	meta := &s.meta[epm.slot]
+	if meta.targetSeqNo != epm.targetSeqNo || s.isInvalidSlot(epm.slot) {
+		// slot reused since
+		return
+	}
+
+	meta.nacked = epm.nacked
+	meta.lastNack = epm.lastNack
+}
+
+func (s *sequencer) getExtPacketMetasLocked(seqNo []uint16, mark bool) []extPacketMeta {
 	if !s.initialized {
 		return nil
 	}
@@ -334,18 +375,23 @@ func (s *sequencer) getExtPacketMetas(seqNo []uint16) []extPacketMeta {
 			continue
 		}
 
-		if meta.nacked < maxAck && refTime-meta.lastNack > uint32(math.Min(float64(ignoreRetransmission), float64(2*s.rtt))) {
-			meta.nacked++
-			meta.lastNack = refTime
+		if meta.nacked < s.maxAck && refTime-meta.lastNack > uint32(math.Min(float64(ignoreRetransmission), float64(2*s.rtt))) {
+			nackedMeta := *meta
+			nackedMeta.nacked++
+			nackedMeta.lastNack = refTime
+			if mark {
+				*meta = nackedMeta
+			}
 
 			extTS := uint64(meta.timestamp) + (s.extHighestTS & 0xFFFF_FFFF_0000_0000)
 			if meta.timestamp > highestTS {
 				extTS -= (1 << 32)
 			}
 			epm := extPacketMeta{
-				packetMeta:        *meta,
+				packetMeta:        nackedMeta,
 				extSequenceNumber: extSN,
 				extTimestamp:      extTS,
+				slot:              int(slot),
 			}
 			epm.codecBytesSlice = append([]byte{}, meta.codecBytesSlice...)
 			epm.ddBytesSlice = append([]byte{}, meta.ddBytesSlice...)
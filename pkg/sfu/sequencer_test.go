@@ -15,6 +15,7 @@
 package sfu
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -25,15 +26,15 @@ import (
 )
 
 func Test_sequencer(t *testing.T) {
-	seq := newSequencer(500, false, logger.GetLogger())
+	seq := newSequencer(500, false, 0, logger.GetLogger())
 	off := uint16(15)
 
 	for i := uint64(1); i < 518; i++ {
-		seq.push(time.Now().UnixNano(), i, i+uint64(off), 123, true, 2, nil, 0, nil, nil)
+		seq.push(time.Now().UnixNano(), i, i+uint64(off), 123, true, 2, 0, nil, 0, nil, nil)
 	}
 	// send the last two out-of-order
-	seq.push(time.Now().UnixNano(), 519, 519+uint64(off), 123, false, 2, nil, 0, nil, nil)
-	seq.push(time.Now().UnixNano(), 518, 518+uint64(off), 123, true, 2, nil, 0, nil, nil)
+	seq.push(time.Now().UnixNano(), 519, 519+uint64(off), 123, false, 2, 0, nil, 0, nil, nil)
+	seq.push(time.Now().UnixNano(), 518, 518+uint64(off), 123, true, 2, 0, nil, 0, nil, nil)
 
 	req := []uint16{57, 58, 62, 63, 513, 514, 515, 516, 517}
 	res := seq.getExtPacketMetas(req)
@@ -63,14 +64,14 @@ func Test_sequencer(t *testing.T) {
 		require.Equal(t, val.extTimestamp, uint64(123))
 	}
 
-	seq.push(time.Now().UnixNano(), 521, 521+uint64(off), 123, true, 1, nil, 0, nil, nil)
+	seq.push(time.Now().UnixNano(), 521, 521+uint64(off), 123, true, 1, 0, nil, 0, nil, nil)
 	m := seq.getExtPacketMetas([]uint16{521 + off})
 	require.Equal(t, 0, len(m))
 	time.Sleep((ignoreRetransmission + 10) * time.Millisecond)
 	m = seq.getExtPacketMetas([]uint16{521 + off})
 	require.Equal(t, 1, len(m))
 
-	seq.push(time.Now().UnixNano(), 505, 505+uint64(off), 123, false, 1, nil, 0, nil, nil)
+	seq.push(time.Now().UnixNano(), 505, 505+uint64(off), 123, false, 1, 0, nil, 0, nil, nil)
 	m = seq.getExtPacketMetas([]uint16{505 + off})
 	require.Equal(t, 0, len(m))
 	time.Sleep((ignoreRetransmission + 10) * time.Millisecond)
@@ -78,6 +79,47 @@ func Test_sequencer(t *testing.T) {
 	require.Equal(t, 1, len(m))
 }
 
+func Test_sequencer_peekAndMarkNacked(t *testing.T) {
+	seq := newSequencer(100, false, 0, logger.GetLogger())
+	seq.push(time.Now().UnixNano(), 1, 1, 123, true, 0, 0, nil, 0, nil, nil)
+	seq.push(time.Now().UnixNano(), 2, 2, 123, true, 0, 0, nil, 0, nil, nil)
+	time.Sleep((ignoreRetransmission + 10) * time.Millisecond)
+
+	// peeking does not record the NACK
+	m := seq.peekExtPacketMetas([]uint16{1, 2})
+	require.Len(t, m, 2)
+	require.Equal(t, uint8(1), m[0].nacked)
+	m = seq.peekExtPacketMetas([]uint16{1, 2})
+	require.Len(t, m, 2)
+
+	// NACK of a packet marked as retransmitted is ignored for a while, the other one is not
+	seq.markNacked(&m[0])
+	m = seq.getExtPacketMetas([]uint16{1, 2})
+	require.Len(t, m, 1)
+	require.Equal(t, uint16(2), m[0].targetSeqNo)
+}
+
+func Test_sequencer_maxRetransmits(t *testing.T) {
+	for _, maxRetransmits := range []int{0, 1, 5} {
+		t.Run(fmt.Sprintf("max %d", maxRetransmits), func(t *testing.T) {
+			seq := newSequencer(100, false, maxRetransmits, logger.GetLogger())
+			seq.setRTT(1)
+			seq.push(time.Now().UnixNano(), 1, 1, 123, true, 0, 0, nil, 0, nil, nil)
+
+			expected := maxRetransmits
+			if expected == 0 {
+				expected = defaultMaxAck
+			}
+			retransmits := 0
+			for i := 0; i < expected+3; i++ {
+				time.Sleep(5 * time.Millisecond)
+				retransmits += len(seq.getExtPacketMetas([]uint16{1}))
+			}
+			require.Equal(t, expected, retransmits)
+		})
+	}
+}
+
 func Test_sequencer_getNACKSeqNo_exclusion(t *testing.T) {
 	type args struct {
 		seqNo []uint16
@@ -149,7 +191,7 @@ func Test_sequencer_getNACKSeqNo_exclusion(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			n := newSequencer(5, true, logger.GetLogger())
+			n := newSequencer(5, true, 0, logger.GetLogger())
 
 			for _, i := range tt.fields.inputs {
 				if i.isPadding {
@@ -163,6 +205,7 @@ func Test_sequencer_getNACKSeqNo_exclusion(t *testing.T) {
 							123,
 							tt.fields.markerOdd,
 							3,
+							0,
 							tt.fields.codecBytesOversized,
 							len(tt.fields.codecBytesOversized),
 							tt.fields.ddBytesOversized,
@@ -177,6 +220,7 @@ func Test_sequencer_getNACKSeqNo_exclusion(t *testing.T) {
 								123,
 								tt.fields.markerEven,
 								3,
+								0,
 								tt.fields.codecBytesEven,
 								tt.fields.numCodecBytesInEven,
 								tt.fields.ddBytesEven,
@@ -190,6 +234,7 @@ func Test_sequencer_getNACKSeqNo_exclusion(t *testing.T) {
 								123,
 								tt.fields.markerOdd,
 								3,
+								0,
 								tt.fields.codecBytesOdd,
 								tt.fields.numCodecBytesInOdd,
 								tt.fields.ddBytesOdd,
@@ -303,7 +348,7 @@ func Test_sequencer_getNACKSeqNo_no_exclusion(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			n := newSequencer(5, false, logger.GetLogger())
+			n := newSequencer(5, false, 0, logger.GetLogger())
 
 			for _, i := range tt.fields.inputs {
 				if i.isPadding {
@@ -317,6 +362,7 @@ func Test_sequencer_getNACKSeqNo_no_exclusion(t *testing.T) {
 							123,
 							tt.fields.markerEven,
 							3,
+							0,
 							tt.fields.codecBytesEven,
 							tt.fields.numCodecBytesInEven,
 							tt.fields.ddBytesEven,
@@ -330,6 +376,7 @@ func Test_sequencer_getNACKSeqNo_no_exclusion(t *testing.T) {
 							123,
 							tt.fields.markerOdd,
 							3,
+							0,
 							tt.fields.codecBytesOdd,
 							tt.fields.numCodecBytesInOdd,
 							tt.fields.ddBytesOdd,
@@ -0,0 +1,133 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	egressBudgetAllocateInterval = 500 * time.Millisecond
+)
+
+// ------------------------------------------------
+
+// EgressBudget splits a node wide limit on the bitrate of video sent to subscribers between the stream allocators
+// of a node. Each allocator declares the bitrate its tracks need, by track priority, and is granted a share of the
+// limit. Shares are granted to higher priorities first, allocators with the same priority share it fairly
+// (max-min), so lower priority tracks of the node are throttled first. Capacity left after all demands are granted
+// is headroom, split evenly between the allocators until the next allocation, so that the sum of what allocators
+// may use never exceeds the limit.
+type EgressBudget struct {
+	limit int64
+
+	lock        sync.Mutex
+	demands     map[*StreamAllocator]map[uint8]int64
+	shares      map[*StreamAllocator]int64
+	headroom    int64
+	allocatedAt time.Time
+}
+
+func NewEgressBudget(limit int64) *EgressBudget {
+	return &EgressBudget{
+		limit:    limit,
+		demands:  make(map[*StreamAllocator]map[uint8]int64),
+		shares:   make(map[*StreamAllocator]int64),
+		headroom: limit,
+	}
+}
+
+func (b *EgressBudget) Limit() int64 {
+	return b.limit
+}
+
+// Update declares the bitrate needed by the tracks of a stream allocator, keyed by track priority,
+// and returns the bitrate the allocator may use
+func (b *EgressBudget) Update(s *StreamAllocator, demand map[uint8]int64) int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.demands[s] = demand
+	if _, ok := b.shares[s]; !ok || time.Since(b.allocatedAt) >= egressBudgetAllocateInterval {
+		b.allocate()
+	}
+	return b.shares[s] + b.headroom/int64(len(b.demands))
+}
+
+func (b *EgressBudget) Remove(s *StreamAllocator) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.demands, s)
+	delete(b.shares, s)
+}
+
+func (b *EgressBudget) allocate() {
+	priorities := make([]uint8, 0, 8)
+	for s, demand := range b.demands {
+		b.shares[s] = 0
+		for priority := range demand {
+			priorities = append(priorities, priority)
+		}
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		return priorities[i] > priorities[j]
+	})
+
+	remaining := b.limit
+	for idx, priority := range priorities {
+		if idx > 0 && priorities[idx-1] == priority {
+			continue
+		}
+
+		type allocatorDemand struct {
+			s      *StreamAllocator
+			demand int64
+		}
+		var demands []allocatorDemand
+		total := int64(0)
+		for s, demand := range b.demands {
+			if demand[priority] > 0 {
+				demands = append(demands, allocatorDemand{s: s, demand: demand[priority]})
+				total += demand[priority]
+			}
+		}
+		if total <= remaining {
+			for _, d := range demands {
+				b.shares[d.s] += d.demand
+			}
+			remaining -= total
+			continue
+		}
+
+		// not enough for this priority, share fairly, smaller demands are granted in full first,
+		// lower priorities get nothing
+		sort.Slice(demands, func(i, j int) bool {
+			return demands[i].demand < demands[j].demand
+		})
+		for i, d := range demands {
+			granted := min(d.demand, remaining/int64(len(demands)-i))
+			b.shares[d.s] += granted
+			remaining -= granted
+		}
+		remaining = 0
+		break
+	}
+
+	b.headroom = remaining
+	b.allocatedAt = time.Now()
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressBudget(t *testing.T) {
+	t.Run("within limit", func(t *testing.T) {
+		b := NewEgressBudget(10_000_000)
+		s1, s2 := &StreamAllocator{}, &StreamAllocator{}
+		b.Update(s1, map[uint8]int64{PriorityDefaultVideo: 2_000_000})
+		b.Update(s2, map[uint8]int64{PriorityDefaultVideo: 3_000_000})
+
+		// demands are granted, what is left is split between subscribers
+		require.EqualValues(t, 4_500_000, b.Update(s1, map[uint8]int64{PriorityDefaultVideo: 2_000_000}))
+		require.EqualValues(t, 5_500_000, b.Update(s2, map[uint8]int64{PriorityDefaultVideo: 3_000_000}))
+	})
+
+	t.Run("headroom does not exceed limit", func(t *testing.T) {
+		limit := int64(10_000_000)
+		b := NewEgressBudget(limit)
+
+		var allocators []*StreamAllocator
+		for i := 0; i < 5; i++ {
+			s := &StreamAllocator{}
+			allocators = append(allocators, s)
+			b.Update(s, map[uint8]int64{PriorityDefaultVideo: 500_000})
+		}
+
+		// even if every subscriber uses all it is allowed, the node stays within the limit
+		total := int64(0)
+		for _, s := range allocators {
+			total += b.Update(s, map[uint8]int64{PriorityDefaultVideo: 500_000})
+		}
+		require.LessOrEqual(t, total, limit)
+		require.Greater(t, total, limit*99/100)
+	})
+
+	t.Run("lower priority throttled first", func(t *testing.T) {
+		b := NewEgressBudget(5_000_000)
+		s1, s2 := &StreamAllocator{}, &StreamAllocator{}
+		b.Update(s1, map[uint8]int64{PriorityDefaultScreenshare: 3_000_000, PriorityDefaultVideo: 2_000_000})
+		b.Update(s2, map[uint8]int64{PriorityDefaultVideo: 2_000_000})
+
+		// screen share is granted in full, cameras share the rest
+		require.EqualValues(t, 4_000_000, b.shares[s1])
+		require.EqualValues(t, 1_000_000, b.shares[s2])
+		require.Zero(t, b.headroom)
+	})
+
+	t.Run("fair share", func(t *testing.T) {
+		b := NewEgressBudget(6_000_000)
+		s1, s2, s3 := &StreamAllocator{}, &StreamAllocator{}, &StreamAllocator{}
+		b.Update(s1, map[uint8]int64{PriorityDefaultVideo: 1_000_000})
+		b.Update(s2, map[uint8]int64{PriorityDefaultVideo: 4_000_000})
+		b.Update(s3, map[uint8]int64{PriorityDefaultVideo: 8_000_000})
+
+		// small demand is granted in full, the others split what is left
+		require.EqualValues(t, 1_000_000, b.shares[s1])
+		require.EqualValues(t, 2_500_000, b.shares[s2])
+		require.EqualValues(t, 2_500_000, b.shares[s3])
+
+		// removed subscriber releases its share
+		b.Remove(s3)
+		b.allocate()
+		require.EqualValues(t, 1_000_000, b.shares[s1])
+		require.EqualValues(t, 4_000_000, b.shares[s2])
+		require.EqualValues(t, 1_000_000, b.headroom)
+	})
+
+	t.Run("aggregate under load", func(t *testing.T) {
+		limit := int64(50_000_000)
+		b := NewEgressBudget(limit)
+
+		demands := make(map[*StreamAllocator]map[uint8]int64)
+		for i := 0; i < 100; i++ {
+			s := &StreamAllocator{}
+			demands[s] = map[uint8]int64{
+				PriorityDefaultVideo: int64(500_000 + (i%7)*250_000),
+			}
+			if i%10 == 0 {
+				demands[s][PriorityDefaultScreenshare] = int64(1_000_000 + (i%3)*500_000)
+			}
+			b.Update(s, demands[s])
+		}
+
+		// each subscriber sends at most what it needs and what it is allowed
+		total := int64(0)
+		for s, demand := range demands {
+			needed := int64(0)
+			for _, bitrate := range demand {
+				needed += bitrate
+			}
+			total += min(needed, b.Update(s, demand))
+		}
+		require.LessOrEqual(t, total, limit)
+		require.Greater(t, total, limit*99/100)
+	})
+}
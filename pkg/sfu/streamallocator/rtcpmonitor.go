@@ -0,0 +1,101 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ------------------------------------------------
+
+// RTCPMonitor detects a subscriber that does not send RTCP feedback while media is being sent to it.
+// Sends and feedback can be recorded from any goroutine, Update is expected to be called from a single goroutine.
+type RTCPMonitor struct {
+	timeout time.Duration
+
+	firstSentAt    atomic.Int64
+	lastSentAt     atomic.Int64
+	lastFeedbackAt atomic.Int64
+
+	timedOutAt time.Time
+}
+
+func NewRTCPMonitor(timeout time.Duration) *RTCPMonitor {
+	return &RTCPMonitor{
+		timeout: timeout,
+	}
+}
+
+func (r *RTCPMonitor) IsEnabled() bool {
+	return r.timeout > 0
+}
+
+func (r *RTCPMonitor) PacketsSent(at time.Time) {
+	if !r.IsEnabled() {
+		return
+	}
+
+	sentAt := at.UnixNano()
+	r.firstSentAt.CompareAndSwap(0, sentAt)
+	r.lastSentAt.Store(sentAt)
+}
+
+func (r *RTCPMonitor) FeedbackReceived(at time.Time) {
+	if !r.IsEnabled() || at.IsZero() {
+		return
+	}
+
+	// feedback times could be reported out of order, keep the latest
+	receivedAt := at.UnixNano()
+	for {
+		lastFeedbackAt := r.lastFeedbackAt.Load()
+		if receivedAt <= lastFeedbackAt || r.lastFeedbackAt.CompareAndSwap(lastFeedbackAt, receivedAt) {
+			return
+		}
+	}
+}
+
+// Update returns whether the monitor is timed out and whether that changed since the last call.
+// It times out when there has been no feedback for the timeout since media started being sent or since
+// the last feedback, as long as media is still being sent. It recovers when feedback arrives after timing out.
+func (r *RTCPMonitor) Update(now time.Time) (isTimedOut bool, isChanged bool) {
+	if !r.IsEnabled() {
+		return false, false
+	}
+
+	lastFeedbackAt := r.lastFeedbackAt.Load()
+	if !r.timedOutAt.IsZero() {
+		if lastFeedbackAt >= r.timedOutAt.UnixNano() {
+			r.timedOutAt = time.Time{}
+			return false, true
+		}
+		return true, false
+	}
+
+	firstSentAt := r.firstSentAt.Load()
+	if firstSentAt == 0 {
+		return false, false
+	}
+
+	silentSince := max(firstSentAt, lastFeedbackAt)
+	if now.Sub(time.Unix(0, silentSince)) < r.timeout || now.Sub(time.Unix(0, r.lastSentAt.Load())) >= r.timeout {
+		return false, false
+	}
+
+	r.timedOutAt = now
+	return true, true
+}
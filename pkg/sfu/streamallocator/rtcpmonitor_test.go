@@ -0,0 +1,94 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTCPMonitor(t *testing.T) {
+	timeout := 5 * time.Second
+	start := time.Now()
+
+	t.Run("disabled", func(t *testing.T) {
+		r := NewRTCPMonitor(0)
+		r.PacketsSent(start)
+		r.PacketsSent(start.Add(time.Hour))
+		isTimedOut, isChanged := r.Update(start.Add(time.Hour))
+		require.False(t, isTimedOut)
+		require.False(t, isChanged)
+	})
+
+	t.Run("nothing sent", func(t *testing.T) {
+		r := NewRTCPMonitor(timeout)
+		isTimedOut, isChanged := r.Update(start.Add(time.Hour))
+		require.False(t, isTimedOut)
+		require.False(t, isChanged)
+	})
+
+	t.Run("times out at timeout", func(t *testing.T) {
+		r := NewRTCPMonitor(timeout)
+		r.PacketsSent(start)
+		r.PacketsSent(start.Add(timeout - time.Millisecond))
+
+		isTimedOut, isChanged := r.Update(start.Add(timeout - time.Millisecond))
+		require.False(t, isTimedOut)
+		require.False(t, isChanged)
+
+		isTimedOut, isChanged = r.Update(start.Add(timeout))
+		require.True(t, isTimedOut)
+		require.True(t, isChanged)
+
+		// reported only once
+		isTimedOut, isChanged = r.Update(start.Add(2 * timeout))
+		require.True(t, isTimedOut)
+		require.False(t, isChanged)
+
+		// recovers on feedback
+		r.FeedbackReceived(start.Add(2*timeout + time.Millisecond))
+		isTimedOut, isChanged = r.Update(start.Add(2*timeout + time.Millisecond))
+		require.False(t, isTimedOut)
+		require.True(t, isChanged)
+	})
+
+	t.Run("timeout restarts on feedback", func(t *testing.T) {
+		r := NewRTCPMonitor(timeout)
+		r.PacketsSent(start)
+		r.FeedbackReceived(start.Add(time.Second))
+		// out of order feedback does not move back the last feedback
+		r.FeedbackReceived(start)
+		r.PacketsSent(start.Add(timeout + time.Second))
+
+		isTimedOut, _ := r.Update(start.Add(timeout))
+		require.False(t, isTimedOut)
+
+		isTimedOut, isChanged := r.Update(start.Add(timeout + time.Second))
+		require.True(t, isTimedOut)
+		require.True(t, isChanged)
+	})
+
+	t.Run("not sending", func(t *testing.T) {
+		r := NewRTCPMonitor(timeout)
+		r.PacketsSent(start)
+
+		// no feedback is expected when media is not being sent
+		isTimedOut, isChanged := r.Update(start.Add(timeout))
+		require.False(t, isTimedOut)
+		require.False(t, isChanged)
+	})
+}
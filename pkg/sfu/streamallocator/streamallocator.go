@@ -15,8 +15,11 @@
 package streamallocator
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -37,6 +40,8 @@ import (
 const (
 	ChannelCapacityInfinity = 100 * 1000 * 1000 // 100 Mbps
 
+	NoRTCPChannelCapacityDefault = 300 * 1000 // 300 kbps
+
 	PriorityMin                = uint8(1)
 	PriorityMax                = uint8(255)
 	PriorityDefaultScreenshare = PriorityMax
@@ -50,6 +55,42 @@ const (
 	FlagAllowOvershootInBoost                   = true
 )
 
+var (
+	ErrPriorityChangeNotAllowed  = errors.New("track priority change not allowed")
+	ErrPriorityChangeTooFrequent = errors.New("track priority changed too frequently")
+)
+
+// ---------------------------------------------------------------------------
+
+// SourcePrioritiesFromConfig parses default track priorities keyed by track source name
+func SourcePrioritiesFromConfig(sourcePriorities map[string]uint8) (map[livekit.TrackSource]uint8, error) {
+	priorities := make(map[livekit.TrackSource]uint8, len(sourcePriorities))
+	for name, priority := range sourcePriorities {
+		source, ok := livekit.TrackSource_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown track source %q", name)
+		}
+		if priority < PriorityMin {
+			return nil, fmt.Errorf("invalid priority %d for track source %s", priority, name)
+		}
+		priorities[livekit.TrackSource(source)] = priority
+	}
+	return priorities, nil
+}
+
+func defaultPriorityForSource(source livekit.TrackSource, sourcePriorities map[livekit.TrackSource]uint8) uint8 {
+	if priority, ok := sourcePriorities[source]; ok {
+		return priority
+	}
+
+	switch source {
+	case livekit.TrackSource_SCREEN_SHARE:
+		return PriorityDefaultScreenshare
+	default:
+		return PriorityDefaultVideo
+	}
+}
+
 // ---------------------------------------------------------------------------
 
 type streamAllocatorState int
@@ -137,23 +178,46 @@ func (e Event) String() string {
 
 // ---------------------------------------------------------------------------
 
+// BandwidthEstimate is the latest channel capacity estimate of a connection
+type BandwidthEstimate struct {
+	// estimated channel capacity in bps
+	Estimate int64
+	// trend of the channel after applying the estimate
+	Trend ChannelTrend
+	// true if estimated locally from transport-cc feedback, false if reported by the remote via REMB
+	SendSide bool
+	At       time.Time
+}
+
+// ---------------------------------------------------------------------------
+
 type StreamAllocatorParams struct {
 	Config config.CongestionControlConfig
-	Logger logger.Logger
+	// node wide limit on video sent to subscribers, shared with the other stream allocators of the node, optional
+	EgressBudget *EgressBudget
+	Logger       logger.Logger
 }
 
 type StreamAllocator struct {
 	params StreamAllocatorParams
 
 	onStreamStateChange func(update *StreamStateUpdate) error
+	onNoRTCP            func()
 
 	bwe cc.BandwidthEstimator
 
 	allowPause bool
 
+	sourcePriorities map[livekit.TrackSource]uint8
+
 	lastReceivedEstimate      int64
+	bandwidthEstimate         atomic.Pointer[BandwidthEstimate]
 	committedChannelCapacity  int64
 	overriddenChannelCapacity int64
+	noRTCPChannelCapacity     int64
+	egressShare               int64
+
+	rtcpMonitor *RTCPMonitor
 
 	probeController *ProbeController
 
@@ -175,13 +239,23 @@ type StreamAllocator struct {
 }
 
 func NewStreamAllocator(params StreamAllocatorParams) *StreamAllocator {
+	sourcePriorities, err := SourcePrioritiesFromConfig(params.Config.SourcePriorities)
+	if err != nil {
+		params.Logger.Warnw("invalid source priorities, using defaults", err)
+		sourcePriorities = nil
+	}
+
 	s := &StreamAllocator{
 		params:     params,
-		allowPause: params.Config.AllowPause,
+		allowPause: params.Config.IsPauseAllowed(),
+
+		sourcePriorities: sourcePriorities,
 		prober: NewProber(ProberParams{
 			Logger: params.Logger,
 		}),
 		// STREAM-ALLOCATOR-DATA rateMonitor: NewRateMonitor(),
+		rtcpMonitor: NewRTCPMonitor(params.Config.NoRTCPTimeout),
+		egressShare: ChannelCapacityInfinity,
 		videoTracks: make(map[livekit.TrackID]*Track),
 		eventsQueue: utils.NewTypedOpsQueue[Event](utils.OpsQueueParams{
 			Name:    "stream-allocator",
@@ -216,12 +290,22 @@ func (s *StreamAllocator) Stop() {
 	// wait for eventsQueue to be done
 	<-s.eventsQueue.Stop()
 	s.probeController.StopProbe()
+
+	if s.params.EgressBudget != nil {
+		s.params.EgressBudget.Remove(s)
+	}
 }
 
 func (s *StreamAllocator) OnStreamStateChange(f func(update *StreamStateUpdate) error) {
 	s.onStreamStateChange = f
 }
 
+// OnNoRTCP is called when the subscriber has not sent RTCP feedback for the configured timeout
+// and the fallback is to disconnect
+func (s *StreamAllocator) OnNoRTCP(f func()) {
+	s.onNoRTCP = f
+}
+
 func (s *StreamAllocator) SetBandwidthEstimator(bwe cc.BandwidthEstimator) {
 	if bwe != nil {
 		bwe.OnTargetBitrateChange(s.onTargetBitrateChange)
@@ -241,8 +325,17 @@ func (s *StreamAllocator) AddTrack(downTrack *sfu.DownTrack, params AddTrackPara
 		return
 	}
 
-	track := NewTrack(downTrack, params.Source, params.IsSimulcast, params.PublisherID, s.params.Logger)
-	track.SetPriority(params.Priority)
+	track := NewTrack(
+		downTrack,
+		params.Source,
+		defaultPriorityForSource(params.Source, s.sourcePriorities),
+		params.IsSimulcast,
+		params.PublisherID,
+		s.params.Logger,
+	)
+	if s.params.Config.PriorityChanges == config.PriorityChangePolicyAllow {
+		track.SetPriority(params.Priority)
+	}
 
 	trackID := livekit.TrackID(downTrack.ID())
 	s.videoTracksMu.Lock()
@@ -276,19 +369,51 @@ func (s *StreamAllocator) RemoveTrack(downTrack *sfu.DownTrack) {
 	})
 }
 
-func (s *StreamAllocator) SetTrackPriority(downTrack *sfu.DownTrack, priority uint8) {
+// SetTrackPriority applies a priority requested by the subscriber, 0 restores the default priority of the track
+// source. Changes are ignored unless allowed by config, and rejected if denied or more frequent than the configured
+// min interval
+func (s *StreamAllocator) SetTrackPriority(downTrack *sfu.DownTrack, priority uint8) error {
 	s.videoTracksMu.Lock()
-	if track := s.videoTracks[livekit.TrackID(downTrack.ID())]; track != nil {
-		changed := track.SetPriority(priority)
-		if changed && !s.isAllocateAllPending {
-			// do a full allocation on a track priority change to keep it simple
-			s.isAllocateAllPending = true
-			s.postEvent(Event{
-				Signal: streamAllocatorSignalAllocateAllTracks,
-			})
+	defer s.videoTracksMu.Unlock()
+
+	track := s.videoTracks[livekit.TrackID(downTrack.ID())]
+	if track == nil {
+		return nil
+	}
+
+	changed, err := s.changeTrackPriority(track, priority)
+	if err != nil {
+		return err
+	}
+	if changed && !s.isAllocateAllPending {
+		// do a full allocation on a track priority change to keep it simple
+		s.isAllocateAllPending = true
+		s.postEvent(Event{
+			Signal: streamAllocatorSignalAllocateAllTracks,
+		})
+	}
+	return nil
+}
+
+func (s *StreamAllocator) changeTrackPriority(track *Track, priority uint8) (bool, error) {
+	if !track.IsPriorityChange(priority) {
+		return false, nil
+	}
+
+	switch s.params.Config.PriorityChanges {
+	case config.PriorityChangePolicyAllow:
+	case config.PriorityChangePolicyDeny:
+		return false, ErrPriorityChangeNotAllowed
+	default:
+		return false, nil
+	}
+	if minInterval := s.params.Config.PriorityChangeMinInterval; minInterval > 0 {
+		if changedAt := track.PriorityChangedAt(); !changedAt.IsZero() && time.Since(changedAt) < minInterval {
+			return false, ErrPriorityChangeTooFrequent
 		}
 	}
-	s.videoTracksMu.Unlock()
+
+	return track.ChangePriority(priority), nil
 }
 
 func (s *StreamAllocator) SetAllowPause(allowPause bool) {
@@ -298,6 +423,11 @@ func (s *StreamAllocator) SetAllowPause(allowPause bool) {
 	})
 }
 
+// GetBandwidthEstimate returns the last received estimate, nil if there has not been one yet
+func (s *StreamAllocator) GetBandwidthEstimate() *BandwidthEstimate {
+	return s.bandwidthEstimate.Load()
+}
+
 func (s *StreamAllocator) SetChannelCapacity(channelCapacity int64) {
 	s.postEvent(Event{
 		Signal: streamAllocatorSignalSetChannelCapacity,
@@ -314,6 +444,8 @@ func (s *StreamAllocator) resetState() {
 
 // called when a new REMB is received (receive side bandwidth estimation)
 func (s *StreamAllocator) OnREMB(downTrack *sfu.DownTrack, remb *rtcp.ReceiverEstimatedMaximumBitrate) {
+	s.rtcpMonitor.FeedbackReceived(time.Now())
+
 	//
 	// Channel capacity is estimated at a peer connection level. All down tracks
 	// in the peer connection will end up calling this for a REMB report with
@@ -394,6 +526,8 @@ func (s *StreamAllocator) OnREMB(downTrack *sfu.DownTrack, remb *rtcp.ReceiverEs
 
 // called when a new transport-cc feedback is received
 func (s *StreamAllocator) OnTransportCCFeedback(downTrack *sfu.DownTrack, fb *rtcp.TransportLayerCC) {
+	s.rtcpMonitor.FeedbackReceived(time.Now())
+
 	if s.bwe != nil {
 		s.bwe.WriteRTCP([]rtcp.Packet{fb}, nil)
 	}
@@ -432,12 +566,14 @@ func (s *StreamAllocator) OnSubscriptionChanged(downTrack *sfu.DownTrack) {
 	s.maybePostEventAllocateTrack(downTrack)
 }
 
-// called when subscribed layer changes (limiting max layer)
+// called when subscribed layer changes (limiting max layer or pinning a spatial layer)
 func (s *StreamAllocator) OnSubscribedLayerChanged(downTrack *sfu.DownTrack, layer buffer.VideoLayer) {
 	shouldPost := false
 	s.videoTracksMu.Lock()
 	if track := s.videoTracks[livekit.TrackID(downTrack.ID())]; track != nil {
-		if track.SetMaxLayer(layer) && track.SetDirty(true) {
+		maxLayerChanged := track.SetMaxLayer(layer)
+		pinnedChanged := track.SetPinned(downTrack.IsSpatialLayerPinned())
+		if (maxLayerChanged || pinnedChanged) && track.SetDirty(true) {
 			shouldPost = true
 		}
 	}
@@ -462,6 +598,7 @@ func (s *StreamAllocator) OnResume(downTrack *sfu.DownTrack) {
 // called by a video DownTrack to report packet send
 func (s *StreamAllocator) OnPacketsSent(downTrack *sfu.DownTrack, size int) {
 	s.prober.PacketsSent(size)
+	s.rtcpMonitor.PacketsSent(time.Now())
 }
 
 /* STREAM-ALLOCATOR-DATA
@@ -642,11 +779,19 @@ func (s *StreamAllocator) handleSignalEstimate(event Event) {
 	// s.monitorRate(receivedEstimate)
 
 	// while probing, maintain estimate separately to enable keeping current committed estimate if probe fails
+	var trend ChannelTrend
 	if s.probeController.IsInProbe() {
-		s.handleNewEstimateInProbe()
+		trend = s.handleNewEstimateInProbe()
 	} else {
-		s.handleNewEstimateInNonProbe()
+		trend = s.handleNewEstimateInNonProbe()
 	}
+
+	s.bandwidthEstimate.Store(&BandwidthEstimate{
+		Estimate: receivedEstimate,
+		Trend:    trend,
+		SendSide: s.bwe != nil,
+		At:       time.Now(),
+	})
 }
 
 func (s *StreamAllocator) handleSignalPeriodicPing(Event) {
@@ -666,9 +811,80 @@ func (s *StreamAllocator) handleSignalPeriodicPing(Event) {
 		s.maybeProbe()
 	}
 
+	s.updateRTCPMonitor()
+	s.updateEgressBudget()
+
 	// s.updateTracksHistory()
 }
 
+func (s *StreamAllocator) updateRTCPMonitor() {
+	if !s.rtcpMonitor.IsEnabled() {
+		return
+	}
+
+	for _, track := range s.getTracks() {
+		s.rtcpMonitor.FeedbackReceived(track.DownTrack().GetLastReceiverReportTime())
+	}
+
+	isTimedOut, isChanged := s.rtcpMonitor.Update(time.Now())
+	if !isChanged {
+		return
+	}
+
+	if !isTimedOut {
+		if s.noRTCPChannelCapacity > 0 {
+			s.params.Logger.Infow("RTCP feedback resumed, clearing no RTCP channel capacity")
+			s.noRTCPChannelCapacity = 0
+			s.allocateAllTracks()
+		}
+		return
+	}
+
+	switch s.params.Config.NoRTCPFallback {
+	case config.NoRTCPFallbackDisconnect:
+		s.params.Logger.Infow("no RTCP feedback, disconnecting", "timeout", s.params.Config.NoRTCPTimeout)
+		if s.onNoRTCP != nil {
+			s.onNoRTCP()
+		}
+
+	default:
+		s.noRTCPChannelCapacity = s.params.Config.NoRTCPChannelCapacity
+		if s.noRTCPChannelCapacity <= 0 {
+			s.noRTCPChannelCapacity = NoRTCPChannelCapacityDefault
+		}
+		s.params.Logger.Infow(
+			"no RTCP feedback, allocating on no RTCP channel capacity",
+			"timeout", s.params.Config.NoRTCPTimeout,
+			"capacity", s.noRTCPChannelCapacity,
+		)
+		s.allocateAllTracks()
+	}
+}
+
+func (s *StreamAllocator) updateEgressBudget() {
+	if s.params.EgressBudget == nil {
+		return
+	}
+
+	demand := make(map[uint8]int64)
+	for _, track := range s.getTracks() {
+		demand[track.Priority()] += track.OptimalBandwidthNeeded()
+	}
+
+	egressShare := s.params.EgressBudget.Update(s, demand)
+	if egressShare == s.egressShare {
+		return
+	}
+
+	// re-allocate when the share no longer covers what is being sent or when it grew while deficient
+	isDecreased := egressShare < s.egressShare
+	s.egressShare = egressShare
+	if (isDecreased && s.getExpectedBandwidthUsage() > egressShare) || (!isDecreased && s.state == streamAllocatorStateDeficient) {
+		s.params.Logger.Debugw("stream allocator: egress share changed", "share", egressShare, "decreased", isDecreased)
+		s.allocateAllTracks()
+	}
+}
+
 func (s *StreamAllocator) handleSignalSendProbe(event Event) {
 	bytesToSend := event.Data.(int)
 	if bytesToSend <= 0 {
@@ -773,13 +989,14 @@ func (s *StreamAllocator) adjustState() {
 	s.setState(streamAllocatorStateStable)
 }
 
-func (s *StreamAllocator) handleNewEstimateInProbe() {
+func (s *StreamAllocator) handleNewEstimateInProbe() ChannelTrend {
 	// always update NACKs, even if aborted
 	packetDelta, repeatedNackDelta := s.getNackDelta()
 
 	if s.probeController.DoesProbeNeedFinalize() {
 		// waiting for aborted probe to finalize
-		return
+		trend, _ := s.channelObserver.GetTrend()
+		return trend
 	}
 
 	s.channelObserver.AddEstimate(s.lastReceivedEstimate)
@@ -787,9 +1004,10 @@ func (s *StreamAllocator) handleNewEstimateInProbe() {
 
 	trend, _ := s.channelObserver.GetTrend()
 	s.probeController.CheckProbe(trend, s.channelObserver.GetHighestEstimate())
+	return trend
 }
 
-func (s *StreamAllocator) handleNewEstimateInNonProbe() {
+func (s *StreamAllocator) handleNewEstimateInNonProbe() ChannelTrend {
 	s.channelObserver.AddEstimate(s.lastReceivedEstimate)
 
 	packetDelta, repeatedNackDelta := s.getNackDelta()
@@ -797,7 +1015,7 @@ func (s *StreamAllocator) handleNewEstimateInNonProbe() {
 
 	trend, reason := s.channelObserver.GetTrend()
 	if trend != ChannelTrendCongesting {
-		return
+		return trend
 	}
 
 	var estimateToCommit int64
@@ -852,7 +1070,7 @@ func (s *StreamAllocator) handleNewEstimateInNonProbe() {
 	*/
 	if estimateToCommit > commitThreshold {
 		// estimate to commit is either higher or within tolerance of expected uage, skip committing and re-allocating
-		return
+		return trend
 	}
 
 	s.committedChannelCapacity = estimateToCommit
@@ -864,6 +1082,7 @@ func (s *StreamAllocator) handleNewEstimateInNonProbe() {
 	s.probeController.Reset()
 
 	s.allocateAllTracks()
+	return trend
 }
 
 func (s *StreamAllocator) allocateTrack(track *Track) {
@@ -872,8 +1091,32 @@ func (s *StreamAllocator) allocateTrack(track *Track) {
 
 	// if not deficient, free pass allocate track
 	if !s.params.Config.Enabled || s.state == streamAllocatorStateStable || !track.IsManaged() {
+		if s.params.Config.Enabled && track.IsManaged() && !track.IsStarted() && s.params.Config.InitialLayer == config.InitialLayerLowest {
+			// start at the lowest layer, probing moves the track up when the channel allows
+			if allocation, isAllocated := track.AllocateLowest(); isAllocated {
+				track.SetStarted()
+
+				update := NewStreamStateUpdate()
+				updateStreamStateChange(track, allocation, update)
+				s.maybeSendUpdate(update)
+
+				s.adjustState()
+				return
+			}
+		}
+
+		if s.params.Config.Enabled && (s.params.EgressBudget != nil || s.noRTCPChannelCapacity > 0) {
+			// node egress limit is a hard limit, as is the conservative capacity without RTCP feedback,
+			// no free pass, allocate all tracks within them
+			s.allocateAllTracks()
+			return
+		}
+
 		update := NewStreamStateUpdate()
 		allocation := track.AllocateOptimal(FlagAllowOvershootWhileOptimal)
+		if allocation.TargetLayer.IsValid() {
+			track.SetStarted()
+		}
 		updateStreamStateChange(track, allocation, update)
 		s.maybeSendUpdate(update)
 		return
@@ -1122,19 +1365,30 @@ func (s *StreamAllocator) allocateAllTracks() {
 	//
 	// This pass is to find out if there is any leftover channel capacity after allocating exempt tracks.
 	// Exempt tracks are given optimal allocation (i. e. no bandwidth constraint) so that they do not fail allocation.
+	// With a node egress limit, exempt tracks are allocated ahead of managed tracks, but within the egress share.
 	//
 	videoTracks := s.getTracks()
-	for _, track := range videoTracks {
-		if track.IsManaged() {
-			continue
+	if s.params.EgressBudget != nil {
+		var exemptTracks []*Track
+		for _, track := range videoTracks {
+			if !track.IsManaged() {
+				exemptTracks = append(exemptTracks, track)
+			}
 		}
+		availableChannelCapacity = s.provisionalAllocateTracks(exemptTracks, availableChannelCapacity, FlagAllowOvershootExemptTrackWhileDeficient, update)
+	} else {
+		for _, track := range videoTracks {
+			if track.IsManaged() {
+				continue
+			}
 
-		allocation := track.AllocateOptimal(FlagAllowOvershootExemptTrackWhileDeficient)
-		updateStreamStateChange(track, allocation, update)
+			allocation := track.AllocateOptimal(FlagAllowOvershootExemptTrackWhileDeficient)
+			updateStreamStateChange(track, allocation, update)
 
-		// STREAM-ALLOCATOR-TODO: optimistic allocation before bitrate is available will return 0. How to account for that?
-		if !s.params.Config.DisableEstimationUnmanagedTracks {
-			availableChannelCapacity -= allocation.BandwidthRequested
+			// STREAM-ALLOCATOR-TODO: optimistic allocation before bitrate is available will return 0. How to account for that?
+			if !s.params.Config.DisableEstimationUnmanagedTracks {
+				availableChannelCapacity -= allocation.BandwidthRequested
+			}
 		}
 	}
 
@@ -1152,37 +1406,44 @@ func (s *StreamAllocator) allocateAllTracks() {
 			updateStreamStateChange(track, allocation, update)
 		}
 	} else {
-		sorted := s.getSorted()
-		for _, track := range sorted {
-			track.ProvisionalAllocatePrepare()
-		}
+		s.provisionalAllocateTracks(s.getSorted(), availableChannelCapacity, FlagAllowOvershootWhileDeficient, update)
+	}
 
-		for spatial := int32(0); spatial <= buffer.DefaultMaxLayerSpatial; spatial++ {
-			for temporal := int32(0); temporal <= buffer.DefaultMaxLayerTemporal; temporal++ {
-				layer := buffer.VideoLayer{
-					Spatial:  spatial,
-					Temporal: temporal,
-				}
+	s.maybeSendUpdate(update)
 
-				for _, track := range sorted {
-					_, usedChannelCapacity := track.ProvisionalAllocate(availableChannelCapacity, layer, s.allowPause, FlagAllowOvershootWhileDeficient)
-					availableChannelCapacity -= usedChannelCapacity
-					if availableChannelCapacity < 0 {
-						availableChannelCapacity = 0
-					}
-				}
+	s.adjustState()
+}
+
+// provisionalAllocateTracks gives each track a chance at a layer before moving up to the next layer
+// and returns the channel capacity left after committing the allocations
+func (s *StreamAllocator) provisionalAllocateTracks(tracks []*Track, availableChannelCapacity int64, allowOvershoot bool, update *StreamStateUpdate) int64 {
+	for _, track := range tracks {
+		track.ProvisionalAllocatePrepare()
+	}
+
+	for spatial := int32(0); spatial <= buffer.DefaultMaxLayerSpatial; spatial++ {
+		for temporal := int32(0); temporal <= buffer.DefaultMaxLayerTemporal; temporal++ {
+			layer := buffer.VideoLayer{
+				Spatial:  spatial,
+				Temporal: temporal,
 			}
-		}
 
-		for _, track := range sorted {
-			allocation := track.ProvisionalAllocateCommit()
-			updateStreamStateChange(track, allocation, update)
+			for _, track := range tracks {
+				_, usedChannelCapacity := track.ProvisionalAllocate(availableChannelCapacity, layer, s.allowPause, allowOvershoot)
+				availableChannelCapacity -= usedChannelCapacity
+				if availableChannelCapacity < 0 {
+					availableChannelCapacity = 0
+				}
+			}
 		}
 	}
 
-	s.maybeSendUpdate(update)
+	for _, track := range tracks {
+		allocation := track.ProvisionalAllocateCommit()
+		updateStreamStateChange(track, allocation, update)
+	}
 
-	s.adjustState()
+	return availableChannelCapacity
 }
 
 func (s *StreamAllocator) maybeSendUpdate(update *StreamStateUpdate) {
@@ -1197,6 +1458,10 @@ func (s *StreamAllocator) maybeSendUpdate(update *StreamStateUpdate) {
 			"state", streamState.State,
 		)
 	}
+	if s.params.Config.LowBandwidthPolicy == config.LowBandwidthPolicyFreeze {
+		// subscribers are not told of streams pausing and resuming, they keep showing the last frame while paused
+		return
+	}
 	if s.onStreamStateChange != nil {
 		err := s.onStreamStateChange(update)
 		if err != nil {
@@ -1215,6 +1480,14 @@ func (s *StreamAllocator) getAvailableChannelCapacity(allowOverride bool) int64
 			"override", availableChannelCapacity,
 		)
 	}
+	if s.noRTCPChannelCapacity > 0 && s.noRTCPChannelCapacity < availableChannelCapacity {
+		availableChannelCapacity = s.noRTCPChannelCapacity
+		s.params.Logger.Debugw(
+			"stream allocator: overriding channel capacity with no RTCP channel capacity",
+			"actual", s.committedChannelCapacity,
+			"override", availableChannelCapacity,
+		)
+	}
 	if allowOverride && s.overriddenChannelCapacity > 0 {
 		availableChannelCapacity = s.overriddenChannelCapacity
 		s.params.Logger.Debugw(
@@ -1223,6 +1496,15 @@ func (s *StreamAllocator) getAvailableChannelCapacity(allowOverride bool) int64
 			"override", availableChannelCapacity,
 		)
 	}
+	if s.params.EgressBudget != nil && s.egressShare < availableChannelCapacity {
+		// node egress limit is a hard limit, applies over any override
+		availableChannelCapacity = s.egressShare
+		s.params.Logger.Debugw(
+			"stream allocator: limiting channel capacity to egress share",
+			"actual", s.committedChannelCapacity,
+			"share", availableChannelCapacity,
+		)
+	}
 
 	return availableChannelCapacity
 }
@@ -1336,19 +1618,30 @@ func (s *StreamAllocator) maybeProbe() {
 }
 
 func (s *StreamAllocator) maybeProbeWithMedia() {
-	// boost deficient track farthest from desired layer
-	for _, track := range s.getMaxDistanceSortedDeficient() {
-		allocation, boosted := track.AllocateNextHigher(ChannelCapacityInfinity, FlagAllowOvershootInBoost)
+	update := NewStreamStateUpdate()
+	boostedSteps := 0
+	for boostedSteps < rampUpSteps(s.params.Config.RampUpFactor) {
+		// boost deficient track farthest from desired layer
+		boosted := false
+		for _, track := range s.getMaxDistanceSortedDeficient() {
+			var allocation sfu.VideoAllocation
+			allocation, boosted = track.AllocateNextHigher(ChannelCapacityInfinity, FlagAllowOvershootInBoost)
+			if !boosted {
+				continue
+			}
+
+			updateStreamStateChange(track, allocation, update)
+			break
+		}
 		if !boosted {
-			continue
+			break
 		}
+		boostedSteps++
+	}
 
-		update := NewStreamStateUpdate()
-		updateStreamStateChange(track, allocation, update)
+	if boostedSteps != 0 {
 		s.maybeSendUpdate(update)
-
 		s.probeController.Reset()
-		break
 	}
 }
 
@@ -1360,11 +1653,27 @@ func (s *StreamAllocator) maybeProbeWithPadding() {
 			continue
 		}
 
-		s.initProbe(transition.BandwidthDelta)
+		s.initProbe(rampUpProbeGoalDelta(transition.BandwidthDelta, s.params.Config.RampUpFactor))
 		break
 	}
 }
 
+// rampUpSteps returns the number of layers boosted per media probe for the given ramp up factor
+func rampUpSteps(rampUpFactor float64) int {
+	if rampUpFactor <= 1.0 {
+		return 1
+	}
+	return int(math.Round(rampUpFactor))
+}
+
+// rampUpProbeGoalDelta scales the bandwidth to probe for by the given ramp up factor
+func rampUpProbeGoalDelta(deltaBps int64, rampUpFactor float64) int64 {
+	if rampUpFactor <= 1.0 {
+		return deltaBps
+	}
+	return int64(float64(deltaBps) * rampUpFactor)
+}
+
 func (s *StreamAllocator) getTracks() []*Track {
 	s.videoTracksMu.RLock()
 	tracks := make([]*Track, 0, len(s.videoTracks))
@@ -0,0 +1,391 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
+)
+
+type testBandwidthEstimator struct {
+	onTargetBitrateChange func(bitrate int)
+}
+
+var _ cc.BandwidthEstimator = (*testBandwidthEstimator)(nil)
+
+func (t *testBandwidthEstimator) AddStream(_ *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return writer
+}
+
+func (t *testBandwidthEstimator) WriteRTCP([]rtcp.Packet, interceptor.Attributes) error {
+	return nil
+}
+
+func (t *testBandwidthEstimator) GetTargetBitrate() int {
+	return 0
+}
+
+func (t *testBandwidthEstimator) OnTargetBitrateChange(f func(bitrate int)) {
+	t.onTargetBitrateChange = f
+}
+
+func (t *testBandwidthEstimator) GetStats() map[string]interface{} {
+	return nil
+}
+
+func (t *testBandwidthEstimator) Close() error {
+	return nil
+}
+
+func TestBandwidthEstimate(t *testing.T) {
+	s := NewStreamAllocator(StreamAllocatorParams{
+		Config: config.DefaultConfig.RTC.CongestionControl,
+		Logger: logger.GetLogger(),
+	})
+	s.Start()
+	defer s.Stop()
+
+	require.Nil(t, s.GetBandwidthEstimate())
+
+	bwe := &testBandwidthEstimator{}
+	s.SetBandwidthEstimator(bwe)
+	require.NotNil(t, bwe.onTargetBitrateChange)
+
+	bwe.onTargetBitrateChange(1_000_000)
+	bwe.onTargetBitrateChange(2_000_000)
+	require.Eventually(t, func() bool {
+		estimate := s.GetBandwidthEstimate()
+		return estimate != nil && estimate.Estimate == 2_000_000
+	}, time.Second, 10*time.Millisecond)
+
+	estimate := s.GetBandwidthEstimate()
+	require.True(t, estimate.SendSide)
+	require.Equal(t, ChannelTrendNeutral, estimate.Trend)
+	require.False(t, estimate.At.IsZero())
+}
+
+func TestRampUpFactor(t *testing.T) {
+	require.Equal(t, 1, rampUpSteps(0))
+	require.Equal(t, 1, rampUpSteps(1))
+	require.Equal(t, 2, rampUpSteps(1.6))
+	require.Equal(t, 3, rampUpSteps(3))
+
+	// probe goal after bandwidth recovers, next layer needs 500 kbps more than the current usage of 1 Mbps
+	probeConfig := config.DefaultConfig.RTC.CongestionControl.ProbeConfig
+	for factor, expectedGoalBps := range map[float64]int64{
+		0: 1_000_000 + 500_000*probeConfig.OveragePct/100,
+		1: 1_000_000 + 500_000*probeConfig.OveragePct/100,
+		2: 1_000_000 + 1_000_000*probeConfig.OveragePct/100,
+		3: 1_000_000 + 1_500_000*probeConfig.OveragePct/100,
+	} {
+		p := NewProbeController(ProbeControllerParams{
+			Config: probeConfig,
+			Prober: NewProber(ProberParams{Logger: logger.GetLogger()}),
+			Logger: logger.GetLogger(),
+		})
+		_, goalBps := p.InitProbe(rampUpProbeGoalDelta(500_000, factor), 1_000_000)
+		require.Equal(t, expectedGoalBps, goalBps, "factor: %v", factor)
+	}
+}
+
+type testTrackReceiver struct {
+	sfu.TrackReceiver
+
+	trackID livekit.TrackID
+}
+
+func (r *testTrackReceiver) TrackID() livekit.TrackID {
+	return r.trackID
+}
+
+func (r *testTrackReceiver) GetLayeredBitrate() ([]int32, sfu.Bitrates) {
+	return []int32{0, 1}, sfu.Bitrates{
+		{100_000, 150_000, 200_000, 0},
+		{500_000, 600_000, 700_000, 0},
+	}
+}
+
+func (r *testTrackReceiver) GetTemporalLayerFpsForSpatial(_layer int32) []float32 {
+	return nil
+}
+
+func (r *testTrackReceiver) SendPLI(_layer int32, _force bool) {
+}
+
+func (r *testTrackReceiver) DeleteDownTrack(_participantID livekit.ParticipantID) {
+}
+
+func TestRampUpFactorMediaProbe(t *testing.T) {
+	// returns the number of tracks boosted by a media probe with all tracks paused for lack of bandwidth
+	boostedTracks := func(t *testing.T, rampUpFactor float64) int {
+		cfg := config.DefaultConfig.RTC.CongestionControl
+		cfg.ProbeMode = config.CongestionControlProbeModeMedia
+		cfg.RampUpFactor = rampUpFactor
+		s := NewStreamAllocator(StreamAllocatorParams{
+			Config: cfg,
+			Logger: logger.GetLogger(),
+		})
+
+		for _, trackID := range []livekit.TrackID{"TR_1", "TR_2", "TR_3"} {
+			d, err := sfu.NewDownTrack(sfu.DowntrackParams{
+				Codecs: []webrtc.RTPCodecParameters{{
+					RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+					PayloadType:        96,
+				}},
+				Receiver: &testTrackReceiver{trackID: trackID},
+				SubID:    "PA_test",
+				MaxTrack: 100,
+				Logger:   logger.GetLogger(),
+			})
+			require.NoError(t, err)
+			t.Cleanup(func() { d.CloseWithFlush(false) })
+
+			d.SetMaxSpatialLayer(1)
+			d.SetMaxTemporalLayer(2)
+			d.UpTrackMaxPublishedLayerChange(1)
+			d.UpTrackMaxTemporalLayerSeenChange(2)
+			require.True(t, d.Pause().IsDeficient)
+
+			s.videoTracks[trackID] = NewTrack(d, livekit.TrackSource_CAMERA, PriorityDefaultVideo, true, "PA_pub", logger.GetLogger())
+		}
+
+		s.maybeProbeWithMedia()
+
+		boosted := 0
+		for _, track := range s.getTracks() {
+			if track.BandwidthRequested() != 0 {
+				boosted++
+			}
+		}
+		return boosted
+	}
+
+	// a track is boosted a layer at a time, the next boost waits for the switch to the target layer
+	require.Equal(t, 1, boostedTracks(t, 0))
+	require.Equal(t, 2, boostedTracks(t, 2))
+	require.Equal(t, 3, boostedTracks(t, 3))
+	// bounded by the deficient tracks
+	require.Equal(t, 3, boostedTracks(t, 5))
+}
+
+func TestTrackPriorityChanges(t *testing.T) {
+	newTestAllocator := func(policy config.PriorityChangePolicy, minInterval time.Duration) *StreamAllocator {
+		cfg := config.DefaultConfig.RTC.CongestionControl
+		cfg.PriorityChanges = policy
+		cfg.PriorityChangeMinInterval = minInterval
+		return NewStreamAllocator(StreamAllocatorParams{
+			Config: cfg,
+			Logger: logger.GetLogger(),
+		})
+	}
+
+	t.Run("ignored by default", func(t *testing.T) {
+		s := newTestAllocator("", 0)
+		track := newTestTrack(livekit.TrackSource_CAMERA, nil)
+
+		changed, err := s.changeTrackPriority(track, 100)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Equal(t, PriorityDefaultVideo, track.Priority())
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		s := newTestAllocator(config.PriorityChangePolicyAllow, 0)
+		track := newTestTrack(livekit.TrackSource_CAMERA, nil)
+
+		changed, err := s.changeTrackPriority(track, 100)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, uint8(100), track.Priority())
+
+		changed, err = s.changeTrackPriority(track, 200)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, uint8(200), track.Priority())
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		s := newTestAllocator(config.PriorityChangePolicyDeny, 0)
+		track := newTestTrack(livekit.TrackSource_CAMERA, nil)
+
+		_, err := s.changeTrackPriority(track, 100)
+		require.ErrorIs(t, err, ErrPriorityChangeNotAllowed)
+		require.Equal(t, PriorityDefaultVideo, track.Priority())
+
+		// requesting the current priority is not a change
+		changed, err := s.changeTrackPriority(track, 0)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		s := newTestAllocator(config.PriorityChangePolicyAllow, time.Minute)
+		track := newTestTrack(livekit.TrackSource_CAMERA, nil)
+
+		changed, err := s.changeTrackPriority(track, 100)
+		require.NoError(t, err)
+		require.True(t, changed)
+
+		// too soon after the previous change
+		_, err = s.changeTrackPriority(track, 200)
+		require.ErrorIs(t, err, ErrPriorityChangeTooFrequent)
+		require.Equal(t, uint8(100), track.Priority())
+
+		// same priority is not limited
+		changed, err = s.changeTrackPriority(track, 100)
+		require.NoError(t, err)
+		require.False(t, changed)
+
+		track.priorityChangedAt = time.Now().Add(-time.Minute)
+		changed, err = s.changeTrackPriority(track, 200)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, uint8(200), track.Priority())
+	})
+}
+
+func TestLowBandwidthPolicy(t *testing.T) {
+	// stream state updates subscribers are sent when the channel is congested, tracks are paused when allowed
+	congestionUpdates := func(policy config.LowBandwidthPolicy, allowPause bool) (bool, []*StreamStateUpdate) {
+		cfg := config.DefaultConfig.RTC.CongestionControl
+		cfg.AllowPause = allowPause
+		cfg.LowBandwidthPolicy = policy
+		s := NewStreamAllocator(StreamAllocatorParams{
+			Config: cfg,
+			Logger: logger.GetLogger(),
+		})
+
+		var updates []*StreamStateUpdate
+		s.OnStreamStateChange(func(update *StreamStateUpdate) error {
+			updates = append(updates, update)
+			return nil
+		})
+
+		// simulated congestion, the track is paused and later resumed
+		for _, state := range []StreamState{StreamStatePaused, StreamStateActive} {
+			s.maybeSendUpdate(&StreamStateUpdate{
+				StreamStates: []*StreamStateInfo{{
+					ParticipantID: "PA_pub",
+					TrackID:       "TR_video",
+					State:         state,
+				}},
+			})
+		}
+		return s.allowPause, updates
+	}
+
+	// follows allow_pause by default
+	allowPause, updates := congestionUpdates("", true)
+	require.True(t, allowPause)
+	require.Len(t, updates, 2)
+	allowPause, _ = congestionUpdates("", false)
+	require.False(t, allowPause)
+
+	// paused, subscribers are notified
+	allowPause, updates = congestionUpdates(config.LowBandwidthPolicyPause, false)
+	require.True(t, allowPause)
+	require.Len(t, updates, 2)
+	require.Equal(t, StreamStatePaused, updates[0].StreamStates[0].State)
+	require.Equal(t, StreamStateActive, updates[1].StreamStates[0].State)
+
+	// never paused, the lowest layer is forwarded
+	allowPause, _ = congestionUpdates(config.LowBandwidthPolicyLowest, true)
+	require.False(t, allowPause)
+
+	// paused, subscribers are not notified and keep showing the last frame
+	allowPause, updates = congestionUpdates(config.LowBandwidthPolicyFreeze, false)
+	require.True(t, allowPause)
+	require.Empty(t, updates)
+}
+
+func TestNoRTCPFallback(t *testing.T) {
+	timeout := time.Second
+	newStreamAllocator := func(fallback config.NoRTCPFallback) *StreamAllocator {
+		cfg := config.DefaultConfig.RTC.CongestionControl
+		cfg.NoRTCPTimeout = timeout
+		cfg.NoRTCPFallback = fallback
+		s := NewStreamAllocator(StreamAllocatorParams{
+			Config: cfg,
+			Logger: logger.GetLogger(),
+		})
+
+		// media has been sent for the timeout without any feedback
+		now := time.Now()
+		s.rtcpMonitor.PacketsSent(now.Add(-timeout))
+		s.rtcpMonitor.PacketsSent(now)
+		return s
+	}
+
+	// conservative, allocates on the no RTCP channel capacity until feedback arrives
+	s := newStreamAllocator("")
+	s.committedChannelCapacity = ChannelCapacityInfinity
+	s.updateRTCPMonitor()
+	require.Equal(t, int64(NoRTCPChannelCapacityDefault), s.getAvailableChannelCapacity(true))
+
+	s.OnTransportCCFeedback(nil, &rtcp.TransportLayerCC{})
+	s.updateRTCPMonitor()
+	require.Equal(t, int64(ChannelCapacityInfinity), s.getAvailableChannelCapacity(true))
+
+	// no free pass while stable, the track is allocated within the no RTCP channel capacity
+	s = newStreamAllocator("")
+	s.committedChannelCapacity = ChannelCapacityInfinity
+	s.updateRTCPMonitor()
+	d, err := sfu.NewDownTrack(sfu.DowntrackParams{
+		Codecs: []webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+			PayloadType:        96,
+		}},
+		Receiver: &testTrackReceiver{trackID: "TR_1"},
+		SubID:    "PA_test",
+		MaxTrack: 100,
+		Logger:   logger.GetLogger(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { d.CloseWithFlush(false) })
+	d.SetMaxSpatialLayer(1)
+	d.SetMaxTemporalLayer(2)
+	d.UpTrackMaxPublishedLayerChange(1)
+	d.UpTrackMaxTemporalLayerSeenChange(2)
+	track := NewTrack(d, livekit.TrackSource_CAMERA, PriorityDefaultVideo, true, "PA_pub", logger.GetLogger())
+	s.videoTracks["TR_1"] = track
+	require.Equal(t, streamAllocatorStateStable, s.state)
+	s.allocateTrack(track)
+	require.NotZero(t, track.BandwidthRequested())
+	require.LessOrEqual(t, track.BandwidthRequested(), int64(NoRTCPChannelCapacityDefault))
+
+	// disconnect
+	s = newStreamAllocator(config.NoRTCPFallbackDisconnect)
+	s.committedChannelCapacity = ChannelCapacityInfinity
+	noRTCP := 0
+	s.OnNoRTCP(func() {
+		noRTCP++
+	})
+	s.updateRTCPMonitor()
+	s.updateRTCPMonitor()
+	require.Equal(t, 1, noRTCP)
+	require.Equal(t, int64(ChannelCapacityInfinity), s.getAvailableChannelCapacity(true))
+}
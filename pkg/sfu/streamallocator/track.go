@@ -15,6 +15,8 @@
 package streamallocator
 
 import (
+	"time"
+
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
@@ -30,7 +32,15 @@ type Track struct {
 	publisherID livekit.ParticipantID
 	logger      logger.Logger
 
+	// priority of the track when the subscriber has not requested one
+	defaultPriority uint8
+	// time of the last priority change requested by the subscriber
+	priorityChangedAt time.Time
+	// set once the track has been allocated a layer
+	isStarted bool
+
 	maxLayer buffer.VideoLayer
+	isPinned bool
 
 	totalPackets       uint32
 	totalRepeatedNacks uint32
@@ -58,6 +68,7 @@ type Track struct {
 func NewTrack(
 	downTrack *sfu.DownTrack,
 	source livekit.TrackSource,
+	defaultPriority uint8,
 	isSimulcast bool,
 	publisherID livekit.ParticipantID,
 	logger logger.Logger,
@@ -68,6 +79,8 @@ func NewTrack(
 		isSimulcast: isSimulcast,
 		publisherID: publisherID,
 		logger:      logger,
+
+		defaultPriority: defaultPriority,
 		/* STREAM-ALLOCATOR-DATA
 		nackInfos:             make(map[uint16]sfu.NackInfo),
 		nackHistory:           make([]string, 0, 10),
@@ -77,10 +90,24 @@ func NewTrack(
 	}
 	t.SetPriority(0)
 	t.SetMaxLayer(downTrack.MaxLayer())
+	t.SetPinned(downTrack.IsSpatialLayerPinned())
 
 	return t
 }
 
+func (t *Track) SetStarted() bool {
+	if t.isStarted {
+		return false
+	}
+
+	t.isStarted = true
+	return true
+}
+
+func (t *Track) IsStarted() bool {
+	return t.isStarted
+}
+
 func (t *Track) SetDirty(isDirty bool) bool {
 	if t.isDirty == isDirty {
 		return false
@@ -105,12 +132,7 @@ func (t *Track) IsSubscribeMutable() bool {
 
 func (t *Track) SetPriority(priority uint8) bool {
 	if priority == 0 {
-		switch t.source {
-		case livekit.TrackSource_SCREEN_SHARE:
-			priority = PriorityDefaultScreenshare
-		default:
-			priority = PriorityDefaultVideo
-		}
+		priority = t.defaultPriority
 	}
 
 	if t.priority == priority {
@@ -121,6 +143,28 @@ func (t *Track) SetPriority(priority uint8) bool {
 	return true
 }
 
+// IsPriorityChange returns true if setting the given priority would change the priority of the track
+func (t *Track) IsPriorityChange(priority uint8) bool {
+	if priority == 0 {
+		priority = t.defaultPriority
+	}
+	return t.priority != priority
+}
+
+// ChangePriority sets a priority requested by the subscriber and records the time of the change
+func (t *Track) ChangePriority(priority uint8) bool {
+	if !t.SetPriority(priority) {
+		return false
+	}
+
+	t.priorityChangedAt = time.Now()
+	return true
+}
+
+func (t *Track) PriorityChangedAt() time.Time {
+	return t.priorityChangedAt
+}
+
 func (t *Track) Priority() uint8 {
 	return t.priority
 }
@@ -129,8 +173,10 @@ func (t *Track) DownTrack() *sfu.DownTrack {
 	return t.downTrack
 }
 
+// IsManaged returns false for tracks forwarded regardless of the estimated bandwidth,
+// i.e. non-simulcast screen share and tracks pinned to a spatial layer by the subscriber
 func (t *Track) IsManaged() bool {
-	return t.source != livekit.TrackSource_SCREEN_SHARE || t.isSimulcast
+	return (t.source != livekit.TrackSource_SCREEN_SHARE || t.isSimulcast) && !t.isPinned
 }
 
 func (t *Track) ID() livekit.TrackID {
@@ -150,6 +196,15 @@ func (t *Track) SetMaxLayer(layer buffer.VideoLayer) bool {
 	return true
 }
 
+func (t *Track) SetPinned(isPinned bool) bool {
+	if t.isPinned == isPinned {
+		return false
+	}
+
+	t.isPinned = isPinned
+	return true
+}
+
 func (t *Track) WritePaddingRTP(bytesToSend int) int {
 	return t.downTrack.WritePaddingRTP(bytesToSend, false, false)
 }
@@ -158,6 +213,10 @@ func (t *Track) AllocateOptimal(allowOvershoot bool) sfu.VideoAllocation {
 	return t.downTrack.AllocateOptimal(allowOvershoot)
 }
 
+func (t *Track) AllocateLowest() (sfu.VideoAllocation, bool) {
+	return t.downTrack.AllocateLowest()
+}
+
 func (t *Track) ProvisionalAllocatePrepare() {
 	t.downTrack.ProvisionalAllocatePrepare()
 }
@@ -202,6 +261,10 @@ func (t *Track) BandwidthRequested() int64 {
 	return t.downTrack.BandwidthRequested()
 }
 
+func (t *Track) OptimalBandwidthNeeded() int64 {
+	return t.downTrack.OptimalBandwidthNeeded()
+}
+
 func (t *Track) DistanceToDesired() float64 {
 	return t.downTrack.DistanceToDesired()
 }
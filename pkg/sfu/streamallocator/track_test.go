@@ -0,0 +1,120 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func newTestTrack(source livekit.TrackSource, sourcePriorities map[livekit.TrackSource]uint8) *Track {
+	t := &Track{
+		source:          source,
+		defaultPriority: defaultPriorityForSource(source, sourcePriorities),
+	}
+	t.SetPriority(0)
+	return t
+}
+
+func TestSourcePrioritiesFromConfig(t *testing.T) {
+	priorities, err := SourcePrioritiesFromConfig(map[string]uint8{
+		"camera":       200,
+		"SCREEN_SHARE": 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[livekit.TrackSource]uint8{
+		livekit.TrackSource_CAMERA:       200,
+		livekit.TrackSource_SCREEN_SHARE: 10,
+	}, priorities)
+
+	_, err = SourcePrioritiesFromConfig(map[string]uint8{"window": 10})
+	require.Error(t, err)
+
+	_, err = SourcePrioritiesFromConfig(map[string]uint8{"camera": 0})
+	require.Error(t, err)
+}
+
+func TestTrackSourcePriorities(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		camera := newTestTrack(livekit.TrackSource_CAMERA, nil)
+		screenShare := newTestTrack(livekit.TrackSource_SCREEN_SHARE, nil)
+		require.Equal(t, PriorityDefaultVideo, camera.Priority())
+		require.Equal(t, PriorityDefaultScreenshare, screenShare.Priority())
+	})
+
+	t.Run("configured priorities decide allocation order", func(t *testing.T) {
+		sourcePriorities, err := SourcePrioritiesFromConfig(map[string]uint8{
+			"camera":       PriorityMax,
+			"screen_share": 10,
+		})
+		require.NoError(t, err)
+
+		camera := newTestTrack(livekit.TrackSource_CAMERA, sourcePriorities)
+		screenShare := newTestTrack(livekit.TrackSource_SCREEN_SHARE, sourcePriorities)
+		// not configured, keeps the built-in default
+		microphone := newTestTrack(livekit.TrackSource_MICROPHONE, sourcePriorities)
+		require.Equal(t, PriorityDefaultVideo, microphone.Priority())
+
+		// higher priority gets the first shot at each layer when bandwidth is constrained
+		trackSorter := TrackSorter{screenShare, camera}
+		sort.Sort(trackSorter)
+		require.Equal(t, TrackSorter{camera, screenShare}, trackSorter)
+
+		// and is the last to give up bandwidth for other tracks
+		minDistanceSorter := MinDistanceSorter{camera, screenShare}
+		sort.Sort(minDistanceSorter)
+		require.Equal(t, MinDistanceSorter{screenShare, camera}, minDistanceSorter)
+
+		// and the first to recover
+		maxDistanceSorter := MaxDistanceSorter{screenShare, camera}
+		sort.Sort(maxDistanceSorter)
+		require.Equal(t, MaxDistanceSorter{camera, screenShare}, maxDistanceSorter)
+	})
+
+	t.Run("subscriber priority overrides source default", func(t *testing.T) {
+		camera := newTestTrack(livekit.TrackSource_CAMERA, map[livekit.TrackSource]uint8{
+			livekit.TrackSource_CAMERA: 100,
+		})
+		require.True(t, camera.SetPriority(20))
+		require.Equal(t, uint8(20), camera.Priority())
+
+		// resetting goes back to the configured default
+		require.True(t, camera.SetPriority(0))
+		require.Equal(t, uint8(100), camera.Priority())
+	})
+}
+
+func TestTrackPinned(t *testing.T) {
+	camera := newTestTrack(livekit.TrackSource_CAMERA, nil)
+	require.True(t, camera.IsManaged())
+
+	// pinned tracks are allocated optimally, even when the channel is deficient
+	require.True(t, camera.SetPinned(true))
+	require.False(t, camera.IsManaged())
+	require.False(t, camera.SetPinned(true))
+
+	require.True(t, camera.SetPinned(false))
+	require.True(t, camera.IsManaged())
+
+	// non-simulcast screen share is never managed
+	screenShare := newTestTrack(livekit.TrackSource_SCREEN_SHARE, nil)
+	require.False(t, screenShare.IsManaged())
+	screenShare.SetPinned(true)
+	require.False(t, screenShare.IsManaged())
+}
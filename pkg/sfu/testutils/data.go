@@ -95,6 +95,11 @@ var TestVP8Codec = webrtc.RTPCodecCapability{
 	ClockRate: 90000,
 }
 
+var TestH264Codec = webrtc.RTPCodecCapability{
+	MimeType:  "video/h264",
+	ClockRate: 90000,
+}
+
 var TestOpusCodec = webrtc.RTPCodecCapability{
 	MimeType:  "audio/opus",
 	ClockRate: 48000,
@@ -45,6 +45,65 @@ func CodecParametersFuzzySearch(needle webrtc.RTPCodecParameters, haystack []web
 	return webrtc.RTPCodecParameters{}, webrtc.ErrCodecNotFound
 }
 
+// format parameters identifying the payload format of a codec, with the value applying when a parameter is not
+// signalled. Other parameters declare capabilities or preferences and do not prevent a match, e.g.
+// level-asymmetry-allowed and the level of profile-level-id of H264 (RFC 6184) or any opus parameter (RFC 7587)
+var identifyingFmtpParameters = map[string]map[string]string{
+	"video/h264": {"packetization-mode": "0", "profile-level-id": "42000a"},
+	"video/vp9":  {"profile-id": "0"},
+	"video/av1":  {"profile": "0"},
+}
+
+// CodecParametersStrictSearch finds a codec with the mime type, clock rate and payload format of the needle,
+// unlike CodecParametersFuzzySearch it does not fall back to a codec of another payload format with the same
+// mime type. Only format parameters identifying the payload format are compared
+func CodecParametersStrictSearch(needle webrtc.RTPCodecParameters, haystack []webrtc.RTPCodecParameters) (webrtc.RTPCodecParameters, error) {
+	for _, c := range haystack {
+		if strings.EqualFold(c.RTPCodecCapability.MimeType, needle.RTPCodecCapability.MimeType) &&
+			c.RTPCodecCapability.ClockRate == needle.RTPCodecCapability.ClockRate &&
+			isSamePayloadFormat(needle.RTPCodecCapability, c.RTPCodecCapability) {
+			return c, nil
+		}
+	}
+
+	return webrtc.RTPCodecParameters{}, webrtc.ErrCodecNotFound
+}
+
+func isSamePayloadFormat(a, b webrtc.RTPCodecCapability) bool {
+	aFmtp := parseFmtp(a.SDPFmtpLine)
+	bFmtp := parseFmtp(b.SDPFmtpLine)
+	for key, defaultValue := range identifyingFmtpParameters[strings.ToLower(a.MimeType)] {
+		aValue, ok := aFmtp[key]
+		if !ok {
+			aValue = defaultValue
+		}
+		bValue, ok := bFmtp[key]
+		if !ok {
+			bValue = defaultValue
+		}
+		if key == "profile-level-id" {
+			// profile_idc and profile-iop, the level in the last byte does not identify the payload format
+			aValue, bValue = aValue[:min(4, len(aValue))], bValue[:min(4, len(bValue))]
+		}
+		if !strings.EqualFold(aValue, bValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFmtp(line string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(line, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key == "" {
+			continue
+		}
+		params[strings.ToLower(key)] = value
+	}
+	return params
+}
+
 // GetHeaderExtensionID returns the ID of a header extension, or 0 if not found
 func GetHeaderExtensionID(extensions []interceptor.RTPHeaderExtension, extension webrtc.RTPHeaderExtensionCapability) int {
 	for _, h := range extensions {
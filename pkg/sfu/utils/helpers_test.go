@@ -0,0 +1,93 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecParametersSearch(t *testing.T) {
+	opus := func(fmtpLine string) webrtc.RTPCodecParameters {
+		return webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    webrtc.MimeTypeOpus,
+				ClockRate:   48000,
+				Channels:    2,
+				SDPFmtpLine: fmtpLine,
+			},
+			PayloadType: 111,
+		}
+	}
+	h264 := func(fmtpLine string) webrtc.RTPCodecParameters {
+		return webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    webrtc.MimeTypeH264,
+				ClockRate:   90000,
+				SDPFmtpLine: fmtpLine,
+			},
+			PayloadType: 125,
+		}
+	}
+	vp8 := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	}
+
+	t.Run("parameters not identifying the payload format", func(t *testing.T) {
+		upstream := opus("minptime=10;useinbandfec=1")
+		negotiated := []webrtc.RTPCodecParameters{vp8, opus("minptime=10;plc=1;stereo=1")}
+
+		codec, err := CodecParametersStrictSearch(upstream, negotiated)
+		require.NoError(t, err)
+		require.Equal(t, negotiated[1], codec)
+
+		upstream = h264("level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f")
+		negotiated = []webrtc.RTPCodecParameters{vp8, h264("profile-level-id=42e034;packetization-mode=1")}
+
+		codec, err = CodecParametersStrictSearch(upstream, negotiated)
+		require.NoError(t, err)
+		require.Equal(t, negotiated[1], codec)
+	})
+
+	t.Run("different payload format", func(t *testing.T) {
+		upstream := h264("level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f")
+		for _, fmtpLine := range []string{
+			"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032",
+			"level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f",
+			// packetization-mode defaults to 0
+			"level-asymmetry-allowed=1;profile-level-id=42e01f",
+		} {
+			negotiated := []webrtc.RTPCodecParameters{vp8, h264(fmtpLine)}
+
+			codec, err := CodecParametersFuzzySearch(upstream, negotiated)
+			require.NoError(t, err)
+			require.Equal(t, negotiated[1], codec)
+
+			_, err = CodecParametersStrictSearch(upstream, negotiated)
+			require.ErrorIs(t, err, webrtc.ErrCodecNotFound, fmtpLine)
+		}
+	})
+
+	t.Run("different clock rate", func(t *testing.T) {
+		narrowband := opus("minptime=10;useinbandfec=1")
+		narrowband.ClockRate = 16000
+
+		_, err := CodecParametersStrictSearch(opus("minptime=10;useinbandfec=1"), []webrtc.RTPCodecParameters{narrowband})
+		require.ErrorIs(t, err, webrtc.ErrCodecNotFound)
+	})
+}
@@ -40,6 +40,8 @@ type DependencyDescriptor struct {
 	decodeTargetsLock sync.RWMutex
 	decodeTargets     []*DecodeTarget
 	fnWrapper         FrameNumberWrapper
+
+	layerCap buffer.VideoLayer
 }
 
 func NewDependencyDescriptor(logger logger.Logger) *DependencyDescriptor {
@@ -47,6 +49,7 @@ func NewDependencyDescriptor(logger logger.Logger) *DependencyDescriptor {
 		Base:      NewBase(logger),
 		decisions: NewSelectorDecisionCache(256, 80),
 		fnWrapper: FrameNumberWrapper{logger: logger},
+		layerCap:  buffer.InvalidLayer,
 	}
 }
 
@@ -55,6 +58,7 @@ func NewDependencyDescriptorFromNull(vls VideoLayerSelector) *DependencyDescript
 		Base:      vls.(*Null).Base,
 		decisions: NewSelectorDecisionCache(256, 80),
 		fnWrapper: FrameNumberWrapper{logger: vls.(*Null).logger},
+		layerCap:  buffer.InvalidLayer,
 	}
 }
 
@@ -62,6 +66,33 @@ func (d *DependencyDescriptor) IsOvershootOkay() bool {
 	return false
 }
 
+// SetLayerCap sets the highest spatial and temporal layers forwarded, whatever the max layer is.
+// An invalid spatial or temporal layer does not cap that dimension.
+func (d *DependencyDescriptor) SetLayerCap(layerCap buffer.VideoLayer) {
+	d.layerCap = layerCap
+}
+
+func (d *DependencyDescriptor) GetLayerCap() buffer.VideoLayer {
+	return d.layerCap
+}
+
+// GetMax returns the max layer limited by the layer cap, so that allocations do not target layers above the cap
+func (d *DependencyDescriptor) GetMax() buffer.VideoLayer {
+	maxLayer := d.Base.GetMax()
+	if d.layerCap.Spatial != buffer.InvalidLayerSpatial && maxLayer.Spatial > d.layerCap.Spatial {
+		maxLayer.Spatial = d.layerCap.Spatial
+	}
+	if d.layerCap.Temporal != buffer.InvalidLayerTemporal && maxLayer.Temporal > d.layerCap.Temporal {
+		maxLayer.Temporal = d.layerCap.Temporal
+	}
+	return maxLayer
+}
+
+func (d *DependencyDescriptor) exceedsLayerCap(layer buffer.VideoLayer) bool {
+	return (d.layerCap.Spatial != buffer.InvalidLayerSpatial && layer.Spatial > d.layerCap.Spatial) ||
+		(d.layerCap.Temporal != buffer.InvalidLayerTemporal && layer.Temporal > d.layerCap.Temporal)
+}
+
 func (d *DependencyDescriptor) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerSelectorResult) {
 	// a packet is always relevant for the svc codec
 	if d.currentLayer.IsValid() {
@@ -168,7 +199,7 @@ func (d *DependencyDescriptor) Select(extPkt *buffer.ExtPacket, _layer int32) (r
 
 	// decodeTargets be sorted from high to low, find the highest decode target that is active and integrity
 	for _, dt := range d.decodeTargets {
-		if !dt.Active() || dt.Layer.Spatial > d.targetLayer.Spatial || dt.Layer.Temporal > d.targetLayer.Temporal {
+		if !dt.Active() || dt.Layer.Spatial > d.targetLayer.Spatial || dt.Layer.Temporal > d.targetLayer.Temporal || d.exceedsLayerCap(dt.Layer) {
 			continue
 		}
 
@@ -272,6 +272,41 @@ func TestDependencyDescriptor(t *testing.T) {
 	require.False(t, locked)
 }
 
+func TestDependencyDescriptorLayerCap(t *testing.T) {
+	for _, layerCap := range []buffer.VideoLayer{
+		{Spatial: 1, Temporal: 1},
+		{Spatial: 0, Temporal: buffer.InvalidLayerTemporal},
+		{Spatial: buffer.InvalidLayerSpatial, Temporal: 0},
+	} {
+		ddSelector := NewDependencyDescriptor(logger.GetLogger())
+		require.Equal(t, buffer.InvalidLayer, ddSelector.GetLayerCap())
+		ddSelector.SetLayerCap(layerCap)
+
+		maxLayer := buffer.VideoLayer{Spatial: 2, Temporal: 2}
+		ddSelector.SetMax(maxLayer)
+		cappedMax := ddSelector.GetMax()
+		require.False(t, ddSelector.exceedsLayerCap(cappedMax))
+
+		// target above the cap, as set by allocations not aware of it
+		ddSelector.SetTarget(maxLayer)
+		ddSelector.SetRequestSpatial(maxLayer.Spatial)
+
+		var numSelected int
+		for _, frame := range createDDFrames(maxLayer, 1) {
+			fd := frame.DependencyDescriptor.Descriptor.FrameDependencies
+			ret := ddSelector.Select(frame, 0)
+			if !ret.IsSelected {
+				continue
+			}
+			numSelected++
+			require.False(t, ddSelector.exceedsLayerCap(buffer.VideoLayer{Spatial: int32(fd.SpatialId), Temporal: int32(fd.TemporalId)}))
+			require.False(t, ddSelector.exceedsLayerCap(ddSelector.GetCurrent()))
+		}
+		require.NotZero(t, numSelected)
+		require.Equal(t, cappedMax, ddSelector.GetCurrent())
+	}
+}
+
 func createDDFrames(maxLayer buffer.VideoLayer, startFrameNumber uint16) []*buffer.ExtPacket {
 	var frames []*buffer.ExtPacket
 	var activeBitMask uint32
@@ -0,0 +1,71 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/pion/transport/v2/packetio"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BufferFactoryMetrics counts buffer lookups through buffer factories, split by whether a buffer was allocated or
+// an existing one reused, and malformed RTP packets dropped by their buffers. It is set as the observer of buffer
+// factories.
+type BufferFactoryMetrics struct {
+	lookups      *prometheus.CounterVec
+	malformedRTP prometheus.Counter
+}
+
+func NewBufferFactoryMetrics(registerer prometheus.Registerer, constLabels prometheus.Labels) (*BufferFactoryMetrics, error) {
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "buffer_factory",
+		Name:        "total",
+		ConstLabels: constLabels,
+	}, []string{"packet_type", "result"})
+	if err := registerer.Register(lookups); err != nil {
+		return nil, err
+	}
+	malformedRTP := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "rtp",
+		Name:        "malformed_total",
+		ConstLabels: constLabels,
+	})
+	if err := registerer.Register(malformedRTP); err != nil {
+		registerer.Unregister(lookups)
+		return nil, err
+	}
+
+	return &BufferFactoryMetrics{
+		lookups:      lookups,
+		malformedRTP: malformedRTP,
+	}, nil
+}
+
+func (m *BufferFactoryMetrics) OnBufferLookup(packetType packetio.BufferPacketType, allocated bool) {
+	pt := "rtp"
+	if packetType == packetio.RTCPBufferPacket {
+		pt = "rtcp"
+	}
+	result := "reused"
+	if allocated {
+		result = "allocated"
+	}
+	m.lookups.WithLabelValues(pt, result).Inc()
+}
+
+func (m *BufferFactoryMetrics) OnMalformedRTP() {
+	m.malformedRTP.Inc()
+}
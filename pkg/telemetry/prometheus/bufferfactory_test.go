@@ -0,0 +1,71 @@
+// Copyright 2024 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/pion/transport/v2/packetio"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferFactoryMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewBufferFactoryMetrics(registry, nil)
+	require.NoError(t, err)
+
+	counter := func(name string, labels map[string]string) float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != name {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				matched := true
+				for _, l := range m.GetLabel() {
+					if labels[l.GetName()] != l.GetValue() {
+						matched = false
+					}
+				}
+				if matched {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+	lookups := func(packetType string, result string) float64 {
+		return counter("livekit_buffer_factory_total", map[string]string{"packet_type": packetType, "result": result})
+	}
+
+	metrics.OnBufferLookup(packetio.RTPBufferPacket, true)
+	metrics.OnBufferLookup(packetio.RTPBufferPacket, false)
+	metrics.OnBufferLookup(packetio.RTPBufferPacket, false)
+	metrics.OnBufferLookup(packetio.RTCPBufferPacket, true)
+	require.Equal(t, float64(1), lookups("rtp", "allocated"))
+	require.Equal(t, float64(2), lookups("rtp", "reused"))
+	require.Equal(t, float64(1), lookups("rtcp", "allocated"))
+	require.Zero(t, lookups("rtcp", "reused"))
+
+	metrics.OnMalformedRTP()
+	metrics.OnMalformedRTP()
+	require.Equal(t, float64(2), counter("livekit_rtp_malformed_total", nil))
+
+	// registering twice with the same registry fails
+	_, err = NewBufferFactoryMetrics(registry, nil)
+	require.Error(t, err)
+}
@@ -136,10 +136,10 @@ func Init(nodeID string, nodeType livekit.NodeType) error {
 	return nil
 }
 
-func GetUpdatedNodeStats(prev *livekit.NodeStats, prevAverage *livekit.NodeStats) (*livekit.NodeStats, bool, error) {
-	loadAvg, err := getLoadAvg()
-	if err != nil {
-		return nil, false, err
+// GetCPULoad returns the current CPU load of the node, 0 to 1, 0 before the node stats are initialized
+func GetCPULoad() float64 {
+	if cpuStats == nil {
+		return 0
 	}
 
 	var cpuLoad float64
@@ -147,6 +147,16 @@ func GetUpdatedNodeStats(prev *livekit.NodeStats, prevAverage *livekit.NodeStats
 	if cpuIdle > 0 {
 		cpuLoad = 1 - (cpuIdle / cpuStats.NumCPU())
 	}
+	return cpuLoad
+}
+
+func GetUpdatedNodeStats(prev *livekit.NodeStats, prevAverage *livekit.NodeStats) (*livekit.NodeStats, bool, error) {
+	loadAvg, err := getLoadAvg()
+	if err != nil {
+		return nil, false, err
+	}
+
+	cpuLoad := GetCPULoad()
 
 	// On MacOS, get "\"vm_stat\": executable file not found in $PATH" although it is in /usr/bin
 	// So, do not error out. Use the information if it is available.
@@ -59,6 +59,9 @@ var (
 	promNackTotal       *prometheus.CounterVec
 	promPliTotal        *prometheus.CounterVec
 	promFirTotal        *prometheus.CounterVec
+	promUnknownRTCP     *prometheus.CounterVec
+	promMTUExceeded     prometheus.Counter
+	promLossThreshold   *prometheus.CounterVec
 	promPacketLossTotal *prometheus.CounterVec
 	promPacketLoss      *prometheus.HistogramVec
 	promJitter          *prometheus.HistogramVec
@@ -68,6 +71,8 @@ var (
 	promForwardLatency  prometheus.Gauge
 	promForwardJitter   prometheus.Gauge
 
+	bufferFactoryMetrics *BufferFactoryMetrics
+
 	promPacketTotalIncomingInitial    prometheus.Counter
 	promPacketTotalIncomingRetransmit prometheus.Counter
 	promPacketTotalOutgoingInitial    prometheus.Counter
@@ -111,6 +116,25 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType) {
 		Name:        "total",
 		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String()},
 	}, promRTCPLabels)
+	promUnknownRTCP = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "rtcp_unknown",
+		Name:        "total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String()},
+	}, promRTCPLabels)
+	promMTUExceeded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "packet",
+		Name:        "mtu_exceeded_total",
+		Help:        "Packets sent to subscribers dropped as they exceed the configured MTU.",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String()},
+	})
+	promLossThreshold = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "loss_threshold",
+		Name:        "crossed_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String()},
+	}, []string{"kind", "threshold", "crossing"})
 	promPacketLossTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   livekitNamespace,
 		Subsystem:   "packet_loss",
@@ -176,6 +200,9 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType) {
 	prometheus.MustRegister(promNackTotal)
 	prometheus.MustRegister(promPliTotal)
 	prometheus.MustRegister(promFirTotal)
+	prometheus.MustRegister(promUnknownRTCP)
+	prometheus.MustRegister(promMTUExceeded)
+	prometheus.MustRegister(promLossThreshold)
 	prometheus.MustRegister(promPacketLossTotal)
 	prometheus.MustRegister(promPacketLoss)
 	prometheus.MustRegister(promJitter)
@@ -196,6 +223,11 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType) {
 	promPacketBytesOutgoingRetransmit = promPacketBytes.WithLabelValues(string(Outgoing), transmissionRetransmit)
 
 	var err error
+	bufferFactoryMetrics, err = NewBufferFactoryMetrics(prometheus.DefaultRegisterer, prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String()})
+	if err != nil {
+		logger.Errorw("Failed to register buffer factory metrics", err)
+	}
+
 	asnReader, err = geoip2.Open("/opt/maxmind/geoip.db")
 	if err != nil {
 		logger.Errorw("Failed to read geoData", err)
@@ -279,6 +311,34 @@ func IncrementRTCP(direction Direction, nack, pli, fir uint32) {
 	}
 }
 
+// GetBufferFactoryMetrics returns the node wide buffer factory metrics, nil until initialized
+func GetBufferFactoryMetrics() *BufferFactoryMetrics {
+	return bufferFactoryMetrics
+}
+
+func IncrementUnknownRTCP(direction Direction, count int) {
+	if count > 0 && promUnknownRTCP != nil {
+		promUnknownRTCP.WithLabelValues(string(direction)).Add(float64(count))
+	}
+}
+
+func IncrementMTUExceeded() {
+	if promMTUExceeded != nil {
+		promMTUExceeded.Inc()
+	}
+}
+
+func IncrementLossThresholdCrossed(kind string, threshold float64, above bool) {
+	if promLossThreshold == nil {
+		return
+	}
+	crossing := "below"
+	if above {
+		crossing = "above"
+	}
+	promLossThreshold.WithLabelValues(kind, strconv.FormatFloat(threshold, 'f', -1, 64), crossing).Inc()
+}
+
 func RecordPacketLoss(direction Direction, trackSource livekit.TrackSource, trackType livekit.TrackType, lost, total uint32) {
 	if total > 0 {
 		promPacketLoss.WithLabelValues(string(direction), trackSource.String(), trackType.String()).Observe(float64(lost) / float64(total) * 100)